@@ -33,6 +33,7 @@ PUSH_IMAGE=true
 TAG="$DEFAULT_TAG"
 FORCE_LOGIN=false
 QUIET=false
+PLATFORMS="${DOCKER_PLATFORMS:-linux/amd64}"
 
 print_help() {
     echo -e "${CYAN}NebulaGraph Dapr Pluggable Components - Local Build Script${NC}"
@@ -43,6 +44,7 @@ print_help() {
     echo "  -t, --tag TAG          Set image tag (default: latest)"
     echo "  -n, --no-push          Build only, don't push to Docker Hub"
     echo "  -f, --force-login      Force re-authentication"
+    echo "  -p, --platform LIST    Target platforms, e.g. linux/amd64,linux/arm64 (default: linux/amd64)"
     echo "  -q, --quiet            Minimal output"
     echo "  -h, --help             Show this help"
     echo ""
@@ -50,6 +52,7 @@ print_help() {
     echo "  $0                     # Build and push foodinvitesadmin/experiom:latest"
     echo "  $0 -t v1.0.0          # Build and push foodinvitesadmin/experiom:v1.0.0"
     echo "  $0 -n                 # Build only, no push"
+    echo "  $0 -p linux/amd64,linux/arm64 # Build and push a multi-arch image with buildx"
     echo "  $0 -t dev-\$(date +%s) # Build with timestamp tag"
     echo ""
 }
@@ -69,6 +72,10 @@ while [[ $# -gt 0 ]]; do
             FORCE_LOGIN=true
             shift
             ;;
+        -p|--platform)
+            PLATFORMS="$2"
+            shift 2
+            ;;
         -q|--quiet)
             QUIET=true
             shift
@@ -87,6 +94,16 @@ done
 
 FULL_IMAGE_NAME="${REGISTRY_USERNAME}/${IMAGE_NAME}:${TAG}"
 
+# Images for several platforms cannot be loaded locally; buildx pushes them directly
+MULTI_PLATFORM=false
+if [[ "$PLATFORMS" == *,* ]]; then
+    MULTI_PLATFORM=true
+    if [ "$PUSH_IMAGE" = false ]; then
+        echo "Building for several platforms (${PLATFORMS}) requires pushing; drop --no-push or pass one platform"
+        exit 1
+    fi
+fi
+
 # Function to print colored output
 print_status() {
     if [ "$QUIET" = false ]; then
@@ -214,22 +231,26 @@ build_image() {
     # Change to component directory for build
     cd "$COMPONENT_DIR"
     
-    # Build the image
+    # Build the image; several platforms go through buildx and are pushed as one manifest list
+    BUILD_COMMAND=(docker build)
+    if [ "$MULTI_PLATFORM" = true ]; then
+        BUILD_COMMAND=(docker buildx build --push)
+    fi
     if [ "$QUIET" = true ]; then
-        docker build \
+        "${BUILD_COMMAND[@]}" \
             --build-arg BUILDTIME="${BUILD_TIME}" \
             --build-arg VERSION="${BUILD_VERSION}" \
             --build-arg REVISION="${BUILD_REVISION}" \
             --tag "${FULL_IMAGE_NAME}" \
-            --platform linux/amd64 \
+            --platform "${PLATFORMS}" \
             . > /dev/null 2>&1
     else
-        docker build \
+        "${BUILD_COMMAND[@]}" \
             --build-arg BUILDTIME="${BUILD_TIME}" \
             --build-arg VERSION="${BUILD_VERSION}" \
             --build-arg REVISION="${BUILD_REVISION}" \
             --tag "${FULL_IMAGE_NAME}" \
-            --platform linux/amd64 \
+            --platform "${PLATFORMS}" \
             .
     fi
 
@@ -250,6 +271,10 @@ push_image() {
         print_status "Skipping push (--no-push specified)"
         return 0
     fi
+    if [ "$MULTI_PLATFORM" = true ]; then
+        print_status "Multi-platform image was pushed by buildx"
+        return 0
+    fi
 
     print_status "Pushing image to Docker Hub..."
     
@@ -287,6 +312,7 @@ main() {
         echo "Configuration:"
         echo "  - Image: ${FULL_IMAGE_NAME}"
         echo "  - Push: ${PUSH_IMAGE}"
+        echo "  - Platforms: ${PLATFORMS}"
         echo "  - Component Dir: ${COMPONENT_DIR}"
         echo ""
     fi
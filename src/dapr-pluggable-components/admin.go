@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time a removal waits for the requests of the store by default
+const defaultDrainTimeout = 30 * time.Second
+
+// routerRegistry tracks the routers of the component instances created by the
+// Dapr runtime, so named stores can be added and removed at runtime.
+var routerRegistry struct {
+	mu      sync.Mutex
+	routers []*storeRouter
+}
+
+// trackRouter registers the router of a component instance.
+func trackRouter(router *storeRouter) *storeRouter {
+	routerRegistry.mu.Lock()
+	defer routerRegistry.mu.Unlock()
+	routerRegistry.routers = append(routerRegistry.routers, router)
+	return router
+}
+
+// componentRouters returns the routers of the instances the sidecar
+// initialized as component, or every initialized one when component is empty.
+func componentRouters(component string) []*storeRouter {
+	routerRegistry.mu.Lock()
+	tracked := slices.Clone(routerRegistry.routers)
+	routerRegistry.mu.Unlock()
+
+	var routers []*storeRouter
+	for _, router := range tracked {
+		if name, ok := router.componentName(); ok && (component == "" || name == component) {
+			routers = append(routers, router)
+		}
+	}
+	return routers
+}
+
+// addStoreRequest is the body of a request adding a named store.
+type addStoreRequest struct {
+	Component string            `json:"component"`
+	Name      string            `json:"name"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+// serveAdminStores lists the named stores of every component instance, adds
+// one (POST) or drains and removes one (DELETE). Stores are added to and
+// removed from component instances the sidecar already uses, so no socket
+// appears or goes away and daprd needs no reload.
+//
+// The diagnostics port is on loopback, which every container of a pod shares,
+// so adding and removing require the component's adminToken as a bearer
+// token; components without one cannot be changed at runtime.
+func serveAdminStores(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listNamedStores(w)
+	case http.MethodPost:
+		addNamedStore(w, r)
+	case http.MethodDelete:
+		removeNamedStore(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorizedRouters returns the routers of component when the request carries
+// its adminToken, and otherwise answers the request and returns false.
+func authorizedRouters(w http.ResponseWriter, r *http.Request, component string) ([]*storeRouter, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "the component's adminToken is required as a bearer token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	routers := componentRouters(component)
+	if len(routers) == 0 {
+		http.Error(w, fmt.Sprintf("unknown component %q", component), http.StatusNotFound)
+		return nil, false
+	}
+	for _, router := range routers {
+		if !router.validAdminToken(token) {
+			fmt.Printf("WARNING: Rejected a change to the stores of component %s without a valid adminToken\n", component)
+			http.Error(w, fmt.Sprintf("invalid adminToken for component %q, or it has none configured", component), http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return routers, true
+}
+
+func listNamedStores(w http.ResponseWriter) {
+	components := []map[string]any{}
+	for _, router := range componentRouters("") {
+		name, _ := router.componentName()
+		stores := append([]string{}, slices.Sorted(maps.Keys(router.namedInstances()))...)
+		components = append(components, map[string]any{
+			"component": name,
+			"stores":    stores,
+			"added":     append([]string{}, router.addedStores()...),
+		})
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i]["component"].(string) < components[j]["component"].(string)
+	})
+	writeJSON(w, map[string]any{"components": components})
+}
+
+func addNamedStore(w http.ResponseWriter, r *http.Request) {
+	var req addStoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Component == "" || req.Name == "" {
+		http.Error(w, "component and name are required", http.StatusBadRequest)
+		return
+	}
+
+	routers, ok := authorizedRouters(w, r, req.Component)
+	if !ok {
+		return
+	}
+
+	// Every instance of the component serves the store, each with its own
+	// connections; one failing leaves the others serving it
+	var errs []error
+	for _, router := range routers {
+		if err := router.addStore(r.Context(), req.Name, req.Metadata); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		fmt.Printf("ERROR: Failed to add store %s to component %s: %v\n", req.Name, req.Component, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	fmt.Printf("DEBUG: Added store %s to component %s\n", req.Name, req.Component)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, map[string]any{"component": req.Component, "store": req.Name, "instances": len(routers)})
+}
+
+func removeNamedStore(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	component, name := query.Get("component"), query.Get("name")
+	if component == "" || name == "" {
+		http.Error(w, "component and name are required", http.StatusBadRequest)
+		return
+	}
+	timeout := defaultDrainTimeout
+	if value := query.Get("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "timeout must be a positive duration", http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+
+	routers, ok := authorizedRouters(w, r, component)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	var errs []error
+	removed := 0
+	for _, router := range routers {
+		if err := router.removeStore(ctx, name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	if removed == 0 && ctx.Err() == nil {
+		http.Error(w, errors.Join(errs...).Error(), http.StatusNotFound)
+		return
+	}
+	if err := errors.Join(errs...); err != nil && ctx.Err() != nil {
+		fmt.Printf("WARNING: Store %s of component %s is still draining: %v\n", name, component, err)
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+
+	fmt.Printf("DEBUG: Removed store %s from component %s\n", name, component)
+	writeJSON(w, map[string]any{"component": component, "store": name, "instances": removed})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dapr/components-contrib/metadata"
+	"github.com/dapr/components-contrib/state"
+)
+
+// initOnlyStore is a store that only needs to initialize.
+type initOnlyStore struct {
+	state.Store
+}
+
+func (s *initOnlyStore) Init(context.Context, state.Metadata) error {
+	return nil
+}
+
+func TestAdminStoresAuthorization(t *testing.T) {
+	// Components tracked for every case: one with an adminToken, one without
+	for component, properties := range map[string]map[string]string{
+		"admin-secured":  {adminTokenProperty: "secret"},
+		"admin-no-token": {},
+	} {
+		router := trackRouter(newStoreRouter(func() state.Store { return &initOnlyStore{} }))
+		err := router.Init(context.Background(), state.Metadata{Base: metadata.Base{Name: component, Properties: properties}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		component     string
+		authorization string
+		want          int
+	}{
+		{"add without token", http.MethodPost, "admin-secured", "", http.StatusUnauthorized},
+		{"add with another scheme", http.MethodPost, "admin-secured", "Basic c2VjcmV0", http.StatusUnauthorized},
+		{"add with empty token", http.MethodPost, "admin-secured", "Bearer ", http.StatusUnauthorized},
+		{"add with wrong token", http.MethodPost, "admin-secured", "Bearer wrong", http.StatusForbidden},
+		{"add to component without adminToken", http.MethodPost, "admin-no-token", "Bearer secret", http.StatusForbidden},
+		{"add to unknown component", http.MethodPost, "admin-unknown", "Bearer secret", http.StatusNotFound},
+		{"add", http.MethodPost, "admin-secured", "Bearer secret", http.StatusCreated},
+		{"remove without token", http.MethodDelete, "admin-secured", "", http.StatusUnauthorized},
+		{"remove with wrong token", http.MethodDelete, "admin-secured", "Bearer wrong", http.StatusForbidden},
+		{"remove from component without adminToken", http.MethodDelete, "admin-no-token", "Bearer secret", http.StatusForbidden},
+		{"remove", http.MethodDelete, "admin-secured", "Bearer secret", http.StatusOK},
+		{"list without token", http.MethodGet, "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			switch tt.method {
+			case http.MethodPost:
+				body := `{"component":"` + tt.component + `","name":"tenant42","metadata":{"keyspace":"tenant42"}}`
+				req = httptest.NewRequest(tt.method, "/admin/stores", strings.NewReader(body))
+			case http.MethodDelete:
+				req = httptest.NewRequest(tt.method, "/admin/stores?component="+tt.component+"&name=tenant42", nil)
+			default:
+				req = httptest.NewRequest(tt.method, "/admin/stores", nil)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			serveAdminStores(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("%s status = %d, want %d (%s)", tt.method, recorder.Code, tt.want, strings.TrimSpace(recorder.Body.String()))
+			}
+		})
+	}
+
+	for _, router := range componentRouters("admin-secured") {
+		if added := router.addedStores(); len(added) != 0 {
+			t.Errorf("stores left after the authorized removal: %v", added)
+		}
+	}
+	for _, router := range componentRouters("admin-no-token") {
+		if added := router.addedStores(); len(added) != 0 {
+			t.Errorf("component without adminToken was changed: %v", added)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dapr/components-contrib/state"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"nebulagraph/stores/stateext"
+)
+
+// Component metadata property restricting the operations an instance serves
+const allowedOperationsProperty = "allowedOperations"
+
+// Operation names accepted in allowedOperations
+const (
+	operationGet              = "get"
+	operationBulkGet          = "bulkGet"
+	operationSet              = "set"
+	operationBulkSet          = "bulkSet"
+	operationDelete           = "delete"
+	operationBulkDelete       = "bulkDelete"
+	operationTransact         = "transact"
+	operationQuery            = "query"
+	operationDeleteWithPrefix = "deleteWithPrefix"
+	operationRefreshSchema    = "refreshSchema"
+	operationProvision        = "provision"
+	operationPassthrough      = "passthrough"
+	operationDiagnostics      = "diagnostics"
+	operationForceWrite       = "forceWrite"
+)
+
+var knownOperations = []string{
+	operationGet, operationBulkGet, operationSet, operationBulkSet, operationDelete,
+	operationBulkDelete, operationTransact, operationQuery, operationDeleteWithPrefix,
+	operationRefreshSchema, operationProvision, operationPassthrough, operationDiagnostics,
+	operationForceWrite,
+}
+
+// adminQueryOperations maps the request metadata of the administrative
+// operations stores run inside Query to the operation that allows them, so
+// allowing query does not allow deleting or provisioning too. The reports on
+// the whole store (stats, hotKeys, usageReport, observedSchema) name keys and
+// tenants the caller may not otherwise know, so they need diagnostics.
+//
+// Watches (watch, watchPrefix) and key group listings (keyGroup) are
+// deliberately ordinary queries: they only return keys and values a query
+// could read.
+var adminQueryOperations = []struct {
+	metadataKey string
+	operation   string
+}{
+	{"deletePrefix", operationDeleteWithPrefix},
+	{"refreshSchema", operationRefreshSchema},
+	{"provision", operationProvision},
+	{"cql", operationPassthrough},
+	{"stats", operationDiagnostics},
+	{"hotKeys", operationDiagnostics},
+	{"usageReport", operationDiagnostics},
+	{"observedSchema", operationDiagnostics},
+}
+
+// Request metadata skipping the ETag checks of a write, which needs
+// forceWrite on top of the write operation itself
+const forceWriteMetadataKey = "forceWrite"
+
+// operationGuard wraps every registered store and rejects the operations a
+// component instance does not allow, e.g. a read-only component for reporting
+// apps with allowedOperations "get,bulkGet,query". Administrative queries are
+// checked against their own operations (see adminQueryOperations), and writes
+// with forceWrite need forceWrite as well. Blocked
+// operations fail with PermissionDenied before reaching the store. Without
+// allowedOperations every operation is allowed.
+//
+// The SDK looks for transactions and queries by type assertion, so the guard
+// always implements them and answers Unimplemented, like the SDK, when the
+// wrapped store does not.
+type operationGuard struct {
+	state.Store
+
+	// Allowed operations, nil when all are allowed; set by Init
+	allowed atomic.Pointer[[]string]
+}
+
+// Compile time check to ensure operationGuard forwards the optional capabilities
+var (
+	_ state.TransactionalStore  = (*operationGuard)(nil)
+	_ state.Querier             = (*operationGuard)(nil)
+	_ stateext.DeleteWithPrefix = (*operationGuard)(nil)
+)
+
+func newOperationGuard(store state.Store) *operationGuard {
+	return &operationGuard{Store: store}
+}
+
+func (g *operationGuard) Init(ctx context.Context, metadata state.Metadata) error {
+	allowed, err := parseAllowedOperations(metadata.Properties[allowedOperationsProperty])
+	if err != nil {
+		return err
+	}
+	if allowed != nil {
+		g.allowed.Store(&allowed)
+	} else {
+		g.allowed.Store(nil)
+	}
+	if err := g.Store.Init(ctx, metadata); err != nil {
+		return err
+	}
+	logCapabilities(ctx, g, metadata)
+	return nil
+}
+
+// parseAllowedOperations parses a comma-separated allowlist. An unknown name
+// fails Init rather than being ignored, since a typo would otherwise silently
+// block an operation the operator meant to allow.
+func parseAllowedOperations(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	allowed := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(knownOperations, name) {
+			return nil, fmt.Errorf("unknown operation %q in %s; use %s", name, allowedOperationsProperty, strings.Join(knownOperations, ", "))
+		}
+		if !slices.Contains(allowed, name) {
+			allowed = append(allowed, name)
+		}
+	}
+	return allowed, nil
+}
+
+// check returns a PermissionDenied status when operation is not allowed.
+func (g *operationGuard) check(operation string) error {
+	allowed := g.allowed.Load()
+	if allowed == nil || slices.Contains(*allowed, operation) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "operation %s is not allowed on this component; %s is %q",
+		operation, allowedOperationsProperty, strings.Join(*allowed, ","))
+}
+
+// checkWrite checks a write operation, and forceWrite when any of metadata
+// forces the write.
+func (g *operationGuard) checkWrite(operation string, metadata ...map[string]string) error {
+	if err := g.check(operation); err != nil {
+		return err
+	}
+	for _, md := range metadata {
+		if md[forceWriteMetadataKey] == "true" {
+			return g.check(operationForceWrite)
+		}
+	}
+	return nil
+}
+
+// checkQuery checks the operations a Query performs: every administrative
+// operation named by its metadata, or query when there is none.
+func (g *operationGuard) checkQuery(metadata map[string]string) error {
+	admin := false
+	for _, op := range adminQueryOperations {
+		if _, ok := metadata[op.metadataKey]; !ok {
+			continue
+		}
+		admin = true
+		if err := g.check(op.operation); err != nil {
+			return err
+		}
+	}
+	if admin {
+		return nil
+	}
+	return g.check(operationQuery)
+}
+
+func (g *operationGuard) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	if err := g.check(operationGet); err != nil {
+		return nil, err
+	}
+	return g.Store.Get(ctx, req)
+}
+
+func (g *operationGuard) BulkGet(ctx context.Context, req []state.GetRequest, opts state.BulkGetOpts) ([]state.BulkGetResponse, error) {
+	if err := g.check(operationBulkGet); err != nil {
+		return nil, err
+	}
+	return g.Store.BulkGet(ctx, req, opts)
+}
+
+func (g *operationGuard) Set(ctx context.Context, req *state.SetRequest) error {
+	if err := g.checkWrite(operationSet, req.Metadata); err != nil {
+		return err
+	}
+	return g.Store.Set(ctx, req)
+}
+
+func (g *operationGuard) BulkSet(ctx context.Context, req []state.SetRequest, opts state.BulkStoreOpts) error {
+	metadata := make([]map[string]string, len(req))
+	for i := range req {
+		metadata[i] = req[i].Metadata
+	}
+	if err := g.checkWrite(operationBulkSet, metadata...); err != nil {
+		return err
+	}
+	return g.Store.BulkSet(ctx, req, opts)
+}
+
+func (g *operationGuard) Delete(ctx context.Context, req *state.DeleteRequest) error {
+	if err := g.checkWrite(operationDelete, req.Metadata); err != nil {
+		return err
+	}
+	return g.Store.Delete(ctx, req)
+}
+
+func (g *operationGuard) BulkDelete(ctx context.Context, req []state.DeleteRequest, opts state.BulkStoreOpts) error {
+	metadata := make([]map[string]string, len(req))
+	for i := range req {
+		metadata[i] = req[i].Metadata
+	}
+	if err := g.checkWrite(operationBulkDelete, metadata...); err != nil {
+		return err
+	}
+	return g.Store.BulkDelete(ctx, req, opts)
+}
+
+func (g *operationGuard) Multi(ctx context.Context, request *state.TransactionalStateRequest) error {
+	transactional, ok := g.Store.(state.TransactionalStore)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "method Transact not implemented")
+	}
+	var metadata []map[string]string
+	if request != nil {
+		metadata = append(metadata, request.Metadata)
+		for _, op := range request.Operations {
+			switch req := op.(type) {
+			case state.SetRequest:
+				metadata = append(metadata, req.Metadata)
+			case state.DeleteRequest:
+				metadata = append(metadata, req.Metadata)
+			}
+		}
+	}
+	if err := g.checkWrite(operationTransact, metadata...); err != nil {
+		return err
+	}
+	return transactional.Multi(ctx, request)
+}
+
+func (g *operationGuard) Query(ctx context.Context, req *state.QueryRequest) (*state.QueryResponse, error) {
+	querier, ok := g.Store.(state.Querier)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+	}
+	if err := g.checkQuery(req.Metadata); err != nil {
+		return nil, err
+	}
+	return querier.Query(ctx, req)
+}
+
+func (g *operationGuard) DeleteWithPrefix(ctx context.Context, req stateext.DeleteWithPrefixRequest) (stateext.DeleteWithPrefixResponse, error) {
+	deleter, ok := g.Store.(stateext.DeleteWithPrefix)
+	if !ok {
+		return stateext.DeleteWithPrefixResponse{}, status.Errorf(codes.Unimplemented, "method DeleteWithPrefix not implemented")
+	}
+	if err := g.check(operationDeleteWithPrefix); err != nil {
+		return stateext.DeleteWithPrefixResponse{}, err
+	}
+	return deleter.DeleteWithPrefix(ctx, req)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// acceptingStore is a store accepting every request.
+type acceptingStore struct {
+	state.Store
+}
+
+func (s *acceptingStore) Set(context.Context, *state.SetRequest) error { return nil }
+
+func (s *acceptingStore) BulkSet(context.Context, []state.SetRequest, state.BulkStoreOpts) error {
+	return nil
+}
+
+func (s *acceptingStore) Delete(context.Context, *state.DeleteRequest) error { return nil }
+
+func (s *acceptingStore) BulkDelete(context.Context, []state.DeleteRequest, state.BulkStoreOpts) error {
+	return nil
+}
+
+func (s *acceptingStore) Multi(context.Context, *state.TransactionalStateRequest) error { return nil }
+
+func (s *acceptingStore) Query(context.Context, *state.QueryRequest) (*state.QueryResponse, error) {
+	return &state.QueryResponse{}, nil
+}
+
+// guardAllowing returns an operation guard allowing the operations in allowed.
+func guardAllowing(t *testing.T, allowed string) *operationGuard {
+	t.Helper()
+	operations, err := parseAllowedOperations(allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newOperationGuard(&acceptingStore{})
+	g.allowed.Store(&operations)
+	return g
+}
+
+func TestOperationGuardQueryClassification(t *testing.T) {
+	// Operation each query metadata key needs
+	tests := []struct {
+		metadataKey string
+		operation   string
+	}{
+		{"deletePrefix", operationDeleteWithPrefix},
+		{"refreshSchema", operationRefreshSchema},
+		{"provision", operationProvision},
+		{"cql", operationPassthrough},
+		{"stats", operationDiagnostics},
+		{"hotKeys", operationDiagnostics},
+		{"usageReport", operationDiagnostics},
+		{"observedSchema", operationDiagnostics},
+		// Deliberately ordinary queries
+		{"watch", operationQuery},
+		{"watchPrefix", operationQuery},
+		{"keyGroup", operationQuery},
+		{"fullText", operationQuery},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metadataKey, func(t *testing.T) {
+			req := &state.QueryRequest{Metadata: map[string]string{tt.metadataKey: "true"}}
+
+			if _, err := guardAllowing(t, tt.operation).Query(context.Background(), req); err != nil {
+				t.Errorf("allowing %s: unexpected error: %v", tt.operation, err)
+			}
+
+			// Every other operation, query included unless it is the one needed
+			var others []string
+			for _, operation := range knownOperations {
+				if operation != tt.operation {
+					others = append(others, operation)
+				}
+			}
+			_, err := guardAllowing(t, strings.Join(others, ",")).Query(context.Background(), req)
+			if status.Code(err) != codes.PermissionDenied {
+				t.Errorf("without %s: got %v, want PermissionDenied", tt.operation, err)
+			}
+		})
+	}
+}
+
+func TestOperationGuardForceWrite(t *testing.T) {
+	forced := map[string]string{forceWriteMetadataKey: "true"}
+	ctx := context.Background()
+
+	// Each write, forced through the metadata of the request or of one item
+	writes := map[string]struct {
+		operation string
+		write     func(g *operationGuard, metadata map[string]string) error
+	}{
+		"set": {operationSet, func(g *operationGuard, metadata map[string]string) error {
+			return g.Set(ctx, &state.SetRequest{Key: "k", Metadata: metadata})
+		}},
+		"bulk set": {operationBulkSet, func(g *operationGuard, metadata map[string]string) error {
+			return g.BulkSet(ctx, []state.SetRequest{{Key: "a"}, {Key: "k", Metadata: metadata}}, state.BulkStoreOpts{})
+		}},
+		"delete": {operationDelete, func(g *operationGuard, metadata map[string]string) error {
+			return g.Delete(ctx, &state.DeleteRequest{Key: "k", Metadata: metadata})
+		}},
+		"bulk delete": {operationBulkDelete, func(g *operationGuard, metadata map[string]string) error {
+			return g.BulkDelete(ctx, []state.DeleteRequest{{Key: "a"}, {Key: "k", Metadata: metadata}}, state.BulkStoreOpts{})
+		}},
+		"transaction": {operationTransact, func(g *operationGuard, metadata map[string]string) error {
+			return g.Multi(ctx, &state.TransactionalStateRequest{Metadata: metadata})
+		}},
+		"transaction operation": {operationTransact, func(g *operationGuard, metadata map[string]string) error {
+			return g.Multi(ctx, &state.TransactionalStateRequest{Operations: []state.TransactionalStateOperation{
+				state.SetRequest{Key: "a"},
+				state.DeleteRequest{Key: "k", Metadata: metadata},
+			}})
+		}},
+	}
+
+	for name, w := range writes {
+		t.Run(name, func(t *testing.T) {
+			tests := []struct {
+				allowed  string
+				metadata map[string]string
+				want     codes.Code
+			}{
+				{allowed: w.operation, want: codes.OK},
+				{allowed: w.operation, metadata: map[string]string{forceWriteMetadataKey: "false"}, want: codes.OK},
+				{allowed: w.operation, metadata: forced, want: codes.PermissionDenied},
+				{allowed: w.operation + "," + operationForceWrite, metadata: forced, want: codes.OK},
+				{allowed: operationForceWrite, metadata: forced, want: codes.PermissionDenied},
+			}
+			for _, tt := range tests {
+				err := w.write(guardAllowing(t, tt.allowed), tt.metadata)
+				if got := status.Code(err); got != tt.want {
+					t.Errorf("allowing %q with metadata %v: got %v (%v), want %v", tt.allowed, tt.metadata, got, err, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/dapr/components-contrib/state"
+	"google.golang.org/grpc/metadata"
+)
+
+// Component metadata property through which the sidecar enables automatic
+// state encryption
+const primaryEncryptionKeyProperty = "primaryEncryptionKey"
+
+// capabilityReporter is implemented by stores that can describe their backend
+// and the features they have enabled.
+type capabilityReporter interface {
+	Capabilities() map[string]any
+}
+
+// capabilityRegistry keeps the capability matrix of every initialized
+// component instance for the diagnostics endpoint. An instance initialized
+// again replaces its matrix.
+var capabilityRegistry struct {
+	mu       sync.Mutex
+	matrices map[*operationGuard]map[string]any
+}
+
+// logCapabilities logs, as one JSON line, the capability matrix of a
+// component instance once its Init succeeded, and records it for support
+// bundles. The pluggable components protocol carries no sidecar version to
+// negotiate against: daprd reads the features through the Features call and
+// disables what a component does not advertise, and the SDK answers
+// Unimplemented for the optional services a store lacks. The matrix records
+// what this instance implements, with the sidecar's gRPC user agent, for
+// matching against the sidecar's own logs, and what each store reports of its
+// backend: version, enabled features, pool and schema.
+func logCapabilities(ctx context.Context, g *operationGuard, md state.Metadata) {
+	matrix := capabilityMatrix(g, md)
+
+	userAgent := "unknown"
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		if agents := incoming.Get("user-agent"); len(agents) > 0 {
+			userAgent = agents[0]
+		}
+	}
+	matrix["userAgent"] = userAgent
+
+	capabilityRegistry.mu.Lock()
+	if capabilityRegistry.matrices == nil {
+		capabilityRegistry.matrices = make(map[*operationGuard]map[string]any)
+	}
+	capabilityRegistry.matrices[g] = matrix
+	capabilityRegistry.mu.Unlock()
+
+	line, err := json.Marshal(matrix)
+	if err != nil {
+		fmt.Printf("WARNING: Failed to encode capabilities: %v\n", err)
+		return
+	}
+	fmt.Println(string(line))
+}
+
+// capabilityMatrix describes a component instance: the services and features
+// offered to the sidecar, the operations allowed, and the capabilities of its
+// default and named stores.
+func capabilityMatrix(g *operationGuard, md state.Metadata) map[string]any {
+	// The router answers for every capability; its default instance tells
+	store := g.Store
+	var named map[string]*routedStore
+	if router, ok := store.(*storeRouter); ok {
+		store = router.Store
+		named = router.namedInstances()
+	}
+
+	services := []string{"StateStore"}
+	if _, ok := store.(state.TransactionalStore); ok {
+		services = append(services, "TransactionalStateStore")
+	}
+	if _, ok := store.(state.Querier); ok {
+		services = append(services, "QueriableStateStore")
+	}
+
+	features := make([]string, 0, len(store.Features()))
+	for _, feature := range store.Features() {
+		features = append(features, string(feature))
+	}
+
+	allowed := []string{"all"}
+	if operations := g.allowed.Load(); operations != nil {
+		allowed = *operations
+	}
+
+	matrix := map[string]any{
+		"msg":               "capabilities",
+		"component":         md.Name,
+		"version":           version,
+		"implemented":       services,
+		"features":          features,
+		"allowedOperations": allowed,
+		"sidecarEncryption": md.Properties[primaryEncryptionKeyProperty] != "",
+		"store":             storeCapabilities(store),
+	}
+	if named != nil {
+		stores := make(map[string]any, len(named))
+		for name, instance := range named {
+			stores[name] = storeCapabilities(instance.Store)
+		}
+		matrix["namedStores"] = stores
+	}
+	return matrix
+}
+
+// storeCapabilities returns what the store reports of itself, or nil.
+func storeCapabilities(store state.Store) map[string]any {
+	if reporter, ok := store.(capabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return nil
+}
+
+// recordedCapabilities returns the recorded matrices, ordered by component.
+func recordedCapabilities() []map[string]any {
+	capabilityRegistry.mu.Lock()
+	matrices := make([]map[string]any, 0, len(capabilityRegistry.matrices))
+	for _, matrix := range capabilityRegistry.matrices {
+		matrices = append(matrices, matrix)
+	}
+	capabilityRegistry.mu.Unlock()
+
+	sort.Slice(matrices, func(i, j int) bool {
+		return fmt.Sprint(matrices[i]["component"]) < fmt.Sprint(matrices[j]["component"])
+	})
+	return matrices
+}
+
+// serveCapabilities reports the capability matrix of every initialized
+// component instance, as logged at startup.
+func serveCapabilities(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{"components": recordedCapabilities()})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	scyllastore "nebulagraph/stores/scylladb"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dapr/components-contrib/metadata"
+	contribstate "github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+)
+
+// runCompactionReport implements the compaction-report subcommand: it samples
+// a ScyllaDB or Cassandra state table and its indexes, and prints the size
+// and expiry figures with the recommended TTL, retention and compaction
+// settings.
+func runCompactionReport(args []string) int {
+	var sets multiFlag
+	flags := flag.NewFlagSet("compaction-report", flag.ExitOnError)
+	storeType := flags.String("store", "scylladb", "Store type: scylladb or cassandra")
+	sampleRows := flags.Int("sample-rows", 100000, "Rows of the state table read")
+	staleAfter := flags.Duration("stale-after", 90*24*time.Hour, "Age from which rows without a TTL count as stale")
+	asJSON := flags.Bool("json", false, "Print the report as JSON")
+	flags.Var(&sets, "set", "Component metadata entry name=value, repeatable")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s compaction-report -store <type> -set name=value ... [flags]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Samples the state table and recommends TTL, retention and compaction settings.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	properties := make(map[string]string, len(sets))
+	for _, entry := range sets {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -set %q; use name=value\n", entry)
+			return 1
+		}
+		properties[name] = value
+	}
+	// The report only reads; background jobs would write
+	for _, name := range []string{"blobMigration", "mirrorReconcileInterval", "fixtures"} {
+		delete(properties, name)
+	}
+
+	var store *scyllastore.ScyllaStateStore
+	switch *storeType {
+	case "scylladb":
+		store = scyllastore.NewScyllaStateStore(logger.NewLogger("scylladb-state")).(*scyllastore.ScyllaStateStore)
+	case "cassandra":
+		store = scyllastore.NewCassandraStateStore(logger.NewLogger("cassandra-state")).(*scyllastore.ScyllaStateStore)
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: store type %q has no compaction report\n", *storeType)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := store.Init(ctx, contribstate.Metadata{Base: metadata.Base{Properties: properties}}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize %s store: %v\n", *storeType, err)
+		return 1
+	}
+	defer store.Close()
+
+	report, err := store.CompactionReport(ctx, scyllastore.CompactionOptions{SampleRows: *sampleRows, StaleAfter: *staleAfter})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printCompactionReport(report)
+	}
+	for _, recommendation := range report.Recommendations {
+		if recommendation.Severity == scyllastore.AdviceWarning {
+			return 2
+		}
+	}
+	return 0
+}
+
+func printCompactionReport(report *scyllastore.CompactionReport) {
+	coverage := "whole table"
+	if !report.Complete {
+		coverage = "first rows in token order"
+	}
+	fmt.Printf("Compaction report for %s.%s: %d rows sampled (%s)\n\n",
+		report.Keyspace, report.Table, report.SampledRows, coverage)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "size (bytes)\ttotal\tp50\tp90\tp99\tmax\t")
+	for _, row := range []struct {
+		name    string
+		summary scyllastore.SizeSummary
+	}{
+		{"key", report.KeySize},
+		{"value", report.ValueSize},
+	} {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\t%d\t\n", row.name, row.summary.Total,
+			row.summary.P50, row.summary.P90, row.summary.P99, row.summary.Max)
+	}
+	writer.Flush()
+
+	expiry := report.Expiry
+	fmt.Printf("\nRows with a TTL: %d, without: %d, of which not written for %s: %d\n",
+		expiry.WithTTL, expiry.WithoutTTL, expiry.StaleAfter, expiry.Stale)
+	if expiry.OldestWrite != nil {
+		fmt.Printf("Oldest write: %s\n", expiry.OldestWrite.Format(time.RFC3339))
+	}
+	options := report.TableOptions
+	fmt.Printf("Table options: compaction %s, gc_grace_seconds %d, default_time_to_live %d\n",
+		options.CompactionClass, options.GCGraceSeconds, options.DefaultTimeToLive)
+	if len(report.ExpiredBuckets) > 0 {
+		fmt.Printf("Expired time buckets not dropped: %s\n", strings.Join(report.ExpiredBuckets, ", "))
+	}
+	if groups := report.KeyGroups; groups != nil {
+		fmt.Printf("Key group index: %d of %d sampled entries without a row\n", groups.Orphans, groups.Sampled)
+	}
+	if queue := report.SearchIndex; queue != nil {
+		fmt.Printf("Search index queue: %d of %d\n", queue.Queued, queue.Capacity)
+	}
+
+	fmt.Println()
+	if len(report.Recommendations) == 0 {
+		fmt.Println("No recommendations.")
+		return
+	}
+	fmt.Println("Recommendations:")
+	for _, recommendation := range report.Recommendations {
+		fmt.Printf("  [%s] %s: %s\n", recommendation.Severity, recommendation.Setting, recommendation.Advice)
+	}
+}
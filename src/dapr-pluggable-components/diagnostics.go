@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	scyllastore "nebulagraph/stores/scylladb"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// diagnosticsProvider is implemented by stores that can report their internal state.
+type diagnosticsProvider interface {
+	Diagnostics() map[string]any
+}
+
+// statsProvider is implemented by stores that can report capacity statistics.
+type statsProvider interface {
+	Stats(ctx context.Context) (map[string]any, error)
+}
+
+// hotKeysProvider is implemented by stores that track their most accessed keys.
+type hotKeysProvider interface {
+	HotKeys(n int) []scyllastore.HotKey
+}
+
+// compactionAdvisor is implemented by stores that can recommend retention
+// and compaction settings.
+type compactionAdvisor interface {
+	CompactionReport(ctx context.Context, opts scyllastore.CompactionOptions) (*scyllastore.CompactionReport, error)
+}
+
+// diagnosticsRegistry tracks store instances created by the Dapr runtime so the
+// diagnostics endpoint can report on them.
+var diagnosticsRegistry struct {
+	mu     sync.Mutex
+	stores map[string][]diagnosticsProvider
+}
+
+// trackStore registers a store instance for diagnostics when it supports them.
+func trackStore(name string, store any) {
+	provider, ok := store.(diagnosticsProvider)
+	if !ok {
+		return
+	}
+
+	diagnosticsRegistry.mu.Lock()
+	defer diagnosticsRegistry.mu.Unlock()
+	if diagnosticsRegistry.stores == nil {
+		diagnosticsRegistry.stores = make(map[string][]diagnosticsProvider)
+	}
+	diagnosticsRegistry.stores[name] = append(diagnosticsRegistry.stores[name], provider)
+}
+
+// untrackStore drops a store instance that was closed for good.
+func untrackStore(store any) {
+	diagnosticsRegistry.mu.Lock()
+	defer diagnosticsRegistry.mu.Unlock()
+	for name, providers := range diagnosticsRegistry.stores {
+		diagnosticsRegistry.stores[name] = slices.DeleteFunc(providers, func(provider diagnosticsProvider) bool {
+			return provider == store
+		})
+	}
+}
+
+// startDiagnosticsServer serves pprof profiles and a diagnostics dump on the
+// loopback interface only, so they are reachable through kubectl port-forward
+// or docker exec but never from the network.
+func startDiagnosticsServer(port string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/diagnostics", serveDiagnostics)
+	mux.HandleFunc("/debug/stats", serveStats)
+	mux.HandleFunc("/debug/hotkeys", serveHotKeys)
+	mux.HandleFunc("/debug/compaction", serveCompaction)
+	mux.HandleFunc("/debug/capabilities", serveCapabilities)
+	mux.HandleFunc("/admin/stores", serveAdminStores)
+	mux.HandleFunc("/metrics", serveMetrics)
+	mux.HandleFunc("/readyz", serveReadiness)
+
+	addr := net.JoinHostPort("127.0.0.1", port)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		fmt.Printf("DEBUG: Diagnostics server listening on %s\n", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("WARNING: Diagnostics server stopped: %v\n", err)
+		}
+	}()
+}
+
+func serveDiagnostics(w http.ResponseWriter, _ *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	diagnosticsRegistry.mu.Lock()
+	stores := make(map[string][]map[string]any, len(diagnosticsRegistry.stores))
+	for name, providers := range diagnosticsRegistry.stores {
+		for _, provider := range providers {
+			stores[name] = append(stores[name], provider.Diagnostics())
+		}
+	}
+	diagnosticsRegistry.mu.Unlock()
+
+	dump := map[string]any{
+		"version": version,
+		"commit":  commit,
+		"runtime": map[string]any{
+			"goVersion":    runtime.Version(),
+			"goroutines":   runtime.NumGoroutine(),
+			"heapAlloc":    mem.HeapAlloc,
+			"heapInuse":    mem.HeapInuse,
+			"heapObjects":  mem.HeapObjects,
+			"sys":          mem.Sys,
+			"numGC":        mem.NumGC,
+			"pauseTotalNs": mem.PauseTotalNs,
+		},
+		"stores":       stores,
+		"capabilities": recordedCapabilities(),
+	}
+
+	writeJSON(w, dump)
+}
+
+// serveStats reports capacity statistics of every store that supports them.
+// Statistics scan the state table, so they are computed on request only.
+func serveStats(w http.ResponseWriter, r *http.Request) {
+	diagnosticsRegistry.mu.Lock()
+	providers := make(map[string][]statsProvider, len(diagnosticsRegistry.stores))
+	for name, stores := range diagnosticsRegistry.stores {
+		for _, store := range stores {
+			if provider, ok := store.(statsProvider); ok {
+				providers[name] = append(providers[name], provider)
+			}
+		}
+	}
+	diagnosticsRegistry.mu.Unlock()
+
+	stats := make(map[string][]map[string]any, len(providers))
+	for name, list := range providers {
+		for _, provider := range list {
+			result, err := provider.Stats(r.Context())
+			if err != nil {
+				result = map[string]any{"error": err.Error()}
+			}
+			stats[name] = append(stats[name], result)
+		}
+	}
+
+	writeJSON(w, map[string]any{"stores": stats})
+}
+
+// serveHotKeys reports the most accessed keys of every store tracking them,
+// limited to the n query parameter (default 20).
+func serveHotKeys(w http.ResponseWriter, r *http.Request) {
+	n := 20
+	if value := r.URL.Query().Get("n"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "n must be a positive number", http.StatusBadRequest)
+			return
+		}
+		n = parsed
+	}
+
+	diagnosticsRegistry.mu.Lock()
+	hotKeys := make(map[string][][]scyllastore.HotKey, len(diagnosticsRegistry.stores))
+	for name, stores := range diagnosticsRegistry.stores {
+		for _, store := range stores {
+			if provider, ok := store.(hotKeysProvider); ok {
+				if keys := provider.HotKeys(n); keys != nil {
+					hotKeys[name] = append(hotKeys[name], keys)
+				}
+			}
+		}
+	}
+	diagnosticsRegistry.mu.Unlock()
+
+	writeJSON(w, map[string]any{"stores": hotKeys})
+}
+
+// serveCompaction reports the compaction advice of every store giving it. The
+// sampleRows and staleAfter query parameters bound the sample and set the age
+// of stale rows.
+func serveCompaction(w http.ResponseWriter, r *http.Request) {
+	var opts scyllastore.CompactionOptions
+	if value := r.URL.Query().Get("sampleRows"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "sampleRows must be a positive number", http.StatusBadRequest)
+			return
+		}
+		opts.SampleRows = parsed
+	}
+	if value := r.URL.Query().Get("staleAfter"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "staleAfter must be a positive duration", http.StatusBadRequest)
+			return
+		}
+		opts.StaleAfter = parsed
+	}
+
+	diagnosticsRegistry.mu.Lock()
+	advisors := make(map[string][]compactionAdvisor, len(diagnosticsRegistry.stores))
+	for name, stores := range diagnosticsRegistry.stores {
+		for _, store := range stores {
+			if advisor, ok := store.(compactionAdvisor); ok {
+				advisors[name] = append(advisors[name], advisor)
+			}
+		}
+	}
+	diagnosticsRegistry.mu.Unlock()
+
+	reports := make(map[string][]any, len(advisors))
+	for name, list := range advisors {
+		for _, advisor := range list {
+			report, err := advisor.CompactionReport(r.Context(), opts)
+			if err != nil {
+				reports[name] = append(reports[name], map[string]any{"error": err.Error()})
+				continue
+			}
+			reports[name] = append(reports[name], report)
+		}
+	}
+
+	writeJSON(w, map[string]any{"stores": reports})
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(body); err != nil {
+		fmt.Printf("WARNING: Failed to write diagnostics: %v\n", err)
+	}
+}
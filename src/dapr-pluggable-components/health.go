@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// readinessProvider is implemented by stores that know whether they can serve
+// requests, e.g. whether any database host is up.
+type readinessProvider interface {
+	Ready() bool
+}
+
+// initProgressProvider is implemented by stores that report the progress of a
+// slow Init. The report carries a "state" of pending, initializing, ready or
+// failed, and details such as the phase, percent and elapsed time.
+type initProgressProvider interface {
+	InitProgress() map[string]any
+}
+
+// loadStateProvider is implemented by stores that shed load when their backend
+// is overloaded. A nil report means shedding is not enabled.
+type loadStateProvider interface {
+	LoadState() map[string]any
+}
+
+// Init state of instances that Dapr never initialized
+const initStatePending = "pending"
+
+// startHealthServer serves /healthz and /readyz on every interface, for
+// Kubernetes probes. Like the metrics server it exposes nothing else.
+func startHealthServer(port string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", serveLiveness)
+	mux.HandleFunc("/readyz", serveReadiness)
+
+	addr := net.JoinHostPort("", port)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		fmt.Printf("DEBUG: Health server listening on %s\n", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("WARNING: Health server stopped: %v\n", err)
+		}
+	}()
+}
+
+// serveLiveness answers as long as the process serves HTTP.
+func serveLiveness(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReadiness reports the readiness and Init progress of every store
+// instance. It answers 503 while any instance Dapr initialized is still
+// initializing, failed to initialize or cannot serve requests, so operators
+// can tell a slow bootstrap, which reports its phase and elapsed time, from a
+// stuck one. Instances Dapr never initialized do not count. The load state of
+// stores shedding requests is reported without affecting readiness.
+func serveReadiness(w http.ResponseWriter, _ *http.Request) {
+	diagnosticsRegistry.mu.Lock()
+	instances := make(map[string][]any, len(diagnosticsRegistry.stores))
+	for name, stores := range diagnosticsRegistry.stores {
+		for _, store := range stores {
+			instances[name] = append(instances[name], store)
+		}
+	}
+	diagnosticsRegistry.mu.Unlock()
+
+	allReady := true
+	report := make(map[string][]map[string]any, len(instances))
+	for name, stores := range instances {
+		for _, store := range stores {
+			entry := map[string]any{}
+			ready := true
+			if provider, ok := store.(initProgressProvider); ok {
+				progress := provider.InitProgress()
+				entry["init"] = progress
+				if progress["state"] == initStatePending {
+					entry["ready"] = false
+					report[name] = append(report[name], entry)
+					continue
+				}
+				ready = progress["state"] == "ready"
+			}
+			if provider, ok := store.(readinessProvider); ok {
+				ready = ready && provider.Ready()
+			}
+			if provider, ok := store.(loadStateProvider); ok {
+				if load := provider.LoadState(); load != nil {
+					entry["load"] = load
+				}
+			}
+			entry["ready"] = ready
+			allReady = allReady && ready
+			report[name] = append(report[name], entry)
+		}
+	}
+
+	if !allReady {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, map[string]any{"ready": allReady, "stores": report})
+}
@@ -0,0 +1,678 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"nebulagraph/stores/client"
+	"nebulagraph/stores/stateext"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dapr/components-contrib/metadata"
+	contribstate "github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+)
+
+const (
+	// Latency buckets per power of two, each about 19% wide
+	latencyBucketsPerOctave = 4
+	// Buckets from 1µs up to about 2^27µs (134s); slower requests share the last
+	latencyBuckets = 27*latencyBucketsPerOctave + 2
+
+	// Keys written per request while prefilling
+	prefillBatchSize = 100
+)
+
+// Operations of the load generator, in report order
+var loadgenOperations = []string{"get", "bulkGet", "set", "delete", "bulkSet", "transact"}
+
+// loadgenConfig is the traffic the load generator sends.
+type loadgenConfig struct {
+	rps          int
+	duration     time.Duration
+	concurrency  int
+	readRatio    float64
+	bulkRatio    float64
+	deleteRatio  float64
+	bulkSize     int
+	valueSize    int
+	actors       int
+	keysPerActor int
+	session      int
+	skew         float64
+	prefix       string
+	seed         uint64
+}
+
+// loadgenReport is the JSON report of the loadgen subcommand.
+type loadgenReport struct {
+	Store        string                      `json:"store"`
+	Duration     string                      `json:"duration"`
+	TargetRPS    int                         `json:"targetRps"` // 0 without a limit
+	AchievedRPS  float64                     `json:"achievedRps"`
+	Concurrency  int                         `json:"concurrency"`
+	Requests     int64                       `json:"requests"`
+	Errors       int64                       `json:"errors"`
+	Transactions bool                        `json:"transactions"` // bulk writes sent as transactions
+	Operations   map[string]loadgenOperation `json:"operations"`
+}
+
+// loadgenOperation holds the figures of one operation. Latencies are upper
+// bounds of histogram buckets, in milliseconds.
+type loadgenOperation struct {
+	Requests   int64           `json:"requests"`
+	Keys       int64           `json:"keys"`
+	Errors     int64           `json:"errors"`
+	FirstError string          `json:"firstError,omitempty"`
+	MeanMs     float64         `json:"meanMs"`
+	P50Ms      float64         `json:"p50Ms"`
+	P90Ms      float64         `json:"p90Ms"`
+	P99Ms      float64         `json:"p99Ms"`
+	P999Ms     float64         `json:"p999Ms"`
+	MaxMs      float64         `json:"maxMs"`
+	Histogram  []latencyBucket `json:"histogram"`
+}
+
+// latencyBucket is a non-empty histogram bucket.
+type latencyBucket struct {
+	UpperBoundMs float64 `json:"leMs"`
+	Count        int64   `json:"count"`
+}
+
+// latencyHistogram counts request latencies in log-linear buckets.
+type latencyHistogram struct {
+	buckets [latencyBuckets]int64
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	bucket := 0
+	if micros := d.Microseconds(); micros >= 1 {
+		bucket = min(int(math.Log2(float64(micros))*latencyBucketsPerOctave)+1, latencyBuckets-1)
+	}
+	h.buckets[bucket]++
+	h.count++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	for i, n := range other.buckets {
+		h.buckets[i] += n
+	}
+	h.count += other.count
+	h.sum += other.sum
+	h.max = max(h.max, other.max)
+}
+
+// upperBound returns the upper bound of a bucket.
+func (h *latencyHistogram) upperBound(bucket int) time.Duration {
+	if bucket == 0 {
+		return time.Microsecond
+	}
+	return time.Duration(math.Exp2(float64(bucket)/latencyBucketsPerOctave) * float64(time.Microsecond))
+}
+
+// quantile returns the upper bound of the bucket holding quantile q, or the
+// largest latency when that is lower.
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	target := max(int64(math.Ceil(q*float64(h.count))), 1)
+	var seen int64
+	for bucket, n := range h.buckets {
+		if seen += n; seen >= target {
+			return min(h.upperBound(bucket), h.max)
+		}
+	}
+	return h.max
+}
+
+// operationStats are the figures of one operation of one worker.
+type operationStats struct {
+	latency    latencyHistogram
+	keys       int64
+	errors     int64
+	firstError string
+}
+
+// loadgenWorker sends requests for one simulated actor at a time, as the
+// actor runtime does while an actor stays active on a host.
+type loadgenWorker struct {
+	store    contribstate.Store
+	multi    contribstate.TransactionalStore // nil when the store has no transactions
+	config   loadgenConfig
+	rng      *rand.Rand
+	actors   *rand.Zipf // nil for uniform actor choice
+	payload  string
+	stats    map[string]*operationStats
+	actor    int
+	actorOps int
+	sequence int64
+}
+
+// runLoadgen implements the loadgen subcommand: it sends Dapr-style traffic to
+// a store through the state store interface the sidecar uses and reports the
+// latency histogram of every operation, to compare tuning changes before they
+// reach production.
+func runLoadgen(args []string) int {
+	var (
+		sets   multiFlag
+		config loadgenConfig
+	)
+	flags := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	storeType := flags.String("store", "scylladb", "Store type: scylladb, cassandra, alternator, etcd or simulated")
+	flags.IntVar(&config.rps, "rps", 200, "Requests per second over all workers; 0 sends as fast as the workers can")
+	flags.DurationVar(&config.duration, "duration", 30*time.Second, "Length of the run")
+	flags.IntVar(&config.concurrency, "concurrency", 16, "Requests in flight at most")
+	flags.Float64Var(&config.readRatio, "read-ratio", 0.8, "Fraction of requests that read")
+	flags.Float64Var(&config.bulkRatio, "bulk-ratio", 0.1, "Fraction of requests that read or write several keys of an actor")
+	flags.Float64Var(&config.deleteRatio, "delete-ratio", 0.05, "Fraction of single-key writes that delete")
+	flags.IntVar(&config.bulkSize, "bulk-size", 10, "Keys per bulk request, at most -keys-per-actor")
+	flags.IntVar(&config.valueSize, "value-size", 256, "Bytes of each written JSON value")
+	flags.IntVar(&config.actors, "actors", 1000, "Actors whose state is read and written")
+	flags.IntVar(&config.keysPerActor, "keys-per-actor", 10, "State keys of each actor")
+	flags.IntVar(&config.session, "session", 20, "Requests a worker sends for one actor before moving to another")
+	flags.Float64Var(&config.skew, "skew", 1.1, "Zipf exponent of the actor choice, above 1; 0 chooses actors uniformly")
+	appID := flags.String("app-id", "loadgen", "App ID the keys are prefixed with, as the sidecar does")
+	seed := flags.String("seed", "", "Seed of the traffic, for the same sequence of requests on every run (default: random)")
+	prefill := flags.Bool("prefill", true, "Write every key once before the run, so reads find values")
+	cleanup := flags.Bool("cleanup", false, "Delete the keys of the app ID after the run, on stores that delete by prefix")
+	jsonOutput := flags.Bool("json", false, "Print the report as JSON")
+	flags.Var(&sets, "set", "Component metadata entry name=value, repeatable")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s loadgen -store <type> -set name=value ... [flags]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Sends Dapr-style traffic to a store and reports the latency of every operation.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if err := config.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if *appID == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: -app-id cannot be empty")
+		return 1
+	}
+	config.prefix = *appID + "||"
+	config.seed = uint64(time.Now().UnixNano())
+	if *seed != "" {
+		parsed, err := strconv.ParseUint(*seed, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -seed %q\n", *seed)
+			return 1
+		}
+		config.seed = parsed
+	}
+
+	properties := make(map[string]string, len(sets))
+	for _, entry := range sets {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -set %q; use name=value\n", entry)
+			return 1
+		}
+		properties[name] = value
+	}
+
+	store, err := client.NewStore(*storeType, logger.NewLogger(*storeType+"-state"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	// An interrupted run stops sending and reports what it measured
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := store.Init(ctx, contribstate.Metadata{Base: metadata.Base{Properties: properties}}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize %s store: %v\n", *storeType, err)
+		return 1
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if *prefill {
+		failed, err := prefillKeys(ctx, store, config)
+		switch {
+		case ctx.Err() != nil || failed == config.actors*config.keysPerActor:
+			fmt.Fprintf(os.Stderr, "ERROR: failed to prefill keys: %v\n", err)
+			return 1
+		case failed > 0:
+			fmt.Fprintf(os.Stderr, "WARNING: %d of %d keys were not prefilled: %v\n", failed, config.actors*config.keysPerActor, err)
+		}
+	}
+
+	report := generateLoad(ctx, store, config)
+	report.Store = *storeType
+
+	if *cleanup {
+		if deleter, ok := store.(stateext.DeleteWithPrefix); ok {
+			if _, err := deleter.DeleteWithPrefix(context.Background(), stateext.DeleteWithPrefixRequest{Prefix: config.prefix}); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: failed to delete the keys of %s: %v\n", config.prefix, err)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "WARNING: store type %q cannot delete by prefix; keys of %s were left in place\n", *storeType, config.prefix)
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.print()
+	}
+
+	if config.rps > 0 && report.AchievedRPS < 0.95*float64(config.rps) {
+		fmt.Fprintf(os.Stderr, "WARNING: sent %.1f of the %d requests per second; raise -concurrency or lower -rps\n", report.AchievedRPS, config.rps)
+	}
+	if report.Errors > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %d of %d requests failed\n", report.Errors, report.Requests)
+		return 2
+	}
+	return 0
+}
+
+func (c *loadgenConfig) validate() error {
+	switch {
+	case c.rps < 0:
+		return fmt.Errorf("-rps cannot be negative")
+	case c.duration <= 0:
+		return fmt.Errorf("-duration must be positive")
+	case c.concurrency <= 0:
+		return fmt.Errorf("-concurrency must be positive")
+	case c.actors <= 0 || c.keysPerActor <= 0:
+		return fmt.Errorf("-actors and -keys-per-actor must be positive")
+	case c.bulkSize <= 0 || c.session <= 0 || c.valueSize < 0:
+		return fmt.Errorf("-bulk-size and -session must be positive, and -value-size cannot be negative")
+	case c.skew != 0 && c.skew <= 1:
+		return fmt.Errorf("-skew must be above 1, or 0 for a uniform actor choice")
+	}
+	for name, ratio := range map[string]float64{"-read-ratio": c.readRatio, "-bulk-ratio": c.bulkRatio, "-delete-ratio": c.deleteRatio} {
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("%s must be a fraction between 0 and 1", name)
+		}
+	}
+	c.bulkSize = min(c.bulkSize, c.keysPerActor)
+	return nil
+}
+
+// key returns the state key of an actor, as the sidecar stores actor state:
+// <app-id>||<actor type>||<actor id>||<key>.
+func (c loadgenConfig) key(actor, index int) string {
+	return fmt.Sprintf("%sLoadgenActor||actor-%d||key-%d", c.prefix, actor, index)
+}
+
+// prefillKeys writes every key once, in bulk requests over several workers,
+// and returns the number of keys of failed requests with the first error.
+// Transient failures leave some keys unwritten without stopping the prefill.
+func prefillKeys(ctx context.Context, store contribstate.Store, config loadgenConfig) (int, error) {
+	payload := strings.Repeat("x", config.valueSize)
+	batches := make(chan []contribstate.SetRequest)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failed   int
+		firstErr error
+	)
+	for range config.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := store.BulkSet(ctx, batch, contribstate.BulkStoreOpts{}); err != nil {
+					mu.Lock()
+					failed += len(batch)
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	var batch []contribstate.SetRequest
+fill:
+	for actor := range config.actors {
+		for index := range config.keysPerActor {
+			batch = append(batch, contribstate.SetRequest{Key: config.key(actor, index), Value: loadgenValue(actor, 0, payload)})
+			if len(batch) == prefillBatchSize || (actor == config.actors-1 && index == config.keysPerActor-1) {
+				if ctx.Err() != nil {
+					break fill
+				}
+				batches <- batch
+				batch = nil
+			}
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return failed, firstErr
+}
+
+// generateLoad sends requests for config.duration, or until ctx is done, and
+// merges the figures of every worker into a report.
+func generateLoad(ctx context.Context, store contribstate.Store, config loadgenConfig) loadgenReport {
+	runCtx, cancel := context.WithTimeout(ctx, config.duration)
+	defer cancel()
+
+	// Paced runs hand out one token per request; without -rps workers send
+	// back to back
+	var tokens chan struct{}
+	if config.rps > 0 {
+		tokens = make(chan struct{})
+		go paceRequests(runCtx, tokens, config.rps)
+	}
+
+	multi, _ := store.(contribstate.TransactionalStore)
+	workers := make([]*loadgenWorker, config.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range workers {
+		rng := rand.New(rand.NewPCG(config.seed, uint64(i)))
+		worker := &loadgenWorker{
+			store:   store,
+			multi:   multi,
+			config:  config,
+			rng:     rng,
+			payload: strings.Repeat("x", config.valueSize),
+			stats:   make(map[string]*operationStats),
+		}
+		if config.skew > 0 {
+			worker.actors = rand.NewZipf(rng, config.skew, 1, uint64(config.actors-1))
+		}
+		workers[i] = worker
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-runCtx.Done():
+						return
+					}
+				} else if runCtx.Err() != nil {
+					return
+				}
+				// Requests still in flight at the end of the run complete
+				worker.send(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report := loadgenReport{
+		Duration:     elapsed.Round(time.Millisecond).String(),
+		TargetRPS:    config.rps,
+		Concurrency:  config.concurrency,
+		Transactions: multi != nil,
+		Operations:   make(map[string]loadgenOperation),
+	}
+	for _, operation := range loadgenOperations {
+		merged := &operationStats{}
+		for _, worker := range workers {
+			stats, ok := worker.stats[operation]
+			if !ok {
+				continue
+			}
+			merged.latency.merge(&stats.latency)
+			merged.keys += stats.keys
+			merged.errors += stats.errors
+			if merged.firstError == "" {
+				merged.firstError = stats.firstError
+			}
+		}
+		if merged.latency.count == 0 {
+			continue
+		}
+		report.Requests += merged.latency.count
+		report.Errors += merged.errors
+		report.Operations[operation] = merged.summary()
+	}
+	report.AchievedRPS = math.Round(float64(report.Requests)/elapsed.Seconds()*10) / 10
+	return report
+}
+
+// paceRequests hands out rps tokens per second until ctx is done. A pacer
+// that fell behind sends the missed tokens at once, up to a second's worth.
+func paceRequests(ctx context.Context, tokens chan<- struct{}, rps int) {
+	interval := time.Second / time.Duration(rps)
+	next := time.Now()
+	for {
+		next = next.Add(interval)
+		if now := time.Now(); now.Sub(next) > time.Second {
+			next = now
+		} else if wait := next.Sub(now); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+		select {
+		case tokens <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send sends one request for the worker's current actor.
+func (w *loadgenWorker) send(ctx context.Context) {
+	if w.actorOps == 0 {
+		w.actor = w.rng.IntN(w.config.actors)
+		if w.actors != nil {
+			w.actor = int(w.actors.Uint64())
+		}
+		w.actorOps = w.config.session
+	}
+	w.actorOps--
+
+	read := w.rng.Float64() < w.config.readRatio
+	bulk := w.rng.Float64() < w.config.bulkRatio
+	var (
+		operation string
+		keys      = 1
+		request   func() error
+	)
+	switch {
+	case read && bulk:
+		operation, keys = "bulkGet", w.config.bulkSize
+		requests := make([]contribstate.GetRequest, keys)
+		for i, index := range w.keyIndexes(keys) {
+			requests[i] = contribstate.GetRequest{Key: w.config.key(w.actor, index)}
+		}
+		request = func() error {
+			responses, err := w.store.BulkGet(ctx, requests, contribstate.BulkGetOpts{})
+			if err != nil {
+				return err
+			}
+			for _, response := range responses {
+				if response.Error != "" {
+					return fmt.Errorf("key %s: %s", response.Key, response.Error)
+				}
+			}
+			return nil
+		}
+	case read:
+		operation = "get"
+		key := w.config.key(w.actor, w.rng.IntN(w.config.keysPerActor))
+		request = func() error {
+			_, err := w.store.Get(ctx, &contribstate.GetRequest{Key: key})
+			return err
+		}
+	case bulk:
+		// Actors save their state changes as one transaction
+		keys = w.config.bulkSize
+		requests := make([]contribstate.SetRequest, keys)
+		for i, index := range w.keyIndexes(keys) {
+			requests[i] = contribstate.SetRequest{Key: w.config.key(w.actor, index), Value: w.value()}
+		}
+		if w.multi != nil {
+			operation = "transact"
+			operations := make([]contribstate.TransactionalStateOperation, len(requests))
+			for i, req := range requests {
+				operations[i] = req
+			}
+			request = func() error {
+				return w.multi.Multi(ctx, &contribstate.TransactionalStateRequest{Operations: operations})
+			}
+		} else {
+			operation = "bulkSet"
+			request = func() error {
+				return w.store.BulkSet(ctx, requests, contribstate.BulkStoreOpts{})
+			}
+		}
+	case w.rng.Float64() < w.config.deleteRatio:
+		operation = "delete"
+		key := w.config.key(w.actor, w.rng.IntN(w.config.keysPerActor))
+		request = func() error {
+			return w.store.Delete(ctx, &contribstate.DeleteRequest{Key: key})
+		}
+	default:
+		operation = "set"
+		req := &contribstate.SetRequest{Key: w.config.key(w.actor, w.rng.IntN(w.config.keysPerActor)), Value: w.value()}
+		request = func() error {
+			return w.store.Set(ctx, req)
+		}
+	}
+
+	stats, ok := w.stats[operation]
+	if !ok {
+		stats = &operationStats{}
+		w.stats[operation] = stats
+	}
+	start := time.Now()
+	err := request()
+	stats.latency.record(time.Since(start))
+	stats.keys += int64(keys)
+	if err != nil {
+		stats.errors++
+		if stats.firstError == "" {
+			stats.firstError = err.Error()
+		}
+	}
+}
+
+// keyIndexes returns n distinct key indexes of an actor.
+func (w *loadgenWorker) keyIndexes(n int) []int {
+	return w.rng.Perm(w.config.keysPerActor)[:n]
+}
+
+// value returns the next value written by the worker.
+func (w *loadgenWorker) value() []byte {
+	w.sequence++
+	return loadgenValue(w.actor, w.sequence, w.payload)
+}
+
+// loadgenValue returns a JSON value of an actor, as an SDK serializes state.
+func loadgenValue(actor int, sequence int64, payload string) []byte {
+	value, _ := json.Marshal(map[string]any{
+		"actor":     actor,
+		"sequence":  sequence,
+		"updatedAt": time.Now().UTC().Format(time.RFC3339Nano),
+		"payload":   payload,
+	})
+	return value
+}
+
+// summary returns the report figures of an operation.
+func (s *operationStats) summary() loadgenOperation {
+	h := &s.latency
+	operation := loadgenOperation{
+		Requests:   h.count,
+		Keys:       s.keys,
+		Errors:     s.errors,
+		FirstError: s.firstError,
+		MeanMs:     milliseconds(h.sum / time.Duration(h.count)),
+		P50Ms:      milliseconds(h.quantile(0.50)),
+		P90Ms:      milliseconds(h.quantile(0.90)),
+		P99Ms:      milliseconds(h.quantile(0.99)),
+		P999Ms:     milliseconds(h.quantile(0.999)),
+		MaxMs:      milliseconds(h.max),
+		Histogram:  []latencyBucket{},
+	}
+	for bucket, n := range h.buckets {
+		if n > 0 {
+			operation.Histogram = append(operation.Histogram, latencyBucket{UpperBoundMs: milliseconds(h.upperBound(bucket)), Count: n})
+		}
+	}
+	return operation
+}
+
+// milliseconds converts d to milliseconds, rounded to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+func (r loadgenReport) print() {
+	fmt.Printf("Load test of the %s store: %d requests in %s, %.1f/s (target %s), %d workers, %d errors\n\n",
+		r.Store, r.Requests, r.Duration, r.AchievedRPS, targetRate(r.TargetRPS), r.Concurrency, r.Errors)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "operation\trequests\tkeys\terrors\tmean ms\tp50\tp90\tp99\tp99.9\tmax\t")
+	for _, name := range loadgenOperations {
+		operation, ok := r.Operations[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n", name,
+			operation.Requests, operation.Keys, operation.Errors, operation.MeanMs,
+			operation.P50Ms, operation.P90Ms, operation.P99Ms, operation.P999Ms, operation.MaxMs)
+	}
+	writer.Flush()
+
+	for _, name := range loadgenOperations {
+		operation, ok := r.Operations[name]
+		if !ok {
+			continue
+		}
+		fmt.Printf("\n%s latency histogram:\n", name)
+		largest := slices.MaxFunc(operation.Histogram, func(a, b latencyBucket) int { return cmp.Compare(a.Count, b.Count) }).Count
+		writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, bucket := range operation.Histogram {
+			bar := strings.Repeat("#", max(int(40*bucket.Count/largest), 1))
+			fmt.Fprintf(writer, "  <= %.3f ms\t%d\t%s\n", bucket.UpperBoundMs, bucket.Count, bar)
+		}
+		writer.Flush()
+		if operation.FirstError != "" {
+			fmt.Printf("  first error: %s\n", operation.FirstError)
+		}
+	}
+	fmt.Println("\nLatencies are upper bounds of histogram buckets about 19% wide.")
+}
+
+// targetRate describes the -rps of a run.
+func targetRate(rps int) string {
+	if rps == 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(rps) + "/s"
+}
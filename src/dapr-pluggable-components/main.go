@@ -3,8 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	alternatorstore "nebulagraph/stores/alternator"
+	etcdstore "nebulagraph/stores/etcd"
 	nebulastore "nebulagraph/stores/nebulagraph"
 	scyllastore "nebulagraph/stores/scylladb"
+	simulatedstore "nebulagraph/stores/simulated"
 	"os"
 	"strings"
 
@@ -21,6 +24,28 @@ var (
 )
 
 func main() {
+	// Subcommands run instead of the component
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "report":
+			os.Exit(runReport(os.Args[2:]))
+		case "generate-manifest":
+			os.Exit(runGenerateManifest(os.Args[2:]))
+		case "migrate-prefix":
+			os.Exit(runMigratePrefix(os.Args[2:]))
+		case "reconcile-mirror":
+			os.Exit(runReconcileMirror(os.Args[2:]))
+		case "load-fixtures":
+			os.Exit(runLoadFixtures(os.Args[2:]))
+		case "schema":
+			os.Exit(runSchema(os.Args[2:]))
+		case "compaction-report":
+			os.Exit(runCompactionReport(os.Args[2:]))
+		case "loadgen":
+			os.Exit(runLoadgen(os.Args[2:]))
+		}
+	}
+
 	// Handle version flag
 	versionFlag := flag.Bool("version", false, "Print version information")
 	flag.Parse()
@@ -35,29 +60,22 @@ func main() {
 
 	fmt.Printf("DEBUG: Starting Dapr component registration (version: %s)\n", version)
 
-	// Get list of stores to register from environment variable
-	// Examples:
-	// STORE_TYPES="nebulagraph" - single store
-	// STORE_TYPES="nebulagraph,scylladb" - multiple stores
-	// STORE_TYPES="scylladb,nebulagraph,redis" - future expansion ready
-	storeTypes := os.Getenv("STORE_TYPES")
-	if storeTypes == "" {
-		// Backward compatibility: check old STORE_TYPE variable
-		legacyStoreType := os.Getenv("STORE_TYPE")
-		switch legacyStoreType {
-		case "both":
-			storeTypes = "nebulagraph,scylladb"
-		case "scylladb":
-			storeTypes = "scylladb"
-		case "nebulagraph":
-			fallthrough
-		default:
-			storeTypes = "nebulagraph" // default
-		}
+	// Optional pprof and diagnostics endpoint, bound to loopback only
+	if diagnosticsPort := os.Getenv("DIAGNOSTICS_PORT"); diagnosticsPort != "" {
+		startDiagnosticsServer(diagnosticsPort)
 	}
 
-	// Parse the comma-separated list of store types
-	stores := strings.Split(storeTypes, ",")
+	// Optional Prometheus metrics endpoint, reachable from the network
+	if metricsPort := os.Getenv("METRICS_PORT"); metricsPort != "" {
+		startMetricsServer(metricsPort)
+	}
+
+	// Optional liveness and readiness endpoints for Kubernetes probes
+	if healthPort := os.Getenv("HEALTH_PORT"); healthPort != "" {
+		startHealthServer(healthPort)
+	}
+
+	stores := requestedStoreTypes()
 	registeredStores := make(map[string]bool)
 
 	fmt.Printf("DEBUG: Requested stores: %v\n", stores)
@@ -75,22 +93,80 @@ func main() {
 		switch storeType {
 		case "nebulagraph":
 			fmt.Println("DEBUG: Registering NebulaGraph state store")
-			dapr.Register("nebulagraph-state", dapr.WithStateStore(func() state.Store {
-				fmt.Println("DEBUG: Factory function called - creating new NebulaStateStore instance")
+			err := registerStateStore(storeType, "nebulagraph-state", func() state.Store {
 				store := nebulastore.NewNebulaStateStore(logger.NewLogger("nebulagraph-state"))
-				fmt.Printf("DEBUG: Created NebulaGraph store instance: %p\n", store)
+				trackStore("nebulagraph-state", store)
 				return store
-			}))
+			})
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
 			registeredStores[storeType] = true
 
 		case "scylladb":
 			fmt.Println("DEBUG: Registering ScyllaDB state store")
-			dapr.Register("scylladb-state", dapr.WithStateStore(func() state.Store {
-				fmt.Println("DEBUG: Factory function called - creating new ScyllaStateStore instance")
+			err := registerStateStore(storeType, "scylladb-state", func() state.Store {
 				store := scyllastore.NewScyllaStateStore(logger.NewLogger("scylladb-state"))
-				fmt.Printf("DEBUG: Created ScyllaDB store instance: %p\n", store)
+				trackStore("scylladb-state", store)
+				return store
+			})
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			registeredStores[storeType] = true
+
+		case "alternator":
+			fmt.Println("DEBUG: Registering ScyllaDB Alternator state store")
+			err := registerStateStore(storeType, "alternator-state", func() state.Store {
+				store := alternatorstore.NewAlternatorStateStore(logger.NewLogger("alternator-state"))
+				trackStore("alternator-state", store)
 				return store
-			}))
+			})
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			registeredStores[storeType] = true
+
+		case "cassandra":
+			fmt.Println("DEBUG: Registering Cassandra state store")
+			err := registerStateStore(storeType, "cassandra-state", func() state.Store {
+				store := scyllastore.NewCassandraStateStore(logger.NewLogger("cassandra-state"))
+				trackStore("cassandra-state", store)
+				return store
+			})
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			registeredStores[storeType] = true
+
+		case "etcd":
+			fmt.Println("DEBUG: Registering etcd state store")
+			err := registerStateStore(storeType, "etcd-state", func() state.Store {
+				store := etcdstore.NewEtcdStateStore(logger.NewLogger("etcd-state"))
+				trackStore("etcd-state", store)
+				return store
+			})
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			registeredStores[storeType] = true
+
+		case "simulated":
+			fmt.Println("DEBUG: Registering simulated state store")
+			err := registerStateStore(storeType, "simulated-state", func() state.Store {
+				store := simulatedstore.NewSimulatedStateStore(logger.NewLogger("simulated-state"))
+				trackStore("simulated-state", store)
+				return store
+			})
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
 			registeredStores[storeType] = true
 
 		// Future stores can be added here easily
@@ -125,10 +201,56 @@ func main() {
 	}
 
 	fmt.Printf("DEBUG: Successfully registered %d store(s): %v\n", len(registeredStores), getKeys(registeredStores))
+
+	// Optional restriction of the sockets to the sidecar's user or group
+	sockets := getKeys(registeredStores)
+	for i, storeType := range sockets {
+		sockets[i] = storeComponentNames[storeType]
+	}
+	if len(sockets) == 0 {
+		sockets = []string{"nebulagraph-state"}
+	}
+	if restricted, err := restrictSockets(sockets); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	} else if restricted {
+		fmt.Println("DEBUG: Component sockets restricted to the allowed peers")
+	}
 	fmt.Println("DEBUG: Registration complete, starting Dapr runtime")
 	dapr.MustRun()
 }
 
+// requestedStoreTypes returns the store types to register, from STORE_TYPES
+// or the legacy STORE_TYPE variable.
+func requestedStoreTypes() []string {
+	// Get list of stores to register from environment variable
+	// Examples:
+	// STORE_TYPES="nebulagraph" - single store
+	// STORE_TYPES="nebulagraph,scylladb" - multiple stores
+	// STORE_TYPES="scylladb,alternator" - CQL and DynamoDB API stores side by side
+	// STORE_TYPES="cassandra" - the CQL store against vanilla Apache Cassandra
+	// STORE_TYPES="scylladb,etcd" - bulk state plus small, strongly consistent configuration state
+	// STORE_TYPES="scylladb,nebulagraph,redis" - future expansion ready
+	storeTypes := os.Getenv("STORE_TYPES")
+	if storeTypes == "" {
+		// Backward compatibility: check old STORE_TYPE variable
+		legacyStoreType := os.Getenv("STORE_TYPE")
+		switch legacyStoreType {
+		case "both":
+			storeTypes = "nebulagraph,scylladb"
+		case "scylladb":
+			storeTypes = "scylladb"
+		case "nebulagraph":
+			fallthrough
+		default:
+			storeTypes = "nebulagraph" // default
+		}
+	}
+
+	// Parse the comma-separated list of store types
+	return strings.Split(storeTypes, ",")
+}
+
 // Helper function to get keys from map for logging
 func getKeys(m map[string]bool) []string {
 	keys := make([]string, 0, len(m))
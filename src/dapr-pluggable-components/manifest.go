@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Component names registered for each store type; they must match main.
+var storeComponentNames = map[string]string{
+	"nebulagraph": "nebulagraph-state",
+	"scylladb":    "scylladb-state",
+	"alternator":  "alternator-state",
+	"cassandra":   "cassandra-state",
+	"etcd":        "etcd-state",
+	"simulated":   "simulated-state",
+}
+
+// Pod annotations read by the Dapr sidecar injector for pluggable components
+const (
+	pluggableComponentsAnnotation    = "dapr.io/pluggable-components"
+	pluggableSocketsFolderAnnotation = "dapr.io/pluggable-components-sockets-folder"
+)
+
+// multiFlag collects a repeatable string flag.
+type multiFlag []string
+
+func (f *multiFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *multiFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// manifestMetadata is one metadata entry of a generated component.
+type manifestMetadata struct {
+	name   string
+	value  string
+	secret bool
+}
+
+// runGenerateManifest implements the generate-manifest subcommand: it prints
+// a Dapr Component resource for each store the binary would register with the
+// current STORE_TYPES, so the names and types always match the sockets.
+func runGenerateManifest(args []string) int {
+	var sets, secrets multiFlag
+	flags := flag.NewFlagSet("generate-manifest", flag.ExitOnError)
+	stores := flags.String("stores", "", "Comma-separated store types (default: STORE_TYPES or STORE_TYPE)")
+	namespace := flags.String("namespace", "default", "Namespace of the components; empty to omit")
+	secretStore := flags.String("secret-store", "", "Secret store that -secret entries are read from")
+	kubernetes := flags.Bool("kubernetes", false, "Also print the pod annotations for the sidecar injector")
+	container := flags.String("container", "dapr-pluggable-components", "Name of the component container in the pod, with -kubernetes")
+	flags.Var(&sets, "set", "Metadata entry [store/]name=value, repeatable")
+	flags.Var(&secrets, "secret", "Metadata entry [store/]name read from -secret-store as <store>:<name>, repeatable")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s generate-manifest [flags]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Prints Dapr Component YAML for the registered stores.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	storeTypes := requestedStoreTypes()
+	if *stores != "" {
+		storeTypes = strings.Split(*stores, ",")
+	}
+	if len(secrets) > 0 && *secretStore == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: -secret requires -secret-store")
+		return 1
+	}
+
+	var (
+		seen  = make(map[string]bool)
+		names []string
+		types []string
+	)
+	for _, storeType := range storeTypes {
+		storeType = strings.TrimSpace(storeType)
+		if storeType == "" || seen[storeType] {
+			continue
+		}
+		name, ok := storeComponentNames[storeType]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "ERROR: unknown store type %q\n", storeType)
+			return 1
+		}
+		if err := validateComponentName(name); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s store: %v\n", storeType, err)
+			return 1
+		}
+		seen[storeType] = true
+		names = append(names, name)
+		types = append(types, storeType)
+	}
+	if len(types) == 0 {
+		fmt.Fprintln(os.Stderr, "ERROR: no store types given")
+		return 1
+	}
+
+	metadata := make(map[string][]manifestMetadata, len(types))
+	for _, entry := range sets {
+		storeType, pair := splitStorePrefix(entry)
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -set %q; use [store/]name=value\n", entry)
+			return 1
+		}
+		if err := addManifestMetadata(metadata, types, storeType, manifestMetadata{name: name, value: value}); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -set %q: %v\n", entry, err)
+			return 1
+		}
+	}
+	for _, entry := range secrets {
+		storeType, name := splitStorePrefix(entry)
+		if name == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -secret %q; use [store/]name\n", entry)
+			return 1
+		}
+		if err := addManifestMetadata(metadata, types, storeType, manifestMetadata{name: name, secret: true}); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -secret %q: %v\n", entry, err)
+			return 1
+		}
+	}
+
+	for i, storeType := range types {
+		if i > 0 {
+			fmt.Println("---")
+		}
+		writeComponentManifest(os.Stdout, names[i], *namespace, *secretStore, metadata[storeType])
+	}
+	if *kubernetes {
+		writePodAnnotations(os.Stdout, *container, types)
+	}
+	return 0
+}
+
+// splitStorePrefix splits an optional "store/" prefix off a flag value.
+func splitStorePrefix(entry string) (string, string) {
+	if storeType, rest, ok := strings.Cut(entry, "/"); ok {
+		if _, known := storeComponentNames[storeType]; known {
+			return storeType, rest
+		}
+	}
+	return "", entry
+}
+
+// addManifestMetadata adds entry to the given store, or to every store when
+// storeType is empty.
+func addManifestMetadata(metadata map[string][]manifestMetadata, types []string, storeType string, entry manifestMetadata) error {
+	if storeType == "" {
+		for _, t := range types {
+			metadata[t] = append(metadata[t], entry)
+		}
+		return nil
+	}
+	for _, t := range types {
+		if t == storeType {
+			metadata[t] = append(metadata[t], entry)
+			return nil
+		}
+	}
+	return fmt.Errorf("store %s is not registered", storeType)
+}
+
+// writeComponentManifest prints one Component resource. Metadata entries are
+// sorted by name, and later entries of the same name win.
+func writeComponentManifest(w io.Writer, name, namespace, secretStore string, metadata []manifestMetadata) {
+	byName := make(map[string]manifestMetadata, len(metadata))
+	for _, entry := range metadata {
+		byName[entry.name] = entry
+	}
+	entries := make([]string, 0, len(byName))
+	for entryName := range byName {
+		entries = append(entries, entryName)
+	}
+	sort.Strings(entries)
+
+	fmt.Fprintln(w, "apiVersion: dapr.io/v1alpha1")
+	fmt.Fprintln(w, "kind: Component")
+	fmt.Fprintln(w, "metadata:")
+	fmt.Fprintf(w, "  name: %s\n", name)
+	if namespace != "" {
+		fmt.Fprintf(w, "  namespace: %s\n", strconv.Quote(namespace))
+	}
+	fmt.Fprintln(w, "spec:")
+	fmt.Fprintf(w, "  type: state.%s\n", name)
+	fmt.Fprintln(w, "  version: v1")
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "  metadata: []")
+	} else {
+		fmt.Fprintln(w, "  metadata:")
+	}
+	for _, entryName := range entries {
+		entry := byName[entryName]
+		fmt.Fprintf(w, "  - name: %s\n", strconv.Quote(entry.name))
+		if !entry.secret {
+			fmt.Fprintf(w, "    value: %s\n", strconv.Quote(entry.value))
+			continue
+		}
+		// Same key layout as the local secret store files: <store>:<name>
+		key := strconv.Quote(strings.TrimSuffix(name, "-state") + ":" + entry.name)
+		fmt.Fprintln(w, "    secretKeyRef:")
+		fmt.Fprintf(w, "      name: %s\n", key)
+		fmt.Fprintf(w, "      key: %s\n", key)
+	}
+	if secretStore != "" {
+		fmt.Fprintln(w, "auth:")
+		fmt.Fprintf(w, "  secretStore: %s\n", strconv.Quote(secretStore))
+	}
+}
+
+// writePodAnnotations prints, as YAML comments so the output stays
+// applicable, the annotations the application's pod template needs for the
+// sidecar to find the component sockets.
+func writePodAnnotations(w io.Writer, container string, types []string) {
+	fmt.Fprintln(w, "# Add to the application's pod template:")
+	fmt.Fprintln(w, "#   metadata:")
+	fmt.Fprintln(w, "#     annotations:")
+	fmt.Fprintln(w, "#       dapr.io/enabled: \"true\"")
+	fmt.Fprintf(w, "#       %s: %s\n", pluggableComponentsAnnotation, strconv.Quote(container))
+	fmt.Fprintf(w, "#       %s: %s\n", pluggableSocketsFolderAnnotation, strconv.Quote(socketFolder()))
+	fmt.Fprintf(w, "# and run the %s container with %s=%s and STORE_TYPES=%s.\n",
+		strconv.Quote(container), socketFolderEnvVar, socketFolder(), strings.Join(types, ","))
+}
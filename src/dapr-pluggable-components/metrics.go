@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gaugeProvider is implemented by stores that sample backend statistics. It
+// returns the labels identifying the sampled table or space and the values by
+// metric name, or nil values when there is nothing to export.
+type gaugeProvider interface {
+	StatsGauges() (map[string]string, map[string]float64)
+}
+
+// Prefix of every exported metric name
+const metricsNamespace = "dapr_state_"
+
+// metricFamilies describes the metrics stores export; unknown names are
+// exported as untyped gauges.
+var metricFamilies = map[string]struct{ kind, help string }{
+	"estimated_rows":                     {"gauge", "Estimated rows in the state table or space."},
+	"estimated_partitions":               {"gauge", "Estimated partitions in the state table or space."},
+	"estimated_bytes":                    {"gauge", "Estimated size of the state table or space in bytes."},
+	"stats_sampled_timestamp_seconds":    {"gauge", "Unix time of the last successful statistics sample."},
+	"stats_sample_failures_total":        {"counter", "Statistics samples that failed."},
+	"mirror_enqueued_total":              {"counter", "Writes queued for the mirror cluster."},
+	"mirror_written_total":               {"counter", "Writes applied on the mirror cluster."},
+	"mirror_dropped_total":               {"counter", "Writes dropped because the mirror queue was full or closed."},
+	"mirror_failed_total":                {"counter", "Writes the mirror cluster rejected or timed out."},
+	"mirror_queue_length":                {"gauge", "Writes waiting for the mirror cluster."},
+	"mirror_reconcile_checked_total":     {"counter", "Keys compared with the mirror cluster by reconciliation."},
+	"mirror_reconcile_missing_total":     {"counter", "Keys found on the primary but not on the mirror cluster."},
+	"mirror_reconcile_diverged_total":    {"counter", "Keys found with another ETag on the mirror cluster."},
+	"mirror_reconcile_orphaned_total":    {"counter", "Keys found on the mirror cluster but not on the primary."},
+	"mirror_reconcile_repaired_total":    {"counter", "Keys rewritten on the mirror cluster by reconciliation."},
+	"mirror_divergence_ratio":            {"gauge", "Share of keys that differed in the last mirror reconciliation."},
+	"search_index_verify_checked_total":  {"counter", "Keys compared with their search index documents."},
+	"search_index_verify_missing_total":  {"counter", "Stored keys found without a search index document."},
+	"search_index_verify_stale_total":    {"counter", "Search index documents found not matching the stored value."},
+	"search_index_verify_orphaned_total": {"counter", "Search index documents found for keys no longer stored."},
+	"search_index_verify_repaired_total": {"counter", "Search index documents queued for reindexing or deletion."},
+	"search_index_drift_ratio":           {"gauge", "Share of keys that differed from the search index in the last verification."},
+	"shadow_reads_total":                 {"counter", "Gets read again from the mirror cluster and compared."},
+	"shadow_read_mismatches_total":       {"counter", "Shadow reads that still differed from the primary when read again."},
+	"shadow_read_lagged_total":           {"counter", "Shadow reads that differed until the mirror caught up."},
+	"shadow_read_errors_total":           {"counter", "Shadow reads the mirror cluster failed."},
+	"shadow_reads_skipped_total":         {"counter", "Sampled Gets not shadowed because too many shadow reads were running."},
+	"shadow_read_primary_p99_seconds":    {"gauge", "p99 latency of shadowed Gets on the primary over the last minute."},
+	"shadow_read_mirror_p99_seconds":     {"gauge", "p99 latency of shadow reads on the mirror cluster over the last minute."},
+	"shadow_read_p99_delta_seconds":      {"gauge", "Mirror p99 minus primary p99 of shadowed Gets; positive when the mirror is slower."},
+	"key_lock_acquired_total":            {"counter", "Per-key locks taken by Set and Delete."},
+	"key_lock_contended_total":           {"counter", "Per-key locks that waited for another write."},
+	"key_lock_wait_seconds_total":        {"counter", "Time Set and Delete waited for per-key locks."},
+	"key_lock_abandoned_total":           {"counter", "Per-key lock waits ended by the request context."},
+	"change_feed_recorded_total":         {"counter", "Writes recorded in the change feed."},
+	"change_feed_dropped_total":          {"counter", "Writes not recorded because the change feed queue was full."},
+	"change_feed_failed_total":           {"counter", "Writes the change feed table rejected or timed out."},
+	"change_feed_queue_length":           {"gauge", "Writes waiting to be recorded in the change feed."},
+	"watches_waiting":                    {"gauge", "Watch queries waiting for a change."},
+	"overload_level":                     {"gauge", "Overload shedding level: 0 none, 1 reduced, 2 minimal, 3 shedding bulk requests."},
+	"overload_signals_total":             {"counter", "Overloaded, rate limit and timeout errors seen."},
+	"overload_raised_total":              {"counter", "Times overload signals raised the load level."},
+	"overload_shed_total":                {"counter", "Bulk requests shed while the backend was overloaded."},
+	"stale_cache_entries":                {"gauge", "Keys whose last known value is kept for stale-if-error reads."},
+	"stale_reads_total":                  {"counter", "Reads answered from the stale cache after a backend error."},
+	"stale_cache_misses_total":           {"counter", "Failed reads the stale cache had no fresh enough value for."},
+	"conflict_merges_total":              {"counter", "Sets with a stale ETag stored as the merge with the current value."},
+	"conflict_merge_failures_total":      {"counter", "Sets with a stale ETag whose values the merge strategy rejected."},
+	"hot_key_top_share":                  {"gauge", "Share of recent accesses that went to the most accessed key."},
+	"hot_key_window_accesses":            {"gauge", "Recent accesses counted by hot key tracking, halved every window."},
+	"write_coalesced_total":              {"counter", "Sets of hot keys buffered instead of written."},
+	"write_coalescing_flushes_total":     {"counter", "Buffered values written to the table."},
+	"write_coalescing_failures_total":    {"counter", "Buffered values lost because their write failed."},
+	"write_coalescing_pending":           {"gauge", "Keys with a buffered value not yet written."},
+	"write_audit_records_total":          {"counter", "Sets and Deletes logged by the write audit."},
+	"write_audit_diffs_total":            {"counter", "Sets logged with the diff of their value."},
+	"write_audit_truncated_total":        {"counter", "Audit diffs cut to writeAuditDiffMaxBytes."},
+}
+
+// startMetricsServer serves /metrics on every interface, for Prometheus to
+// scrape. Unlike the diagnostics server it exposes nothing but the metrics.
+func startMetricsServer(port string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", serveMetrics)
+
+	addr := net.JoinHostPort("", port)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		fmt.Printf("DEBUG: Metrics server listening on %s\n", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("WARNING: Metrics server stopped: %v\n", err)
+		}
+	}()
+}
+
+// serveMetrics writes the gauges of every store instance in the Prometheus
+// text format, labelled with the component name.
+func serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	type sample struct {
+		labels string
+		value  float64
+	}
+	families := make(map[string][]sample)
+
+	diagnosticsRegistry.mu.Lock()
+	for name, stores := range diagnosticsRegistry.stores {
+		for _, store := range stores {
+			provider, ok := store.(gaugeProvider)
+			if !ok {
+				continue
+			}
+			labels, values := provider.StatsGauges()
+			if values == nil {
+				continue
+			}
+			rendered := metricLabels(name, labels)
+			for metric, value := range values {
+				families[metric] = append(families[metric], sample{labels: rendered, value: value})
+			}
+		}
+	}
+	diagnosticsRegistry.mu.Unlock()
+
+	metrics := make([]string, 0, len(families))
+	for metric := range families {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, metric := range metrics {
+		family, known := metricFamilies[metric]
+		if !known {
+			family.kind, family.help = "untyped", "State store statistic "+metric+"."
+		}
+		fmt.Fprintf(w, "# HELP %s%s %s\n", metricsNamespace, metric, family.help)
+		fmt.Fprintf(w, "# TYPE %s%s %s\n", metricsNamespace, metric, family.kind)
+
+		samples := families[metric]
+		sort.Slice(samples, func(i, j int) bool { return samples[i].labels < samples[j].labels })
+		for _, s := range samples {
+			fmt.Fprintf(w, "%s%s{%s} %g\n", metricsNamespace, metric, s.labels, s.value)
+		}
+	}
+}
+
+// metricLabels renders the component label and the store's labels, sorted.
+func metricLabels(component string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "component=%q", escapeLabelValue(component))
+	for _, name := range names {
+		fmt.Fprintf(&b, ",%s=%q", name, escapeLabelValue(labels[name]))
+	}
+	return b.String()
+}
+
+// escapeLabelValue leaves only the characters %q and Prometheus escape alike.
+func escapeLabelValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '_'
+		}
+		return r
+	}, value)
+}
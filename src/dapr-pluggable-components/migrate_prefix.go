@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	etcdstore "nebulagraph/stores/etcd"
+	scyllastore "nebulagraph/stores/scylladb"
+	"nebulagraph/stores/stateext"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/dapr/components-contrib/metadata"
+	contribstate "github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+)
+
+// prefixMigrationStore is a store the migrate-prefix subcommand can run on.
+type prefixMigrationStore interface {
+	contribstate.Store
+	stateext.PrefixMigrator
+	io.Closer
+}
+
+// runMigratePrefix implements the migrate-prefix subcommand: it renames the
+// keys of one store from one key prefix to another, for example after the
+// component's keyPrefix changed, and can undo a migration from its journal.
+func runMigratePrefix(args []string) int {
+	var sets multiFlag
+	flags := flag.NewFlagSet("migrate-prefix", flag.ExitOnError)
+	storeType := flags.String("store", "scylladb", "Store type: scylladb, cassandra or etcd")
+	from := flags.String("from", "", "Prefix of the keys to rename, e.g. myapp||")
+	to := flags.String("to", "", "New prefix of the keys")
+	batchSize := flags.Int("batch-size", 100, "Keys renamed per batch")
+	dryRun := flags.Bool("dry-run", false, "Only report what would be renamed")
+	journal := flags.String("journal", "", "File the renamed keys are appended to before each batch, for -rollback")
+	rollback := flags.String("rollback", "", "Journal of a migration to undo instead of migrating")
+	flags.Var(&sets, "set", "Component metadata entry name=value, repeatable")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s migrate-prefix -store <type> -set name=value ... -from <prefix> -to <prefix> [flags]\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "       %s migrate-prefix -store <type> -set name=value ... -rollback <journal> [flags]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Renames stored keys from one prefix to another and prints a JSON report.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	migration := stateext.PrefixMigration{From: *from, To: *to, BatchSize: *batchSize, DryRun: *dryRun}
+	var moves []stateext.PrefixMove
+	if *rollback != "" {
+		file, err := os.Open(*rollback)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		moves, err = stateext.ReadJournal(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", *rollback, err)
+			return 1
+		}
+	} else if err := migration.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	properties := make(map[string]string, len(sets))
+	for _, entry := range sets {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -set %q; use name=value\n", entry)
+			return 1
+		}
+		properties[name] = value
+	}
+
+	var base contribstate.Store
+	switch *storeType {
+	case "scylladb":
+		base = scyllastore.NewScyllaStateStore(logger.NewLogger("scylladb-state"))
+	case "cassandra":
+		base = scyllastore.NewCassandraStateStore(logger.NewLogger("cassandra-state"))
+	case "etcd":
+		base = etcdstore.NewEtcdStateStore(logger.NewLogger("etcd-state"))
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: store type %q cannot migrate prefixes\n", *storeType)
+		return 1
+	}
+	store, ok := base.(prefixMigrationStore)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ERROR: store type %q cannot migrate prefixes\n", *storeType)
+		return 1
+	}
+
+	// An interrupted migration stops between keys; the journal lists every
+	// key that may have been renamed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := store.Init(ctx, contribstate.Metadata{Base: metadata.Base{Properties: properties}}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize %s store: %v\n", *storeType, err)
+		return 1
+	}
+	defer store.Close()
+
+	if *journal != "" && !migration.DryRun {
+		file, err := os.OpenFile(*journal, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		migration.Journal = file
+	}
+
+	var (
+		report stateext.PrefixMigrationReport
+		err    error
+	)
+	if *rollback != "" {
+		report, err = store.RollbackPrefixMigration(ctx, moves, migration)
+	} else {
+		report, err = store.MigratePrefix(ctx, migration)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(report.Conflicts) > 0 || len(report.Changed) > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %d keys were left in place, see conflicts and changed\n", len(report.Conflicts)+len(report.Changed))
+		return 2
+	}
+	return 0
+}
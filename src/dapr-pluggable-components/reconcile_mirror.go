@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	scyllastore "nebulagraph/stores/scylladb"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dapr/components-contrib/metadata"
+	contribstate "github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+)
+
+// runReconcileMirror implements the reconcile-mirror subcommand: it compares
+// every key of a ScyllaDB or Cassandra table with the mirror cluster set by
+// mirrorHosts and, with -repair, rewrites the keys that differ on the mirror.
+func runReconcileMirror(args []string) int {
+	var sets multiFlag
+	flags := flag.NewFlagSet("reconcile-mirror", flag.ExitOnError)
+	storeType := flags.String("store", "scylladb", "Store type: scylladb or cassandra")
+	repair := flags.Bool("repair", false, "Rewrite keys that differ on the mirror from the primary")
+	connectTimeout := flags.Duration("connect-timeout", time.Minute, "Time to wait for the mirror cluster to connect")
+	flags.Var(&sets, "set", "Component metadata entry name=value, repeatable; include mirrorHosts")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s reconcile-mirror -store <type> -set name=value ... [flags]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Compares every key with the mirror cluster and prints a JSON report.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	properties := make(map[string]string, len(sets))
+	for _, entry := range sets {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -set %q; use name=value\n", entry)
+			return 1
+		}
+		properties[name] = value
+	}
+	if properties["mirrorHosts"] == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: -set mirrorHosts=... is required\n")
+		return 1
+	}
+	// The command compares everything itself
+	delete(properties, "mirrorReconcileInterval")
+
+	var store *scyllastore.ScyllaStateStore
+	switch *storeType {
+	case "scylladb":
+		store = scyllastore.NewScyllaStateStore(logger.NewLogger("scylladb-state")).(*scyllastore.ScyllaStateStore)
+	case "cassandra":
+		store = scyllastore.NewCassandraStateStore(logger.NewLogger("cassandra-state")).(*scyllastore.ScyllaStateStore)
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: store type %q has no mirror cluster\n", *storeType)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := store.Init(ctx, contribstate.Metadata{Base: metadata.Base{Properties: properties}}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize %s store: %v\n", *storeType, err)
+		return 1
+	}
+	defer store.Close()
+
+	deadline := time.Now().Add(*connectTimeout)
+	for !store.MirrorConnected() {
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "ERROR: mirror cluster %s not connected within %v\n", properties["mirrorHosts"], *connectTimeout)
+			return 1
+		}
+		select {
+		case <-ctx.Done():
+			return 1
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	report, err := store.ReconcileMirror(ctx, *repair)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if divergent := report.Divergent(); divergent > report.Repaired {
+		fmt.Fprintf(os.Stderr, "WARNING: %d of %d keys differ on the mirror cluster\n", divergent-report.Repaired, report.Checked)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	dapr "github.com/dapr-sandbox/components-go-sdk"
+	"github.com/dapr-sandbox/components-go-sdk/state/v1"
+	contribstate "github.com/dapr/components-contrib/state"
+)
+
+const (
+	// Component names become Kubernetes resource names, so they follow the
+	// DNS label rules: lowercase alphanumerics and '-', at most 63 characters.
+	maxComponentNameLength = 63
+
+	// Unix socket paths are limited to 108 bytes including the trailing NUL.
+	maxSocketPathLength = 107
+
+	// Socket folder lookup used by the components SDK.
+	socketFolderEnvVar         = "DAPR_COMPONENT_SOCKETS_FOLDER"
+	fallbackSocketFolderEnvVar = "DAPR_COMPONENT_SOCKET_FOLDER"
+	defaultSocketFolder        = "/tmp/dapr-components-sockets"
+)
+
+var componentNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// registeredSockets maps each registered component name, and so each socket,
+// to the store type that registered it.
+var registeredSockets = make(map[string]string)
+
+// validateComponentName checks that name can be used as a Dapr component name
+// and as the file name of its Unix socket.
+func validateComponentName(name string) error {
+	if name == "" {
+		return fmt.Errorf("component name is empty")
+	}
+	if len(name) > maxComponentNameLength {
+		return fmt.Errorf("component name %q is %d characters long; use at most %d", name, len(name), maxComponentNameLength)
+	}
+	if !componentNamePattern.MatchString(name) {
+		return fmt.Errorf("component name %q is invalid; use lowercase letters, digits and '-', starting and ending with a letter or digit", name)
+	}
+
+	socket := filepath.Join(socketFolder(), name+".sock")
+	if len(socket) > maxSocketPathLength {
+		return fmt.Errorf("socket path %s is %d bytes long, over the %d byte limit for Unix sockets; shorten the component name or %s", socket, len(socket), maxSocketPathLength, socketFolderEnvVar)
+	}
+	return nil
+}
+
+// socketFolder returns the folder the components SDK creates sockets in.
+func socketFolder() string {
+	if folder, ok := os.LookupEnv(socketFolderEnvVar); ok {
+		return folder
+	}
+	if folder, ok := os.LookupEnv(fallbackSocketFolderEnvVar); ok {
+		return folder
+	}
+	return defaultSocketFolder
+}
+
+// registerStateStore validates name and registers the state store factory
+// under it. The SDK merges registrations sharing a name into one socket,
+// which fails at startup with a duplicate gRPC service, so a name already
+// taken by any store type is rejected here instead. Every instance is wrapped
+// in an operationGuard enforcing its allowedOperations, around a storeRouter
+// serving its namedStores.
+func registerStateStore(storeType, name string, factory func() state.Store) error {
+	if err := validateComponentName(name); err != nil {
+		return fmt.Errorf("cannot register %s store: %w", storeType, err)
+	}
+	if owner, taken := registeredSockets[name]; taken {
+		return fmt.Errorf("cannot register %s store as %q: the name and its socket are already used by the %s store", storeType, name, owner)
+	}
+
+	dapr.Register(name, dapr.WithStateStore(func() state.Store {
+		// Named instances are created by the same factory
+		return newOperationGuard(trackRouter(newStoreRouter(func() contribstate.Store { return factory() })))
+	}))
+	registeredSockets[name] = storeType
+	return nil
+}
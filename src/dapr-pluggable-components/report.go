@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	scyllastore "nebulagraph/stores/scylladb"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Sample file used when the report subcommand is given none
+const defaultWorkloadSampleFile = "/tmp/scylladb-workload.jsonl"
+
+// workloadTotals aggregates workload samples across files.
+type workloadTotals struct {
+	samples    int
+	seconds    float64
+	first      time.Time
+	last       time.Time
+	operations map[string]int64
+	peakRate   map[string]float64
+	keySize    []int64
+	readSize   []int64
+	writeSize  []int64
+}
+
+// runReport implements the report subcommand: it summarizes the workload
+// sample files written by workloadSampling for capacity planning.
+func runReport(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s report [sample-file ...]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Summarizes workload samples (default file: %s).\n", defaultWorkloadSampleFile)
+	}
+	flags.Parse(args)
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{defaultWorkloadSampleFile}
+	}
+
+	totals := &workloadTotals{operations: make(map[string]int64), peakRate: make(map[string]float64)}
+	for _, file := range files {
+		if err := totals.addFile(file); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	}
+	if totals.samples == 0 {
+		fmt.Fprintln(os.Stderr, "ERROR: no workload samples found")
+		return 1
+	}
+
+	totals.print()
+	return 0
+}
+
+func (t *workloadTotals) addFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var sample scyllastore.WorkloadSample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return fmt.Errorf("%s:%d: invalid sample: %w", path, line, err)
+		}
+		t.add(sample)
+	}
+	return scanner.Err()
+}
+
+func (t *workloadTotals) add(sample scyllastore.WorkloadSample) {
+	t.samples++
+	t.seconds += sample.Interval
+	start := sample.Time.Add(-time.Duration(sample.Interval * float64(time.Second)))
+	if t.first.IsZero() || start.Before(t.first) {
+		t.first = start
+	}
+	if sample.Time.After(t.last) {
+		t.last = sample.Time
+	}
+
+	for op, count := range sample.Operations {
+		t.operations[op] += count
+		if sample.Interval > 0 {
+			if rate := float64(count) / sample.Interval; rate > t.peakRate[op] {
+				t.peakRate[op] = rate
+			}
+		}
+	}
+	t.keySize = addHistogram(t.keySize, sample.KeySize)
+	t.readSize = addHistogram(t.readSize, sample.ReadSize)
+	t.writeSize = addHistogram(t.writeSize, sample.WriteSize)
+}
+
+func addHistogram(total, histogram []int64) []int64 {
+	for len(total) < len(histogram) {
+		total = append(total, 0)
+	}
+	for i, count := range histogram {
+		total[i] += count
+	}
+	return total
+}
+
+func (t *workloadTotals) print() {
+	fmt.Printf("Workload report: %d samples, %s of traffic between %s and %s\n\n",
+		t.samples, time.Duration(t.seconds*float64(time.Second)).Round(time.Second),
+		t.first.Format(time.RFC3339), t.last.Format(time.RFC3339))
+
+	var total int64
+	ops := make([]string, 0, len(t.operations))
+	for op, count := range t.operations {
+		ops = append(ops, op)
+		total += count
+	}
+	sort.Slice(ops, func(i, j int) bool { return t.operations[ops[i]] > t.operations[ops[j]] })
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "operation\tcount\tshare\tavg/s\tpeak/s\t")
+	for _, op := range ops {
+		count := t.operations[op]
+		fmt.Fprintf(writer, "%s\t%d\t%.1f%%\t%.1f\t%.1f\t\n",
+			op, count, float64(count)*100/float64(total), float64(count)/t.seconds, t.peakRate[op])
+	}
+	writer.Flush()
+
+	fmt.Println()
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "size (bytes)\tcount\tp50\tp90\tp99\tmax\t")
+	for _, row := range []struct {
+		name      string
+		histogram []int64
+	}{
+		{"key", t.keySize},
+		{"read value", t.readSize},
+		{"written value", t.writeSize},
+	} {
+		var count int64
+		for _, n := range row.histogram {
+			count += n
+		}
+		if count == 0 {
+			continue
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\t%s\t\n", row.name, count,
+			histogramQuantile(row.histogram, count, 0.50), histogramQuantile(row.histogram, count, 0.90),
+			histogramQuantile(row.histogram, count, 0.99), histogramQuantile(row.histogram, count, 1))
+	}
+	writer.Flush()
+
+	fmt.Println("\nSizes are upper bounds of power-of-two buckets.")
+}
+
+// histogramQuantile returns the upper bound of the power-of-two bucket
+// holding quantile q of a size histogram.
+func histogramQuantile(histogram []int64, count int64, q float64) string {
+	target := int64(q*float64(count) + 0.5)
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for bucket, n := range histogram {
+		seen += n
+		if seen < target {
+			continue
+		}
+		switch {
+		case bucket == 0:
+			return "0"
+		case bucket >= scyllastore.WorkloadSizeBuckets-1:
+			return fmt.Sprintf(">=%d", int64(1)<<(bucket-1))
+		default:
+			return fmt.Sprintf("<%d", int64(1)<<bucket)
+		}
+	}
+	return "-"
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dapr/components-contrib/state"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"nebulagraph/stores/stateext"
+)
+
+// Component metadata property listing the named instances of a component
+const namedStoresProperty = "namedStores"
+
+// Request metadata key routing a request to a named instance
+const storeMetadataKey = "store"
+
+// Component metadata property holding the token administrative requests carry
+const adminTokenProperty = "adminToken"
+
+var storeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// storeRouter lets one component serve several logical databases, instead of
+// one component per database. namedStores lists instance names, e.g.
+// "ordersdb,billingdb". Each named instance is a separate store of the
+// component's type, initialized with the component metadata overridden by the
+// properties prefixed with its name, such as ordersdb.keyspace. Requests with
+// store=<name> metadata go to that instance; the others go to the default
+// instance, configured by the properties without a prefix.
+//
+// Bulk requests are split by instance. A transaction runs on one instance,
+// named by its metadata or its operations', which must all agree.
+//
+// Named instances can also be added and removed while the component runs,
+// through the admin endpoint (see admin.go).
+type storeRouter struct {
+	state.Store // default instance
+
+	factory func() state.Store
+	// Named instances, nil without any; replaced as a whole on every change
+	named atomic.Pointer[map[string]*routedStore]
+
+	// Serializes Init with adding and removing instances
+	mu sync.Mutex
+	// Metadata of the default instance, which added instances override; set by Init
+	metadata *state.Metadata
+	// Overrides of the instances added at runtime, initialized again with the component
+	added map[string]map[string]string
+}
+
+// routedStore is a named instance. Requests hold its lock shared while they
+// run, so removal can drain them by taking it exclusively.
+type routedStore struct {
+	state.Store
+
+	mu      sync.RWMutex
+	removed bool
+}
+
+// acquire admits a request to the instance; release must follow.
+func (s *routedStore) acquire(name string) error {
+	s.mu.RLock()
+	if s.removed {
+		s.mu.RUnlock()
+		return status.Errorf(codes.Unavailable, "store %q is being removed", name)
+	}
+	return nil
+}
+
+func (s *routedStore) release() {
+	s.mu.RUnlock()
+}
+
+// Compile time check to ensure storeRouter forwards the optional capabilities
+var (
+	_ state.TransactionalStore  = (*storeRouter)(nil)
+	_ state.Querier             = (*storeRouter)(nil)
+	_ stateext.DeleteWithPrefix = (*storeRouter)(nil)
+)
+
+func newStoreRouter(factory func() state.Store) *storeRouter {
+	return &storeRouter{Store: factory(), factory: factory}
+}
+
+func (r *storeRouter) Init(ctx context.Context, metadata state.Metadata) error {
+	names, err := parseNamedStores(metadata.Properties[namedStoresProperty])
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Split the properties into the default's and each instance's overrides
+	base := make(map[string]string, len(metadata.Properties))
+	overrides := make(map[string]map[string]string, len(names))
+	for name, value := range metadata.Properties {
+		if name == namedStoresProperty {
+			continue
+		}
+		if prefix, property, ok := strings.Cut(name, "."); ok && slices.Contains(names, prefix) {
+			if overrides[prefix] == nil {
+				overrides[prefix] = make(map[string]string)
+			}
+			overrides[prefix][property] = value
+			continue
+		}
+		base[name] = value
+	}
+
+	defaultMetadata := metadata
+	defaultMetadata.Properties = base
+	if err := r.Store.Init(ctx, defaultMetadata); err != nil {
+		return err
+	}
+	r.metadata = &defaultMetadata
+
+	// Instances added at runtime outlive the sidecar initializing the component again
+	for _, name := range slices.Sorted(maps.Keys(r.added)) {
+		if slices.Contains(names, name) {
+			delete(r.added, name)
+			continue
+		}
+		names = append(names, name)
+		overrides[name] = r.added[name]
+	}
+	if len(names) == 0 {
+		r.named.Store(nil)
+		return nil
+	}
+
+	named := make(map[string]*routedStore, len(names))
+	for _, name := range names {
+		store, err := r.initInstance(ctx, overrides[name])
+		if err != nil {
+			for _, initialized := range named {
+				closeStore(initialized.Store)
+			}
+			return fmt.Errorf("failed to initialize named store %s: %w", name, err)
+		}
+		named[name] = store
+	}
+	r.named.Store(&named)
+	return nil
+}
+
+// initInstance creates and initializes a named instance with the default
+// instance's properties overridden by overrides.
+func (r *storeRouter) initInstance(ctx context.Context, overrides map[string]string) (*routedStore, error) {
+	properties := make(map[string]string, len(r.metadata.Properties)+len(overrides))
+	for property, value := range r.metadata.Properties {
+		properties[property] = value
+	}
+	for property, value := range overrides {
+		properties[property] = value
+	}
+	instanceMetadata := *r.metadata
+	instanceMetadata.Properties = properties
+
+	store := r.factory()
+	if err := store.Init(ctx, instanceMetadata); err != nil {
+		closeStore(store)
+		return nil, err
+	}
+	return &routedStore{Store: store}, nil
+}
+
+// closeStore closes store when it can be closed.
+func closeStore(store state.Store) {
+	if closer, ok := store.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// namedInstances returns the named instances, or nil without any.
+func (r *storeRouter) namedInstances() map[string]*routedStore {
+	if named := r.named.Load(); named != nil {
+		return *named
+	}
+	return nil
+}
+
+// componentName returns the component name the sidecar initialized the
+// instance with, and false before Init.
+func (r *storeRouter) componentName() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.metadata == nil {
+		return "", false
+	}
+	return r.metadata.Name, true
+}
+
+// validAdminToken reports whether token is the adminToken the component was
+// initialized with. Without one configured, no token is valid.
+func (r *storeRouter) validAdminToken(token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.metadata == nil {
+		return false
+	}
+	configured := r.metadata.Properties[adminTokenProperty]
+	return configured != "" && subtle.ConstantTimeCompare([]byte(token), []byte(configured)) == 1
+}
+
+// addedStores returns the names of the instances added at runtime, sorted.
+func (r *storeRouter) addedStores() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Sorted(maps.Keys(r.added))
+}
+
+// addStore initializes a named instance with the component's properties
+// overridden by properties, and routes requests to it. The instance is
+// initialized again whenever the sidecar initializes the component.
+func (r *storeRouter) addStore(ctx context.Context, name string, properties map[string]string) error {
+	if !storeNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid store name %q; use letters, digits, '-' and '_'", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.metadata == nil {
+		return errors.New("the component is not initialized by the sidecar yet")
+	}
+	if _, exists := r.namedInstances()[name]; exists {
+		return fmt.Errorf("store %q already exists", name)
+	}
+
+	store, err := r.initInstance(ctx, properties)
+	if err != nil {
+		return fmt.Errorf("failed to initialize store %s: %w", name, err)
+	}
+
+	named := maps.Clone(r.namedInstances())
+	if named == nil {
+		named = make(map[string]*routedStore)
+	}
+	named[name] = store
+	r.named.Store(&named)
+	if r.added == nil {
+		r.added = make(map[string]map[string]string)
+	}
+	r.added[name] = maps.Clone(properties)
+	return nil
+}
+
+// removeStore stops routing requests to a named instance, waits for the
+// requests it is serving, and closes it. When ctx ends first, it returns and
+// the instance is closed once drained.
+func (r *storeRouter) removeStore(ctx context.Context, name string) error {
+	r.mu.Lock()
+	instance, exists := r.namedInstances()[name]
+	if !exists {
+		r.mu.Unlock()
+		return fmt.Errorf("unknown store %q", name)
+	}
+	named := maps.Clone(r.namedInstances())
+	delete(named, name)
+	if len(named) == 0 {
+		r.named.Store(nil)
+	} else {
+		r.named.Store(&named)
+	}
+	delete(r.added, name)
+	r.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		// Waits for the requests holding the lock shared
+		instance.mu.Lock()
+		instance.removed = true
+		instance.mu.Unlock()
+		closeStore(instance.Store)
+		untrackStore(instance.Store)
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("store %s is still draining; it is closed once its requests finish: %w", name, ctx.Err())
+	}
+}
+
+// parseNamedStores parses the comma-separated instance names.
+func parseNamedStores(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !storeNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid store name %q in %s; use letters, digits, '-' and '_'", name, namedStoresProperty)
+		}
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("store name %q appears twice in %s", name, namedStoresProperty)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// route returns the instance named by the store metadata, or the default
+// instance without one. release ends the request on the instance.
+func (r *storeRouter) route(metadata map[string]string) (store state.Store, release func(), err error) {
+	name := metadata[storeMetadataKey]
+	if name == "" {
+		return r.Store, func() {}, nil
+	}
+	named := r.named.Load()
+	if named == nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "unknown store %q: this component has no %s", name, namedStoresProperty)
+	}
+	instance, ok := (*named)[name]
+	if !ok {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "unknown store %q: it is not listed in %s or added at runtime", name, namedStoresProperty)
+	}
+	if err := instance.acquire(name); err != nil {
+		return nil, nil, err
+	}
+	return instance.Store, instance.release, nil
+}
+
+// routedBatch is the part of a bulk request going to one instance.
+type routedBatch[T any] struct {
+	store    state.Store
+	requests []T
+}
+
+// splitRequests groups bulk requests by instance, keeping their order within
+// each instance. release ends the request on every instance.
+func splitRequests[T state.StateRequest](r *storeRouter, requests []T) ([]routedBatch[T], func(), error) {
+	var batches []routedBatch[T]
+	var releases []func()
+	release := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+	for _, request := range requests {
+		store, releaseStore, err := r.route(request.GetMetadata())
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		i := slices.IndexFunc(batches, func(batch routedBatch[T]) bool { return batch.store == store })
+		if i < 0 {
+			i = len(batches)
+			batches = append(batches, routedBatch[T]{store: store})
+			releases = append(releases, releaseStore)
+		} else {
+			releaseStore()
+		}
+		batches[i].requests = append(batches[i].requests, request)
+	}
+	return batches, release, nil
+}
+
+// batchError returns the failure of a bulk request split over instances. The
+// gRPC server neither unwraps nor joins errors, so a single failure is
+// returned unchanged, and several keep the status of the first that has one,
+// with every message.
+func batchError(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	joined := errors.Join(errs...)
+	for _, err := range errs {
+		if st, ok := status.FromError(err); ok {
+			proto := st.Proto()
+			proto.Message = joined.Error()
+			return status.ErrorProto(proto)
+		}
+	}
+	return joined
+}
+
+func (r *storeRouter) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	store, release, err := r.route(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return store.Get(ctx, req)
+}
+
+func (r *storeRouter) BulkGet(ctx context.Context, req []state.GetRequest, opts state.BulkGetOpts) ([]state.BulkGetResponse, error) {
+	batches, release, err := splitRequests(r, req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if len(batches) == 1 {
+		return batches[0].store.BulkGet(ctx, req, opts)
+	}
+	responses := make([]state.BulkGetResponse, 0, len(req))
+	for _, batch := range batches {
+		batchResponses, err := batch.store.BulkGet(ctx, batch.requests, opts)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, batchResponses...)
+	}
+	return responses, nil
+}
+
+func (r *storeRouter) Set(ctx context.Context, req *state.SetRequest) error {
+	store, release, err := r.route(req.Metadata)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return store.Set(ctx, req)
+}
+
+func (r *storeRouter) BulkSet(ctx context.Context, req []state.SetRequest, opts state.BulkStoreOpts) error {
+	batches, release, err := splitRequests(r, req)
+	if err != nil {
+		return err
+	}
+	defer release()
+	var errs []error
+	for _, batch := range batches {
+		if err := batch.store.BulkSet(ctx, batch.requests, opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return batchError(errs)
+}
+
+func (r *storeRouter) Delete(ctx context.Context, req *state.DeleteRequest) error {
+	store, release, err := r.route(req.Metadata)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return store.Delete(ctx, req)
+}
+
+func (r *storeRouter) BulkDelete(ctx context.Context, req []state.DeleteRequest, opts state.BulkStoreOpts) error {
+	batches, release, err := splitRequests(r, req)
+	if err != nil {
+		return err
+	}
+	defer release()
+	var errs []error
+	for _, batch := range batches {
+		if err := batch.store.BulkDelete(ctx, batch.requests, opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return batchError(errs)
+}
+
+func (r *storeRouter) Multi(ctx context.Context, request *state.TransactionalStateRequest) error {
+	name := request.Metadata[storeMetadataKey]
+	for _, operation := range request.Operations {
+		other := operation.GetMetadata()[storeMetadataKey]
+		if other == "" {
+			continue
+		}
+		if name == "" {
+			name = other
+		} else if other != name {
+			return status.Errorf(codes.InvalidArgument, "operation on key %s names store %q in a transaction on store %q; a transaction runs on one store",
+				operation.GetKey(), other, name)
+		}
+	}
+	store, release, err := r.route(map[string]string{storeMetadataKey: name})
+	if err != nil {
+		return err
+	}
+	defer release()
+	transactional, ok := store.(state.TransactionalStore)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "method Transact not implemented")
+	}
+	return transactional.Multi(ctx, request)
+}
+
+func (r *storeRouter) Query(ctx context.Context, req *state.QueryRequest) (*state.QueryResponse, error) {
+	store, release, err := r.route(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	querier, ok := store.(state.Querier)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+	}
+	return querier.Query(ctx, req)
+}
+
+func (r *storeRouter) DeleteWithPrefix(ctx context.Context, req stateext.DeleteWithPrefixRequest) (stateext.DeleteWithPrefixResponse, error) {
+	store, release, err := r.route(req.Metadata)
+	if err != nil {
+		return stateext.DeleteWithPrefixResponse{}, err
+	}
+	defer release()
+	deleter, ok := store.(stateext.DeleteWithPrefix)
+	if !ok {
+		return stateext.DeleteWithPrefixResponse{}, status.Errorf(codes.Unimplemented, "method DeleteWithPrefix not implemented")
+	}
+	return deleter.DeleteWithPrefix(ctx, req)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dapr/components-contrib/metadata"
+	"github.com/dapr/components-contrib/state"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"nebulagraph/stores/stateext"
+)
+
+// bulkFailingStore fails bulk writes as its "fail" property says: "etag" with
+// an ETag mismatch, "error" with a plain error, and not at all when unset.
+type bulkFailingStore struct {
+	state.Store
+	err error
+}
+
+func (s *bulkFailingStore) Init(_ context.Context, md state.Metadata) error {
+	switch md.Properties["fail"] {
+	case "etag":
+		s.err = stateext.NewETagError(state.ETagMismatch, errors.New("etag mismatch for key k"))
+	case "error":
+		s.err = errors.New("write timeout")
+	}
+	return nil
+}
+
+func (s *bulkFailingStore) BulkSet(context.Context, []state.SetRequest, state.BulkStoreOpts) error {
+	return s.err
+}
+
+func (s *bulkFailingStore) BulkDelete(context.Context, []state.DeleteRequest, state.BulkStoreOpts) error {
+	return s.err
+}
+
+func TestStoreRouterBulkErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail property of the default instance, a and b
+		fail        [3]string
+		want        codes.Code
+		wantDetails bool
+	}{
+		{"no failure", [3]string{}, codes.OK, false},
+		{"etag mismatch on one instance", [3]string{"", "etag", ""}, codes.FailedPrecondition, true},
+		{"etag mismatch on the default instance", [3]string{"etag", "", ""}, codes.FailedPrecondition, true},
+		{"plain error on one instance", [3]string{"", "", "error"}, codes.Unknown, false},
+		{"etag mismatch and plain error", [3]string{"error", "etag", ""}, codes.FailedPrecondition, true},
+		{"etag mismatch on every instance", [3]string{"etag", "etag", "etag"}, codes.FailedPrecondition, true},
+		{"plain errors", [3]string{"error", "error", ""}, codes.Unknown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newStoreRouter(func() state.Store { return &bulkFailingStore{} })
+			err := router.Init(context.Background(), state.Metadata{Base: metadata.Base{Properties: map[string]string{
+				namedStoresProperty: "a,b",
+				"fail":              tt.fail[0],
+				"a.fail":            tt.fail[1],
+				"b.fail":            tt.fail[2],
+			}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sets := []state.SetRequest{
+				{Key: "k0"},
+				{Key: "k1", Metadata: map[string]string{storeMetadataKey: "a"}},
+				{Key: "k2", Metadata: map[string]string{storeMetadataKey: "b"}},
+			}
+			deletes := []state.DeleteRequest{
+				{Key: "k0"},
+				{Key: "k1", Metadata: map[string]string{storeMetadataKey: "a"}},
+				{Key: "k2", Metadata: map[string]string{storeMetadataKey: "b"}},
+			}
+			for operation, err := range map[string]error{
+				"BulkSet":    router.BulkSet(context.Background(), sets, state.BulkStoreOpts{}),
+				"BulkDelete": router.BulkDelete(context.Background(), deletes, state.BulkStoreOpts{}),
+			} {
+				st, _ := status.FromError(err)
+				if st.Code() != tt.want {
+					t.Errorf("%s: status.FromError code = %s, want %s (%v)", operation, st.Code(), tt.want, err)
+				}
+				hasDetails := false
+				for _, detail := range st.Details() {
+					if _, ok := detail.(*errdetails.BadRequest); ok {
+						hasDetails = true
+					}
+				}
+				if hasDetails != tt.wantDetails {
+					t.Errorf("%s: BadRequest details = %v, want %v", operation, hasDetails, tt.wantDetails)
+				}
+			}
+		})
+	}
+}
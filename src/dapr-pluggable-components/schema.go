@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	scyllastore "nebulagraph/stores/scylladb"
+	"os"
+	"strings"
+
+	"github.com/dapr/components-contrib/metadata"
+	contribstate "github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+)
+
+// runSchema implements the schema subcommand: it prints the CQL a ScyllaDB or
+// Cassandra component would run to create its keyspace and tables, so DBAs
+// can review and apply it where the component runs with createSchema=false.
+func runSchema(args []string) int {
+	var sets multiFlag
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	storeType := flags.String("store", "scylladb", "Store type: scylladb or cassandra")
+	flags.Var(&sets, "set", "Component metadata entry name=value, repeatable")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s schema -store <type> -set name=value ... [flags]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Prints the CQL the component would run for its schema, without connecting.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	properties := make(map[string]string, len(sets))
+	for _, entry := range sets {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid -set %q; use name=value\n", entry)
+			return 1
+		}
+		properties[name] = value
+	}
+
+	// Keep stdout to the statements; configuration warnings still show
+	log := logger.NewLogger("schema")
+	log.SetOutput(os.Stderr)
+	log.SetOutputLevel(logger.WarnLevel)
+
+	var store *scyllastore.ScyllaStateStore
+	switch *storeType {
+	case "scylladb":
+		store = scyllastore.NewScyllaStateStore(log).(*scyllastore.ScyllaStateStore)
+	case "cassandra":
+		store = scyllastore.NewCassandraStateStore(log).(*scyllastore.ScyllaStateStore)
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: store type %q has no schema to print\n", *storeType)
+		return 1
+	}
+
+	statements, err := store.Schema(contribstate.Metadata{Base: metadata.Base{Properties: properties}})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	for i, statement := range statements {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("-- %s\n%s;\n", statement.Comment, statement.CQL)
+	}
+	return 0
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Environment variables restricting who may connect to the component sockets
+const (
+	socketAllowedUIDEnvVar = "SOCKET_ALLOWED_UID"
+	socketAllowedGIDEnvVar = "SOCKET_ALLOWED_GID"
+)
+
+// How long the sockets may take to appear once the SDK starts serving
+const socketAuthWaitTimeout = 30 * time.Second
+
+// socketPeers is the user and group allowed to connect to the sockets.
+type socketPeers struct {
+	uid int // -1 keeps the component's own user
+	gid int // -1 when only the user may connect
+}
+
+// restrictSockets limits the component sockets to the peers configured with
+// SOCKET_ALLOWED_UID and SOCKET_ALLOWED_GID, so on a shared node only the
+// intended sidecar can drive the component. It must run right before the SDK
+// starts serving, and returns false when neither variable is set.
+//
+// The SDK creates its sockets world-writable and serves them with a gRPC
+// server that takes no options, so peers cannot be checked per connection.
+// Connecting to a Unix socket requires write permission on it, though, so the
+// kernel checks the peer's user and group once the socket is owned by the
+// allowed user (and group) with mode 0600 (0660 with a group). The sockets
+// are created owner-only and opened to the allowed peers afterwards, never
+// the other way round.
+func restrictSockets(names []string) (bool, error) {
+	peers, ok, err := socketPeersFromEnv()
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// Also applies to every other file the process creates from now on
+	syscall.Umask(0o077)
+
+	mode := os.FileMode(0o600)
+	if peers.gid >= 0 {
+		mode = 0o660
+	}
+	folder := socketFolder()
+	for _, name := range names {
+		// A socket left by a previous run must not be mistaken for the new one
+		socket := filepath.Join(folder, name+".sock")
+		if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+
+		go func(socket string) {
+			if err := waitForSocket(socket, socketAuthWaitTimeout); err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			if peers.uid >= 0 || peers.gid >= 0 {
+				if err := os.Chown(socket, peers.uid, peers.gid); err != nil {
+					// The socket stays owner-only: fail rather than run unreachable
+					fmt.Printf("ERROR: Cannot hand socket %s to uid %d gid %d: %v\n", socket, peers.uid, peers.gid, err)
+					os.Exit(1)
+				}
+			}
+			if err := os.Chmod(socket, mode); err != nil {
+				fmt.Printf("ERROR: Cannot set mode of socket %s: %v\n", socket, err)
+				os.Exit(1)
+			}
+			fmt.Printf("DEBUG: Socket %s restricted to uid %s gid %s (mode %04o)\n", socket, peerID(peers.uid), peerID(peers.gid), mode)
+		}(socket)
+	}
+	return true, nil
+}
+
+// socketPeersFromEnv parses SOCKET_ALLOWED_UID and SOCKET_ALLOWED_GID.
+func socketPeersFromEnv() (socketPeers, bool, error) {
+	peers := socketPeers{uid: -1, gid: -1}
+	uid, gid := os.Getenv(socketAllowedUIDEnvVar), os.Getenv(socketAllowedGIDEnvVar)
+	if uid == "" && gid == "" {
+		return peers, false, nil
+	}
+
+	var err error
+	if uid != "" {
+		if peers.uid, err = strconv.Atoi(uid); err != nil || peers.uid < 0 {
+			return peers, false, fmt.Errorf("invalid %s %q: use a numeric user id", socketAllowedUIDEnvVar, uid)
+		}
+	}
+	if gid != "" {
+		if peers.gid, err = strconv.Atoi(gid); err != nil || peers.gid < 0 {
+			return peers, false, fmt.Errorf("invalid %s %q: use a numeric group id", socketAllowedGIDEnvVar, gid)
+		}
+	}
+	return peers, true, nil
+}
+
+// waitForSocket waits until the SDK has created the socket.
+func waitForSocket(socket string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("socket %s did not appear within %v", socket, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func peerID(id int) string {
+	if id < 0 {
+		return "unchanged"
+	}
+	return strconv.Itoa(id)
+}
@@ -0,0 +1,563 @@
+package alternator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dapr/components-contrib/state"
+	stateutils "github.com/dapr/components-contrib/state/utils"
+	"github.com/dapr/kit/logger"
+
+	"nebulagraph/stores/clock"
+	"nebulagraph/stores/stateext"
+)
+
+// Attribute names of a state item
+const (
+	keyAttribute   = "key"
+	valueAttribute = "value"
+	etagAttribute  = "etag"
+)
+
+// Request metadata key choosing whether deleting a missing key succeeds
+const ignoreNotFoundMetadataKey = "ignoreNotFound"
+
+// AlternatorStateStore is a state store for ScyllaDB Alternator, Scylla's
+// DynamoDB-compatible API, for teams that standardize on the DynamoDB API
+// while self-hosting Scylla.
+//
+// It talks to Alternator through the AWS SDK:
+// 1. One item per key with the value stored as binary and a string ETag
+// 2. Conditional writes (ConditionExpression) for ETag and first-write concurrency
+// 3. An epoch-seconds TTL attribute honoured by Alternator's TTL expiration
+// 4. Bulk operations and prefix deletes fall back to one request per key
+//
+// Alternator must run with --alternator-write-isolation set, since conditional
+// writes are served with lightweight transactions.
+type AlternatorStateStore struct {
+	state.BulkStore
+
+	client         *dynamodb.Client
+	config         AlternatorConfig
+	requestTimeout time.Duration
+	clock          clock.Clock
+	etags          clock.ETags
+	logger         logger.Logger
+	mu             sync.RWMutex
+	closed         bool
+}
+
+// Compile time check to ensure AlternatorStateStore implements stateext.DeleteWithPrefix
+var _ stateext.DeleteWithPrefix = (*AlternatorStateStore)(nil)
+
+// AlternatorConfig holds the configuration for the Alternator state store.
+type AlternatorConfig struct {
+	Endpoint        string `json:"endpoint" mapstructure:"endpoint"`               // Alternator URL, e.g. http://scylladb-node1:8000 (required)
+	Region          string `json:"region" mapstructure:"region"`                   // Region passed to the SDK; Alternator ignores it (default: us-east-1)
+	Table           string `json:"table" mapstructure:"table"`                     // Table name (default: dapr_state)
+	AccessKeyID     string `json:"accessKeyId" mapstructure:"accessKeyId"`         // Role name when Alternator enforces authorization
+	SecretAccessKey string `json:"secretAccessKey" mapstructure:"secretAccessKey"` // Salted hash of the role when Alternator enforces authorization
+	TTLAttribute    string `json:"ttlAttribute" mapstructure:"ttlAttribute"`       // Attribute holding the expiry time in epoch seconds (default: expiresAt)
+	CreateTable     string `json:"createTable" mapstructure:"createTable"`         // Create the table and enable TTL when missing (default: true)
+	RequestTimeout  string `json:"requestTimeout" mapstructure:"requestTimeout"`   // Timeout per Alternator request (default: 10s)
+	TestClock       string `json:"testClock" mapstructure:"testClock"`             // Hidden: RFC 3339 time the store's clock starts at, for reproducible staging runs
+	TestETags       string `json:"testETags" mapstructure:"testETags"`             // Hidden: ETag generator, time or sequence[:n] (default: time)
+}
+
+// NewAlternatorStateStore creates a new instance of AlternatorStateStore.
+func NewAlternatorStateStore(inputLogger logger.Logger) state.Store {
+	// Create default logger if none provided
+	if inputLogger == nil {
+		inputLogger = logger.NewLogger("alternator-state")
+	}
+	store := &AlternatorStateStore{
+		logger: inputLogger,
+	}
+	store.BulkStore = state.NewDefaultBulkStore(store)
+	return store
+}
+
+// UseClock replaces the clock and the ETag generator of the store, for tests
+// of TTLs and ETag conflicts that should not depend on the wall clock. It
+// must be called before Init; the testClock and testETags metadata take
+// precedence.
+func (store *AlternatorStateStore) UseClock(c clock.Clock, etags clock.ETags) {
+	store.clock = c
+	store.etags = etags
+}
+
+func (store *AlternatorStateStore) Init(ctx context.Context, metadata state.Metadata) error {
+	store.logger.Info("Initializing AlternatorStateStore...")
+
+	// Parse configuration from metadata
+	configBytes, _ := json.Marshal(metadata.Properties)
+	if err := json.Unmarshal(configBytes, &store.config); err != nil {
+		store.logger.Errorf("Failed to parse config: %v", err)
+		return fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	if store.config.Endpoint == "" {
+		return errors.New("endpoint is required")
+	}
+
+	// Set defaults
+	if store.config.Region == "" {
+		store.config.Region = "us-east-1"
+	}
+	if store.config.Table == "" {
+		store.config.Table = "dapr_state"
+	}
+	if store.config.TTLAttribute == "" {
+		store.config.TTLAttribute = "expiresAt"
+	}
+	if store.config.CreateTable == "" {
+		store.config.CreateTable = "true"
+	}
+	if store.config.RequestTimeout == "" {
+		store.config.RequestTimeout = "10s"
+	}
+
+	if timeout, err := time.ParseDuration(store.config.RequestTimeout); err == nil && timeout > 0 {
+		store.requestTimeout = timeout
+	} else {
+		store.logger.Warnf("Invalid requestTimeout: %s, using default", store.config.RequestTimeout)
+		store.requestTimeout = 10 * time.Second
+	}
+
+	if store.config.TestClock != "" || store.config.TestETags != "" {
+		c, etags, err := clock.FromMetadata(store.config.TestClock, store.config.TestETags)
+		if err != nil {
+			store.logger.Warnf("Invalid test clock: %v, using the wall clock", err)
+		} else {
+			store.logger.Warnf("Using test clock (testClock=%q, testETags=%q); not for production", store.config.TestClock, store.config.TestETags)
+			store.clock, store.etags = c, etags
+		}
+	}
+	if store.clock == nil {
+		store.clock = clock.System
+	}
+	if store.etags == nil {
+		store.etags = clock.TimeETags(store.clock)
+	}
+
+	// Without enforced authorization Alternator accepts any credentials
+	accessKeyID, secretAccessKey := store.config.AccessKeyID, store.config.SecretAccessKey
+	if accessKeyID == "" {
+		accessKeyID, secretAccessKey = "alternator", "alternator"
+	}
+	credentials := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, Source: "alternator"}, nil
+	})
+
+	store.client = dynamodb.New(dynamodb.Options{
+		Region:       store.config.Region,
+		BaseEndpoint: aws.String(store.config.Endpoint),
+		Credentials:  aws.NewCredentialsCache(credentials),
+	})
+
+	store.logger.Infof("Alternator config: endpoint=%s, table=%s, ttlAttribute=%s",
+		store.config.Endpoint, store.config.Table, store.config.TTLAttribute)
+
+	initCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if store.config.CreateTable == "true" {
+		if err := store.ensureTable(initCtx); err != nil {
+			return fmt.Errorf("failed to initialize Alternator: %w", err)
+		}
+	} else if _, err := store.client.DescribeTable(initCtx, &dynamodb.DescribeTableInput{TableName: aws.String(store.config.Table)}); err != nil {
+		return fmt.Errorf("failed to describe table %s: %w", store.config.Table, err)
+	}
+
+	store.logger.Info("AlternatorStateStore initialized successfully")
+	return nil
+}
+
+// ensureTable creates the state table when it does not exist and enables TTL
+// expiration on the TTL attribute.
+func (store *AlternatorStateStore) ensureTable(ctx context.Context) error {
+	table := aws.String(store.config.Table)
+
+	_, err := store.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: table})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		store.logger.Infof("Creating table %s", store.config.Table)
+		_, err = store.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+			TableName:   table,
+			BillingMode: types.BillingModePayPerRequest,
+			AttributeDefinitions: []types.AttributeDefinition{
+				{AttributeName: aws.String(keyAttribute), AttributeType: types.ScalarAttributeTypeS},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String(keyAttribute), KeyType: types.KeyTypeHash},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create table %s: %w", store.config.Table, err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to describe table %s: %w", store.config.Table, err)
+	}
+
+	ttl, err := store.client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: table})
+	if err != nil {
+		store.logger.Warnf("TTL status unavailable, expired items are only hidden from reads: %v", err)
+		return nil
+	}
+	if ttl.TimeToLiveDescription != nil && ttl.TimeToLiveDescription.TimeToLiveStatus == types.TimeToLiveStatusEnabled {
+		return nil
+	}
+
+	_, err = store.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: table,
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(store.config.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		store.logger.Warnf("Failed to enable TTL on %s, expired items are only hidden from reads: %v", store.config.TTLAttribute, err)
+	}
+	return nil
+}
+
+func (store *AlternatorStateStore) GetComponentMetadata() map[string]string {
+	return map[string]string{
+		"type":    "state",
+		"version": "v1",
+		"author":  "ScyllaDB Team",
+		"url":     "https://opensource.docs.scylladb.com/stable/alternator/alternator.html",
+	}
+}
+
+func (store *AlternatorStateStore) Features() []state.Feature {
+	// Transactions are not offered: Alternator does not implement TransactWriteItems
+	return []state.Feature{
+		state.FeatureETag,
+		stateext.FeatureDeleteWithPrefix,
+	}
+}
+
+func (store *AlternatorStateStore) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed {
+		return nil, errors.New("store is closed")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	out, err := store.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(store.config.Table),
+		Key:            itemKey(req.Key),
+		ConsistentRead: aws.Bool(req.Options.Consistency == state.Strong),
+	})
+	if err != nil {
+		store.logger.Errorf("Failed to get key %s: %v", req.Key, err)
+		return nil, fmt.Errorf("failed to get key %s: %w", req.Key, err)
+	}
+
+	// Alternator removes expired items in the background, so hide them until then
+	if len(out.Item) == 0 || store.expired(out.Item) {
+		return &state.GetResponse{}, nil
+	}
+
+	response := &state.GetResponse{}
+	if value, ok := out.Item[valueAttribute].(*types.AttributeValueMemberB); ok {
+		response.Data = value.Value
+	}
+	if etag, ok := out.Item[etagAttribute].(*types.AttributeValueMemberS); ok {
+		response.ETag = &etag.Value
+	}
+	return response, nil
+}
+
+func (store *AlternatorStateStore) Set(ctx context.Context, req *state.SetRequest) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed {
+		return errors.New("store is closed")
+	}
+
+	value, err := valueBytes(req.Value)
+	if err != nil {
+		return fmt.Errorf("failed to convert value for key %s: %w", req.Key, err)
+	}
+
+	item := itemKey(req.Key)
+	item[valueAttribute] = &types.AttributeValueMemberB{Value: value}
+	item[etagAttribute] = &types.AttributeValueMemberS{Value: store.etags.NewETag()}
+
+	// PutItem replaces the whole item, so a write without TTL also clears an earlier one
+	ttl, err := stateutils.ParseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("invalid ttl for key %s: %w", req.Key, err)
+	}
+	if ttl != nil && *ttl > 0 {
+		expiresAt := store.clock.Now().Unix() + int64(*ttl)
+		item[store.config.TTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)}
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(store.config.Table),
+		Item:      item,
+	}
+	if req.HasETag() {
+		input.ConditionExpression = aws.String("etag = :etag")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":etag": &types.AttributeValueMemberS{Value: *req.ETag},
+		}
+	} else if req.Options.Concurrency == state.FirstWrite {
+		input.ConditionExpression = aws.String("attribute_not_exists(etag)")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	if _, err := store.client.PutItem(ctx, input); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return stateext.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch for key %s", req.Key))
+		}
+		store.logger.Errorf("Failed to set key %s: %v", req.Key, err)
+		return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+	}
+	return nil
+}
+
+func (store *AlternatorStateStore) Delete(ctx context.Context, req *state.DeleteRequest) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed {
+		return errors.New("store is closed")
+	}
+
+	// Same contract as the ScyllaDB store: a missing key is deleted successfully
+	// unless ignoreNotFound=false, and a mismatched ETag of an existing key fails
+	ignoreNotFound := true
+	if value, ok := req.Metadata[ignoreNotFoundMetadataKey]; ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			store.logger.Warnf("Invalid %s request metadata: %s, using default", ignoreNotFoundMetadataKey, value)
+		} else {
+			ignoreNotFound = parsed
+		}
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.config.Table),
+		Key:       itemKey(req.Key),
+	}
+	switch {
+	case req.HasETag() && ignoreNotFound:
+		input.ConditionExpression = aws.String("attribute_not_exists(etag) OR etag = :etag")
+	case req.HasETag():
+		input.ConditionExpression = aws.String("etag = :etag")
+	case !ignoreNotFound:
+		input.ConditionExpression = aws.String("attribute_exists(etag)")
+	}
+	if req.HasETag() {
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":etag": &types.AttributeValueMemberS{Value: *req.ETag},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	if _, err := store.client.DeleteItem(ctx, input); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return store.deleteConditionError(ctx, req, ignoreNotFound)
+		}
+		store.logger.Errorf("Failed to delete key %s: %v", req.Key, err)
+		return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+	}
+	return nil
+}
+
+// DeleteWithPrefix deletes every key starting with req.Prefix. Alternator has
+// no range delete on the partition key, so the keys are found with a filtered
+// Scan and deleted one DeleteItem at a time.
+func (store *AlternatorStateStore) DeleteWithPrefix(ctx context.Context, req stateext.DeleteWithPrefixRequest) (stateext.DeleteWithPrefixResponse, error) {
+	if err := req.Validate(); err != nil {
+		return stateext.DeleteWithPrefixResponse{}, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed {
+		return stateext.DeleteWithPrefixResponse{}, errors.New("store is closed")
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(store.config.Table),
+		FilterExpression:         aws.String("begins_with(#key, :prefix)"),
+		ProjectionExpression:     aws.String("#key"),
+		ExpressionAttributeNames: map[string]string{"#key": keyAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: req.Prefix},
+		},
+	}
+
+	var deleted int64
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+		page, err := store.client.Scan(pageCtx, input)
+		cancel()
+		if err != nil {
+			return stateext.DeleteWithPrefixResponse{Count: deleted},
+				fmt.Errorf("delete with prefix failed after deleting %d keys: %w", deleted, err)
+		}
+
+		for _, item := range page.Items {
+			deleteCtx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+			_, err := store.client.DeleteItem(deleteCtx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(store.config.Table),
+				Key:       map[string]types.AttributeValue{keyAttribute: item[keyAttribute]},
+			})
+			cancel()
+			if err != nil {
+				return stateext.DeleteWithPrefixResponse{Count: deleted},
+					fmt.Errorf("delete with prefix failed after deleting %d keys: %w", deleted, err)
+			}
+			deleted++
+		}
+
+		if len(page.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+
+	store.logger.Infof("Delete with prefix %q deleted %d keys", req.Prefix, deleted)
+	return stateext.DeleteWithPrefixResponse{Count: deleted}, nil
+}
+
+// deleteConditionError classifies a failed delete condition as a missing key
+// or an ETag mismatch. A strict delete with an ETag cannot tell the two apart
+// from the condition alone, so it reads the item.
+func (store *AlternatorStateStore) deleteConditionError(ctx context.Context, req *state.DeleteRequest, ignoreNotFound bool) error {
+	notFound := fmt.Errorf("key %s not found", req.Key)
+	mismatch := stateext.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch for key %s", req.Key))
+	switch {
+	case !req.HasETag():
+		return notFound
+	case ignoreNotFound:
+		return mismatch
+	}
+
+	out, err := store.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(store.config.Table),
+		Key:            itemKey(req.Key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+	}
+	if len(out.Item) == 0 {
+		return notFound
+	}
+	return mismatch
+}
+
+// Diagnostics reports the store's configuration for the diagnostics endpoint.
+func (store *AlternatorStateStore) Diagnostics() map[string]any {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return map[string]any{
+		"closed":       store.closed,
+		"endpoint":     store.config.Endpoint,
+		"table":        store.config.Table,
+		"ttlAttribute": store.config.TTLAttribute,
+		"authorized":   store.config.AccessKeyID != "",
+	}
+}
+
+// Capabilities returns the capability matrix logged once Init succeeded and
+// served for support bundles. Alternator reports no server version.
+func (store *AlternatorStateStore) Capabilities() map[string]any {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	schemaStatus := "created"
+	if store.config.CreateTable != "true" {
+		schemaStatus = "unchecked"
+	}
+	return map[string]any{
+		"backend": map[string]any{
+			"dialect": "alternator",
+			"version": "unknown",
+		},
+		"features": map[string]any{
+			"ttl":          true,
+			"lwt":          true, // ETags are conditional expressions
+			"transactions": false,
+			"queryAPI":     false,
+			"cache":        []string{},
+			"encryption": map[string]any{
+				"tls": strings.HasPrefix(store.config.Endpoint, "https://"),
+			},
+		},
+		"pool": map[string]any{
+			"endpoint": store.config.Endpoint,
+		},
+		"schema": map[string]any{
+			"table":  store.config.Table,
+			"status": schemaStatus,
+		},
+	}
+}
+
+func (store *AlternatorStateStore) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.closed = true
+	store.logger.Info("AlternatorStateStore closed successfully")
+	return nil
+}
+
+// expired reports whether an item's TTL has passed.
+func (store *AlternatorStateStore) expired(item map[string]types.AttributeValue) bool {
+	expiresAt, ok := item[store.config.TTLAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+	return err == nil && seconds <= store.clock.Now().Unix()
+}
+
+func itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		keyAttribute: &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+// valueBytes converts a state value to the bytes stored in the item.
+func valueBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return []byte{}, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,298 @@
+// Package client is a typed Go API over the state stores of this module, for
+// tools that read and write the same data as Dapr apps without running a
+// sidecar. It opens the store implementations the components serve, with the
+// same component metadata, so keys, values and tables follow the exact
+// conventions of the components.
+//
+// Values are stored as JSON, as the sidecar stores them, except []byte values,
+// which are stored as they are.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dapr/components-contrib/metadata"
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
+	"github.com/dapr/kit/logger"
+
+	alternatorstore "nebulagraph/stores/alternator"
+	etcdstore "nebulagraph/stores/etcd"
+	"nebulagraph/stores/fixtures"
+	scyllastore "nebulagraph/stores/scylladb"
+	simulatedstore "nebulagraph/stores/simulated"
+)
+
+// ErrNotFound is returned when a key has no value.
+var ErrNotFound = errors.New("key not found")
+
+// Separator between the app ID and the key in keys written by a sidecar with
+// the default keyPrefix
+const appKeySeparator = "||"
+
+const jsonContentType = "application/json"
+
+// Options configures a Client.
+type Options struct {
+	// Logger of the store; a logger named after the store type by default
+	Logger logger.Logger
+	// AppID reads and writes the keys of a Dapr app, which its sidecar stores
+	// as <app ID>||<key>; keys are used as given without it
+	AppID string
+}
+
+// Client reads and writes one store.
+type Client struct {
+	store     state.Store
+	storeType string
+	prefix    string
+}
+
+// NewStore creates an uninitialized store of the given type: scylladb,
+// cassandra, alternator, etcd or simulated.
+func NewStore(storeType string, log logger.Logger) (state.Store, error) {
+	switch storeType {
+	case "scylladb":
+		return scyllastore.NewScyllaStateStore(log), nil
+	case "cassandra":
+		return scyllastore.NewCassandraStateStore(log), nil
+	case "alternator":
+		return alternatorstore.NewAlternatorStateStore(log), nil
+	case "etcd":
+		return etcdstore.NewEtcdStateStore(log), nil
+	case "simulated":
+		return simulatedstore.NewSimulatedStateStore(log), nil
+	default:
+		return nil, fmt.Errorf("unknown store type %q; use scylladb, cassandra, alternator, etcd or simulated", storeType)
+	}
+}
+
+// Open connects to a store of the given type configured by properties, the
+// metadata of a component of that type.
+func Open(ctx context.Context, storeType string, properties map[string]string, opts Options) (*Client, error) {
+	log := opts.Logger
+	if log == nil {
+		log = logger.NewLogger(storeType + "-client")
+	}
+	store, err := NewStore(storeType, log)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Init(ctx, state.Metadata{Base: metadata.Base{Properties: properties}}); err != nil {
+		return nil, fmt.Errorf("failed to initialize %s store: %w", storeType, err)
+	}
+
+	client := &Client{store: store, storeType: storeType}
+	if opts.AppID != "" {
+		client.prefix = opts.AppID + appKeySeparator
+	}
+	return client, nil
+}
+
+// Store returns the underlying Dapr state store, for operations the client
+// does not cover.
+func (c *Client) Store() state.Store {
+	return c.store
+}
+
+// Close releases the store's connections.
+func (c *Client) Close() error {
+	if closer, ok := c.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// storageKey returns the key the store holds key under.
+func (c *Client) storageKey(key string) string {
+	return c.prefix + key
+}
+
+// Item is a value read from the store.
+type Item[T any] struct {
+	Key   string
+	Value T
+	ETag  string
+	// Response metadata, such as the row times of ttlMetadata
+	Metadata map[string]string
+}
+
+// WriteOption configures a Set or Delete.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	etag     *string
+	metadata map[string]string
+}
+
+// WithETag makes the write fail unless the key's current ETag is etag.
+func WithETag(etag string) WriteOption {
+	return func(o *writeOptions) { o.etag = &etag }
+}
+
+// WithTTL makes the value expire after ttl, in whole seconds.
+func WithTTL(ttl time.Duration) WriteOption {
+	return WithMetadata("ttlInSeconds", strconv.Itoa(int(ttl/time.Second)))
+}
+
+// WithMetadata sets a request metadata entry, as the sidecar's
+// metadata.<name> query parameters do.
+func WithMetadata(name, value string) WriteOption {
+	return func(o *writeOptions) { o.metadata[name] = value }
+}
+
+func newWriteOptions(opts []WriteOption) writeOptions {
+	options := writeOptions{metadata: map[string]string{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// encode returns the stored form of value.
+func encode[T any](value T) ([]byte, error) {
+	if data, ok := any(value).([]byte); ok {
+		return data, nil
+	}
+	return json.Marshal(value)
+}
+
+// decode returns the value of the stored data.
+func decode[T any](data []byte) (T, error) {
+	var value T
+	if _, ok := any(value).([]byte); ok {
+		return any(data).(T), nil
+	}
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// Get reads the value of key, or returns ErrNotFound.
+func Get[T any](ctx context.Context, c *Client, key string) (Item[T], error) {
+	response, err := c.store.Get(ctx, &state.GetRequest{Key: c.storageKey(key)})
+	if err != nil {
+		return Item[T]{}, err
+	}
+	if response == nil || response.Data == nil {
+		return Item[T]{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	value, err := decode[T](response.Data)
+	if err != nil {
+		return Item[T]{}, fmt.Errorf("failed to decode value of key %s: %w", key, err)
+	}
+	item := Item[T]{Key: key, Value: value, Metadata: response.Metadata}
+	if response.ETag != nil {
+		item.ETag = *response.ETag
+	}
+	return item, nil
+}
+
+// Set writes value to key.
+func Set[T any](ctx context.Context, c *Client, key string, value T, opts ...WriteOption) error {
+	data, err := encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value of key %s: %w", key, err)
+	}
+	options := newWriteOptions(opts)
+	request := &state.SetRequest{
+		Key:      c.storageKey(key),
+		Value:    data,
+		ETag:     options.etag,
+		Metadata: options.metadata,
+	}
+	if _, raw := any(value).([]byte); !raw {
+		contentType := jsonContentType
+		request.ContentType = &contentType
+	}
+	return c.store.Set(ctx, request)
+}
+
+// Delete deletes key.
+func (c *Client) Delete(ctx context.Context, key string, opts ...WriteOption) error {
+	options := newWriteOptions(opts)
+	return c.store.Delete(ctx, &state.DeleteRequest{
+		Key:      c.storageKey(key),
+		ETag:     options.etag,
+		Metadata: options.metadata,
+	})
+}
+
+// QueryResult is a page of query results.
+type QueryResult[T any] struct {
+	Items []Item[T]
+	// Token of the next page, empty after the last one
+	Token string
+}
+
+// Query runs a query of the Dapr state query API, given as its JSON form, on
+// stores that support it. With an AppID, only the app's keys are returned.
+func Query[T any](ctx context.Context, c *Client, q string, metadata map[string]string) (QueryResult[T], error) {
+	querier, ok := c.store.(state.Querier)
+	if !ok {
+		return QueryResult[T]{}, fmt.Errorf("the %s store does not support queries", c.storeType)
+	}
+	var parsed query.Query
+	if err := json.Unmarshal([]byte(q), &parsed); err != nil {
+		return QueryResult[T]{}, fmt.Errorf("invalid query: %w", err)
+	}
+
+	response, err := querier.Query(ctx, &state.QueryRequest{Query: parsed, Metadata: metadata})
+	if err != nil {
+		return QueryResult[T]{}, err
+	}
+	result := QueryResult[T]{Token: response.Token}
+	for _, found := range response.Results {
+		key, ok := strings.CutPrefix(found.Key, c.prefix)
+		if !ok {
+			continue
+		}
+		if found.Error != "" {
+			return result, fmt.Errorf("query result %s: %s", key, found.Error)
+		}
+		value, err := decode[T](found.Data)
+		if err != nil {
+			return result, fmt.Errorf("failed to decode value of key %s: %w", key, err)
+		}
+		item := Item[T]{Key: key, Value: value}
+		if found.ETag != nil {
+			item.ETag = *found.ETag
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result, nil
+}
+
+// Relate stores the key to in the field of the JSON object stored at from,
+// as fixture relationships do. field is a dot-separated path, and objects
+// along it are created. Both keys must exist. The write is conditional on the
+// ETag read, so a concurrent change of from fails it with an ETag mismatch.
+func (c *Client) Relate(ctx context.Context, from, field, to string) error {
+	if field == "" {
+		return fmt.Errorf("relationship of %s to %s without a field", from, to)
+	}
+	if _, err := Get[json.RawMessage](ctx, c, to); err != nil {
+		return fmt.Errorf("relationship of %s to %s: %w", from, to, err)
+	}
+	item, err := Get[any](ctx, c, from)
+	if err != nil {
+		return fmt.Errorf("relationship of %s to %s: %w", from, to, err)
+	}
+
+	value, err := fixtures.SetField(item.Value, strings.Split(field, "."), to)
+	if err != nil {
+		return fmt.Errorf("relationship of %s to %s: %w", from, to, err)
+	}
+	var opts []WriteOption
+	if item.ETag != "" {
+		opts = append(opts, WithETag(item.ETag))
+	}
+	return Set(ctx, c, from, value, opts...)
+}
@@ -0,0 +1,161 @@
+// Package clock abstracts the wall clock and the ETag generation of the state
+// stores, so tests can control time and ETags instead of sleeping, and staging
+// runs can be replayed with the same timestamps and ETags.
+//
+// Only the time stored with state is taken from a Clock: last-modified
+// columns, expiry times, ages of cached entries and time bucket selection.
+// Latencies and timeouts are measured with the real monotonic clock.
+package clock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// ETags generates the ETags written with new values. Every call returns an
+// ETag that has not been returned before.
+type ETags interface {
+	NewETag() string
+}
+
+// Since returns the time elapsed on c since t.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+type system struct{}
+
+func (system) Now() time.Time { return time.Now() }
+
+// System is the wall clock.
+var System Clock = system{}
+
+// Manual is a clock that only moves when told to, for tests of TTLs,
+// expirations and background jobs.
+type Manual struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManual returns a manual clock set to start.
+func NewManual(start time.Time) *Manual {
+	return &Manual{now: start}
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Advance moves the clock forward by d.
+func (m *Manual) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+// Set moves the clock to t, which may be in the past.
+func (m *Manual) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = t
+}
+
+// shifted runs at the pace of the wall clock from a chosen start.
+type shifted struct {
+	offset time.Duration
+}
+
+func (s shifted) Now() time.Time { return time.Now().Add(s.offset) }
+
+// StartingAt returns a clock that reads start now and then advances with the
+// wall clock, so a replayed run sees the same times relative to its start.
+func StartingAt(start time.Time) Clock {
+	return shifted{offset: time.Until(start)}
+}
+
+// timeETags formats the clock's time in nanoseconds. An ETag is never lower
+// than the previous one, so a clock that stands still still yields unique
+// ETags.
+type timeETags struct {
+	clock Clock
+	last  atomic.Int64
+}
+
+// TimeETags returns ETags made of c's current time in nanoseconds, the format
+// the stores have always written.
+func TimeETags(c Clock) ETags {
+	return &timeETags{clock: c}
+}
+
+func (t *timeETags) NewETag() string {
+	for {
+		last := t.last.Load()
+		next := t.clock.Now().UnixNano()
+		if next <= last {
+			next = last + 1
+		}
+		if t.last.CompareAndSwap(last, next) {
+			return strconv.FormatInt(next, 10)
+		}
+	}
+}
+
+// Sequence returns consecutive numbers as ETags.
+type Sequence struct {
+	last atomic.Int64
+}
+
+// NewSequence returns ETags counting up from after.
+func NewSequence(after int64) *Sequence {
+	s := &Sequence{}
+	s.last.Store(after)
+	return s
+}
+
+func (s *Sequence) NewETag() string {
+	return strconv.FormatInt(s.last.Add(1), 10)
+}
+
+// FromMetadata builds the clock and ETags selected by the stores' hidden
+// testClock and testETags metadata. An empty testClock is the wall clock,
+// otherwise an RFC 3339 time the clock starts at. An empty testETags, or
+// "time", formats the clock's time; "sequence" or "sequence:<n>" counts up
+// from n (default 0).
+func FromMetadata(testClock, testETags string) (Clock, ETags, error) {
+	c := System
+	if testClock != "" {
+		start, err := time.Parse(time.RFC3339Nano, testClock)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid testClock %q: %w", testClock, err)
+		}
+		c = StartingAt(start)
+	}
+
+	name, arg, _ := strings.Cut(testETags, ":")
+	switch name {
+	case "", "time":
+		return c, TimeETags(c), nil
+	case "sequence":
+		var after int64
+		if arg != "" {
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid testETags %q: %w", testETags, err)
+			}
+			after = n
+		}
+		return c, NewSequence(after), nil
+	}
+	return nil, nil, fmt.Errorf("invalid testETags %q: use time or sequence[:n]", testETags)
+}
@@ -0,0 +1,215 @@
+// Package discovery resolves the hosts of a backend cluster, so components can
+// name a DNS SRV record or a Kubernetes service instead of listing hosts, and
+// follows the cluster as it scales.
+//
+// A host specification is one of:
+//
+//	host1,host2:9042          static hosts or URLs; hosts without a port get the default port
+//	srv:_cql._tcp.scylla.db   the targets and ports of a DNS SRV record
+//	dns:scylla-headless.db    every address of a name, e.g. a headless service
+//	k8s:db/scylla-client:cql  the ready endpoints of a Kubernetes service
+//
+// The dns: and k8s: forms take an optional port after the name; k8s: also
+// accepts a port name and an optional namespace, which defaults to the pod's.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dapr/kit/logger"
+)
+
+// Resolver returns the current hosts of a cluster as host:port pairs.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+	// String returns the specification the resolver was parsed from
+	String() string
+}
+
+// watcher is implemented by resolvers that are notified of changes instead
+// of polling. Watch calls onChange with every new list of hosts until ctx ends.
+type watcher interface {
+	Watch(ctx context.Context, onChange func([]string)) error
+}
+
+// Parse returns the resolver of a host specification. defaultPort is added to
+// hosts without a port.
+func Parse(spec, defaultPort string) (Resolver, error) {
+	spec = strings.TrimSpace(spec)
+	scheme, target, _ := strings.Cut(spec, ":")
+
+	switch scheme {
+	case "srv":
+		if target == "" {
+			return nil, fmt.Errorf("invalid host specification %q: missing SRV name", spec)
+		}
+		return &srvResolver{spec: spec, name: target}, nil
+	case "dns":
+		name, port := splitPort(target, defaultPort)
+		if name == "" {
+			return nil, fmt.Errorf("invalid host specification %q: missing name", spec)
+		}
+		return &dnsResolver{spec: spec, name: name, port: port}, nil
+	case "k8s":
+		return parseKubernetes(spec, target, defaultPort)
+	default:
+		// Anything else, including host:port, is a static list
+		var hosts []string
+		for _, host := range strings.Split(spec, ",") {
+			if host = strings.TrimSpace(host); host == "" {
+				continue
+			}
+			if strings.Contains(host, "://") {
+				// URLs are used as they are
+				hosts = append(hosts, host)
+				continue
+			}
+			name, port := splitPort(host, defaultPort)
+			hosts = append(hosts, net.JoinHostPort(name, port))
+		}
+		if len(hosts) == 0 {
+			return nil, errors.New("no hosts")
+		}
+		return staticResolver{spec: spec, hosts: hosts}, nil
+	}
+}
+
+// IsStatic reports whether r returns a fixed list of hosts.
+func IsStatic(r Resolver) bool {
+	_, ok := r.(staticResolver)
+	return ok
+}
+
+// Watch follows the hosts of r until ctx ends, calling onChange whenever they
+// differ from current. Resolvers that cannot be notified of changes are polled
+// every interval. Failed lookups are logged and keep the current hosts; an
+// empty result is treated as a failure, so a DNS outage never empties the
+// list.
+func Watch(ctx context.Context, r Resolver, current []string, interval time.Duration, log logger.Logger, onChange func([]string)) {
+	if IsStatic(r) {
+		return
+	}
+	current = normalize(current)
+	update := func(hosts []string) {
+		hosts = normalize(hosts)
+		if len(hosts) == 0 || slices.Equal(hosts, current) {
+			return
+		}
+		log.Infof("Hosts of %s changed from %v to %v", r, current, hosts)
+		current = hosts
+		onChange(slices.Clone(hosts))
+	}
+
+	if w, ok := r.(watcher); ok {
+		for ctx.Err() == nil {
+			if err := w.Watch(ctx, update); err != nil && ctx.Err() == nil {
+				log.Warnf("Watching hosts of %s failed, retrying in %v: %v", r, interval, err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+		}
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, interval)
+		hosts, err := r.Resolve(lookupCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warnf("Resolving hosts of %s failed, keeping %v: %v", r, current, err)
+			}
+			continue
+		}
+		update(hosts)
+	}
+}
+
+// normalize sorts hosts and removes duplicates.
+func normalize(hosts []string) []string {
+	hosts = slices.Clone(hosts)
+	slices.Sort(hosts)
+	return slices.Compact(hosts)
+}
+
+// splitPort splits host[:port], using defaultPort when there is none.
+func splitPort(hostport, defaultPort string) (string, string) {
+	if host, port, err := net.SplitHostPort(hostport); err == nil {
+		return host, port
+	}
+	return strings.Trim(hostport, "[]"), defaultPort
+}
+
+type staticResolver struct {
+	spec  string
+	hosts []string
+}
+
+func (r staticResolver) Resolve(context.Context) ([]string, error) {
+	return slices.Clone(r.hosts), nil
+}
+
+func (r staticResolver) String() string { return r.spec }
+
+// srvResolver returns the targets of a DNS SRV record, with their ports.
+type srvResolver struct {
+	spec string
+	name string
+}
+
+func (r *srvResolver) Resolve(ctx context.Context) ([]string, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", r.name)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, 0, len(records))
+	for _, record := range records {
+		hosts = append(hosts, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), fmt.Sprint(record.Port)))
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("SRV record %s has no targets", r.name)
+	}
+	return hosts, nil
+}
+
+func (r *srvResolver) String() string { return r.spec }
+
+// dnsResolver returns every address of a name, such as the pods behind a
+// Kubernetes headless service.
+type dnsResolver struct {
+	spec string
+	name string
+	port string
+}
+
+func (r *dnsResolver) Resolve(ctx context.Context) ([]string, error) {
+	addresses, err := net.DefaultResolver.LookupHost(ctx, r.name)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		hosts = append(hosts, net.JoinHostPort(address, r.port))
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", r.name)
+	}
+	return hosts, nil
+}
+
+func (r *dnsResolver) String() string { return r.spec }
@@ -0,0 +1,237 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Files and environment the pod's service account provides
+const (
+	serviceAccountDir     = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesHostEnvVar  = "KUBERNETES_SERVICE_HOST"
+	kubernetesPortEnvVar  = "KUBERNETES_SERVICE_PORT"
+	kubernetesWatchPeriod = 300 // seconds a watch request stays open
+)
+
+// kubernetesResolver returns the ready addresses of a Kubernetes service from
+// its Endpoints object, read with the pod's service account, and watches the
+// object for changes. The service account needs get, list and watch on
+// endpoints in the service's namespace.
+type kubernetesResolver struct {
+	spec      string
+	namespace string
+	service   string
+	// Port number, or the name of a port of the service
+	port string
+
+	apiServer string
+	client    *http.Client
+}
+
+// endpoints holds the fields of a Kubernetes Endpoints object used here.
+type endpoints struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+// watchEvent is one event of a watch stream.
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// parseKubernetes parses [namespace/]service[:port] and prepares the
+// in-cluster API client.
+func parseKubernetes(spec, target, defaultPort string) (*kubernetesResolver, error) {
+	name, port := splitPort(target, defaultPort)
+	namespace, service, ok := strings.Cut(name, "/")
+	if !ok {
+		namespace, service = "", name
+	}
+	if service == "" {
+		return nil, fmt.Errorf("invalid host specification %q: missing service name", spec)
+	}
+
+	host, apiPort := os.Getenv(kubernetesHostEnvVar), os.Getenv(kubernetesPortEnvVar)
+	if host == "" || apiPort == "" {
+		return nil, fmt.Errorf("%s needs to run in a Kubernetes pod: %s is not set", spec, kubernetesHostEnvVar)
+	}
+	if namespace == "" {
+		own, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine the namespace of %s: %w", spec, err)
+		}
+		namespace = strings.TrimSpace(string(own))
+	}
+
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("service account CA contains no certificates")
+	}
+
+	return &kubernetesResolver{
+		spec:      spec,
+		namespace: namespace,
+		service:   service,
+		port:      port,
+		apiServer: "https://" + net.JoinHostPort(host, apiPort),
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+				Proxy:           http.ProxyFromEnvironment,
+			},
+		},
+	}, nil
+}
+
+func (r *kubernetesResolver) String() string { return r.spec }
+
+func (r *kubernetesResolver) Resolve(ctx context.Context) ([]string, error) {
+	object, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.hosts(object)
+}
+
+// Watch reads the Endpoints object, then follows it until the API server ends
+// the watch, an error occurs or ctx ends.
+func (r *kubernetesResolver) Watch(ctx context.Context, onChange func([]string)) error {
+	object, err := r.get(ctx)
+	if err != nil {
+		return err
+	}
+	if hosts, err := r.hosts(object); err == nil {
+		onChange(hosts)
+	}
+
+	query := url.Values{
+		"watch":           {"true"},
+		"fieldSelector":   {"metadata.name=" + r.service},
+		"resourceVersion": {object.Metadata.ResourceVersion},
+		"timeoutSeconds":  {strconv.Itoa(kubernetesWatchPeriod)},
+	}
+	response, err := r.request(ctx, fmt.Sprintf("/api/v1/namespaces/%s/endpoints?%s", url.PathEscape(r.namespace), query.Encode()))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			var changed endpoints
+			if err := json.Unmarshal(event.Object, &changed); err != nil {
+				return err
+			}
+			// An Endpoints object without ready addresses keeps the current hosts
+			if hosts, err := r.hosts(changed); err == nil {
+				onChange(hosts)
+			}
+		case "ERROR":
+			// Typically 410 Gone once the resource version is too old
+			return fmt.Errorf("watch of endpoints %s/%s failed: %s", r.namespace, r.service, event.Object)
+		}
+	}
+}
+
+// get reads the Endpoints object of the service.
+func (r *kubernetesResolver) get(ctx context.Context) (endpoints, error) {
+	var object endpoints
+	response, err := r.request(ctx, fmt.Sprintf("/api/v1/namespaces/%s/endpoints/%s", url.PathEscape(r.namespace), url.PathEscape(r.service)))
+	if err != nil {
+		return object, err
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(&object); err != nil {
+		return object, fmt.Errorf("invalid endpoints %s/%s: %w", r.namespace, r.service, err)
+	}
+	return object, nil
+}
+
+// request sends an authenticated GET to the API server. The token is read for
+// every request, as projected service account tokens are rotated.
+func (r *kubernetesResolver) request(ctx context.Context, path string) (*http.Response, error) {
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the service account token: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, r.apiServer+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	request.Header.Set("Accept", "application/json")
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		response.Body.Close()
+		return nil, fmt.Errorf("endpoints %s/%s: %s: %s", r.namespace, r.service, response.Status, strings.TrimSpace(string(body)))
+	}
+	return response, nil
+}
+
+// hosts returns the ready addresses of an Endpoints object with the resolver's
+// port, resolving a port name per subset.
+func (r *kubernetesResolver) hosts(object endpoints) ([]string, error) {
+	_, numeric := strconv.Atoi(r.port)
+	var hosts []string
+	for _, subset := range object.Subsets {
+		port := r.port
+		if numeric != nil {
+			port = ""
+			for _, candidate := range subset.Ports {
+				if candidate.Name == r.port {
+					port = strconv.Itoa(candidate.Port)
+				}
+			}
+			if port == "" {
+				continue
+			}
+		}
+		for _, address := range subset.Addresses {
+			hosts = append(hosts, net.JoinHostPort(address.IP, port))
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("service %s/%s has no ready endpoints with port %s", r.namespace, r.service, r.port)
+	}
+	return hosts, nil
+}
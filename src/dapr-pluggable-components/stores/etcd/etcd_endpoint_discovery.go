@@ -0,0 +1,53 @@
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"nebulagraph/stores/discovery"
+)
+
+// Port of endpoints given without one
+const defaultClientPort = "2379"
+
+// endpointWatch follows the endpoints named by a DNS SRV record or a
+// Kubernetes service and hands every change to the client, which balances
+// requests over the new endpoints without reconnecting.
+type endpointWatch struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// watchEndpoints starts following the endpoints of resolver, initially
+// endpoints. The caller holds no lock.
+func (store *EtcdStateStore) watchEndpoints(resolver discovery.Resolver, endpoints []string) {
+	interval, err := time.ParseDuration(store.config.EndpointDiscoveryInterval)
+	if err != nil || interval <= 0 {
+		store.logger.Warnf("Invalid endpointDiscoveryInterval: %s, using default", store.config.EndpointDiscoveryInterval)
+		interval = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watch := &endpointWatch{cancel: cancel, done: make(chan struct{})}
+	store.endpointWatch = watch
+
+	store.logger.Infof("Following the endpoints of %s (interval=%v)", resolver, interval)
+
+	go func() {
+		defer close(watch.done)
+		discovery.Watch(ctx, resolver, endpoints, interval, store.logger, func(endpoints []string) {
+			store.mu.Lock()
+			defer store.mu.Unlock()
+			if store.closed {
+				return
+			}
+			store.client.SetEndpoints(endpoints...)
+			store.endpoints = endpoints
+		})
+	}()
+}
+
+func (w *endpointWatch) stop() {
+	w.cancel()
+	<-w.done
+}
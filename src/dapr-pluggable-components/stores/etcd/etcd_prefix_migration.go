@@ -0,0 +1,218 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"nebulagraph/stores/stateext"
+)
+
+// Keys renamed per batch when the migration does not say
+const defaultPrefixMigrationBatchSize = 100
+
+// Compile time check to ensure EtcdStateStore implements stateext.PrefixMigrator
+var _ stateext.PrefixMigrator = (*EtcdStateStore)(nil)
+
+// MigratePrefix renames every key starting with m.From in place. Keys are
+// listed in pages of m.BatchSize; each page is written to the journal, then
+// every key is moved with one Txn that puts the new key on the old key's
+// lease and deletes the old key, provided the old key is unchanged and the
+// new one does not exist. Moved keys get a new ETag, as any etcd write does.
+func (store *EtcdStateStore) MigratePrefix(ctx context.Context, m stateext.PrefixMigration) (stateext.PrefixMigrationReport, error) {
+	var report stateext.PrefixMigrationReport
+	if err := m.Validate(); err != nil {
+		return report, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.ready(); err != nil {
+		return report, err
+	}
+	batchSize := m.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPrefixMigrationBatchSize
+	}
+
+	store.logger.Infof("Renaming keys with prefix %q to %q (dryRun=%t)", m.From, m.To, m.DryRun)
+
+	prefix := store.etcdKey(m.From)
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	renamed := make(map[string]bool)
+	for start := prefix; ; {
+		pageCtx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+		resp, err := store.client.Get(pageCtx, start, clientv3.WithRange(end), clientv3.WithLimit(int64(batchSize)), clientv3.WithKeysOnly())
+		cancel()
+		if err != nil {
+			return report, fmt.Errorf("failed to list keys with prefix %s: %w", m.From, err)
+		}
+
+		var batch []stateext.PrefixMove
+		for _, kv := range resp.Kvs {
+			report.Scanned++
+			key := strings.TrimPrefix(string(kv.Key), store.config.KeyPrefixPath+"/")
+			if !m.Matches(key) || renamed[key] {
+				continue
+			}
+			report.Matched++
+			move := stateext.PrefixMove{From: key, To: m.Rename(key)}
+			if m.Matches(move.To) {
+				renamed[move.To] = true
+			}
+			batch = append(batch, move)
+		}
+		if err := store.movePrefixBatch(ctx, batch, m, &report); err != nil {
+			return report, err
+		}
+		store.logger.Infof("Prefix migration progress: scanned %d keys, matched %d, moved %d", report.Scanned, report.Matched, report.Moved)
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	store.logger.Infof("Prefix migration done: moved %d of %d keys, %d conflicts, %d changed, %d missing",
+		report.Moved, report.Matched, len(report.Conflicts), len(report.Changed), report.Missing)
+	return report, nil
+}
+
+// RollbackPrefixMigration renames the keys of a migration journal back.
+func (store *EtcdStateStore) RollbackPrefixMigration(ctx context.Context, journal []stateext.PrefixMove, m stateext.PrefixMigration) (stateext.PrefixMigrationReport, error) {
+	var report stateext.PrefixMigrationReport
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.ready(); err != nil {
+		return report, err
+	}
+	batchSize := m.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPrefixMigrationBatchSize
+	}
+
+	store.logger.Infof("Rolling back %d renamed keys (dryRun=%t)", len(journal), m.DryRun)
+
+	// The rollback itself is not journaled: the journal already lists its moves
+	m.Journal = nil
+	for start := 0; start < len(journal); start += batchSize {
+		end := min(start+batchSize, len(journal))
+		batch := make([]stateext.PrefixMove, 0, end-start)
+		for _, move := range journal[start:end] {
+			batch = append(batch, stateext.PrefixMove{From: move.To, To: move.From})
+		}
+		report.Scanned += int64(len(batch))
+		report.Matched += int64(len(batch))
+		if err := store.movePrefixBatch(ctx, batch, m, &report); err != nil {
+			return report, err
+		}
+	}
+
+	store.logger.Infof("Prefix migration rollback done: moved %d of %d keys, %d conflicts, %d changed, %d missing",
+		report.Moved, report.Matched, len(report.Conflicts), len(report.Changed), report.Missing)
+	return report, nil
+}
+
+// movePrefixBatch renames the keys of one batch and adds the outcome to
+// report. In dry-run mode it only looks for new names that already exist.
+func (store *EtcdStateStore) movePrefixBatch(ctx context.Context, moves []stateext.PrefixMove, m stateext.PrefixMigration, report *stateext.PrefixMigrationReport) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	if !m.DryRun {
+		if err := stateext.WriteJournal(m.Journal, moves); err != nil {
+			return err
+		}
+	}
+
+	for _, move := range moves {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if m.DryRun {
+			exists, err := store.keyExists(ctx, move.To)
+			if err != nil {
+				return err
+			}
+			if exists {
+				report.Conflicts = append(report.Conflicts, move.From)
+				continue
+			}
+			store.logger.Infof("DRY RUN: would rename key %s to %s", move.From, move.To)
+			report.Moved++
+			continue
+		}
+
+		if err := store.moveKey(ctx, move, report); err != nil {
+			return fmt.Errorf("failed to rename key %s: %w", move.From, err)
+		}
+	}
+	return nil
+}
+
+// moveKey renames one key with a single Txn and records the outcome.
+func (store *EtcdStateStore) moveKey(ctx context.Context, move stateext.PrefixMove, report *stateext.PrefixMigrationReport) error {
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	from, to := store.etcdKey(move.From), store.etcdKey(move.To)
+	resp, err := store.client.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		report.Missing++
+		return nil
+	}
+	kv := resp.Kvs[0]
+
+	put := clientv3.OpPut(to, string(kv.Value))
+	if kv.Lease != 0 {
+		put = clientv3.OpPut(to, string(kv.Value), clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+	}
+	txn, err := store.client.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.ModRevision(from), "=", kv.ModRevision),
+			clientv3.Compare(clientv3.CreateRevision(to), "=", 0),
+		).
+		Then(put, clientv3.OpDelete(from)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if txn.Succeeded {
+		report.Moved++
+		return nil
+	}
+
+	// Tell which condition failed
+	exists, err := store.keyExists(ctx, move.To)
+	if err != nil {
+		return err
+	}
+	if exists {
+		store.logger.Warnf("Key %s not renamed: %s already exists", move.From, move.To)
+		report.Conflicts = append(report.Conflicts, move.From)
+		return nil
+	}
+	store.logger.Warnf("Key %s not renamed: it was written during the move", move.From)
+	report.Changed = append(report.Changed, move.From)
+	return nil
+}
+
+// keyExists reports whether a state key exists.
+func (store *EtcdStateStore) keyExists(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	resp, err := store.client.Get(ctx, store.etcdKey(key), clientv3.WithCountOnly())
+	if err != nil {
+		return false, err
+	}
+	return resp.Count > 0, nil
+}
@@ -0,0 +1,621 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	stateutils "github.com/dapr/components-contrib/state/utils"
+	"github.com/dapr/kit/logger"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"nebulagraph/stores/discovery"
+	"nebulagraph/stores/fixtures"
+	"nebulagraph/stores/stateext"
+)
+
+// Request metadata key choosing whether deleting a missing key succeeds
+const ignoreNotFoundMetadataKey = "ignoreNotFound"
+
+// EtcdStateStore is a small state store for etcd, meant for low-volume,
+// strongly consistent configuration-like state next to the bulk stores.
+//
+// It maps the state API onto etcd's key space:
+// 1. One etcd key per state key, under keyPrefixPath
+// 2. The key's mod revision as its ETag, checked with Txn comparisons
+// 3. TTLs as leases granted per write
+// 4. Transactions as a single etcd Txn, limited to maxTxnOps operations
+type EtcdStateStore struct {
+	state.BulkStore
+
+	client         *clientv3.Client
+	config         EtcdConfig
+	requestTimeout time.Duration
+	maxTxnOps      int
+	logger         logger.Logger
+	mu             sync.RWMutex
+	closed         bool
+
+	// Current endpoints, and the watch following them when they are discovered
+	endpoints     []string
+	endpointWatch *endpointWatch
+	// Version of the member that answered the status check during Init
+	serverVersion string
+}
+
+// Compile time check to ensure EtcdStateStore implements state.TransactionalStore
+var _ state.TransactionalStore = (*EtcdStateStore)(nil)
+
+// EtcdConfig holds the configuration for the etcd state store.
+type EtcdConfig struct {
+	Endpoints                 string `json:"endpoints" mapstructure:"endpoints"`                                 // Comma-separated etcd endpoints, e.g. etcd:2379, or srv:, dns: or k8s: discovery (required)
+	KeyPrefixPath             string `json:"keyPrefixPath" mapstructure:"keyPrefixPath"`                         // Path prepended to every key (default: dapr)
+	Username                  string `json:"username" mapstructure:"username"`                                   // User when etcd authentication is enabled
+	Password                  string `json:"password" mapstructure:"password"`                                   // Password of the user
+	DialTimeout               string `json:"dialTimeout" mapstructure:"dialTimeout"`                             // Timeout for connecting during Init (default: 5s)
+	RequestTimeout            string `json:"requestTimeout" mapstructure:"requestTimeout"`                       // Timeout per etcd request (default: 10s)
+	MaxTxnOps                 string `json:"maxTxnOps" mapstructure:"maxTxnOps"`                                 // Largest transaction accepted, matching etcd's --max-txn-ops (default: 128)
+	EndpointDiscoveryInterval string `json:"endpointDiscoveryInterval" mapstructure:"endpointDiscoveryInterval"` // Interval between lookups of discovered endpoints (default: 30s)
+	DevMode                   string `json:"devMode" mapstructure:"devMode"`                                     // Development instance: allows loading fixtures on Init (default: false)
+	Fixtures                  string `json:"fixtures" mapstructure:"fixtures"`                                   // Comma-separated fixture files or directories written on Init in devMode
+}
+
+// NewEtcdStateStore creates a new instance of EtcdStateStore.
+func NewEtcdStateStore(inputLogger logger.Logger) state.Store {
+	// Create default logger if none provided
+	if inputLogger == nil {
+		inputLogger = logger.NewLogger("etcd-state")
+	}
+	store := &EtcdStateStore{
+		logger: inputLogger,
+	}
+	store.BulkStore = state.NewDefaultBulkStore(store)
+	return store
+}
+
+func (store *EtcdStateStore) Init(ctx context.Context, metadata state.Metadata) error {
+	store.logger.Info("Initializing EtcdStateStore...")
+
+	// Parse configuration from metadata
+	configBytes, _ := json.Marshal(metadata.Properties)
+	if err := json.Unmarshal(configBytes, &store.config); err != nil {
+		store.logger.Errorf("Failed to parse config: %v", err)
+		return fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	if strings.TrimSpace(store.config.Endpoints) == "" {
+		return errors.New("endpoints is required")
+	}
+	resolver, err := discovery.Parse(store.config.Endpoints, defaultClientPort)
+	if err != nil {
+		return fmt.Errorf("invalid endpoints: %w", err)
+	}
+
+	// Set defaults
+	if store.config.KeyPrefixPath == "" {
+		store.config.KeyPrefixPath = "dapr"
+	}
+	if store.config.DialTimeout == "" {
+		store.config.DialTimeout = "5s"
+	}
+	if store.config.RequestTimeout == "" {
+		store.config.RequestTimeout = "10s"
+	}
+	if store.config.MaxTxnOps == "" {
+		store.config.MaxTxnOps = "128"
+	}
+	if store.config.EndpointDiscoveryInterval == "" {
+		store.config.EndpointDiscoveryInterval = "30s"
+	}
+	if store.config.DevMode == "" {
+		store.config.DevMode = "false"
+	}
+
+	dialTimeout, err := time.ParseDuration(store.config.DialTimeout)
+	if err != nil || dialTimeout <= 0 {
+		store.logger.Warnf("Invalid dialTimeout: %s, using default", store.config.DialTimeout)
+		dialTimeout = 5 * time.Second
+	}
+	if timeout, err := time.ParseDuration(store.config.RequestTimeout); err == nil && timeout > 0 {
+		store.requestTimeout = timeout
+	} else {
+		store.logger.Warnf("Invalid requestTimeout: %s, using default", store.config.RequestTimeout)
+		store.requestTimeout = 10 * time.Second
+	}
+	if maxTxnOps, err := strconv.Atoi(store.config.MaxTxnOps); err == nil && maxTxnOps > 0 {
+		store.maxTxnOps = maxTxnOps
+	} else {
+		store.logger.Warnf("Invalid maxTxnOps: %s, using default", store.config.MaxTxnOps)
+		store.maxTxnOps = 128
+	}
+
+	endpoints, err := resolver.Resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to resolve endpoints %s: %w", store.config.Endpoints, err)
+	}
+
+	store.logger.Infof("etcd config: endpoints=%v, keyPrefixPath=%s, maxTxnOps=%d",
+		endpoints, store.config.KeyPrefixPath, store.maxTxnOps)
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: dialTimeout,
+		Username:    store.config.Username,
+		Password:    store.config.Password,
+		Context:     context.WithoutCancel(ctx),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to etcd at %s: %w", strings.Join(endpoints, ","), err)
+	}
+
+	// The client connects lazily, so ask for a member's status to fail Init
+	// on an unreachable cluster instead of the first request
+	version, err := checkEndpoints(ctx, client, endpoints, dialTimeout)
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("failed to connect to etcd at %s: %w", strings.Join(endpoints, ","), err)
+	}
+	store.client = client
+	store.serverVersion = version
+	store.endpoints = endpoints
+
+	if !discovery.IsStatic(resolver) {
+		store.watchEndpoints(resolver, endpoints)
+	}
+
+	if store.config.Fixtures != "" {
+		if err := fixtures.Seed(ctx, store, store.config.Fixtures, store.config.DevMode == "true", store.logger); err != nil {
+			return err
+		}
+	}
+
+	store.logger.Info("EtcdStateStore initialized successfully")
+	return nil
+}
+
+func (store *EtcdStateStore) GetComponentMetadata() map[string]string {
+	return map[string]string{
+		"type":    "state",
+		"version": "v1",
+		"author":  "etcd Authors",
+		"url":     "https://etcd.io/docs/",
+	}
+}
+
+func (store *EtcdStateStore) Features() []state.Feature {
+	return []state.Feature{
+		state.FeatureETag,
+		state.FeatureTransactional,
+	}
+}
+
+func (store *EtcdStateStore) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.ready(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	// Reads are linearizable unless the caller accepts a local, possibly stale read
+	var opts []clientv3.OpOption
+	if req.Options.Consistency == state.Eventual {
+		opts = append(opts, clientv3.WithSerializable())
+	}
+
+	resp, err := store.client.Get(ctx, store.etcdKey(req.Key), opts...)
+	if err != nil {
+		store.logger.Errorf("Failed to get key %s: %v", req.Key, err)
+		return nil, fmt.Errorf("failed to get key %s: %w", req.Key, err)
+	}
+	if len(resp.Kvs) == 0 {
+		return &state.GetResponse{}, nil
+	}
+
+	etag := strconv.FormatInt(resp.Kvs[0].ModRevision, 10)
+	return &state.GetResponse{
+		Data: resp.Kvs[0].Value,
+		ETag: &etag,
+	}, nil
+}
+
+// BulkGet reads keys one by one, or with keysOnly request metadata returns
+// their ETags without values: up to maxTxnOps keys are read per Txn of
+// keys-only range requests, so each group is one consistent revision.
+func (store *EtcdStateStore) BulkGet(ctx context.Context, req []state.GetRequest, opts state.BulkGetOpts) ([]state.BulkGetResponse, error) {
+	if !stateext.KeysOnly(requestsMetadata(req)...) {
+		return store.BulkStore.BulkGet(ctx, req, opts)
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.ready(); err != nil {
+		return nil, err
+	}
+
+	responses := make([]state.BulkGetResponse, len(req))
+	for start := 0; start < len(req); start += store.maxTxnOps {
+		end := min(start+store.maxTxnOps, len(req))
+		ops := make([]clientv3.Op, 0, end-start)
+		for _, getReq := range req[start:end] {
+			// A Txn is served serializably only when all of its reads are
+			getOpts := []clientv3.OpOption{clientv3.WithKeysOnly()}
+			if getReq.Options.Consistency == state.Eventual {
+				getOpts = append(getOpts, clientv3.WithSerializable())
+			}
+			ops = append(ops, clientv3.OpGet(store.etcdKey(getReq.Key), getOpts...))
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+		resp, err := store.client.Txn(reqCtx).Then(ops...).Commit()
+		cancel()
+		if err != nil {
+			store.logger.Errorf("Failed to read ETags of %d keys: %v", len(ops), err)
+			return nil, fmt.Errorf("bulk get failed: %w", err)
+		}
+
+		for i, op := range resp.Responses {
+			responses[start+i] = state.BulkGetResponse{Key: req[start+i].Key}
+			if kvs := op.GetResponseRange().Kvs; len(kvs) > 0 {
+				etag := strconv.FormatInt(kvs[0].ModRevision, 10)
+				responses[start+i].ETag = &etag
+			}
+		}
+	}
+	return responses, nil
+}
+
+// requestsMetadata collects the metadata of every request of a bulk operation.
+func requestsMetadata(requests []state.GetRequest) []map[string]string {
+	metadata := make([]map[string]string, len(requests))
+	for i := range requests {
+		metadata[i] = requests[i].Metadata
+	}
+	return metadata
+}
+
+func (store *EtcdStateStore) Set(ctx context.Context, req *state.SetRequest) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.ready(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	put, cmp, err := store.putOp(ctx, *req, make(map[int64]clientv3.LeaseID))
+	if err != nil {
+		return err
+	}
+
+	if cmp == nil {
+		if _, err := store.client.Do(ctx, put); err != nil {
+			store.logger.Errorf("Failed to set key %s: %v", req.Key, err)
+			return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+		}
+		return nil
+	}
+
+	resp, err := store.client.Txn(ctx).If(*cmp).Then(put).Commit()
+	if err != nil {
+		store.logger.Errorf("Failed to set key %s: %v", req.Key, err)
+		return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+	}
+	if !resp.Succeeded {
+		return stateext.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch for key %s", req.Key))
+	}
+	return nil
+}
+
+func (store *EtcdStateStore) Delete(ctx context.Context, req *state.DeleteRequest) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.ready(); err != nil {
+		return err
+	}
+
+	// Same contract as the ScyllaDB store: a missing key is deleted successfully
+	// unless ignoreNotFound=false, and a mismatched ETag of an existing key fails
+	ignoreNotFound := true
+	if value, ok := req.Metadata[ignoreNotFoundMetadataKey]; ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			store.logger.Warnf("Invalid %s request metadata: %s, using default", ignoreNotFoundMetadataKey, value)
+		} else {
+			ignoreNotFound = parsed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	key := store.etcdKey(req.Key)
+	if !req.HasETag() {
+		resp, err := store.client.Delete(ctx, key)
+		if err != nil {
+			store.logger.Errorf("Failed to delete key %s: %v", req.Key, err)
+			return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+		}
+		if resp.Deleted == 0 && !ignoreNotFound {
+			return fmt.Errorf("key %s not found", req.Key)
+		}
+		return nil
+	}
+
+	revision, err := parseETag(req.Key, *req.ETag)
+	if err != nil {
+		return err
+	}
+
+	// The Else branch tells a missing key apart from a mismatched ETag
+	resp, err := store.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpDelete(key)).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		store.logger.Errorf("Failed to delete key %s: %v", req.Key, err)
+		return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+	}
+	if resp.Succeeded {
+		return nil
+	}
+
+	if resp.Responses[0].GetResponseRange().GetCount() == 0 {
+		if ignoreNotFound {
+			return nil
+		}
+		return fmt.Errorf("key %s not found", req.Key)
+	}
+	return stateext.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch for key %s", req.Key))
+}
+
+// Multi applies the operations as one etcd Txn: every ETag and first-write
+// condition is compared first, and either all operations apply or none do.
+func (store *EtcdStateStore) Multi(ctx context.Context, request *state.TransactionalStateRequest) error {
+	if request == nil || len(request.Operations) == 0 {
+		return nil
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.ready(); err != nil {
+		return err
+	}
+	if len(request.Operations) > store.maxTxnOps {
+		return fmt.Errorf("transaction has %d operations, more than maxTxnOps (%d)", len(request.Operations), store.maxTxnOps)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, store.requestTimeout)
+	defer cancel()
+
+	var cmps []clientv3.Cmp
+	ops := make([]clientv3.Op, 0, len(request.Operations))
+	leases := make(map[int64]clientv3.LeaseID)
+	for _, op := range request.Operations {
+		switch req := op.(type) {
+		case state.SetRequest:
+			put, cmp, err := store.putOp(ctx, req, leases)
+			if err != nil {
+				return err
+			}
+			if cmp != nil {
+				cmps = append(cmps, *cmp)
+			}
+			ops = append(ops, put)
+		case state.DeleteRequest:
+			key := store.etcdKey(req.Key)
+			if req.HasETag() {
+				revision, err := parseETag(req.Key, *req.ETag)
+				if err != nil {
+					return err
+				}
+				cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", revision))
+			}
+			ops = append(ops, clientv3.OpDelete(key))
+		default:
+			return fmt.Errorf("unsupported operation type %T", op)
+		}
+	}
+
+	resp, err := store.client.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		store.logger.Errorf("Failed to execute transaction: %v", err)
+		return fmt.Errorf("failed to execute transaction: %w", err)
+	}
+	if !resp.Succeeded {
+		return stateext.NewETagError(state.ETagMismatch, errors.New("etag mismatch in transaction"))
+	}
+	return nil
+}
+
+// putOp builds the Put of a set request and the condition it is written
+// under, if any. A TTL is attached as a lease; leases are granted once per TTL
+// value and shared through leases.
+func (store *EtcdStateStore) putOp(ctx context.Context, req state.SetRequest, leases map[int64]clientv3.LeaseID) (clientv3.Op, *clientv3.Cmp, error) {
+	if req.Key == "" {
+		return clientv3.Op{}, nil, errors.New("key cannot be empty")
+	}
+	key := store.etcdKey(req.Key)
+
+	value, err := valueBytes(req.Value)
+	if err != nil {
+		return clientv3.Op{}, nil, fmt.Errorf("failed to convert value for key %s: %w", req.Key, err)
+	}
+
+	var cmp *clientv3.Cmp
+	switch {
+	case req.HasETag():
+		revision, err := parseETag(req.Key, *req.ETag)
+		if err != nil {
+			return clientv3.Op{}, nil, err
+		}
+		c := clientv3.Compare(clientv3.ModRevision(key), "=", revision)
+		cmp = &c
+	case req.Options.Concurrency == state.FirstWrite:
+		c := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+		cmp = &c
+	}
+
+	// A Put without a lease detaches the key from an earlier one, clearing its TTL
+	ttl, err := stateutils.ParseTTL(req.Metadata)
+	if err != nil {
+		return clientv3.Op{}, nil, fmt.Errorf("invalid ttl for key %s: %w", req.Key, err)
+	}
+	if ttl == nil || *ttl <= 0 {
+		return clientv3.OpPut(key, string(value)), cmp, nil
+	}
+
+	seconds := int64(*ttl)
+	lease, ok := leases[seconds]
+	if !ok {
+		granted, err := store.client.Grant(ctx, seconds)
+		if err != nil {
+			return clientv3.Op{}, nil, fmt.Errorf("failed to grant a %ds lease for key %s: %w", seconds, req.Key, err)
+		}
+		lease = granted.ID
+		leases[seconds] = lease
+	}
+	return clientv3.OpPut(key, string(value), clientv3.WithLease(lease)), cmp, nil
+}
+
+// Diagnostics reports the store's configuration for the diagnostics endpoint.
+func (store *EtcdStateStore) Diagnostics() map[string]any {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return map[string]any{
+		"closed":        store.closed,
+		"endpoints":     store.config.Endpoints,
+		"resolved":      store.endpoints,
+		"keyPrefixPath": store.config.KeyPrefixPath,
+		"maxTxnOps":     store.maxTxnOps,
+		"authenticated": store.config.Username != "",
+	}
+}
+
+// Capabilities returns the capability matrix logged once Init succeeded and
+// served for support bundles.
+func (store *EtcdStateStore) Capabilities() map[string]any {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	version := store.serverVersion
+	if version == "" {
+		version = "unknown"
+	}
+	return map[string]any{
+		"backend": map[string]any{
+			"dialect": "etcd",
+			"version": version,
+		},
+		"features": map[string]any{
+			"ttl":          true,
+			"lwt":          true, // ETags are Txn comparisons
+			"transactions": true,
+			"queryAPI":     false,
+			"cache":        []string{},
+			"encryption": map[string]any{
+				"tls": false,
+			},
+		},
+		"pool": map[string]any{
+			"endpoints": len(store.endpoints),
+		},
+		"schema": map[string]any{
+			"keyPrefixPath": store.config.KeyPrefixPath,
+			"status":        "schemaless",
+		},
+	}
+}
+
+func (store *EtcdStateStore) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.closed {
+		return nil
+	}
+	store.closed = true
+	if store.endpointWatch != nil {
+		// The watch takes the lock to record changes
+		watch := store.endpointWatch
+		store.mu.Unlock()
+		watch.stop()
+		store.mu.Lock()
+	}
+	if store.client != nil {
+		if err := store.client.Close(); err != nil {
+			store.logger.Warnf("Failed to close etcd client: %v", err)
+		}
+	}
+	store.logger.Info("EtcdStateStore closed successfully")
+	return nil
+}
+
+// checkEndpoints succeeds as soon as one endpoint reports its status, and
+// returns the version of that member.
+func checkEndpoints(ctx context.Context, client *clientv3.Client, endpoints []string, timeout time.Duration) (string, error) {
+	var errs []error
+	for _, endpoint := range endpoints {
+		statusCtx, cancel := context.WithTimeout(ctx, timeout)
+		status, err := client.Status(statusCtx, endpoint)
+		cancel()
+		if err == nil {
+			return status.Version, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+	return "", errors.Join(errs...)
+}
+
+// ready reports why the store cannot serve requests, if it cannot.
+func (store *EtcdStateStore) ready() error {
+	if store.closed {
+		return errors.New("store is closed")
+	}
+	if store.client == nil {
+		return errors.New("client not initialized")
+	}
+	return nil
+}
+
+func (store *EtcdStateStore) etcdKey(key string) string {
+	return store.config.KeyPrefixPath + "/" + key
+}
+
+// parseETag converts an ETag back into the mod revision it was made from.
+func parseETag(key, etag string) (int64, error) {
+	revision, err := strconv.ParseInt(etag, 10, 64)
+	if err != nil {
+		return 0, stateext.NewETagError(state.ETagInvalid, fmt.Errorf("invalid etag %q for key %s", etag, key))
+	}
+	return revision, nil
+}
+
+// valueBytes converts a state value to the bytes stored under the key.
+func valueBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return []byte{}, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return json.Marshal(value)
+}
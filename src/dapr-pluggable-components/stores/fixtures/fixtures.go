@@ -0,0 +1,285 @@
+// Package fixtures loads declarative state fixtures, so integration tests and
+// local development can seed every store with the same known keys.
+//
+// A fixture file is YAML or JSON:
+//
+//	keys:
+//	  - key: customer||1
+//	    value: {name: Ann, tier: gold}
+//	  - key: order||1
+//	    value: {total: 25.5, status: paid}
+//	    ttlInSeconds: 3600               # optional
+//	    contentType: application/json    # optional
+//	    metadata: {partitionKey: eu}     # optional request metadata
+//	relationships:
+//	  - from: order||1
+//	    field: customer                  # dot-separated path in from's value
+//	    to: customer||1
+//
+// A relationship stores the key named by to in a field of from's JSON value,
+// and makes sure to is written before from, so whatever reads from can follow
+// the reference. Keys must be unique across all files loaded together.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// Content type given to values that are not strings
+const jsonContentType = "application/json"
+
+// Key is one key of a fixture file.
+type Key struct {
+	Key          string            `yaml:"key" json:"key"`
+	Value        any               `yaml:"value" json:"value"`
+	TTLInSeconds int               `yaml:"ttlInSeconds" json:"ttlInSeconds"`
+	ContentType  string            `yaml:"contentType" json:"contentType"`
+	Metadata     map[string]string `yaml:"metadata" json:"metadata"`
+}
+
+// Relationship stores the key To in the field Field of the value of From.
+type Relationship struct {
+	From  string `yaml:"from" json:"from"`
+	Field string `yaml:"field" json:"field"`
+	To    string `yaml:"to" json:"to"`
+}
+
+// File is the content of one fixture file.
+type File struct {
+	Keys          []Key          `yaml:"keys" json:"keys"`
+	Relationships []Relationship `yaml:"relationships" json:"relationships"`
+}
+
+// Fixtures are the keys of one or more files, with their relationships
+// applied, in the order they are written.
+type Fixtures struct {
+	Files []string
+	Keys  []Key
+}
+
+// Load reads fixture files; a directory stands for the .yaml, .yml and .json
+// files it contains, in name order.
+func Load(paths ...string) (*Fixtures, error) {
+	var files []string
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no fixture files")
+	}
+
+	fixtures := &Fixtures{Files: files}
+	var relationships []Relationship
+	index := make(map[string]int)
+	for _, name := range files {
+		file, err := parseFile(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range file.Keys {
+			if key.Key == "" {
+				return nil, fmt.Errorf("%s: key without a name", name)
+			}
+			if _, ok := index[key.Key]; ok {
+				return nil, fmt.Errorf("%s: key %s is defined twice", name, key.Key)
+			}
+			index[key.Key] = len(fixtures.Keys)
+			fixtures.Keys = append(fixtures.Keys, key)
+		}
+		relationships = append(relationships, file.Relationships...)
+	}
+
+	if err := fixtures.relate(relationships, index); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// parseFile decodes one file; JSON is read by the YAML decoder too.
+func parseFile(name string) (File, error) {
+	var file File
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return file, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return file, fmt.Errorf("%s: %w", name, err)
+	}
+	return file, nil
+}
+
+// relate sets the fields named by relationships and orders the keys so every
+// key follows the keys it references, except for one reference of each cycle.
+func (f *Fixtures) relate(relationships []Relationship, index map[string]int) error {
+	references := make(map[int][]int)
+	for _, rel := range relationships {
+		from, ok := index[rel.From]
+		if !ok {
+			return fmt.Errorf("relationship from unknown key %s", rel.From)
+		}
+		to, ok := index[rel.To]
+		if !ok {
+			return fmt.Errorf("relationship of %s to unknown key %s", rel.From, rel.To)
+		}
+		if rel.Field == "" {
+			return fmt.Errorf("relationship of %s to %s without a field", rel.From, rel.To)
+		}
+		value, err := SetField(f.Keys[from].Value, strings.Split(rel.Field, "."), rel.To)
+		if err != nil {
+			return fmt.Errorf("relationship of %s to %s: %w", rel.From, rel.To, err)
+		}
+		f.Keys[from].Value = value
+		references[from] = append(references[from], to)
+	}
+
+	// Depth-first, visiting references before the key itself
+	ordered := make([]Key, 0, len(f.Keys))
+	visited := make([]bool, len(f.Keys))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, to := range references[i] {
+			visit(to)
+		}
+		ordered = append(ordered, f.Keys[i])
+	}
+	for i := range f.Keys {
+		visit(i)
+	}
+	f.Keys = ordered
+	return nil
+}
+
+// SetField returns value with the field at path set to key, creating objects
+// along the path. A missing value becomes an object.
+func SetField(value any, path []string, key string) (any, error) {
+	if len(path) == 0 {
+		return key, nil
+	}
+	if value == nil {
+		value = map[string]any{}
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("field %s is inside a %T, not an object", path[0], value)
+	}
+	child, err := SetField(object[path[0]], path[1:], key)
+	if err != nil {
+		return nil, err
+	}
+	object[path[0]] = child
+	return object, nil
+}
+
+// Requests returns a Set request per key, in write order. Values that are not
+// strings are sent as JSON.
+func (f *Fixtures) Requests() ([]state.SetRequest, error) {
+	requests := make([]state.SetRequest, 0, len(f.Keys))
+	for _, key := range f.Keys {
+		request := state.SetRequest{Key: key.Key, Metadata: map[string]string{}}
+		for name, value := range key.Metadata {
+			request.Metadata[name] = value
+		}
+		if key.TTLInSeconds > 0 {
+			request.Metadata["ttlInSeconds"] = strconv.Itoa(key.TTLInSeconds)
+		}
+
+		contentType := key.ContentType
+		switch value := key.Value.(type) {
+		case string:
+			request.Value = value
+		default:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("value of key %s: %w", key.Key, err)
+			}
+			request.Value = data
+			if contentType == "" {
+				contentType = jsonContentType
+			}
+		}
+		if contentType != "" {
+			request.ContentType = &contentType
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// Apply writes every key to store in order, overwriting existing values, and
+// returns the number of keys written.
+func (f *Fixtures) Apply(ctx context.Context, store state.Store) (int, error) {
+	requests, err := f.Requests()
+	if err != nil {
+		return 0, err
+	}
+	for i := range requests {
+		if err := store.Set(ctx, &requests[i]); err != nil {
+			return i, fmt.Errorf("failed to write fixture key %s: %w", requests[i].Key, err)
+		}
+	}
+	return len(requests), nil
+}
+
+// Summary describes the loaded fixtures for logs.
+func (f *Fixtures) Summary() string {
+	return fmt.Sprintf("%d keys from %s", len(f.Keys), strings.Join(f.Files, ", "))
+}
+
+// Seed loads the comma-separated fixture paths into store during Init. As
+// fixtures overwrite keys, they are only loaded when devMode is set.
+func Seed(ctx context.Context, store state.Store, paths string, devMode bool, log logger.Logger) error {
+	if !devMode {
+		log.Warnf("fixtures ignored: they are only loaded with devMode set to true")
+		return nil
+	}
+	loaded, err := Load(strings.Split(paths, ",")...)
+	if err != nil {
+		return fmt.Errorf("invalid fixtures: %w", err)
+	}
+	if _, err := loaded.Apply(ctx, store); err != nil {
+		return err
+	}
+	log.Infof("Loaded fixtures: %s", loaded.Summary())
+	return nil
+}
@@ -0,0 +1,301 @@
+package scylladb
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// Actor pinning strategies
+const (
+	actorPinningNone       = "none"
+	actorPinningRing       = "ring"
+	actorPinningRendezvous = "rendezvous"
+)
+
+// Points per host on the consistent hash ring
+const actorPinningVirtualNodes = 128
+
+// Separator of the parts of Dapr actor state keys:
+// <app-id>||<actor-type>||<actor-id>||<key>
+const actorKeySeparator = "||"
+
+type actorPinKey struct{}
+
+// actorPinner sends all single-key operations of an actor to the same
+// coordinator, chosen by hashing the actor type and ID over the hosts that are
+// up. Token-aware routing spreads an actor's keys over the replicas of each
+// key, so after a reactivation every coordinator has to warm up; pinning keeps
+// an actor's reads and writes on one node, which improves its cache hit rate.
+// The remaining hosts of the wrapped policy follow as fallbacks.
+//
+// Each session gets its own pinnedPolicy from wrap, which follows the host
+// up/down events of that session, so a session being built for a refresh does
+// not move the actors of the one serving requests.
+type actorPinner struct {
+	strategy string
+
+	mu     sync.RWMutex
+	active *pinTargets      // targets of the session in use (see activate)
+	pinned map[string]int64 // host address -> pinned operations
+}
+
+// pinTargets holds the hosts one session can pin actors to.
+type pinTargets struct {
+	hosts map[string]*gocql.HostInfo // host ID -> host, up hosts only
+	ring  []ringPoint                // sorted by hash, ring strategy only
+}
+
+// pinnedPolicy is the host selection policy of one session.
+type pinnedPolicy struct {
+	gocql.HostSelectionPolicy
+
+	pinner  *actorPinner
+	targets *pinTargets
+}
+
+type ringPoint struct {
+	hash uint64
+	host *gocql.HostInfo
+}
+
+// pinnedHost is the host selected for a pinned operation. Without a token
+// the driver picks the least busy shard connection.
+type pinnedHost struct {
+	host *gocql.HostInfo
+}
+
+func (h pinnedHost) Info() *gocql.HostInfo { return h.host }
+func (h pinnedHost) Token() gocql.Token    { return nil }
+func (h pinnedHost) Mark(error)            {}
+
+func newActorPinner(strategy string) *actorPinner {
+	return &actorPinner{
+		strategy: strategy,
+		active:   newPinTargets(),
+		pinned:   make(map[string]int64),
+	}
+}
+
+func newPinTargets() *pinTargets {
+	return &pinTargets{hosts: make(map[string]*gocql.HostInfo)}
+}
+
+// wrap returns the policy of a new session, delegating host selection to
+// policy. A fresh policy is needed per session, so this is called before each
+// CreateSession.
+func (p *actorPinner) wrap(policy gocql.HostSelectionPolicy) gocql.HostSelectionPolicy {
+	return &pinnedPolicy{HostSelectionPolicy: policy, pinner: p, targets: newPinTargets()}
+}
+
+// activate makes the targets of a session's policy the ones diagnostics report.
+func (p *actorPinner) activate(policy *pinnedPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active = policy.targets
+}
+
+// hostSelectionPolicy builds the host selection policy of a new session.
+func (store *ScyllaStateStore) hostSelectionPolicy() gocql.HostSelectionPolicy {
+	policy := gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	if store.actorPinner != nil {
+		policy = store.actorPinner.wrap(policy)
+	}
+	return store.hosts.wrap(policy)
+}
+
+// activateHostSelectionPolicy makes the hosts seen by a session's policy,
+// built by hostSelectionPolicy, the ones the store reports. It is called
+// once the session is the store's.
+func (store *ScyllaStateStore) activateHostSelectionPolicy(policy gocql.HostSelectionPolicy) {
+	tracked, ok := policy.(*trackedPolicy)
+	if !ok {
+		return
+	}
+	store.hosts.activate(tracked)
+	if pinned, ok := tracked.HostSelectionPolicy.(*pinnedPolicy); ok {
+		store.actorPinner.activate(pinned)
+	}
+}
+
+// actorID returns the actor type and ID of a Dapr actor state key, or "" for
+// other keys.
+func actorID(key string) string {
+	parts := strings.SplitN(key, actorKeySeparator, 4)
+	if len(parts) < 4 || parts[1] == "" || parts[2] == "" {
+		return ""
+	}
+	return parts[1] + actorKeySeparator + parts[2]
+}
+
+// pinActor marks ctx so the operation on key is routed to its actor's host.
+func (store *ScyllaStateStore) pinActor(ctx context.Context, key string) context.Context {
+	if store.actorPinner == nil {
+		return ctx
+	}
+	if actor := actorID(key); actor != "" {
+		return context.WithValue(ctx, actorPinKey{}, actor)
+	}
+	return ctx
+}
+
+func hashString(values ...string) uint64 {
+	hash := fnv.New64a()
+	for _, value := range values {
+		hash.Write([]byte(value))
+		hash.Write([]byte{0})
+	}
+	return hash.Sum64()
+}
+
+func (p *pinnedPolicy) setHost(host *gocql.HostInfo, up bool) {
+	p.pinner.mu.Lock()
+	defer p.pinner.mu.Unlock()
+
+	targets := p.targets
+	if up {
+		targets.hosts[host.HostID()] = host
+	} else {
+		delete(targets.hosts, host.HostID())
+	}
+	if p.pinner.strategy != actorPinningRing {
+		return
+	}
+
+	// Rebuild the ring; only the points of the changed host move
+	targets.ring = targets.ring[:0]
+	for id, h := range targets.hosts {
+		for i := 0; i < actorPinningVirtualNodes; i++ {
+			targets.ring = append(targets.ring, ringPoint{hash: hashString(id, strconv.Itoa(i)), host: h})
+		}
+	}
+	sort.Slice(targets.ring, func(i, j int) bool { return targets.ring[i].hash < targets.ring[j].hash })
+}
+
+// lookup returns the host an actor is pinned to, or nil when no host is up.
+func (p *pinnedPolicy) lookup(actor string) *gocql.HostInfo {
+	p.pinner.mu.RLock()
+	defer p.pinner.mu.RUnlock()
+
+	targets := p.targets
+	switch p.pinner.strategy {
+	case actorPinningRing:
+		if len(targets.ring) == 0 {
+			return nil
+		}
+		hash := hashString(actor)
+		i := sort.Search(len(targets.ring), func(i int) bool { return targets.ring[i].hash >= hash })
+		if i == len(targets.ring) {
+			i = 0
+		}
+		return targets.ring[i].host
+	default:
+		// Rendezvous hashing: the host with the highest weight for the actor wins
+		var best *gocql.HostInfo
+		var bestWeight uint64
+		for id, host := range targets.hosts {
+			if weight := hashString(actor, id); best == nil || weight > bestWeight {
+				best, bestWeight = host, weight
+			}
+		}
+		return best
+	}
+}
+
+// Pick tries the actor's host first for pinned operations, then the hosts of
+// the wrapped policy.
+func (p *pinnedPolicy) Pick(qry gocql.ExecutableQuery) gocql.NextHost {
+	next := p.HostSelectionPolicy.Pick(qry)
+
+	withContext, ok := qry.(interface{ Context() context.Context })
+	if !ok || withContext.Context() == nil {
+		return next
+	}
+	actor, _ := withContext.Context().Value(actorPinKey{}).(string)
+	if actor == "" {
+		return next
+	}
+	host := p.lookup(actor)
+	if host == nil {
+		return next
+	}
+
+	p.pinner.mu.Lock()
+	p.pinner.pinned[host.ConnectAddressAndPort()]++
+	p.pinner.mu.Unlock()
+
+	tried := false
+	return func() gocql.SelectedHost {
+		if !tried {
+			tried = true
+			return pinnedHost{host: host}
+		}
+		for {
+			selected := next()
+			if selected == nil || selected.Info().HostID() != host.HostID() {
+				return selected
+			}
+		}
+	}
+}
+
+// AddHost adds a host joining the cluster to the pinning targets.
+func (p *pinnedPolicy) AddHost(host *gocql.HostInfo) {
+	p.setHost(host, host.IsUp())
+	p.HostSelectionPolicy.AddHost(host)
+}
+
+// RemoveHost removes a host leaving the cluster from the pinning targets.
+func (p *pinnedPolicy) RemoveHost(host *gocql.HostInfo) {
+	p.setHost(host, false)
+	p.HostSelectionPolicy.RemoveHost(host)
+}
+
+// HostUp adds a reachable host to the pinning targets.
+func (p *pinnedPolicy) HostUp(host *gocql.HostInfo) {
+	p.setHost(host, true)
+	p.HostSelectionPolicy.HostUp(host)
+}
+
+// HostDown moves the actors of an unreachable host to the remaining hosts.
+func (p *pinnedPolicy) HostDown(host *gocql.HostInfo) {
+	p.setHost(host, false)
+	p.HostSelectionPolicy.HostDown(host)
+}
+
+// diagnostics reports pinned operations per host and the distribution skew:
+// the busiest host's share relative to an even spread (1 is perfectly even).
+func (p *actorPinner) diagnostics() map[string]any {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	pinned := make(map[string]int64, len(p.pinned))
+	var total, busiest int64
+	for address, count := range p.pinned {
+		pinned[address] = count
+		total += count
+		busiest = max(busiest, count)
+	}
+
+	hosts := len(p.active.hosts)
+	if hosts == 0 {
+		hosts = len(pinned)
+	}
+	skew := 0.0
+	if total > 0 && hosts > 0 {
+		skew = math.Round(float64(busiest)*float64(hosts)/float64(total)*100) / 100
+	}
+	return map[string]any{
+		"strategy":  p.strategy,
+		"upHosts":   len(p.active.hosts),
+		"pinned":    pinned,
+		"skew":      skew,
+		"pinnedOps": total,
+	}
+}
@@ -0,0 +1,231 @@
+package scylladb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	// Statements and estimated bytes per UNLOGGED batch of a token range; the
+	// bytes stay under Cassandra's default 50 KB batch_size_fail_threshold
+	writeBatchMaxStatements = 100
+	writeBatchMaxBytes      = 40 * 1024
+)
+
+// partitionStatement is one mutation of a bulk request.
+type partitionStatement struct {
+	query      string
+	args       []interface{}
+	storageKey string
+}
+
+// size estimates the bytes the statement adds to a batch.
+func (stmt partitionStatement) size() int {
+	size := mutationOverhead
+	for _, arg := range stmt.args {
+		switch value := arg.(type) {
+		case string:
+			size += len(value)
+		case []byte:
+			size += len(value)
+		}
+	}
+	return size
+}
+
+// lastMutations returns the statements without those superseded by a later
+// mutation of the same key, keeping their order. Statements in one batch share
+// a write timestamp and would otherwise resolve in an unspecified order.
+func lastMutations(stmts []partitionStatement) []partitionStatement {
+	lastIndex := make(map[string]int, len(stmts))
+	for i, stmt := range stmts {
+		lastIndex[stmt.storageKey] = i
+	}
+	last := make([]partitionStatement, 0, len(lastIndex))
+	for i, stmt := range stmts {
+		if lastIndex[stmt.storageKey] == i {
+			last = append(last, stmt)
+		}
+	}
+	return last
+}
+
+// groupByTokenRange groups the unconditional mutations of a bulk request for
+// UNLOGGED batches. Every key is its own partition, so a batch holds the keys
+// whose tokens fall in one range of the token ring: they share their
+// replicas, and the driver routes the batch to one of them, so the batch is
+// applied without the coordinator fanning out to other nodes. Batches are
+// capped at writeBatchMaxStatements and writeBatchMaxBytes. Without a token
+// ring, e.g. when the session is injected, each key is a group of its own.
+// Only the last mutation per key is kept.
+func (store *ScyllaStateStore) groupByTokenRange(stmts []partitionStatement) [][]partitionStatement {
+	var ring *tokenRing
+	if store.hosts != nil {
+		ring = store.hosts.tokenRing()
+	}
+	return groupByTokenRange(stmts, ring)
+}
+
+func groupByTokenRange(stmts []partitionStatement, ring *tokenRing) [][]partitionStatement {
+	stmts = lastMutations(stmts)
+	if ring == nil {
+		groups := make([][]partitionStatement, len(stmts))
+		for i := range stmts {
+			groups[i] = stmts[i : i+1 : i+1]
+		}
+		return groups
+	}
+
+	type rangeStatement struct {
+		token int64
+		stmt  partitionStatement
+	}
+	ranges := make(map[int][]rangeStatement)
+	var order []int
+	for _, stmt := range stmts {
+		token := murmur3Token(stringToBytes(stmt.storageKey))
+		i := ring.rangeOf(token)
+		if _, exists := ranges[i]; !exists {
+			order = append(order, i)
+		}
+		ranges[i] = append(ranges[i], rangeStatement{token: token, stmt: stmt})
+	}
+
+	var groups [][]partitionStatement
+	for _, i := range order {
+		inRange := ranges[i]
+		slices.SortStableFunc(inRange, func(a, b rangeStatement) int { return cmp.Compare(a.token, b.token) })
+		var group []partitionStatement
+		size := 0
+		for _, entry := range inRange {
+			stmtSize := entry.stmt.size()
+			if len(group) > 0 && (len(group) == writeBatchMaxStatements || size+stmtSize > writeBatchMaxBytes) {
+				groups = append(groups, group)
+				group, size = nil, 0
+			}
+			group = append(group, entry.stmt)
+			size += stmtSize
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// groupByPartition groups bulk mutations by the routing key of their prepared
+// statement, for conditional batches, which must touch a single partition.
+// Only the last mutation per key is kept.
+func (store *ScyllaStateStore) groupByPartition(stmts []partitionStatement) [][]partitionStatement {
+	groups := make(map[string][]partitionStatement)
+	order := make([]string, 0, len(stmts))
+	for _, stmt := range lastMutations(stmts) {
+		partition := stmt.storageKey
+		if routingKey, err := store.session.Query(stmt.query, stmt.args...).GetRoutingKey(); err == nil && routingKey != nil {
+			partition = string(routingKey)
+		}
+
+		if _, exists := groups[partition]; !exists {
+			order = append(order, partition)
+		}
+		groups[partition] = append(groups[partition], stmt)
+	}
+
+	result := make([][]partitionStatement, 0, len(order))
+	for _, partition := range order {
+		result = append(result, groups[partition])
+	}
+	return result
+}
+
+// executePartitionGroups runs one batch per group, executing up to
+// parallelism groups concurrently. Single-statement groups are executed as
+// plain queries to avoid batch overhead. The first error is returned after all
+// in-flight work completes.
+func (store *ScyllaStateStore) executePartitionGroups(ctx context.Context, groups [][]partitionStatement, parallelism int, operation string) error {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, parallelism)
+
+	for _, group := range groups {
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(group []partitionStatement) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := store.executePartitionGroup(ctx, group, operation); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}(group)
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+func (store *ScyllaStateStore) executePartitionGroup(ctx context.Context, group []partitionStatement, operation string) error {
+	// Hooks see each statement once, not once per retry
+	if len(statementHooks) > 0 {
+		hooked := make([]partitionStatement, len(group))
+		for i, stmt := range group {
+			query, args, err := runStatementHooks(ctx, operation, stmt.query, stmt.args)
+			if err != nil {
+				return err
+			}
+			hooked[i] = partitionStatement{query: query, args: args, storageKey: stmt.storageKey}
+		}
+		group = hooked
+	}
+
+	exec := func() error {
+		if len(group) == 1 {
+			return store.session.Query(group[0].query, group[0].args...).WithContext(ctx).Exec()
+		}
+
+		// The statements of a group share their replicas (see groupByTokenRange)
+		batch := store.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		for _, stmt := range group {
+			batch.Query(stmt.query, stmt.args...)
+		}
+		return store.session.ExecuteBatch(batch)
+	}
+
+	// Execute with retry logic
+	if err := store.withRetry(ctx, operation+" partition batch", exec); err != nil {
+		store.logger.Errorf("Failed to execute %s partition batch: %v", operation, err)
+		return fmt.Errorf("%s partition batch failed: %w", operation, err)
+	}
+	return nil
+}
+
+// bulkParallelism resolves the number of partitions executed concurrently,
+// preferring the per-request option over the component configuration, and
+// reduced while the backend is overloaded.
+func (store *ScyllaStateStore) bulkParallelism(requested int) int {
+	if requested <= 0 {
+		requested = store.bulkConcurrency
+	}
+	return store.overloadScaled(requested, 1)
+}
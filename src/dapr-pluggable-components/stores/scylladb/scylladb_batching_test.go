@@ -0,0 +1,129 @@
+package scylladb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testRing returns a ring of hosts with vnodes evenly spaced tokens each.
+func testRing(hosts, vnodes int) *tokenRing {
+	n := hosts * vnodes
+	ring := &tokenRing{tokens: make([]int64, n), hosts: make([]string, n)}
+	step := math.MaxUint64 / uint64(n)
+	for i := 0; i < n; i++ {
+		ring.tokens[i] = math.MinInt64 + int64(uint64(i+1)*step)
+		ring.hosts[i] = fmt.Sprintf("10.0.0.%d", i%hosts+1)
+	}
+	sort.Slice(ring.tokens, func(i, j int) bool { return ring.tokens[i] < ring.tokens[j] })
+	return ring
+}
+
+func testStatements(n, valueBytes int) []partitionStatement {
+	value := strings.Repeat("v", valueBytes)
+	stmts := make([]partitionStatement, n)
+	for i := range stmts {
+		key := fmt.Sprintf("app||key-%d", i)
+		stmts[i] = partitionStatement{query: "UPDATE", args: []interface{}{key, value}, storageKey: key}
+	}
+	return stmts
+}
+
+func TestGroupByTokenRange(t *testing.T) {
+	ring := testRing(3, 4)
+
+	tests := []struct {
+		name  string
+		ring  *tokenRing
+		stmts []partitionStatement
+		// Fewest groups expected, which batching must reach
+		maxGroups int
+	}{
+		{name: "no ring", stmts: testStatements(20, 10), maxGroups: 20},
+		{name: "small values", ring: ring, stmts: testStatements(1000, 10), maxGroups: 12 + 1000/writeBatchMaxStatements},
+		{name: "large values", ring: ring, stmts: testStatements(100, 10*1024), maxGroups: 100},
+		{
+			name:      "superseded mutations",
+			ring:      ring,
+			stmts:     append(testStatements(50, 10), testStatements(50, 20)...),
+			maxGroups: 12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := groupByTokenRange(tt.stmts, tt.ring)
+			if len(groups) > tt.maxGroups {
+				t.Errorf("got %d groups, want at most %d", len(groups), tt.maxGroups)
+			}
+
+			seen := make(map[string]partitionStatement)
+			for _, group := range groups {
+				if len(group) == 0 {
+					t.Fatal("empty group")
+				}
+				if tt.ring == nil && len(group) != 1 {
+					t.Errorf("got a group of %d statements without a ring", len(group))
+				}
+				if len(group) > writeBatchMaxStatements {
+					t.Errorf("got a group of %d statements, limit %d", len(group), writeBatchMaxStatements)
+				}
+				size := 0
+				for _, stmt := range group {
+					size += stmt.size()
+					if _, exists := seen[stmt.storageKey]; exists {
+						t.Errorf("key %s grouped twice", stmt.storageKey)
+					}
+					seen[stmt.storageKey] = stmt
+					if tt.ring != nil {
+						want := tt.ring.rangeOf(murmur3Token([]byte(group[0].storageKey)))
+						if got := tt.ring.rangeOf(murmur3Token([]byte(stmt.storageKey))); got != want {
+							t.Errorf("key %s of range %d grouped with range %d", stmt.storageKey, got, want)
+						}
+					}
+				}
+				if len(group) > 1 && size > writeBatchMaxBytes {
+					t.Errorf("got a group of %d bytes, limit %d", size, writeBatchMaxBytes)
+				}
+			}
+
+			// Every key is written once, with its last mutation
+			want := make(map[string]partitionStatement)
+			for _, stmt := range tt.stmts {
+				want[stmt.storageKey] = stmt
+			}
+			if len(seen) != len(want) {
+				t.Fatalf("got %d keys, want %d", len(seen), len(want))
+			}
+			for key, stmt := range want {
+				if seen[key].args[1] != stmt.args[1] {
+					t.Errorf("key %s grouped with a superseded mutation", key)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGroupByTokenRange(b *testing.B) {
+	ring := testRing(6, 256)
+	for _, n := range []int{100, 1000, 10000} {
+		stmts := testStatements(n, 100)
+		b.Run(fmt.Sprintf("keys=%d", n), func(b *testing.B) {
+			var groups [][]partitionStatement
+			for i := 0; i < b.N; i++ {
+				groups = groupByTokenRange(stmts, ring)
+			}
+			// Round trips per bulk request, against one per key without batching
+			b.ReportMetric(float64(len(groups)), "batches/op")
+		})
+	}
+}
+
+func BenchmarkMurmur3Token(b *testing.B) {
+	key := []byte("app||order-0000000042")
+	for i := 0; i < b.N; i++ {
+		murmur3Token(key)
+	}
+}
@@ -0,0 +1,160 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Values are written to the value_blob column, which stores raw bytes without
+// the UTF-8 validation of the legacy text column. Rows written before the
+// column existed keep their value in the text column until the migrator copies
+// them, so every read selects both columns and prefers the blob.
+
+// storedValue returns the value of a row, preferring value_blob over the
+// legacy text column.
+func storedValue(text string, blob []byte) string {
+	if blob != nil {
+		return string(blob)
+	}
+	return text
+}
+
+// storedBytes is storedValue for callers that need the value as bytes; it
+// avoids copying blob values.
+func storedBytes(text string, blob []byte) []byte {
+	if blob != nil {
+		return blob
+	}
+	return []byte(text)
+}
+
+// ensureBlobColumn adds the value_blob column to tables created before it existed.
+func (store *ScyllaStateStore) ensureBlobColumn(ctx context.Context) error {
+	keyspace, err := store.session.KeyspaceMetadata(store.config.Keyspace)
+	if err != nil {
+		return fmt.Errorf("failed to read keyspace metadata: %w", err)
+	}
+	if table, ok := keyspace.Tables[store.config.Table]; ok {
+		if _, exists := table.Columns["value_blob"]; exists {
+			return nil
+		}
+	}
+
+	alterQuery := fmt.Sprintf("ALTER TABLE %s ADD value_blob blob", store.config.Table)
+	store.logger.Infof("Adding value_blob column with query: %s", alterQuery)
+	if err := store.session.Query(alterQuery).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to add value_blob column: %w", err)
+	}
+	return nil
+}
+
+// blobMigrator copies legacy text values into value_blob in the background.
+type blobMigrator struct {
+	rowsPerSecond int
+	job           *backgroundJob
+}
+
+// initBlobMigration parses the migration settings and starts the migrator.
+// With leader election enabled only the replica holding the blobMigration
+// lease scans the table.
+func (store *ScyllaStateStore) initBlobMigration() {
+	rowsPerSecond, err := strconv.Atoi(store.config.BlobMigrationRate)
+	if err != nil || rowsPerSecond <= 0 {
+		store.logger.Warnf("Invalid blobMigrationRate: %s, using default", store.config.BlobMigrationRate)
+		rowsPerSecond = 1000
+	}
+
+	migrator := &blobMigrator{rowsPerSecond: rowsPerSecond}
+	store.blobMigration = migrator
+
+	store.logger.Infof("Blob value migration enabled (rate=%d rows/s)", rowsPerSecond)
+
+	migrator.job = store.startBackgroundJob("blobMigration", func(ctx context.Context) {
+		start := time.Now()
+		scanned, migrated, err := store.migrateBlobValues(ctx, migrator.rowsPerSecond)
+		if err != nil {
+			store.logger.Warnf("Blob value migration stopped after scanning %d rows (%d migrated): %v", scanned, migrated, err)
+			return
+		}
+		store.logger.Infof("Blob value migration completed: scanned %d rows, migrated %d in %v", scanned, migrated, time.Since(start))
+	})
+}
+
+// migrateBlobValues moves every legacy text value into value_blob.
+//
+// Each update is written with the write timestamp of the text value it was
+// read from, so a Set that lands concurrently carries a newer timestamp and
+// wins, and the text cell is removed by a tombstone at that same timestamp.
+// Rows that already have a blob only get their stale text value removed.
+func (store *ScyllaStateStore) migrateBlobValues(ctx context.Context, rowsPerSecond int) (int64, int64, error) {
+	store.mu.RLock()
+	session := store.session
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || session == nil {
+		return 0, 0, errors.New("store is closed")
+	}
+
+	selectQuery := fmt.Sprintf("SELECT key, value, value_blob, writetime(value) FROM %s", store.config.Table)
+	copyQuery := fmt.Sprintf("UPDATE %s USING TIMESTAMP ? SET value_blob = ?, value = null WHERE key = ?", store.config.Table)
+	dropQuery := fmt.Sprintf("DELETE value FROM %s USING TIMESTAMP ? WHERE key = ?", store.config.Table)
+
+	interval := time.Second / time.Duration(rowsPerSecond)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	iter := session.Query(selectQuery).WithContext(ctx).Iter()
+
+	var (
+		scanned, migrated int64
+		key               string
+		text              *string
+		blob              []byte
+		writeTime         *int64
+	)
+	for iter.Scan(&key, &text, &blob, &writeTime) {
+		scanned++
+		if text == nil || writeTime == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			iter.Close()
+			return scanned, migrated, ctx.Err()
+		case <-ticker.C:
+		}
+
+		var err error
+		if blob == nil {
+			err = session.Query(copyQuery, *writeTime, []byte(*text), key).WithContext(ctx).Exec()
+		} else {
+			err = session.Query(dropQuery, *writeTime, key).WithContext(ctx).Exec()
+		}
+		if err != nil {
+			iter.Close()
+			return scanned, migrated, fmt.Errorf("failed to migrate key %s: %w", key, err)
+		}
+
+		migrated++
+		if migrated%10000 == 0 {
+			store.logger.Infof("Blob value migration progress: scanned %d rows, migrated %d", scanned, migrated)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return scanned, migrated, fmt.Errorf("row scan failed: %w", err)
+	}
+	return scanned, migrated, nil
+}
+
+func (m *blobMigrator) stop() {
+	m.job.stop()
+}
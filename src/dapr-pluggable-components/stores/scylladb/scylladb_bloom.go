@@ -0,0 +1,270 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bloomFilter is a fixed-size bloom filter over state keys using double hashing.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+// newBloomFilter sizes a filter for the expected number of keys and target
+// false-positive rate, never exceeding maxBytes of bit storage.
+func newBloomFilter(expected uint64, fpRate float64, maxBytes uint64) *bloomFilter {
+	if expected == 0 {
+		expected = 1
+	}
+
+	m := uint64(math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if maxBits := maxBytes * 8; maxBits > 0 && m > maxBits {
+		m = maxBits
+	}
+	if m < 64 {
+		m = 64
+	}
+
+	k := uint64(math.Round(float64(m) / float64(expected) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+func bloomHashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+	// Force the second hash to be odd so probes cover the whole bit array
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+func (b *bloomFilter) add(key string) {
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) sizeBytes() uint64 {
+	return uint64(len(b.bits)) * 8
+}
+
+// keyFilter maintains a bloom filter of existing keys so Gets for keys that
+// definitely do not exist can skip the backend round trip.
+//
+// The filter is built incrementally from writes and rebuilt periodically from a
+// full key scan, which also drops keys that have since been deleted. Until the
+// first build completes the filter is not consulted.
+//
+// Keys written by anyone else, such as other replicas or the migrate-prefix
+// and load-fixtures commands, only reach the filter at the next rebuild, and
+// Gets report them missing until then. The filter is therefore only enabled
+// when the configuration declares this instance the table's only writer.
+type keyFilter struct {
+	mu       sync.RWMutex
+	current  *bloomFilter // nil until the first build completes
+	building *bloomFilter // receives concurrent adds while a rebuild is running
+	lastSize uint64
+
+	fpRate          float64
+	maxBytes        uint64
+	rebuildInterval time.Duration
+	scan            func(ctx context.Context, add func(key string)) error
+	rebuildCh       chan struct{} // requests a rebuild before the interval ends
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
+}
+
+// Minimum number of keys the filter is sized for before the first scan completes
+const minBloomExpectedKeys = 100000
+
+func (f *keyFilter) add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.current != nil {
+		f.current.add(key)
+	}
+	if f.building != nil {
+		f.building.add(key)
+	}
+}
+
+// invalidate stops consulting the filter and requests a rebuild, after keys
+// were written around it, e.g. by a CQL passthrough statement.
+func (f *keyFilter) invalidate() {
+	f.mu.Lock()
+	f.current = nil
+	f.mu.Unlock()
+
+	select {
+	case f.rebuildCh <- struct{}{}:
+	default:
+	}
+}
+
+// mayContain reports false only when the key definitely does not exist.
+func (f *keyFilter) mayContain(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.current == nil {
+		return true
+	}
+	return f.current.mayContain(key)
+}
+
+// rebuild scans all keys into a fresh filter and swaps it in.
+func (f *keyFilter) rebuild(ctx context.Context) (uint64, uint64, error) {
+	f.mu.Lock()
+	expected := f.lastSize + f.lastSize/4
+	if expected < minBloomExpectedKeys {
+		expected = minBloomExpectedKeys
+	}
+	next := newBloomFilter(expected, f.fpRate, f.maxBytes)
+	f.building = next
+	f.mu.Unlock()
+
+	var count uint64
+	err := f.scan(ctx, func(key string) {
+		f.mu.Lock()
+		next.add(key)
+		f.mu.Unlock()
+		count++
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.building = nil
+	if err != nil {
+		return 0, 0, err
+	}
+	f.current = next
+	f.lastSize = count
+	return count, next.sizeBytes(), nil
+}
+
+func (f *keyFilter) stop() {
+	close(f.stopCh)
+	f.wg.Wait()
+}
+
+// initBloomFilter parses the bloom filter settings and starts the background
+// build/rebuild loop.
+func (store *ScyllaStateStore) initBloomFilter() {
+	if store.config.BloomSingleWriter != "true" {
+		store.logger.Warnf("bloomFilter is set without bloomSingleWriter=true, ignoring it: keys written by other replicas or tools would read as missing until the next rebuild")
+		return
+	}
+
+	fpRate, err := strconv.ParseFloat(store.config.BloomFalsePositiveRate, 64)
+	if err != nil || fpRate <= 0 || fpRate >= 1 {
+		store.logger.Warnf("Invalid bloomFalsePositiveRate: %s, using default", store.config.BloomFalsePositiveRate)
+		fpRate = 0.01
+	}
+
+	maxBytes, err := strconv.ParseUint(store.config.BloomMaxMemory, 10, 64)
+	if err != nil || maxBytes == 0 {
+		store.logger.Warnf("Invalid bloomMaxMemory: %s, using default", store.config.BloomMaxMemory)
+		maxBytes = 64 * 1024 * 1024
+	}
+
+	rebuildInterval, err := time.ParseDuration(store.config.BloomRebuildInterval)
+	if err != nil || rebuildInterval <= 0 {
+		store.logger.Warnf("Invalid bloomRebuildInterval: %s, using default", store.config.BloomRebuildInterval)
+		rebuildInterval = time.Hour
+	}
+
+	store.keyFilter = &keyFilter{
+		fpRate:          fpRate,
+		maxBytes:        maxBytes,
+		rebuildInterval: rebuildInterval,
+		scan:            store.scanKeys,
+		rebuildCh:       make(chan struct{}, 1),
+		stopCh:          make(chan struct{}),
+	}
+
+	store.logger.Infof("Bloom filter enabled (falsePositiveRate=%g, maxMemory=%d bytes, rebuildInterval=%v)",
+		fpRate, maxBytes, rebuildInterval)
+
+	filter := store.keyFilter
+	filter.wg.Add(1)
+	go func() {
+		defer filter.wg.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-filter.stopCh
+			cancel()
+		}()
+
+		ticker := time.NewTicker(filter.rebuildInterval)
+		defer ticker.Stop()
+
+		for {
+			start := time.Now()
+			if count, size, err := filter.rebuild(ctx); err != nil {
+				store.logger.Warnf("Bloom filter rebuild failed: %v", err)
+			} else {
+				store.logger.Infof("Bloom filter rebuilt with %d keys (%d bytes) in %v", count, size, time.Since(start))
+			}
+
+			select {
+			case <-filter.stopCh:
+				return
+			case <-ticker.C:
+			case <-filter.rebuildCh:
+			}
+		}
+	}()
+}
+
+// scanKeys walks every key in the state table.
+func (store *ScyllaStateStore) scanKeys(ctx context.Context, add func(key string)) error {
+	store.mu.RLock()
+	session := store.session
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || session == nil {
+		return errors.New("store is closed")
+	}
+
+	iter, err := store.newKeyIterator(session, "")
+	if err != nil {
+		return err
+	}
+	for iter.Next(ctx) {
+		add(iter.Key())
+	}
+
+	return iter.Err()
+}
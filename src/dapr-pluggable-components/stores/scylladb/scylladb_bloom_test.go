@@ -0,0 +1,167 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected uint64
+		fpRate   float64
+		maxBytes uint64
+		keys     int
+		// Highest false-positive rate accepted over keys never added
+		maxFalsePositives float64
+	}{
+		{"sized for keys", 10000, 0.01, 0, 10000, 0.02},
+		{"low rate", 10000, 0.001, 0, 10000, 0.003},
+		{"fewer keys than expected", 10000, 0.01, 0, 1000, 0.01},
+		// Capped at 4 KiB, far below the 12 KiB the rate needs
+		{"capped size", 10000, 0.01, 4096, 10000, 0.5},
+		{"no expected keys", 0, 0.01, 0, 1, 0.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := newBloomFilter(tt.expected, tt.fpRate, tt.maxBytes)
+			if tt.maxBytes > 0 && filter.sizeBytes() > tt.maxBytes {
+				t.Errorf("sizeBytes() = %d, want at most %d", filter.sizeBytes(), tt.maxBytes)
+			}
+
+			for i := 0; i < tt.keys; i++ {
+				filter.add("key-" + strconv.Itoa(i))
+			}
+			for i := 0; i < tt.keys; i++ {
+				if key := "key-" + strconv.Itoa(i); !filter.mayContain(key) {
+					t.Fatalf("mayContain(%q) = false for an added key", key)
+				}
+			}
+
+			const probes = 100000
+			falsePositives := 0
+			for i := 0; i < probes; i++ {
+				if filter.mayContain("absent-" + strconv.Itoa(i)) {
+					falsePositives++
+				}
+			}
+			if rate := float64(falsePositives) / probes; rate > tt.maxFalsePositives {
+				t.Errorf("false-positive rate = %.4f, want at most %.4f", rate, tt.maxFalsePositives)
+			}
+		})
+	}
+}
+
+func TestBloomFilterEmpty(t *testing.T) {
+	filter := newBloomFilter(1000, 0.01, 0)
+	for _, key := range []string{"", "a", "order-1", "app||key"} {
+		if filter.mayContain(key) {
+			t.Errorf("mayContain(%q) = true on an empty filter", key)
+		}
+	}
+}
+
+func TestKeyFilter(t *testing.T) {
+	stored := []string{"order-1", "order-2", "cart-1"}
+	tests := []struct {
+		name    string
+		prepare func(f *keyFilter)
+		// Keys that must be reported as possibly present
+		present []string
+		// Keys the filter must rule out
+		absent []string
+	}{
+		{
+			name:    "not built",
+			prepare: func(f *keyFilter) {},
+			present: []string{"order-1", "never-written"},
+		},
+		{
+			name:    "built",
+			prepare: rebuildFilter(t),
+			present: stored,
+			absent:  []string{"never-written"},
+		},
+		{
+			name: "added after build",
+			prepare: func(f *keyFilter) {
+				rebuildFilter(t)(f)
+				f.add("order-3")
+			},
+			present: append([]string{"order-3"}, stored...),
+			absent:  []string{"never-written"},
+		},
+		{
+			name: "added during rebuild",
+			prepare: func(f *keyFilter) {
+				scan := f.scan
+				f.scan = func(ctx context.Context, add func(key string)) error {
+					f.add("order-4")
+					return scan(ctx, add)
+				}
+				rebuildFilter(t)(f)
+			},
+			present: append([]string{"order-4"}, stored...),
+			absent:  []string{"never-written"},
+		},
+		{
+			name: "invalidated",
+			prepare: func(f *keyFilter) {
+				rebuildFilter(t)(f)
+				f.invalidate()
+			},
+			present: append([]string{"never-written"}, stored...),
+		},
+		{
+			name: "failed rebuild",
+			prepare: func(f *keyFilter) {
+				f.scan = func(ctx context.Context, add func(key string)) error {
+					add("order-1")
+					return errors.New("scan failed")
+				}
+				if _, _, err := f.rebuild(context.Background()); err == nil {
+					t.Fatal("rebuild() succeeded with a failing scan")
+				}
+			},
+			present: []string{"order-1", "never-written"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &keyFilter{
+				fpRate:    0.01,
+				rebuildCh: make(chan struct{}, 1),
+				scan: func(ctx context.Context, add func(key string)) error {
+					for _, key := range stored {
+						add(key)
+					}
+					return nil
+				},
+			}
+			tt.prepare(f)
+
+			for _, key := range tt.present {
+				if !f.mayContain(key) {
+					t.Errorf("mayContain(%q) = false, want true", key)
+				}
+			}
+			for _, key := range tt.absent {
+				if f.mayContain(key) {
+					t.Errorf("mayContain(%q) = true, want false", key)
+				}
+			}
+		})
+	}
+}
+
+// rebuildFilter returns a step that rebuilds the filter from its scan.
+func rebuildFilter(t *testing.T) func(f *keyFilter) {
+	return func(f *keyFilter) {
+		t.Helper()
+		if _, _, err := f.rebuild(context.Background()); err != nil {
+			t.Fatalf("rebuild() error = %v", err)
+		}
+	}
+}
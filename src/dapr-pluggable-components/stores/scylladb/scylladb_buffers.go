@@ -0,0 +1,86 @@
+package scylladb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Values pass through the store without being copied: a byte slice received
+// from the sidecar becomes the stored string, and that string is bound to the
+// statement as the value_blob bytes, sharing one backing array. This is safe
+// because nothing writes to either after the conversion: protobuf decoding
+// gives every request its own value bytes, the driver only reads bound values
+// while framing them, and values read from the database are handed to the
+// sidecar as-is.
+
+// bytesToString returns b as a string without copying. b must not be modified
+// afterwards.
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// stringToBytes returns the bytes of s without copying. The result must not be
+// modified.
+func stringToBytes(s string) []byte {
+	if s == "" {
+		return []byte{}
+	}
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// Buffers larger than this are not returned to the pool, so one large flush
+// does not pin its memory for the life of the process
+const maxPooledBufferSize = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to the pool; its contents must no longer be referenced.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// stateQueries holds the texts of the unprepared statements built per
+// request, formatted once when the statements are prepared.
+type stateQueries struct {
+	set    string // upsert of key, value_blob, etag, last_modified with TTL
+	delete string // delete by key
+	etag   string // read of the current ETag
+}
+
+// inQueryKey identifies a multi-key SELECT by its columns and key count.
+type inQueryKey struct {
+	columns string
+	keys    int
+}
+
+// inQuery returns the text of a SELECT of columns for n keys, built once per
+// distinct shape. Batches hold at most 100 keys, so the cache stays small.
+func (store *ScyllaStateStore) inQuery(columns string, n int) string {
+	cacheKey := inQueryKey{columns: columns, keys: n}
+	if query, ok := store.inQueries.Load(cacheKey); ok {
+		return query.(string)
+	}
+	placeholders := strings.Repeat("?,", n)
+	placeholders = placeholders[:len(placeholders)-1] // Remove trailing comma
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE key IN (%s)", columns, store.config.Table, placeholders)
+	store.inQueries.Store(cacheKey, query)
+	return query
+}
@@ -0,0 +1,39 @@
+package scylladb
+
+import "fmt"
+
+// bulkGetBudget bounds the value bytes buffered for one BulkGet response.
+// Dapr returns the whole response at once, so instead of streaming, rows are
+// handed from the driver to the response one at a time and the scan stops once
+// the budget is spent; keys that were not read get a per-key error.
+type bulkGetBudget struct {
+	limit int64 // 0 for no limit
+	used  int64
+}
+
+func (store *ScyllaStateStore) newBulkGetBudget() *bulkGetBudget {
+	return &bulkGetBudget{limit: store.bulkGetMaxBytes}
+}
+
+// take reserves n bytes and reports whether they fit in the budget.
+func (b *bulkGetBudget) take(n int) bool {
+	if b.limit > 0 && b.used+int64(n) > b.limit {
+		return false
+	}
+	b.used += int64(n)
+	return true
+}
+
+func (b *bulkGetBudget) exceededError() string {
+	return fmt.Sprintf("bulk get memory budget of %d bytes exceeded, get this key separately", b.limit)
+}
+
+// recordBulkGetBytes tracks the largest BulkGet response for diagnostics.
+func (store *ScyllaStateStore) recordBulkGetBytes(n int64) {
+	for {
+		peak := store.bulkGetPeakBytes.Load()
+		if n <= peak || store.bulkGetPeakBytes.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
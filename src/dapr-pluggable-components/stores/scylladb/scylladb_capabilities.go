@@ -0,0 +1,101 @@
+package scylladb
+
+import "context"
+
+// Queries reading the backend version: ScyllaDB's own version, then the
+// Cassandra release version every node reports (fixed at 3.0.8 on ScyllaDB)
+const (
+	scyllaVersionQuery  = "SELECT version FROM system.versions WHERE key = 'local'"
+	releaseVersionQuery = "SELECT release_version FROM system.local"
+)
+
+// detectBackendVersion reads the version of the coordinator for the
+// capability matrix. A failed lookup leaves the version unknown.
+func (store *ScyllaStateStore) detectBackendVersion(ctx context.Context) {
+	var version string
+	var err error
+	if store.config.Dialect == dialectScylla {
+		err = store.session.Query(scyllaVersionQuery).WithContext(ctx).Scan(&version)
+	}
+	// Cassandra has no system.versions table
+	if store.config.Dialect != dialectScylla || err != nil {
+		err = store.session.Query(releaseVersionQuery).WithContext(ctx).Scan(&version)
+	}
+	if err != nil {
+		store.logger.Warnf("Failed to read the backend version: %v", err)
+		return
+	}
+	store.backendVersion = version
+}
+
+// Capabilities returns the capability matrix logged once Init succeeded and
+// served for support bundles: the backend and its version, the features
+// enabled, the connection pool and the schema.
+func (store *ScyllaStateStore) Capabilities() map[string]any {
+	store.mu.RLock()
+	config := store.config
+	version := store.backendVersion
+	numConns := 0
+	if store.cluster != nil {
+		numConns = store.cluster.NumConns
+	}
+	store.mu.RUnlock()
+
+	if version == "" {
+		version = "unknown"
+	}
+
+	caches := []string{}
+	if store.queryCache != nil {
+		caches = append(caches, "query")
+	}
+	if store.staleCache != nil {
+		caches = append(caches, "stale")
+	}
+	if store.getFlights != nil {
+		caches = append(caches, "getDeduplication")
+	}
+	if store.keyFilter != nil {
+		caches = append(caches, "bloomFilter")
+	}
+
+	schemaStatus := "created"
+	if config.CreateSchema != "true" {
+		schemaStatus = "checked"
+	}
+
+	availableHosts, knownHosts := store.AvailableHosts()
+	return map[string]any{
+		"backend": map[string]any{
+			"dialect": config.Dialect,
+			"version": version,
+		},
+		"features": map[string]any{
+			"ttl":          true,
+			"lwt":          true,
+			"transactions": store.timeBuckets == nil,
+			"queryAPI":     store.queryAPISupported(),
+			"cache":        caches,
+			"encryption": map[string]any{
+				"tls": store.tlsReloader != nil,
+			},
+			"timeBuckets":         store.timeBuckets != nil,
+			"keyGroups":           store.keyGroups != nil,
+			"changeFeed":          store.changeFeed != nil,
+			"clusterMirror":       store.clusterMirror != nil,
+			"transactionChunking": store.txChunking != nil,
+		},
+		"pool": map[string]any{
+			"connectionsPerHost": numConns,
+			"autotuned":          store.autotune != nil,
+			"availableHosts":     availableHosts,
+			"knownHosts":         knownHosts,
+			"injectedSession":    store.injected != nil,
+		},
+		"schema": map[string]any{
+			"keyspace": config.Keyspace,
+			"table":    config.Table,
+			"status":   schemaStatus,
+		},
+	}
+}
@@ -0,0 +1,408 @@
+package scylladb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+// With changeFeed enabled every successful write is also recorded in the
+// <table>_changes table: the key, the operation, the new ETag and a timeuuid,
+// partitioned by minute and expiring after changeFeedRetention. Services watch
+// keys through Query requests carrying watch or watchPrefix metadata, which
+// long-poll the feed until a matching change arrives and return it with a
+// resume token. Records are written by every replica in the background, so a
+// watch sees writes made through any replica of the component.
+
+// Request metadata keys of watch queries
+const (
+	watchMetadataKey       = "watch"
+	watchPrefixMetadataKey = "watchPrefix"
+	resumeTokenMetadataKey = "resumeToken"
+	waitTimeoutMetadataKey = "waitTimeout"
+)
+
+const (
+	// Width of one change feed partition
+	changeFeedSlot = time.Minute
+	// Age a change must reach before watches deliver it, covering the
+	// background write and clock skew between replicas so a watch never
+	// advances its token past a change that is still arriving
+	changeFeedSettle = 2 * time.Second
+	// Interval and size of the background writes of changes
+	changeFeedFlushInterval = 100 * time.Millisecond
+	changeFeedBatchSize     = 100
+	// Changes waiting to be written; more are dropped
+	changeFeedQueueSize = 10000
+	// Changes returned by one watch
+	watchMaxChanges = 100
+)
+
+var errChangeFeedDisabled = errors.New("watch requires changeFeed=true")
+
+// changeEvent is one recorded write.
+type changeEvent struct {
+	id   gocql.UUID
+	key  string // stored partition key
+	op   string // set or delete
+	etag string
+}
+
+// changeFeed records writes and serves watches.
+type changeFeed struct {
+	retention    time.Duration
+	pollInterval time.Duration
+	maxWait      time.Duration
+	queue        chan changeEvent
+
+	recorded atomic.Int64
+	dropped  atomic.Int64
+	failed   atomic.Int64
+	watches  atomic.Int64 // watches waiting now
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// changeFeedTable returns the name of the table holding the change feed.
+func (store *ScyllaStateStore) changeFeedTable() string {
+	return store.config.Table + "_changes"
+}
+
+// changeFeedTableDDL returns the CREATE TABLE statement of a change feed
+// table; name may be keyspace-qualified.
+func changeFeedTableDDL(name string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			slot bigint,
+			id timeuuid,
+			key text,
+			op text,
+			etag text,
+			PRIMARY KEY (slot, id)
+		)`, name)
+}
+
+// ensureChangeFeedTable creates the change feed table when the feed is enabled.
+func (store *ScyllaStateStore) ensureChangeFeedTable(ctx context.Context) error {
+	createQuery := changeFeedTableDDL(store.changeFeedTable())
+
+	store.logger.Debugf("Creating change feed table with query: %s", createQuery)
+	if err := store.session.Query(createQuery).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to create change feed table: %w", err)
+	}
+	return nil
+}
+
+// initChangeFeed parses the feed settings and starts the background writer.
+func (store *ScyllaStateStore) initChangeFeed() {
+	retention, err := time.ParseDuration(store.config.ChangeFeedRetention)
+	if err != nil || retention < time.Minute {
+		store.logger.Warnf("Invalid changeFeedRetention: %s, using default", store.config.ChangeFeedRetention)
+		retention = time.Hour
+	}
+	pollInterval, err := time.ParseDuration(store.config.WatchPollInterval)
+	if err != nil || pollInterval < 10*time.Millisecond {
+		store.logger.Warnf("Invalid watchPollInterval: %s, using default", store.config.WatchPollInterval)
+		pollInterval = 500 * time.Millisecond
+	}
+	maxWait, err := time.ParseDuration(store.config.WatchMaxWait)
+	if err != nil || maxWait <= 0 {
+		store.logger.Warnf("Invalid watchMaxWait: %s, using default", store.config.WatchMaxWait)
+		maxWait = 30 * time.Second
+	}
+
+	feed := &changeFeed{
+		retention:    retention,
+		pollInterval: pollInterval,
+		maxWait:      maxWait,
+		queue:        make(chan changeEvent, changeFeedQueueSize),
+		stopCh:       make(chan struct{}),
+	}
+	store.changeFeed = feed
+	store.logger.Infof("Change feed enabled (retention=%v, watchPollInterval=%v, watchMaxWait=%v)", retention, pollInterval, maxWait)
+
+	feed.wg.Add(1)
+	go store.runChangeFeed(feed)
+}
+
+// record queues a change without blocking the write path.
+func (f *changeFeed) record(storageKey, op, etag string) {
+	select {
+	case f.queue <- changeEvent{id: gocql.TimeUUID(), key: storageKey, op: op, etag: etag}:
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+// runChangeFeed writes queued changes in batches until the feed stops.
+func (store *ScyllaStateStore) runChangeFeed(feed *changeFeed) {
+	defer feed.wg.Done()
+
+	ticker := time.NewTicker(changeFeedFlushInterval)
+	defer ticker.Stop()
+
+	pending := make([]changeEvent, 0, changeFeedBatchSize)
+	for {
+		select {
+		case event := <-feed.queue:
+			pending = append(pending, event)
+			if len(pending) >= changeFeedBatchSize {
+				store.writeChanges(feed, pending)
+				pending = pending[:0]
+			}
+		case <-ticker.C:
+			if len(pending) > 0 {
+				store.writeChanges(feed, pending)
+				pending = pending[:0]
+			}
+		case <-feed.stopCh:
+			// Write whatever is still queued before exiting
+			for {
+				select {
+				case event := <-feed.queue:
+					pending = append(pending, event)
+				default:
+					if len(pending) > 0 {
+						store.writeChanges(feed, pending)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeChanges stores events with one unlogged batch per partition.
+func (store *ScyllaStateStore) writeChanges(feed *changeFeed, events []changeEvent) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.session == nil {
+		feed.failed.Add(int64(len(events)))
+		return
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO %s (slot, id, key, op, etag) VALUES (?, ?, ?, ?, ?) USING TTL ?", store.changeFeedTable())
+	ttl := int(feed.retention / time.Second)
+
+	slots := make(map[int64][]changeEvent)
+	for _, event := range events {
+		slot := changeSlot(event.id.Time())
+		slots[slot] = append(slots[slot], event)
+	}
+	for slot, slotEvents := range slots {
+		batch := store.session.NewBatch(gocql.UnloggedBatch)
+		for _, event := range slotEvents {
+			batch.Query(insertQuery, slot, event.id, event.key, event.op, event.etag, ttl)
+		}
+		if err := store.session.ExecuteBatch(batch); err != nil {
+			feed.failed.Add(int64(len(slotEvents)))
+			store.logger.Warnf("Failed to record %d changes in the change feed: %v", len(slotEvents), err)
+			continue
+		}
+		feed.recorded.Add(int64(len(slotEvents)))
+	}
+}
+
+// changeSlot returns the partition of changes made at t.
+func changeSlot(t time.Time) int64 {
+	return t.Unix() / int64(changeFeedSlot/time.Second)
+}
+
+// watchQuery handles Query requests carrying watch (a key) or watchPrefix
+// metadata. It returns the changes of matching keys after resumeToken, waiting
+// up to waitTimeout (watchMaxWait at most) for one to arrive. Without a token
+// it waits for changes made after the request. The response carries the token
+// to pass to the next watch, also when it timed out without changes.
+func (store *ScyllaStateStore) watchQuery(ctx context.Context, metadata map[string]string) (*state.QueryResponse, error) {
+	store.mu.RLock()
+	feed := store.changeFeed
+	store.mu.RUnlock()
+	if feed == nil {
+		return nil, errChangeFeedDisabled
+	}
+
+	matches, err := store.watchMatcher(metadata[watchMetadataKey], metadata[watchPrefixMetadataKey])
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := gocql.MaxTimeUUID(time.Now())
+	if token := metadata[resumeTokenMetadataKey]; token != "" {
+		if cursor, err = gocql.ParseUUID(token); err != nil || cursor.Version() != 1 {
+			return nil, fmt.Errorf("invalid %s: %s", resumeTokenMetadataKey, token)
+		}
+	}
+	// Changes older than the retention have expired
+	resumeLost := time.Since(cursor.Time()) > feed.retention
+
+	wait := feed.maxWait
+	if raw := metadata[waitTimeoutMetadataKey]; raw != "" {
+		requested, err := time.ParseDuration(raw)
+		if err != nil || requested < 0 {
+			return nil, fmt.Errorf("invalid %s: %s", waitTimeoutMetadataKey, raw)
+		}
+		wait = min(requested, feed.maxWait)
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	feed.watches.Add(1)
+	defer feed.watches.Add(-1)
+
+	for {
+		var results []state.QueryItem
+		results, cursor, err = store.readChanges(ctx, cursor, matches)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > 0 {
+			return watchResponse(results, cursor, resumeLost), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-feed.stopCh:
+			return nil, errors.New("store is closed")
+		case <-deadline.C:
+			return watchResponse([]state.QueryItem{}, cursor, resumeLost), nil
+		case <-time.After(feed.pollInterval):
+		}
+	}
+}
+
+// watchMatcher returns the function mapping stored keys to the watched Dapr
+// keys they belong to.
+func (store *ScyllaStateStore) watchMatcher(key, prefix string) (func(storageKey string) (string, bool), error) {
+	switch {
+	case key != "" && prefix != "":
+		return nil, fmt.Errorf("%s and %s cannot be combined", watchMetadataKey, watchPrefixMetadataKey)
+	case key != "":
+		watched := store.storageKey(key)
+		return func(storageKey string) (string, bool) {
+			return key, storageKey == watched
+		}, nil
+	case prefix != "":
+		// Prefixes are matched on Dapr keys, which hashed partition keys do not preserve
+		if store.keys != nil && !store.keys.reversible() {
+			return nil, errors.New("watchPrefix is not supported with keyStrategy=hash")
+		}
+		return func(storageKey string) (string, bool) {
+			daprKey := storageKey
+			if store.keys != nil {
+				var ok bool
+				if daprKey, ok = store.keys.fromStorage(storageKey); !ok {
+					return "", false
+				}
+			}
+			return daprKey, strings.HasPrefix(daprKey, prefix)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s or %s metadata must name the keys to watch", watchMetadataKey, watchPrefixMetadataKey)
+	}
+}
+
+// readChanges returns the matching changes after cursor that are old enough
+// to be delivered, and the cursor to continue from.
+func (store *ScyllaStateStore) readChanges(ctx context.Context, cursor gocql.UUID, matches func(string) (string, bool)) ([]state.QueryItem, gocql.UUID, error) {
+	upper := time.Now().Add(-changeFeedSettle)
+	if !upper.After(cursor.Time()) {
+		return nil, cursor, nil
+	}
+	upperID := gocql.MaxTimeUUID(upper)
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	if store.closed || store.session == nil {
+		return nil, cursor, errors.New("store is closed")
+	}
+
+	selectQuery := fmt.Sprintf("SELECT id, key, op, etag FROM %s WHERE slot = ? AND id > ? AND id <= ?", store.changeFeedTable())
+	var results []state.QueryItem
+	for slot := changeSlot(cursor.Time()); slot <= changeSlot(upper); slot++ {
+		iter := store.session.Query(selectQuery, slot, cursor, upperID).WithContext(ctx).Iter()
+		var event changeEvent
+		for iter.Scan(&event.id, &event.key, &event.op, &event.etag) {
+			daprKey, ok := matches(event.key)
+			if !ok {
+				continue
+			}
+			results = append(results, changeItem(daprKey, event))
+			if len(results) == watchMaxChanges {
+				// Continue after the last change returned
+				iter.Close()
+				return results, event.id, nil
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return nil, cursor, fmt.Errorf("failed to read the change feed: %w", err)
+		}
+	}
+	return results, upperID, nil
+}
+
+// changeItem describes a change as a query result item.
+func changeItem(daprKey string, event changeEvent) state.QueryItem {
+	data, _ := json.Marshal(map[string]string{
+		"op":   event.op,
+		"etag": event.etag,
+		"time": event.id.Time().UTC().Format(time.RFC3339Nano),
+	})
+	item := state.QueryItem{Key: daprKey, Data: data}
+	if event.op == "set" {
+		etag := event.etag
+		item.ETag = &etag
+	}
+	return item
+}
+
+func watchResponse(results []state.QueryItem, cursor gocql.UUID, resumeLost bool) *state.QueryResponse {
+	return &state.QueryResponse{
+		Results: results,
+		Metadata: map[string]string{
+			resumeTokenMetadataKey: cursor.String(),
+			"changes":              strconv.Itoa(len(results)),
+			"resumeLost":           strconv.FormatBool(resumeLost),
+		},
+	}
+}
+
+func (f *changeFeed) stop() {
+	close(f.stopCh)
+	f.wg.Wait()
+}
+
+// gauges returns the change feed counters for the metrics endpoint.
+func (f *changeFeed) gauges() map[string]float64 {
+	return map[string]float64{
+		"change_feed_recorded_total": float64(f.recorded.Load()),
+		"change_feed_dropped_total":  float64(f.dropped.Load()),
+		"change_feed_failed_total":   float64(f.failed.Load()),
+		"change_feed_queue_length":   float64(len(f.queue)),
+		"watches_waiting":            float64(f.watches.Load()),
+	}
+}
+
+func (f *changeFeed) diagnostics() map[string]any {
+	return map[string]any{
+		"retention":    f.retention.String(),
+		"pollInterval": f.pollInterval.String(),
+		"maxWait":      f.maxWait.String(),
+		"queued":       len(f.queue),
+		"recorded":     f.recorded.Load(),
+		"dropped":      f.dropped.Load(),
+		"failed":       f.failed.Load(),
+		"watches":      f.watches.Load(),
+	}
+}
@@ -0,0 +1,47 @@
+package scylladb
+
+import (
+	"time"
+
+	"nebulagraph/stores/clock"
+)
+
+// UseClock replaces the clock and the ETag generator of the store, for tests
+// of TTLs, ETag conflicts and time buckets that should not depend on the wall
+// clock. It must be called before Init; the testClock and testETags metadata
+// take precedence.
+func (store *ScyllaStateStore) UseClock(c clock.Clock, etags clock.ETags) {
+	store.clock = c
+	store.etags = etags
+}
+
+// initClock selects the clock and ETag generator. testClock and testETags are
+// hidden options for reproducible staging runs and are not documented for
+// production use.
+func (store *ScyllaStateStore) initClock() {
+	if store.config.TestClock != "" || store.config.TestETags != "" {
+		c, etags, err := clock.FromMetadata(store.config.TestClock, store.config.TestETags)
+		if err != nil {
+			store.logger.Warnf("Invalid test clock: %v, using the wall clock", err)
+		} else {
+			store.logger.Warnf("Using test clock (testClock=%q, testETags=%q); not for production", store.config.TestClock, store.config.TestETags)
+			store.clock, store.etags = c, etags
+		}
+	}
+	if store.clock == nil {
+		store.clock = clock.System
+	}
+	if store.etags == nil {
+		store.etags = clock.TimeETags(store.clock)
+	}
+}
+
+// now returns the store's current time.
+func (store *ScyllaStateStore) now() time.Time {
+	return store.clock.Now()
+}
+
+// newETag returns a fresh ETag.
+func (store *ScyllaStateStore) newETag() string {
+	return store.etags.NewETag()
+}
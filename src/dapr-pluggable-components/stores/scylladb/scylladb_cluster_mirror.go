@@ -0,0 +1,363 @@
+package scylladb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/kit/logger"
+	"github.com/gocql/gocql"
+)
+
+const (
+	// Delay between connection attempts while the secondary cluster is unreachable
+	mirrorConnectRetry = 5 * time.Second
+	// Time Close spends writing operations still queued
+	mirrorDrainTimeout = 5 * time.Second
+	// Minimum interval between logged drops
+	mirrorDropLogInterval = 10 * time.Second
+)
+
+// mirrorOp is one write to replay on the secondary cluster.
+type mirrorOp struct {
+	key       string // stored partition key
+	value     string
+	etag      string
+	ttl       int
+	delete    bool
+	writtenAt time.Time
+}
+
+// clusterMirror asynchronously replays successful writes on a secondary
+// cluster, typically a near-real-time analytics cluster. It is fire-and-forget:
+// operations wait in bounded per-worker queues and are dropped, and counted,
+// when a queue is full or the secondary write fails, so the secondary never
+// slows down or fails the primary write path.
+//
+// Each key always goes to the same worker, so writes of a key are replayed in
+// order, and every mirrored write carries the primary write's timestamp so a
+// late replay never overwrites a newer value.
+type clusterMirror struct {
+	newCluster func(keyspace string) *gocql.ClusterConfig
+	hosts      []string
+	keyspace   string
+	table      string
+	// Whether the keyspace and table are created when missing, and the
+	// replication settings of the keyspace
+	createSchema        bool
+	replicationStrategy string
+	replicationFactor   string
+	logger              logger.Logger
+
+	session atomic.Pointer[gocql.Session]
+	queues  []chan mirrorOp
+
+	enqueued    atomic.Int64
+	written     atomic.Int64
+	dropped     atomic.Int64
+	failed      atomic.Int64
+	lastDropLog atomic.Int64
+
+	// Optional periodic comparison with the primary (nil when disabled)
+	reconciler *mirrorReconciler
+	// Optional comparison of Gets with the mirror (nil when disabled)
+	shadow *shadowReader
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// initClusterMirror parses the mirror settings and starts connecting to the
+// secondary cluster in the background; Init never waits for it.
+func (store *ScyllaStateStore) initClusterMirror() {
+	port := store.config.MirrorPort
+	if port == "" {
+		port = store.config.Port
+	}
+	var hosts []string
+	for _, host := range strings.Split(store.config.MirrorHosts, ",") {
+		if host = strings.TrimSpace(host); host == "" {
+			continue
+		}
+		if !strings.Contains(host, ":") {
+			host = fmt.Sprintf("%s:%s", host, port)
+		}
+		hosts = append(hosts, host)
+	}
+
+	keyspace := store.config.MirrorKeyspace
+	if keyspace == "" {
+		keyspace = store.config.Keyspace
+	}
+
+	consistency, err := gocql.ParseConsistencyWrapper(strings.ToUpper(store.config.MirrorConsistency))
+	if err != nil {
+		store.logger.Warnf("Invalid mirrorConsistency: %s, using default", store.config.MirrorConsistency)
+		consistency = gocql.LocalOne
+	}
+
+	queueSize, err := strconv.Atoi(store.config.MirrorQueueSize)
+	if err != nil || queueSize <= 0 {
+		store.logger.Warnf("Invalid mirrorQueueSize: %s, using default", store.config.MirrorQueueSize)
+		queueSize = 10000
+	}
+
+	workers, err := strconv.Atoi(store.config.MirrorWorkers)
+	if err != nil || workers <= 0 {
+		store.logger.Warnf("Invalid mirrorWorkers: %s, using default", store.config.MirrorWorkers)
+		workers = 4
+	}
+
+	// The secondary shares the primary's credentials, TLS and transport settings,
+	// as configured at Init
+	primary := store.cluster
+	authenticator, sslOpts, compressor := primary.Authenticator, primary.SslOpts, primary.Compressor
+	connectTimeout, timeout, keepalive := primary.ConnectTimeout, primary.Timeout, primary.SocketKeepalive
+	reconnectInterval, protoVersion := primary.ReconnectInterval, primary.ProtoVersion
+	mirror := &clusterMirror{
+		newCluster: func(keyspace string) *gocql.ClusterConfig {
+			cluster := gocql.NewCluster(hosts...)
+			cluster.Keyspace = keyspace
+			cluster.Authenticator = authenticator
+			cluster.SslOpts = sslOpts
+			cluster.Compressor = compressor
+			cluster.ConnectTimeout = connectTimeout
+			cluster.Timeout = timeout
+			cluster.SocketKeepalive = keepalive
+			cluster.ReconnectInterval = reconnectInterval
+			cluster.ProtoVersion = protoVersion
+			cluster.Consistency = consistency
+			cluster.DefaultTimestamp = true
+			cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+			// Failed writes are dropped rather than retried
+			cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: 1}
+			cluster.Events.DisableSchemaEvents = true
+			store.applyDialect(cluster)
+			return cluster
+		},
+		hosts:               hosts,
+		keyspace:            keyspace,
+		table:               store.config.Table,
+		replicationStrategy: store.config.ReplicationStrategy,
+		replicationFactor:   store.config.ReplicationFactor,
+		createSchema:        store.config.CreateSchema == "true",
+		logger:              store.logger,
+		queues:              make([]chan mirrorOp, workers),
+		stopCh:              make(chan struct{}),
+	}
+	for i := range mirror.queues {
+		mirror.queues[i] = make(chan mirrorOp, (queueSize+workers-1)/workers)
+	}
+	store.clusterMirror = mirror
+
+	store.logger.Infof("Mirroring writes to %v (keyspace=%s, consistency=%s, queue=%d, workers=%d)",
+		hosts, keyspace, consistency, queueSize, workers)
+
+	mirror.wg.Add(1)
+	go mirror.run()
+}
+
+// enqueue adds an operation without blocking the caller.
+func (m *clusterMirror) enqueue(op mirrorOp) {
+	h := fnv.New32a()
+	h.Write([]byte(op.key))
+	select {
+	case m.queues[h.Sum32()%uint32(len(m.queues))] <- op:
+		m.enqueued.Add(1)
+	default:
+		m.drop(1, "queue full")
+	}
+}
+
+// drop counts dropped operations, logging at most every mirrorDropLogInterval.
+func (m *clusterMirror) drop(n int64, reason string) {
+	total := m.dropped.Add(n)
+	now := time.Now().UnixNano()
+	last := m.lastDropLog.Load()
+	if now-last >= int64(mirrorDropLogInterval) && m.lastDropLog.CompareAndSwap(last, now) {
+		m.logger.Warnf("Dropping mirrored writes (%s); %d dropped so far", reason, total)
+	}
+}
+
+// mirrorSet queues an upsert of the stored key and value.
+func (m *clusterMirror) mirrorSet(storageKey, value, etag string, ttl int) {
+	m.enqueue(mirrorOp{key: storageKey, value: value, etag: etag, ttl: ttl, writtenAt: time.Now()})
+}
+
+// mirrorDelete queues a delete of the stored key.
+func (m *clusterMirror) mirrorDelete(storageKey string) {
+	m.enqueue(mirrorOp{key: storageKey, delete: true, writtenAt: time.Now()})
+}
+
+// run connects to the secondary cluster, retrying until it succeeds or the
+// mirror stops, then starts the workers. Operations queue up meanwhile.
+func (m *clusterMirror) run() {
+	defer m.wg.Done()
+
+	for {
+		session, err := m.connect()
+		if err == nil {
+			m.session.Store(session)
+			break
+		}
+		m.logger.Warnf("Failed to connect to mirror cluster %v, retrying in %v: %v", m.hosts, mirrorConnectRetry, err)
+
+		select {
+		case <-m.stopCh:
+			// Nothing queued can be written
+			for _, queue := range m.queues {
+				for len(queue) > 0 {
+					<-queue
+					m.drop(1, "mirror cluster unreachable at close")
+				}
+			}
+			return
+		case <-time.After(mirrorConnectRetry):
+		}
+	}
+	m.logger.Infof("Connected to mirror cluster %v", m.hosts)
+
+	m.wg.Add(len(m.queues))
+	for _, queue := range m.queues {
+		go m.worker(queue)
+	}
+}
+
+// connect opens a session on the mirror keyspace, creating the keyspace and
+// table like the primary does when they are missing.
+func (m *clusterMirror) connect() (*gocql.Session, error) {
+	if !m.createSchema {
+		return m.newCluster(m.keyspace).CreateSession()
+	}
+	session, err := m.newCluster("").CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	err = session.Query(keyspaceDDL(m.keyspace, m.replicationStrategy, m.replicationFactor)).Exec()
+	session.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keyspace: %w", err)
+	}
+
+	session, err = m.newCluster(m.keyspace).CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Query(stateTableDDL(m.table)).Exec(); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to create table: %w", err)
+	}
+	return session, nil
+}
+
+func (m *clusterMirror) worker(queue chan mirrorOp) {
+	defer m.wg.Done()
+
+	session := m.session.Load()
+	for {
+		select {
+		case op := <-queue:
+			m.write(session, op)
+		case <-m.stopCh:
+			// Write what is still queued, within the drain timeout
+			deadline := time.Now().Add(mirrorDrainTimeout)
+			for {
+				select {
+				case op := <-queue:
+					if time.Now().After(deadline) {
+						m.drop(1, "drain timeout at close")
+						continue
+					}
+					m.write(session, op)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write replays one operation, counting it as written or failed.
+func (m *clusterMirror) write(session *gocql.Session, op mirrorOp) {
+	if err := m.apply(session, op); err != nil {
+		m.failed.Add(1)
+		m.logger.Debugf("Failed to mirror write of %s: %v", op.key, err)
+		return
+	}
+	m.written.Add(1)
+}
+
+// apply writes one operation with the timestamp of the primary write.
+func (m *clusterMirror) apply(session *gocql.Session, op mirrorOp) error {
+	var query *gocql.Query
+	if op.delete {
+		query = session.Query(fmt.Sprintf("DELETE FROM %s WHERE key = ?", m.table), op.key)
+	} else {
+		query = session.Query(fmt.Sprintf("INSERT INTO %s (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?) USING TTL ?", m.table),
+			op.key, stringToBytes(op.value), op.etag, op.writtenAt, op.ttl)
+	}
+	return query.WithTimestamp(op.writtenAt.UnixMicro()).Idempotent(true).Exec()
+}
+
+func (m *clusterMirror) stop() {
+	close(m.stopCh)
+	m.wg.Wait()
+	if session := m.session.Load(); session != nil {
+		session.Close()
+	}
+}
+
+// queued returns the number of operations waiting in the queues.
+func (m *clusterMirror) queued() int {
+	n := 0
+	for _, queue := range m.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// gauges returns the mirror counters for the metrics endpoint.
+func (m *clusterMirror) gauges() map[string]float64 {
+	gauges := map[string]float64{
+		"mirror_enqueued_total": float64(m.enqueued.Load()),
+		"mirror_written_total":  float64(m.written.Load()),
+		"mirror_dropped_total":  float64(m.dropped.Load()),
+		"mirror_failed_total":   float64(m.failed.Load()),
+		"mirror_queue_length":   float64(m.queued()),
+	}
+	if m.reconciler != nil {
+		for name, value := range m.reconciler.gauges() {
+			gauges[name] = value
+		}
+	}
+	if m.shadow != nil {
+		for name, value := range m.shadow.gauges() {
+			gauges[name] = value
+		}
+	}
+	return gauges
+}
+
+func (m *clusterMirror) diagnostics() map[string]any {
+	diagnostics := map[string]any{
+		"hosts":     m.hosts,
+		"keyspace":  m.keyspace,
+		"connected": m.session.Load() != nil,
+		"queued":    m.queued(),
+		"enqueued":  m.enqueued.Load(),
+		"written":   m.written.Load(),
+		"dropped":   m.dropped.Load(),
+		"failed":    m.failed.Load(),
+	}
+	if m.reconciler != nil {
+		diagnostics["reconcile"] = m.reconciler.diagnostics()
+	}
+	if m.shadow != nil {
+		diagnostics["shadowReads"] = m.shadow.diagnostics()
+	}
+	return diagnostics
+}
@@ -0,0 +1,391 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Defaults of the compaction report
+const (
+	defaultCompactionSampleRows = 100000
+	defaultCompactionStaleAfter = 90 * 24 * time.Hour
+	// Key group entries checked for a row
+	compactionKeyGroupSample = 1000
+	// Rows read per page of the sample
+	compactionPageSize = 1000
+)
+
+// Thresholds of the compaction recommendations
+const (
+	// Share of sampled rows without a TTL, not written for staleAfter, that
+	// makes retention worth setting
+	staleShareWarning = 0.2
+	// Share of sampled rows with a TTL from which time-windowed compaction
+	// drops expired data by whole SSTables
+	ttlShareTimeWindow = 0.9
+	// Value size from which rows slow down compaction and repair
+	largeValueBytes = 1 << 20
+	// Share of orphan key group entries worth a repair
+	orphanShareWarning = 0.05
+	// Search index queue fill worth a larger batch or shorter interval
+	searchQueueWarning = 0.8
+)
+
+// Severities of recommendations
+const (
+	AdviceInfo    = "info"
+	AdviceWarning = "warning"
+)
+
+// CompactionOptions bounds the work of a compaction report.
+type CompactionOptions struct {
+	SampleRows int           // rows read from the state table (default: 100000)
+	StaleAfter time.Duration // age from which rows without a TTL count as stale (default: 90 days)
+}
+
+// CompactionReport describes how a state table grows and expires, and
+// recommends settings keeping it compact.
+type CompactionReport struct {
+	Keyspace    string    `json:"keyspace"`
+	Table       string    `json:"table"`
+	GeneratedAt time.Time `json:"generatedAt"`
+
+	// Rows read; Complete when they are the whole table
+	SampledRows int64 `json:"sampledRows"`
+	Complete    bool  `json:"complete"`
+
+	KeySize   SizeSummary   `json:"keySize"`
+	ValueSize SizeSummary   `json:"valueSize"`
+	Expiry    ExpirySummary `json:"expiry"`
+
+	TableOptions TableOptions `json:"tableOptions"`
+
+	// Time bucket tables past the retention that were not dropped yet
+	ExpiredBuckets []string `json:"expiredBuckets,omitempty"`
+	// Key group index entries whose key has no row
+	KeyGroups *IndexHealth `json:"keyGroups,omitempty"`
+	// Writes waiting to be mirrored to the search index
+	SearchIndex *QueueHealth `json:"searchIndex,omitempty"`
+
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// SizeSummary is the distribution of sampled sizes in bytes. Histogram has
+// the power-of-two buckets of workload samples.
+type SizeSummary struct {
+	Total     int64   `json:"total"`
+	P50       int     `json:"p50"`
+	P90       int     `json:"p90"`
+	P99       int     `json:"p99"`
+	Max       int     `json:"max"`
+	Histogram []int64 `json:"histogram"`
+}
+
+// ExpirySummary counts sampled rows by expiry.
+type ExpirySummary struct {
+	WithTTL    int64 `json:"withTtl"`
+	WithoutTTL int64 `json:"withoutTtl"`
+	// Rows without a TTL last written before StaleAfter
+	Stale      int64  `json:"stale"`
+	StaleAfter string `json:"staleAfter"`
+	// Oldest write of the sampled rows
+	OldestWrite *time.Time `json:"oldestWrite,omitempty"`
+}
+
+// TableOptions are the schema options of the state table that govern how
+// expired and deleted data is purged.
+type TableOptions struct {
+	CompactionClass   string `json:"compactionClass"`
+	GCGraceSeconds    int    `json:"gcGraceSeconds"`
+	DefaultTimeToLive int    `json:"defaultTimeToLive"`
+}
+
+// IndexHealth counts the sampled entries of an index without a row.
+type IndexHealth struct {
+	Sampled int64 `json:"sampled"`
+	Orphans int64 `json:"orphans"`
+}
+
+// QueueHealth is the fill of a bounded queue.
+type QueueHealth struct {
+	Queued   int `json:"queued"`
+	Capacity int `json:"capacity"`
+}
+
+// Recommendation is one setting worth changing, with the reason.
+type Recommendation struct {
+	Severity string `json:"severity"`
+	Setting  string `json:"setting"`
+	Advice   string `json:"advice"`
+}
+
+// CompactionReport samples the state table and the indexes the configuration
+// maintains, and recommends TTL, retention and compaction settings: rows that
+// never expire and are no longer written, tables whose rows all expire but
+// are compacted by size, large values, time bucket tables left behind, and
+// orphan or backed up index entries. The sample is a full scan up to
+// SampleRows, so the report is computed on request only.
+func (store *ScyllaStateStore) CompactionReport(ctx context.Context, opts CompactionOptions) (*CompactionReport, error) {
+	store.mu.RLock()
+	session := store.session
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || session == nil {
+		return nil, errors.New("store is closed")
+	}
+
+	if opts.SampleRows <= 0 {
+		opts.SampleRows = defaultCompactionSampleRows
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = defaultCompactionStaleAfter
+	}
+
+	now := store.now()
+	report := &CompactionReport{
+		Keyspace:    store.config.Keyspace,
+		Table:       store.config.Table,
+		GeneratedAt: now.UTC(),
+		Expiry:      ExpirySummary{StaleAfter: opts.StaleAfter.String()},
+	}
+
+	options, err := store.tableOptions(ctx, session, store.config.Table)
+	if err != nil {
+		return nil, err
+	}
+	report.TableOptions = options
+
+	// Keys live in the kept buckets when time buckets are enabled
+	tables := []string{store.config.Table}
+	if store.timeBuckets != nil {
+		tables = store.timeBuckets.liveBuckets(store.config.Table, now)
+		if report.ExpiredBuckets, err = store.expiredBuckets(ctx, session, now); err != nil {
+			return nil, err
+		}
+	}
+	if err := store.sampleRows(ctx, session, tables, opts, now, report); err != nil {
+		return nil, err
+	}
+
+	if store.keyGroups != nil && store.timeBuckets == nil {
+		if report.KeyGroups, err = store.keyGroupHealth(ctx, session, now); err != nil {
+			return nil, err
+		}
+	}
+	if searchIndex := store.searchIndex; searchIndex != nil {
+		report.SearchIndex = &QueueHealth{Queued: len(searchIndex.queue), Capacity: cap(searchIndex.queue)}
+	}
+
+	report.Recommendations = store.recommend(report)
+	return report, nil
+}
+
+// tableOptions reads the compaction, gc_grace_seconds and default TTL of table.
+func (store *ScyllaStateStore) tableOptions(ctx context.Context, session *gocql.Session, table string) (TableOptions, error) {
+	var (
+		options    TableOptions
+		compaction map[string]string
+	)
+	err := session.Query(
+		"SELECT compaction, gc_grace_seconds, default_time_to_live FROM system_schema.tables WHERE keyspace_name = ? AND table_name = ?",
+		store.config.Keyspace, strings.ToLower(table)).WithContext(ctx).
+		Scan(&compaction, &options.GCGraceSeconds, &options.DefaultTimeToLive)
+	if err != nil {
+		return options, fmt.Errorf("failed to read options of table %s: %w", table, err)
+	}
+	// The class is fully qualified on Cassandra
+	class := compaction["class"]
+	options.CompactionClass = class[strings.LastIndex(class, ".")+1:]
+	return options, nil
+}
+
+// sampleRows reads up to opts.SampleRows rows of tables, recording their key
+// and value sizes and expiry.
+func (store *ScyllaStateStore) sampleRows(ctx context.Context, session *gocql.Session, tables []string, opts CompactionOptions, now time.Time, report *CompactionReport) error {
+	var keySizes, valueSizes []int
+	staleBefore := now.Add(-opts.StaleAfter).UnixMicro()
+	var oldest int64
+
+	report.Complete = true
+	for _, table := range tables {
+		remaining := opts.SampleRows - len(keySizes)
+		if remaining <= 0 {
+			report.Complete = false
+			break
+		}
+
+		// LIMIT one more row than needed tells whether the table has more
+		query := fmt.Sprintf("SELECT key, value, value_blob, %s FROM %s LIMIT %d", rowTimesColumns, table, remaining+1)
+		iter := session.Query(query).WithContext(ctx).PageSize(compactionPageSize).Iter()
+
+		var (
+			key, value string
+			blob       []byte
+			ttl        int
+			writeTime  int64
+		)
+		for iter.Scan(&key, &value, &blob, &ttl, &writeTime) {
+			if len(keySizes) == opts.SampleRows {
+				report.Complete = false
+				break
+			}
+			keySizes = append(keySizes, len(key))
+			valueSizes = append(valueSizes, len(value)+len(blob))
+
+			if ttl > 0 {
+				report.Expiry.WithTTL++
+			} else {
+				report.Expiry.WithoutTTL++
+				if writeTime < staleBefore {
+					report.Expiry.Stale++
+				}
+			}
+			if oldest == 0 || writeTime < oldest {
+				oldest = writeTime
+			}
+		}
+		if err := iter.Close(); err != nil {
+			// Bucket tables are created as buckets start
+			if store.timeBuckets != nil && isSchemaChangeError(err) {
+				continue
+			}
+			return fmt.Errorf("failed to sample table %s: %w", table, err)
+		}
+	}
+
+	report.SampledRows = int64(len(keySizes))
+	report.KeySize = summarizeSizes(keySizes)
+	report.ValueSize = summarizeSizes(valueSizes)
+	if oldest != 0 {
+		oldestWrite := time.UnixMicro(oldest).UTC()
+		report.Expiry.OldestWrite = &oldestWrite
+	}
+	return nil
+}
+
+// summarizeSizes returns the distribution of sizes, which it sorts.
+func summarizeSizes(sizes []int) SizeSummary {
+	summary := SizeSummary{Histogram: make([]int64, WorkloadSizeBuckets)}
+	if len(sizes) == 0 {
+		return summary
+	}
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		summary.Total += int64(size)
+		summary.Histogram[sizeBucket(size)]++
+	}
+	percentile := func(p int) int { return sizes[(len(sizes)-1)*p/100] }
+	summary.P50 = percentile(50)
+	summary.P90 = percentile(90)
+	summary.P99 = percentile(99)
+	summary.Max = sizes[len(sizes)-1]
+	return summary
+}
+
+// keyGroupHealth checks whether the first key group entries still have a row.
+// Entries younger than keyGroupRepairGrace may belong to writes in flight and
+// are not counted.
+func (store *ScyllaStateStore) keyGroupHealth(ctx context.Context, session *gocql.Session, now time.Time) (*IndexHealth, error) {
+	health := &IndexHealth{}
+	rowQuery := fmt.Sprintf("SELECT key FROM %s WHERE key = ?", store.config.Table)
+
+	iter := session.Query(fmt.Sprintf("SELECT key, indexed_at FROM %s LIMIT %d", store.keyGroupsTable(), compactionKeyGroupSample)).
+		WithContext(ctx).Iter()
+	var (
+		key       string
+		indexedAt time.Time
+	)
+	for iter.Scan(&key, &indexedAt) {
+		if now.Sub(indexedAt) < keyGroupRepairGrace {
+			continue
+		}
+		health.Sampled++
+
+		var found string
+		err := session.Query(rowQuery, store.storageKey(key)).WithContext(ctx).Scan(&found)
+		if errors.Is(err, gocql.ErrNotFound) {
+			health.Orphans++
+		} else if err != nil {
+			iter.Close()
+			return nil, fmt.Errorf("failed to check key group entry %s: %w", key, err)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to sample key group index: %w", err)
+	}
+	return health, nil
+}
+
+// recommend derives the recommendations of a report.
+func (store *ScyllaStateStore) recommend(report *CompactionReport) []Recommendation {
+	recommendations := []Recommendation{}
+	add := func(severity, setting, format string, args ...any) {
+		recommendations = append(recommendations, Recommendation{Severity: severity, Setting: setting, Advice: fmt.Sprintf(format, args...)})
+	}
+	qualified := store.config.Keyspace + "." + store.config.Table
+
+	sampled := float64(report.SampledRows)
+	if sampled > 0 {
+		if share := float64(report.Expiry.Stale) / sampled; share >= staleShareWarning {
+			add(AdviceWarning, "ttlInSeconds",
+				"%.0f%% of sampled rows never expire and were last written over %s ago; write such keys with ttlInSeconds, or delete them with deleteWithPrefix",
+				share*100, report.Expiry.StaleAfter)
+		}
+
+		ttlShare := float64(report.Expiry.WithTTL) / sampled
+		if ttlShare >= ttlShareTimeWindow && report.TableOptions.CompactionClass != "TimeWindowCompactionStrategy" && store.timeBuckets == nil {
+			add(AdviceWarning, "compaction",
+				"%.0f%% of sampled rows expire but %s is compacted with %s, which keeps expired rows until their SSTables are merged; "+
+					"use ALTER TABLE %s WITH compaction = {'class': 'TimeWindowCompactionStrategy'}, or timeBuckets to drop whole tables",
+				ttlShare*100, qualified, report.TableOptions.CompactionClass, qualified)
+		}
+		if ttlShare >= 0.5 && report.TableOptions.GCGraceSeconds >= 864000 {
+			add(AdviceInfo, "gc_grace_seconds",
+				"expired rows are purged %s after they expire (gc_grace_seconds=%d); lower it to match how often the cluster is repaired",
+				time.Duration(report.TableOptions.GCGraceSeconds)*time.Second, report.TableOptions.GCGraceSeconds)
+		}
+		if report.Expiry.WithoutTTL > 0 && report.TableOptions.DefaultTimeToLive == 0 && report.Expiry.Stale > 0 {
+			add(AdviceInfo, "default_time_to_live",
+				"rows written without ttlInSeconds never expire; a table default_time_to_live bounds how long forgotten keys are kept")
+		}
+	}
+
+	if report.ValueSize.P99 >= largeValueBytes {
+		add(AdviceWarning, "value size",
+			"1%% of sampled values are %d bytes or more, which slows compaction and repair; split large values across keys", report.ValueSize.P99)
+	}
+
+	if len(report.ExpiredBuckets) > 0 {
+		add(AdviceWarning, "timeBucketRetention",
+			"time bucket tables past the retention were not dropped: %s; check that background jobs run, as they are dropped by the leader with leaderElection",
+			strings.Join(report.ExpiredBuckets, ", "))
+	}
+
+	if groups := report.KeyGroups; groups != nil && groups.Sampled > 0 {
+		if share := float64(groups.Orphans) / float64(groups.Sampled); share >= orphanShareWarning {
+			add(AdviceWarning, "keyGroups",
+				"%.0f%% of sampled key group entries have no row; they are removed by the keyGroup queries reading their groups, so read groups that are no longer queried once to clean them up",
+				share*100)
+		}
+	}
+
+	if queue := report.SearchIndex; queue != nil && queue.Capacity > 0 {
+		if fill := float64(queue.Queued) / float64(queue.Capacity); fill >= searchQueueWarning {
+			add(AdviceWarning, "searchIndexBatchSize",
+				"the search index queue is %.0f%% full and drops writes once full; raise searchIndexBatchSize or lower searchIndexFlushInterval", fill*100)
+		}
+	}
+
+	if !report.Complete {
+		add(AdviceInfo, "sample",
+			"the report covers the first %d rows in token order; raise the sample size for exact figures", report.SampledRows)
+	}
+	return recommendations
+}
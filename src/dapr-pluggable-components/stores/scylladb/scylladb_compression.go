@@ -0,0 +1,68 @@
+package scylladb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// Component metadata property of a compression level, which no codec takes
+const compressionLevelProperty = "compressionLevel"
+
+var errCompressionLevel = errors.New("compressionLevel is not supported: the CQL transport codecs snappy and lz4 have no level")
+
+// unsupportedCompressors explains the codecs the CQL native protocol or this
+// build cannot offer.
+var unsupportedCompressors = map[string]string{
+	"zstd": "the CQL native protocol only negotiates lz4 and snappy",
+	"lz4":  "it is not built in; register gocql's lz4 compressor (github.com/gocql/gocql/lz4) with RegisterCompressor",
+}
+
+// CompressorFactory builds a gocql compressor.
+type CompressorFactory func() (gocql.Compressor, error)
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]CompressorFactory{
+		"snappy": func() (gocql.Compressor, error) { return &gocql.SnappyCompressor{}, nil },
+		"none":   func() (gocql.Compressor, error) { return nil, nil },
+	}
+)
+
+// RegisterCompressor makes an additional transport compressor (e.g. lz4 or zstd)
+// selectable through the "compression" metadata. It is meant to be called from
+// an init function in a file compiled into the binary, so the default build
+// does not carry the extra codec dependencies.
+func RegisterCompressor(name string, factory CompressorFactory) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[strings.ToLower(name)] = factory
+}
+
+// newCompressor resolves the configured compressor. Codecs that are not
+// registered are reported rather than replaced, so Init fails on them.
+func newCompressor(name string) (gocql.Compressor, error) {
+	compressorsMu.RLock()
+	factory, ok := compressors[strings.ToLower(name)]
+	compressorsMu.RUnlock()
+
+	if !ok {
+		if reason, known := unsupportedCompressors[strings.ToLower(name)]; known {
+			return nil, fmt.Errorf("compressor %q is not available: %s", name, reason)
+		}
+		return nil, fmt.Errorf("compressor %q is not available in this build", name)
+	}
+	return factory()
+}
+
+// checkCompressionLevel fails configurations still setting compressionLevel,
+// rather than ignoring it.
+func checkCompressionLevel(properties map[string]string) error {
+	if _, ok := properties[compressionLevelProperty]; ok {
+		return errCompressionLevel
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package scylladb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewCompressor(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    string
+		wantName string // empty for no compression
+		wantErr  string
+	}{
+		{"snappy", "snappy", "snappy", ""},
+		{"case insensitive", "Snappy", "snappy", ""},
+		{"none", "none", "", ""},
+		{"zstd", "zstd", "", "only negotiates lz4 and snappy"},
+		{"lz4 not registered", "lz4", "", "RegisterCompressor"},
+		{"unknown", "brotli", "", "not available in this build"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressor, err := newCompressor(tt.codec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newCompressor(%q) error = %v, want one containing %q", tt.codec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newCompressor(%q) error = %v", tt.codec, err)
+			}
+			name := ""
+			if compressor != nil {
+				name = compressor.Name()
+			}
+			if name != tt.wantName {
+				t.Errorf("newCompressor(%q) = %q, want %q", tt.codec, name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestCheckCompressionLevel(t *testing.T) {
+	if err := checkCompressionLevel(map[string]string{"compression": "snappy"}); err != nil {
+		t.Errorf("checkCompressionLevel() without a level = %v, want nil", err)
+	}
+	if err := checkCompressionLevel(map[string]string{compressionLevelProperty: "3"}); err != errCompressionLevel {
+		t.Errorf("checkCompressionLevel() with a level = %v, want %v", err, errCompressionLevel)
+	}
+}
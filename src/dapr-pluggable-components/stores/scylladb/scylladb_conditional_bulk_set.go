@@ -0,0 +1,184 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+
+	"nebulagraph/stores/stateext"
+)
+
+// conditionalSet is a BulkSet item carrying an ETag.
+type conditionalSet struct {
+	key        string // Dapr key
+	storageKey string
+	value      string
+	expected   string // ETag of the request
+	etag       string // ETag written when the condition holds
+	ttl        int
+}
+
+// conditionalBulkSet writes the ETag-carrying items of a large BulkSet with
+// lightweight transactions. A conditional batch may only touch one
+// partition and every key is its own partition, so each item is a conditional
+// statement of its own; items are independent and run up to parallelism at a
+// time. Like Set, an item whose key does not exist is
+// written anyway. It returns the items that were applied and the first error.
+func (store *ScyllaStateStore) conditionalBulkSet(ctx context.Context, items []conditionalSet, parallelism int) ([]conditionalSet, error) {
+	// Group by routing key, as conditional batches are single-partition
+	stmts := make([]partitionStatement, len(items))
+	byKey := make(map[string]conditionalSet, len(items))
+	for i, item := range items {
+		stmts[i] = partitionStatement{
+			query:      store.queries.set,
+			args:       []interface{}{item.storageKey, stringToBytes(item.value), item.etag, store.now(), item.ttl},
+			storageKey: item.storageKey,
+		}
+		byKey[item.storageKey] = item
+	}
+	partitions := store.groupByPartition(stmts)
+	store.logger.Debugf("Conditional bulk set of %d keys grouped into %d partitions", len(items), len(partitions))
+
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		applied  []conditionalSet
+		firstErr error
+	)
+	sem := make(chan struct{}, parallelism)
+
+	for _, partition := range partitions {
+		if ctx.Err() != nil {
+			break
+		}
+
+		group := make([]conditionalSet, len(partition))
+		for i, stmt := range partition {
+			group[i] = byKey[stmt.storageKey]
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := store.applyConditionalGroup(ctx, group)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			applied = append(applied, group...)
+		}()
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		return applied, ctx.Err()
+	}
+	return applied, firstErr
+}
+
+// applyConditionalGroup writes the items of one partition in a conditional
+// batch. When the batch is not applied, the current ETags tell a mismatch,
+// which fails the group, from missing keys, which are retried with
+// IF NOT EXISTS once.
+func (store *ScyllaStateStore) applyConditionalGroup(ctx context.Context, group []conditionalSet) error {
+	missing := make(map[string]bool)
+	for round := 1; round <= 2; round++ {
+		applied, err := store.execConditionalGroup(ctx, group, missing)
+		if err != nil {
+			store.logger.Errorf("Failed to execute conditional bulk set batch: %v", err)
+			return fmt.Errorf("conditional bulk set batch failed: %w", err)
+		}
+		if applied {
+			return nil
+		}
+
+		keys := make([]string, len(group))
+		for i, item := range group {
+			keys[i] = item.storageKey
+		}
+		snapshot, err := store.readETagSnapshot(ctx, keys)
+		if err != nil {
+			return fmt.Errorf("failed to read etag snapshot: %w", err)
+		}
+		for _, item := range group {
+			currentEtag, exists := snapshot[item.storageKey]
+			if exists && currentEtag != item.expected {
+				// Carry the current ETag so callers can reconcile without another Get
+				return stateext.NewETagMismatch(
+					fmt.Errorf("etag mismatch for key %s: expected %s, got %s", item.key, item.expected, currentEtag), currentEtag)
+			}
+			missing[item.storageKey] = !exists
+		}
+		store.logger.Debugf("Conditional bulk set batch of %d keys hit missing keys (round %d), retrying", len(group), round)
+	}
+
+	keys := make([]string, len(group))
+	for i, item := range group {
+		keys[i] = item.key
+	}
+	return stateext.NewETagError(state.ETagMismatch,
+		fmt.Errorf("etag mismatch for keys %s: values changed during bulk set", strings.Join(keys, ", ")))
+}
+
+// execConditionalGroup runs one round of a conditional batch. Keys known to
+// be missing are inserted with IF NOT EXISTS, the others updated IF etag = ?.
+// It is not retried: a CAS whose outcome is unknown cannot be repeated
+// safely, so the error is returned to the caller.
+func (store *ScyllaStateStore) execConditionalGroup(ctx context.Context, group []conditionalSet, missing map[string]bool) (bool, error) {
+	insertQuery := fmt.Sprintf("INSERT INTO %s (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?) IF NOT EXISTS USING TTL ?", store.config.Table)
+	updateQuery := fmt.Sprintf("UPDATE %s USING TTL ? SET value_blob = ?, value = null, etag = ?, last_modified = ? WHERE key = ? IF etag = ?", store.config.Table)
+
+	statement := func(item conditionalSet) (string, []any) {
+		if missing[item.storageKey] {
+			return insertQuery, []any{item.storageKey, stringToBytes(item.value), item.etag, store.now(), item.ttl}
+		}
+		return updateQuery, []any{item.ttl, stringToBytes(item.value), item.etag, store.now(), item.storageKey, item.expected}
+	}
+
+	var applied bool
+	err := func() error {
+		if len(group) == 1 {
+			query, args := statement(group[0])
+			stmt, err := store.hookedQuery(ctx, "bulk set", query, args...)
+			if err != nil {
+				return err
+			}
+			applied, err = stmt.MapScanCAS(make(map[string]any))
+			return err
+		}
+
+		batch := store.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		for _, item := range group {
+			query, args := statement(item)
+			if err := store.addHookedBatchEntry(ctx, batch, "bulk set", query, args...); err != nil {
+				return err
+			}
+		}
+		var iter *gocql.Iter
+		var err error
+		applied, iter, err = store.session.MapExecuteBatchCAS(batch, make(map[string]any))
+		if iter != nil {
+			if closeErr := iter.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}()
+	store.observeOverload(err)
+	return applied, err
+}
@@ -0,0 +1,54 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+const (
+	// Request metadata carrying the ETag of a value the caller already holds
+	ifNoneMatchMetadataKey = "ifNoneMatch"
+
+	// Response metadata set when the stored value still has that ETag
+	notModifiedMetadataKey = "notModified"
+)
+
+// notModified serves Gets carrying ifNoneMatch metadata. When the stored ETag
+// still equals the one the caller holds it returns a response without data,
+// flagged notModified, so large values are not transferred again. It reads
+// only the etag column; a nil response means the caller must fetch the value.
+func (store *ScyllaStateStore) notModified(ctx context.Context, req *state.GetRequest, key string) (*state.GetResponse, error) {
+	ifNoneMatch, ok := req.Metadata[ifNoneMatchMetadataKey]
+	if !ok || ifNoneMatch == "" {
+		return nil, nil
+	}
+
+	checkStmt, err := store.hookedQuery(ctx, "get", store.queries.etag, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var etag string
+	err = store.withRetry(ctx, fmt.Sprintf("check etag of key %s", req.Key), func() error {
+		return checkStmt.Scan(&etag)
+	})
+	if err == gocql.ErrNotFound {
+		return &state.GetResponse{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check etag of key %s: %w", req.Key, err)
+	}
+	if etag != ifNoneMatch {
+		return nil, nil
+	}
+
+	store.meterRead(req.Key, 0)
+	store.logger.Debugf("Key %s not modified since etag %s", req.Key, etag)
+	return &state.GetResponse{
+		ETag:     &etag,
+		Metadata: map[string]string{notModifiedMetadataKey: "true"},
+	}, nil
+}
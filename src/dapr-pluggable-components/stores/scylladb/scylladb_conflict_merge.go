@@ -0,0 +1,361 @@
+package scylladb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+
+	"nebulagraph/stores/stateext"
+)
+
+// conflictMerge rule that applies to every key without a rule of its own
+const conflictMergeDefaultRule = "*"
+
+// Read-merge-write rounds before a merge racing with other writers gives up
+const conflictMergeAttempts = 5
+
+// MergeStrategy resolves a conflicting write: when a Set carries an ETag that
+// is no longer current, Merge receives the stored value and the value of the
+// request and returns the value to store instead. An error leaves the
+// conflict unresolved and the Set fails with an ETag mismatch as usual.
+type MergeStrategy interface {
+	Merge(key string, current, incoming []byte) ([]byte, error)
+}
+
+// MergeFunc adapts a function to a MergeStrategy.
+type MergeFunc func(key string, current, incoming []byte) ([]byte, error)
+
+// Merge calls f.
+func (f MergeFunc) Merge(key string, current, incoming []byte) ([]byte, error) {
+	return f(key, current, incoming)
+}
+
+var (
+	mergeStrategiesMu sync.RWMutex
+	mergeStrategies   = map[string]MergeStrategy{
+		"lww":   MergeFunc(mergeLastWriteWins),
+		"max":   MergeFunc(func(_ string, current, incoming []byte) ([]byte, error) { return mergeNumeric(current, incoming, 1) }),
+		"min":   MergeFunc(func(_ string, current, incoming []byte) ([]byte, error) { return mergeNumeric(current, incoming, -1) }),
+		"union": MergeFunc(mergeSetUnion),
+	}
+)
+
+// RegisterMergeStrategy makes an additional merge strategy selectable through
+// the "conflictMerge" metadata. Like RegisterCompressor, it is meant to be
+// called from an init function of a file compiled into the binary.
+func RegisterMergeStrategy(name string, strategy MergeStrategy) {
+	mergeStrategiesMu.Lock()
+	defer mergeStrategiesMu.Unlock()
+	mergeStrategies[strings.ToLower(name)] = strategy
+}
+
+// mergeRule selects the strategy of the keys starting with prefix.
+type mergeRule struct {
+	prefix   string
+	name     string
+	strategy MergeStrategy
+}
+
+// conflictMerger resolves ETag conflicts of Set with the strategy configured
+// for the key's prefix, instead of failing them. Rules are matched on Dapr
+// keys, longest prefix first.
+type conflictMerger struct {
+	rules []mergeRule
+
+	mu     sync.Mutex
+	merged map[string]int64 // per strategy
+	failed map[string]int64 // per strategy, values the strategy could not merge
+
+	mergedTotal atomic.Int64
+	failedTotal atomic.Int64
+}
+
+// parseMergeRules parses rules of the form "<prefix>=<strategy>", separated
+// by commas, where prefix is a key prefix or *.
+func parseMergeRules(spec string) ([]mergeRule, error) {
+	mergeStrategiesMu.RLock()
+	defer mergeStrategiesMu.RUnlock()
+
+	var rules []mergeRule
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		separator := strings.LastIndex(entry, "=")
+		if separator <= 0 {
+			return nil, fmt.Errorf("invalid conflictMerge rule %q, expected <prefix>=<strategy>", entry)
+		}
+		prefix := strings.TrimSpace(entry[:separator])
+		name := strings.ToLower(strings.TrimSpace(entry[separator+1:]))
+		strategy, ok := mergeStrategies[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown merge strategy %q in conflictMerge rule %q", name, entry)
+		}
+		if prefix == "" || seen[prefix] {
+			return nil, fmt.Errorf("invalid conflictMerge rule %q: empty or repeated prefix", entry)
+		}
+		seen[prefix] = true
+		rules = append(rules, mergeRule{prefix: prefix, name: name, strategy: strategy})
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("no conflictMerge rules")
+	}
+
+	// Longest prefix first, the default rule last
+	length := func(rule mergeRule) int {
+		if rule.prefix == conflictMergeDefaultRule {
+			return -1
+		}
+		return len(rule.prefix)
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return length(rules[i]) > length(rules[j]) })
+	return rules, nil
+}
+
+// initConflictMerge parses the merge rules.
+func (store *ScyllaStateStore) initConflictMerge() {
+	rules, err := parseMergeRules(store.config.ConflictMerge)
+	if err != nil {
+		store.logger.Warnf("Invalid conflictMerge: %v, disabling it", err)
+		return
+	}
+	for _, rule := range rules {
+		if store.sidecarEncryption && rule.name != "lww" {
+			store.logger.Warnf("Values are encrypted by the sidecar: conflicts of keys matching %q cannot be merged with %s", rule.prefix, rule.name)
+		}
+	}
+
+	store.merger = &conflictMerger{
+		rules:  rules,
+		merged: make(map[string]int64),
+		failed: make(map[string]int64),
+	}
+	store.logger.Infof("Merging ETag conflicts of Set for %d key prefixes", len(rules))
+}
+
+// ruleFor returns the rule of key, or nil when its conflicts are not merged.
+func (m *conflictMerger) ruleFor(key string) *mergeRule {
+	if m == nil {
+		return nil
+	}
+	for i := range m.rules {
+		if m.rules[i].prefix == conflictMergeDefaultRule || strings.HasPrefix(key, m.rules[i].prefix) {
+			return &m.rules[i]
+		}
+	}
+	return nil
+}
+
+func (m *conflictMerger) record(name string, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ok {
+		m.merged[name]++
+		m.mergedTotal.Add(1)
+	} else {
+		m.failed[name]++
+		m.failedTotal.Add(1)
+	}
+}
+
+// setMerged stores the merge of value and the key's current value after a
+// Set failed its ETag check. Like setMergePatch, the merged value is written
+// with a lightweight transaction conditioned on the ETag it was merged with,
+// and a lost race reads and merges again. A key deleted meanwhile is written
+// with value as is.
+func (store *ScyllaStateStore) setMerged(ctx context.Context, req *state.SetRequest, key, value string, ttl int, rule *mergeRule) error {
+	mismatch := func(currentEtag string, cause error) error {
+		return stateext.NewETagMismatch(
+			fmt.Errorf("etag mismatch for key %s: expected %s, got %s; %s merge failed: %w", req.Key, *req.ETag, currentEtag, rule.name, cause), currentEtag)
+	}
+
+	readQuery := fmt.Sprintf("SELECT value, value_blob, etag FROM %s WHERE key = ?", store.config.Table)
+	insertQuery := fmt.Sprintf("INSERT INTO %s (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?) IF NOT EXISTS USING TTL ?", store.config.Table)
+	updateQuery := fmt.Sprintf("UPDATE %s USING TTL ? SET value_blob = ?, value = null, etag = ?, last_modified = ? WHERE key = ? IF etag = ?", store.config.Table)
+
+	// Record the key before writing so concurrent Gets never see a false negative
+	if store.keyFilter != nil {
+		store.keyFilter.add(key)
+	}
+
+	for attempt := 1; attempt <= conflictMergeAttempts; attempt++ {
+		var text, currentEtag string
+		var blob []byte
+		readStmt, err := store.hookedQuery(ctx, "set", readQuery, key)
+		if err != nil {
+			return err
+		}
+		err = store.withRetry(ctx, fmt.Sprintf("read key %s for conflict merge", req.Key), func() error {
+			return readStmt.Scan(&text, &blob, &currentEtag)
+		})
+		exists := err == nil
+		if err != nil && err != gocql.ErrNotFound {
+			return fmt.Errorf("failed to read key %s for conflict merge: %w", req.Key, err)
+		}
+
+		merged := stringToBytes(value)
+		if exists {
+			if store.sidecarEncryption && rule.name != "lww" {
+				store.merger.record(rule.name, false)
+				return mismatch(currentEtag, errors.New("values are encrypted by the sidecar"))
+			}
+			if merged, err = rule.strategy.Merge(req.Key, storedBytes(text, blob), merged); err != nil {
+				store.merger.record(rule.name, false)
+				return mismatch(currentEtag, err)
+			}
+		}
+
+		etag := store.newETag()
+		var writeStmt *gocql.Query
+		if exists {
+			writeStmt, err = store.hookedQuery(ctx, "set", updateQuery, ttl, merged, etag, store.now(), key, currentEtag)
+		} else {
+			writeStmt, err = store.hookedQuery(ctx, "set", insertQuery, key, merged, etag, store.now(), ttl)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Not retried: a CAS whose outcome is unknown is resolved by the next round's read
+		applied, err := writeStmt.MapScanCAS(make(map[string]any))
+		if err != nil {
+			store.logger.Errorf("Failed to write merged value of key %s: %v", req.Key, err)
+			return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+		}
+		if !applied {
+			store.logger.Debugf("Conflict merge for key %s lost a race (attempt %d), retrying", req.Key, attempt)
+			continue
+		}
+
+		store.merger.record(rule.name, true)
+		mergedValue := bytesToString(merged)
+		store.afterSet(req.Key, key, mergedValue, etag, ttl)
+		store.meterWrite(req.Key, len(merged))
+		store.observeSchema(req.Key, mergedValue)
+		store.verifyWrite(req.Key, key, mergedValue, etag)
+		store.auditSet(req.Key, etag, mergedValue, &priorValue{value: storedBytes(text, blob), exists: exists})
+
+		store.logger.Debugf("Merged conflicting write of key %s with %s", req.Key, rule.name)
+		return nil
+	}
+
+	return fmt.Errorf("failed to merge conflicting write of key %s: value kept changing after %d attempts", req.Key, conflictMergeAttempts)
+}
+
+// mergeLastWriteWins keeps the value of the conflicting write.
+func mergeLastWriteWins(_ string, _, incoming []byte) ([]byte, error) {
+	return incoming, nil
+}
+
+// mergeNumeric keeps the larger (sign 1) or smaller (sign -1) of two JSON
+// numbers, compared exactly.
+func mergeNumeric(current, incoming []byte, sign int) ([]byte, error) {
+	a, err := parseJSONNumber(current)
+	if err != nil {
+		return nil, fmt.Errorf("stored value: %w", err)
+	}
+	b, err := parseJSONNumber(incoming)
+	if err != nil {
+		return nil, fmt.Errorf("new value: %w", err)
+	}
+	if b.Cmp(a)*sign > 0 {
+		return incoming, nil
+	}
+	return current, nil
+}
+
+func parseJSONNumber(data []byte) (*big.Rat, error) {
+	doc, err := decodeJSONDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	number, ok := doc.(json.Number)
+	if !ok {
+		return nil, errors.New("not a JSON number")
+	}
+	value, ok := new(big.Rat).SetString(string(number))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %s", number)
+	}
+	return value, nil
+}
+
+// mergeSetUnion merges two JSON arrays as sets: the stored elements in their
+// order, followed by the new elements not stored yet. Elements are compared
+// by their JSON encoding, with object members sorted.
+func mergeSetUnion(_ string, current, incoming []byte) ([]byte, error) {
+	stored, err := decodeJSONArray(current)
+	if err != nil {
+		return nil, fmt.Errorf("stored value: %w", err)
+	}
+	added, err := decodeJSONArray(incoming)
+	if err != nil {
+		return nil, fmt.Errorf("new value: %w", err)
+	}
+
+	seen := make(map[string]bool, len(stored)+len(added))
+	union := make([]any, 0, len(stored)+len(added))
+	for _, elements := range [][]any{stored, added} {
+		for _, element := range elements {
+			encoded, err := json.Marshal(element)
+			if err != nil {
+				return nil, err
+			}
+			if seen[string(encoded)] {
+				continue
+			}
+			seen[string(encoded)] = true
+			union = append(union, element)
+		}
+	}
+	return json.Marshal(union)
+}
+
+func decodeJSONArray(data []byte) ([]any, error) {
+	doc, err := decodeJSONDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	array, ok := doc.([]any)
+	if !ok {
+		return nil, errors.New("not a JSON array")
+	}
+	return array, nil
+}
+
+func (m *conflictMerger) gauges() map[string]float64 {
+	return map[string]float64{
+		"conflict_merges_total":         float64(m.mergedTotal.Load()),
+		"conflict_merge_failures_total": float64(m.failedTotal.Load()),
+	}
+}
+
+func (m *conflictMerger) diagnostics() map[string]any {
+	rules := make(map[string]string, len(m.rules))
+	for _, rule := range m.rules {
+		rules[rule.prefix] = rule.name
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	merged := make(map[string]int64, len(m.merged))
+	for name, count := range m.merged {
+		merged[name] = count
+	}
+	failed := make(map[string]int64, len(m.failed))
+	for name, count := range m.failed {
+		failed[name] = count
+	}
+	return map[string]any{"rules": rules, "merged": merged, "failed": failed}
+}
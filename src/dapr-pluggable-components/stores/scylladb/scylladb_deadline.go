@@ -0,0 +1,123 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// deadlineStatement is the variant of a prepared statement that carries a
+// server-side timeout as its first or last bind value.
+type deadlineStatement struct {
+	query        *gocql.Query
+	timeoutFirst bool
+}
+
+// deadlinePropagation bounds the server-side timeout of Get, Set and Delete
+// statements by the caller's remaining deadline, using ScyllaDB's USING
+// TIMEOUT. Without it a coordinator keeps working on a request the sidecar
+// has already abandoned until the cluster's default timeout expires.
+//
+// Requests without a deadline use the plain statements, so the server
+// defaults still apply to them.
+type deadlinePropagation struct {
+	// Time left for the network and the driver after the server gives up
+	margin time.Duration
+	// Upper bound for the server-side timeout: the driver gives up after it
+	max time.Duration
+	// Variants of the prepared statements, rebuilt with every session
+	statements atomic.Pointer[map[*gocql.Query]deadlineStatement]
+
+	bound   atomic.Int64 // statements sent with a deadline-derived timeout
+	expired atomic.Int64 // requests failed before reaching the cluster
+}
+
+// initDeadlinePropagation parses the deadline settings.
+func (store *ScyllaStateStore) initDeadlinePropagation(clientTimeout time.Duration) {
+	margin, err := time.ParseDuration(store.config.DeadlineMargin)
+	if err != nil || margin < 0 {
+		store.logger.Warnf("Invalid deadlineMargin: %s, using default", store.config.DeadlineMargin)
+		margin = 10 * time.Millisecond
+	}
+
+	store.deadlines = &deadlinePropagation{margin: margin, max: clientTimeout}
+	store.logger.Infof("Propagating request deadlines to ScyllaDB (margin=%v)", margin)
+}
+
+// prepareDeadlineStatements builds the USING TIMEOUT variants of the Get, Set
+// and Delete statements.
+func (store *ScyllaStateStore) prepareDeadlineStatements(session *gocql.Session, getQuery, setQuery string) {
+	if store.deadlines == nil {
+		return
+	}
+
+	variant := func(query string) *gocql.Query {
+		return session.Query(query).Consistency(store.cluster.Consistency).
+			Idempotent(true).SetSpeculativeExecutionPolicy(store.speculative)
+	}
+	statements := map[*gocql.Query]deadlineStatement{
+		store.getStmt: {query: variant(getQuery + " USING TIMEOUT ?")},
+		store.setStmt: {query: variant(strings.Replace(setQuery, "USING TTL ?", "USING TTL ? AND TIMEOUT ?", 1))},
+		store.deleteStmt: {
+			query:        variant(fmt.Sprintf("DELETE FROM %s USING TIMEOUT ? WHERE key = ?", store.config.Table)),
+			timeoutFirst: true,
+		},
+	}
+	store.deadlines.statements.Store(&statements)
+}
+
+// withDeadline returns the statement and values to execute prepared with
+// under ctx: the USING TIMEOUT variant when ctx has a deadline, otherwise
+// prepared itself. A deadline that leaves no time for the server fails
+// without contacting the cluster.
+func (store *ScyllaStateStore) withDeadline(ctx context.Context, operation string, prepared *gocql.Query, values []any) (*gocql.Query, []any, error) {
+	d := store.deadlines
+	if d == nil {
+		return prepared, values, nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return prepared, values, nil
+	}
+	statements := d.statements.Load()
+	if statements == nil {
+		return prepared, values, nil
+	}
+	variant, ok := (*statements)[prepared]
+	if !ok {
+		return prepared, values, nil
+	}
+
+	// ScyllaDB timeouts have millisecond resolution
+	budget := (time.Until(deadline) - d.margin).Truncate(time.Millisecond)
+	if budget <= 0 {
+		d.expired.Add(1)
+		return nil, nil, fmt.Errorf("request deadline leaves no time for %s: %w", operation, context.DeadlineExceeded)
+	}
+	if d.max > 0 && budget > d.max {
+		budget = d.max
+	}
+	d.bound.Add(1)
+
+	bound := make([]any, 0, len(values)+1)
+	if variant.timeoutFirst {
+		bound = append(bound, budget)
+		bound = append(bound, values...)
+	} else {
+		bound = append(bound, values...)
+		bound = append(bound, budget)
+	}
+	return variant.query, bound, nil
+}
+
+func (d *deadlinePropagation) diagnostics() map[string]any {
+	return map[string]any{
+		"margin":  d.margin.String(),
+		"bound":   d.bound.Load(),
+		"expired": d.expired.Load(),
+	}
+}
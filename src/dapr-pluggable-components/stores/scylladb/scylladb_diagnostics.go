@@ -0,0 +1,204 @@
+package scylladb
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Diagnostics returns a snapshot of the store's internal state for the
+// diagnostics endpoint: connection pool, caches and background workers, and
+// the configuration with secrets redacted.
+func (store *ScyllaStateStore) Diagnostics() map[string]any {
+	store.mu.RLock()
+	closed := store.closed
+	connected := store.session != nil
+	config := store.config
+	numConns := 0
+	if store.cluster != nil {
+		numConns = store.cluster.NumConns
+	}
+	store.mu.RUnlock()
+
+	availableHosts, knownHosts := store.AvailableHosts()
+	diagnostics := map[string]any{
+		"closed":            closed,
+		"connected":         connected,
+		"degraded":          store.Degraded(),
+		"sidecarEncryption": store.sidecarEncryption,
+		"pool": map[string]any{
+			"availableHosts":     availableHosts,
+			"knownHosts":         knownHosts,
+			"connectionsPerHost": numConns,
+		},
+		"unpreparedRetries": store.unpreparedRetries.Load(),
+		"forcedWrites":      store.forcedWrites.Load(),
+		"priorities":        store.priorityDiagnostics(),
+		"bulkGet": map[string]any{
+			"maxBytes":  store.bulkGetMaxBytes,
+			"peakBytes": store.bulkGetPeakBytes.Load(),
+		},
+		"config": redactedConfig(config),
+	}
+
+	if filter := store.keyFilter; filter != nil {
+		filter.mu.RLock()
+		bloom := map[string]any{"ready": filter.current != nil, "keys": filter.lastSize}
+		if filter.current != nil {
+			bloom["sizeBytes"] = filter.current.sizeBytes()
+		}
+		filter.mu.RUnlock()
+		diagnostics["bloomFilter"] = bloom
+	}
+
+	if deadlines := store.deadlines; deadlines != nil {
+		diagnostics["deadlinePropagation"] = deadlines.diagnostics()
+	}
+
+	if quotas := store.quotas; quotas != nil {
+		diagnostics["quotas"] = quotas.diagnostics()
+	}
+
+	if gauges := store.statsGauges; gauges != nil {
+		diagnostics["statsGauges"] = gauges.diagnostics()
+	}
+
+	if masker := store.masker; masker != nil {
+		diagnostics["masking"] = masker.diagnostics()
+	}
+
+	if merger := store.merger; merger != nil {
+		diagnostics["conflictMerge"] = merger.diagnostics()
+	}
+
+	if watch := store.hostWatch; watch != nil {
+		diagnostics["hostDiscovery"] = watch.diagnostics()
+	}
+
+	if mirror := store.clusterMirror; mirror != nil {
+		diagnostics["clusterMirror"] = mirror.diagnostics()
+	}
+
+	if clock := store.writeClock; clock != nil {
+		diagnostics["writeTimestamps"] = clock.diagnostics()
+	}
+
+	if flights := store.getFlights; flights != nil {
+		diagnostics["getDeduplication"] = flights.diagnostics()
+	}
+
+	if locks := store.keyLocks; locks != nil {
+		diagnostics["keyLocking"] = locks.diagnostics()
+	}
+
+	if tune := store.autotune; tune != nil {
+		diagnostics["poolAutotune"] = tune.diagnostics()
+	}
+
+	if buckets := store.timeBuckets; buckets != nil {
+		diagnostics["timeBuckets"] = buckets.diagnostics(config.Table, store.now())
+	}
+
+	if chunking := store.txChunking; chunking != nil {
+		diagnostics["transactionChunking"] = chunking.diagnostics()
+	}
+
+	if detector := store.overload; detector != nil {
+		diagnostics["overload"] = detector.diagnostics()
+	}
+
+	if stale := store.staleCache; stale != nil {
+		diagnostics["staleCache"] = stale.diagnostics()
+	}
+
+	if groups := store.keyGroups; groups != nil {
+		diagnostics["keyGroups"] = groups.diagnostics()
+	}
+
+	if feed := store.changeFeed; feed != nil {
+		diagnostics["changeFeed"] = feed.diagnostics()
+	}
+
+	if pinner := store.actorPinner; pinner != nil {
+		diagnostics["actorPinning"] = pinner.diagnostics()
+	}
+
+	if cache := store.queryCache; cache != nil {
+		cache.mu.Lock()
+		entries := len(cache.entries)
+		cache.mu.Unlock()
+		diagnostics["queryCache"] = map[string]any{"entries": entries, "maxEntries": cache.maxEntries}
+	}
+
+	if searchIndex := store.searchIndex; searchIndex != nil {
+		entry := map[string]any{"queued": len(searchIndex.queue), "queueCapacity": cap(searchIndex.queue)}
+		if searchIndex.verifier != nil {
+			entry["verify"] = searchIndex.verifier.diagnostics()
+		}
+		diagnostics["searchIndex"] = entry
+	}
+
+	if verifier := store.verifier; verifier != nil {
+		diagnostics["writeVerification"] = map[string]any{
+			"verified":   verifier.verified.Load(),
+			"mismatches": verifier.mismatches.Load(),
+		}
+	}
+
+	if tracker := store.hotKeys; tracker != nil {
+		diagnostics["hotKeys"] = tracker.diagnostics()
+	}
+
+	if coalescer := store.coalescer; coalescer != nil {
+		diagnostics["writeCoalescing"] = coalescer.diagnostics()
+	}
+
+	if auditor := store.auditor; auditor != nil {
+		diagnostics["writeAudit"] = auditor.diagnostics()
+	}
+
+	if usage := store.usage; usage != nil {
+		usage.mu.Lock()
+		buckets := len(usage.buckets)
+		usage.mu.Unlock()
+		diagnostics["usageMetering"] = map[string]any{"buckets": buckets}
+	}
+
+	if schemas := store.schemas; schemas != nil {
+		schemas.mu.Lock()
+		buckets := len(schemas.schemas)
+		schemas.mu.Unlock()
+		diagnostics["schemaSampling"] = map[string]any{"buckets": buckets, "samplePercent": schemas.samplePercent}
+	}
+
+	if migration := store.blobMigration; migration != nil {
+		diagnostics["blobMigration"] = map[string]any{"leader": migration.job.leader.Load()}
+	}
+
+	if reloader := store.tlsReloader; reloader != nil {
+		diagnostics["tls"] = map[string]any{"hostVerification": reloader.hostVerification, "reloadInterval": reloader.interval.String()}
+	}
+
+	if election := store.leaderElection; election != nil {
+		diagnostics["leaderElection"] = map[string]any{"holder": election.holder, "leaseDuration": election.duration.String()}
+	}
+
+	return diagnostics
+}
+
+// redactedConfig returns the component configuration with every password and
+// token replaced by a placeholder.
+func redactedConfig(config ScyllaConfig) map[string]any {
+	var fields map[string]any
+	encoded, err := json.Marshal(config)
+	if err != nil || json.Unmarshal(encoded, &fields) != nil {
+		return nil
+	}
+
+	for name, value := range fields {
+		lower := strings.ToLower(name)
+		if (strings.Contains(lower, "password") || strings.HasSuffix(lower, "token") || strings.HasSuffix(lower, "hashkey")) && value != "" {
+			fields[name] = "<redacted>"
+		}
+	}
+	return fields
+}
@@ -0,0 +1,72 @@
+package scylladb
+
+import (
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+	"github.com/gocql/gocql"
+)
+
+// CQL dialects the store can talk to
+const (
+	dialectScylla    = "scylla"
+	dialectCassandra = "cassandra"
+)
+
+// NewCassandraStateStore creates a store that defaults to the Cassandra
+// dialect, for registration as cassandra-state. A dialect set in the
+// component metadata still takes precedence.
+func NewCassandraStateStore(inputLogger logger.Logger) state.Store {
+	if inputLogger == nil {
+		inputLogger = logger.NewLogger("cassandra-state")
+	}
+	return &ScyllaStateStore{
+		logger:         inputLogger,
+		defaultDialect: dialectCassandra,
+	}
+}
+
+// resolveDialect settles the dialect and, for Cassandra, the defaults and
+// options that differ from ScyllaDB. It runs before the other defaults are
+// applied.
+func (store *ScyllaStateStore) resolveDialect() {
+	if store.config.Dialect == "" {
+		store.config.Dialect = store.defaultDialect
+	}
+	switch store.config.Dialect {
+	case dialectScylla, dialectCassandra:
+	case "":
+		store.config.Dialect = dialectScylla
+	default:
+		store.logger.Warnf("Invalid dialect: %s, using default", store.config.Dialect)
+		store.config.Dialect = store.defaultDialect
+		if store.config.Dialect == "" {
+			store.config.Dialect = dialectScylla
+		}
+	}
+	if store.config.Dialect != dialectCassandra {
+		return
+	}
+
+	// Cassandra 5 dropped snappy, and lz4 is not built in (see
+	// RegisterCompressor), so Cassandra connections are uncompressed by default
+	if store.config.Compression == "" {
+		store.config.Compression = "none"
+	}
+	// USING TIMEOUT is a ScyllaDB extension
+	if store.config.DeadlinePropagation == "true" {
+		store.logger.Warnf("deadlinePropagation is not supported with dialect=cassandra, disabling it")
+		store.config.DeadlinePropagation = "false"
+	}
+	store.logger.Infof("Using the Cassandra dialect")
+}
+
+// applyDialect turns off the ScyllaDB-specific driver optimizations for
+// Cassandra: the shard-aware port does not exist there, and write coalescing
+// is tuned for ScyllaDB's shard-per-core connections.
+func (store *ScyllaStateStore) applyDialect(cluster *gocql.ClusterConfig) {
+	if store.config.Dialect != dialectCassandra {
+		return
+	}
+	cluster.DisableShardAwarePort = true
+	cluster.WriteCoalesceWaitTime = 0
+}
@@ -0,0 +1,18 @@
+package scylladb
+
+import "strconv"
+
+// Request metadata key overriding the component-level dryRun setting
+const dryRunMetadataKey = "dryRun"
+
+// isDryRun reports whether destructive operations should only be logged.
+// Request metadata takes precedence over the component-level dryRun setting.
+func (store *ScyllaStateStore) isDryRun(metadata map[string]string) bool {
+	if value, ok := metadata[dryRunMetadataKey]; ok {
+		if dryRun, err := strconv.ParseBool(value); err == nil {
+			return dryRun
+		}
+		store.logger.Warnf("Invalid %s request metadata: %s, using component setting", dryRunMetadataKey, value)
+	}
+	return store.dryRun
+}
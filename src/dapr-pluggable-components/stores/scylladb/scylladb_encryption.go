@@ -0,0 +1,24 @@
+package scylladb
+
+// Component metadata key the Dapr sidecar reads to enable automatic state
+// encryption. The sidecar encrypts values before they reach the component and
+// decrypts them on Get and BulkGet; the component only has to store the
+// ciphertext (base64 followed by the name of the key that encrypted it)
+// byte for byte.
+const primaryEncryptionKeyMetadata = "primaryEncryptionKey"
+
+// initSidecarEncryption detects automatic state encryption and turns off the
+// features that would operate on ciphertext.
+func (store *ScyllaStateStore) initSidecarEncryption(properties map[string]string) {
+	if properties[primaryEncryptionKeyMetadata] == "" {
+		return
+	}
+
+	store.sidecarEncryption = true
+	store.logger.Info("Dapr state encryption enabled for this component: values are stored as ciphertext")
+
+	if store.config.SearchIndexURL != "" {
+		store.logger.Warn("searchIndexUrl ignored: values encrypted by the Dapr sidecar cannot be indexed")
+		store.config.SearchIndexURL = ""
+	}
+}
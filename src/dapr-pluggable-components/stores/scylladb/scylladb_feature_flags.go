@@ -0,0 +1,75 @@
+package scylladb
+
+import (
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Features that can be rolled out gradually with featureRollout
+const (
+	featureStrictValues = "strictValues"
+	featureQueryCache   = "queryCache"
+	featureVerifyWrites = "verifyWrites"
+)
+
+// Environment variable overriding the featureRollout metadata, so a rollout can
+// be widened or rolled back without editing the component
+const featureRolloutEnv = "SCYLLADB_FEATURE_ROLLOUT"
+
+// initFeatureRollout parses rollout percentages such as
+// "strictValues=10,queryCache=50". A feature still has to be enabled by its own
+// setting; the rollout limits it to that share of keys.
+func (store *ScyllaStateStore) initFeatureRollout() {
+	spec := store.config.FeatureRollout
+	if env := os.Getenv(featureRolloutEnv); env != "" {
+		store.logger.Infof("Using feature rollout from %s", featureRolloutEnv)
+		spec = env
+	}
+
+	rollout := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		feature, value, ok := strings.Cut(entry, "=")
+		feature = strings.TrimSpace(feature)
+		percent, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || percent < 0 || percent > 100 {
+			store.logger.Warnf("Invalid featureRollout entry: %s, ignoring", entry)
+			continue
+		}
+		switch feature {
+		case featureStrictValues, featureQueryCache, featureVerifyWrites:
+			rollout[feature] = percent
+		default:
+			store.logger.Warnf("Unknown feature in featureRollout: %s, ignoring", feature)
+		}
+	}
+	if len(rollout) == 0 {
+		return
+	}
+
+	store.rollout = rollout
+	store.logger.Infof("Feature rollout enabled (%v)", rollout)
+}
+
+// featureEnabled reports whether feature applies to key. Keys are assigned to
+// rollout buckets by hash, so a key keeps the same behavior while the
+// percentage stays the same and stays enabled when it is raised. Features
+// without a rollout entry apply to every key.
+func (store *ScyllaStateStore) featureEnabled(feature, key string) bool {
+	percent, ok := store.rollout[feature]
+	if !ok {
+		return true
+	}
+
+	// Hash the feature name too, so each feature is rolled out to different keys
+	hash := fnv.New32a()
+	hash.Write([]byte(feature))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key))
+	return float64(hash.Sum32()%10000) < percent*100
+}
@@ -0,0 +1,92 @@
+package scylladb
+
+import (
+	"errors"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// Request metadata key skipping the ETag checks of a write
+const forceWriteMetadataKey = "forceWrite"
+
+var errForceWriteToken = errors.New("forceWrite requires a valid adminToken")
+
+// forceWrite reports whether a write of key skips its ETag checks, for
+// disaster recovery where values have to be restored whatever their current
+// ETag. A write is forced when any of metadata sets forceWrite=true, which
+// also has to carry the adminToken configured on the component. Every forced
+// write is logged for audit.
+//
+// Only concurrency checks are skipped: ignoreNotFound, quotas and dry runs
+// apply as usual.
+func (store *ScyllaStateStore) forceWrite(operation, key string, etag *string, metadata ...map[string]string) (bool, error) {
+	for _, md := range metadata {
+		if md[forceWriteMetadataKey] != "true" {
+			continue
+		}
+		if !store.validAdminToken(md) {
+			store.logger.Warnf("Rejected forceWrite %s of key %s without a valid adminToken", operation, key)
+			return false, errForceWriteToken
+		}
+
+		expected := "none"
+		if etag != nil {
+			expected = *etag
+		}
+		store.forcedWrites.Add(1)
+		store.logger.Warnf("AUDIT: forceWrite %s of key %s skips ETag checks (request ETag: %s)", operation, key, expected)
+		return true, nil
+	}
+	return false, nil
+}
+
+// forceBulkDelete returns req with the ETags of forced deletes removed, so the
+// batched BulkDelete checks only the others.
+func (store *ScyllaStateStore) forceBulkDelete(req []state.DeleteRequest) ([]state.DeleteRequest, error) {
+	var forced []state.DeleteRequest
+	for i := range req {
+		force, err := store.forceWrite("bulk delete", req[i].Key, req[i].ETag, req[i].Metadata)
+		if err != nil {
+			return nil, err
+		}
+		if !force {
+			continue
+		}
+		if forced == nil {
+			// Copy before modifying the caller's requests
+			forced = append([]state.DeleteRequest(nil), req...)
+		}
+		forced[i].ETag = nil
+	}
+	if forced == nil {
+		return req, nil
+	}
+	return forced, nil
+}
+
+// forceBulkSet returns req with the ETags of forced sets removed, so the
+// batched BulkSet writes them unconditionally.
+func (store *ScyllaStateStore) forceBulkSet(req []state.SetRequest) ([]state.SetRequest, error) {
+	var forced []state.SetRequest
+	for i := range req {
+		if req[i].ETag == nil {
+			continue
+		}
+		force, err := store.forceWrite("bulk set", req[i].Key, req[i].ETag, req[i].Metadata)
+		if err != nil {
+			return nil, err
+		}
+		if !force {
+			continue
+		}
+		if forced == nil {
+			// Copy before modifying the caller's requests
+			forced = append([]state.SetRequest(nil), req...)
+		}
+		forced[i].ETag = nil
+	}
+	if forced == nil {
+		return req, nil
+	}
+	return forced, nil
+}
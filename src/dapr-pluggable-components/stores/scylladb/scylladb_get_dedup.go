@@ -0,0 +1,119 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// storedRow is the part of a state row returned by Get.
+type storedRow struct {
+	text string
+	blob []byte
+	etag string
+	// Read with ttlMetadata only
+	times rowTimes
+}
+
+// getFlight is one backend read shared by concurrent Gets of a key.
+type getFlight struct {
+	done chan struct{}
+	row  storedRow
+	err  error
+}
+
+// getDeduplicator collapses concurrent Gets of the same key into a single
+// backend read whose result is shared, like singleflight. Actor reactivation
+// storms issue hundreds of identical reads within milliseconds; only the first
+// reaches ScyllaDB. A Get arriving after the read completed starts a new one,
+// so results are never older than the read in flight when the Get started.
+type getDeduplicator struct {
+	mu      sync.Mutex
+	flights map[string]*getFlight
+
+	reads  atomic.Int64 // backend reads started
+	shared atomic.Int64 // Gets answered by another Get's read
+}
+
+func newGetDeduplicator() *getDeduplicator {
+	return &getDeduplicator{flights: make(map[string]*getFlight)}
+}
+
+// do runs read for key unless a read of key is already in flight, and waits
+// for the result. The read runs without ctx's cancellation, so a caller that
+// gives up does not fail the others; each caller stops waiting on its own ctx.
+func (d *getDeduplicator) do(ctx context.Context, key string, read func(context.Context) (storedRow, error)) (storedRow, error) {
+	d.mu.Lock()
+	flight, inFlight := d.flights[key]
+	if !inFlight {
+		flight = &getFlight{done: make(chan struct{})}
+		d.flights[key] = flight
+	}
+	d.mu.Unlock()
+
+	if inFlight {
+		d.shared.Add(1)
+	} else {
+		d.reads.Add(1)
+		go func() {
+			flight.row, flight.err = read(context.WithoutCancel(ctx))
+
+			d.mu.Lock()
+			delete(d.flights, key)
+			d.mu.Unlock()
+			close(flight.done)
+		}()
+	}
+
+	select {
+	case <-flight.done:
+		return flight.row, flight.err
+	case <-ctx.Done():
+		return storedRow{}, ctx.Err()
+	}
+}
+
+func (d *getDeduplicator) diagnostics() map[string]any {
+	d.mu.Lock()
+	inFlight := len(d.flights)
+	d.mu.Unlock()
+
+	return map[string]any{
+		"reads":    d.reads.Load(),
+		"shared":   d.shared.Load(),
+		"inFlight": inFlight,
+	}
+}
+
+// readRow reads the value and ETag stored under key, sharing the read with
+// concurrent Gets of the same key when getDeduplication is enabled.
+func (store *ScyllaStateStore) readRow(ctx context.Context, daprKey, key string) (storedRow, error) {
+	if store.getFlights == nil {
+		return store.queryRow(ctx, daprKey, key)
+	}
+	return store.getFlights.do(ctx, key, func(ctx context.Context) (storedRow, error) {
+		return store.queryRow(ctx, daprKey, key)
+	})
+}
+
+func (store *ScyllaStateStore) queryRow(ctx context.Context, daprKey, key string) (storedRow, error) {
+	var row storedRow
+	var lastModified time.Time
+
+	// Use prepared statement with context (benchmark best practice)
+	stmt, err := store.hookedStatement(ctx, "get", store.getStmt, key)
+	if err != nil {
+		return row, err
+	}
+
+	// Execute with retry logic for resilience
+	err = store.withRetry(ctx, fmt.Sprintf("get key %s", daprKey), func() error {
+		if store.rowTimesEnabled() {
+			return stmt.Scan(&row.text, &row.blob, &row.etag, &lastModified, &row.times.ttl, &row.times.writeTime)
+		}
+		return stmt.Scan(&row.text, &row.blob, &row.etag, &lastModified)
+	})
+	return row, err
+}
@@ -0,0 +1,91 @@
+package scylladb
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"nebulagraph/stores/discovery"
+)
+
+// hostWatch follows the hosts named by a DNS SRV record or a Kubernetes
+// service. The driver discovers the rest of the ring from any node it reaches,
+// so the resolved hosts serve as contact points: they are used for every new
+// session, and when none of the previous hosts remains the session is rebuilt
+// on the new ones.
+type hostWatch struct {
+	resolver discovery.Resolver
+	interval time.Duration
+
+	mu        sync.Mutex
+	hosts     []string
+	changes   int64
+	changedAt time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// watchHosts starts following the hosts of resolver, initially hosts.
+func (store *ScyllaStateStore) watchHosts(resolver discovery.Resolver, hosts []string) {
+	interval, err := time.ParseDuration(store.config.HostDiscoveryInterval)
+	if err != nil || interval <= 0 {
+		store.logger.Warnf("Invalid hostDiscoveryInterval: %s, using default", store.config.HostDiscoveryInterval)
+		interval = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watch := &hostWatch{
+		resolver:  resolver,
+		interval:  interval,
+		hosts:     hosts,
+		changedAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	store.hostWatch = watch
+
+	store.logger.Infof("Following the hosts of %s (interval=%v)", resolver, interval)
+
+	go func() {
+		defer close(watch.done)
+		discovery.Watch(ctx, resolver, hosts, interval, store.logger, func(hosts []string) {
+			watch.mu.Lock()
+			previous := watch.hosts
+			watch.hosts = hosts
+			watch.changes++
+			watch.changedAt = time.Now()
+			watch.mu.Unlock()
+
+			store.mu.Lock()
+			store.cluster.Hosts = hosts
+			store.mu.Unlock()
+
+			if slices.ContainsFunc(previous, func(host string) bool { return slices.Contains(hosts, host) }) {
+				return
+			}
+			// The driver only knows the nodes it was connected to
+			if err := store.refreshSession(); err != nil {
+				store.logger.Errorf("Failed to rebuild the session on the new hosts %v: %v", hosts, err)
+			}
+		})
+	}()
+}
+
+func (w *hostWatch) stop() {
+	w.cancel()
+	<-w.done
+}
+
+func (w *hostWatch) diagnostics() map[string]any {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return map[string]any{
+		"spec":      w.resolver.String(),
+		"interval":  w.interval.String(),
+		"hosts":     slices.Clone(w.hosts),
+		"changes":   w.changes,
+		"changedAt": w.changedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,171 @@
+package scylladb
+
+import (
+	"sync"
+
+	"github.com/dapr/kit/logger"
+	"github.com/gocql/gocql"
+)
+
+// hostTracker follows node up/down and connection events reported by the
+// driver, so readiness reflects actual cluster reachability rather than the
+// outcome of the last operation.
+//
+// Each session gets its own host selection policy from wrap, which the driver
+// notifies of every topology and status change. The tracker reports the hosts
+// of the session in use, so a session being built for a refresh does not
+// disturb the one serving requests. It doubles as the connect observer.
+type hostTracker struct {
+	mu     sync.Mutex
+	active *hostSet // hosts of the session in use (see activate)
+	logger logger.Logger
+}
+
+// hostSet holds the hosts one session was told about.
+type hostSet struct {
+	hosts  map[string]bool     // host address -> up
+	tokens map[string][]string // host address -> owned tokens
+	// Token ring built from tokens, rebuilt after they change (see tokenRing)
+	ring *tokenRing
+}
+
+// trackedPolicy is the host selection policy of one session. It records the
+// session's host events in its own hostSet and forwards them to the wrapped
+// policy.
+type trackedPolicy struct {
+	gocql.HostSelectionPolicy
+
+	tracker *hostTracker
+	set     *hostSet
+}
+
+func newHostTracker(log logger.Logger) *hostTracker {
+	return &hostTracker{active: newHostSet(), logger: log}
+}
+
+func newHostSet() *hostSet {
+	return &hostSet{
+		hosts:  make(map[string]bool),
+		tokens: make(map[string][]string),
+	}
+}
+
+// wrap returns the policy of a new session, delegating host selection to
+// policy. A fresh policy is needed per session, so this is called before each
+// CreateSession; the session's hosts are reported once it is activated.
+func (t *hostTracker) wrap(policy gocql.HostSelectionPolicy) gocql.HostSelectionPolicy {
+	return &trackedPolicy{HostSelectionPolicy: policy, tracker: t, set: newHostSet()}
+}
+
+// activate makes the hosts of a session's policy the ones the tracker reports.
+func (t *hostTracker) activate(policy *trackedPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active = policy.set
+}
+
+func (t *hostTracker) setState(set *hostSet, host *gocql.HostInfo, up, known bool, event string) {
+	address := host.ConnectAddressAndPort()
+
+	t.mu.Lock()
+	wasUp, existed := set.hosts[address]
+	if known {
+		set.hosts[address] = up
+		if tokens := host.Tokens(); len(tokens) > 0 {
+			set.tokens[address] = tokens
+			set.ring = nil
+		}
+	} else {
+		delete(set.hosts, address)
+		delete(set.tokens, address)
+		set.ring = nil
+	}
+	available, total := set.count()
+	t.mu.Unlock()
+
+	if existed == known && wasUp == up {
+		return
+	}
+	if known && !up {
+		t.logger.Warnf("ScyllaDB host %s %s (%d/%d hosts available)", address, event, available, total)
+	} else {
+		t.logger.Infof("ScyllaDB host %s %s (%d/%d hosts available)", address, event, available, total)
+	}
+}
+
+// count returns the number of hosts up and known. Callers must hold the
+// tracker's lock.
+func (s *hostSet) count() (int, int) {
+	available := 0
+	for _, up := range s.hosts {
+		if up {
+			available++
+		}
+	}
+	return available, len(s.hosts)
+}
+
+// AddHost records a host joining the cluster.
+func (p *trackedPolicy) AddHost(host *gocql.HostInfo) {
+	p.tracker.setState(p.set, host, host.IsUp(), true, "added")
+	p.HostSelectionPolicy.AddHost(host)
+}
+
+// RemoveHost records a host leaving the cluster.
+func (p *trackedPolicy) RemoveHost(host *gocql.HostInfo) {
+	p.tracker.setState(p.set, host, false, false, "removed")
+	p.HostSelectionPolicy.RemoveHost(host)
+}
+
+// HostUp records a host becoming reachable.
+func (p *trackedPolicy) HostUp(host *gocql.HostInfo) {
+	p.tracker.setState(p.set, host, true, true, "up")
+	p.HostSelectionPolicy.HostUp(host)
+}
+
+// HostDown records a host becoming unreachable.
+func (p *trackedPolicy) HostDown(host *gocql.HostInfo) {
+	p.tracker.setState(p.set, host, false, true, "down")
+	p.HostSelectionPolicy.HostDown(host)
+}
+
+// ObserveConnect logs failed connection attempts, which precede a host being
+// marked down.
+func (t *hostTracker) ObserveConnect(observed gocql.ObservedConnect) {
+	if observed.Err != nil && observed.Host != nil {
+		t.logger.Warnf("Connection to ScyllaDB host %s failed after %v: %v",
+			observed.Host.ConnectAddressAndPort(), observed.End.Sub(observed.Start), observed.Err)
+	}
+}
+
+// available returns the number of hosts currently up and the number known.
+func (t *hostTracker) available() (int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active.count()
+}
+
+// AvailableHosts returns the number of ScyllaDB hosts currently up and the
+// number of hosts known to the driver.
+func (store *ScyllaStateStore) AvailableHosts() (int, int) {
+	if store.hosts == nil {
+		return 0, 0
+	}
+	return store.hosts.available()
+}
+
+// Ready reports whether the store is open and at least one ScyllaDB host is
+// reachable. The pluggable component SDK answers health pings itself, so hosts
+// that want readiness to follow cluster reachability can poll this method.
+func (store *ScyllaStateStore) Ready() bool {
+	store.mu.RLock()
+	open := !store.closed && store.session != nil
+	store.mu.RUnlock()
+
+	// Injected sessions report no host events to the store
+	if store.hosts == nil {
+		return open
+	}
+	available, _ := store.AvailableHosts()
+	return open && available > 0
+}
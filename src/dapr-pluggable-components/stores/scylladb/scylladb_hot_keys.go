@@ -0,0 +1,263 @@
+package scylladb
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"hash/maphash"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// Request metadata key that makes a Query return the hot keys report
+const hotKeysMetadataKey = "hotKeys"
+
+const (
+	// Rows and counters per row of the count-min sketch. With 4 rows of 4096
+	// counters an estimate exceeds the true count by more than 0.07% of all
+	// accesses with probability below 2%.
+	hotKeysSketchDepth = 4
+	hotKeysSketchWidth = 4096
+)
+
+// HotKey is one entry of the hot keys report. Every hotKeysWindow all counts
+// are halved, so they weigh recent accesses most.
+type HotKey struct {
+	Key    string  `json:"key"`
+	Count  int64   `json:"count"`  // estimated accesses, never below the true count
+	Reads  int64   `json:"reads"`  // reads since the key entered the top keys
+	Writes int64   `json:"writes"` // writes and deletes since the key entered the top keys
+	Share  float64 `json:"share"`  // Count as a fraction of all counted accesses
+}
+
+// hotKeyTracker estimates per-key access frequency with a count-min sketch and
+// keeps the topK most frequent keys in a min-heap, so finding the key that
+// dominates the load costs a fixed amount of memory regardless of the number
+// of keys. Every window all counts are halved, so keys that cool down leave
+// the report.
+type hotKeyTracker struct {
+	mu        sync.Mutex
+	seed      maphash.Seed
+	sketch    [hotKeysSketchDepth][hotKeysSketchWidth]int64
+	top       hotKeyHeap
+	index     map[string]*hotKeyEntry
+	total     int64
+	topK      int
+	window    time.Duration
+	lastDecay time.Time
+}
+
+type hotKeyEntry struct {
+	key    string
+	count  int64
+	reads  int64
+	writes int64
+	pos    int
+}
+
+// hotKeyHeap orders the tracked keys by ascending count.
+type hotKeyHeap []*hotKeyEntry
+
+func (h hotKeyHeap) Len() int           { return len(h) }
+func (h hotKeyHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h hotKeyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos, h[j].pos = i, j
+}
+func (h *hotKeyHeap) Push(x any) {
+	entry := x.(*hotKeyEntry)
+	entry.pos = len(*h)
+	*h = append(*h, entry)
+}
+func (h *hotKeyHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// initHotKeys parses the hot key settings.
+func (store *ScyllaStateStore) initHotKeys() {
+	topK, err := strconv.Atoi(store.config.HotKeysTopK)
+	if err != nil || topK <= 0 {
+		store.logger.Warnf("Invalid hotKeysTopK: %s, using default", store.config.HotKeysTopK)
+		topK = 20
+	}
+	window, err := time.ParseDuration(store.config.HotKeysWindow)
+	if err != nil || window <= 0 {
+		store.logger.Warnf("Invalid hotKeysWindow: %s, using default", store.config.HotKeysWindow)
+		window = time.Minute
+	}
+
+	store.hotKeys = &hotKeyTracker{
+		seed:      maphash.MakeSeed(),
+		index:     make(map[string]*hotKeyEntry, topK),
+		topK:      topK,
+		window:    window,
+		lastDecay: time.Now(),
+	}
+
+	store.logger.Infof("Hot key tracking enabled (top=%d, window=%v)", topK, window)
+}
+
+// record counts one access of key.
+func (t *hotKeyTracker) record(key string, write bool) {
+	hash := maphash.String(t.seed, key)
+	// Derive the row hashes from two halves of one hash (Kirsch-Mitzenmacher)
+	h1, h2 := uint32(hash), uint32(hash>>32)|1
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now := time.Now(); now.Sub(t.lastDecay) >= t.window {
+		t.decay()
+		t.lastDecay = now
+	}
+
+	estimate := int64(-1)
+	for row := range t.sketch {
+		counter := &t.sketch[row][(h1+uint32(row)*h2)%hotKeysSketchWidth]
+		*counter++
+		if estimate < 0 || *counter < estimate {
+			estimate = *counter
+		}
+	}
+	t.total++
+
+	entry, tracked := t.index[key]
+	switch {
+	case tracked:
+		entry.count = estimate
+		heap.Fix(&t.top, entry.pos)
+	case len(t.top) < t.topK:
+		entry = &hotKeyEntry{key: key, count: estimate}
+		t.index[key] = entry
+		heap.Push(&t.top, entry)
+	case estimate > t.top[0].count:
+		// Replace the coolest tracked key
+		delete(t.index, t.top[0].key)
+		entry = &hotKeyEntry{key: key, count: estimate, pos: 0}
+		t.top[0] = entry
+		t.index[key] = entry
+		heap.Fix(&t.top, 0)
+	default:
+		return
+	}
+	if write {
+		entry.writes++
+	} else {
+		entry.reads++
+	}
+}
+
+// count returns the estimated access count of key when it is among the
+// tracked keys, or 0.
+func (t *hotKeyTracker) count(key string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if entry, ok := t.index[key]; ok {
+		return entry.count
+	}
+	return 0
+}
+
+// decay halves every count. The caller holds the lock.
+func (t *hotKeyTracker) decay() {
+	for row := range t.sketch {
+		for i := range t.sketch[row] {
+			t.sketch[row][i] /= 2
+		}
+	}
+	t.total /= 2
+	for _, entry := range t.top {
+		entry.count /= 2
+		entry.reads /= 2
+		entry.writes /= 2
+	}
+}
+
+// report returns up to n of the hottest keys, hottest first; n <= 0 returns
+// all tracked keys.
+func (t *hotKeyTracker) report(n int) []HotKey {
+	t.mu.Lock()
+	keys := make([]HotKey, 0, len(t.top))
+	for _, entry := range t.top {
+		if entry.count == 0 {
+			continue
+		}
+		key := HotKey{Key: entry.key, Count: entry.count, Reads: entry.reads, Writes: entry.writes}
+		if t.total > 0 {
+			key.Share = float64(entry.count) / float64(t.total)
+		}
+		keys = append(keys, key)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if n > 0 && len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// HotKeys returns up to n of the most frequently accessed keys, hottest first,
+// or nil when hotKeys tracking is disabled. Keys are Dapr keys, as requested.
+func (store *ScyllaStateStore) HotKeys(n int) []HotKey {
+	store.mu.RLock()
+	tracker := store.hotKeys
+	store.mu.RUnlock()
+
+	if tracker == nil {
+		return nil
+	}
+	return tracker.report(n)
+}
+
+// hotKeysQuery answers a Query carrying the hotKeys metadata with one item per
+// tracked key, hottest first.
+func (store *ScyllaStateStore) hotKeysQuery() (*state.QueryResponse, error) {
+	if store.hotKeys == nil {
+		return nil, errors.New("hot keys report requires hotKeys to be enabled")
+	}
+
+	keys := store.hotKeys.report(0)
+	results := make([]state.QueryItem, 0, len(keys))
+	for _, key := range keys {
+		data, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, state.QueryItem{Key: key.Key, Data: data})
+	}
+
+	return &state.QueryResponse{Results: results}, nil
+}
+
+// gauges returns the share of the hottest key and the counted accesses.
+func (t *hotKeyTracker) gauges() map[string]float64 {
+	gauges := map[string]float64{"hot_key_top_share": 0}
+	if top := t.report(1); len(top) > 0 {
+		gauges["hot_key_top_share"] = top[0].Share
+	}
+	t.mu.Lock()
+	gauges["hot_key_window_accesses"] = float64(t.total)
+	t.mu.Unlock()
+	return gauges
+}
+
+func (t *hotKeyTracker) diagnostics() map[string]any {
+	return map[string]any{
+		"topK":   t.topK,
+		"window": t.window.String(),
+		"top":    t.report(10),
+	}
+}
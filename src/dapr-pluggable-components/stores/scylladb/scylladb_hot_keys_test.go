@@ -0,0 +1,81 @@
+package scylladb
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dapr/kit/logger"
+)
+
+func TestInitHotKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		topK       string
+		window     string
+		wantTopK   int
+		wantWindow time.Duration
+	}{
+		{"configured", "5", "30s", 5, 30 * time.Second},
+		{"empty", "", "", 20, time.Minute},
+		{"invalid", "many", "soon", 20, time.Minute},
+		{"not positive", "0", "-1s", 20, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &ScyllaStateStore{logger: logger.NewLogger("test")}
+			store.config.HotKeysTopK = tt.topK
+			store.config.HotKeysWindow = tt.window
+			store.initHotKeys()
+
+			if store.hotKeys.topK != tt.wantTopK || store.hotKeys.window != tt.wantWindow {
+				t.Errorf("topK, window = %d, %v, want %d, %v",
+					store.hotKeys.topK, store.hotKeys.window, tt.wantTopK, tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestHotKeyTracker(t *testing.T) {
+	store := &ScyllaStateStore{logger: logger.NewLogger("test")}
+	store.config.HotKeysTopK = "3"
+	store.config.HotKeysWindow = "1h"
+	store.initHotKeys()
+	tracker := store.hotKeys
+
+	// One dominant key, two warm keys and a long tail of cold ones
+	accesses := map[string]int{"hot": 5000, "warm-1": 800, "warm-2": 600}
+	for key, n := range accesses {
+		for i := 0; i < n; i++ {
+			tracker.record(key, i%4 == 0)
+		}
+	}
+	for i := 0; i < 20000; i++ {
+		tracker.record("cold-"+strconv.Itoa(i), false)
+	}
+
+	report := tracker.report(0)
+	want := []string{"hot", "warm-1", "warm-2"}
+	if len(report) != len(want) {
+		t.Fatalf("report() = %v, want keys %v", report, want)
+	}
+	for i, key := range want {
+		got := report[i]
+		if got.Key != key {
+			t.Errorf("report()[%d].Key = %q, want %q", i, got.Key, key)
+		}
+		// Count-min estimates never fall below the true count
+		if got.Count < int64(accesses[key]) {
+			t.Errorf("count of %q = %d, below the %d accesses", key, got.Count, accesses[key])
+		}
+		if got.Reads+got.Writes != int64(accesses[key]) {
+			t.Errorf("reads+writes of %q = %d, want %d", key, got.Reads+got.Writes, accesses[key])
+		}
+	}
+	if got := tracker.count("cold-1"); got != 0 {
+		t.Errorf("count(%q) = %d, want 0 for an untracked key", "cold-1", got)
+	}
+	if got := tracker.report(1); len(got) != 1 || got[0].Key != "hot" {
+		t.Errorf("report(1) = %v, want only %q", got, "hot")
+	}
+}
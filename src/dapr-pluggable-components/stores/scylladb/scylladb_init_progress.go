@@ -0,0 +1,169 @@
+package scylladb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// Interval between progress logs of a phase that is still running
+const initProgressLogInterval = 10 * time.Second
+
+// Init phases in order. Schema changes wait for schema agreement across the
+// cluster, which can take minutes on large clusters.
+const (
+	initPhaseConfiguring     = "configuring"
+	initPhaseConnecting      = "connecting"
+	initPhaseCreatingSpace   = "creating keyspace"
+	initPhaseCreatingTable   = "creating table"
+	initPhaseMigratingSchema = "migrating schema"
+	initPhaseCheckingClock   = "checking clock"
+	initPhaseStartingWorkers = "starting workers"
+)
+
+var initPhases = []string{
+	initPhaseConfiguring, initPhaseConnecting, initPhaseCreatingSpace, initPhaseCreatingTable,
+	initPhaseMigratingSchema, initPhaseCheckingClock, initPhaseStartingWorkers,
+}
+
+// Overall Init states reported with the progress
+const (
+	initStatePending      = "pending"
+	initStateInitializing = "initializing"
+	initStateReady        = "ready"
+	initStateFailed       = "failed"
+)
+
+// initProgress records which phase Init is in, so operators can tell a slow
+// bootstrap from a stuck one. Phase changes are logged, a phase still running
+// is logged again every initProgressLogInterval, and the health endpoint
+// reports the progress with the readiness of the instance.
+type initProgress struct {
+	mu           sync.Mutex
+	state        string
+	phase        string
+	step         int
+	started      time.Time
+	phaseStarted time.Time
+	finished     time.Time
+	err          string
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Init initializes the store, tracking the progress of each phase.
+func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata) error {
+	progress := &initProgress{
+		state:   initStateInitializing,
+		started: time.Now(),
+		stopCh:  make(chan struct{}),
+	}
+	store.progress.Store(progress)
+
+	progress.wg.Add(1)
+	go store.logInitProgress(progress)
+
+	err := store.initialize(ctx, metadata)
+
+	close(progress.stopCh)
+	progress.wg.Wait()
+
+	progress.mu.Lock()
+	progress.finished = time.Now()
+	elapsed := progress.finished.Sub(progress.started).Round(time.Millisecond)
+	if err != nil {
+		progress.state = initStateFailed
+		progress.err = err.Error()
+		store.logger.Errorf("Init failed during phase %q after %v: %v", progress.phase, elapsed, err)
+	} else {
+		progress.state = initStateReady
+		progress.step = len(initPhases)
+		store.logger.Infof("Init progress: ready (100%%) after %v", elapsed)
+	}
+	progress.mu.Unlock()
+	return err
+}
+
+// initPhase records the start of an Init phase.
+func (store *ScyllaStateStore) initPhase(phase string) {
+	progress := store.progress.Load()
+	if progress == nil {
+		return
+	}
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if progress.state != initStateInitializing {
+		return
+	}
+	now := time.Now()
+	for i, name := range initPhases {
+		if name == phase {
+			progress.step = i
+		}
+	}
+	progress.phase = phase
+	progress.phaseStarted = now
+	store.logger.Infof("Init progress: %s (%d%%, elapsed %v)", phase, progress.percent(),
+		now.Sub(progress.started).Round(time.Millisecond))
+}
+
+// logInitProgress logs the current phase while it keeps running.
+func (store *ScyllaStateStore) logInitProgress(progress *initProgress) {
+	defer progress.wg.Done()
+
+	ticker := time.NewTicker(initProgressLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-progress.stopCh:
+			return
+		case now := <-ticker.C:
+			progress.mu.Lock()
+			store.logger.Infof("Init progress: still %s (%d%%, phase elapsed %v, elapsed %v)", progress.phase,
+				progress.percent(), now.Sub(progress.phaseStarted).Round(time.Second),
+				now.Sub(progress.started).Round(time.Second))
+			progress.mu.Unlock()
+		}
+	}
+}
+
+// percent is the share of phases completed; callers hold mu.
+func (p *initProgress) percent() int {
+	return p.step * 100 / len(initPhases)
+}
+
+// InitProgress reports the state of Init: the current phase, the share of
+// phases completed and the time elapsed. Instances whose Init was never called
+// report pending.
+func (store *ScyllaStateStore) InitProgress() map[string]any {
+	progress := store.progress.Load()
+	if progress == nil {
+		return map[string]any{"state": initStatePending}
+	}
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	end := time.Now()
+	if !progress.finished.IsZero() {
+		end = progress.finished
+	}
+	report := map[string]any{
+		"state":   progress.state,
+		"percent": progress.percent(),
+		"elapsed": end.Sub(progress.started).Round(time.Millisecond).String(),
+	}
+	if progress.state != initStateReady {
+		report["phase"] = progress.phase
+		report["phaseElapsed"] = end.Sub(progress.phaseStarted).Round(time.Millisecond).String()
+	}
+	if progress.err != "" {
+		report["error"] = progress.err
+	}
+	return report
+}
@@ -0,0 +1,298 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+// Request metadata key that turns a Query into a read of one key group
+const keyGroupMetadataKey = "keyGroup"
+
+// Index entries without a row are only removed once they are this old, so an
+// entry recorded for a write still in flight is kept
+const keyGroupRepairGrace = time.Minute
+
+// keyGroups indexes keys by the part before their last delimiter, so all keys
+// of a group can be read in one call. Dapr actor and workflow keys have the
+// form <app>||<actor type>||<actor id>||<name>, which makes one workflow
+// instance's history, inbox and metadata keys one group.
+//
+// Entries are recorded before the value is written, so a group read never
+// misses a written key. Entries of keys that were deleted, or whose write
+// failed, are skipped and removed by the reads that find them; prefix
+// deletes remove the entries of the keys they delete.
+type keyGroups struct {
+	delimiter    string
+	insertQuery  string
+	deleteQuery  string
+	unindexQuery string
+	listQuery    string
+
+	indexed  atomic.Int64
+	reads    atomic.Int64
+	repaired atomic.Int64
+	removed  atomic.Int64
+}
+
+// keyGroupsTable returns the name of the table indexing keys by group.
+func (store *ScyllaStateStore) keyGroupsTable() string {
+	return store.config.Table + "_key_groups"
+}
+
+// keyGroupsTableDDL returns the CREATE TABLE statement of a key group index;
+// name may be keyspace-qualified.
+func keyGroupsTableDDL(name string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			grp text,
+			key text,
+			indexed_at timestamp,
+			PRIMARY KEY (grp, key)
+		)`, name)
+}
+
+// ensureKeyGroupsTable creates the key group index when key groups are enabled.
+func (store *ScyllaStateStore) ensureKeyGroupsTable(ctx context.Context) error {
+	createQuery := keyGroupsTableDDL(store.keyGroupsTable())
+
+	store.logger.Debugf("Creating key group table with query: %s", createQuery)
+	if err := store.session.Query(createQuery).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to create key group table: %w", err)
+	}
+	return nil
+}
+
+// initKeyGroups prepares the key group index statements.
+func (store *ScyllaStateStore) initKeyGroups() {
+	if store.config.KeyGroupDelimiter == "" {
+		store.logger.Warnf("Invalid keyGroupDelimiter: %q, using default", store.config.KeyGroupDelimiter)
+		store.config.KeyGroupDelimiter = "||"
+	}
+
+	table := store.keyGroupsTable()
+	store.keyGroups = &keyGroups{
+		delimiter:    store.config.KeyGroupDelimiter,
+		insertQuery:  fmt.Sprintf("INSERT INTO %s (grp, key, indexed_at) VALUES (?, ?, ?)", table),
+		deleteQuery:  fmt.Sprintf("DELETE FROM %s WHERE grp = ? AND key = ? IF indexed_at = ?", table),
+		unindexQuery: fmt.Sprintf("DELETE FROM %s WHERE grp = ? AND key = ? IF indexed_at < ?", table),
+		listQuery:    fmt.Sprintf("SELECT key, indexed_at FROM %s WHERE grp = ? AND key > ?", table),
+	}
+	store.logger.Infof("Indexing keys by group in %s (delimiter %q)", table, store.config.KeyGroupDelimiter)
+}
+
+// groupOf returns the group of a Dapr key: the part before the last delimiter.
+// Keys without the delimiter belong to no group.
+func (g *keyGroups) groupOf(daprKey string) (string, bool) {
+	i := strings.LastIndex(daprKey, g.delimiter)
+	if i <= 0 {
+		return "", false
+	}
+	return daprKey[:i], true
+}
+
+// indexKeyGroups records the group of each key before the keys are written.
+// The entries of one group share a partition and go in one batch.
+func (store *ScyllaStateStore) indexKeyGroups(ctx context.Context, daprKeys ...string) error {
+	groups := store.keyGroups
+	if groups == nil {
+		return nil
+	}
+
+	byGroup := make(map[string][]string)
+	for _, key := range daprKeys {
+		if group, ok := groups.groupOf(key); ok {
+			byGroup[group] = append(byGroup[group], key)
+		}
+	}
+
+	now := store.now()
+	for group, keys := range byGroup {
+		exec := func() error {
+			if len(keys) == 1 {
+				stmt, err := store.hookedQuery(ctx, "index key group", groups.insertQuery, group, keys[0], now)
+				if err != nil {
+					return err
+				}
+				return stmt.Exec()
+			}
+			batch := store.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+			for _, key := range keys {
+				if err := store.addHookedBatchEntry(ctx, batch, "index key group", groups.insertQuery, group, key, now); err != nil {
+					return err
+				}
+			}
+			return store.session.ExecuteBatch(batch)
+		}
+		if err := store.withRetry(ctx, fmt.Sprintf("index key group %s", group), exec); err != nil {
+			store.logger.Errorf("Failed to index keys of group %s: %v", group, err)
+			return fmt.Errorf("failed to index keys of group %s: %w", group, err)
+		}
+		groups.indexed.Add(int64(len(keys)))
+	}
+	return nil
+}
+
+// addKeyGroupEntries adds the group entries of daprKeys to a transaction's
+// batch, so they are written atomically with the values.
+func (store *ScyllaStateStore) addKeyGroupEntries(ctx context.Context, batch *gocql.Batch, daprKeys []string) error {
+	groups := store.keyGroups
+	if groups == nil {
+		return nil
+	}
+
+	now := store.now()
+	for _, key := range daprKeys {
+		group, ok := groups.groupOf(key)
+		if !ok {
+			continue
+		}
+		if err := store.addHookedBatchEntry(ctx, batch, "transaction", groups.insertQuery, group, key, now); err != nil {
+			return err
+		}
+		groups.indexed.Add(1)
+	}
+	return nil
+}
+
+// keyGroupQuery returns the keys of a group in key order with their values.
+// The page limit bounds the keys returned; the token is the last key of the
+// previous page.
+func (store *ScyllaStateStore) keyGroupQuery(ctx context.Context, group string, req *state.QueryRequest) (*state.QueryResponse, error) {
+	groups := store.keyGroups
+	if groups == nil {
+		return nil, errors.New("keyGroup queries require keyGroups to be enabled")
+	}
+	if group == "" {
+		return nil, errors.New("keyGroup cannot be empty")
+	}
+	groups.reads.Add(1)
+
+	limit := req.Query.Page.Limit
+	query := groups.listQuery
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	stmt, err := store.hookedQuery(ctx, "key group", query, group, req.Query.Page.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		keys      []string
+		indexedAt = make(map[string]time.Time)
+		key       string
+		at        time.Time
+	)
+	iter := stmt.Iter()
+	for iter.Scan(&key, &at) {
+		keys = append(keys, key)
+		indexedAt[key] = at
+	}
+	if err := iter.Close(); err != nil {
+		store.logger.Errorf("Failed to list key group %s: %v", group, err)
+		return nil, fmt.Errorf("failed to list key group %s: %w", group, err)
+	}
+
+	storageKeys := make([]string, len(keys))
+	daprKeys := make(map[string]string, len(keys))
+	for i, key := range keys {
+		storageKeys[i] = store.storageKey(key)
+		daprKeys[storageKeys[i]] = key
+	}
+
+	results := make([]state.QueryItem, 0, len(keys))
+	_, err = store.fetchRows(ctx, storageKeys, func(storageKey string, value []byte, etag string) bool {
+		if store.masker != nil {
+			value = store.masker.mask(value)
+		}
+		results = append(results, state.QueryItem{Key: daprKeys[storageKey], Data: value, ETag: &etag})
+		store.meterRead(daprKeys[storageKey], len(value))
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key group %s: %w", group, err)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+
+	if len(results) < len(keys) {
+		store.repairKeyGroup(ctx, group, keys, results, indexedAt)
+	}
+
+	response := &state.QueryResponse{Results: results}
+	if limit > 0 && len(keys) == limit {
+		response.Token = keys[len(keys)-1]
+	}
+	store.logger.Debugf("Key group %s returned %d keys", group, len(results))
+	return response, nil
+}
+
+// repairKeyGroup removes the entries of keys that have no row. Entries newer
+// than keyGroupRepairGrace may belong to a write in flight and are kept; the
+// condition on indexed_at keeps an entry that was just written again.
+func (store *ScyllaStateStore) repairKeyGroup(ctx context.Context, group string, keys []string, results []state.QueryItem, indexedAt map[string]time.Time) {
+	found := make(map[string]bool, len(results))
+	for _, item := range results {
+		found[item.Key] = true
+	}
+
+	for _, key := range keys {
+		at := indexedAt[key]
+		if found[key] || store.now().Sub(at) < keyGroupRepairGrace {
+			continue
+		}
+		stmt, err := store.hookedQuery(ctx, "key group", store.keyGroups.deleteQuery, group, key, at)
+		if err != nil {
+			return
+		}
+		if applied, err := stmt.MapScanCAS(make(map[string]any)); err != nil {
+			store.logger.Debugf("Failed to remove stale key group entry %s: %v", key, err)
+		} else if applied {
+			store.keyGroups.repaired.Add(1)
+		}
+	}
+}
+
+// unindexKeyGroups removes the group entries of deleted keys. Entries newer
+// than keyGroupRepairGrace may belong to a write of the key in flight, so
+// they are kept and left to the group reads to repair.
+func (store *ScyllaStateStore) unindexKeyGroups(ctx context.Context, daprKeys ...string) {
+	groups := store.keyGroups
+	if groups == nil {
+		return
+	}
+
+	cutoff := store.now().Add(-keyGroupRepairGrace)
+	for _, key := range daprKeys {
+		group, ok := groups.groupOf(key)
+		if !ok {
+			continue
+		}
+		stmt, err := store.hookedQuery(ctx, "key group", groups.unindexQuery, group, key, cutoff)
+		if err != nil {
+			return
+		}
+		if applied, err := stmt.MapScanCAS(make(map[string]any)); err != nil {
+			store.logger.Debugf("Failed to remove key group entry %s: %v", key, err)
+		} else if applied {
+			groups.removed.Add(1)
+		}
+	}
+}
+
+func (g *keyGroups) diagnostics() map[string]any {
+	return map[string]any{
+		"delimiter": g.delimiter,
+		"indexed":   g.indexed.Load(),
+		"reads":     g.reads.Load(),
+		"repaired":  g.repaired.Load(),
+		"removed":   g.removed.Load(),
+	}
+}
@@ -0,0 +1,147 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// Rows fetched per page by keyIterator
+const keyIteratorPageSize = 1000
+
+// keyIterator walks every partition key of the state table in token order.
+//
+// Each page is a separate token-range query, so a scan survives node
+// restarts and topology changes: the position is the last (token, key) pair
+// returned, not a driver paging state tied to a coordinator. Cursor encodes
+// that position so a failed or interrupted scan can be resumed with
+// newKeyIterator. Transient errors are retried with the store's retry policy.
+//
+// Tokens are read as int64, which matches the Murmur3 partitioner ScyllaDB
+// uses by default.
+type keyIterator struct {
+	store    *ScyllaStateStore
+	session  *gocql.Session
+	query    string
+	pageSize int
+
+	token int64 // position: last returned token
+	key   string
+	// started is false until the first key is returned or a cursor is resumed,
+	// so the first page includes the minimum token.
+	started bool
+	done    bool
+
+	page []keyIteratorRow
+	err  error
+}
+
+type keyIteratorRow struct {
+	key   string
+	token int64
+}
+
+// newKeyIterator starts a scan at the beginning of the table, or after the
+// position encoded in cursor when it is not empty. The caller provides the
+// session so the iterator can be used while the store lock is held.
+func (store *ScyllaStateStore) newKeyIterator(session *gocql.Session, cursor string) (*keyIterator, error) {
+	it := &keyIterator{
+		store:    store,
+		session:  session,
+		query:    fmt.Sprintf("SELECT key, token(key) FROM %s WHERE token(key) >= ? LIMIT ?", store.config.Table),
+		pageSize: keyIteratorPageSize,
+		token:    math.MinInt64,
+	}
+
+	if cursor != "" {
+		tokenPart, key, ok := strings.Cut(cursor, ":")
+		token, err := strconv.ParseInt(tokenPart, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid key cursor: %s", cursor)
+		}
+		it.token, it.key, it.started = token, key, true
+	}
+	return it, nil
+}
+
+// Next advances to the next key, fetching a new page when needed.
+func (it *keyIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	for len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+		if err := it.fetch(ctx); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	row := it.page[0]
+	it.page = it.page[1:]
+	it.token, it.key, it.started = row.token, row.key, true
+	return true
+}
+
+// fetch loads the page following the current position. Keys sharing the
+// current token are ordered by key bytes, so those up to the current key have
+// already been returned and are skipped.
+func (it *keyIterator) fetch(ctx context.Context) error {
+	var rows []keyIteratorRow
+	err := it.store.withRetry(ctx, "key scan page", func() error {
+		rows = rows[:0]
+		iter := it.session.Query(it.query, it.token, it.pageSize).WithContext(ctx).Iter()
+
+		var row keyIteratorRow
+		for iter.Scan(&row.key, &row.token) {
+			rows = append(rows, row)
+		}
+		return iter.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("key scan failed at cursor %s: %w", it.Cursor(), err)
+	}
+
+	if len(rows) < it.pageSize {
+		it.done = true
+	}
+
+	for _, row := range rows {
+		if it.started && row.token == it.token && row.key <= it.key {
+			continue
+		}
+		it.page = append(it.page, row)
+	}
+
+	// A full page made only of already returned keys cannot advance the scan
+	if len(it.page) == 0 && !it.done {
+		return fmt.Errorf("key scan cannot advance past token %d: more than %d keys share it", it.token, it.pageSize)
+	}
+	return nil
+}
+
+// Key returns the stored partition key the iterator is positioned on.
+func (it *keyIterator) Key() string {
+	return it.key
+}
+
+// Cursor returns the resumable position after the current key, or an empty
+// string before the first key.
+func (it *keyIterator) Cursor() string {
+	if !it.started {
+		return ""
+	}
+	return strconv.FormatInt(it.token, 10) + ":" + it.key
+}
+
+// Err returns the error that stopped the scan, if any.
+func (it *keyIterator) Err() error {
+	return it.err
+}
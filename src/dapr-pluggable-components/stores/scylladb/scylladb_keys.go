@@ -0,0 +1,93 @@
+package scylladb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Supported key strategies for composing the partition key stored in ScyllaDB
+const (
+	keyStrategyPassthrough = "passthrough" // store the Dapr key unchanged
+	keyStrategyTemplate    = "template"    // render keyTemplate, e.g. "{appid}:{key}"
+	keyStrategyHash        = "hash"        // SHA-256 of the rendered keyTemplate
+)
+
+// keyMapper translates Dapr state keys into the partition keys stored in the
+// table, so several applications can share one table without collisions.
+type keyMapper struct {
+	strategy string
+	prefix   string // rendered template text before {key}
+	suffix   string // rendered template text after {key}
+}
+
+// newKeyMapper validates the key strategy configuration.
+func newKeyMapper(strategy, template, appID string) (*keyMapper, error) {
+	mapper := &keyMapper{strategy: strings.ToLower(strategy)}
+
+	switch mapper.strategy {
+	case keyStrategyPassthrough:
+		return mapper, nil
+	case keyStrategyTemplate, keyStrategyHash:
+	default:
+		return nil, fmt.Errorf("unknown keyStrategy %q (expected passthrough, template or hash)", strategy)
+	}
+
+	if strings.Contains(template, "{appid}") && appID == "" {
+		return nil, fmt.Errorf("keyTemplate %q references {appid} but appId is not configured", template)
+	}
+
+	rendered := strings.ReplaceAll(template, "{appid}", appID)
+	if strings.Count(rendered, "{key}") != 1 {
+		return nil, fmt.Errorf("keyTemplate %q must contain {key} exactly once", template)
+	}
+
+	parts := strings.SplitN(rendered, "{key}", 2)
+	mapper.prefix, mapper.suffix = parts[0], parts[1]
+	return mapper, nil
+}
+
+// toStorage returns the partition key stored for a Dapr key.
+func (m *keyMapper) toStorage(key string) string {
+	switch m.strategy {
+	case keyStrategyTemplate:
+		return m.prefix + key + m.suffix
+	case keyStrategyHash:
+		sum := sha256.Sum256([]byte(m.prefix + key + m.suffix))
+		return hex.EncodeToString(sum[:])
+	default:
+		return key
+	}
+}
+
+// fromStorage recovers the Dapr key from a stored partition key. It reports
+// false for rows that belong to another application's namespace.
+func (m *keyMapper) fromStorage(storageKey string) (string, bool) {
+	switch m.strategy {
+	case keyStrategyTemplate:
+		if len(storageKey) < len(m.prefix)+len(m.suffix) ||
+			!strings.HasPrefix(storageKey, m.prefix) || !strings.HasSuffix(storageKey, m.suffix) {
+			return "", false
+		}
+		return storageKey[len(m.prefix) : len(storageKey)-len(m.suffix)], true
+	case keyStrategyHash:
+		// Hashed keys cannot be reversed
+		return "", false
+	default:
+		return storageKey, true
+	}
+}
+
+// reversible reports whether stored keys can be mapped back to Dapr keys.
+func (m *keyMapper) reversible() bool {
+	return m.strategy != keyStrategyHash
+}
+
+// storageKey maps a Dapr key to the partition key used in the table.
+func (store *ScyllaStateStore) storageKey(key string) string {
+	if store.keys == nil {
+		return key
+	}
+	return store.keys.toStorage(key)
+}
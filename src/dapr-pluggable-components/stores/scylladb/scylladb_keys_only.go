@@ -0,0 +1,56 @@
+package scylladb
+
+import "context"
+
+// keysOnlyKey marks a context whose reads project the key and etag columns
+// only, for BulkGets and queries with keysOnly request metadata.
+type keysOnlyKey struct{}
+
+func withKeysOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keysOnlyKey{}, true)
+}
+
+func isKeysOnly(ctx context.Context) bool {
+	return ctx.Value(keysOnlyKey{}) != nil
+}
+
+// rowScan holds the columns of a row read by key: key, value and etag, or
+// key and etag for keysOnly reads.
+type rowScan struct {
+	keysOnly bool
+	key      string
+	text     string
+	blob     []byte
+	etag     string
+}
+
+func newRowScan(ctx context.Context) *rowScan {
+	return &rowScan{keysOnly: isKeysOnly(ctx)}
+}
+
+// columns returns the selected columns, in the order of dest.
+func (r *rowScan) columns() string {
+	if r.keysOnly {
+		return "key, etag"
+	}
+	return "key, value, value_blob, etag"
+}
+
+func (r *rowScan) dest() []any {
+	if r.keysOnly {
+		return []any{&r.key, &r.etag}
+	}
+	return []any{&r.key, &r.text, &r.blob, &r.etag}
+}
+
+// value returns the value of the row just scanned, nil for keysOnly reads.
+func (r *rowScan) value() []byte {
+	if r.keysOnly {
+		return nil
+	}
+	value := storedBytes(r.text, r.blob)
+	// Scan appends into the destination's buffer; reset it so the next row
+	// does not overwrite the value just returned
+	r.blob = nil
+	return value
+}
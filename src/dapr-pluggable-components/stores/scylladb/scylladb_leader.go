@@ -0,0 +1,224 @@
+package scylladb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Several replicas of the component usually share one table. Cluster-wide
+// background work, such as the blob value migration, should run on only one of
+// them: with leader election enabled each job is guarded by a lease row in the
+// <table>_leases table. The lease is taken with a lightweight transaction and
+// kept alive by TTL renewals; the job runs only while this replica holds it and
+// is cancelled as soon as a renewal fails. Work that belongs to every replica
+// (bloom filter, search index, usage reports) is not affected.
+
+// leaderElection holds this replica's lease settings.
+type leaderElection struct {
+	holder   string
+	duration time.Duration
+}
+
+// backgroundJob is a background worker that is optionally guarded by a lease.
+type backgroundJob struct {
+	name   string
+	leader atomic.Bool
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// leaseTable returns the name of the table storing job leases.
+func (store *ScyllaStateStore) leaseTable() string {
+	return store.config.Table + "_leases"
+}
+
+// leaseTableDDL returns the CREATE TABLE statement of a lease table; name may
+// be keyspace-qualified.
+func leaseTableDDL(name string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			name text PRIMARY KEY,
+			holder text
+		)`, name)
+}
+
+// ensureLeaseTable creates the lease table when leader election is enabled.
+func (store *ScyllaStateStore) ensureLeaseTable(ctx context.Context) error {
+	createQuery := leaseTableDDL(store.leaseTable())
+
+	store.logger.Debugf("Creating lease table with query: %s", createQuery)
+	if err := store.session.Query(createQuery).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to create lease table: %w", err)
+	}
+	return nil
+}
+
+// initLeaderElection parses the lease settings and picks this replica's holder id.
+func (store *ScyllaStateStore) initLeaderElection() {
+	duration, err := time.ParseDuration(store.config.LeaseDuration)
+	if err != nil || duration < 3*time.Second {
+		store.logger.Warnf("Invalid leaseDuration: %s, using default", store.config.LeaseDuration)
+		duration = 30 * time.Second
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "replica"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	store.leaderElection = &leaderElection{
+		holder:   hostname + "-" + hex.EncodeToString(suffix),
+		duration: duration,
+	}
+	store.logger.Infof("Leader election enabled (holder=%s, leaseDuration=%v)", store.leaderElection.holder, duration)
+}
+
+// startBackgroundJob runs job in the background. With leader election enabled
+// it only runs while this replica holds the job's lease, and is started again
+// if the lease is lost and later reacquired.
+func (store *ScyllaStateStore) startBackgroundJob(name string, run func(ctx context.Context)) *backgroundJob {
+	job := &backgroundJob{
+		name:   name,
+		stopCh: make(chan struct{}),
+	}
+
+	job.wg.Add(1)
+	go func() {
+		defer job.wg.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-job.stopCh
+			cancel()
+		}()
+
+		if store.leaderElection == nil {
+			job.leader.Store(true)
+			run(ctx)
+			return
+		}
+		store.runAsLeader(ctx, job, run)
+	}()
+	return job
+}
+
+// runAsLeader acquires or renews the job's lease every third of the lease
+// duration, starting run when the lease is acquired and cancelling it when the
+// lease is lost. A run that completes keeps the lease, so other replicas do
+// not repeat the work while this one is alive.
+func (store *ScyllaStateStore) runAsLeader(ctx context.Context, job *backgroundJob, run func(ctx context.Context)) {
+	ticker := time.NewTicker(store.leaderElection.duration / 3)
+	defer ticker.Stop()
+
+	var (
+		cancelRun context.CancelFunc
+		runDone   chan struct{}
+	)
+	stopRun := func() {
+		if cancelRun != nil {
+			cancelRun()
+			<-runDone
+			cancelRun = nil
+		}
+	}
+
+	for {
+		held, err := store.holdLease(ctx, job.name, job.leader.Load())
+		if err != nil && ctx.Err() == nil {
+			store.logger.Warnf("Lease %s could not be acquired or renewed: %v", job.name, err)
+		}
+
+		switch {
+		case held && !job.leader.Load():
+			store.logger.Infof("Acquired lease %s, starting job", job.name)
+			job.leader.Store(true)
+			runCtx, cancel := context.WithCancel(ctx)
+			cancelRun, runDone = cancel, make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				run(runCtx)
+			}(runDone)
+		case !held && job.leader.Load() && ctx.Err() == nil:
+			store.logger.Warnf("Lost lease %s, stopping job", job.name)
+			job.leader.Store(false)
+			stopRun()
+		}
+
+		select {
+		case <-ctx.Done():
+			stopRun()
+			if job.leader.Load() {
+				store.releaseLease(job.name)
+				job.leader.Store(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// holdLease takes the lease when it is free, or renews it when renew is set.
+// A lease row still naming this replica counts as held, so a renewal that
+// failed transiently does not leave the job idle until the row expires.
+func (store *ScyllaStateStore) holdLease(ctx context.Context, name string, renew bool) (bool, error) {
+	store.mu.RLock()
+	session := store.session
+	store.mu.RUnlock()
+
+	if session == nil {
+		return false, errors.New("store is closed")
+	}
+
+	election := store.leaderElection
+	ttl := int(election.duration / time.Second)
+	existing := make(map[string]any)
+
+	if renew {
+		renewQuery := fmt.Sprintf("UPDATE %s USING TTL ? SET holder = ? WHERE name = ? IF holder = ?", store.leaseTable())
+		return session.Query(renewQuery, ttl, election.holder, name, election.holder).WithContext(ctx).MapScanCAS(existing)
+	}
+
+	acquireQuery := fmt.Sprintf("INSERT INTO %s (name, holder) VALUES (?, ?) IF NOT EXISTS USING TTL ?", store.leaseTable())
+	applied, err := session.Query(acquireQuery, name, election.holder, ttl).WithContext(ctx).MapScanCAS(existing)
+	if err != nil {
+		return false, err
+	}
+	return applied || existing["holder"] == election.holder, nil
+}
+
+// releaseLease gives up the lease on shutdown so another replica can take over
+// without waiting for it to expire.
+func (store *ScyllaStateStore) releaseLease(name string) {
+	store.mu.RLock()
+	session := store.session
+	store.mu.RUnlock()
+
+	if session == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	releaseQuery := fmt.Sprintf("DELETE FROM %s WHERE name = ? IF holder = ?", store.leaseTable())
+	if _, err := session.Query(releaseQuery, name, store.leaderElection.holder).WithContext(ctx).MapScanCAS(make(map[string]any)); err != nil {
+		store.logger.Warnf("Failed to release lease %s: %v", name, err)
+		return
+	}
+	store.logger.Infof("Released lease %s", name)
+}
+
+func (j *backgroundJob) stop() {
+	close(j.stopCh)
+	j.wg.Wait()
+}
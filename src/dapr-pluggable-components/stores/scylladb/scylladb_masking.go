@@ -0,0 +1,212 @@
+package scylladb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// Placeholder replacing redacted fields
+const redactedValue = "[REDACTED]"
+
+// maskAction is what happens to a masked field.
+type maskAction string
+
+const (
+	maskRedact maskAction = "redact"
+	maskHash   maskAction = "hash"
+)
+
+// maskRule masks the fields at one path of a JSON value.
+type maskRule struct {
+	path   []string
+	action maskAction
+}
+
+// valueMasker redacts or hashes fields of JSON values returned by Get,
+// BulkGet and Query, so analytics applications can read state without seeing
+// raw PII. It is enabled only on component instances serving one of the
+// designated consumer app ids. Masking is applied to responses only; stored
+// values are never changed.
+//
+// Paths are dot-separated field names; "*" matches every field of an object
+// or element of an array, and a number selects an array element. Hashing
+// keeps values joinable across datasets: equal inputs give equal outputs.
+type valueMasker struct {
+	rules   []maskRule
+	hashKey []byte
+
+	masked     atomic.Int64 // values returned with masked fields
+	unmaskable atomic.Int64 // non-JSON values returned unchanged
+}
+
+// initMasking parses the masking rules and enables them when this instance
+// serves one of maskAppIds. The consumer is the appId setting, or the APP_ID
+// environment variable when appId is empty.
+func (store *ScyllaStateStore) initMasking() error {
+	rules, err := parseMaskRules(store.config.MaskPaths)
+	if err != nil {
+		return err
+	}
+
+	consumer := store.config.AppID
+	if consumer == "" {
+		consumer = os.Getenv("APP_ID")
+	}
+	var appIDs []string
+	for _, appID := range strings.Split(store.config.MaskAppIDs, ",") {
+		if appID = strings.TrimSpace(appID); appID != "" {
+			appIDs = append(appIDs, appID)
+		}
+	}
+	if !slices.Contains(appIDs, consumer) && !slices.Contains(appIDs, "*") {
+		store.logger.Infof("Masking not applied: app id %q is not in maskAppIds", consumer)
+		return nil
+	}
+
+	store.masker = &valueMasker{rules: rules, hashKey: []byte(store.config.MaskHashKey)}
+	store.logger.Infof("Masking %d field paths on reads for app id %q", len(rules), consumer)
+	return nil
+}
+
+// parseMaskRules parses "path=action,..." rules.
+func parseMaskRules(spec string) ([]maskRule, error) {
+	var rules []maskRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		path, action, _ := strings.Cut(entry, "=")
+		path = strings.TrimSpace(path)
+		if action = strings.TrimSpace(action); action == "" {
+			action = string(maskRedact)
+		}
+
+		switch maskAction(action) {
+		case maskRedact, maskHash:
+		default:
+			return nil, fmt.Errorf("invalid maskPaths entry %q: action must be redact or hash", entry)
+		}
+		segments := strings.Split(path, ".")
+		if slices.Contains(segments, "") {
+			return nil, fmt.Errorf("invalid maskPaths entry %q: empty path segment", entry)
+		}
+		rules = append(rules, maskRule{path: segments, action: maskAction(action)})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("maskPaths has no rules")
+	}
+	return rules, nil
+}
+
+// mask returns data with every rule applied, or data unchanged when it is not
+// a JSON object or array.
+func (m *valueMasker) mask(data []byte) []byte {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		if len(trimmed) > 0 {
+			m.unmaskable.Add(1)
+		}
+		return data
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		m.unmaskable.Add(1)
+		return data
+	}
+
+	for _, rule := range m.rules {
+		value = m.apply(value, rule.path, rule.action)
+	}
+
+	masked, err := json.Marshal(value)
+	if err != nil {
+		m.unmaskable.Add(1)
+		return data
+	}
+	m.masked.Add(1)
+	return masked
+}
+
+func (m *valueMasker) apply(node any, path []string, action maskAction) any {
+	if len(path) == 0 {
+		return m.transform(node, action)
+	}
+
+	segment, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]any:
+		if segment == "*" {
+			for field, child := range n {
+				n[field] = m.apply(child, rest, action)
+			}
+		} else if child, ok := n[segment]; ok {
+			n[segment] = m.apply(child, rest, action)
+		}
+	case []any:
+		if segment == "*" {
+			for i, child := range n {
+				n[i] = m.apply(child, rest, action)
+			}
+		} else if i, err := strconv.Atoi(segment); err == nil && i >= 0 && i < len(n) {
+			n[i] = m.apply(n[i], rest, action)
+		}
+	}
+	return node
+}
+
+func (m *valueMasker) transform(value any, action maskAction) any {
+	if action == maskRedact {
+		return redactedValue
+	}
+
+	encoded, _ := json.Marshal(value)
+	var h hash.Hash
+	prefix := "sha256:"
+	if len(m.hashKey) > 0 {
+		h = hmac.New(sha256.New, m.hashKey)
+		prefix = "hmac-sha256:"
+	} else {
+		h = sha256.New()
+	}
+	h.Write(encoded)
+	return prefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// maskQueryResponse masks the values of a Query response in place.
+func (store *ScyllaStateStore) maskQueryResponse(response *state.QueryResponse, err error) (*state.QueryResponse, error) {
+	if store.masker == nil || response == nil || err != nil {
+		return response, err
+	}
+	masked := make([]state.QueryItem, len(response.Results))
+	for i, item := range response.Results {
+		item.Data = store.masker.mask(item.Data)
+		masked[i] = item
+	}
+	// Cached responses are shared, so the masked copy gets its own result slice
+	return &state.QueryResponse{Results: masked, Token: response.Token, Metadata: response.Metadata}, nil
+}
+
+func (m *valueMasker) diagnostics() map[string]any {
+	return map[string]any{
+		"rules":      len(m.rules),
+		"keyedHash":  len(m.hashKey) > 0,
+		"masked":     m.masked.Load(),
+		"unmaskable": m.unmaskable.Load(),
+	}
+}
@@ -0,0 +1,174 @@
+package scylladb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+
+	"nebulagraph/stores/stateext"
+)
+
+const (
+	// Request metadata selecting how Set applies its value
+	patchMetadataKey = "patch"
+	mergePatchMode   = "merge"
+
+	// Read-modify-write rounds before a merge patch racing with other writers gives up
+	mergePatchAttempts = 5
+)
+
+// isMergePatch reports whether a Set request carries an RFC 7386 merge patch
+// instead of a whole value.
+func isMergePatch(metadata map[string]string) bool {
+	return metadata[patchMetadataKey] == mergePatchMode
+}
+
+// rejectMergePatch fails operations that cannot apply a merge patch, so a
+// patch is never stored as if it were the whole value.
+func rejectMergePatch(operation, key string, metadata map[string]string) error {
+	if isMergePatch(metadata) {
+		return fmt.Errorf("%s cannot apply %s=%s for key %s: send merge patches as single Set requests", operation, patchMetadataKey, mergePatchMode, key)
+	}
+	return nil
+}
+
+// setMergePatch applies patch to the JSON document stored under key.
+//
+// The document is read and the merged result is written with a lightweight
+// transaction conditioned on the etag that was read, so concurrent writers
+// cannot be overwritten. Without a request ETag a lost race re-reads and
+// merges again; with one it is reported as an ETag mismatch. A missing key is
+// patched as an empty document.
+func (store *ScyllaStateStore) setMergePatch(ctx context.Context, req *state.SetRequest, key, patch string, ttl int) error {
+	if store.sidecarEncryption {
+		return fmt.Errorf("cannot merge patch key %s: values are encrypted by the sidecar", req.Key)
+	}
+
+	patchDoc, err := decodeJSONDocument([]byte(patch))
+	if err != nil {
+		return fmt.Errorf("invalid merge patch for key %s: %w", req.Key, err)
+	}
+
+	readQuery := fmt.Sprintf("SELECT value, value_blob, etag FROM %s WHERE key = ?", store.config.Table)
+	insertQuery := fmt.Sprintf("INSERT INTO %s (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?) IF NOT EXISTS USING TTL ?", store.config.Table)
+	updateQuery := fmt.Sprintf("UPDATE %s USING TTL ? SET value_blob = ?, value = null, etag = ?, last_modified = ? WHERE key = ? IF etag = ?", store.config.Table)
+
+	// Record the key before writing so concurrent Gets never see a false negative
+	if store.keyFilter != nil {
+		store.keyFilter.add(key)
+	}
+
+	for attempt := 1; attempt <= mergePatchAttempts; attempt++ {
+		var text, currentEtag string
+		var blob []byte
+		readStmt, err := store.hookedQuery(ctx, "set", readQuery, key)
+		if err != nil {
+			return err
+		}
+		err = store.withRetry(ctx, fmt.Sprintf("read key %s for merge patch", req.Key), func() error {
+			return readStmt.Scan(&text, &blob, &currentEtag)
+		})
+		exists := err == nil
+		if err != nil && err != gocql.ErrNotFound {
+			return fmt.Errorf("failed to read key %s for merge patch: %w", req.Key, err)
+		}
+
+		if exists && req.ETag != nil && currentEtag != *req.ETag {
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", req.Key, *req.ETag, currentEtag), currentEtag)
+		}
+
+		var target any
+		if exists {
+			if target, err = decodeJSONDocument(storedBytes(text, blob)); err != nil {
+				return fmt.Errorf("cannot merge patch key %s: stored value is not a JSON document: %w", req.Key, err)
+			}
+		}
+
+		merged, err := json.Marshal(mergePatch(target, patchDoc))
+		if err != nil {
+			return fmt.Errorf("failed to encode merged value for key %s: %w", req.Key, err)
+		}
+
+		etag := store.newETag()
+		var writeStmt *gocql.Query
+		if exists {
+			writeStmt, err = store.hookedQuery(ctx, "set", updateQuery, ttl, merged, etag, store.now(), key, currentEtag)
+		} else {
+			writeStmt, err = store.hookedQuery(ctx, "set", insertQuery, key, merged, etag, store.now(), ttl)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Not retried: a CAS whose outcome is unknown is resolved by the next round's read
+		applied, err := writeStmt.MapScanCAS(make(map[string]any))
+		if err != nil {
+			store.logger.Errorf("Failed to merge patch key %s: %v", req.Key, err)
+			return fmt.Errorf("failed to merge patch key %s: %w", req.Key, err)
+		}
+		if !applied {
+			if req.ETag != nil {
+				return stateext.NewETagError(state.ETagMismatch,
+					fmt.Errorf("etag mismatch for key %s: value changed during merge patch", req.Key))
+			}
+			store.logger.Debugf("Merge patch for key %s lost a race (attempt %d), retrying", req.Key, attempt)
+			continue
+		}
+
+		value := string(merged)
+		store.afterSet(req.Key, key, value, etag, ttl)
+		store.meterWrite(req.Key, len(value))
+		store.observeSchema(req.Key, value)
+		store.verifyWrite(req.Key, key, value, etag)
+		store.auditSet(req.Key, etag, value, &priorValue{value: storedBytes(text, blob), exists: exists})
+
+		store.logger.Debugf("Successfully merge patched key: %s", req.Key)
+		return nil
+	}
+
+	return fmt.Errorf("failed to merge patch key %s: value kept changing after %d attempts", req.Key, mergePatchAttempts)
+}
+
+// decodeJSONDocument parses a single JSON value, keeping numbers exact.
+func decodeJSONDocument(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return doc, nil
+}
+
+// mergePatch applies patch to target as described in RFC 7386: objects are
+// merged member by member, null removes a member, and any other patch value
+// replaces the target.
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any, len(patchObject))
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
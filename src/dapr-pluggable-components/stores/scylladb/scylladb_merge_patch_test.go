@@ -0,0 +1,100 @@
+package scylladb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Cases from RFC 7386, Appendix A, plus a missing target
+	tests := []struct {
+		name   string
+		target string // empty for a missing key
+		patch  string
+		want   string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"remove one of two", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"array replaces", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"replace with array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"nested", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"arrays are not merged", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"array target", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"object replaces array", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"null patch", `{"a":"foo"}`, `null`, `null`},
+		{"string patch", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"null member kept", `{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{"scalar target", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"nested null in new member", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{"missing key", ``, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"exact numbers", `{"n":12345678901234567890}`, `{"m":0.1}`, `{"m":0.1,"n":12345678901234567890}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target any
+			if tt.target != "" {
+				var err error
+				if target, err = decodeJSONDocument([]byte(tt.target)); err != nil {
+					t.Fatalf("decode target: %v", err)
+				}
+			}
+			patch, err := decodeJSONDocument([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("decode patch: %v", err)
+			}
+
+			got, err := json.Marshal(mergePatch(target, patch))
+			if err != nil {
+				t.Fatalf("encode result: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergePatch(%s, %s) = %s, want %s", tt.target, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"object", `{"a":1}`, false},
+		{"scalar", `"a"`, false},
+		{"surrounding space", " {} \n", false},
+		{"empty", ``, true},
+		{"invalid", `{"a":`, true},
+		{"two values", `{} {}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeJSONDocument([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeJSONDocument(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRejectMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  bool
+	}{
+		{"no metadata", nil, false},
+		{"other patch mode", map[string]string{patchMetadataKey: "json"}, false},
+		{"merge patch", map[string]string{patchMetadataKey: mergePatchMode}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rejectMergePatch("BulkSet", "key", tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rejectMergePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
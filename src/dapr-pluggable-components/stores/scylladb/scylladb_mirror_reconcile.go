@@ -0,0 +1,448 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Time a difference is given to resolve itself before it counts: writes still
+// queued for the mirror when a key is compared are not divergence
+const mirrorReconcileRecheckDelay = 10 * time.Second
+
+// MirrorReconcileReport counts the keys one reconciliation of the mirror
+// cluster compared and the differences it found. Keys are stored partition
+// keys.
+type MirrorReconcileReport struct {
+	Full     bool   `json:"full"`
+	Checked  int64  `json:"checked"`  // keys read on both clusters
+	Missing  int64  `json:"missing"`  // on the primary only
+	Diverged int64  `json:"diverged"` // with another ETag on the mirror
+	Orphaned int64  `json:"orphaned"` // on the mirror only
+	Repaired int64  `json:"repaired"`
+	Duration string `json:"duration"`
+}
+
+// Divergent returns the number of keys that differ between the clusters.
+func (r MirrorReconcileReport) Divergent() int64 {
+	return r.Missing + r.Diverged + r.Orphaned
+}
+
+// mirrorReconciler periodically compares a sample of keys between the primary
+// and the mirror cluster, since dropped or failed mirror writes are otherwise
+// only counted. With repair set, keys that differ are rewritten on the mirror
+// from the primary.
+type mirrorReconciler struct {
+	interval time.Duration
+	sample   int
+	repair   bool
+	job      *backgroundJob
+
+	rounds   atomic.Int64
+	checked  atomic.Int64
+	missing  atomic.Int64
+	diverged atomic.Int64
+	orphaned atomic.Int64
+	repaired atomic.Int64
+	last     atomic.Pointer[MirrorReconcileReport]
+}
+
+// mirrorRow is the version of a key on one cluster.
+type mirrorRow struct {
+	etag      string
+	writeTime int64 // microseconds
+}
+
+// initMirrorReconcile parses the reconciliation settings and starts the job.
+// With leader election enabled only the replica holding the mirrorReconcile
+// lease compares keys.
+func (store *ScyllaStateStore) initMirrorReconcile() {
+	mirror := store.clusterMirror
+	if mirror == nil {
+		store.logger.Warnf("mirrorReconcileInterval is set without mirrorHosts, ignoring it")
+		return
+	}
+
+	interval, err := time.ParseDuration(store.config.MirrorReconcileInterval)
+	if err != nil || interval <= 0 {
+		store.logger.Warnf("Invalid mirrorReconcileInterval: %s, using default", store.config.MirrorReconcileInterval)
+		interval = 10 * time.Minute
+	}
+	sample, err := strconv.Atoi(store.config.MirrorReconcileSample)
+	if err != nil || sample <= 0 {
+		store.logger.Warnf("Invalid mirrorReconcileSample: %s, using default", store.config.MirrorReconcileSample)
+		sample = 1000
+	}
+
+	reconciler := &mirrorReconciler{
+		interval: interval,
+		sample:   sample,
+		repair:   store.config.MirrorReconcileRepair == "true",
+	}
+	mirror.reconciler = reconciler
+
+	store.logger.Infof("Reconciling %d sampled keys with the mirror cluster every %v (repair=%t)", sample, interval, reconciler.repair)
+
+	reconciler.job = store.startBackgroundJob("mirrorReconcile", func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if mirror.session.Load() == nil {
+				continue
+			}
+			report, err := store.reconcileMirror(ctx, reconciler.sample, reconciler.repair)
+			if err != nil {
+				if ctx.Err() == nil {
+					store.logger.Warnf("Mirror reconciliation failed: %v", err)
+				}
+				continue
+			}
+			reconciler.record(report)
+			if report.Divergent() > 0 {
+				store.logger.Warnf("Mirror reconciliation: %d of %d sampled keys differ (missing=%d, diverged=%d, orphaned=%d, repaired=%d)",
+					report.Divergent(), report.Checked, report.Missing, report.Diverged, report.Orphaned, report.Repaired)
+			} else {
+				store.logger.Debugf("Mirror reconciliation: %d sampled keys match", report.Checked)
+			}
+		}
+	})
+}
+
+// MirrorConnected reports whether the mirror cluster set by mirrorHosts is
+// connected; the mirror connects in the background after Init.
+func (store *ScyllaStateStore) MirrorConnected() bool {
+	store.mu.RLock()
+	mirror := store.clusterMirror
+	store.mu.RUnlock()
+	return mirror != nil && mirror.session.Load() != nil
+}
+
+// ReconcileMirror compares every key of the table with the mirror cluster.
+// With repair, keys that differ are rewritten on the mirror from the primary,
+// and keys only on the mirror are deleted there.
+func (store *ScyllaStateStore) ReconcileMirror(ctx context.Context, repair bool) (MirrorReconcileReport, error) {
+	store.mu.RLock()
+	mirror := store.clusterMirror
+	store.mu.RUnlock()
+
+	if mirror == nil {
+		return MirrorReconcileReport{}, errors.New("no mirror cluster configured; set mirrorHosts")
+	}
+	if mirror.session.Load() == nil {
+		return MirrorReconcileReport{}, fmt.Errorf("mirror cluster %v not connected", mirror.hosts)
+	}
+
+	report, err := store.reconcileMirror(ctx, 0, repair)
+	if err == nil && mirror.reconciler != nil {
+		mirror.reconciler.record(report)
+	}
+	return report, err
+}
+
+// reconcileMirror compares up to sample keys of each cluster, starting at a
+// random token, or every key when sample is 0. Keys read on the primary are
+// looked up on the mirror and the other way round; differences are read again
+// after mirrorReconcileRecheckDelay and only count if they remain.
+func (store *ScyllaStateStore) reconcileMirror(ctx context.Context, sample int, repair bool) (MirrorReconcileReport, error) {
+	start := time.Now()
+	report := MirrorReconcileReport{Full: sample == 0}
+
+	store.mu.RLock()
+	primary := store.session
+	mirror := store.clusterMirror
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || primary == nil {
+		return report, errors.New("store is closed")
+	}
+	secondary := mirror.session.Load()
+	if secondary == nil {
+		return report, errors.New("mirror cluster not connected")
+	}
+
+	// Keys of the primary missing or different on the mirror, then keys of the
+	// mirror missing on the primary; keys on both are compared once
+	var candidates []string
+	for _, side := range []struct {
+		session, other *gocql.Session
+		orphansOnly    bool
+	}{
+		{primary, secondary, false},
+		{secondary, primary, true},
+	} {
+		keys, err := store.sampleKeys(ctx, side.session, sample)
+		if err != nil {
+			return report, err
+		}
+		for batchStart := 0; batchStart < len(keys); batchStart += store.inBatchSize() {
+			batch := keys[batchStart:min(batchStart+store.inBatchSize(), len(keys))]
+			differ, checked, err := store.compareMirrorKeys(ctx, side.session, side.other, batch, side.orphansOnly)
+			if err != nil {
+				return report, err
+			}
+			report.Checked += checked
+			candidates = append(candidates, differ...)
+		}
+	}
+
+	if len(candidates) > 0 {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-time.After(mirrorReconcileRecheckDelay):
+		}
+	}
+	if err := store.recheckMirrorKeys(ctx, primary, secondary, candidates, repair, &report); err != nil {
+		return report, err
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	return report, nil
+}
+
+// sampleKeys returns up to sample keys of a cluster from a random token on,
+// wrapping around to the start of the ring, or every key when sample is 0.
+func (store *ScyllaStateStore) sampleKeys(ctx context.Context, session *gocql.Session, sample int) ([]string, error) {
+	cursor := ""
+	if sample > 0 {
+		cursor = strconv.FormatInt(int64(rand.Uint64()), 10) + ":"
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for pass := 0; pass < 2; pass++ {
+		iter, err := store.newKeyIterator(session, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next(ctx) {
+			if seen[iter.Key()] {
+				return keys, nil
+			}
+			seen[iter.Key()] = true
+			keys = append(keys, iter.Key())
+			if sample > 0 && len(keys) >= sample {
+				return keys, nil
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+		if cursor == "" {
+			break
+		}
+		// Wrap around from the start of the ring
+		cursor = ""
+	}
+	return keys, nil
+}
+
+// compareMirrorKeys reads keys, taken from the source cluster, on both
+// clusters and returns the ones that differ. Keys gone from the source
+// meanwhile are not counted, and with orphansOnly neither are keys found on
+// the other cluster.
+func (store *ScyllaStateStore) compareMirrorKeys(ctx context.Context, source, other *gocql.Session, keys []string, orphansOnly bool) ([]string, int64, error) {
+	sourceRows, err := store.readMirrorRows(ctx, source, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+	otherRows, err := store.readMirrorRows(ctx, other, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		differ  []string
+		checked int64
+	)
+	for _, key := range keys {
+		row, ok := sourceRows[key]
+		if !ok {
+			continue
+		}
+		otherRow, onOther := otherRows[key]
+		if orphansOnly && onOther {
+			continue
+		}
+		checked++
+		if !onOther || otherRow.etag != row.etag {
+			differ = append(differ, key)
+		}
+	}
+	return differ, checked, nil
+}
+
+// recheckMirrorKeys compares keys that differed once more, counts the ones
+// that still differ and, with repair, rewrites them on the mirror.
+func (store *ScyllaStateStore) recheckMirrorKeys(ctx context.Context, primary, secondary *gocql.Session, keys []string, repair bool, report *MirrorReconcileReport) error {
+	mirror := store.clusterMirror
+	for batchStart := 0; batchStart < len(keys); batchStart += store.inBatchSize() {
+		batch := keys[batchStart:min(batchStart+store.inBatchSize(), len(keys))]
+
+		// Deletes of orphaned keys are timestamped before the primary is read, so
+		// a key created on the primary after the read wins on the mirror
+		checkedAt := time.Now()
+		primaryRows, err := store.readMirrorRows(ctx, primary, batch)
+		if err != nil {
+			return err
+		}
+		mirrorRows, err := store.readMirrorRows(ctx, secondary, batch)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range batch {
+			primaryRow, onPrimary := primaryRows[key]
+			mirrorRow, onMirror := mirrorRows[key]
+			var op mirrorOp
+			switch {
+			case onPrimary && !onMirror:
+				report.Missing++
+			case !onPrimary && onMirror:
+				report.Orphaned++
+				op = mirrorOp{key: key, delete: true, writtenAt: checkedAt}
+			case onPrimary && primaryRow.etag != mirrorRow.etag:
+				report.Diverged++
+			default:
+				continue
+			}
+			store.logger.Debugf("Key %s differs on the mirror cluster (primary=%t, mirror=%t)", key, onPrimary, onMirror)
+			if !repair {
+				continue
+			}
+
+			if onPrimary {
+				if op, err = store.mirrorRepairOp(ctx, primary, key, mirrorRow); err != nil {
+					return err
+				}
+				if op.key == "" {
+					// Deleted on the primary meanwhile; its delete is queued for the mirror
+					continue
+				}
+			}
+			if err := mirror.apply(secondary, op); err != nil {
+				store.logger.Warnf("Failed to repair key %s on the mirror cluster: %v", key, err)
+				continue
+			}
+			report.Repaired++
+		}
+	}
+	return nil
+}
+
+// mirrorRepairOp reads the primary's row of key as a mirror write. It keeps
+// the row's write timestamp, so a newer write of the key still wins on the
+// mirror, unless the mirror holds a newer cell that would hide the repair.
+func (store *ScyllaStateStore) mirrorRepairOp(ctx context.Context, primary *gocql.Session, key string, mirrorRow mirrorRow) (mirrorOp, error) {
+	var (
+		text      string
+		blob      []byte
+		etag      string
+		ttl       int
+		writeTime int64
+	)
+	query := fmt.Sprintf("SELECT value, value_blob, etag, TTL(etag), WRITETIME(etag) FROM %s WHERE key = ?", store.config.Table)
+	err := store.withRetry(ctx, fmt.Sprintf("read key %s for mirror repair", key), func() error {
+		return primary.Query(query, key).WithContext(ctx).Scan(&text, &blob, &etag, &ttl, &writeTime)
+	})
+	if err == gocql.ErrNotFound {
+		return mirrorOp{}, nil
+	}
+	if err != nil {
+		return mirrorOp{}, fmt.Errorf("failed to read key %s for mirror repair: %w", key, err)
+	}
+
+	writtenAt := max(writeTime, mirrorRow.writeTime+1)
+	return mirrorOp{key: key, value: storedValue(text, blob), etag: etag, ttl: ttl, writtenAt: time.UnixMicro(writtenAt)}, nil
+}
+
+// readMirrorRows reads the ETag and its write time of keys on one cluster.
+func (store *ScyllaStateStore) readMirrorRows(ctx context.Context, session *gocql.Session, keys []string) (map[string]mirrorRow, error) {
+	rows := make(map[string]mirrorRow, len(keys))
+	if len(keys) == 0 {
+		return rows, nil
+	}
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	query := store.inQuery("key, etag, WRITETIME(etag)", len(keys))
+	err := store.withRetry(ctx, "read keys for mirror reconciliation", func() error {
+		clear(rows)
+		iter := session.Query(query, args...).WithContext(ctx).Iter()
+		var (
+			key string
+			row mirrorRow
+		)
+		for iter.Scan(&key, &row.etag, &row.writeTime) {
+			rows[key] = row
+		}
+		return iter.Close()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys for mirror reconciliation: %w", err)
+	}
+	return rows, nil
+}
+
+func (r *mirrorReconciler) record(report MirrorReconcileReport) {
+	r.rounds.Add(1)
+	r.checked.Add(report.Checked)
+	r.missing.Add(report.Missing)
+	r.diverged.Add(report.Diverged)
+	r.orphaned.Add(report.Orphaned)
+	r.repaired.Add(report.Repaired)
+	r.last.Store(&report)
+}
+
+func (r *mirrorReconciler) stop() {
+	if r.job != nil {
+		r.job.stop()
+	}
+}
+
+// gauges returns the reconciliation counters for the metrics endpoint.
+func (r *mirrorReconciler) gauges() map[string]float64 {
+	gauges := map[string]float64{
+		"mirror_reconcile_checked_total":  float64(r.checked.Load()),
+		"mirror_reconcile_missing_total":  float64(r.missing.Load()),
+		"mirror_reconcile_diverged_total": float64(r.diverged.Load()),
+		"mirror_reconcile_orphaned_total": float64(r.orphaned.Load()),
+		"mirror_reconcile_repaired_total": float64(r.repaired.Load()),
+	}
+	if last := r.last.Load(); last != nil && last.Checked > 0 {
+		gauges["mirror_divergence_ratio"] = float64(last.Divergent()) / float64(last.Checked)
+	}
+	return gauges
+}
+
+func (r *mirrorReconciler) diagnostics() map[string]any {
+	diagnostics := map[string]any{
+		"interval": r.interval.String(),
+		"sample":   r.sample,
+		"repair":   r.repair,
+		"rounds":   r.rounds.Load(),
+		"checked":  r.checked.Load(),
+		"missing":  r.missing.Load(),
+		"diverged": r.diverged.Load(),
+		"orphaned": r.orphaned.Load(),
+		"repaired": r.repaired.Load(),
+	}
+	if last := r.last.Load(); last != nil {
+		diagnostics["last"] = *last
+	}
+	return diagnostics
+}
@@ -0,0 +1,75 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dapr/components-contrib/state"
+
+	"nebulagraph/stores/stateext"
+)
+
+// Request metadata key choosing whether deleting a missing key succeeds
+const ignoreNotFoundMetadataKey = "ignoreNotFound"
+
+// Deletes follow one contract whether or not an ETag is given:
+//
+//   - ignoreNotFound=true (the default): deleting a missing key succeeds;
+//   - ignoreNotFound=false: deleting a missing key fails with a not found error;
+//   - an ETag that does not match an existing key fails with an ETag mismatch.
+//
+// BulkDelete applies the contract per request and checks every key before
+// deleting any of them.
+
+// ignoreNotFound reports whether a delete of a missing key succeeds.
+func (store *ScyllaStateStore) ignoreNotFound(metadata map[string]string) bool {
+	value, ok := metadata[ignoreNotFoundMetadataKey]
+	if !ok {
+		return true
+	}
+	ignore, err := strconv.ParseBool(value)
+	if err != nil {
+		store.logger.Warnf("Invalid %s request metadata: %s, using default", ignoreNotFoundMetadataKey, value)
+		return true
+	}
+	return ignore
+}
+
+func keyNotFoundError(key string) error {
+	return fmt.Errorf("key %s not found", key)
+}
+
+// checkBulkDelete applies the delete contract to the requests of a batched
+// BulkDelete that carry an ETag or disable ignoreNotFound, reading their
+// current ETags in one snapshot. The first violation fails the whole request.
+func (store *ScyllaStateStore) checkBulkDelete(ctx context.Context, req []state.DeleteRequest) error {
+	var checked []state.DeleteRequest
+	var keys []string
+	for _, delReq := range req {
+		if delReq.ETag != nil || !store.ignoreNotFound(delReq.Metadata) {
+			checked = append(checked, delReq)
+			keys = append(keys, store.storageKey(delReq.Key))
+		}
+	}
+	if len(checked) == 0 {
+		return nil
+	}
+
+	snapshot, err := store.readETagSnapshot(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("failed to read etag snapshot: %w", err)
+	}
+
+	for i, delReq := range checked {
+		currentEtag, exists := snapshot[keys[i]]
+		switch {
+		case !exists && !store.ignoreNotFound(delReq.Metadata):
+			return keyNotFoundError(delReq.Key)
+		case exists && delReq.ETag != nil && currentEtag != *delReq.ETag:
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", delReq.Key, *delReq.ETag, currentEtag), currentEtag)
+		}
+	}
+	return nil
+}
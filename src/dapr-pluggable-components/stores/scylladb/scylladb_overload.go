@@ -0,0 +1,213 @@
+package scylladb
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Load levels of overload shedding. Each level halves the bulk concurrency
+// and the IN batch size of the level below; the last one also sheds
+// bulk-class requests.
+const (
+	overloadNone = iota
+	overloadReduced
+	overloadMinimal
+	overloadShedding
+)
+
+var overloadLevelNames = [...]string{"none", "reduced", "minimal", "shedding"}
+
+// Smallest IN batch under overload
+const minOverloadBatchSize = 10
+
+// overloadDetector follows the overload signals of the cluster: coordinator
+// overloaded and rate limit errors, and server and client timeouts. When
+// overloadThreshold signals arrive within overloadWindow it raises the load
+// level by one, and it lowers the level by one after each overloadRecovery
+// without signals, so throughput comes back gradually.
+type overloadDetector struct {
+	threshold int
+	window    time.Duration
+	recovery  time.Duration
+
+	level      atomic.Int32
+	mu         sync.Mutex
+	recent     []time.Time // signals within window
+	lastSignal time.Time
+	lastChange time.Time
+
+	signals atomic.Int64
+	shed    atomic.Int64
+	raised  atomic.Int64
+}
+
+// initOverloadShedding parses the overload settings.
+func (store *ScyllaStateStore) initOverloadShedding() {
+	threshold, err := strconv.Atoi(store.config.OverloadThreshold)
+	if err != nil || threshold <= 0 {
+		store.logger.Warnf("Invalid overloadThreshold: %s, using default", store.config.OverloadThreshold)
+		threshold = 20
+	}
+	window, err := time.ParseDuration(store.config.OverloadWindow)
+	if err != nil || window <= 0 {
+		store.logger.Warnf("Invalid overloadWindow: %s, using default", store.config.OverloadWindow)
+		window = 10 * time.Second
+	}
+	recovery, err := time.ParseDuration(store.config.OverloadRecovery)
+	if err != nil || recovery <= 0 {
+		store.logger.Warnf("Invalid overloadRecovery: %s, using default", store.config.OverloadRecovery)
+		recovery = 30 * time.Second
+	}
+
+	store.overload = &overloadDetector{threshold: threshold, window: window, recovery: recovery}
+	store.logger.Infof("Overload shedding enabled (threshold=%d signals per %v, recovery=%v)", threshold, window, recovery)
+}
+
+// isOverloadSignal reports whether err shows that the cluster cannot keep up.
+func isOverloadSignal(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gocql.ErrTimeoutNoResponse) {
+		return true
+	}
+	var rateLimited *gocql.RequestErrRateLimitReached
+	if errors.As(err, &rateLimited) {
+		return true
+	}
+	var requestErr gocql.RequestError
+	if !errors.As(err, &requestErr) {
+		return false
+	}
+	switch requestErr.Code() {
+	case gocql.ErrCodeOverloaded, gocql.ErrCodeReadTimeout, gocql.ErrCodeWriteTimeout:
+		return true
+	}
+	return false
+}
+
+// observeOverload counts err when it is an overload signal.
+func (store *ScyllaStateStore) observeOverload(err error) {
+	detector := store.overload
+	if detector == nil || !isOverloadSignal(err) {
+		return
+	}
+	detector.signals.Add(1)
+
+	now := time.Now()
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	detector.lastSignal = now
+	recent := detector.recent[:0]
+	for _, at := range detector.recent {
+		if now.Sub(at) < detector.window {
+			recent = append(recent, at)
+		}
+	}
+	detector.recent = append(recent, now)
+
+	level := int(detector.level.Load())
+	if len(detector.recent) < detector.threshold || level == overloadShedding {
+		return
+	}
+	// Each level gets a full window of signals before the next one
+	detector.recent = detector.recent[:0]
+	detector.lastChange = now
+	detector.level.Store(int32(level + 1))
+	detector.raised.Add(1)
+	store.logger.Warnf("Backend overloaded (%v): load level raised to %s", err, overloadLevelNames[level+1])
+}
+
+// overloadLevel returns the current load level, lowering it first when the
+// cluster has been quiet for the recovery interval.
+func (store *ScyllaStateStore) overloadLevel() int {
+	detector := store.overload
+	if detector == nil || detector.level.Load() == overloadNone {
+		return overloadNone
+	}
+
+	now := time.Now()
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	level := int(detector.level.Load())
+	quietSince := detector.lastSignal
+	if detector.lastChange.After(quietSince) {
+		quietSince = detector.lastChange
+	}
+	if level > overloadNone && now.Sub(quietSince) >= detector.recovery {
+		level--
+		detector.lastChange = now
+		detector.level.Store(int32(level))
+		store.logger.Warnf("No overload signals for %v: load level lowered to %s", detector.recovery, overloadLevelNames[level])
+	}
+	return level
+}
+
+// overloadScaled reduces n by the current load level, keeping at least floor.
+func (store *ScyllaStateStore) overloadScaled(n, floor int) int {
+	level := store.overloadLevel()
+	if level > overloadMinimal {
+		level = overloadMinimal
+	}
+	return max(n>>level, min(n, floor))
+}
+
+// inBatchSize returns the number of keys per IN query of bulk reads.
+func (store *ScyllaStateStore) inBatchSize() int {
+	return store.overloadScaled(bulkGetBatchSize, minOverloadBatchSize)
+}
+
+// shedOverloaded rejects bulk-class requests while the load level sheds them.
+func (store *ScyllaStateStore) shedOverloaded(op string, class priorityClass) error {
+	if class != priorityBulk || store.overloadLevel() < overloadShedding {
+		return nil
+	}
+	store.overload.shed.Add(1)
+	store.priorities.shed[class].Add(1)
+	store.logger.Debugf("Shedding %s request of priority %s while the backend is overloaded", op, class)
+
+	retryAfter := min(max(store.overload.recovery, minShedRetryAfter), maxShedRetryAfter)
+	return &loadShedError{op: op, class: class, retryAfter: retryAfter, overload: true}
+}
+
+// LoadState reports the overload level for the readiness endpoint. Shedding
+// bulk requests does not make the store unready.
+func (store *ScyllaStateStore) LoadState() map[string]any {
+	if store.overload == nil {
+		return nil
+	}
+	level := store.overloadLevel()
+	return map[string]any{
+		"level":        overloadLevelNames[level],
+		"sheddingBulk": level >= overloadShedding,
+	}
+}
+
+// gauges returns the overload counters for the metrics endpoint.
+func (d *overloadDetector) gauges() map[string]float64 {
+	return map[string]float64{
+		"overload_level":         float64(d.level.Load()),
+		"overload_signals_total": float64(d.signals.Load()),
+		"overload_raised_total":  float64(d.raised.Load()),
+		"overload_shed_total":    float64(d.shed.Load()),
+	}
+}
+
+func (d *overloadDetector) diagnostics() map[string]any {
+	return map[string]any{
+		"level":     overloadLevelNames[d.level.Load()],
+		"threshold": d.threshold,
+		"window":    d.window.String(),
+		"recovery":  d.recovery.String(),
+		"signals":   d.signals.Load(),
+		"raised":    d.raised.Load(),
+		"shed":      d.shed.Load(),
+	}
+}
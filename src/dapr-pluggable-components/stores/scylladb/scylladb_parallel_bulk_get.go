@@ -0,0 +1,168 @@
+package scylladb
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Keys per IN query of BulkGet, as ScyllaDB recommends for IN queries
+const bulkGetBatchSize = 100
+
+// parallelBulkGet reports whether a BulkGet of n keys reads its batches in
+// parallel instead of one after another.
+func (store *ScyllaStateStore) parallelBulkGet(n int) bool {
+	return store.bulkGetParallelThreshold > 0 && n > store.bulkGetParallelThreshold
+}
+
+// groupKeysByReplica splits keys into batches of at most inBatchSize
+// whose partitions belong to the same host, so each IN query can be routed to
+// a replica of all its keys and the coordinator does not fan out. Batches
+// hold indexes into keys. Without a token ring, e.g. when the session is
+// injected, keys are grouped into contiguous token ranges instead.
+func (store *ScyllaStateStore) groupKeysByReplica(keys []string) [][]int {
+	tokens := make([]int64, len(keys))
+	for i, key := range keys {
+		tokens[i] = murmur3Token(stringToBytes(key))
+	}
+
+	var ring *tokenRing
+	if store.hosts != nil {
+		ring = store.hosts.tokenRing()
+	}
+
+	groups := make(map[string][]int)
+	var owners []string
+	for i := range keys {
+		owner := ""
+		if ring != nil {
+			owner = ring.owner(tokens[i])
+		}
+		if _, exists := groups[owner]; !exists {
+			owners = append(owners, owner)
+		}
+		groups[owner] = append(groups[owner], i)
+	}
+
+	batchSize := store.inBatchSize()
+	var batches [][]int
+	for _, owner := range owners {
+		indexes := groups[owner]
+		slices.SortFunc(indexes, func(a, b int) int { return cmp.Compare(tokens[a], tokens[b]) })
+		for start := 0; start < len(indexes); start += batchSize {
+			end := min(start+batchSize, len(indexes))
+			batches = append(batches, indexes[start:end])
+		}
+	}
+	return batches
+}
+
+// fetchRowsParallel is fetchRows for large BulkGets: the keys are grouped by
+// replica and up to bulkConcurrency IN queries run at once. fn is called for
+// one row at a time, in no particular order; callers place rows by key, so
+// the response keeps the order of the request. When fn returns false or a
+// query fails the remaining queries are cancelled. The returned slice tells
+// which keys belong to a batch that was read completely.
+func (store *ScyllaStateStore) fetchRowsParallel(ctx context.Context, keys []string, fn func(key string, value []byte, etag string) bool) ([]bool, error) {
+	batches := store.groupKeysByReplica(keys)
+	store.logger.Debugf("Reading %d keys in %d parallel batches", len(keys), len(batches))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex // serializes fn and the fields below
+		stopped   bool
+		firstErr  error
+		completed = make([]bool, len(keys))
+		wg        sync.WaitGroup
+		slots     = make(chan struct{}, max(store.bulkParallelism(0), 1))
+	)
+
+	read := func(batch []int) {
+		batchKeys := make([]interface{}, len(batch))
+		for i, index := range batch {
+			batchKeys[i] = keys[index]
+		}
+
+		row := newRowScan(ctx)
+		stmt, err := store.hookedQuery(ctx, "bulk get", store.inQuery(row.columns(), len(batch)), batchKeys...)
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil && !stopped {
+				firstErr = err
+			}
+			stopped = true
+			mu.Unlock()
+			cancel()
+			return
+		}
+		// Route the query to a replica of the batch
+		iter := stmt.RoutingKey(stringToBytes(keys[batch[0]])).Iter()
+
+		aborted := false
+		for iter.Scan(row.dest()...) {
+			mu.Lock()
+			if stopped || !fn(row.key, row.value(), row.etag) {
+				stopped = true
+				aborted = true
+			}
+			mu.Unlock()
+			if aborted {
+				cancel()
+				break
+			}
+		}
+		err = iter.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case aborted:
+		case err != nil:
+			if firstErr == nil && !stopped {
+				firstErr = err
+			}
+			stopped = true
+			cancel()
+		default:
+			for _, index := range batch {
+				completed[index] = true
+			}
+		}
+	}
+
+	for _, batch := range batches {
+		slots <- struct{}{}
+		mu.Lock()
+		done := stopped
+		mu.Unlock()
+		if done {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(batch []int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			read(batch)
+		}(batch)
+	}
+	wg.Wait()
+
+	return completed, firstErr
+}
+
+// parseBulkGetParallelThreshold parses the key count above which BulkGet
+// reads in parallel.
+func (store *ScyllaStateStore) parseBulkGetParallelThreshold() {
+	n, err := strconv.Atoi(store.config.BulkGetParallelThreshold)
+	if err != nil || n < 0 {
+		store.logger.Warnf("Invalid bulkGetParallelThreshold: %s, using default", store.config.BulkGetParallelThreshold)
+		n = 1000
+	}
+	store.bulkGetParallelThreshold = n
+}
@@ -0,0 +1,267 @@
+package scylladb
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// numConns value that sizes the pool at Init
+const numConnsAuto = "auto"
+
+// Connections opened per CPU available to the component, and the most
+// numConns: auto ever chooses
+const (
+	autotuneConnsPerCPU = 2
+	autotuneMaxConns    = 32
+)
+
+// Time allowed for the shard count probe of one host
+const autotuneProbeTimeout = 5 * time.Second
+
+// poolAutotune records how numConns: auto sized the connection pool.
+type poolAutotune struct {
+	shards    int     // shards of the probed node, 0 when unknown
+	probed    string  // host whose shard count was read
+	cpuQuota  float64 // CPUs available to the component
+	cpuSource string  // where cpuQuota was read from
+	numConns  int
+}
+
+// autotunePool sizes the connections per host from the backend's shard count
+// and the CPUs this container may use. ScyllaDB serves each connection on one
+// shard (one core), so more connections than shards add nothing, and a
+// component limited to a few CPUs cannot keep many connections busy. The
+// driver keeps one connection per shard to hosts that report their shards;
+// the chosen value applies to hosts that do not, such as Cassandra nodes or
+// ScyllaDB behind a proxy that hides the sharding.
+func (store *ScyllaStateStore) autotunePool(cluster *gocql.ClusterConfig, hosts []string) {
+	tune := &poolAutotune{}
+	tune.cpuQuota, tune.cpuSource = containerCPUQuota()
+	cpus := int(math.Ceil(tune.cpuQuota))
+	if cpus < 1 {
+		cpus = 1
+	}
+
+	if store.config.Dialect != dialectCassandra {
+		var tlsConfig *tls.Config
+		if cluster.SslOpts != nil {
+			tlsConfig = cluster.SslOpts.Config
+		}
+		for _, host := range hosts {
+			ctx, cancel := context.WithTimeout(context.Background(), autotuneProbeTimeout)
+			shards, err := probeShardCount(ctx, host, tlsConfig)
+			cancel()
+			if err != nil {
+				store.logger.Debugf("Shard count probe of %s failed: %v", host, err)
+				continue
+			}
+			tune.shards, tune.probed = shards, host
+			break
+		}
+		if tune.probed == "" {
+			store.logger.Warnf("Could not read the shard count of any host, sizing the pool from CPUs only")
+		}
+	}
+
+	tune.numConns = autotuneConnsPerCPU * cpus
+	if tune.shards > 0 && tune.shards < tune.numConns {
+		tune.numConns = tune.shards
+	}
+	tune.numConns = min(tune.numConns, autotuneMaxConns)
+
+	cluster.NumConns = tune.numConns
+	store.autotune = tune
+	store.logger.Infof("Autotuned NumConns to %d (backend shards=%d, cpu quota=%.2f from %s, arch=%s/%s)",
+		tune.numConns, tune.shards, tune.cpuQuota, tune.cpuSource, runtime.GOOS, runtime.GOARCH)
+}
+
+// containerCPUQuota returns the CPUs the process may use: the cgroup CPU
+// limit when one is set, otherwise the CPUs of the machine.
+func containerCPUQuota() (float64, string) {
+	cpus := float64(runtime.NumCPU())
+
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			if quota, period, ok := parseCPUQuota(fields[0], fields[1]); ok {
+				return math.Min(quota/period, cpus), "cgroup v2"
+			}
+		}
+		return cpus, "runtime"
+	}
+
+	// cgroup v1: a quota of -1 means unlimited
+	for _, dir := range []string{"/sys/fs/cgroup/cpu", "/sys/fs/cgroup/cpu,cpuacct"} {
+		quotaData, err := os.ReadFile(dir + "/cpu.cfs_quota_us")
+		if err != nil {
+			continue
+		}
+		periodData, err := os.ReadFile(dir + "/cpu.cfs_period_us")
+		if err != nil {
+			continue
+		}
+		if quota, period, ok := parseCPUQuota(strings.TrimSpace(string(quotaData)), strings.TrimSpace(string(periodData))); ok {
+			return math.Min(quota/period, cpus), "cgroup v1"
+		}
+		break
+	}
+	return cpus, "runtime"
+}
+
+func parseCPUQuota(quotaText, periodText string) (float64, float64, bool) {
+	quota, err := strconv.ParseFloat(quotaText, 64)
+	if err != nil || quota <= 0 {
+		return 0, 0, false
+	}
+	period, err := strconv.ParseFloat(periodText, 64)
+	if err != nil || period <= 0 {
+		return 0, 0, false
+	}
+	return quota, period, true
+}
+
+// CQL native protocol v4 opcodes of the probe
+const (
+	cqlOpError     = 0x00
+	cqlOpOptions   = 0x05
+	cqlOpSupported = 0x06
+)
+
+// probeShardCount asks host for its supported options, which on ScyllaDB
+// include SCYLLA_NR_SHARDS, over a short-lived connection of its own: the
+// driver does not expose the value, and the pool must be sized before the
+// session connects.
+func probeShardCount(ctx context.Context, host string, tlsConfig *tls.Config) (int, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if tlsConfig != nil {
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(host)
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return 0, err
+		}
+		conn = tlsConn
+	}
+
+	// Header: version, flags, stream, opcode, body length
+	request := []byte{0x04, 0x00, 0x00, 0x01, cqlOpOptions, 0x00, 0x00, 0x00, 0x00}
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, err
+	}
+	length := binary.BigEndian.Uint32(header[5:9])
+	if length > 1<<20 {
+		return 0, fmt.Errorf("unexpected response of %d bytes", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, err
+	}
+
+	switch header[4] {
+	case cqlOpSupported:
+	case cqlOpError:
+		return 0, errors.New("host rejected the OPTIONS request")
+	default:
+		return 0, fmt.Errorf("unexpected response opcode %#x", header[4])
+	}
+
+	options, err := parseStringMultimap(body)
+	if err != nil {
+		return 0, err
+	}
+	values := options["SCYLLA_NR_SHARDS"]
+	if len(values) == 0 {
+		return 0, errors.New("host does not report SCYLLA_NR_SHARDS")
+	}
+	shards, err := strconv.Atoi(values[0])
+	if err != nil || shards <= 0 {
+		return 0, fmt.Errorf("invalid SCYLLA_NR_SHARDS %q", values[0])
+	}
+	return shards, nil
+}
+
+// parseStringMultimap decodes a CQL [string multimap].
+func parseStringMultimap(body []byte) (map[string][]string, error) {
+	readShort := func() (int, error) {
+		if len(body) < 2 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n := int(binary.BigEndian.Uint16(body))
+		body = body[2:]
+		return n, nil
+	}
+	readString := func() (string, error) {
+		n, err := readShort()
+		if err != nil {
+			return "", err
+		}
+		if len(body) < n {
+			return "", io.ErrUnexpectedEOF
+		}
+		s := string(body[:n])
+		body = body[n:]
+		return s, nil
+	}
+
+	entries, err := readShort()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string, entries)
+	for range entries {
+		key, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		count, err := readShort()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]string, count)
+		for i := range values {
+			if values[i], err = readString(); err != nil {
+				return nil, err
+			}
+		}
+		result[key] = values
+	}
+	return result, nil
+}
+
+func (t *poolAutotune) diagnostics() map[string]any {
+	return map[string]any{
+		"shards":    t.shards,
+		"probed":    t.probed,
+		"cpuQuota":  t.cpuQuota,
+		"cpuSource": t.cpuSource,
+		"numConns":  t.numConns,
+	}
+}
@@ -0,0 +1,190 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+
+	"nebulagraph/stores/stateext"
+)
+
+// Request metadata key that turns a Query into a delete-by-prefix operation
+const deletePrefixMetadataKey = "deletePrefix"
+
+// Number of matching keys deleted per round of partition batches
+const deletePrefixBatchSize = 500
+
+var errDeletePrefixToken = errors.New("deletePrefix queries require a valid adminToken")
+
+// DeleteWithPrefix deletes every key starting with req.Prefix. It is the
+// native form of the deletePrefix query, for callers that use the
+// DELETE_WITH_PREFIX feature; dryRun request metadata only counts the keys.
+func (store *ScyllaStateStore) DeleteWithPrefix(ctx context.Context, req stateext.DeleteWithPrefixRequest) (stateext.DeleteWithPrefixResponse, error) {
+	if err := req.Validate(); err != nil {
+		return stateext.DeleteWithPrefixResponse{}, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed {
+		return stateext.DeleteWithPrefixResponse{}, errors.New("store is closed")
+	}
+
+	if store.session == nil {
+		return stateext.DeleteWithPrefixResponse{}, errors.New("session not initialized")
+	}
+
+	ctx, err := store.admit(ctx, sloOpBulkDelete, store.requestPriority(priorityBulk, req.Metadata))
+	if err != nil {
+		return stateext.DeleteWithPrefixResponse{}, err
+	}
+
+	_, deleted, err := store.deleteWithPrefix(ctx, req.Prefix, store.isDryRun(req.Metadata))
+	if err != nil {
+		store.logger.Errorf("Delete with prefix %q failed after deleting %d keys: %v", req.Prefix, deleted, err)
+		return stateext.DeleteWithPrefixResponse{Count: deleted}, fmt.Errorf("delete with prefix failed after deleting %d keys: %w", deleted, err)
+	}
+	return stateext.DeleteWithPrefixResponse{Count: deleted}, nil
+}
+
+// deleteByPrefixQuery handles Query requests carrying deletePrefix metadata and
+// reports the outcome in the response metadata. Like provisioning, every
+// request must carry the adminToken configured on the component.
+func (store *ScyllaStateStore) deleteByPrefixQuery(ctx context.Context, prefix string, metadata map[string]string) (*state.QueryResponse, error) {
+	if !store.validAdminToken(metadata) {
+		store.logger.Warnf("Rejected deletePrefix query without a valid adminToken")
+		return nil, errDeletePrefixToken
+	}
+
+	ctx, err := store.admit(ctx, sloOpQuery, store.requestPriority(priorityBulk, metadata))
+	if err != nil {
+		store.logger.Warnf("Shedding deletePrefix query while store is degraded")
+		return nil, err
+	}
+
+	start := time.Now()
+	dryRun := store.isDryRun(metadata)
+	scanned, deleted, err := store.deleteWithPrefix(ctx, prefix, dryRun)
+	if err != nil {
+		store.logger.Errorf("Delete by prefix %q failed after deleting %d keys: %v", prefix, deleted, err)
+		return nil, fmt.Errorf("delete by prefix failed after deleting %d keys: %w", deleted, err)
+	}
+
+	return &state.QueryResponse{
+		Results: []state.QueryItem{},
+		Metadata: map[string]string{
+			"scanned":  strconv.FormatInt(scanned, 10),
+			"deleted":  strconv.FormatInt(deleted, 10),
+			"duration": time.Since(start).String(),
+			"dryRun":   strconv.FormatBool(dryRun),
+		},
+	}, nil
+}
+
+// deleteWithPrefix deletes every key starting with prefix using a token-range
+// scan of the table (see keyIterator) and batched partition deletes, logging progress after each batch.
+// Deleted keys are audited and their key group entries removed, as for Delete.
+// It returns the number of keys scanned and deleted (or, in dry-run mode, that
+// would be deleted). Callers must hold the store read lock.
+func (store *ScyllaStateStore) deleteWithPrefix(ctx context.Context, prefix string, dryRun bool) (int64, int64, error) {
+	if prefix == "" {
+		return 0, 0, errors.New("prefix cannot be empty")
+	}
+
+	// Prefixes are matched on Dapr keys, which hashed partition keys do not preserve
+	if store.keys != nil && !store.keys.reversible() {
+		return 0, 0, errors.New("delete by prefix is not supported with keyStrategy=hash")
+	}
+
+	// Buckets expire as a whole; the scan only covers the base table
+	if store.timeBuckets != nil {
+		return 0, 0, errors.New("delete by prefix is not supported with timeBuckets")
+	}
+
+	store.logger.Infof("Deleting keys with prefix %q", prefix)
+	defer store.invalidateQueryCache()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE key = ?", store.config.Table)
+	iter, err := store.newKeyIterator(store.session, "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var scanned, deleted int64
+	pending := make([]partitionStatement, 0, deletePrefixBatchSize)
+	pendingKeys := make([]string, 0, deletePrefixBatchSize)
+
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if dryRun {
+			for _, stmt := range pending {
+				store.logger.Infof("DRY RUN: would delete key %s", stmt.storageKey)
+			}
+			deleted += int64(len(pending))
+			pending = pending[:0]
+			pendingKeys = pendingKeys[:0]
+			return nil
+		}
+		groups := store.groupByTokenRange(pending)
+		if err := store.executePartitionGroups(ctx, groups, store.bulkParallelism(0), "delete by prefix"); err != nil {
+			return err
+		}
+		for i, stmt := range pending {
+			store.afterDelete(stmt.storageKey)
+			store.meterDelete(pendingKeys[i])
+			store.auditDelete(pendingKeys[i])
+		}
+		store.unindexKeyGroups(ctx, pendingKeys...)
+		deleted += int64(len(pending))
+		pending = pending[:0]
+		pendingKeys = pendingKeys[:0]
+		store.logger.Infof("Delete by prefix %q progress: scanned %d keys, deleted %d", prefix, scanned, deleted)
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		scanned++
+		storageKey := iter.Key()
+
+		daprKey := storageKey
+		if store.keys != nil {
+			var ok bool
+			if daprKey, ok = store.keys.fromStorage(storageKey); !ok {
+				continue
+			}
+		}
+		if !strings.HasPrefix(daprKey, prefix) {
+			continue
+		}
+
+		pending = append(pending, partitionStatement{
+			query:      query,
+			args:       []interface{}{storageKey},
+			storageKey: storageKey,
+		})
+		pendingKeys = append(pendingKeys, daprKey)
+		if len(pending) >= deletePrefixBatchSize {
+			if err := flush(); err != nil {
+				return scanned, deleted, err
+			}
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return scanned, deleted, err
+	}
+	if err := flush(); err != nil {
+		return scanned, deleted, err
+	}
+
+	store.logger.Infof("Delete by prefix %q completed: scanned %d keys, deleted %d", prefix, scanned, deleted)
+	return scanned, deleted, nil
+}
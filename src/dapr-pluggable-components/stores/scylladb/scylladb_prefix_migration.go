@@ -0,0 +1,324 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"nebulagraph/stores/stateext"
+)
+
+// Keys renamed per batch when the migration does not say
+const defaultPrefixMigrationBatchSize = 100
+
+// Compile time check to ensure ScyllaStateStore implements stateext.PrefixMigrator
+var _ stateext.PrefixMigrator = (*ScyllaStateStore)(nil)
+
+// Outcome of renaming one key
+type prefixMoveOutcome int
+
+const (
+	prefixMoved prefixMoveOutcome = iota
+	prefixMissing
+	prefixConflict
+	prefixChanged
+)
+
+// MigratePrefix renames every key starting with m.From in place, scanning the
+// table in token order (see keyIterator). Matching keys are renamed in
+// batches: each batch is written to the journal first, then its keys are
+// moved concurrently. A key keeps its value, ETag and remaining TTL; one
+// whose new name exists, or that is written while it is moved, is left in
+// place and reported.
+func (store *ScyllaStateStore) MigratePrefix(ctx context.Context, m stateext.PrefixMigration) (stateext.PrefixMigrationReport, error) {
+	var report stateext.PrefixMigrationReport
+	if err := m.Validate(); err != nil {
+		return report, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.checkPrefixMigration(); err != nil {
+		return report, err
+	}
+	batchSize := m.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPrefixMigrationBatchSize
+	}
+
+	store.logger.Infof("Renaming keys with prefix %q to %q (dryRun=%t)", m.From, m.To, m.DryRun)
+	defer store.invalidateQueryCache()
+
+	iter, err := store.newKeyIterator(store.session, "")
+	if err != nil {
+		return report, err
+	}
+
+	// New names that match again are skipped when the scan reaches them
+	renamed := make(map[string]bool)
+	batch := make([]stateext.PrefixMove, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := store.movePrefixBatch(ctx, batch, m, &report)
+		batch = batch[:0]
+		store.logger.Infof("Prefix migration progress: scanned %d keys, matched %d, moved %d", report.Scanned, report.Matched, report.Moved)
+		return err
+	}
+
+	for iter.Next(ctx) {
+		report.Scanned++
+		daprKey := iter.Key()
+		if store.keys != nil {
+			var ok bool
+			if daprKey, ok = store.keys.fromStorage(daprKey); !ok {
+				continue
+			}
+		}
+		if !m.Matches(daprKey) || renamed[daprKey] {
+			continue
+		}
+		report.Matched++
+		move := stateext.PrefixMove{From: daprKey, To: m.Rename(daprKey)}
+		if m.Matches(move.To) {
+			renamed[move.To] = true
+		}
+		batch = append(batch, move)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return report, err
+	}
+	if err := flush(); err != nil {
+		return report, err
+	}
+
+	store.logger.Infof("Prefix migration done: moved %d of %d keys, %d conflicts, %d changed, %d missing",
+		report.Moved, report.Matched, len(report.Conflicts), len(report.Changed), report.Missing)
+	return report, nil
+}
+
+// RollbackPrefixMigration renames the keys of a migration journal back.
+func (store *ScyllaStateStore) RollbackPrefixMigration(ctx context.Context, journal []stateext.PrefixMove, m stateext.PrefixMigration) (stateext.PrefixMigrationReport, error) {
+	var report stateext.PrefixMigrationReport
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if err := store.checkPrefixMigration(); err != nil {
+		return report, err
+	}
+	batchSize := m.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPrefixMigrationBatchSize
+	}
+
+	store.logger.Infof("Rolling back %d renamed keys (dryRun=%t)", len(journal), m.DryRun)
+	defer store.invalidateQueryCache()
+
+	// The rollback itself is not journaled: the journal already lists its moves
+	m.Journal = nil
+	for start := 0; start < len(journal); start += batchSize {
+		end := min(start+batchSize, len(journal))
+		batch := make([]stateext.PrefixMove, 0, end-start)
+		for _, move := range journal[start:end] {
+			batch = append(batch, stateext.PrefixMove{From: move.To, To: move.From})
+		}
+		report.Scanned += int64(len(batch))
+		report.Matched += int64(len(batch))
+		if err := store.movePrefixBatch(ctx, batch, m, &report); err != nil {
+			return report, err
+		}
+	}
+
+	store.logger.Infof("Prefix migration rollback done: moved %d of %d keys, %d conflicts, %d changed, %d missing",
+		report.Moved, report.Matched, len(report.Conflicts), len(report.Changed), report.Missing)
+	return report, nil
+}
+
+// checkPrefixMigration reports why keys cannot be renamed. Callers must hold
+// the store read lock.
+func (store *ScyllaStateStore) checkPrefixMigration() error {
+	if store.closed {
+		return errors.New("store is closed")
+	}
+	if store.session == nil {
+		return errors.New("session not initialized")
+	}
+	// Prefixes are matched on Dapr keys, which hashed partition keys do not preserve
+	if store.keys != nil && !store.keys.reversible() {
+		return errors.New("prefix migration is not supported with keyStrategy=hash")
+	}
+	// Bucket tables are not scanned; their keys expire with the bucket
+	if store.timeBuckets != nil {
+		return errors.New("prefix migration is not supported with timeBuckets")
+	}
+	return nil
+}
+
+// movePrefixBatch renames the keys of one batch and adds the outcome to
+// report. In dry-run mode it only looks for new names that already exist.
+func (store *ScyllaStateStore) movePrefixBatch(ctx context.Context, moves []stateext.PrefixMove, m stateext.PrefixMigration, report *stateext.PrefixMigrationReport) error {
+	if m.DryRun {
+		targets := make([]string, len(moves))
+		daprKeys := make(map[string]string, len(moves))
+		for i, move := range moves {
+			targets[i] = store.storageKey(move.To)
+			daprKeys[targets[i]] = move.From
+		}
+		existing := make(map[string]bool)
+		if _, err := store.fetchRows(ctx, targets, func(storageKey string, _ []byte, _ string) bool {
+			existing[storageKey] = true
+			return true
+		}); err != nil {
+			return fmt.Errorf("failed to check new key names: %w", err)
+		}
+		for _, move := range moves {
+			if existing[store.storageKey(move.To)] {
+				report.Conflicts = append(report.Conflicts, move.From)
+				continue
+			}
+			store.logger.Infof("DRY RUN: would rename key %s to %s", move.From, move.To)
+			report.Moved++
+		}
+		return nil
+	}
+
+	if err := stateext.WriteJournal(m.Journal, moves); err != nil {
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, store.bulkParallelism(0))
+	for _, move := range moves {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			outcome, err := store.moveKey(ctx, move)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to rename key %s: %w", move.From, err)
+				}
+				return
+			}
+			switch outcome {
+			case prefixMoved:
+				report.Moved++
+			case prefixMissing:
+				report.Missing++
+			case prefixConflict:
+				store.logger.Warnf("Key %s not renamed: %s already exists", move.From, move.To)
+				report.Conflicts = append(report.Conflicts, move.From)
+			case prefixChanged:
+				store.logger.Warnf("Key %s not renamed: it was written during the move", move.From)
+				report.Changed = append(report.Changed, move.From)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// moveKey copies one row to its new key with IF NOT EXISTS and deletes the
+// old row if its ETag is unchanged; otherwise the copy is removed again.
+// Lightweight transactions are not retried, since their outcome may be
+// unknown, but a copy with the row's own ETag counts as done, so a failed
+// migration can simply be run again.
+func (store *ScyllaStateStore) moveKey(ctx context.Context, move stateext.PrefixMove) (prefixMoveOutcome, error) {
+	from, to := store.storageKey(move.From), store.storageKey(move.To)
+	table := store.config.Table
+
+	var (
+		text         string
+		blob         []byte
+		etag         string
+		lastModified time.Time
+		ttl          int
+	)
+	readQuery := fmt.Sprintf("SELECT value, value_blob, etag, last_modified, TTL(etag) FROM %s WHERE key = ?", table)
+	stmt, err := store.hookedQuery(ctx, "migrate prefix", readQuery, from)
+	if err != nil {
+		return 0, err
+	}
+	if err := stmt.Scan(&text, &blob, &etag, &lastModified, &ttl); err != nil {
+		if err == gocql.ErrNotFound {
+			return prefixMissing, nil
+		}
+		return 0, err
+	}
+	value := storedBytes(text, blob)
+
+	// Group entries are recorded before the value, as for any write
+	if err := store.indexKeyGroups(ctx, move.To); err != nil {
+		return 0, err
+	}
+	if store.keyFilter != nil {
+		store.keyFilter.add(to)
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO %s (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?) IF NOT EXISTS USING TTL ?", table)
+	stmt, err = store.hookedQuery(ctx, "migrate prefix", insertQuery, to, value, etag, lastModified, ttl)
+	if err != nil {
+		return 0, err
+	}
+	existing := make(map[string]any)
+	applied, err := stmt.MapScanCAS(existing)
+	if err != nil {
+		return 0, err
+	}
+	if !applied && existing["etag"] != etag {
+		return prefixConflict, nil
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE key = ? IF etag = ?", table)
+	stmt, err = store.hookedQuery(ctx, "migrate prefix", deleteQuery, from, etag)
+	if err != nil {
+		return 0, err
+	}
+	if applied, err = stmt.MapScanCAS(make(map[string]any)); err != nil {
+		return 0, err
+	}
+	if !applied {
+		// The old key was written or deleted meanwhile; drop the copy unless it changed too
+		stmt, err = store.hookedQuery(ctx, "migrate prefix", deleteQuery, to, etag)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stmt.MapScanCAS(make(map[string]any)); err != nil {
+			return 0, err
+		}
+		return prefixChanged, nil
+	}
+
+	store.afterSet(move.To, to, bytesToString(value), etag, ttl)
+	store.afterDelete(from)
+	store.meterDelete(move.From)
+	store.meterWrite(move.To, len(value))
+	return prefixMoved, nil
+}
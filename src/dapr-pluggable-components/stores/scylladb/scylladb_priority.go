@@ -0,0 +1,130 @@
+package scylladb
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+)
+
+// Request metadata key tagging an operation with its priority class
+const priorityMetadataKey = "priority"
+
+// priorityClass orders requests by how long they are protected under
+// overload; lower classes are shed first.
+type priorityClass int
+
+const (
+	priorityBulk priorityClass = iota
+	priorityNormal
+	priorityCritical
+
+	// Shedding level that rejects nothing
+	priorityNone priorityClass = -1
+)
+
+var priorityNames = [...]string{"bulk", "normal", "critical"}
+
+func (class priorityClass) String() string {
+	if class < 0 || int(class) >= len(priorityNames) {
+		return "none"
+	}
+	return priorityNames[class]
+}
+
+// parsePriority maps a class name to its priority class.
+func parsePriority(name string) (priorityClass, bool) {
+	for class, className := range priorityNames {
+		if strings.EqualFold(name, className) {
+			return priorityClass(class), true
+		}
+	}
+	return priorityNone, false
+}
+
+// priorityCounters are the cumulative request and shed counts per class.
+type priorityCounters struct {
+	requests [len(priorityNames)]atomic.Int64
+	shed     [len(priorityNames)]atomic.Int64
+}
+
+// admittedKey marks a context whose request already passed admission, so
+// operations composed of other operations (such as a small BulkSet issuing
+// Sets) are classified and counted once.
+type admittedKey struct{}
+
+// requestPriority returns the class requested through the priority metadata
+// of any of metadata, taking the highest one, or fallback when none is set.
+func (store *ScyllaStateStore) requestPriority(fallback priorityClass, metadata ...map[string]string) priorityClass {
+	class, found := priorityNone, false
+	for _, md := range metadata {
+		value, ok := md[priorityMetadataKey]
+		if !ok {
+			continue
+		}
+		parsed, valid := parsePriority(value)
+		if !valid {
+			store.logger.Warnf("Invalid %s request metadata: %s, using default", priorityMetadataKey, value)
+			continue
+		}
+		if parsed > class {
+			class = parsed
+		}
+		found = true
+	}
+	if !found {
+		return fallback
+	}
+	return class
+}
+
+// requestsMetadata collects the metadata of every request of a bulk operation.
+func requestsMetadata[T interface{ GetMetadata() map[string]string }](requests []T) []map[string]string {
+	metadata := make([]map[string]string, len(requests))
+	for i := range requests {
+		metadata[i] = requests[i].GetMetadata()
+	}
+	return metadata
+}
+
+// admit classifies and counts a request, and rejects it with a loadShedError
+// while the store is degraded and the class is at or below
+// latencySloShedPriority. Queries are also shed below the critical class when
+// latencySloShedQueries is set, and bulk requests while overload shedding is
+// at its last level. The returned context marks the request as admitted.
+func (store *ScyllaStateStore) admit(ctx context.Context, op string, class priorityClass) (context.Context, error) {
+	if ctx.Value(admittedKey{}) != nil {
+		return ctx, nil
+	}
+	store.priorities.requests[class].Add(1)
+	if store.workload != nil {
+		store.workload.countOperation(op)
+	}
+
+	if tracker := store.slo; tracker != nil {
+		limit := tracker.shedPriority
+		if op == sloOpQuery && tracker.shedQueries && limit < priorityNormal {
+			limit = priorityNormal
+		}
+		if class <= limit && store.Degraded() {
+			store.priorities.shed[class].Add(1)
+			store.logger.Debugf("Shedding %s request of priority %s while store is degraded", op, class)
+			return ctx, &loadShedError{op: op, class: class, retryAfter: store.shedRetryAfter()}
+		}
+	}
+	if err := store.shedOverloaded(op, class); err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, admittedKey{}, class), nil
+}
+
+// priorityDiagnostics reports the request and shed counts of every class.
+func (store *ScyllaStateStore) priorityDiagnostics() map[string]any {
+	classes := make(map[string]any, len(priorityNames))
+	for class, name := range priorityNames {
+		classes[name] = map[string]any{
+			"requests": store.priorities.requests[class].Load(),
+			"shed":     store.priorities.shed[class].Load(),
+		}
+	}
+	return classes
+}
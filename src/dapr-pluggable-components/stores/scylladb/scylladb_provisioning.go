@@ -0,0 +1,207 @@
+package scylladb
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+// Request metadata keys of keyspace provisioning queries
+const (
+	provisionMetadataKey         = "provision"
+	provisionKeyspaceMetadataKey = "provisionKeyspace"
+	provisionTableMetadataKey    = "provisionTable"
+	adminTokenMetadataKey        = "adminToken"
+)
+
+// Provisioning actions
+const (
+	provisionCreate   = "create"
+	provisionDescribe = "describe"
+	provisionDrop     = "drop"
+)
+
+// Upper bound for provisioning DDL including schema agreement
+const provisionTimeout = time.Minute
+
+// Unquoted CQL identifiers; ScyllaDB limits names to 48 characters
+var cqlIdentifier = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,47}$`)
+
+var errAdminToken = errors.New("provisioning requires a valid adminToken")
+
+// keyspaceDDL returns the CREATE KEYSPACE statement of a keyspace with the
+// given replication.
+func keyspaceDDL(keyspace, strategy, factor string) string {
+	return fmt.Sprintf(`
+		CREATE KEYSPACE IF NOT EXISTS %s
+		WITH replication = {
+			'class': '%s',
+			'replication_factor': %s
+		}`, keyspace, strategy, factor)
+}
+
+// stateTableDDL returns the CREATE TABLE statement of a state table; name may
+// be keyspace-qualified.
+func stateTableDDL(name string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			key text PRIMARY KEY,
+			value text,
+			value_blob blob,
+			etag text,
+			last_modified timestamp
+		)`, name)
+}
+
+// validAdminToken reports whether metadata carries the adminToken configured
+// on the component. Without a configured token no request is valid.
+func (store *ScyllaStateStore) validAdminToken(metadata map[string]string) bool {
+	return store.config.AdminToken != "" &&
+		subtle.ConstantTimeCompare([]byte(metadata[adminTokenMetadataKey]), []byte(store.config.AdminToken)) == 1
+}
+
+// provisionQuery handles Query requests carrying provision metadata: it
+// creates, describes or drops additional keyspaces and state tables so tenants
+// can be onboarded without manual CQL. Every request must carry the adminToken
+// configured on the component; without one, provisioning is disabled. The
+// store's own keyspace and table cannot be dropped.
+func (store *ScyllaStateStore) provisionQuery(ctx context.Context, metadata map[string]string) (*state.QueryResponse, error) {
+	if !store.validAdminToken(metadata) {
+		store.logger.Warnf("Rejected provisioning request without a valid adminToken")
+		return nil, errAdminToken
+	}
+
+	action := metadata[provisionMetadataKey]
+	// Unquoted identifiers are case-insensitive and stored in lower case
+	keyspace := strings.ToLower(metadata[provisionKeyspaceMetadataKey])
+	table := strings.ToLower(metadata[provisionTableMetadataKey])
+	if strings.HasPrefix(keyspace, "system") {
+		return nil, fmt.Errorf("refusing to provision system keyspace %s", keyspace)
+	}
+	if !cqlIdentifier.MatchString(keyspace) {
+		return nil, fmt.Errorf("invalid %s: %q", provisionKeyspaceMetadataKey, keyspace)
+	}
+	if table != "" && !cqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid %s: %q", provisionTableMetadataKey, table)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, provisionTimeout)
+	defer cancel()
+
+	var result map[string]any
+	var err error
+	switch action {
+	case provisionCreate:
+		if table == "" {
+			table = store.config.Table
+		}
+		result, err = store.provisionCreate(ctx, keyspace, table)
+	case provisionDescribe:
+		result, err = store.describeKeyspace(ctx, keyspace)
+	case provisionDrop:
+		result, err = store.provisionDrop(ctx, keyspace, table, store.isDryRun(metadata))
+	default:
+		return nil, fmt.Errorf("invalid %s: %q (expected %s, %s or %s)",
+			provisionMetadataKey, action, provisionCreate, provisionDescribe, provisionDrop)
+	}
+	if err != nil {
+		store.logger.Errorf("Provisioning %s of keyspace %s failed: %v", action, keyspace, err)
+		return nil, err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &state.QueryResponse{
+		Results: []state.QueryItem{{Key: keyspace, Data: data}},
+	}, nil
+}
+
+// provisionCreate creates keyspace with the store's replication settings and a
+// state table in it. Both statements are idempotent.
+func (store *ScyllaStateStore) provisionCreate(ctx context.Context, keyspace, table string) (map[string]any, error) {
+	createKeyspaceQuery := keyspaceDDL(keyspace, store.config.ReplicationStrategy, store.config.ReplicationFactor)
+	if err := store.session.Query(createKeyspaceQuery).WithContext(ctx).Exec(); err != nil {
+		return nil, fmt.Errorf("failed to create keyspace %s: %w", keyspace, err)
+	}
+	if err := store.session.Query(stateTableDDL(keyspace + "." + table)).WithContext(ctx).Exec(); err != nil {
+		return nil, fmt.Errorf("failed to create table %s.%s: %w", keyspace, table, err)
+	}
+	if err := store.session.AwaitSchemaAgreement(ctx); err != nil {
+		return nil, fmt.Errorf("schema agreement after creating %s.%s: %w", keyspace, table, err)
+	}
+
+	store.logger.Infof("Provisioned keyspace %s with table %s", keyspace, table)
+	return store.describeKeyspace(ctx, keyspace)
+}
+
+// describeKeyspace reports the replication settings and tables of keyspace.
+func (store *ScyllaStateStore) describeKeyspace(ctx context.Context, keyspace string) (map[string]any, error) {
+	var replication map[string]string
+	err := store.session.Query(
+		"SELECT replication FROM system_schema.keyspaces WHERE keyspace_name = ?", keyspace).
+		WithContext(ctx).Scan(&replication)
+	if err != nil {
+		if err == gocql.ErrNotFound {
+			return map[string]any{"keyspace": keyspace, "exists": false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe keyspace %s: %w", keyspace, err)
+	}
+
+	tables := []string{}
+	var table string
+	iter := store.session.Query(
+		"SELECT table_name FROM system_schema.tables WHERE keyspace_name = ?", keyspace).
+		WithContext(ctx).Iter()
+	for iter.Scan(&table) {
+		tables = append(tables, table)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to list tables of keyspace %s: %w", keyspace, err)
+	}
+
+	return map[string]any{
+		"keyspace":    keyspace,
+		"exists":      true,
+		"replication": replication,
+		"tables":      tables,
+	}, nil
+}
+
+// provisionDrop drops table from keyspace, or the whole keyspace when table is
+// empty. The store's own keyspace can only lose tables other than its state
+// table.
+func (store *ScyllaStateStore) provisionDrop(ctx context.Context, keyspace, table string, dryRun bool) (map[string]any, error) {
+	if keyspace == store.config.Keyspace && (table == "" || table == store.config.Table) {
+		return nil, fmt.Errorf("refusing to drop the store's own keyspace or table %s.%s", keyspace, store.config.Table)
+	}
+
+	statement := "DROP KEYSPACE IF EXISTS " + keyspace
+	if table != "" {
+		statement = fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", keyspace, table)
+	}
+	result := map[string]any{"keyspace": keyspace, "statement": statement, "dryRun": dryRun}
+
+	if dryRun {
+		store.logger.Infof("Dry run: would execute %s", statement)
+		return result, nil
+	}
+	if err := store.session.Query(statement).WithContext(ctx).Exec(); err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w", statement, err)
+	}
+	if err := store.session.AwaitSchemaAgreement(ctx); err != nil {
+		return nil, fmt.Errorf("schema agreement after %s: %w", statement, err)
+	}
+
+	store.logger.Infof("Provisioning executed %s", statement)
+	return result, nil
+}
@@ -0,0 +1,21 @@
+package scylladb
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errQueryNotSupported is returned for state queries the store cannot answer.
+// It converts to a gRPC Unimplemented status, which the sidecar reports as an
+// unsupported operation rather than an internal error.
+var errQueryNotSupported = status.Error(codes.Unimplemented,
+	"state query API is not supported: filters and sorting are not translated to CQL; "+
+		"configure searchIndexUrl for full-text queries or set queryScan=true to list rows")
+
+// queryAPISupported reports whether state queries are advertised to the
+// sidecar: when full-text queries are answered by the search index, or when
+// queryScan opts in to listing rows. Administrative queries selected by
+// request metadata are accepted either way.
+func (store *ScyllaStateStore) queryAPISupported() bool {
+	return store.searchIndex != nil || store.config.QueryScan == "true"
+}
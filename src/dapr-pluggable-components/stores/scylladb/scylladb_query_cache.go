@@ -0,0 +1,150 @@
+package scylladb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// queryCacheEntry is a cached Query response together with the freshness
+// markers it was produced under.
+type queryCacheEntry struct {
+	response     *state.QueryResponse
+	lastModified time.Time // max(last_modified) of the table when the query ran
+	generation   uint64    // local write generation when the query ran
+	storedAt     time.Time
+}
+
+// queryCache holds recent Query responses keyed by the normalized query, for
+// dashboards that poll the same listing. Entries are served only while younger
+// than ttl, when no write went through this instance since, and when a
+// max(last_modified) probe shows no newer row in the table.
+//
+// Deletes made by other instances do not move max(last_modified), so they can
+// stay invisible for up to ttl.
+type queryCache struct {
+	mu         sync.Mutex
+	entries    map[string]queryCacheEntry
+	ttl        time.Duration
+	maxEntries int
+	generation atomic.Uint64
+}
+
+// initQueryCache parses the query cache settings.
+func (store *ScyllaStateStore) initQueryCache() {
+	ttl, err := time.ParseDuration(store.config.QueryCacheTTL)
+	if err != nil || ttl <= 0 {
+		store.logger.Warnf("Invalid queryCacheTTL: %s, query cache disabled", store.config.QueryCacheTTL)
+		return
+	}
+
+	maxEntries, err := strconv.Atoi(store.config.QueryCacheMaxEntries)
+	if err != nil || maxEntries <= 0 {
+		store.logger.Warnf("Invalid queryCacheMaxEntries: %s, using default", store.config.QueryCacheMaxEntries)
+		maxEntries = 100
+	}
+
+	store.queryCache = &queryCache{
+		entries:    make(map[string]queryCacheEntry),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+	store.logger.Infof("Query cache enabled (ttl=%v, maxEntries=%d)", ttl, maxEntries)
+}
+
+// invalidateQueryCache marks cached responses stale after a local write.
+func (store *ScyllaStateStore) invalidateQueryCache() {
+	if store.queryCache != nil {
+		store.queryCache.generation.Add(1)
+	}
+}
+
+// queryCacheKey normalizes a query into a cache key. Filters are decoded into
+// maps, which encoding/json marshals with sorted keys.
+func queryCacheKey(req *state.QueryRequest) (string, error) {
+	normalized, err := json.Marshal(struct {
+		Query    any               `json:"query"`
+		Metadata map[string]string `json:"metadata"`
+	}{req.Query, req.Metadata})
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
+
+// probeLastModified reads the newest last_modified of the table.
+func (store *ScyllaStateStore) probeLastModified(ctx context.Context) (time.Time, error) {
+	var lastModified time.Time
+	probe := fmt.Sprintf("SELECT max(last_modified) FROM %s", store.config.Table)
+	if err := store.session.Query(probe).WithContext(ctx).Scan(&lastModified); err != nil {
+		return time.Time{}, err
+	}
+	return lastModified, nil
+}
+
+// cachedQuery serves req from the cache when the cached response is still
+// fresh, and otherwise runs it through run and caches the result.
+func (store *ScyllaStateStore) cachedQuery(ctx context.Context, req *state.QueryRequest, run func() (*state.QueryResponse, error)) (*state.QueryResponse, error) {
+	cache := store.queryCache
+	key, err := queryCacheKey(req)
+	if err != nil {
+		store.logger.Debugf("Query not cacheable: %v", err)
+		return run()
+	}
+	if !store.featureEnabled(featureQueryCache, key) {
+		return run()
+	}
+
+	// Probe before running the query so a write racing with it invalidates the entry
+	generation := cache.generation.Load()
+	lastModified, err := store.probeLastModified(ctx)
+	if err != nil {
+		store.logger.Warnf("Query cache freshness probe failed, bypassing cache: %v", err)
+		return run()
+	}
+
+	cache.mu.Lock()
+	entry, ok := cache.entries[key]
+	cache.mu.Unlock()
+
+	if ok && store.now().Sub(entry.storedAt) < cache.ttl &&
+		entry.generation == generation && entry.lastModified.Equal(lastModified) {
+		store.logger.Debugf("Serving query from cache")
+		return entry.response, nil
+	}
+
+	response, err := run()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, exists := cache.entries[key]; !exists && len(cache.entries) >= cache.maxEntries {
+		// Evict expired entries first, then an arbitrary one
+		for cachedKey, cached := range cache.entries {
+			if store.now().Sub(cached.storedAt) >= cache.ttl {
+				delete(cache.entries, cachedKey)
+			}
+		}
+		for cachedKey := range cache.entries {
+			if len(cache.entries) < cache.maxEntries {
+				break
+			}
+			delete(cache.entries, cachedKey)
+		}
+	}
+	cache.entries[key] = queryCacheEntry{
+		response:     response,
+		lastModified: lastModified,
+		generation:   generation,
+		storedAt:     store.now(),
+	}
+	return response, nil
+}
@@ -0,0 +1,227 @@
+package scylladb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// Request metadata keys of CQL passthrough queries
+const (
+	cqlMetadataKey       = "cql"
+	cqlParamsMetadataKey = "cqlParams"
+)
+
+// Statement kinds passthrough can allow; DDL stays with provisioning
+var passthroughStatementKinds = []string{"SELECT", "INSERT", "UPDATE", "DELETE"}
+
+// Keyspace-qualified references to the system keyspaces
+var systemKeyspaceReference = regexp.MustCompile(`(?i)\bsystem\w*\s*\.`)
+
+var (
+	errPassthroughDisabled = errors.New("CQL passthrough is disabled; set queryPassthrough=true to enable it")
+	errPassthroughToken    = errors.New("CQL passthrough requires a valid adminToken")
+)
+
+// queryPassthrough holds the parsed passthrough settings.
+type queryPassthrough struct {
+	allowed map[string]bool // allowed statement kinds
+	maxRows int
+}
+
+// initQueryPassthrough parses the allowed statement kinds and the row limit.
+func (store *ScyllaStateStore) initQueryPassthrough() {
+	passthrough := &queryPassthrough{allowed: make(map[string]bool)}
+	for _, kind := range strings.Split(store.config.QueryPassthroughAllow, ",") {
+		kind = strings.ToUpper(strings.TrimSpace(kind))
+		if kind == "" {
+			continue
+		}
+		valid := false
+		for _, known := range passthroughStatementKinds {
+			valid = valid || kind == known
+		}
+		if !valid {
+			store.logger.Warnf("Invalid statement kind in queryPassthroughAllow: %s, ignoring it", kind)
+			continue
+		}
+		passthrough.allowed[kind] = true
+	}
+	if len(passthrough.allowed) == 0 {
+		store.logger.Warnf("Invalid queryPassthroughAllow: %s, using default", store.config.QueryPassthroughAllow)
+		passthrough.allowed["SELECT"] = true
+	}
+
+	maxRows, err := strconv.Atoi(store.config.QueryPassthroughMaxRows)
+	if err != nil || maxRows <= 0 {
+		store.logger.Warnf("Invalid queryPassthroughMaxRows: %s, using default", store.config.QueryPassthroughMaxRows)
+		maxRows = 1000
+	}
+	passthrough.maxRows = maxRows
+	store.passthrough = passthrough
+
+	if store.config.AdminToken == "" {
+		store.logger.Warnf("queryPassthrough is enabled but no adminToken is configured; every passthrough query will be rejected")
+	}
+	store.logger.Infof("CQL passthrough enabled for %s statements (max %d rows)",
+		strings.Join(passthrough.kinds(), ", "), maxRows)
+}
+
+func (p *queryPassthrough) kinds() []string {
+	var kinds []string
+	for _, kind := range passthroughStatementKinds {
+		if p.allowed[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// passthroughQuery handles Query requests carrying cql metadata: it executes
+// one CQL statement with the parameters bound from cqlParams, a JSON array,
+// and returns each row as a JSON object of typed column values. Every request
+// must carry the adminToken configured on the component, and only the
+// statement kinds listed in queryPassthroughAllow run. Statements see
+// stored partition keys, and values are returned without masking.
+func (store *ScyllaStateStore) passthroughQuery(ctx context.Context, statement string, metadata map[string]string) (*state.QueryResponse, error) {
+	passthrough := store.passthrough
+	if passthrough == nil {
+		return nil, errPassthroughDisabled
+	}
+	if !store.validAdminToken(metadata) {
+		store.logger.Warnf("Rejected CQL passthrough query without a valid adminToken")
+		return nil, errPassthroughToken
+	}
+
+	statement, kind, err := checkPassthroughStatement(statement)
+	if err != nil {
+		return nil, err
+	}
+	if !passthrough.allowed[kind] {
+		return nil, fmt.Errorf("%s statements are not allowed by queryPassthroughAllow", kind)
+	}
+	if kind != "SELECT" && systemKeyspaceReference.MatchString(statement) {
+		return nil, errors.New("refusing to modify a system keyspace")
+	}
+
+	params, err := decodePassthroughParams(metadata[cqlParamsMetadataKey])
+	if err != nil {
+		return nil, err
+	}
+
+	if kind != "SELECT" && store.isDryRun(metadata) {
+		store.logger.Infof("DRY RUN: would execute CQL passthrough statement: %s", statement)
+		return &state.QueryResponse{
+			Results:  []state.QueryItem{},
+			Metadata: map[string]string{"dryRun": "true"},
+		}, nil
+	}
+
+	// Rows written around the write path are unknown to the bloom filter
+	if kind == "INSERT" || kind == "UPDATE" {
+		if store.keyFilter != nil {
+			store.keyFilter.invalidate()
+		}
+	}
+
+	store.logger.Infof("Executing CQL passthrough %s statement", kind)
+	stmt, err := store.hookedQuery(ctx, "query", statement, params...)
+	if err != nil {
+		return nil, err
+	}
+	iter := stmt.Iter()
+
+	columns := make([]map[string]string, 0, len(iter.Columns()))
+	for _, column := range iter.Columns() {
+		columns = append(columns, map[string]string{"name": column.Name, "type": column.TypeInfo.Type().String()})
+	}
+
+	results := []state.QueryItem{}
+	truncated := false
+	for {
+		row := make(map[string]any, len(columns))
+		if !iter.MapScan(row) {
+			break
+		}
+		if len(results) == passthrough.maxRows {
+			truncated = true
+			break
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			iter.Close()
+			return nil, fmt.Errorf("failed to encode row %d: %w", len(results), err)
+		}
+		results = append(results, state.QueryItem{Key: strconv.Itoa(len(results)), Data: data})
+	}
+	if err := iter.Close(); err != nil {
+		store.logger.Errorf("CQL passthrough query failed: %v", err)
+		return nil, fmt.Errorf("CQL passthrough query failed: %w", err)
+	}
+
+	columnsJSON, _ := json.Marshal(columns)
+	return &state.QueryResponse{
+		Results: results,
+		Metadata: map[string]string{
+			"columns":   string(columnsJSON),
+			"rows":      strconv.Itoa(len(results)),
+			"truncated": strconv.FormatBool(truncated),
+		},
+	}, nil
+}
+
+// checkPassthroughStatement trims statement, ensures it is a single statement
+// and returns it with its kind, the leading keyword in upper case.
+func checkPassthroughStatement(statement string) (string, string, error) {
+	statement = strings.TrimSpace(statement)
+	statement = strings.TrimSpace(strings.TrimSuffix(statement, ";"))
+	if statement == "" {
+		return "", "", errors.New("cql metadata must hold a statement")
+	}
+
+	// A semicolon outside string literals separates statements
+	quoted := false
+	for _, r := range statement {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			return "", "", errors.New("cql metadata must hold a single statement")
+		}
+	}
+
+	return statement, strings.ToUpper(strings.Fields(statement)[0]), nil
+}
+
+// decodePassthroughParams decodes the JSON array of bound parameters. Integral
+// numbers bind as int64 and other numbers as float64.
+func decodePassthroughParams(raw string) ([]any, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(stringToBytes(raw)))
+	decoder.UseNumber()
+	var params []any
+	if err := decoder.Decode(&params); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON array: %w", cqlParamsMetadataKey, err)
+	}
+	for i, param := range params {
+		number, ok := param.(json.Number)
+		if !ok {
+			continue
+		}
+		if n, err := number.Int64(); err == nil {
+			params[i] = n
+		} else if f, err := number.Float64(); err == nil {
+			params[i] = f
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,462 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Quota rule that applies to every app id without a rule of its own
+const quotaDefaultRule = "*"
+
+// Upper bound for one usage scan, which reads every value in the table
+const quotaScanTimeout = 30 * time.Minute
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// quotaLimit is the configured limit of a quota rule; 0 means unlimited.
+type quotaLimit struct {
+	maxKeys  int64
+	maxBytes int64
+}
+
+// quotaUsage is the number of keys and value bytes charged to a bucket.
+type quotaUsage struct {
+	keys  int64
+	bytes int64
+}
+
+// quotaWrite is a value about to be written under a Dapr key.
+type quotaWrite struct {
+	key  string
+	size int
+}
+
+// quotaCharge is what a write adds to its bucket.
+type quotaCharge struct {
+	bucket string
+	quotaUsage
+}
+
+// quotaEnforcer limits the number of keys and value bytes per app id or per
+// app id and key prefix, so a buggy service cannot fill a shared cluster.
+//
+// Usage is counted by a periodic scan of the table. Between scans each replica
+// adds its own writes: a write counts as a new key of its full size while its
+// bucket is well under quota, and near the limit the existing row is read so
+// overwrites only count their growth. Deletes free quota at the next scan.
+type quotaEnforcer struct {
+	rules        map[string]quotaLimit // app id, app id/prefix or *
+	delimiter    string
+	defaultAppID string
+	scanInterval time.Duration
+
+	mu       sync.Mutex
+	baseline map[string]quotaUsage  // per bucket, from the last scan
+	delta    map[string]*quotaUsage // per bucket, charged since the scan started
+	rejected map[string]int64
+	lastScan time.Time
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// parseQuotas parses rules of the form "<bucket>=<maxKeys>:<maxBytes>",
+// separated by commas, where bucket is an app id, "<app id>/<prefix>" or *.
+func parseQuotas(spec string) (map[string]quotaLimit, error) {
+	rules := make(map[string]quotaLimit)
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		bucket, limits, ok := strings.Cut(rule, "=")
+		maxKeys, maxBytes, ok2 := strings.Cut(limits, ":")
+		if !ok || !ok2 || strings.TrimSpace(bucket) == "" {
+			return nil, fmt.Errorf("invalid quota %q, expected <bucket>=<maxKeys>:<maxBytes>", rule)
+		}
+		keys, err := strconv.ParseInt(strings.TrimSpace(maxKeys), 10, 64)
+		if err != nil || keys < 0 {
+			return nil, fmt.Errorf("invalid key limit in quota %q", rule)
+		}
+		bytes, err := strconv.ParseInt(strings.TrimSpace(maxBytes), 10, 64)
+		if err != nil || bytes < 0 {
+			return nil, fmt.Errorf("invalid byte limit in quota %q", rule)
+		}
+		rules[strings.TrimSpace(bucket)] = quotaLimit{maxKeys: keys, maxBytes: bytes}
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("no quota rules")
+	}
+	return rules, nil
+}
+
+// initQuotas parses the quota settings and starts the usage scan loop.
+func (store *ScyllaStateStore) initQuotas() {
+	if store.keys != nil && !store.keys.reversible() {
+		store.logger.Warnf("Quotas are not supported with keyStrategy=hash, disabling them")
+		return
+	}
+
+	rules, err := parseQuotas(store.config.Quotas)
+	if err != nil {
+		store.logger.Warnf("Invalid quotas: %v, disabling them", err)
+		return
+	}
+
+	scanInterval, err := time.ParseDuration(store.config.QuotaScanInterval)
+	if err != nil || scanInterval <= 0 {
+		store.logger.Warnf("Invalid quotaScanInterval: %s, using default", store.config.QuotaScanInterval)
+		scanInterval = 5 * time.Minute
+	}
+
+	quotas := &quotaEnforcer{
+		rules:        rules,
+		delimiter:    store.config.UsagePrefixDelimiter,
+		defaultAppID: store.config.AppID,
+		scanInterval: scanInterval,
+		baseline:     make(map[string]quotaUsage),
+		delta:        make(map[string]*quotaUsage),
+		rejected:     make(map[string]int64),
+		stopCh:       make(chan struct{}),
+	}
+	store.quotas = quotas
+
+	store.logger.Infof("Quotas enabled for %d rules (scanInterval=%v)", len(rules), scanInterval)
+
+	quotas.wg.Add(1)
+	go func() {
+		defer quotas.wg.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-quotas.stopCh
+			cancel()
+		}()
+
+		ticker := time.NewTicker(quotas.scanInterval)
+		defer ticker.Stop()
+
+		for {
+			start := time.Now()
+			if rows, err := store.scanQuotaUsage(ctx); err != nil {
+				store.logger.Warnf("Quota usage scan failed: %v", err)
+			} else {
+				store.logger.Infof("Quota usage scanned %d rows in %v", rows, time.Since(start))
+			}
+
+			select {
+			case <-quotas.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// bucket returns the quota bucket a Dapr key is charged to, or "" when no
+// rule applies. The most specific rule wins: app id and prefix, then app id,
+// then the default rule, which limits each app id separately.
+func (q *quotaEnforcer) bucket(key string) string {
+	b := bucketForKey(key, q.defaultAppID, q.delimiter)
+	if b.Prefix != "" {
+		if _, ok := q.rules[b.AppID+"/"+b.Prefix]; ok {
+			return b.AppID + "/" + b.Prefix
+		}
+	}
+	if _, ok := q.rules[b.AppID]; ok {
+		return b.AppID
+	}
+	if _, ok := q.rules[quotaDefaultRule]; ok {
+		return b.AppID
+	}
+	return ""
+}
+
+func (q *quotaEnforcer) limit(bucket string) quotaLimit {
+	if limit, ok := q.rules[bucket]; ok {
+		return limit
+	}
+	return q.rules[quotaDefaultRule]
+}
+
+// usageLocked returns the current usage of bucket.
+func (q *quotaEnforcer) usageLocked(bucket string) quotaUsage {
+	usage := q.baseline[bucket]
+	if delta := q.delta[bucket]; delta != nil {
+		usage.keys += delta.keys
+		usage.bytes += delta.bytes
+	}
+	return usage
+}
+
+// tryCharge applies all charges, or none when a bucket that grows would end
+// up over its quota.
+func (q *quotaEnforcer) tryCharge(charges []quotaCharge) error {
+	growth := make(map[string]quotaUsage)
+	for _, charge := range charges {
+		if charge.bucket == "" {
+			continue
+		}
+		total := growth[charge.bucket]
+		total.keys += charge.keys
+		total.bytes += charge.bytes
+		growth[charge.bucket] = total
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for bucket, grow := range growth {
+		limit := q.limit(bucket)
+		usage := q.usageLocked(bucket)
+		if grow.keys > 0 && limit.maxKeys > 0 && usage.keys+grow.keys > limit.maxKeys {
+			return &quotaExceededError{bucket: bucket, resource: "keys", limit: limit.maxKeys, usage: usage.keys}
+		}
+		if grow.bytes > 0 && limit.maxBytes > 0 && usage.bytes+grow.bytes > limit.maxBytes {
+			return &quotaExceededError{bucket: bucket, resource: "bytes", limit: limit.maxBytes, usage: usage.bytes}
+		}
+	}
+
+	for bucket, grow := range growth {
+		delta := q.delta[bucket]
+		if delta == nil {
+			delta = &quotaUsage{}
+			q.delta[bucket] = delta
+		}
+		delta.keys += grow.keys
+		delta.bytes += grow.bytes
+	}
+	return nil
+}
+
+// reserveQuota charges writes to their quota buckets before they are
+// executed. All writes are charged, or none when one of them would exceed a
+// quota; the error then matches errQuotaExceeded.
+func (store *ScyllaStateStore) reserveQuota(ctx context.Context, writes ...quotaWrite) error {
+	q := store.quotas
+	if q == nil {
+		return nil
+	}
+
+	charges := make([]quotaCharge, len(writes))
+	for i, write := range writes {
+		charges[i] = quotaCharge{bucket: q.bucket(write.key), quotaUsage: quotaUsage{keys: 1, bytes: int64(write.size)}}
+	}
+	if q.tryCharge(charges) == nil {
+		return nil
+	}
+
+	// Near the limit, overwrites of existing keys are charged their growth only
+	for i, write := range writes {
+		if charges[i].bucket == "" {
+			continue
+		}
+		size, exists, err := store.storedSize(ctx, write.key)
+		if err != nil {
+			return fmt.Errorf("failed to check quota for key %s: %w", write.key, err)
+		}
+		if exists {
+			charges[i].keys = 0
+			charges[i].bytes = int64(write.size) - size
+		}
+	}
+
+	err := q.tryCharge(charges)
+	var exceeded *quotaExceededError
+	if errors.As(err, &exceeded) {
+		q.mu.Lock()
+		q.rejected[exceeded.bucket]++
+		q.mu.Unlock()
+		store.logger.Warnf("Rejected write over quota: %v", err)
+	}
+	return err
+}
+
+// storedSize returns the value size of a Dapr key and whether it exists.
+func (store *ScyllaStateStore) storedSize(ctx context.Context, key string) (int64, bool, error) {
+	var text string
+	var blob []byte
+	query := fmt.Sprintf("SELECT value, value_blob FROM %s WHERE key = ?", store.config.Table)
+	stmt, err := store.hookedQuery(ctx, "quota", query, store.storageKey(key))
+	if err != nil {
+		return 0, false, err
+	}
+	if err := stmt.Scan(&text, &blob); err != nil {
+		if err == gocql.ErrNotFound {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return int64(len(storedBytes(text, blob))), true, nil
+}
+
+// scanQuotaUsage recounts the keys and value bytes of every bucket. Writes
+// charged while the scan runs stay charged, so they may be counted twice
+// until the next scan; on failure the previous usage is kept.
+func (store *ScyllaStateStore) scanQuotaUsage(ctx context.Context) (int64, error) {
+	q := store.quotas
+
+	store.mu.RLock()
+	session := store.session
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || session == nil {
+		return 0, errors.New("store is closed")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, quotaScanTimeout)
+	defer cancel()
+
+	q.mu.Lock()
+	previous := q.delta
+	q.delta = make(map[string]*quotaUsage)
+	q.mu.Unlock()
+
+	usage := make(map[string]quotaUsage)
+	var rows int64
+	var storageKey, text string
+	var blob []byte
+	iter := session.Query(fmt.Sprintf("SELECT key, value, value_blob FROM %s", store.config.Table)).
+		WithContext(ctx).PageSize(1000).Iter()
+	for iter.Scan(&storageKey, &text, &blob) {
+		rows++
+		key := storageKey
+		if store.keys != nil {
+			var ok bool
+			if key, ok = store.keys.fromStorage(storageKey); !ok {
+				continue
+			}
+		}
+		if bucket := q.bucket(key); bucket != "" {
+			total := usage[bucket]
+			total.keys++
+			total.bytes += int64(len(storedBytes(text, blob)))
+			usage[bucket] = total
+		}
+	}
+	err := iter.Close()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err != nil {
+		// Keep charging the writes made before the failed scan
+		for bucket, charged := range previous {
+			delta := q.delta[bucket]
+			if delta == nil {
+				delta = &quotaUsage{}
+				q.delta[bucket] = delta
+			}
+			delta.keys += charged.keys
+			delta.bytes += charged.bytes
+		}
+		return rows, err
+	}
+
+	q.baseline = usage
+	q.lastScan = time.Now()
+	return rows, nil
+}
+
+// diagnostics reports usage and limits per bucket.
+func (q *quotaEnforcer) diagnostics() map[string]any {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	buckets := make(map[string]struct{})
+	for bucket := range q.baseline {
+		buckets[bucket] = struct{}{}
+	}
+	for bucket := range q.delta {
+		buckets[bucket] = struct{}{}
+	}
+	for bucket := range q.rejected {
+		buckets[bucket] = struct{}{}
+	}
+	names := make([]string, 0, len(buckets))
+	for bucket := range buckets {
+		names = append(names, bucket)
+	}
+	sort.Strings(names)
+
+	usage := make([]map[string]any, 0, len(names))
+	for _, bucket := range names {
+		current := q.usageLocked(bucket)
+		limit := q.limit(bucket)
+		usage = append(usage, map[string]any{
+			"bucket":   bucket,
+			"keys":     current.keys,
+			"bytes":    current.bytes,
+			"maxKeys":  limit.maxKeys,
+			"maxBytes": limit.maxBytes,
+			"rejected": q.rejected[bucket],
+		})
+	}
+
+	diagnostics := map[string]any{"buckets": usage}
+	if !q.lastScan.IsZero() {
+		diagnostics["lastScan"] = q.lastScan.UTC()
+	}
+	return diagnostics
+}
+
+func (q *quotaEnforcer) stop() {
+	close(q.stopCh)
+	q.wg.Wait()
+}
+
+// quotaExceededError is returned for writes that would exceed a quota. It
+// matches errQuotaExceeded with errors.Is and converts to a gRPC
+// ResourceExhausted status with QuotaFailure and ErrorInfo details, so callers
+// can tell it apart from transient failures and do not retry.
+type quotaExceededError struct {
+	bucket   string
+	resource string
+	limit    int64
+	usage    int64
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("%s for %s: %s limit %d reached (current %d)", errQuotaExceeded, e.bucket, e.resource, e.limit, e.usage)
+}
+
+func (e *quotaExceededError) Is(target error) bool {
+	return target == errQuotaExceeded
+}
+
+// GRPCStatus is used by the gRPC server to build the response status.
+func (e *quotaExceededError) GRPCStatus() *status.Status {
+	st := status.New(codes.ResourceExhausted, e.Error())
+	detailed, err := st.WithDetails(
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     e.bucket,
+			Description: fmt.Sprintf("%s limit %d reached", e.resource, e.limit),
+		}}},
+		&errdetails.ErrorInfo{
+			Reason: "QUOTA_EXCEEDED",
+			Domain: errorInfoDomain,
+			Metadata: map[string]string{
+				"bucket":   e.bucket,
+				"resource": e.resource,
+				"limit":    strconv.FormatInt(e.limit, 10),
+			},
+		},
+	)
+	if err != nil {
+		return st
+	}
+	return detailed
+}
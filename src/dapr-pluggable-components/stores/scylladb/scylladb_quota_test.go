@@ -0,0 +1,97 @@
+package scylladb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuotas(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]quotaLimit
+		wantErr bool
+	}{
+		{
+			name: "single rule",
+			spec: "orders=1000:1048576",
+			want: map[string]quotaLimit{"orders": {maxKeys: 1000, maxBytes: 1048576}},
+		},
+		{
+			name: "app id, prefix and default",
+			spec: "orders=1000:0, orders/session=10:1024 ,*=0:4096",
+			want: map[string]quotaLimit{
+				"orders":         {maxKeys: 1000},
+				"orders/session": {maxKeys: 10, maxBytes: 1024},
+				"*":              {maxBytes: 4096},
+			},
+		},
+		{
+			name: "spaces around values",
+			spec: " cart = 5 : 6 ",
+			want: map[string]quotaLimit{"cart": {maxKeys: 5, maxBytes: 6}},
+		},
+		{
+			name: "later rule for the same bucket wins",
+			spec: "cart=1:1,cart=2:2",
+			want: map[string]quotaLimit{"cart": {maxKeys: 2, maxBytes: 2}},
+		},
+		{name: "empty", spec: "", wantErr: true},
+		{name: "only separators", spec: ",,", wantErr: true},
+		{name: "no limits", spec: "orders", wantErr: true},
+		{name: "no byte limit", spec: "orders=10", wantErr: true},
+		{name: "no bucket", spec: "=10:10", wantErr: true},
+		{name: "invalid key limit", spec: "orders=ten:10", wantErr: true},
+		{name: "negative key limit", spec: "orders=-1:10", wantErr: true},
+		{name: "invalid byte limit", spec: "orders=10:1MB", wantErr: true},
+		{name: "negative byte limit", spec: "orders=10:-1", wantErr: true},
+		{name: "one invalid rule", spec: "orders=10:10,cart=x:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQuotas(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQuotas(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQuotas(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaBucket(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		key       string
+		want      string
+		wantLimit quotaLimit
+	}{
+		{"prefix rule", "orders=100:0,orders/session=10:0", "orders||session:1", "orders/session", quotaLimit{maxKeys: 10}},
+		{"app id rule", "orders=100:0,orders/session=10:0", "orders||cart:1", "orders", quotaLimit{maxKeys: 100}},
+		{"default rule", "*=5:0", "cart||item:1", "cart", quotaLimit{maxKeys: 5}},
+		{"app id before default", "*=5:0,cart=50:0", "cart||item:1", "cart", quotaLimit{maxKeys: 50}},
+		{"default app id", "shared=7:0", "item:1", "shared", quotaLimit{maxKeys: 7}},
+		{"no matching rule", "orders=100:0", "cart||item:1", "", quotaLimit{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseQuotas(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := &quotaEnforcer{rules: rules, delimiter: ":", defaultAppID: "shared"}
+
+			got := q.bucket(tt.key)
+			if got != tt.want {
+				t.Fatalf("bucket(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+			if got != "" {
+				if limit := q.limit(got); limit != tt.wantLimit {
+					t.Errorf("limit(%q) = %+v, want %+v", got, limit, tt.wantLimit)
+				}
+			}
+		})
+	}
+}
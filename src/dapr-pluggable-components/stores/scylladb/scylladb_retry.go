@@ -0,0 +1,101 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// retryPolicy controls application-level retries of transient ScyllaDB errors.
+// It is shared by every operation of the store so retry behaviour is configured
+// in one place (maxRetries, retryBaseDelay, retryMaxDelay).
+type retryPolicy struct {
+	maxAttempts int           // total attempts including the first one
+	baseDelay   time.Duration // delay before the first retry
+	maxDelay    time.Duration // upper bound for the exponential backoff
+}
+
+// defaultRetryPolicy matches the historical behaviour of three attempts.
+var defaultRetryPolicy = retryPolicy{
+	maxAttempts: 3,
+	baseDelay:   100 * time.Millisecond,
+	maxDelay:    10 * time.Second,
+}
+
+// backoff returns the delay before the given retry (attempt >= 1), doubling
+// from baseDelay and capped at maxDelay.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= p.maxDelay {
+			return p.maxDelay
+		}
+	}
+	if delay > p.maxDelay {
+		return p.maxDelay
+	}
+	return delay
+}
+
+// isTransientError reports whether an error is worth retrying.
+func isTransientError(err error) bool {
+	return errors.Is(err, gocql.ErrUnavailable) || errors.Is(err, gocql.ErrTimeoutNoResponse)
+}
+
+// isUnpreparedError reports whether a node no longer knows a prepared
+// statement, typically because it restarted.
+func isUnpreparedError(err error) bool {
+	var unprepared *gocql.RequestErrUnprepared
+	return errors.As(err, &unprepared)
+}
+
+// withRetry runs fn until it succeeds, fails with a non-transient error, the
+// attempts are exhausted or ctx is done. The last error is returned unwrapped
+// so callers can still match sentinel errors such as gocql.ErrNotFound.
+//
+// The driver re-prepares a statement once when a node reports it unprepared.
+// During a rolling restart that retry can land on another restarted node, so an
+// unprepared error that reaches this point is retried once more immediately,
+// without using one of the policy's attempts. The statement cache entry has
+// already been evicted, so the retry prepares the statement again.
+func (store *ScyllaStateStore) withRetry(ctx context.Context, operation string, fn func() error) error {
+	policy := store.retry
+	if policy.maxAttempts <= 0 {
+		policy = defaultRetryPolicy
+	}
+
+	reprepared := false
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		store.observeOverload(err)
+		if !reprepared && isUnpreparedError(err) && ctx.Err() == nil {
+			reprepared = true
+			count := store.unpreparedRetries.Add(1)
+			store.logger.Warnf("Prepared statement invalidated on %s, re-preparing and retrying (%d occurrences): %v",
+				operation, count, err)
+			attempt--
+			continue
+		}
+		if err == nil || !isTransientError(err) || attempt >= policy.maxAttempts {
+			if isSchemaChangeError(err) {
+				store.scheduleSchemaRefresh(err)
+			}
+			return err
+		}
+
+		backoff := policy.backoff(attempt)
+		store.logger.Warnf("Transient error on %s (attempt %d/%d), retrying after %v: %v",
+			operation, attempt, policy.maxAttempts, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package scylladb
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain reported in the ErrorInfo details of shed requests
+const errorInfoDomain = "scylladb-state.dapr.io"
+
+// Bounds of the retry-after hint of shed requests
+const (
+	minShedRetryAfter = time.Second
+	maxShedRetryAfter = 30 * time.Second
+)
+
+// loadShedError is returned for requests shed while the store is degraded or
+// the backend is overloaded. It matches errLoadShed with errors.Is and
+// converts to a gRPC Unavailable status
+// carrying RetryInfo and ErrorInfo details, so the sidecar and applications
+// can back off for the hinted delay instead of retrying immediately.
+type loadShedError struct {
+	op         string
+	class      priorityClass
+	retryAfter time.Duration
+	overload   bool // shed by overload shedding rather than a latency SLO
+}
+
+func (e *loadShedError) Error() string {
+	if e.overload {
+		return "request shed: backend is overloaded (retry after " + e.retryAfter.String() + ")"
+	}
+	return errLoadShed.Error() + " (retry after " + e.retryAfter.String() + ")"
+}
+
+// cause names what made the store shed the request.
+func (e *loadShedError) cause() string {
+	if e.overload {
+		return "overload"
+	}
+	return "latencySlo"
+}
+
+func (e *loadShedError) Is(target error) bool {
+	return target == errLoadShed
+}
+
+// GRPCStatus is used by the gRPC server to build the response status. It has
+// to be the error returned by the store, as the server does not unwrap errors.
+func (e *loadShedError) GRPCStatus() *status.Status {
+	st := status.New(codes.Unavailable, e.Error())
+	detailed, err := st.WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(e.retryAfter)},
+		&errdetails.ErrorInfo{
+			Reason: "LOAD_SHED",
+			Domain: errorInfoDomain,
+			Metadata: map[string]string{
+				"operation":  e.op,
+				"priority":   e.class.String(),
+				"retryAfter": e.retryAfter.String(),
+				"cause":      e.cause(),
+			},
+		},
+	)
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// shedRetryAfter estimates when a shed request is worth retrying. A breach
+// clears once enough slow samples age out of the SLO window, so the hint is a
+// tenth of the window, bounded to [1s, 30s].
+func (store *ScyllaStateStore) shedRetryAfter() time.Duration {
+	retryAfter := store.slo.window / 10
+	if retryAfter < minShedRetryAfter {
+		return minShedRetryAfter
+	}
+	if retryAfter > maxShedRetryAfter {
+		return maxShedRetryAfter
+	}
+	return retryAfter
+}
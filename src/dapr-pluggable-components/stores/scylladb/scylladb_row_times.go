@@ -0,0 +1,52 @@
+package scylladb
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Response metadata keys of a row's expiry and write time. ttlExpireTime is
+// the key Dapr's own stores use.
+const (
+	ttlExpireTimeMetadataKey = "ttlExpireTime"
+	writeTimeMetadataKey     = "writeTime"
+	// Query response metadata with the times of every result, as JSON
+	rowTimesMetadataKey = "rowTimes"
+)
+
+// rowTimesColumns selects the remaining TTL and the write time of a row with
+// ttlMetadata. Every write sets etag with the row's TTL and timestamp, while
+// value stays null for values stored as blobs, so etag stands for the row.
+const rowTimesColumns = "TTL(etag), WRITETIME(etag)"
+
+// rowTimes are the remaining TTL and the write time of a row.
+type rowTimes struct {
+	ttl       int   // seconds left; 0 when the row does not expire
+	writeTime int64 // microseconds since the epoch
+}
+
+// rowTimesEnabled reports whether reads return the times of rows.
+func (store *ScyllaStateStore) rowTimesEnabled() bool {
+	return store.config.TTLMetadata == "true"
+}
+
+// metadata returns the response metadata of times read at now: the write
+// time, and the expiry time of rows with a TTL.
+func (t rowTimes) metadata(now time.Time) map[string]string {
+	metadata := map[string]string{
+		writeTimeMetadataKey: time.UnixMicro(t.writeTime).UTC().Format(time.RFC3339Nano),
+	}
+	if t.ttl > 0 {
+		metadata[ttlExpireTimeMetadataKey] = now.Add(time.Duration(t.ttl) * time.Second).UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// rowTimesResponseMetadata encodes the times of query results by Dapr key.
+func rowTimesResponseMetadata(times map[string]map[string]string) map[string]string {
+	encoded, err := json.Marshal(times)
+	if err != nil {
+		return nil
+	}
+	return map[string]string{rowTimesMetadataKey: string(encoded)}
+}
@@ -0,0 +1,140 @@
+package scylladb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+// SchemaStatement is one CQL statement of the schema the store needs.
+type SchemaStatement struct {
+	Comment string // what the statement creates
+	CQL     string
+}
+
+// schemaTable is a table of the store's keyspace.
+type schemaTable struct {
+	name string
+	ddl  func(name string) string
+}
+
+// schemaTables returns the tables the configuration needs in the keyspace,
+// the state table first. Time bucket tables come and go, so they are not
+// listed.
+func (store *ScyllaStateStore) schemaTables() []schemaTable {
+	tables := []schemaTable{{store.config.Table, stateTableDDL}}
+	if store.config.LeaderElection == "true" {
+		tables = append(tables, schemaTable{store.leaseTable(), leaseTableDDL})
+	}
+	if store.config.ChangeFeed == "true" {
+		tables = append(tables, schemaTable{store.changeFeedTable(), changeFeedTableDDL})
+	}
+	if store.config.KeyGroups == "true" {
+		tables = append(tables, schemaTable{store.keyGroupsTable(), keyGroupsTableDDL})
+	}
+	return tables
+}
+
+// Schema returns the statements Init runs to create the keyspace and tables
+// for the configuration in metadata, followed by those run on the mirror
+// cluster, without connecting. Tables are qualified with their keyspace, so
+// the statements can be reviewed and applied with cqlsh where the component
+// runs with createSchema=false.
+func (store *ScyllaStateStore) Schema(metadata state.Metadata) ([]SchemaStatement, error) {
+	if err := store.configure(metadata); err != nil {
+		return nil, err
+	}
+
+	keyspace := store.config.Keyspace
+	statements := []SchemaStatement{{
+		Comment: "Keyspace " + keyspace,
+		CQL:     keyspaceDDL(keyspace, store.config.ReplicationStrategy, store.config.ReplicationFactor),
+	}}
+	for _, table := range store.schemaTables() {
+		statements = append(statements, SchemaStatement{
+			Comment: "Table " + table.name,
+			CQL:     table.ddl(keyspace + "." + table.name),
+		})
+	}
+	if store.timeBuckets != nil {
+		for _, name := range store.timeBuckets.liveBuckets(store.config.Table, store.now()) {
+			statements = append(statements, SchemaStatement{
+				Comment: "Time bucket table " + name + ", created and dropped by the component as buckets rotate",
+				CQL:     stateTableDDL(keyspace + "." + name),
+			})
+		}
+	}
+
+	if store.config.MirrorHosts != "" {
+		mirrorKeyspace := store.config.MirrorKeyspace
+		if mirrorKeyspace == "" {
+			mirrorKeyspace = keyspace
+		}
+		statements = append(statements,
+			SchemaStatement{
+				Comment: fmt.Sprintf("Keyspace %s on the mirror cluster %s", mirrorKeyspace, store.config.MirrorHosts),
+				CQL:     keyspaceDDL(mirrorKeyspace, store.config.ReplicationStrategy, store.config.ReplicationFactor),
+			},
+			SchemaStatement{
+				Comment: fmt.Sprintf("Table %s on the mirror cluster %s", store.config.Table, store.config.MirrorHosts),
+				CQL:     stateTableDDL(mirrorKeyspace + "." + store.config.Table),
+			})
+	}
+
+	for i := range statements {
+		statements[i].CQL = dedent(statements[i].CQL)
+	}
+	return statements, nil
+}
+
+// checkSchema verifies, with createSchema=false, that the tables the
+// configuration needs exist, since Init must not change the schema then.
+func (store *ScyllaStateStore) checkSchema(session *gocql.Session) error {
+	keyspace, err := session.KeyspaceMetadata(store.config.Keyspace)
+	if err != nil {
+		return fmt.Errorf("failed to read keyspace metadata: %w", err)
+	}
+
+	var missing []string
+	for _, table := range store.schemaTables() {
+		// Unquoted names are stored in lower case
+		if _, ok := keyspace.Tables[strings.ToLower(table.name)]; !ok {
+			missing = append(missing, table.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("createSchema is false and keyspace %s lacks %s; create the tables with the statements printed by the schema subcommand",
+			store.config.Keyspace, strings.Join(missing, ", "))
+	}
+	if _, ok := keyspace.Tables[strings.ToLower(store.config.Table)].Columns["value_blob"]; !ok {
+		return fmt.Errorf("createSchema is false and table %s has no value_blob column; add it with ALTER TABLE %s.%s ADD value_blob blob",
+			store.config.Table, store.config.Keyspace, store.config.Table)
+	}
+	return nil
+}
+
+// dedent strips the indentation the statements have in the source.
+func dedent(cql string) string {
+	lines := strings.Split(strings.Trim(cql, "\n"), "\n")
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, "\t")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent < 0 {
+		indent = 0
+	}
+	for i, line := range lines {
+		if len(line) >= indent {
+			line = line[indent:]
+		}
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
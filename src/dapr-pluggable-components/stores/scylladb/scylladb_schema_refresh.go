@@ -0,0 +1,167 @@
+package scylladb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+// Request metadata key that makes a Query rebuild the session and statement caches
+const refreshSchemaMetadataKey = "refreshSchema"
+
+// Minimum time between schema refreshes, automatic or requested
+const schemaRefreshMinInterval = 30 * time.Second
+
+var errRefreshSchemaToken = errors.New("refreshSchema queries require a valid adminToken")
+
+// Server messages of invalid-request errors caused by a schema change under a
+// running session, e.g. after a DBA altered or recreated the table.
+var schemaChangeMessages = []string{
+	"unconfigured table",
+	"undefined column name",
+	"unknown identifier",
+	"keyspace does not exist",
+}
+
+// isSchemaChangeError reports whether err indicates that cached statements or
+// schema metadata no longer match the database.
+func isSchemaChangeError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gocql.ErrKeyspaceDoesNotExist) {
+		return true
+	}
+
+	var unprepared *gocql.RequestErrUnprepared
+	if errors.As(err, &unprepared) {
+		return true
+	}
+
+	var requestErr gocql.RequestError
+	if !errors.As(err, &requestErr) || requestErr.Code() != gocql.ErrCodeInvalid {
+		return false
+	}
+	message := strings.ToLower(requestErr.Message())
+	for _, fragment := range schemaChangeMessages {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaRefreshState serializes refreshes and rate-limits schema refreshes.
+type schemaRefreshState struct {
+	mu          sync.Mutex // held for the whole of a session refresh
+	running     atomic.Bool
+	lastRefresh atomic.Int64 // unix nanoseconds
+}
+
+// refreshSession replaces the session with a fresh one, which drops the
+// driver's prepared statement and schema metadata caches, and rebuilds the
+// store's statements on it. The new session connects without the store lock,
+// so operations keep running on the old one until the swap; those in flight
+// then finish before the old session is closed.
+func (store *ScyllaStateStore) refreshSession() error {
+	store.schemaRefresh.mu.Lock()
+	defer store.schemaRefresh.mu.Unlock()
+
+	start := time.Now()
+	store.logger.Info("Refreshing ScyllaDB session, prepared statements and schema metadata")
+
+	store.mu.RLock()
+	closed, injected := store.closed, store.injected != nil
+	cluster := *store.cluster
+	store.mu.RUnlock()
+
+	if closed {
+		return errors.New("store is closed")
+	}
+	if injected {
+		return errors.New("cannot refresh a session created by the embedding program")
+	}
+
+	// Host selection policies cannot be shared between sessions
+	policy := store.hostSelectionPolicy()
+	cluster.PoolConfig.HostSelectionPolicy = policy
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	store.mu.Lock()
+	if store.closed {
+		store.mu.Unlock()
+		session.Close()
+		return errors.New("store is closed")
+	}
+	old := store.session
+	store.session = session
+	store.cluster.PoolConfig.HostSelectionPolicy = policy
+	store.activateHostSelectionPolicy(policy)
+	store.prepareStatements(session)
+	store.schemaRefresh.lastRefresh.Store(time.Now().UnixNano())
+	store.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	store.logger.Infof("ScyllaDB session refreshed in %v", time.Since(start))
+	return nil
+}
+
+// scheduleSchemaRefresh refreshes the session in the background after a schema
+// change error, at most once per schemaRefreshMinInterval. It runs
+// asynchronously because callers hold the store's read lock.
+func (store *ScyllaStateStore) scheduleSchemaRefresh(cause error) {
+	last := time.Unix(0, store.schemaRefresh.lastRefresh.Load())
+	if time.Since(last) < schemaRefreshMinInterval || !store.schemaRefresh.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	store.logger.Warnf("Schema change detected (%v), refreshing prepared statements", cause)
+	go func() {
+		defer store.schemaRefresh.running.Store(false)
+		if err := store.refreshSession(); err != nil {
+			store.logger.Errorf("Automatic session refresh failed: %v", err)
+			// Back off before the next attempt as well
+			store.schemaRefresh.lastRefresh.Store(time.Now().UnixNano())
+		}
+	}()
+}
+
+// refreshSchemaQuery answers a Query carrying the refreshSchema metadata. Like
+// provisioning, every request must carry the adminToken configured on the
+// component, and refreshes are at least schemaRefreshMinInterval apart.
+func (store *ScyllaStateStore) refreshSchemaQuery(metadata map[string]string) (*state.QueryResponse, error) {
+	if !store.validAdminToken(metadata) {
+		store.logger.Warnf("Rejected refreshSchema query without a valid adminToken")
+		return nil, errRefreshSchemaToken
+	}
+	last := time.Unix(0, store.schemaRefresh.lastRefresh.Load())
+	if wait := schemaRefreshMinInterval - time.Since(last); wait > 0 {
+		return nil, fmt.Errorf("session was refreshed %v ago; retry in %v",
+			time.Since(last).Round(time.Second), wait.Round(time.Second))
+	}
+
+	start := time.Now()
+	if err := store.refreshSession(); err != nil {
+		store.logger.Errorf("Session refresh failed: %v", err)
+		return nil, fmt.Errorf("session refresh failed: %w", err)
+	}
+
+	return &state.QueryResponse{
+		Results: []state.QueryItem{},
+		Metadata: map[string]string{
+			"duration": time.Since(start).String(),
+		},
+	}, nil
+}
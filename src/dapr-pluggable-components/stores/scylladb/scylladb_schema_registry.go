@@ -0,0 +1,217 @@
+package scylladb
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// Request metadata key that makes a Query return the observed schema
+const observedSchemaMetadataKey = "observedSchema"
+
+const (
+	// Upper bound on distinct (app id, prefix) buckets; values under further prefixes are not sampled
+	schemaMaxBuckets = 1000
+	// Upper bound on distinct field paths recorded per bucket
+	schemaMaxFields = 500
+	// Nesting depth below which fields are not recorded
+	schemaMaxDepth = 8
+)
+
+// observedSchema summarizes the sampled values of one key prefix: how often
+// each field path was seen with each JSON type. Array elements share the
+// path of their array with a "[]" suffix.
+type observedSchema struct {
+	Samples int64                       `json:"samples"`
+	NonJSON int64                       `json:"nonJson"`
+	Fields  map[string]map[string]int64 `json:"fields"`
+}
+
+// schemaRegistry samples written values per Dapr app id and key prefix and
+// records the field names and types it observes, so teams can see what shared
+// keys actually hold before declaring query indexes. The registry lives in
+// memory and starts empty on every restart.
+type schemaRegistry struct {
+	mu            sync.Mutex
+	schemas       map[usageBucket]*observedSchema
+	samplePercent float64
+	delimiter     string
+	defaultAppID  string
+}
+
+// initSchemaRegistry parses the sampling settings.
+func (store *ScyllaStateStore) initSchemaRegistry() {
+	samplePercent, err := strconv.ParseFloat(store.config.SchemaSamplePercent, 64)
+	if err != nil || samplePercent <= 0 || samplePercent > 100 {
+		store.logger.Warnf("Invalid schemaSamplePercent: %s, using default", store.config.SchemaSamplePercent)
+		samplePercent = 1
+	}
+
+	store.schemas = &schemaRegistry{
+		schemas:       make(map[usageBucket]*observedSchema),
+		samplePercent: samplePercent,
+		delimiter:     store.config.UsagePrefixDelimiter,
+		defaultAppID:  store.config.AppID,
+	}
+	store.logger.Infof("Schema sampling enabled for %.1f%% of writes (prefixDelimiter=%q)", samplePercent, store.config.UsagePrefixDelimiter)
+}
+
+// observeSchema records the fields of a written value when it is sampled.
+func (store *ScyllaStateStore) observeSchema(key, value string) {
+	registry := store.schemas
+	if registry == nil || rand.Float64()*100 >= registry.samplePercent {
+		return
+	}
+
+	// Decode outside the lock; only the counters are shared
+	var doc any
+	isJSON := json.Unmarshal(stringToBytes(value), &doc) == nil
+	fields := make(map[string]string)
+	if isJSON {
+		collectFields(doc, "", 0, fields)
+	}
+
+	bucket := bucketForKey(key, registry.defaultAppID, registry.delimiter)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	schema, ok := registry.schemas[bucket]
+	if !ok {
+		if len(registry.schemas) >= schemaMaxBuckets {
+			return
+		}
+		schema = &observedSchema{Fields: make(map[string]map[string]int64)}
+		registry.schemas[bucket] = schema
+	}
+
+	schema.Samples++
+	if !isJSON {
+		schema.NonJSON++
+		return
+	}
+	for path, kind := range fields {
+		types, ok := schema.Fields[path]
+		if !ok {
+			if len(schema.Fields) >= schemaMaxFields {
+				continue
+			}
+			types = make(map[string]int64)
+			schema.Fields[path] = types
+		}
+		types[kind]++
+	}
+}
+
+// collectFields adds the path and JSON type of every field below value to
+// fields. The document root is recorded under the path "$".
+func collectFields(value any, path string, depth int, fields map[string]string) {
+	if path == "" {
+		fields["$"] = jsonKind(value)
+	} else {
+		fields[path] = jsonKind(value)
+	}
+	if depth >= schemaMaxDepth {
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for name, member := range v {
+			memberPath := name
+			if path != "" {
+				memberPath = path + "." + name
+			}
+			collectFields(member, memberPath, depth+1, fields)
+		}
+	case []any:
+		elementPath := path + "[]"
+		if path == "" {
+			elementPath = "$[]"
+		}
+		for _, element := range v {
+			collectFields(element, elementPath, depth+1, fields)
+		}
+	}
+}
+
+// jsonKind names the JSON type of a decoded value.
+func jsonKind(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// observedSchemaEntry is one row of the observed schema report.
+type observedSchemaEntry struct {
+	usageBucket
+	observedSchema
+}
+
+// report returns a snapshot of all buckets ordered by app id and prefix.
+func (r *schemaRegistry) report() []observedSchemaEntry {
+	r.mu.Lock()
+	entries := make([]observedSchemaEntry, 0, len(r.schemas))
+	for bucket, schema := range r.schemas {
+		fields := make(map[string]map[string]int64, len(schema.Fields))
+		for path, types := range schema.Fields {
+			copied := make(map[string]int64, len(types))
+			for kind, count := range types {
+				copied[kind] = count
+			}
+			fields[path] = copied
+		}
+		entries = append(entries, observedSchemaEntry{
+			usageBucket:    bucket,
+			observedSchema: observedSchema{Samples: schema.Samples, NonJSON: schema.NonJSON, Fields: fields},
+		})
+	}
+	r.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AppID != entries[j].AppID {
+			return entries[i].AppID < entries[j].AppID
+		}
+		return entries[i].Prefix < entries[j].Prefix
+	})
+	return entries
+}
+
+// observedSchemaQuery answers a Query carrying the observedSchema metadata
+// with one item per (app id, prefix) bucket.
+func (store *ScyllaStateStore) observedSchemaQuery() (*state.QueryResponse, error) {
+	if store.schemas == nil {
+		return nil, errors.New("observed schema requires schemaSampling to be enabled")
+	}
+
+	entries := store.schemas.report()
+	results := make([]state.QueryItem, 0, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, state.QueryItem{
+			Key:  entry.AppID + "/" + entry.Prefix,
+			Data: data,
+		})
+	}
+
+	return &state.QueryResponse{Results: results}, nil
+}
@@ -0,0 +1,409 @@
+package scylladb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+)
+
+// Request metadata key that routes a Query to the search index
+const fullTextQueryMetadataKey = "fullText"
+
+var errSearchIndexUnscoped = errors.New("full-text queries require appId, APP_ID or searchIndexKeyPrefix to scope results; set searchIndexKeyPrefix=none to search all keys")
+
+// indexOp is a single pending mutation of the search index.
+type indexOp struct {
+	id     string         // document id (the stored partition key)
+	doc    map[string]any // nil for deletes
+	delete bool
+}
+
+// searchIndexer asynchronously mirrors writes into an OpenSearch/Elasticsearch
+// index using the _bulk API. Writes are buffered in a bounded queue, flushed by
+// size or interval, and retried with backoff; when the queue is full new
+// operations are dropped and logged rather than blocking the state write path.
+type searchIndexer struct {
+	client        *http.Client
+	baseURL       string
+	index         string
+	username      string
+	password      string
+	fields        []string
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	keyPrefix     string // Dapr key prefix of the hits search returns
+	scoped        bool   // false with searchIndexKeyPrefix=none
+	logger        logger.Logger
+
+	queue  chan indexOp
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	verifier *searchIndexVerifier // nil without searchIndexVerifyInterval
+}
+
+// initSearchIndex parses the search index settings and starts the flush loop.
+func (store *ScyllaStateStore) initSearchIndex() {
+	batchSize, err := strconv.Atoi(store.config.SearchIndexBatchSize)
+	if err != nil || batchSize <= 0 {
+		store.logger.Warnf("Invalid searchIndexBatchSize: %s, using default", store.config.SearchIndexBatchSize)
+		batchSize = 500
+	}
+
+	flushInterval, err := time.ParseDuration(store.config.SearchIndexFlushInterval)
+	if err != nil || flushInterval <= 0 {
+		store.logger.Warnf("Invalid searchIndexFlushInterval: %s, using default", store.config.SearchIndexFlushInterval)
+		flushInterval = time.Second
+	}
+
+	maxRetries, err := strconv.Atoi(store.config.SearchIndexMaxRetries)
+	if err != nil || maxRetries < 0 {
+		store.logger.Warnf("Invalid searchIndexMaxRetries: %s, using default", store.config.SearchIndexMaxRetries)
+		maxRetries = 3
+	}
+
+	var fields []string
+	for _, field := range strings.Split(store.config.SearchIndexFields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	// Tables are shared between apps, so results are limited to the caller's keys
+	scoped := store.config.SearchIndexKeyPrefix != "none"
+	keyPrefix := ""
+	if scoped {
+		keyPrefix = store.config.SearchIndexKeyPrefix
+	}
+	if keyPrefix == "" && scoped {
+		appID := store.config.AppID
+		if appID == "" {
+			appID = os.Getenv("APP_ID")
+		}
+		if appID != "" {
+			keyPrefix = appID + daprKeySeparator
+		}
+	}
+	if scoped && keyPrefix == "" {
+		store.logger.Warnf("searchIndexUrl is set but no appId, APP_ID or searchIndexKeyPrefix is known; full-text queries will be rejected")
+	}
+
+	indexer := &searchIndexer{
+		client:        &http.Client{Timeout: 30 * time.Second},
+		baseURL:       strings.TrimRight(store.config.SearchIndexURL, "/"),
+		index:         store.config.SearchIndexName,
+		username:      store.config.SearchIndexUsername,
+		password:      store.config.SearchIndexPassword,
+		fields:        fields,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		maxRetries:    maxRetries,
+		keyPrefix:     keyPrefix,
+		scoped:        scoped,
+		logger:        store.logger,
+		queue:         make(chan indexOp, batchSize*10),
+		stopCh:        make(chan struct{}),
+	}
+	store.searchIndex = indexer
+
+	store.logger.Infof("Search index mirroring enabled (url=%s, index=%s, fields=%v, keyPrefix=%q)",
+		indexer.baseURL, indexer.index, fields, keyPrefix)
+
+	indexer.wg.Add(1)
+	go indexer.run()
+}
+
+// enqueue adds an operation without blocking the caller. It returns false
+// when the queue is full and the operation was dropped.
+func (ix *searchIndexer) enqueue(op indexOp) bool {
+	select {
+	case ix.queue <- op:
+		return true
+	default:
+		ix.logger.Warnf("Search index queue full, dropping update for %s", op.id)
+		return false
+	}
+}
+
+// indexSet mirrors an upsert of the given Dapr key and stored value.
+func (ix *searchIndexer) indexSet(daprKey, storageKey, value string) bool {
+	return ix.enqueue(indexOp{id: storageKey, doc: ix.document(daprKey, value)})
+}
+
+// document builds the search document of the given Dapr key and stored value.
+func (ix *searchIndexer) document(daprKey, value string) map[string]any {
+	doc := map[string]any{"key": daprKey}
+
+	var parsed map[string]any
+	if len(ix.fields) > 0 && json.Unmarshal(stringToBytes(value), &parsed) == nil {
+		for _, field := range ix.fields {
+			if fieldValue, ok := lookupJSONPath(parsed, field); ok {
+				doc[field] = fieldValue
+			}
+		}
+	} else {
+		doc["value"] = value
+	}
+	return doc
+}
+
+// indexDelete mirrors a delete of the given stored key.
+func (ix *searchIndexer) indexDelete(storageKey string) bool {
+	return ix.enqueue(indexOp{id: storageKey, delete: true})
+}
+
+// lookupJSONPath resolves a dotted path like "customer.name" in a decoded JSON object.
+func lookupJSONPath(doc map[string]any, path string) (any, bool) {
+	var current any = doc
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func (ix *searchIndexer) run() {
+	defer ix.wg.Done()
+
+	ticker := time.NewTicker(ix.flushInterval)
+	defer ticker.Stop()
+
+	pending := make([]indexOp, 0, ix.batchSize)
+	for {
+		select {
+		case op := <-ix.queue:
+			pending = append(pending, op)
+			if len(pending) >= ix.batchSize {
+				ix.flush(pending)
+				pending = pending[:0]
+			}
+		case <-ticker.C:
+			if len(pending) > 0 {
+				ix.flush(pending)
+				pending = pending[:0]
+			}
+		case <-ix.stopCh:
+			// Drain whatever is still queued before exiting
+			for {
+				select {
+				case op := <-ix.queue:
+					pending = append(pending, op)
+				default:
+					if len(pending) > 0 {
+						ix.flush(pending)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush sends one _bulk request, retrying transient failures with backoff.
+func (ix *searchIndexer) flush(ops []indexOp) {
+	body := getBuffer()
+	defer putBuffer(body)
+	encoder := json.NewEncoder(body)
+	for _, op := range ops {
+		action := "index"
+		if op.delete {
+			action = "delete"
+		}
+		_ = encoder.Encode(map[string]any{action: map[string]string{"_index": ix.index, "_id": op.id}})
+		if !op.delete {
+			if err := encoder.Encode(op.doc); err != nil {
+				ix.logger.Warnf("Failed to encode search document for %s: %v", op.id, err)
+			}
+		}
+	}
+
+	for attempt := 1; attempt <= ix.maxRetries+1; attempt++ {
+		err := ix.sendBulk(body.Bytes())
+		if err == nil {
+			ix.logger.Debugf("Flushed %d operations to search index", len(ops))
+			return
+		}
+
+		if attempt <= ix.maxRetries {
+			backoff := time.Duration(attempt*attempt) * 100 * time.Millisecond
+			ix.logger.Warnf("Search index bulk request failed (attempt %d/%d), retrying after %v: %v",
+				attempt, ix.maxRetries+1, backoff, err)
+			time.Sleep(backoff)
+			continue
+		}
+
+		ix.logger.Errorf("Dropping %d search index operations after %d attempts: %v", len(ops), attempt, err)
+	}
+}
+
+func (ix *searchIndexer) sendBulk(payload []byte) error {
+	httpReq, err := http.NewRequest(http.MethodPost, ix.baseURL+"/_bulk", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-ndjson")
+	if ix.username != "" {
+		httpReq.SetBasicAuth(ix.username, ix.password)
+	}
+
+	resp, err := ix.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("bulk request returned HTTP %d: %s", resp.StatusCode, respBody)
+	}
+
+	// Item-level failures are not retried as a whole; log them for investigation
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if json.Unmarshal(respBody, &result) == nil && result.Errors {
+		ix.logger.Warnf("Search index bulk request reported item errors: %.512s", respBody)
+	}
+	return nil
+}
+
+// search runs a full-text query and returns matching Dapr keys and the token
+// for the next page. Hits outside the key prefix are dropped after paging, so
+// a page may hold fewer than limit keys while more pages follow.
+func (ix *searchIndexer) search(ctx context.Context, text string, limit int, token string) ([]string, string, error) {
+	if ix.scoped && ix.keyPrefix == "" {
+		return nil, "", errSearchIndexUnscoped
+	}
+	from := 0
+	if token != "" {
+		parsed, err := strconv.Atoi(token)
+		if err != nil || parsed < 0 {
+			return nil, "", fmt.Errorf("invalid pagination token: %s", token)
+		}
+		from = parsed
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	searchBody, err := json.Marshal(map[string]any{
+		"from":    from,
+		"size":    limit,
+		"_source": []string{"key"},
+		"query": map[string]any{
+			"query_string": map[string]any{"query": text},
+		},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, ix.baseURL+"/"+ix.index+"/_search", bytes.NewReader(searchBody))
+	if err != nil {
+		return nil, "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if ix.username != "" {
+		httpReq.SetBasicAuth(ix.username, ix.password)
+	}
+
+	resp, err := ix.client.Do(httpReq)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("search returned HTTP %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source struct {
+					Key string `json:"key"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, "", fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	keys := make([]string, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		if strings.HasPrefix(hit.Source.Key, ix.keyPrefix) {
+			keys = append(keys, hit.Source.Key)
+		}
+	}
+
+	nextToken := ""
+	if len(result.Hits.Hits) == limit {
+		nextToken = strconv.Itoa(from + limit)
+	}
+	return keys, nextToken, nil
+}
+
+func (ix *searchIndexer) stop() {
+	close(ix.stopCh)
+	ix.wg.Wait()
+}
+
+// fullTextQuery resolves a full-text query against the search index and loads
+// the current values of the matching keys from ScyllaDB.
+func (store *ScyllaStateStore) fullTextQuery(ctx context.Context, req *state.QueryRequest, text string) (*state.QueryResponse, error) {
+	keys, nextToken, err := store.searchIndex.search(ctx, text, req.Query.Page.Limit, req.Query.Page.Token)
+	if err != nil {
+		store.logger.Errorf("Full-text search failed: %v", err)
+		return nil, fmt.Errorf("full-text search failed: %w", err)
+	}
+
+	storageKeys := make([]string, len(keys))
+	for i, key := range keys {
+		storageKeys[i] = store.storageKey(key)
+	}
+
+	rows := make(map[string]state.QueryItem, len(keys))
+	_, err = store.fetchRows(ctx, storageKeys, func(storageKey string, value []byte, etag string) bool {
+		rows[storageKey] = state.QueryItem{Data: value, ETag: &etag}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load search results: %w", err)
+	}
+
+	// Keep the relevance order of the search engine; skip keys deleted since indexing
+	results := make([]state.QueryItem, 0, len(keys))
+	for i, key := range keys {
+		if item, ok := rows[storageKeys[i]]; ok {
+			item.Key = key
+			store.meterRead(key, len(item.Data))
+			results = append(results, item)
+		}
+	}
+
+	store.logger.Debugf("Full-text query returned %d results", len(results))
+	return &state.QueryResponse{
+		Results: results,
+		Token:   nextToken,
+	}, nil
+}
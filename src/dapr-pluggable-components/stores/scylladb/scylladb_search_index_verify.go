@@ -0,0 +1,443 @@
+package scylladb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Time a difference is given to resolve itself before it counts, on top of
+// the flush interval: writes still queued for the index when a key is
+// compared are not drift
+const searchIndexVerifyRecheckDelay = 10 * time.Second
+
+// Documents a search request returns at most by default (index.max_result_window)
+const searchIndexMaxResultWindow = 10000
+
+// SearchIndexVerifyReport counts the keys one verification of the search
+// index compared and the differences it found. Keys are stored partition
+// keys, which are also the document ids.
+type SearchIndexVerifyReport struct {
+	Checked  int64  `json:"checked"`  // keys stored or indexed
+	Missing  int64  `json:"missing"`  // stored without a document
+	Stale    int64  `json:"stale"`    // with a document not matching the stored value
+	Orphaned int64  `json:"orphaned"` // with a document but no longer stored
+	Repaired int64  `json:"repaired"`
+	Duration string `json:"duration"`
+}
+
+// Drifted returns the number of keys whose document differs from the table.
+func (r SearchIndexVerifyReport) Drifted() int64 {
+	return r.Missing + r.Stale + r.Orphaned
+}
+
+// searchIndexVerifier periodically compares a sample of stored values with
+// their search documents, since dropped or failed index updates would
+// otherwise leave full-text queries matching stale projections. With repair
+// set, documents that differ are reindexed from the table or deleted.
+type searchIndexVerifier struct {
+	interval time.Duration
+	sample   int
+	repair   bool
+	job      *backgroundJob
+
+	rounds   atomic.Int64
+	checked  atomic.Int64
+	missing  atomic.Int64
+	stale    atomic.Int64
+	orphaned atomic.Int64
+	repaired atomic.Int64
+	last     atomic.Pointer[SearchIndexVerifyReport]
+}
+
+// searchDrift is the difference between a stored key and its document.
+type searchDrift struct {
+	kind    string // missing, stale or orphaned
+	daprKey string // empty when the key cannot be recovered
+	value   []byte
+}
+
+// initSearchIndexVerify parses the verification settings and starts the job.
+// With leader election enabled only the replica holding the
+// searchIndexVerify lease compares documents.
+func (store *ScyllaStateStore) initSearchIndexVerify() {
+	ix := store.searchIndex
+	if ix == nil {
+		store.logger.Warnf("searchIndexVerifyInterval is set without searchIndexUrl, ignoring it")
+		return
+	}
+
+	interval, err := time.ParseDuration(store.config.SearchIndexVerifyInterval)
+	if err != nil || interval <= 0 {
+		store.logger.Warnf("Invalid searchIndexVerifyInterval: %s, using default", store.config.SearchIndexVerifyInterval)
+		interval = 15 * time.Minute
+	}
+	sample, err := strconv.Atoi(store.config.SearchIndexVerifySample)
+	if err != nil || sample <= 0 {
+		store.logger.Warnf("Invalid searchIndexVerifySample: %s, using default", store.config.SearchIndexVerifySample)
+		sample = 1000
+	}
+	if sample > searchIndexMaxResultWindow {
+		store.logger.Warnf("searchIndexVerifySample %d is above the %d documents a search returns, using %d",
+			sample, searchIndexMaxResultWindow, searchIndexMaxResultWindow)
+		sample = searchIndexMaxResultWindow
+	}
+
+	verifier := &searchIndexVerifier{
+		interval: interval,
+		sample:   sample,
+		repair:   store.config.SearchIndexVerifyRepair == "true",
+	}
+	ix.verifier = verifier
+
+	store.logger.Infof("Verifying %d sampled keys against the search index every %v (repair=%t)", sample, interval, verifier.repair)
+
+	verifier.job = store.startBackgroundJob("searchIndexVerify", func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			report, err := store.verifySearchIndex(ctx, verifier.sample, verifier.repair)
+			if err != nil {
+				if ctx.Err() == nil {
+					store.logger.Warnf("Search index verification failed: %v", err)
+				}
+				continue
+			}
+			verifier.record(report)
+			if report.Drifted() > 0 {
+				store.logger.Warnf("Search index verification: %d of %d sampled keys differ (missing=%d, stale=%d, orphaned=%d, repaired=%d)",
+					report.Drifted(), report.Checked, report.Missing, report.Stale, report.Orphaned, report.Repaired)
+			} else {
+				store.logger.Debugf("Search index verification: %d sampled keys match", report.Checked)
+			}
+		}
+	})
+}
+
+// verifySearchIndex compares up to sample keys of the table, starting at a
+// random token, and as many random documents of the index. Keys sampled on
+// either side are read on both; differences are read again after the flush
+// interval and searchIndexVerifyRecheckDelay and only count if they remain.
+func (store *ScyllaStateStore) verifySearchIndex(ctx context.Context, sample int, repair bool) (SearchIndexVerifyReport, error) {
+	start := time.Now()
+	var report SearchIndexVerifyReport
+
+	store.mu.RLock()
+	session := store.session
+	ix := store.searchIndex
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || session == nil {
+		return report, errors.New("store is closed")
+	}
+
+	keys, err := store.sampleKeys(ctx, session, sample)
+	if err != nil {
+		return report, err
+	}
+	ids, err := ix.sampleDocumentIDs(ctx, sample)
+	if err != nil {
+		return report, err
+	}
+	// Keys on both sides are compared once
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			keys = append(keys, id)
+		}
+	}
+
+	var candidates []string
+	for batchStart := 0; batchStart < len(keys); batchStart += store.inBatchSize() {
+		batch := keys[batchStart:min(batchStart+store.inBatchSize(), len(keys))]
+		drifts, checked, err := store.compareSearchDocuments(ctx, ix, batch)
+		if err != nil {
+			return report, err
+		}
+		report.Checked += checked
+		for _, key := range batch {
+			if _, ok := drifts[key]; ok {
+				candidates = append(candidates, key)
+			}
+		}
+	}
+
+	if len(candidates) > 0 {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-time.After(ix.flushInterval + searchIndexVerifyRecheckDelay):
+		}
+	}
+	if err := store.recheckSearchDocuments(ctx, ix, candidates, repair, &report); err != nil {
+		return report, err
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	return report, nil
+}
+
+// compareSearchDocuments reads keys from the table and the index and returns
+// the ones whose document differs from the document their value would be
+// indexed as. Keys gone from both meanwhile are not counted.
+func (store *ScyllaStateStore) compareSearchDocuments(ctx context.Context, ix *searchIndexer, keys []string) (map[string]searchDrift, int64, error) {
+	rows := make(map[string][]byte, len(keys))
+	_, err := store.fetchRows(ctx, keys, func(key string, value []byte, _ string) bool {
+		rows[key] = value
+		return true
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read keys for search index verification: %w", err)
+	}
+	docs, err := ix.getDocuments(ctx, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var checked int64
+	drifts := make(map[string]searchDrift)
+	for _, key := range keys {
+		value, stored := rows[key]
+		doc, indexed := docs[key]
+		if !stored && !indexed {
+			continue
+		}
+		checked++
+
+		// Hashed partition keys keep the Dapr key in the document only
+		daprKey := key
+		if store.keys != nil {
+			var ok bool
+			if daprKey, ok = store.keys.fromStorage(key); !ok {
+				daprKey, _ = doc["key"].(string)
+			}
+		}
+
+		switch {
+		case !stored:
+			drifts[key] = searchDrift{kind: "orphaned"}
+		case !indexed:
+			drifts[key] = searchDrift{kind: "missing", daprKey: daprKey, value: value}
+		case daprKey == "" || !reflect.DeepEqual(normalizeDocument(ix.document(daprKey, bytesToString(value))), doc):
+			drifts[key] = searchDrift{kind: "stale", daprKey: daprKey, value: value}
+		}
+	}
+	return drifts, checked, nil
+}
+
+// recheckSearchDocuments compares keys that differed once more, counts the
+// ones that still differ and, with repair, queues their reindexing or the
+// delete of their document. A write racing a repair may be indexed before
+// it; the next round finds and repairs the document again.
+func (store *ScyllaStateStore) recheckSearchDocuments(ctx context.Context, ix *searchIndexer, keys []string, repair bool, report *SearchIndexVerifyReport) error {
+	for batchStart := 0; batchStart < len(keys); batchStart += store.inBatchSize() {
+		batch := keys[batchStart:min(batchStart+store.inBatchSize(), len(keys))]
+		drifts, _, err := store.compareSearchDocuments(ctx, ix, batch)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range batch {
+			drift, ok := drifts[key]
+			if !ok {
+				continue
+			}
+			switch drift.kind {
+			case "missing":
+				report.Missing++
+			case "stale":
+				report.Stale++
+			case "orphaned":
+				report.Orphaned++
+			}
+			store.logger.Debugf("Search document of key %s is %s", key, drift.kind)
+			if !repair {
+				continue
+			}
+
+			var queued bool
+			switch {
+			case drift.kind == "orphaned":
+				queued = ix.indexDelete(key)
+			case drift.daprKey == "":
+				store.logger.Warnf("Cannot reindex key %s: its Dapr key is neither in the document nor recoverable from the partition key", key)
+			default:
+				queued = ix.indexSet(drift.daprKey, key, bytesToString(drift.value))
+			}
+			if queued {
+				report.Repaired++
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeDocument converts a document to the types its JSON decodes to, so
+// it compares equal to the same document read from the index.
+func normalizeDocument(doc map[string]any) map[string]any {
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return doc
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return doc
+	}
+	return normalized
+}
+
+// getDocuments reads the documents with the given ids through the _mget API.
+// Ids without a document are left out.
+func (ix *searchIndexer) getDocuments(ctx context.Context, ids []string) (map[string]map[string]any, error) {
+	docs := make(map[string]map[string]any, len(ids))
+	if len(ids) == 0 {
+		return docs, nil
+	}
+
+	var result struct {
+		Docs []struct {
+			ID     string         `json:"_id"`
+			Found  bool           `json:"found"`
+			Source map[string]any `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := ix.post(ctx, "/"+ix.index+"/_mget", map[string]any{"ids": ids}, &result); err != nil {
+		return nil, fmt.Errorf("failed to read search documents: %w", err)
+	}
+	for _, doc := range result.Docs {
+		if doc.Found {
+			docs[doc.ID] = doc.Source
+		}
+	}
+	return docs, nil
+}
+
+// sampleDocumentIDs returns the ids of up to sample random documents, so
+// documents of keys no longer stored are found too.
+func (ix *searchIndexer) sampleDocumentIDs(ctx context.Context, sample int) ([]string, error) {
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	err := ix.post(ctx, "/"+ix.index+"/_search", map[string]any{
+		"size":    min(sample, searchIndexMaxResultWindow),
+		"_source": false,
+		"query": map[string]any{
+			"function_score": map[string]any{
+				"query":        map[string]any{"match_all": map[string]any{}},
+				"random_score": map[string]any{},
+			},
+		},
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample search documents: %w", err)
+	}
+
+	ids := make([]string, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids, nil
+}
+
+// post sends a JSON request to the search cluster and decodes the response
+// into result.
+func (ix *searchIndexer) post(ctx context.Context, path string, body, result any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, ix.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if ix.username != "" {
+		httpReq.SetBasicAuth(ix.username, ix.password)
+	}
+
+	resp, err := ix.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned HTTP %d: %.512s", path, resp.StatusCode, respBody)
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+func (v *searchIndexVerifier) record(report SearchIndexVerifyReport) {
+	v.rounds.Add(1)
+	v.checked.Add(report.Checked)
+	v.missing.Add(report.Missing)
+	v.stale.Add(report.Stale)
+	v.orphaned.Add(report.Orphaned)
+	v.repaired.Add(report.Repaired)
+	v.last.Store(&report)
+}
+
+func (v *searchIndexVerifier) stop() {
+	if v.job != nil {
+		v.job.stop()
+	}
+}
+
+// gauges returns the verification counters for the metrics endpoint.
+func (v *searchIndexVerifier) gauges() map[string]float64 {
+	gauges := map[string]float64{
+		"search_index_verify_checked_total":  float64(v.checked.Load()),
+		"search_index_verify_missing_total":  float64(v.missing.Load()),
+		"search_index_verify_stale_total":    float64(v.stale.Load()),
+		"search_index_verify_orphaned_total": float64(v.orphaned.Load()),
+		"search_index_verify_repaired_total": float64(v.repaired.Load()),
+	}
+	if last := v.last.Load(); last != nil && last.Checked > 0 {
+		gauges["search_index_drift_ratio"] = float64(last.Drifted()) / float64(last.Checked)
+	}
+	return gauges
+}
+
+func (v *searchIndexVerifier) diagnostics() map[string]any {
+	diagnostics := map[string]any{
+		"interval": v.interval.String(),
+		"sample":   v.sample,
+		"repair":   v.repair,
+		"rounds":   v.rounds.Load(),
+		"checked":  v.checked.Load(),
+		"missing":  v.missing.Load(),
+		"stale":    v.stale.Load(),
+		"orphaned": v.orphaned.Load(),
+		"repaired": v.repaired.Load(),
+	}
+	if last := v.last.Load(); last != nil {
+		diagnostics["last"] = *last
+	}
+	return diagnostics
+}
@@ -0,0 +1,81 @@
+package scylladb
+
+import (
+	"errors"
+
+	"github.com/dapr/kit/logger"
+	"github.com/gocql/gocql"
+)
+
+// SessionOwnership says who closes a session passed to
+// NewScyllaStateStoreWithSession.
+type SessionOwnership int
+
+const (
+	// StoreOwnsSession makes Close, or a failed Init, close the session.
+	StoreOwnsSession SessionOwnership = iota
+	// CallerOwnsSession leaves the session open; the caller closes it after
+	// closing the store.
+	CallerOwnsSession
+)
+
+// injectedSession is a session created by the program embedding the store.
+type injectedSession struct {
+	session   *gocql.Session
+	ownership SessionOwnership
+}
+
+// NewScyllaStateStoreWithSession creates a store that runs on an existing
+// session, for Go programs that embed the store as a library and already hold
+// a connection to the cluster.
+//
+// cfg holds the options otherwise read from component metadata. Init must
+// still be called; properties in its metadata override cfg, and an empty
+// state.Metadata uses cfg as-is. The session must be bound to the store's
+// keyspace, which Init does not create. Options that configure the connection,
+// such as hosts, TLS, compression, numConns, actorPinning and
+// writeTimestamps=server, are those of the session and are ignored here, and
+// the store never replaces the session: TLS reloads and schema refreshes are
+// not available.
+func NewScyllaStateStoreWithSession(session *gocql.Session, cfg ScyllaConfig, ownership SessionOwnership, inputLogger logger.Logger) *ScyllaStateStore {
+	if inputLogger == nil {
+		inputLogger = logger.NewLogger("scylladb-state")
+	}
+	return &ScyllaStateStore{
+		logger:   inputLogger,
+		config:   cfg,
+		injected: &injectedSession{session: session, ownership: ownership},
+	}
+}
+
+// initializeInjectedSession creates the state table on the injected session.
+func (store *ScyllaStateStore) initializeInjectedSession() error {
+	session := store.injected.session
+	if session == nil || session.Closed() {
+		return errors.New("injected session is nil or closed")
+	}
+	// The driver reports host events to the session's own policy
+	store.hosts = nil
+	store.logger.Infof("Using injected session (keyspace=%s, closed by the %s)", store.config.Keyspace, store.injected.owner())
+
+	if err := store.initializeTables(session); err != nil {
+		store.closeSession(session)
+		return err
+	}
+	return nil
+}
+
+// closeSession closes session unless the embedding program owns it.
+func (store *ScyllaStateStore) closeSession(session *gocql.Session) {
+	if injected := store.injected; injected != nil && injected.session == session && injected.ownership == CallerOwnsSession {
+		return
+	}
+	session.Close()
+}
+
+func (i *injectedSession) owner() string {
+	if i.ownership == CallerOwnsSession {
+		return "caller"
+	}
+	return "store"
+}
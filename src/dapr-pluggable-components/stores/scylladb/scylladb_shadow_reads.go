@@ -0,0 +1,243 @@
+package scylladb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	// Shadow reads running at once; Gets beyond that are not shadowed
+	shadowReadConcurrency = 64
+	// Time a shadow read may take, recheck included
+	shadowReadTimeout = 10 * time.Second
+	// Time a difference is given to resolve itself before it counts, as the
+	// mirror replays writes asynchronously
+	shadowReadRecheckDelay = 2 * time.Second
+	// Period the latency percentiles are computed over
+	shadowReadLatencyWindow = time.Minute
+	// Mismatched keys kept for the diagnostics dump
+	shadowReadRecentMismatches = 10
+)
+
+// shadowReader validates the mirror cluster as a future primary: a share of
+// the Gets served by the primary is read again from the mirror in the
+// background, and the two results and latencies are compared. The Get never
+// waits for its shadow read, and shadow reads that cannot start right away
+// are skipped rather than queued.
+//
+// A difference is read again on both clusters after shadowReadRecheckDelay
+// and only counts as a mismatch when the mirror still differs from an
+// unchanged primary; otherwise it is counted as lag.
+type shadowReader struct {
+	store   *ScyllaStateStore
+	mirror  *clusterMirror
+	percent float64
+	slots   chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	reads      atomic.Int64
+	matches    atomic.Int64
+	mismatches atomic.Int64
+	lagged     atomic.Int64
+	errors     atomic.Int64
+	skipped    atomic.Int64
+
+	mu        sync.Mutex
+	primary   latencyWindow
+	secondary latencyWindow
+	recent    []string // last mismatched Dapr keys, newest last
+}
+
+// initShadowReads parses the shadow read share of Gets. It requires the
+// mirror cluster.
+func (store *ScyllaStateStore) initShadowReads() {
+	mirror := store.clusterMirror
+	if mirror == nil {
+		store.logger.Warnf("mirrorShadowReads is set without mirrorHosts, ignoring it")
+		return
+	}
+	percent, err := strconv.ParseFloat(store.config.MirrorShadowReads, 64)
+	if err != nil || percent < 0 || percent > 100 {
+		store.logger.Warnf("Invalid mirrorShadowReads: %s, disabling shadow reads", store.config.MirrorShadowReads)
+		return
+	}
+	if percent == 0 {
+		return
+	}
+	if store.timeBuckets != nil {
+		store.logger.Warnf("mirrorShadowReads does not apply to time-bucketed tables, ignoring it")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mirror.shadow = &shadowReader{
+		store:   store,
+		mirror:  mirror,
+		percent: percent,
+		slots:   make(chan struct{}, shadowReadConcurrency),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	store.logger.Infof("Shadow reading %.4g%% of Gets from the mirror cluster", percent)
+}
+
+// shadowGet starts a shadow read of a key the primary returned as row, or
+// missing when found is false, in latency. The caller holds the store's read
+// lock, so Close waits for the read to start before stopping it.
+func (s *shadowReader) shadowGet(daprKey, storageKey string, row storedRow, found bool, latency time.Duration) {
+	if s.mirror.session.Load() == nil || rand.Float64()*100 >= s.percent {
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.skipped.Add(1)
+		return
+	}
+
+	s.mu.Lock()
+	s.primary.add(latencySample{at: time.Now(), duration: latency})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.slots }()
+		s.compare(daprKey, storageKey, row, found)
+	}()
+}
+
+// compare reads the key from the mirror and checks it against the primary's
+// result.
+func (s *shadowReader) compare(daprKey, storageKey string, row storedRow, found bool) {
+	ctx, cancel := context.WithTimeout(s.ctx, shadowReadTimeout)
+	defer cancel()
+	session := s.mirror.session.Load()
+
+	var blob []byte
+	var etag string
+	start := time.Now()
+	err := session.Query(fmt.Sprintf("SELECT value_blob, etag FROM %s WHERE key = ?", s.mirror.table), storageKey).
+		WithContext(ctx).Scan(&blob, &etag)
+	latency := time.Since(start)
+	onMirror := err == nil
+	if err != nil && err != gocql.ErrNotFound {
+		if s.ctx.Err() == nil {
+			s.errors.Add(1)
+			s.store.logger.Debugf("Shadow read of key %s failed: %v", daprKey, err)
+		}
+		return
+	}
+
+	s.reads.Add(1)
+	s.mu.Lock()
+	s.secondary.add(latencySample{at: time.Now(), duration: latency})
+	s.mu.Unlock()
+
+	if onMirror == found && (!found || (etag == row.etag && bytes.Equal(blob, storedBytes(row.text, row.blob)))) {
+		s.matches.Add(1)
+		return
+	}
+
+	// The mirror may not have replayed the latest write yet
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(shadowReadRecheckDelay):
+	}
+	s.store.mu.RLock()
+	if s.store.closed || s.store.session == nil {
+		s.store.mu.RUnlock()
+		return
+	}
+	primaryRows, err := s.store.readMirrorRows(ctx, s.store.session, []string{storageKey})
+	s.store.mu.RUnlock()
+	if err != nil {
+		return
+	}
+	mirrorRows, err := s.store.readMirrorRows(ctx, session, []string{storageKey})
+	if err != nil {
+		return
+	}
+	primaryRow, nowOnPrimary := primaryRows[storageKey]
+	mirrorRow, nowOnMirror := mirrorRows[storageKey]
+	if nowOnPrimary != found || primaryRow.etag != row.etag ||
+		(nowOnPrimary == nowOnMirror && primaryRow.etag == mirrorRow.etag) {
+		// The key changed meanwhile, or the mirror caught up
+		s.lagged.Add(1)
+		return
+	}
+
+	s.mismatches.Add(1)
+	s.store.logger.Debugf("Shadow read of key %s differs on the mirror cluster (primary=%t, mirror=%t)", daprKey, found, nowOnMirror)
+	s.mu.Lock()
+	s.recent = append(s.recent, daprKey)
+	if len(s.recent) > shadowReadRecentMismatches {
+		s.recent = s.recent[1:]
+	}
+	s.mu.Unlock()
+}
+
+// stop cancels the shadow reads under way and waits for them. It runs in
+// Close before the mirror's session is closed.
+func (s *shadowReader) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// latencies returns the p99 latencies of the primary and the mirror over the
+// last shadowReadLatencyWindow.
+func (s *shadowReader) latencies() (time.Duration, time.Duration) {
+	since := time.Now().Add(-shadowReadLatencyWindow)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	primary, _ := s.primary.p99(since)
+	secondary, _ := s.secondary.p99(since)
+	return primary, secondary
+}
+
+// gauges returns the shadow read counters and latencies for the metrics
+// endpoint.
+func (s *shadowReader) gauges() map[string]float64 {
+	primary, secondary := s.latencies()
+	return map[string]float64{
+		"shadow_reads_total":              float64(s.reads.Load()),
+		"shadow_read_mismatches_total":    float64(s.mismatches.Load()),
+		"shadow_read_lagged_total":        float64(s.lagged.Load()),
+		"shadow_read_errors_total":        float64(s.errors.Load()),
+		"shadow_reads_skipped_total":      float64(s.skipped.Load()),
+		"shadow_read_primary_p99_seconds": primary.Seconds(),
+		"shadow_read_mirror_p99_seconds":  secondary.Seconds(),
+		"shadow_read_p99_delta_seconds":   (secondary - primary).Seconds(),
+	}
+}
+
+func (s *shadowReader) diagnostics() map[string]any {
+	primary, secondary := s.latencies()
+	s.mu.Lock()
+	recent := append([]string(nil), s.recent...)
+	s.mu.Unlock()
+	return map[string]any{
+		"percent":          s.percent,
+		"reads":            s.reads.Load(),
+		"matches":          s.matches.Load(),
+		"mismatches":       s.mismatches.Load(),
+		"lagged":           s.lagged.Load(),
+		"errors":           s.errors.Load(),
+		"skipped":          s.skipped.Load(),
+		"primaryP99":       primary.String(),
+		"mirrorP99":        secondary.String(),
+		"recentMismatches": recent,
+	}
+}
@@ -0,0 +1,208 @@
+package scylladb
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation names used for latency SLO tracking and in latencySlo
+const (
+	sloOpGet        = "get"
+	sloOpSet        = "set"
+	sloOpDelete     = "delete"
+	sloOpBulkGet    = "bulkGet"
+	sloOpBulkSet    = "bulkSet"
+	sloOpBulkDelete = "bulkDelete"
+	sloOpMulti      = "multi"
+	sloOpQuery      = "query"
+)
+
+const (
+	// Samples kept per operation; older samples are overwritten
+	sloMaxSamples = 4096
+	// Below this many samples in the window p99 is too noisy to act on
+	sloMinSamples = 50
+)
+
+// errLoadShed is returned for shed requests while the store is degraded.
+var errLoadShed = errors.New("request shed: store is degraded by a latency SLO breach")
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// latencyWindow is a fixed-size ring of recent latency samples for one operation.
+type latencyWindow struct {
+	samples []latencySample
+	next    int
+	full    bool
+}
+
+func (w *latencyWindow) add(sample latencySample) {
+	if w.samples == nil {
+		w.samples = make([]latencySample, sloMaxSamples)
+	}
+	w.samples[w.next] = sample
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// p99 returns the 99th percentile of samples newer than since and the number
+// of samples it was computed from.
+func (w *latencyWindow) p99(since time.Time) (time.Duration, int) {
+	count := w.next
+	if w.full {
+		count = len(w.samples)
+	}
+
+	durations := make([]time.Duration, 0, count)
+	for _, sample := range w.samples[:count] {
+		if sample.at.After(since) {
+			durations = append(durations, sample.duration)
+		}
+	}
+	if len(durations) == 0 {
+		return 0, 0
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	index := (len(durations)*99+99)/100 - 1
+	return durations[index], len(durations)
+}
+
+// sloTracker keeps a rolling p99 per operation and flags the store as degraded
+// while any operation with a configured target exceeds it. Breaches and
+// recoveries are logged as warnings so they can be alerted on.
+type sloTracker struct {
+	mu           sync.Mutex
+	targets      map[string]time.Duration
+	windows      map[string]*latencyWindow
+	breached     map[string]bool
+	window       time.Duration
+	shedQueries  bool
+	shedPriority priorityClass
+	lastEvaluate time.Time
+}
+
+// initLatencySLO parses the latency SLO settings.
+func (store *ScyllaStateStore) initLatencySLO() {
+	targets := make(map[string]time.Duration)
+	for _, entry := range strings.Split(store.config.LatencySLO, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		op, value, ok := strings.Cut(entry, "=")
+		target, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || err != nil || target <= 0 {
+			store.logger.Warnf("Invalid latencySlo entry: %s, ignoring", entry)
+			continue
+		}
+		targets[strings.TrimSpace(op)] = target
+	}
+	if len(targets) == 0 {
+		store.logger.Warnf("latencySlo has no valid entries, SLO tracking disabled")
+		return
+	}
+
+	window, err := time.ParseDuration(store.config.LatencySLOWindow)
+	if err != nil || window <= 0 {
+		store.logger.Warnf("Invalid latencySloWindow: %s, using default", store.config.LatencySLOWindow)
+		window = time.Minute
+	}
+
+	shedPriority := priorityNone
+	if name := store.config.LatencySLOShedPriority; name != "" && name != "none" {
+		class, ok := parsePriority(name)
+		if !ok || class == priorityCritical {
+			store.logger.Warnf("Invalid latencySloShedPriority: %s, using default", name)
+		} else {
+			shedPriority = class
+		}
+	}
+
+	store.slo = &sloTracker{
+		targets:      targets,
+		windows:      make(map[string]*latencyWindow),
+		breached:     make(map[string]bool),
+		window:       window,
+		shedQueries:  store.config.LatencySLOShedQueries == "true",
+		shedPriority: shedPriority,
+	}
+	store.logger.Infof("Latency SLO tracking enabled (targets=%v, window=%v, shedQueries=%t, shedPriority=%s)",
+		targets, window, store.slo.shedQueries, shedPriority)
+}
+
+// observeLatency records the latency of an operation that started at start.
+// It is meant to be deferred at the top of each store operation.
+func (store *ScyllaStateStore) observeLatency(op string, start time.Time) {
+	tracker := store.slo
+	if tracker == nil {
+		return
+	}
+
+	now := time.Now()
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	window, ok := tracker.windows[op]
+	if !ok {
+		window = &latencyWindow{}
+		tracker.windows[op] = window
+	}
+	window.add(latencySample{at: now, duration: now.Sub(start)})
+
+	// Re-evaluating sorts every window, so do it at most once a second
+	if now.Sub(tracker.lastEvaluate) < time.Second {
+		return
+	}
+	tracker.lastEvaluate = now
+
+	since := now.Add(-tracker.window)
+	for target, limit := range tracker.targets {
+		window, ok := tracker.windows[target]
+		if !ok {
+			continue
+		}
+		p99, samples := window.p99(since)
+		if samples < sloMinSamples {
+			// Shed or idle operations age out of the window; do not stay degraded on stale data
+			if tracker.breached[target] {
+				delete(tracker.breached, target)
+				store.logger.Warnf("Latency SLO for %s cleared: only %d samples in the last %v", target, samples, tracker.window)
+			}
+			continue
+		}
+
+		switch {
+		case p99 > limit && !tracker.breached[target]:
+			tracker.breached[target] = true
+			store.logger.Warnf("Latency SLO breached for %s: p99=%v exceeds %v over %d samples; store is degraded",
+				target, p99, limit, samples)
+		case p99 <= limit && tracker.breached[target]:
+			delete(tracker.breached, target)
+			store.logger.Warnf("Latency SLO recovered for %s: p99=%v within %v over %d samples",
+				target, p99, limit, samples)
+		}
+	}
+}
+
+// Degraded reports whether any operation currently breaches its latency SLO.
+// The pluggable component SDK answers health pings itself, so hosts that want
+// to surface degradation can poll this method.
+func (store *ScyllaStateStore) Degraded() bool {
+	tracker := store.slo
+	if tracker == nil {
+		return false
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return len(tracker.breached) > 0
+}
@@ -0,0 +1,234 @@
+package scylladb
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+
+	"nebulagraph/stores/clock"
+)
+
+// Response metadata marking a value served from the stale cache
+const (
+	staleMetadataKey    = "stale"
+	staleAgeMetadataKey = "staleAge"
+)
+
+// staleEntry is the last value this instance read or wrote for a key.
+type staleEntry struct {
+	storageKey string
+	value      []byte
+	etag       string
+	seenAt     time.Time // when the value was last confirmed by the backend
+}
+
+// staleCache keeps the last known value of recently read keys so reads can
+// be answered while the backend fails (stale-if-error). It is never consulted
+// while the backend answers. Entries are refreshed by reads and local writes,
+// removed by local deletes and evicted least recently used first; writes by
+// other instances are not seen, which the reported age accounts for.
+type staleCache struct {
+	clock      clock.Clock
+	maxAge     time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+
+	served atomic.Int64
+	missed atomic.Int64
+}
+
+// initStaleIfError parses the stale-if-error settings.
+func (store *ScyllaStateStore) initStaleIfError() {
+	maxAge, err := time.ParseDuration(store.config.StaleIfError)
+	if err != nil || maxAge <= 0 {
+		store.logger.Warnf("Invalid staleIfError: %s, stale reads disabled", store.config.StaleIfError)
+		return
+	}
+	maxEntries, err := strconv.Atoi(store.config.StaleCacheMaxEntries)
+	if err != nil || maxEntries <= 0 {
+		store.logger.Warnf("Invalid staleCacheMaxEntries: %s, using default", store.config.StaleCacheMaxEntries)
+		maxEntries = 10000
+	}
+
+	store.staleCache = &staleCache{
+		clock:      store.clock,
+		maxAge:     maxAge,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+	store.logger.Infof("Stale-if-error reads enabled (maxAge=%v, maxEntries=%d)", maxAge, maxEntries)
+}
+
+// remember records value as the current value of storageKey. Unless add is
+// set only keys already cached are refreshed, so write-only keys do not fill
+// the cache.
+func (c *staleCache) remember(storageKey string, value []byte, etag string, add bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[storageKey]; ok {
+		entry := element.Value.(*staleEntry)
+		entry.value, entry.etag, entry.seenAt = value, etag, c.clock.Now()
+		c.lru.MoveToFront(element)
+		return
+	}
+	if !add {
+		return
+	}
+	c.entries[storageKey] = c.lru.PushFront(&staleEntry{storageKey: storageKey, value: value, etag: etag, seenAt: c.clock.Now()})
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*staleEntry).storageKey)
+	}
+}
+
+// forget drops storageKey, after a delete or a read that found no row.
+func (c *staleCache) forget(storageKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[storageKey]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, storageKey)
+	}
+}
+
+// lookup returns the cached value of storageKey when it is younger than maxAge.
+func (c *staleCache) lookup(storageKey string) (*staleEntry, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[storageKey]
+	if !ok {
+		c.missed.Add(1)
+		return nil, 0, false
+	}
+	entry := element.Value.(*staleEntry)
+	age := clock.Since(c.clock, entry.seenAt)
+	if age > c.maxAge {
+		c.missed.Add(1)
+		return nil, 0, false
+	}
+	c.served.Add(1)
+	copied := *entry
+	return &copied, age, true
+}
+
+// rememberRead records a value read from the backend.
+func (store *ScyllaStateStore) rememberRead(storageKey string, value []byte, etag string) {
+	if store.staleCache != nil {
+		store.staleCache.remember(storageKey, value, etag, true)
+	}
+}
+
+// staleMetadata flags a response as served from the stale cache.
+func staleMetadata(age time.Duration) map[string]string {
+	return map[string]string{
+		staleMetadataKey:    "true",
+		staleAgeMetadataKey: age.Round(time.Millisecond).String(),
+	}
+}
+
+// staleGet answers a failed Get from the stale cache, or returns nil. Reads
+// the caller gave up on are not answered.
+func (store *ScyllaStateStore) staleGet(ctx context.Context, daprKey, storageKey string, cause error) *state.GetResponse {
+	if store.staleCache == nil || ctx.Err() != nil {
+		return nil
+	}
+	entry, age, ok := store.staleCache.lookup(storageKey)
+	if !ok {
+		return nil
+	}
+	store.logger.Warnf("Serving key %s from the stale cache (age %v) after read error: %v", daprKey, age.Round(time.Millisecond), cause)
+
+	response := &state.GetResponse{
+		Data:     entry.value,
+		ETag:     &entry.etag,
+		Metadata: staleMetadata(age),
+	}
+	if store.masker != nil {
+		response.Data = store.masker.mask(response.Data)
+	}
+	return response
+}
+
+// staleBulkGet fills the responses of a failed BulkGet from the stale cache.
+// Keys whose batch was read completely keep their result; done reports them.
+// Keys without a usable cached value get the read error. It returns false,
+// leaving responses unchanged, when no key could be answered.
+func (store *ScyllaStateStore) staleBulkGet(ctx context.Context, responses []state.BulkGetResponse, keys []string, done func(int) bool, cause error) bool {
+	if store.staleCache == nil || ctx.Err() != nil {
+		return false
+	}
+
+	type staleHit struct {
+		index int
+		entry *staleEntry
+		age   time.Duration
+	}
+	var hits []staleHit
+	var pending []int
+	for i := range keys {
+		if done(i) || responses[i].Data != nil {
+			continue
+		}
+		if entry, age, ok := store.staleCache.lookup(keys[i]); ok {
+			hits = append(hits, staleHit{index: i, entry: entry, age: age})
+		} else {
+			pending = append(pending, i)
+		}
+	}
+	if len(hits) == 0 {
+		return false
+	}
+
+	for _, hit := range hits {
+		data := hit.entry.value
+		if store.masker != nil {
+			data = store.masker.mask(data)
+		}
+		responses[hit.index].Data = data
+		responses[hit.index].ETag = &hit.entry.etag
+		responses[hit.index].Metadata = staleMetadata(hit.age)
+	}
+	for _, i := range pending {
+		responses[i].Error = cause.Error()
+	}
+	store.logger.Warnf("Served %d keys of a failed bulk get from the stale cache (%d without a cached value): %v", len(hits), len(pending), cause)
+	return true
+}
+
+// gauges returns the stale cache counters for the metrics endpoint.
+func (c *staleCache) gauges() map[string]float64 {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return map[string]float64{
+		"stale_cache_entries":      float64(entries),
+		"stale_reads_total":        float64(c.served.Load()),
+		"stale_cache_misses_total": float64(c.missed.Load()),
+	}
+}
+
+func (c *staleCache) diagnostics() map[string]any {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return map[string]any{
+		"maxAge":     c.maxAge.String(),
+		"maxEntries": c.maxEntries,
+		"entries":    entries,
+		"served":     c.served.Load(),
+		"missed":     c.missed.Load(),
+	}
+}
@@ -8,11 +8,18 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/dapr/components-contrib/metadata"
 	"github.com/dapr/components-contrib/state"
 	"github.com/dapr/kit/logger"
 	"github.com/gocql/gocql"
+
+	"nebulagraph/stores/clock"
+	"nebulagraph/stores/discovery"
+	"nebulagraph/stores/fixtures"
+	"nebulagraph/stores/stateext"
 )
 
 // ScyllaStateStore is a production-ready state store implementation for ScyllaDB.
@@ -20,10 +27,10 @@ import (
 // This implementation follows ScyllaDB GoCQL benchmark best practices:
 // 1. Token-aware host policy with round-robin fallback for optimal load distribution
 // 2. Prepared statements to minimize query parsing overhead
-// 3. Snappy compression for better network performance
+// 3. Configurable transport compression (Snappy by default) for better network performance
 // 4. Exponential backoff retry policy for resilient error handling
 // 5. Optimized connection pooling matching ScyllaDB's shard-per-core architecture
-// 6. UNLOGGED batches for better write performance in bulk operations
+// 6. Partition-aware UNLOGGED batches (one partition per batch) for bulk writes
 // 7. Concurrent execution patterns for small bulk operations
 // 8. Proper batch size limits (50-100 items) for optimal performance
 // 9. Connection pool optimizations (MaxPreparedStmts, WriteCoalesceWaitTime)
@@ -47,6 +54,119 @@ type ScyllaStateStore struct {
 	getStmt    *gocql.Query
 	setStmt    *gocql.Query
 	deleteStmt *gocql.Query
+	// Texts of the statements built per request, formatted once
+	queries stateQueries
+	// Texts of multi-key SELECTs by shape (see inQuery)
+	inQueries sync.Map
+	// Optional bloom filter of existing keys (nil when disabled)
+	keyFilter *keyFilter
+	// Maps Dapr keys to stored partition keys (see keyStrategy)
+	keys *keyMapper
+	// Optional asynchronous mirror into a search index (nil when disabled)
+	searchIndex *searchIndexer
+	// Number of partitions written concurrently by bulk operations
+	bulkConcurrency int
+	// Application-level retry policy for transient errors
+	retry retryPolicy
+	// Speculative execution policy of idempotent statements
+	speculative gocql.SpeculativeExecutionPolicy
+	// Optional read-after-write verification (nil when disabled)
+	verifier *writeVerifier
+	// Log destructive operations instead of executing them
+	dryRun bool
+	// Reject values that are not strings, bytes or JSON payloads
+	strictValues bool
+	// Optional rolling latency SLO tracking (nil when disabled)
+	slo *sloTracker
+	// Optional cache of Query responses (nil when disabled)
+	queryCache *queryCache
+	// Optional per-app usage metering (nil when disabled)
+	usage *usageMeter
+	// Optional sampling of the fields stored under each key prefix (nil when disabled)
+	schemas *schemaRegistry
+	// Optional operation mix and size histograms for capacity planning (nil when disabled)
+	workload *workloadSampler
+	// Optional per-key access frequency tracking (nil when disabled)
+	hotKeys *hotKeyTracker
+	// Optional buffering of Sets of hot keys (nil when disabled)
+	coalescer *writeCoalescer
+	// Optional log of every write, with diffs of Sets (nil when disabled)
+	auditor *writeAuditor
+	// Tracks node up/down events for readiness
+	hosts *hostTracker
+	// Optional following of hosts named by DNS or a Kubernetes service (nil for static hosts)
+	hostWatch *hostWatch
+	// Optional routing of each actor's operations to one host (nil when disabled)
+	actorPinner *actorPinner
+	// Optional sharing of reads between concurrent Gets of a key (nil when disabled)
+	getFlights *getDeduplicator
+	// Optional key count and size limits per app id or key prefix (nil when disabled)
+	quotas *quotaEnforcer
+	// Optional server-side timeouts derived from request deadlines (nil when disabled)
+	deadlines *deadlinePropagation
+	// Rate limits session refreshes after schema change errors
+	schemaRefresh schemaRefreshState
+	// Optional background copy of legacy text values into value_blob (nil when disabled)
+	blobMigration *blobMigrator
+	// Optional lease-based election for background jobs (nil when disabled)
+	leaderElection *leaderElection
+	// Rollout percentage per gradually enabled feature (nil when every feature applies to all keys)
+	rollout map[string]float64
+	// Values are encrypted by the Dapr sidecar before they reach the store
+	sidecarEncryption bool
+	// Operations retried after a node reported a statement as unprepared
+	unpreparedRetries atomic.Int64
+	// Value bytes one BulkGet response may hold (0 for no limit)
+	bulkGetMaxBytes int64
+	// Optional chunking of transactions over the batch size limit (nil when disabled)
+	txChunking *transactionChunking
+	// Key count above which BulkGet reads its batches in parallel; 0 disables
+	bulkGetParallelThreshold int
+	// Largest BulkGet response seen, in value bytes
+	bulkGetPeakBytes atomic.Int64
+	// Requests and shed requests per priority class
+	priorities priorityCounters
+	// Optional reload of rotated TLS certificates (nil when TLS is disabled)
+	tlsReloader *tlsReloader
+	// Writes that skipped their ETag checks with forceWrite
+	forcedWrites atomic.Int64
+	// Dialect used when the metadata sets none (empty for scylla)
+	defaultDialect string
+	// Optional periodic size estimates exported as gauges (nil when disabled)
+	statsGauges *statsGauges
+	// Optional masking of PII fields in read responses (nil when disabled)
+	masker *valueMasker
+	// Optional merge of ETag conflicts per key prefix (nil when disabled)
+	merger *conflictMerger
+	// Optional asynchronous mirror of writes into a secondary cluster (nil when disabled)
+	clusterMirror *clusterMirror
+	// Optional per-key serialization of local writes (nil when disabled)
+	keyLocks *keyLocks
+	// Session passed in by an embedding program (nil when the store connects)
+	injected *injectedSession
+	// Optional raw CQL queries for administrators (nil when disabled)
+	passthrough *queryPassthrough
+	// Optional per-period bucket tables (nil when disabled)
+	timeBuckets *timeBuckets
+	// Optional record of writes served to watches (nil when disabled)
+	changeFeed *changeFeed
+	// Optional reaction to backend overload signals (nil when disabled)
+	overload *overloadDetector
+	// Optional last known values served when reads fail (nil when disabled)
+	staleCache *staleCache
+	// Optional index of keys by group, for workflow state (nil when disabled)
+	keyGroups *keyGroups
+	// How numConns: auto sized the pool (nil unless autotuned)
+	autotune *poolAutotune
+	// Timestamps of Sets without ETag (nil unless writeTimestamps is request)
+	writeClock *writeClock
+	// Time and ETags of stored values (see UseClock)
+	clock clock.Clock
+	etags clock.ETags
+	// Progress of the last Init (nil before Init)
+	progress atomic.Pointer[initProgress]
+	// Version reported by the coordinator during Init (empty when unknown)
+	backendVersion string
 }
 
 // Compile time check to ensure ScyllaStateStore implements state.Store
@@ -58,22 +178,149 @@ var _ state.Querier = (*ScyllaStateStore)(nil)
 // Compile time check to ensure ScyllaStateStore implements state.BulkStore
 var _ state.BulkStore = (*ScyllaStateStore)(nil)
 
+// Compile time check to ensure ScyllaStateStore implements state.TransactionalStore
+var _ state.TransactionalStore = (*ScyllaStateStore)(nil)
+
+// Compile time check to ensure ScyllaStateStore implements stateext.DeleteWithPrefix
+var _ stateext.DeleteWithPrefix = (*ScyllaStateStore)(nil)
+
 // ScyllaConfig contains configuration for ScyllaDB connection
 type ScyllaConfig struct {
-	Hosts                    string `json:"hosts" mapstructure:"hosts"`                                       // Comma-separated list of ScyllaDB hosts
-	Port                     string `json:"port" mapstructure:"port"`                                         // Port for ScyllaDB (default: 9042)
-	Username                 string `json:"username" mapstructure:"username"`                                 // Username for authentication
-	Password                 string `json:"password" mapstructure:"password"`                                 // Password for authentication
-	Keyspace                 string `json:"keyspace" mapstructure:"keyspace"`                                 // Keyspace name (default: dapr_state)
-	Table                    string `json:"table" mapstructure:"table"`                                       // Table name (default: state)
-	Consistency              string `json:"consistency" mapstructure:"consistency"`                           // Consistency level (default: LOCAL_QUORUM)
-	ConnectionTimeout        string `json:"connectionTimeout" mapstructure:"connectionTimeout"`               // Connection timeout (default: 10s)
-	SocketKeepalive          string `json:"socketKeepalive" mapstructure:"socketKeepalive"`                   // Socket keepalive (default: 30s)
-	MaxReconnectInterval     string `json:"maxReconnectInterval" mapstructure:"maxReconnectInterval"`         // Max reconnect interval (default: 60s)
-	NumConns                 string `json:"numConns" mapstructure:"numConns"`                                 // Number of connections per host (default: 2)
-	DisableInitialHostLookup string `json:"disableInitialHostLookup" mapstructure:"disableInitialHostLookup"` // Disable initial host lookup (default: false)
-	ReplicationStrategy      string `json:"replicationStrategy" mapstructure:"replicationStrategy"`           // Replication strategy for keyspace creation
-	ReplicationFactor        string `json:"replicationFactor" mapstructure:"replicationFactor"`               // Replication factor (default: 3)
+	Hosts                     string `json:"hosts" mapstructure:"hosts"`                                         // Comma-separated list of ScyllaDB hosts, or srv:<name>, dns:<name>[:port] or k8s:[namespace/]<service>[:port]
+	HostDiscoveryInterval     string `json:"hostDiscoveryInterval" mapstructure:"hostDiscoveryInterval"`         // Interval between lookups of discovered hosts (default: 30s)
+	Port                      string `json:"port" mapstructure:"port"`                                           // Port for ScyllaDB (default: 9042)
+	Username                  string `json:"username" mapstructure:"username"`                                   // Username for authentication
+	Password                  string `json:"password" mapstructure:"password"`                                   // Password for authentication
+	Keyspace                  string `json:"keyspace" mapstructure:"keyspace"`                                   // Keyspace name (default: dapr_state)
+	Table                     string `json:"table" mapstructure:"table"`                                         // Table name (default: state)
+	Consistency               string `json:"consistency" mapstructure:"consistency"`                             // Consistency level (default: LOCAL_QUORUM)
+	ConnectionTimeout         string `json:"connectionTimeout" mapstructure:"connectionTimeout"`                 // Connection timeout (default: 10s)
+	SocketKeepalive           string `json:"socketKeepalive" mapstructure:"socketKeepalive"`                     // Socket keepalive (default: 30s)
+	MaxReconnectInterval      string `json:"maxReconnectInterval" mapstructure:"maxReconnectInterval"`           // Max reconnect interval (default: 60s)
+	NumConns                  string `json:"numConns" mapstructure:"numConns"`                                   // Number of connections per host, or auto to size from shards and CPU quota (default: 2)
+	DisableInitialHostLookup  string `json:"disableInitialHostLookup" mapstructure:"disableInitialHostLookup"`   // Disable initial host lookup (default: false)
+	ReplicationStrategy       string `json:"replicationStrategy" mapstructure:"replicationStrategy"`             // Replication strategy for keyspace creation
+	ReplicationFactor         string `json:"replicationFactor" mapstructure:"replicationFactor"`                 // Replication factor (default: 3)
+	CreateSchema              string `json:"createSchema" mapstructure:"createSchema"`                           // Create the keyspace and tables on Init; false only checks they exist (default: true)
+	BloomFilter               string `json:"bloomFilter" mapstructure:"bloomFilter"`                             // Enable client-side bloom filter for misses (default: false)
+	BloomFalsePositiveRate    string `json:"bloomFalsePositiveRate" mapstructure:"bloomFalsePositiveRate"`       // Target false-positive rate (default: 0.01)
+	BloomMaxMemory            string `json:"bloomMaxMemory" mapstructure:"bloomMaxMemory"`                       // Memory cap for the filter in bytes (default: 67108864)
+	BloomRebuildInterval      string `json:"bloomRebuildInterval" mapstructure:"bloomRebuildInterval"`           // Interval between full rebuilds (default: 1h)
+	BloomSingleWriter         string `json:"bloomSingleWriter" mapstructure:"bloomSingleWriter"`                 // Declares this instance the only writer of the table; bloomFilter requires it (default: false)
+	KeyStrategy               string `json:"keyStrategy" mapstructure:"keyStrategy"`                             // Partition key composition: passthrough, template or hash (default: passthrough)
+	KeyTemplate               string `json:"keyTemplate" mapstructure:"keyTemplate"`                             // Template for template/hash strategies (default: {appid}:{key})
+	AppID                     string `json:"appId" mapstructure:"appId"`                                         // Value substituted for {appid} in keyTemplate
+	SearchIndexURL            string `json:"searchIndexUrl" mapstructure:"searchIndexUrl"`                       // OpenSearch/Elasticsearch URL; enables write mirroring when set
+	SearchIndexName           string `json:"searchIndexName" mapstructure:"searchIndexName"`                     // Index name (default: dapr_state)
+	SearchIndexFields         string `json:"searchIndexFields" mapstructure:"searchIndexFields"`                 // Comma-separated JSON paths to index (default: whole value)
+	SearchIndexUsername       string `json:"searchIndexUsername" mapstructure:"searchIndexUsername"`             // Basic auth username for the search cluster
+	SearchIndexPassword       string `json:"searchIndexPassword" mapstructure:"searchIndexPassword"`             // Basic auth password for the search cluster
+	SearchIndexBatchSize      string `json:"searchIndexBatchSize" mapstructure:"searchIndexBatchSize"`           // Operations per _bulk request (default: 500)
+	SearchIndexFlushInterval  string `json:"searchIndexFlushInterval" mapstructure:"searchIndexFlushInterval"`   // Max time before buffered operations are flushed (default: 1s)
+	SearchIndexMaxRetries     string `json:"searchIndexMaxRetries" mapstructure:"searchIndexMaxRetries"`         // Retries per _bulk request (default: 3)
+	SearchIndexKeyPrefix      string `json:"searchIndexKeyPrefix" mapstructure:"searchIndexKeyPrefix"`           // Dapr key prefix full-text results are limited to (default: "<appId>||"); "none" disables the scoping
+	SearchIndexVerifyInterval string `json:"searchIndexVerifyInterval" mapstructure:"searchIndexVerifyInterval"` // Interval of sampled comparisons of search documents with stored values, e.g. "15m"; disabled when empty
+	SearchIndexVerifySample   string `json:"searchIndexVerifySample" mapstructure:"searchIndexVerifySample"`     // Keys and documents compared per round (default: 1000)
+	SearchIndexVerifyRepair   string `json:"searchIndexVerifyRepair" mapstructure:"searchIndexVerifyRepair"`     // Reindex or delete documents that differ (default: false)
+	BulkConcurrency           string `json:"bulkConcurrency" mapstructure:"bulkConcurrency"`                     // Partitions written in parallel by bulk operations (default: 16)
+	BulkGetMaxBytes           string `json:"bulkGetMaxBytes" mapstructure:"bulkGetMaxBytes"`                     // Value bytes one BulkGet response may hold; 0 disables the limit (default: 0)
+	BulkGetParallelThreshold  string `json:"bulkGetParallelThreshold" mapstructure:"bulkGetParallelThreshold"`   // Keys above which BulkGet reads batches grouped by replica in parallel; 0 disables (default: 1000)
+	Compression               string `json:"compression" mapstructure:"compression"`                             // Transport compression: snappy, none or a registered codec such as lz4 (default: snappy)
+	MaxRetries                string `json:"maxRetries" mapstructure:"maxRetries"`                               // Max attempts for transient errors (default: 3)
+	RetryBaseDelay            string `json:"retryBaseDelay" mapstructure:"retryBaseDelay"`                       // Delay before the first retry, doubled per attempt (default: 100ms)
+	RetryMaxDelay             string `json:"retryMaxDelay" mapstructure:"retryMaxDelay"`                         // Upper bound for retry backoff (default: 10s)
+	SpeculativeDelay          string `json:"speculativeDelay" mapstructure:"speculativeDelay"`                   // Delay before an idempotent statement is sent to another host; disabled when empty
+	SpeculativeAttempts       string `json:"speculativeAttempts" mapstructure:"speculativeAttempts"`             // Additional executions per statement (default: 1)
+	VerifyWrites              string `json:"verifyWrites" mapstructure:"verifyWrites"`                           // Re-read written keys and log mismatches, for debugging (default: false)
+	VerifyWritesSamplePercent string `json:"verifyWritesSamplePercent" mapstructure:"verifyWritesSamplePercent"` // Percentage of writes verified (default: 100)
+	DryRun                    string `json:"dryRun" mapstructure:"dryRun"`                                       // Log Delete/BulkDelete/Multi mutations without executing them (default: false)
+	LatencySLO                string `json:"latencySlo" mapstructure:"latencySlo"`                               // p99 targets per operation, e.g. get=20ms,set=50ms; enables SLO tracking when set
+	LatencySLOWindow          string `json:"latencySloWindow" mapstructure:"latencySloWindow"`                   // Rolling window for the p99 (default: 1m)
+	LatencySLOShedQueries     string `json:"latencySloShedQueries" mapstructure:"latencySloShedQueries"`         // Reject Query requests while an SLO is breached (default: false)
+	LatencySLOShedPriority    string `json:"latencySloShedPriority" mapstructure:"latencySloShedPriority"`       // Highest priority class rejected while an SLO is breached: bulk or normal (default: none)
+	QueryCacheTTL             string `json:"queryCacheTtl" mapstructure:"queryCacheTtl"`                         // Cache Query results for this long, e.g. 2s; disabled when empty
+	QueryCacheMaxEntries      string `json:"queryCacheMaxEntries" mapstructure:"queryCacheMaxEntries"`           // Maximum number of cached queries (default: 100)
+	UsageMetering             string `json:"usageMetering" mapstructure:"usageMetering"`                         // Account operations and bytes per app id and key prefix (default: false)
+	UsagePrefixDelimiter      string `json:"usagePrefixDelimiter" mapstructure:"usagePrefixDelimiter"`           // Delimiter ending the metered key prefix (default: ":")
+	UsageReportInterval       string `json:"usageReportInterval" mapstructure:"usageReportInterval"`             // Interval between usage reports in the log (default: 5m)
+	HotKeys                   string `json:"hotKeys" mapstructure:"hotKeys"`                                     // Track the most frequently accessed keys (default: false)
+	HotKeysTopK               string `json:"hotKeysTopK" mapstructure:"hotKeysTopK"`                             // Number of hottest keys reported (default: 20)
+	HotKeysWindow             string `json:"hotKeysWindow" mapstructure:"hotKeysWindow"`                         // Interval after which access counts are halved (default: 1m)
+	WriteCoalescing           string `json:"writeCoalescing" mapstructure:"writeCoalescing"`                     // Buffer Sets of hot keys per prefix, e.g. "actor||counter=100ms"; disabled when empty
+	WriteCoalescingMinCount   string `json:"writeCoalescingMinCount" mapstructure:"writeCoalescingMinCount"`     // Hot key count from which Sets of a key are coalesced (default: 100)
+	WriteAudit                string `json:"writeAudit" mapstructure:"writeAudit"`                               // Log every Set and Delete with the AUDIT: prefix (default: false)
+	WriteAuditDiff            string `json:"writeAuditDiff" mapstructure:"writeAuditDiff"`                       // Also log what each Set changed, read before the write (default: false)
+	WriteAuditDiffMaxBytes    string `json:"writeAuditDiffMaxBytes" mapstructure:"writeAuditDiffMaxBytes"`       // Size the diff of one Set is cut to (default: 4096)
+	BlobMigration             string `json:"blobMigration" mapstructure:"blobMigration"`                         // Copy legacy text values into value_blob in the background (default: false)
+	BlobMigrationRate         string `json:"blobMigrationRate" mapstructure:"blobMigrationRate"`                 // Rows migrated per second (default: 1000)
+	StrictValues              string `json:"strictValues" mapstructure:"strictValues"`                           // Reject values that are not strings, bytes or JSON payloads (default: false)
+	LeaderElection            string `json:"leaderElection" mapstructure:"leaderElection"`                       // Run cluster-wide background jobs on one replica only (default: false)
+	LeaseDuration             string `json:"leaseDuration" mapstructure:"leaseDuration"`                         // Lease expiry for leader election (default: 30s)
+	FeatureRollout            string `json:"featureRollout" mapstructure:"featureRollout"`                       // Share of keys per feature, e.g. "strictValues=10,queryCache=50" (default: all keys)
+	SchemaSampling            string `json:"schemaSampling" mapstructure:"schemaSampling"`                       // Record field names and types of sampled values per key prefix (default: false)
+	SchemaSamplePercent       string `json:"schemaSamplePercent" mapstructure:"schemaSamplePercent"`             // Percentage of writes sampled for the observed schema (default: 1)
+	WorkloadSampling          string `json:"workloadSampling" mapstructure:"workloadSampling"`                   // Record operation mix and key/value size histograms (default: false)
+	WorkloadSampleFile        string `json:"workloadSampleFile" mapstructure:"workloadSampleFile"`               // File the samples are appended to (default: /tmp/scylladb-workload.jsonl)
+	WorkloadSampleInterval    string `json:"workloadSampleInterval" mapstructure:"workloadSampleInterval"`       // Interval covered by each sample (default: 5m)
+	TLS                       string `json:"tls" mapstructure:"tls"`                                             // Connect over TLS; implied by tlsCaFile or tlsCertFile (default: false)
+	TLSCAFile                 string `json:"tlsCaFile" mapstructure:"tlsCaFile"`                                 // PEM file with the CA certificates trusted for server certificates
+	TLSCertFile               string `json:"tlsCertFile" mapstructure:"tlsCertFile"`                             // PEM client certificate for mutual TLS
+	TLSKeyFile                string `json:"tlsKeyFile" mapstructure:"tlsKeyFile"`                               // PEM private key of the client certificate
+	TLSServerName             string `json:"tlsServerName" mapstructure:"tlsServerName"`                         // Name verified in server certificates (default: the host name)
+	TLSHostVerification       string `json:"tlsHostVerification" mapstructure:"tlsHostVerification"`             // Verify server certificates and host names (default: true)
+	TLSReloadInterval         string `json:"tlsReloadInterval" mapstructure:"tlsReloadInterval"`                 // How often TLS files are checked for rotation; 0 disables reloading (default: 1m)
+	AdminToken                string `json:"adminToken" mapstructure:"adminToken"`                               // Token required by provisioning, passthrough, deletePrefix and refreshSchema queries and forceWrite; all are disabled when empty
+	ActorPinning              string `json:"actorPinning" mapstructure:"actorPinning"`                           // Route each actor's operations to one host: none, ring or rendezvous (default: none)
+	GetDeduplication          string `json:"getDeduplication" mapstructure:"getDeduplication"`                   // Share one backend read between concurrent Gets of the same key (default: false)
+	Quotas                    string `json:"quotas" mapstructure:"quotas"`                                       // Limits per bucket, e.g. "*=100000:1073741824,orders/order=1000:0"; disabled when empty
+	QuotaScanInterval         string `json:"quotaScanInterval" mapstructure:"quotaScanInterval"`                 // Interval between usage scans of the table (default: 5m)
+	DeadlinePropagation       string `json:"deadlinePropagation" mapstructure:"deadlinePropagation"`             // Bound server-side timeouts of Get/Set/Delete by the request deadline (default: false)
+	DeadlineMargin            string `json:"deadlineMargin" mapstructure:"deadlineMargin"`                       // Time reserved for the network and driver (default: 10ms)
+	QueryScan                 string `json:"queryScan" mapstructure:"queryScan"`                                 // Answer Query requests without full-text metadata by listing the first rows (default: false)
+	TTLMetadata               string `json:"ttlMetadata" mapstructure:"ttlMetadata"`                             // Return the expiry and write time of rows with Gets and scanned Query results (default: false)
+	Dialect                   string `json:"dialect" mapstructure:"dialect"`                                     // CQL database: scylla or cassandra (default: scylla, cassandra for cassandra-state)
+	StatsInterval             string `json:"statsInterval" mapstructure:"statsInterval"`                         // Interval between size estimate samples exported as gauges, e.g. "5m"; disabled when empty
+	MaskPaths                 string `json:"maskPaths" mapstructure:"maskPaths"`                                 // JSON fields masked on reads, e.g. "email=hash,card.number=redact"; disabled when empty
+	MaskAppIDs                string `json:"maskAppIds" mapstructure:"maskAppIds"`                               // Consumer app ids whose reads are masked, e.g. "analytics,reporting"; "*" for all
+	MaskHashKey               string `json:"maskHashKey" mapstructure:"maskHashKey"`                             // Key for HMAC-SHA256 hashing of masked fields (default: plain SHA-256)
+	ConflictMerge             string `json:"conflictMerge" mapstructure:"conflictMerge"`                         // Strategies merging Sets with stale ETags, e.g. "counter-=max,cart-=union"; disabled when empty
+	MirrorHosts               string `json:"mirrorHosts" mapstructure:"mirrorHosts"`                             // Comma-separated hosts of a secondary cluster receiving every write; disabled when empty
+	MirrorPort                string `json:"mirrorPort" mapstructure:"mirrorPort"`                               // Port of the mirror hosts (default: port)
+	MirrorKeyspace            string `json:"mirrorKeyspace" mapstructure:"mirrorKeyspace"`                       // Keyspace written on the secondary cluster (default: keyspace)
+	MirrorConsistency         string `json:"mirrorConsistency" mapstructure:"mirrorConsistency"`                 // Consistency of mirrored writes (default: LOCAL_ONE)
+	MirrorQueueSize           string `json:"mirrorQueueSize" mapstructure:"mirrorQueueSize"`                     // Writes buffered for the secondary before new ones are dropped (default: 10000)
+	MirrorWorkers             string `json:"mirrorWorkers" mapstructure:"mirrorWorkers"`                         // Concurrent writers to the secondary cluster (default: 4)
+	MirrorReconcileInterval   string `json:"mirrorReconcileInterval" mapstructure:"mirrorReconcileInterval"`     // Interval of sampled comparisons with the mirror cluster, e.g. "10m"; disabled when empty
+	MirrorReconcileSample     string `json:"mirrorReconcileSample" mapstructure:"mirrorReconcileSample"`         // Keys of each cluster compared per round (default: 1000)
+	MirrorReconcileRepair     string `json:"mirrorReconcileRepair" mapstructure:"mirrorReconcileRepair"`         // Rewrite keys that differ on the mirror from the primary (default: false)
+	MirrorShadowReads         string `json:"mirrorShadowReads" mapstructure:"mirrorShadowReads"`                 // Percentage of Gets read again from the mirror and compared (default: 0)
+	WriteTimestamps           string `json:"writeTimestamps" mapstructure:"writeTimestamps"`                     // Timestamps of Sets without ETag: request, driver or server (default: request)
+	WriteTimestampMaxSkew     string `json:"writeTimestampMaxSkew" mapstructure:"writeTimestampMaxSkew"`         // Clock skew with the coordinator that fails Init with request timestamps; 0 disables the check (default: 1s)
+	KeyLocking                string `json:"keyLocking" mapstructure:"keyLocking"`                               // Serialize concurrent Sets and Deletes of the same key within the replica (default: false)
+	KeyLockStripes            string `json:"keyLockStripes" mapstructure:"keyLockStripes"`                       // Number of locks keys are hashed onto (default: 1024)
+	QueryPassthrough          string `json:"queryPassthrough" mapstructure:"queryPassthrough"`                   // Execute raw CQL from Query requests carrying cql metadata and the adminToken (default: false)
+	QueryPassthroughAllow     string `json:"queryPassthroughAllow" mapstructure:"queryPassthroughAllow"`         // Statement kinds passthrough may execute: SELECT, INSERT, UPDATE, DELETE (default: SELECT)
+	QueryPassthroughMaxRows   string `json:"queryPassthroughMaxRows" mapstructure:"queryPassthroughMaxRows"`     // Rows returned by one passthrough query (default: 1000)
+	TimeBuckets               string `json:"timeBuckets" mapstructure:"timeBuckets"`                             // Write keys into daily or weekly bucket tables dropped as a whole (default: disabled)
+	TimeBucketRetention       string `json:"timeBucketRetention" mapstructure:"timeBucketRetention"`             // Buckets kept, the current one included (default: 7)
+	ChangeFeed                string `json:"changeFeed" mapstructure:"changeFeed"`                               // Record writes in <table>_changes so keys can be watched (default: false)
+	ChangeFeedRetention       string `json:"changeFeedRetention" mapstructure:"changeFeedRetention"`             // How long recorded changes can be resumed from (default: 1h)
+	WatchPollInterval         string `json:"watchPollInterval" mapstructure:"watchPollInterval"`                 // Interval at which waiting watches read the change feed (default: 500ms)
+	WatchMaxWait              string `json:"watchMaxWait" mapstructure:"watchMaxWait"`                           // Longest time one watch waits for a change (default: 30s)
+	OverloadShedding          string `json:"overloadShedding" mapstructure:"overloadShedding"`                   // Reduce bulk work, then shed bulk requests, on overload errors and timeouts (default: false)
+	OverloadThreshold         string `json:"overloadThreshold" mapstructure:"overloadThreshold"`                 // Overload signals within overloadWindow that raise the load level (default: 20)
+	OverloadWindow            string `json:"overloadWindow" mapstructure:"overloadWindow"`                       // Window overload signals are counted in (default: 10s)
+	OverloadRecovery          string `json:"overloadRecovery" mapstructure:"overloadRecovery"`                   // Time without signals before the load level is lowered by one (default: 30s)
+	StaleIfError              string `json:"staleIfError" mapstructure:"staleIfError"`                           // Serve cached values up to this old when reads fail, e.g. 5m; disabled when empty
+	StaleCacheMaxEntries      string `json:"staleCacheMaxEntries" mapstructure:"staleCacheMaxEntries"`           // Maximum number of keys kept for stale reads (default: 10000)
+	KeyGroups                 string `json:"keyGroups" mapstructure:"keyGroups"`                                 // Index keys by the part before the last keyGroupDelimiter in <table>_key_groups (default: false)
+	KeyGroupDelimiter         string `json:"keyGroupDelimiter" mapstructure:"keyGroupDelimiter"`                 // Delimiter ending a key's group (default: ||)
+	DevMode                   string `json:"devMode" mapstructure:"devMode"`                                     // Development instance: allows loading fixtures on Init (default: false)
+	Fixtures                  string `json:"fixtures" mapstructure:"fixtures"`                                   // Comma-separated fixture files or directories written on Init in devMode
+	TransactionChunkBytes     string `json:"transactionChunkBytes" mapstructure:"transactionChunkBytes"`         // Mutation bytes above which a transaction is applied in several batches; 0 disables (default: 0)
+	TransactionCompensation   string `json:"transactionCompensation" mapstructure:"transactionCompensation"`     // What a failed chunk does to the applied ones: rollback or report (default: rollback)
+	TestClock                 string `json:"testClock" mapstructure:"testClock"`                                 // Hidden: RFC 3339 time the store's clock starts at, for reproducible staging runs
+	TestETags                 string `json:"testETags" mapstructure:"testETags"`                                 // Hidden: ETag generator, time or sequence[:n] (default: time)
 }
 
 // NewScyllaStateStore creates a new instance of ScyllaStateStore.
@@ -87,8 +334,10 @@ func NewScyllaStateStore(inputLogger logger.Logger) state.Store {
 	}
 }
 
-func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata) error {
+// initialize parses the configuration, connects and prepares the schema.
+func (store *ScyllaStateStore) initialize(ctx context.Context, metadata state.Metadata) error {
 	store.logger.Info("Initializing ScyllaStateStore...")
+	store.initPhase(initPhaseConfiguring)
 
 	// Check for context cancellation
 	select {
@@ -97,17 +346,35 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 	default:
 	}
 
+	if err := store.configure(metadata); err != nil {
+		return err
+	}
+	return store.connect(ctx, metadata)
+}
+
+// configure parses the configuration from metadata and applies the defaults,
+// without connecting.
+func (store *ScyllaStateStore) configure(metadata state.Metadata) error {
 	// Parse configuration from metadata
 	configBytes, _ := json.Marshal(metadata.Properties)
 	if err := json.Unmarshal(configBytes, &store.config); err != nil {
 		store.logger.Errorf("Failed to parse config: %v", err)
 		return fmt.Errorf("failed to parse configuration: %w", err)
 	}
+	if err := checkCompressionLevel(metadata.Properties); err != nil {
+		return err
+	}
+
+	// The dialect adjusts some of the defaults below
+	store.resolveDialect()
 
 	// Set defaults
 	if store.config.Hosts == "" {
 		store.config.Hosts = "localhost"
 	}
+	if store.config.HostDiscoveryInterval == "" {
+		store.config.HostDiscoveryInterval = "30s"
+	}
 	if store.config.Port == "" {
 		store.config.Port = "9042"
 	}
@@ -138,20 +405,254 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 	if store.config.ReplicationFactor == "" {
 		store.config.ReplicationFactor = "3"
 	}
+	if store.config.CreateSchema == "" {
+		store.config.CreateSchema = "true"
+	}
+	if store.config.BloomFalsePositiveRate == "" {
+		store.config.BloomFalsePositiveRate = "0.01"
+	}
+	if store.config.BloomMaxMemory == "" {
+		store.config.BloomMaxMemory = "67108864"
+	}
+	if store.config.BloomRebuildInterval == "" {
+		store.config.BloomRebuildInterval = "1h"
+	}
+	if store.config.KeyStrategy == "" {
+		store.config.KeyStrategy = keyStrategyPassthrough
+	}
+	if store.config.KeyTemplate == "" {
+		store.config.KeyTemplate = "{appid}:{key}"
+	}
+	if store.config.WriteTimestamps == "" {
+		store.config.WriteTimestamps = writeTimestampsRequest
+	}
+	if store.config.WriteTimestampMaxSkew == "" {
+		store.config.WriteTimestampMaxSkew = "1s"
+	}
+	if store.config.KeyLocking == "" {
+		store.config.KeyLocking = "false"
+	}
+	if store.config.KeyLockStripes == "" {
+		store.config.KeyLockStripes = "1024"
+	}
+	if store.config.QueryPassthrough == "" {
+		store.config.QueryPassthrough = "false"
+	}
+	if store.config.QueryPassthroughAllow == "" {
+		store.config.QueryPassthroughAllow = "SELECT"
+	}
+	if store.config.QueryPassthroughMaxRows == "" {
+		store.config.QueryPassthroughMaxRows = "1000"
+	}
+	if store.config.TimeBucketRetention == "" {
+		store.config.TimeBucketRetention = "7"
+	}
+	if store.config.ChangeFeedRetention == "" {
+		store.config.ChangeFeedRetention = "1h"
+	}
+	if store.config.WatchPollInterval == "" {
+		store.config.WatchPollInterval = "500ms"
+	}
+	if store.config.WatchMaxWait == "" {
+		store.config.WatchMaxWait = "30s"
+	}
+	if store.config.OverloadThreshold == "" {
+		store.config.OverloadThreshold = "20"
+	}
+	if store.config.OverloadWindow == "" {
+		store.config.OverloadWindow = "10s"
+	}
+	if store.config.OverloadRecovery == "" {
+		store.config.OverloadRecovery = "30s"
+	}
+	if store.config.StaleCacheMaxEntries == "" {
+		store.config.StaleCacheMaxEntries = "10000"
+	}
+	if store.config.MirrorReconcileSample == "" {
+		store.config.MirrorReconcileSample = "1000"
+	}
+	if store.config.MirrorShadowReads == "" {
+		store.config.MirrorShadowReads = "0"
+	}
+	if store.config.KeyGroupDelimiter == "" {
+		store.config.KeyGroupDelimiter = "||"
+	}
+	if store.config.SearchIndexName == "" {
+		store.config.SearchIndexName = "dapr_state"
+	}
+	if store.config.SearchIndexBatchSize == "" {
+		store.config.SearchIndexBatchSize = "500"
+	}
+	if store.config.SearchIndexFlushInterval == "" {
+		store.config.SearchIndexFlushInterval = "1s"
+	}
+	if store.config.SearchIndexMaxRetries == "" {
+		store.config.SearchIndexMaxRetries = "3"
+	}
+	if store.config.SearchIndexVerifySample == "" {
+		store.config.SearchIndexVerifySample = "1000"
+	}
+	if store.config.BulkGetMaxBytes == "" {
+		store.config.BulkGetMaxBytes = "0"
+	}
+	if store.config.BulkGetParallelThreshold == "" {
+		store.config.BulkGetParallelThreshold = "1000"
+	}
+	if store.config.BulkConcurrency == "" {
+		store.config.BulkConcurrency = "16"
+	}
+	if store.config.Compression == "" {
+		store.config.Compression = "snappy"
+	}
+	if store.config.MaxRetries == "" {
+		store.config.MaxRetries = "3"
+	}
+	if store.config.RetryBaseDelay == "" {
+		store.config.RetryBaseDelay = "100ms"
+	}
+	if store.config.RetryMaxDelay == "" {
+		store.config.RetryMaxDelay = "10s"
+	}
+	if store.config.SpeculativeAttempts == "" {
+		store.config.SpeculativeAttempts = "1"
+	}
+	if store.config.VerifyWritesSamplePercent == "" {
+		store.config.VerifyWritesSamplePercent = "100"
+	}
+	if store.config.LatencySLOWindow == "" {
+		store.config.LatencySLOWindow = "1m"
+	}
+	if store.config.QueryCacheMaxEntries == "" {
+		store.config.QueryCacheMaxEntries = "100"
+	}
+	if store.config.UsagePrefixDelimiter == "" {
+		store.config.UsagePrefixDelimiter = ":"
+	}
+	if store.config.UsageReportInterval == "" {
+		store.config.UsageReportInterval = "5m"
+	}
+	if store.config.BlobMigrationRate == "" {
+		store.config.BlobMigrationRate = "1000"
+	}
+	if store.config.StrictValues == "" {
+		store.config.StrictValues = "false"
+	}
+	if store.config.LeaderElection == "" {
+		store.config.LeaderElection = "false"
+	}
+	if store.config.SchemaSampling == "" {
+		store.config.SchemaSampling = "false"
+	}
+	if store.config.SchemaSamplePercent == "" {
+		store.config.SchemaSamplePercent = "1"
+	}
+	if store.config.WorkloadSampleFile == "" {
+		store.config.WorkloadSampleFile = "/tmp/scylladb-workload.jsonl"
+	}
+	if store.config.WorkloadSampleInterval == "" {
+		store.config.WorkloadSampleInterval = "5m"
+	}
+	if store.config.HotKeysTopK == "" {
+		store.config.HotKeysTopK = "20"
+	}
+	if store.config.HotKeysWindow == "" {
+		store.config.HotKeysWindow = "1m"
+	}
+	if store.config.WriteCoalescingMinCount == "" {
+		store.config.WriteCoalescingMinCount = "100"
+	}
+	if store.config.WriteAudit == "" {
+		store.config.WriteAudit = "false"
+	}
+	if store.config.WriteAuditDiffMaxBytes == "" {
+		store.config.WriteAuditDiffMaxBytes = "4096"
+	}
+	if store.config.DevMode == "" {
+		store.config.DevMode = "false"
+	}
+	if store.config.TransactionChunkBytes == "" {
+		store.config.TransactionChunkBytes = "0"
+	}
+	if store.config.TransactionCompensation == "" {
+		store.config.TransactionCompensation = compensationRollback
+	}
+	if store.config.GetDeduplication == "" {
+		store.config.GetDeduplication = "false"
+	}
+	if store.config.QueryScan == "" {
+		store.config.QueryScan = "false"
+	}
+	if store.config.TTLMetadata == "" {
+		store.config.TTLMetadata = "false"
+	}
+	if store.config.DeadlinePropagation == "" {
+		store.config.DeadlinePropagation = "false"
+	}
+	if store.config.DeadlineMargin == "" {
+		store.config.DeadlineMargin = "10ms"
+	}
+	if store.config.QuotaScanInterval == "" {
+		store.config.QuotaScanInterval = "5m"
+	}
+	if store.config.ActorPinning == "" {
+		store.config.ActorPinning = actorPinningNone
+	}
+	if store.config.TLSHostVerification == "" {
+		store.config.TLSHostVerification = "true"
+	}
+	if store.config.TLSReloadInterval == "" {
+		store.config.TLSReloadInterval = "1m"
+	}
+	if store.config.LeaseDuration == "" {
+		store.config.LeaseDuration = "30s"
+	}
 
 	store.logger.Infof("Parsed ScyllaDB config: hosts=%s, port=%s, keyspace=%s, table=%s",
 		store.config.Hosts, store.config.Port, store.config.Keyspace, store.config.Table)
 
-	// Parse hosts
-	hosts := strings.Split(store.config.Hosts, ",")
-	for i := range hosts {
-		hosts[i] = strings.TrimSpace(hosts[i])
-		// Add port if not specified
-		if !strings.Contains(hosts[i], ":") {
-			hosts[i] = fmt.Sprintf("%s:%s", hosts[i], store.config.Port)
+	// Clock and ETag generator of stored values
+	store.initClock()
+
+	// Resolve how Dapr keys map to stored partition keys
+	keys, err := newKeyMapper(store.config.KeyStrategy, store.config.KeyTemplate, store.config.AppID)
+	if err != nil {
+		return fmt.Errorf("invalid key strategy configuration: %w", err)
+	}
+	store.keys = keys
+	store.logger.Infof("Using key strategy: %s", keys.strategy)
+
+	// Masking of PII fields for designated consumer apps
+	if store.config.MaskPaths != "" {
+		if err := store.initMasking(); err != nil {
+			return fmt.Errorf("invalid masking configuration: %w", err)
 		}
 	}
 
+	// Per-period bucket tables for ephemeral keys
+	if store.config.TimeBuckets != "" {
+		if err := store.initTimeBuckets(); err != nil {
+			return fmt.Errorf("invalid time bucket configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// connect resolves the hosts, opens the session on the prepared schema and
+// starts the optional features.
+func (store *ScyllaStateStore) connect(ctx context.Context, metadata state.Metadata) error {
+	// Resolve hosts, which may also name a DNS SRV record or a Kubernetes service
+	resolver, err := discovery.Parse(store.config.Hosts, store.config.Port)
+	if err != nil {
+		return fmt.Errorf("invalid hosts: %w", err)
+	}
+	hosts, err := resolver.Resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to resolve hosts %s: %w", store.config.Hosts, err)
+	}
+	if !discovery.IsStatic(resolver) {
+		store.logger.Infof("Resolved %s to %v", resolver, hosts)
+	}
+
 	// Create cluster configuration
 	cluster := gocql.NewCluster(hosts...)
 
@@ -173,6 +674,11 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 		cluster.Timeout = 11 * time.Second // Query timeout higher than connection timeout
 	}
 
+	// Server-side timeouts follow the caller's deadline; the driver timeout caps them
+	if store.config.DeadlinePropagation == "true" {
+		store.initDeadlinePropagation(cluster.Timeout)
+	}
+
 	if keepalive, err := time.ParseDuration(store.config.SocketKeepalive); err == nil {
 		cluster.SocketKeepalive = keepalive
 	} else {
@@ -214,7 +720,7 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 	cluster.Consistency = consistency
 
 	// Set number of connections per host (ScyllaDB best practice: match shard count)
-	if numConns := store.config.NumConns; numConns != "" {
+	if numConns := store.config.NumConns; numConns != "" && numConns != numConnsAuto {
 		if n, err := strconv.Atoi(numConns); err == nil && n > 0 {
 			cluster.NumConns = n
 			store.logger.Infof("Setting NumConns to %d (should match ScyllaDB shard count)", n)
@@ -223,6 +729,79 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 		}
 	}
 
+	// Application-level retry policy shared by all operations
+	store.retry = defaultRetryPolicy
+	if n, err := strconv.Atoi(store.config.MaxRetries); err == nil && n > 0 {
+		store.retry.maxAttempts = n
+	} else {
+		store.logger.Warnf("Invalid maxRetries: %s, using default", store.config.MaxRetries)
+	}
+	if delay, err := time.ParseDuration(store.config.RetryBaseDelay); err == nil && delay > 0 {
+		store.retry.baseDelay = delay
+	} else {
+		store.logger.Warnf("Invalid retryBaseDelay: %s, using default", store.config.RetryBaseDelay)
+	}
+	if delay, err := time.ParseDuration(store.config.RetryMaxDelay); err == nil && delay > 0 {
+		store.retry.maxDelay = delay
+	} else {
+		store.logger.Warnf("Invalid retryMaxDelay: %s, using default", store.config.RetryMaxDelay)
+	}
+
+	// Speculative executions of idempotent statements, off unless a delay is set
+	store.speculative = gocql.NonSpeculativeExecution{}
+	if store.config.SpeculativeDelay != "" {
+		delay, err := time.ParseDuration(store.config.SpeculativeDelay)
+		attempts, attemptsErr := strconv.Atoi(store.config.SpeculativeAttempts)
+		switch {
+		case err != nil || delay <= 0:
+			store.logger.Warnf("Invalid speculativeDelay: %s, disabling speculative execution", store.config.SpeculativeDelay)
+		case attemptsErr != nil || attempts <= 0:
+			store.logger.Warnf("Invalid speculativeAttempts: %s, disabling speculative execution", store.config.SpeculativeAttempts)
+		default:
+			store.speculative = &gocql.SimpleSpeculativeExecution{NumAttempts: attempts, TimeoutDelay: delay}
+			store.logger.Infof("Speculative execution enabled for idempotent statements (attempts=%d, delay=%v)", attempts, delay)
+		}
+	}
+
+	// Dry-run mode only logs destructive operations
+	store.dryRun = store.config.DryRun == "true"
+	if store.dryRun {
+		store.logger.Warn("Dry-run mode enabled: Delete, BulkDelete and Multi will not modify state")
+	}
+
+	// Sidecar encryption disables features that cannot work on ciphertext
+	store.initSidecarEncryption(metadata.Properties)
+
+	// Gradual rollout applies to the features below, so parse it first
+	store.initFeatureRollout()
+
+	// Strict mode rejects values that would otherwise be marshaled to JSON
+	store.strictValues = store.config.StrictValues == "true"
+	if store.strictValues {
+		store.logger.Info("Strict value mode enabled: only strings, bytes and JSON payloads are accepted")
+	}
+
+	// Bulk writes run one batch per partition; bound how many execute at once
+	if n, err := strconv.Atoi(store.config.BulkConcurrency); err == nil && n > 0 {
+		store.bulkConcurrency = n
+	} else {
+		store.logger.Warnf("Invalid bulkConcurrency: %s, using default", store.config.BulkConcurrency)
+		store.bulkConcurrency = 16
+	}
+
+	// Bound the value bytes a single BulkGet response may buffer
+	if n, err := strconv.ParseInt(store.config.BulkGetMaxBytes, 10, 64); err == nil && n >= 0 {
+		store.bulkGetMaxBytes = n
+	} else {
+		store.logger.Warnf("Invalid bulkGetMaxBytes: %s, using default", store.config.BulkGetMaxBytes)
+	}
+
+	// Large BulkGets read their batches in parallel
+	store.parseBulkGetParallelThreshold()
+
+	// Transactions over the batch size limit are split into chunks
+	store.initTransactionChunking()
+
 	// Disable initial host lookup if configured
 	if store.config.DisableInitialHostLookup == "true" {
 		cluster.DisableInitialHostLookup = true
@@ -241,11 +820,31 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 		NumRetries: 3,
 	}
 
-	// Enable Snappy compression for better performance (ScyllaDB best practice)
-	cluster.Compressor = &gocql.SnappyCompressor{}
+	// Transport compression (Snappy by default, ScyllaDB best practice)
+	compressor, err := newCompressor(store.config.Compression)
+	if err != nil {
+		return fmt.Errorf("invalid compression: %w", err)
+	}
+	cluster.Compressor = compressor
+	if compressor == nil {
+		store.logger.Info("Transport compression disabled")
+	} else {
+		store.logger.Infof("Using %s transport compression", compressor.Name())
+	}
 
-	// Token-aware host policy with round-robin fallback (benchmark best practice)
-	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	// Token-aware host policy with round-robin fallback (benchmark best practice),
+	// wrapped to follow node up/down events for readiness
+	store.hosts = newHostTracker(store.logger)
+	switch store.config.ActorPinning {
+	case actorPinningNone:
+	case actorPinningRing, actorPinningRendezvous:
+		store.actorPinner = newActorPinner(store.config.ActorPinning)
+		store.logger.Infof("Pinning actor operations to hosts (%s)", store.config.ActorPinning)
+	default:
+		store.logger.Warnf("Invalid actorPinning: %s, using default", store.config.ActorPinning)
+	}
+	cluster.PoolConfig.HostSelectionPolicy = store.hostSelectionPolicy()
+	cluster.ConnectObserver = store.hosts
 
 	// Additional ScyllaDB optimizations based on repository examples
 	cluster.WriteCoalesceWaitTime = 200 * time.Microsecond // Improves throughput by batching writes
@@ -253,6 +852,12 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 	cluster.DefaultTimestamp = true
 	cluster.DisableSkipMetadata = false
 
+	// Retried Sets must not reorder writes of a key
+	store.initWriteTimestamps(cluster)
+
+	// Cassandra has neither shard-aware ports nor shard-per-core connections
+	store.applyDialect(cluster)
+
 	// Connection pool optimizations for ScyllaDB's shard-per-core architecture
 	cluster.MaxPreparedStmts = 1000
 	cluster.MaxRoutingKeyInfo = 1000
@@ -262,6 +867,16 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 	cluster.Events.DisableTopologyEvents = false   // Keep enabled for cluster changes
 	cluster.Events.DisableSchemaEvents = true      // Disable for performance (we don't alter schema)
 
+	// Optional TLS, including client certificates for mutual TLS
+	if err := store.initTLS(cluster); err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	// Size the pool from the backend's shards and the CPU quota, over TLS when enabled
+	if store.config.NumConns == numConnsAuto {
+		store.autotunePool(cluster, hosts)
+	}
+
 	store.cluster = cluster
 	store.logger.Info("ScyllaDB cluster configuration created successfully")
 
@@ -269,6 +884,149 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 	if err := store.createSessionAndInitialize(); err != nil {
 		return fmt.Errorf("failed to initialize ScyllaDB: %w", err)
 	}
+	store.detectBackendVersion(ctx)
+
+	if !discovery.IsStatic(resolver) {
+		store.watchHosts(resolver, hosts)
+	}
+
+	store.initPhase(initPhaseCheckingClock)
+	if err := store.checkClockSkew(ctx); err != nil {
+		store.closeSession(store.session)
+		store.session = nil
+		return err
+	}
+
+	store.initPhase(initPhaseStartingWorkers)
+
+	// Rotated certificates are applied by rebuilding the session
+	if store.tlsReloader != nil && store.injected == nil {
+		store.startTLSReload()
+	}
+
+	// Start the bloom filter after the session exists so the first build can scan keys
+	if store.config.BloomFilter == "true" {
+		store.initBloomFilter()
+	}
+
+	if store.config.SearchIndexURL != "" {
+		store.initSearchIndex()
+	}
+
+	if store.config.VerifyWrites == "true" {
+		store.initWriteVerifier()
+	}
+
+	if store.config.LatencySLO != "" {
+		store.initLatencySLO()
+	}
+
+	if store.config.OverloadShedding == "true" {
+		store.initOverloadShedding()
+	}
+
+	if store.config.QueryCacheTTL != "" {
+		store.initQueryCache()
+	}
+
+	if store.config.StaleIfError != "" {
+		store.initStaleIfError()
+	}
+
+	if store.config.UsageMetering == "true" {
+		store.initUsageMeter()
+	}
+
+	if store.config.HotKeys == "true" {
+		store.initHotKeys()
+	}
+
+	if store.config.WriteCoalescing != "" {
+		store.initWriteCoalescing()
+	}
+
+	if store.config.WriteAudit == "true" {
+		store.initWriteAudit()
+	}
+
+	if store.config.SchemaSampling == "true" {
+		store.initSchemaRegistry()
+	}
+
+	if store.config.Quotas != "" {
+		store.initQuotas()
+	}
+
+	if store.config.ConflictMerge != "" {
+		store.initConflictMerge()
+	}
+
+	if store.config.GetDeduplication == "true" {
+		store.getFlights = newGetDeduplicator()
+		store.logger.Info("Sharing reads between concurrent Gets of the same key")
+	}
+
+	if store.config.KeyLocking == "true" {
+		store.initKeyLocks()
+	}
+
+	if store.config.QueryPassthrough == "true" {
+		store.initQueryPassthrough()
+	}
+
+	if store.config.WorkloadSampling == "true" {
+		store.initWorkloadSampler()
+	}
+
+	if store.config.LeaderElection == "true" {
+		store.initLeaderElection()
+	}
+
+	if store.config.BlobMigration == "true" {
+		store.initBlobMigration()
+	}
+
+	if store.timeBuckets != nil {
+		if err := store.startTimeBuckets(); err != nil {
+			store.closeSession(store.session)
+			store.session = nil
+			return err
+		}
+	}
+
+	if store.config.StatsInterval != "" {
+		store.initStatsGauges()
+	}
+
+	if store.config.MirrorHosts != "" {
+		store.initClusterMirror()
+	}
+
+	if store.config.MirrorReconcileInterval != "" {
+		store.initMirrorReconcile()
+	}
+
+	if store.config.SearchIndexVerifyInterval != "" {
+		store.initSearchIndexVerify()
+	}
+
+	if store.config.MirrorShadowReads != "0" {
+		store.initShadowReads()
+	}
+
+	if store.config.ChangeFeed == "true" {
+		store.initChangeFeed()
+	}
+
+	if store.config.KeyGroups == "true" {
+		store.initKeyGroups()
+	}
+
+	if store.config.Fixtures != "" {
+		if err := fixtures.Seed(ctx, store, store.config.Fixtures, store.config.DevMode == "true", store.logger); err != nil {
+			return err
+		}
+	}
 
 	store.logger.Info("ScyllaStateStore initialized successfully")
 	return nil
@@ -276,6 +1034,10 @@ func (store *ScyllaStateStore) Init(ctx context.Context, metadata state.Metadata
 
 func (store *ScyllaStateStore) createSessionAndInitialize() error {
 	// First, create a session without specifying keyspace to create it if needed
+	store.initPhase(initPhaseConnecting)
+	if store.injected != nil {
+		return store.initializeInjectedSession()
+	}
 	session, err := store.cluster.CreateSession()
 	if err != nil {
 		store.logger.Errorf("Failed to create ScyllaDB session: %v", err)
@@ -283,20 +1045,15 @@ func (store *ScyllaStateStore) createSessionAndInitialize() error {
 	}
 
 	// Create keyspace if it doesn't exist
-	createKeyspaceQuery := fmt.Sprintf(`
-		CREATE KEYSPACE IF NOT EXISTS %s 
-		WITH replication = {
-			'class': '%s', 
-			'replication_factor': %s
-		}`,
-		store.config.Keyspace,
-		store.config.ReplicationStrategy,
-		store.config.ReplicationFactor)
-
-	store.logger.Debugf("Creating keyspace with query: %s", createKeyspaceQuery)
-	if err := session.Query(createKeyspaceQuery).Exec(); err != nil {
-		session.Close()
-		return fmt.Errorf("failed to create keyspace: %w", err)
+	createKeyspaceQuery := keyspaceDDL(store.config.Keyspace, store.config.ReplicationStrategy, store.config.ReplicationFactor)
+
+	store.initPhase(initPhaseCreatingSpace)
+	if store.config.CreateSchema == "true" {
+		store.logger.Debugf("Creating keyspace with query: %s", createKeyspaceQuery)
+		if err := session.Query(createKeyspaceQuery).Exec(); err != nil {
+			session.Close()
+			return fmt.Errorf("failed to create keyspace: %w", err)
+		}
 	}
 
 	// Close the initial session
@@ -304,44 +1061,114 @@ func (store *ScyllaStateStore) createSessionAndInitialize() error {
 
 	// Create a new session with the keyspace
 	store.cluster.Keyspace = store.config.Keyspace
+	// Host selection policies cannot be shared between sessions
+	policy := store.hostSelectionPolicy()
+	store.cluster.PoolConfig.HostSelectionPolicy = policy
 	session, err = store.cluster.CreateSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session with keyspace: %w", err)
 	}
+	store.activateHostSelectionPolicy(policy)
+
+	if err := store.initializeTables(session); err != nil {
+		session.Close()
+		return err
+	}
+	return nil
+}
+
+// initializeTables creates the state table on session, or migrates it, and
+// prepares the statements. The store uses session from then on.
+func (store *ScyllaStateStore) initializeTables(session *gocql.Session) error {
+	// Locked-down clusters get their schema from a DBA instead
+	if store.config.CreateSchema != "true" {
+		store.initPhase(initPhaseCreatingTable)
+		if err := store.checkSchema(session); err != nil {
+			return err
+		}
+		store.session = session
+		store.logger.Info("ScyllaDB schema checked (createSchema=false)")
+		store.prepareStatements(session)
+		return nil
+	}
 
 	// Create table if it doesn't exist
-	createTableQuery := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			key text PRIMARY KEY,
-			value text,
-			etag text,
-			last_modified timestamp
-		)`, store.config.Table)
+	createTableQuery := stateTableDDL(store.config.Table)
 
+	store.initPhase(initPhaseCreatingTable)
 	store.logger.Debugf("Creating table with query: %s", createTableQuery)
 	if err := session.Query(createTableQuery).Exec(); err != nil {
-		session.Close()
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
 	store.session = session
+
+	// Tables created by earlier versions only have the text value column
+	store.initPhase(initPhaseMigratingSchema)
+	if err := store.ensureBlobColumn(context.Background()); err != nil {
+		store.session = nil
+		return err
+	}
+
+	if store.config.LeaderElection == "true" {
+		if err := store.ensureLeaseTable(context.Background()); err != nil {
+			store.session = nil
+			return err
+		}
+	}
+
+	if store.config.ChangeFeed == "true" {
+		if err := store.ensureChangeFeedTable(context.Background()); err != nil {
+			store.session = nil
+			return err
+		}
+	}
+
+	if store.config.KeyGroups == "true" {
+		if err := store.ensureKeyGroupsTable(context.Background()); err != nil {
+			store.session = nil
+			return err
+		}
+	}
 	store.logger.Info("ScyllaDB keyspace and table initialized successfully")
 
+	store.prepareStatements(session)
+	return nil
+}
+
+// prepareStatements configures the statements reused by Get, Set and Delete on session.
+func (store *ScyllaStateStore) prepareStatements(session *gocql.Session) {
 	// Prepare statements for best performance (benchmark best practice)
 	// Using prepared statements reduces query parsing overhead significantly
-	getQuery := fmt.Sprintf("SELECT value, etag, last_modified FROM %s WHERE key = ?", store.config.Table)
-	setQuery := fmt.Sprintf("INSERT INTO %s (key, value, etag, last_modified) VALUES (?, ?, ?, ?)", store.config.Table)
+	getQuery := fmt.Sprintf("SELECT value, value_blob, etag, last_modified FROM %s WHERE key = ?", store.config.Table)
+	if store.rowTimesEnabled() {
+		getQuery = fmt.Sprintf("SELECT value, value_blob, etag, last_modified, %s FROM %s WHERE key = ?", rowTimesColumns, store.config.Table)
+	}
+	setQuery := fmt.Sprintf("INSERT INTO %s (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?) USING TTL ?", store.config.Table)
 	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE key = ?", store.config.Table)
-
-	// Create prepared statements with proper configuration
-	store.getStmt = session.Query(getQuery).Consistency(store.cluster.Consistency)
-	store.setStmt = session.Query(setQuery).Consistency(store.cluster.Consistency)
-	store.deleteStmt = session.Query(deleteQuery).Consistency(store.cluster.Consistency)
+	store.queries = stateQueries{
+		set:    setQuery,
+		delete: deleteQuery,
+		etag:   fmt.Sprintf("SELECT etag FROM %s WHERE key = ?", store.config.Table),
+	}
+
+	// Create prepared statements with proper configuration. All three are
+	// idempotent: Set writes the full row with a client-generated ETag and
+	// timestamp, so re-executing it yields the same row, and ETag checks happen
+	// in a separate read. The driver only runs speculative executions of
+	// idempotent statements.
+	store.getStmt = session.Query(getQuery).Consistency(store.cluster.Consistency).
+		Idempotent(true).SetSpeculativeExecutionPolicy(store.speculative)
+	store.setStmt = session.Query(setQuery).Consistency(store.cluster.Consistency).
+		Idempotent(true).SetSpeculativeExecutionPolicy(store.speculative)
+	store.deleteStmt = session.Query(deleteQuery).Consistency(store.cluster.Consistency).
+		Idempotent(true).SetSpeculativeExecutionPolicy(store.speculative)
+
+	store.prepareDeadlineStatements(session, getQuery, setQuery)
 
 	// Ensure statements are prepared at initialization for optimal performance
 	// Note: GoCQL automatically prepares statements on first use, so we don't need explicit Prepare() calls
 	store.logger.Info("Prepared statements configured successfully")
-	return nil
 }
 
 func (store *ScyllaStateStore) GetComponentMetadata() map[string]string {
@@ -355,14 +1182,32 @@ func (store *ScyllaStateStore) GetComponentMetadata() map[string]string {
 
 func (store *ScyllaStateStore) Features() []state.Feature {
 	// Return supported features for ScyllaDB state store
-	return []state.Feature{
+	features := []state.Feature{
 		state.FeatureETag,
-		state.FeatureTransactional,
-		state.FeatureQueryAPI,
 	}
+	// Keys of one transaction may live in different bucket tables
+	if store.timeBuckets == nil {
+		features = append(features, state.FeatureTransactional)
+	}
+	if store.queryAPISupported() {
+		features = append(features, state.FeatureQueryAPI)
+	}
+	// Prefixes are matched on Dapr keys, which hashed partition keys do not preserve
+	if (store.keys == nil || store.keys.reversible()) && store.timeBuckets == nil {
+		features = append(features, stateext.FeatureDeleteWithPrefix)
+	}
+	return features
 }
 
 func (store *ScyllaStateStore) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	ctx, err := store.admit(ctx, sloOpGet, store.requestPriority(priorityNormal, req.Metadata))
+	if err != nil {
+		return nil, err
+	}
+	ctx = store.pinActor(ctx, req.Key)
+
+	defer store.observeLatency(sloOpGet, time.Now())
+
 	if req.Key == "" {
 		return nil, errors.New("key cannot be empty")
 	}
@@ -380,44 +1225,67 @@ func (store *ScyllaStateStore) Get(ctx context.Context, req *state.GetRequest) (
 
 	store.logger.Debugf("Getting value for key: %s", req.Key)
 
-	var value, etag string
-	var lastModified time.Time
+	key := store.storageKey(req.Key)
 
-	// Use prepared statement with context (benchmark best practice)
-	stmt := store.getStmt.Bind(req.Key).WithContext(ctx)
+	if store.timeBuckets != nil {
+		return store.bucketGet(ctx, req, key)
+	}
 
-	// Execute with retry logic for resilience
-	var err error
-	maxRetries := 3
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err = stmt.Scan(&value, &etag, &lastModified)
-		if err == nil {
-			break
+	// A Set buffered by write coalescing is newer than the stored value
+	if store.coalescer != nil {
+		if value, etag, ok := store.coalescer.get(key); ok {
+			store.meterRead(req.Key, len(value))
+			response := &state.GetResponse{Data: []byte(value), ETag: &etag}
+			if store.masker != nil {
+				response.Data = store.masker.mask(response.Data)
+			}
+			return response, nil
 		}
+	}
 
-		if err == gocql.ErrNotFound {
-			// Key not found, return empty response
-			return &state.GetResponse{}, nil
-		}
+	// Skip the round trip for keys the bloom filter knows do not exist
+	if store.keyFilter != nil && !store.keyFilter.mayContain(key) {
+		store.logger.Debugf("Bloom filter miss for key: %s", req.Key)
+		return &state.GetResponse{}, nil
+	}
 
-		// Retry on transient errors
-		if errors.Is(err, gocql.ErrUnavailable) || errors.Is(err, gocql.ErrTimeoutNoResponse) {
-			if attempt < maxRetries {
-				backoff := time.Duration(attempt*attempt) * 100 * time.Millisecond
-				store.logger.Warnf("Transient error on get key %s (attempt %d/%d), retrying after %v: %v",
-					req.Key, attempt, maxRetries, backoff, err)
-				time.Sleep(backoff)
-				continue
-			}
-		}
+	if response, err := store.notModified(ctx, req, key); response != nil || err != nil {
+		return response, err
+	}
 
-		store.logger.Errorf("Failed to get key %s after %d attempts: %v", req.Key, attempt, err)
+	start := time.Now()
+	row, err := store.readRow(ctx, req.Key, key)
+	if store.clusterMirror != nil && store.clusterMirror.shadow != nil && (err == nil || err == gocql.ErrNotFound) {
+		store.clusterMirror.shadow.shadowGet(req.Key, key, row, err == nil, time.Since(start))
+	}
+	if err == gocql.ErrNotFound {
+		if store.staleCache != nil {
+			store.staleCache.forget(key)
+		}
+		// Key not found, return empty response
+		return &state.GetResponse{}, nil
+	}
+	if err != nil {
+		if response := store.staleGet(ctx, req.Key, key, err); response != nil {
+			return response, nil
+		}
+		store.logger.Errorf("Failed to get key %s: %v", req.Key, err)
 		return nil, fmt.Errorf("failed to get key %s: %w", req.Key, err)
 	}
 
+	value := storedBytes(row.text, row.blob)
+	store.meterRead(req.Key, len(value))
+	store.rememberRead(key, value, row.etag)
+
 	response := &state.GetResponse{
-		Data: []byte(value),
-		ETag: &etag,
+		Data: value,
+		ETag: &row.etag,
+	}
+	if store.rowTimesEnabled() {
+		response.Metadata = row.times.metadata(store.now())
+	}
+	if store.masker != nil {
+		response.Data = store.masker.mask(response.Data)
 	}
 
 	store.logger.Debugf("Successfully retrieved key: %s", req.Key)
@@ -425,6 +1293,15 @@ func (store *ScyllaStateStore) Get(ctx context.Context, req *state.GetRequest) (
 }
 
 func (store *ScyllaStateStore) Set(ctx context.Context, req *state.SetRequest) error {
+	ctx, err := store.admit(ctx, sloOpSet, store.requestPriority(priorityNormal, req.Metadata))
+	if err != nil {
+		return err
+	}
+	ctx = store.pinActor(ctx, req.Key)
+
+	defer store.observeLatency(sloOpSet, time.Now())
+	defer store.invalidateQueryCache()
+
 	if req.Key == "" {
 		return errors.New("key cannot be empty")
 	}
@@ -442,73 +1319,138 @@ func (store *ScyllaStateStore) Set(ctx context.Context, req *state.SetRequest) e
 
 	store.logger.Debugf("Setting value for key: %s", req.Key)
 
-	// Convert value to string efficiently
-	var value string
-	if req.Value != nil {
-		if bytes, ok := req.Value.([]byte); ok {
-			value = string(bytes)
-		} else if str, ok := req.Value.(string); ok {
-			value = str
-		} else {
-			// Try to marshal as JSON
-			if jsonBytes, err := json.Marshal(req.Value); err == nil {
-				value = string(jsonBytes)
-			} else {
-				return fmt.Errorf("failed to convert value to string: %w", err)
-			}
+	// Recovery writes overwrite the key whatever its current ETag
+	force, err := store.forceWrite("set", req.Key, req.ETag, req.Metadata)
+	if err != nil {
+		return err
+	}
+	if force {
+		forced := *req
+		forced.ETag = nil
+		req = &forced
+	}
+
+	value, err := store.coerceValue(req.Key, req.Value, req.ContentType)
+	if err != nil {
+		return err
+	}
+
+	ttl, err := rowTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	key := store.storageKey(req.Key)
+
+	// Wait for other local writes of the key (see keyLocks)
+	unlock, err := store.lockKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := store.reserveQuota(ctx, quotaWrite{key: req.Key, size: len(value)}); err != nil {
+		return err
+	}
+
+	if store.timeBuckets != nil {
+		return store.bucketSet(ctx, req, key, value, ttl)
+	}
+
+	if err := store.indexKeyGroups(ctx, req.Key); err != nil {
+		return err
+	}
+
+	// Plain Sets of hot keys may be buffered and written once per window; other
+	// Sets write the buffered value first, so ETags and patches apply to it
+	if store.coalescer != nil {
+		if req.ETag == nil && !isMergePatch(req.Metadata) {
+			if store.coalescer.offer(req.Key, key, value, ttl) {
+				if store.keyFilter != nil {
+					store.keyFilter.add(key)
+				}
+				store.meterWrite(req.Key, len(value))
+				return nil
+			}
+		} else {
+			store.coalescer.settle(key)
 		}
 	}
 
+	if isMergePatch(req.Metadata) {
+		return store.setMergePatch(ctx, req, key, value, ttl)
+	}
+
 	// Generate etag with higher precision for better concurrency control
-	etag := fmt.Sprintf("%d", time.Now().UnixNano())
+	etag := store.newETag()
 
 	// Handle ETag for optimistic concurrency (lightweight read before write)
 	if req.ETag != nil {
 		// Use prepared statement for etag check for better performance
 		var currentEtag string
-		checkQuery := fmt.Sprintf("SELECT etag FROM %s WHERE key = ?", store.config.Table)
-		checkStmt := store.session.Query(checkQuery, req.Key).WithContext(ctx)
+		checkStmt, err := store.hookedQuery(ctx, "set", store.queries.etag, key)
+		if err != nil {
+			return err
+		}
 		checkErr := checkStmt.Scan(&currentEtag)
 		if checkErr != nil && checkErr != gocql.ErrNotFound {
 			return fmt.Errorf("failed to check current etag: %w", checkErr)
 		}
 
 		if checkErr != gocql.ErrNotFound && currentEtag != *req.ETag {
-			return fmt.Errorf("etag mismatch: expected %s, got %s", *req.ETag, currentEtag)
+			// A merge strategy configured for the key resolves the conflict instead
+			if rule := store.merger.ruleFor(req.Key); rule != nil {
+				return store.setMerged(ctx, req, key, value, ttl, rule)
+			}
+			// Carry the current ETag so callers can reconcile without another Get
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", req.Key, *req.ETag, currentEtag), currentEtag)
 		}
 	}
 
+	// The audit diff needs the value being replaced
+	previous := store.auditPrevious(ctx, req.Key, key)
+
+	// Record the key before writing so concurrent Gets never see a false negative
+	if store.keyFilter != nil {
+		store.keyFilter.add(key)
+	}
+
 	// Insert/update using prepared statement with retry logic (benchmark best practice)
-	stmt := store.setStmt.Bind(req.Key, value, etag, time.Now()).WithContext(ctx)
-
-	var err error
-	maxRetries := 3
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err = stmt.Exec()
-		if err == nil {
-			break
-		}
-
-		// Retry logic for transient errors with exponential backoff
-		if errors.Is(err, gocql.ErrUnavailable) || errors.Is(err, gocql.ErrTimeoutNoResponse) {
-			if attempt < maxRetries {
-				backoff := time.Duration(attempt*attempt) * 100 * time.Millisecond
-				store.logger.Warnf("Transient error on set key %s (attempt %d/%d), retrying after %v: %v",
-					req.Key, attempt, maxRetries, backoff, err)
-				time.Sleep(backoff)
-				continue
-			}
-		}
+	stmt, err := store.hookedStatement(ctx, "set", store.setStmt, key, stringToBytes(value), etag, store.now(), ttl)
+	if err != nil {
+		return err
+	}
+	if req.ETag == nil {
+		// Every attempt writes the same cell version, so a late retry cannot win
+		stmt = store.withWriteTimestamp(stmt)
+	}
 
-		store.logger.Errorf("Failed to set key %s after %d attempts: %v", req.Key, attempt, err)
+	if err := store.withRetry(ctx, fmt.Sprintf("set key %s", req.Key), stmt.Exec); err != nil {
+		store.logger.Errorf("Failed to set key %s: %v", req.Key, err)
 		return fmt.Errorf("failed to set key %s: %w", req.Key, err)
 	}
 
+	store.afterSet(req.Key, key, value, etag, ttl)
+	store.meterWrite(req.Key, len(value))
+	store.observeSchema(req.Key, value)
+	store.verifyWrite(req.Key, key, value, etag)
+	store.auditSet(req.Key, etag, value, previous)
+
 	store.logger.Debugf("Successfully set key: %s", req.Key)
 	return nil
 }
 
 func (store *ScyllaStateStore) Delete(ctx context.Context, req *state.DeleteRequest) error {
+	ctx, err := store.admit(ctx, sloOpDelete, store.requestPriority(priorityNormal, req.Metadata))
+	if err != nil {
+		return err
+	}
+	ctx = store.pinActor(ctx, req.Key)
+
+	defer store.observeLatency(sloOpDelete, time.Now())
+	defer store.invalidateQueryCache()
+
 	if req.Key == "" {
 		return errors.New("key cannot be empty")
 	}
@@ -526,56 +1468,93 @@ func (store *ScyllaStateStore) Delete(ctx context.Context, req *state.DeleteRequ
 
 	store.logger.Debugf("Deleting key: %s", req.Key)
 
-	// Handle ETag for optimistic concurrency
-	if req.ETag != nil {
+	force, err := store.forceWrite("delete", req.Key, req.ETag, req.Metadata)
+	if err != nil {
+		return err
+	}
+	if force {
+		forced := *req
+		forced.ETag = nil
+		req = &forced
+	}
+
+	key := store.storageKey(req.Key)
+
+	// Wait for other local writes of the key (see keyLocks)
+	unlock, err := store.lockKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if store.timeBuckets != nil {
+		return store.bucketDelete(ctx, req, key)
+	}
+
+	// Write a value buffered by write coalescing first, so the checks see it
+	if store.coalescer != nil && (req.ETag != nil || !store.ignoreNotFound(req.Metadata)) {
+		store.coalescer.settle(key)
+	}
+
+	// Handle ETag for optimistic concurrency; a missing key is only an error
+	// when the request asks for it
+	if req.ETag != nil || !store.ignoreNotFound(req.Metadata) {
 		// Verify current etag matches using prepared statement pattern
 		var currentEtag string
-		checkQuery := fmt.Sprintf("SELECT etag FROM %s WHERE key = ?", store.config.Table)
-		checkStmt := store.session.Query(checkQuery, req.Key).WithContext(ctx)
+		checkStmt, err := store.hookedQuery(ctx, "delete", store.queries.etag, key)
+		if err != nil {
+			return err
+		}
 		if err := checkStmt.Scan(&currentEtag); err != nil {
 			if err == gocql.ErrNotFound {
-				// Key doesn't exist, nothing to delete
-				return nil
+				if store.ignoreNotFound(req.Metadata) {
+					// Key doesn't exist, nothing to delete
+					return nil
+				}
+				return keyNotFoundError(req.Key)
 			}
 			return fmt.Errorf("failed to check current etag: %w", err)
 		}
 
-		if currentEtag != *req.ETag {
-			return fmt.Errorf("etag mismatch: expected %s, got %s", *req.ETag, currentEtag)
+		if req.ETag != nil && currentEtag != *req.ETag {
+			// Carry the current ETag so callers can reconcile without another Get
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", req.Key, *req.ETag, currentEtag), currentEtag)
 		}
 	}
 
+	if store.isDryRun(req.Metadata) {
+		store.logger.Infof("DRY RUN: would delete key %s", req.Key)
+		return nil
+	}
+
 	// Delete using prepared statement with retry logic (benchmark best practice)
-	stmt := store.deleteStmt.Bind(req.Key).WithContext(ctx)
-
-	var err error
-	maxRetries := 3
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err = stmt.Exec()
-		if err == nil {
-			break
-		}
-
-		// Retry logic for transient errors with exponential backoff
-		if errors.Is(err, gocql.ErrUnavailable) || errors.Is(err, gocql.ErrTimeoutNoResponse) {
-			if attempt < maxRetries {
-				backoff := time.Duration(attempt*attempt) * 100 * time.Millisecond
-				store.logger.Warnf("Transient error on delete key %s (attempt %d/%d), retrying after %v: %v",
-					req.Key, attempt, maxRetries, backoff, err)
-				time.Sleep(backoff)
-				continue
-			}
-		}
+	stmt, err := store.hookedStatement(ctx, "delete", store.deleteStmt, key)
+	if err != nil {
+		return err
+	}
 
-		store.logger.Errorf("Failed to delete key %s after %d attempts: %v", req.Key, attempt, err)
+	if err := store.withRetry(ctx, fmt.Sprintf("delete key %s", req.Key), stmt.Exec); err != nil {
+		store.logger.Errorf("Failed to delete key %s: %v", req.Key, err)
 		return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
 	}
 
+	store.afterDelete(key)
+	store.meterDelete(req.Key)
+	store.auditDelete(req.Key)
+
 	store.logger.Debugf("Successfully deleted key: %s", req.Key)
 	return nil
 }
 
 func (store *ScyllaStateStore) BulkGet(ctx context.Context, req []state.GetRequest, opts state.BulkGetOpts) ([]state.BulkGetResponse, error) {
+	ctx, err := store.admit(ctx, sloOpBulkGet, store.requestPriority(priorityBulk, requestsMetadata(req)...))
+	if err != nil {
+		return nil, err
+	}
+
+	defer store.observeLatency(sloOpBulkGet, time.Now())
+
 	if len(req) == 0 {
 		return []state.BulkGetResponse{}, nil
 	}
@@ -595,8 +1574,16 @@ func (store *ScyllaStateStore) BulkGet(ctx context.Context, req []state.GetReque
 
 	responses := make([]state.BulkGetResponse, len(req))
 
-	// For small batches, use concurrent individual queries for better performance
-	if len(req) <= 10 {
+	// Existence and ETags only: any number of keys is read with a projection of
+	// the key and etag columns
+	keysOnly := stateext.KeysOnly(requestsMetadata(req)...)
+	if keysOnly {
+		ctx = withKeysOnly(ctx)
+	}
+
+	// For small batches, use concurrent individual queries for better performance;
+	// bucketed keys are always read one by one
+	if (len(req) <= 10 && !keysOnly) || store.timeBuckets != nil {
 		type getResult struct {
 			index int
 			resp  *state.GetResponse
@@ -604,6 +1591,7 @@ func (store *ScyllaStateStore) BulkGet(ctx context.Context, req []state.GetReque
 		}
 
 		resultChan := make(chan getResult, len(req))
+		budget := store.newBulkGetBudget()
 
 		// Use goroutine pool for concurrent execution (benchmark best practice)
 		for i, getReq := range req {
@@ -619,14 +1607,21 @@ func (store *ScyllaStateStore) BulkGet(ctx context.Context, req []state.GetReque
 			response := state.BulkGetResponse{
 				Key: req[result.index].Key,
 			}
+			if result.err == nil && result.resp != nil && keysOnly {
+				result.resp.Data = nil
+			}
 			if result.err != nil {
 				response.Error = result.err.Error()
+			} else if result.resp != nil && !budget.take(len(result.resp.Data)) {
+				response.Error = budget.exceededError()
 			} else if result.resp != nil {
 				response.Data = result.resp.Data
 				response.ETag = result.resp.ETag
+				response.Metadata = result.resp.Metadata
 			}
 			responses[result.index] = response
 		}
+		store.recordBulkGetBytes(budget.used)
 		return responses, nil
 	}
 
@@ -634,24 +1629,96 @@ func (store *ScyllaStateStore) BulkGet(ctx context.Context, req []state.GetReque
 	keys := make([]string, len(req))
 	keyToIndex := make(map[string]int, len(req))
 	for i, getReq := range req {
-		keys[i] = getReq.Key
-		keyToIndex[getReq.Key] = i
+		keys[i] = store.storageKey(getReq.Key)
+		keyToIndex[keys[i]] = i
 		responses[i] = state.BulkGetResponse{Key: getReq.Key}
 	}
 
-	// Build IN query with batch size optimization
-	const maxBatchSize = 100 // ScyllaDB recommendation for IN queries
-	for start := 0; start < len(keys); start += maxBatchSize {
-		end := start + maxBatchSize
+	budget := store.newBulkGetBudget()
+	collect := func(key string, value []byte, etag string) bool {
+		idx, exists := keyToIndex[key]
+		if !exists {
+			return true
+		}
+		if !budget.take(len(value)) {
+			return false
+		}
+		if !keysOnly {
+			store.rememberRead(key, value, etag)
+		}
+		if store.masker != nil {
+			value = store.masker.mask(value)
+		}
+		responses[idx].Data = value
+		responses[idx].ETag = &etag
+		store.meterRead(req[idx].Key, len(value))
+		return true
+	}
+
+	// Keys the scan did not reach are returned with an error so the caller can fetch them separately
+	unread := 0
+	if store.parallelBulkGet(len(keys)) {
+		completed, err := store.fetchRowsParallel(ctx, keys, collect)
+		if err != nil {
+			store.observeOverload(err)
+			// Stale values cannot vouch for the current ETags keysOnly reads check
+			if !keysOnly && store.staleBulkGet(ctx, responses, keys, func(i int) bool { return completed[i] }, err) {
+				return responses, nil
+			}
+			store.logger.Errorf("Error during bulk get iteration: %v", err)
+			return nil, fmt.Errorf("bulk get failed: %w", err)
+		}
+		for i := range keys {
+			if !completed[i] && responses[i].ETag == nil {
+				responses[i].Error = budget.exceededError()
+				unread++
+			}
+		}
+	} else {
+		fetched, err := store.fetchRows(ctx, keys, collect)
+		if err != nil {
+			store.observeOverload(err)
+			if !keysOnly && store.staleBulkGet(ctx, responses, keys, func(i int) bool { return i < fetched }, err) {
+				return responses, nil
+			}
+			store.logger.Errorf("Error during bulk get iteration: %v", err)
+			return nil, fmt.Errorf("bulk get failed: %w", err)
+		}
+		for i := fetched; i < len(keys); i++ {
+			if responses[i].ETag == nil {
+				responses[i].Error = budget.exceededError()
+				unread++
+			}
+		}
+	}
+	store.recordBulkGetBytes(budget.used)
+
+	if unread > 0 {
+		store.logger.Warnf("BulkGet memory budget of %d bytes exceeded after %d bytes; %d keys not read",
+			budget.limit, budget.used, unread)
+	}
+
+	store.logger.Debugf("BulkGet completed for %d keys", len(req))
+	return responses, nil
+}
+
+// fetchRows reads key, value and etag for the given stored keys using batched IN
+// queries and calls fn for every row found; keysOnly contexts read no values. Rows are handed over one at a time,
+// so only the current IN batch is buffered by the driver. When fn returns false
+// the scan stops; the returned count is the number of leading keys whose batch
+// was read completely.
+func (store *ScyllaStateStore) fetchRows(ctx context.Context, keys []string, fn func(key string, value []byte, etag string) bool) (int, error) {
+	// Build IN query with batch size optimization, smaller while the backend is overloaded
+	batchSize := store.inBatchSize()
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
 		if end > len(keys) {
 			end = len(keys)
 		}
 
 		batchKeys := keys[start:end]
-		placeholders := strings.Repeat("?,", len(batchKeys))
-		placeholders = placeholders[:len(placeholders)-1] // Remove trailing comma
-
-		query := fmt.Sprintf("SELECT key, value, etag FROM %s WHERE key IN (%s)", store.config.Table, placeholders)
+		row := newRowScan(ctx)
+		query := store.inQuery(row.columns(), len(batchKeys))
 
 		// Convert keys to interface{} slice for query
 		keyInterfaces := make([]interface{}, len(batchKeys))
@@ -660,27 +1727,36 @@ func (store *ScyllaStateStore) BulkGet(ctx context.Context, req []state.GetReque
 		}
 
 		// Execute query with error handling
-		iter := store.session.Query(query, keyInterfaces...).WithContext(ctx).Iter()
+		stmt, err := store.hookedQuery(ctx, "bulk get", query, keyInterfaces...)
+		if err != nil {
+			return start, err
+		}
+		iter := stmt.Iter()
 
-		var key, value, etag string
-		for iter.Scan(&key, &value, &etag) {
-			if idx, exists := keyToIndex[key]; exists {
-				responses[idx].Data = []byte(value)
-				responses[idx].ETag = &etag
+		for iter.Scan(row.dest()...) {
+			if !fn(row.key, row.value(), row.etag) {
+				iter.Close()
+				return start, nil
 			}
 		}
 
 		if err := iter.Close(); err != nil {
-			store.logger.Errorf("Error during bulk get iteration: %v", err)
-			return nil, fmt.Errorf("bulk get failed: %w", err)
+			return start, err
 		}
 	}
 
-	store.logger.Debugf("BulkGet completed for %d keys", len(req))
-	return responses, nil
+	return len(keys), nil
 }
 
 func (store *ScyllaStateStore) BulkSet(ctx context.Context, req []state.SetRequest, opts state.BulkStoreOpts) error {
+	ctx, err := store.admit(ctx, sloOpBulkSet, store.requestPriority(priorityBulk, requestsMetadata(req)...))
+	if err != nil {
+		return err
+	}
+
+	defer store.observeLatency(sloOpBulkSet, time.Now())
+	defer store.invalidateQueryCache()
+
 	if len(req) == 0 {
 		return nil
 	}
@@ -698,95 +1774,140 @@ func (store *ScyllaStateStore) BulkSet(ctx context.Context, req []state.SetReque
 
 	store.logger.Debugf("Bulk setting %d keys", len(req))
 
-	// For small batches, use concurrent individual operations for better performance
-	if len(req) <= 5 {
-		type setResult struct {
-			key string
-			err error
+	for i := range req {
+		if err := rejectMergePatch("bulk set", req[i].Key, req[i].Metadata); err != nil {
+			return err
 		}
+	}
+
+	// For small batches, use concurrent individual operations for better performance;
+	// bucketed keys are always written one by one
+	if len(req) <= 5 || store.timeBuckets != nil {
+		return concurrentBulk(req, "set", func(request state.SetRequest) error {
+			return store.Set(ctx, &request)
+		})
+	}
+
+	req, err = store.forceBulkSet(req)
+	if err != nil {
+		return err
+	}
+
+	// For larger batches, group by partition and execute one batch per partition in parallel;
+	// items carrying an ETag are written with conditional batches (see conditionalBulkSet)
+	lastIndex := make(map[string]int, len(req))
+	for i, setReq := range req {
+		lastIndex[store.storageKey(setReq.Key)] = i
+	}
 
-		resultChan := make(chan setResult, len(req))
+	query := store.queries.set
+	stmts := make([]partitionStatement, 0, len(req))
+	plain := make([]int, 0, len(req)) // request index of each statement
+	values := make([]string, len(req))
+	var conditional []conditionalSet
 
-		// Use concurrent execution (benchmark best practice)
-		for _, setReq := range req {
-			go func(request state.SetRequest) {
-				err := store.Set(ctx, &request)
-				resultChan <- setResult{key: request.Key, err: err}
-			}(setReq)
+	for i, setReq := range req {
+		value, err := store.coerceValue(setReq.Key, setReq.Value, setReq.ContentType)
+		if err != nil {
+			return err
+		}
+		ttl, err := rowTTL(setReq.Metadata)
+		if err != nil {
+			return fmt.Errorf("invalid ttl for key %s: %w", setReq.Key, err)
 		}
 
-		// Collect results and check for errors
-		for i := 0; i < len(req); i++ {
-			result := <-resultChan
-			if result.err != nil {
-				return fmt.Errorf("failed to set key %s: %w", result.key, result.err)
-			}
+		// Generate etag with higher precision
+		etag := store.newETag()
+
+		key := store.storageKey(setReq.Key)
+		if store.keyFilter != nil {
+			store.keyFilter.add(key)
 		}
-		return nil
-	}
+		values[i] = value
 
-	// For larger batches, use optimized batch operations
-	const maxBatchSize = 50 // Optimal batch size for ScyllaDB
+		// Only the last write of a key that has a conditional write is applied:
+		// an earlier condition is moot, and an earlier plain write would race the
+		// conditional batch
+		last := lastIndex[key]
+		if last != i && (setReq.ETag != nil || req[last].ETag != nil) {
+			continue
+		}
+		if setReq.ETag != nil {
+			conditional = append(conditional, conditionalSet{
+				key:        setReq.Key,
+				storageKey: key,
+				value:      value,
+				expected:   *setReq.ETag,
+				etag:       etag,
+				ttl:        ttl,
+			})
+			continue
+		}
 
-	for start := 0; start < len(req); start += maxBatchSize {
-		end := start + maxBatchSize
-		if end > len(req) {
-			end = len(req)
-		}
-
-		batchReq := req[start:end]
-
-		// Use UNLOGGED batch for better performance (benchmark best practice)
-		batch := store.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
-
-		query := fmt.Sprintf("INSERT INTO %s (key, value, etag, last_modified) VALUES (?, ?, ?, ?)", store.config.Table)
-
-		for _, setReq := range batchReq {
-			// Convert value to string efficiently
-			var value string
-			if setReq.Value != nil {
-				if bytes, ok := setReq.Value.([]byte); ok {
-					value = string(bytes)
-				} else if str, ok := setReq.Value.(string); ok {
-					value = str
-				} else {
-					// Try to marshal as JSON
-					if jsonBytes, err := json.Marshal(setReq.Value); err == nil {
-						value = string(jsonBytes)
-					} else {
-						return fmt.Errorf("failed to convert value to string for key %s: %w", setReq.Key, err)
-					}
-				}
-			}
+		plain = append(plain, i)
+		stmts = append(stmts, partitionStatement{
+			query:      query,
+			args:       []interface{}{key, stringToBytes(value), etag, store.now(), ttl},
+			storageKey: key,
+		})
+	}
 
-			// Generate etag with higher precision
-			etag := fmt.Sprintf("%d", time.Now().UnixNano())
+	writes := make([]quotaWrite, len(req))
+	for i, setReq := range req {
+		writes[i] = quotaWrite{key: setReq.Key, size: len(values[i])}
+	}
+	if err := store.reserveQuota(ctx, writes...); err != nil {
+		return err
+	}
 
-			batch.Query(query, setReq.Key, value, etag, time.Now())
+	if store.keyGroups != nil {
+		daprKeys := make([]string, len(req))
+		for i, setReq := range req {
+			daprKeys[i] = setReq.Key
+		}
+		if err := store.indexKeyGroups(ctx, daprKeys...); err != nil {
+			return err
 		}
+	}
 
-		// Execute batch with retry logic
-		var err error
-		maxRetries := 3
-		for attempt := 1; attempt <= maxRetries; attempt++ {
-			err = store.session.ExecuteBatch(batch)
-			if err == nil {
-				break
-			}
+	parallelism := store.bulkParallelism(opts.Parallelism)
+	if len(stmts) > 0 {
+		groups := store.groupByTokenRange(stmts)
+		store.logger.Debugf("Bulk set of %d keys grouped into %d batches", len(stmts), len(groups))
 
-			// Retry on transient errors with exponential backoff
-			if errors.Is(err, gocql.ErrUnavailable) || errors.Is(err, gocql.ErrTimeoutNoResponse) {
-				if attempt < maxRetries {
-					backoff := time.Duration(attempt*attempt) * 100 * time.Millisecond
-					store.logger.Warnf("Transient error on bulk set batch (attempt %d/%d), retrying after %v: %v",
-						attempt, maxRetries, backoff, err)
-					time.Sleep(backoff)
-					continue
-				}
-			}
+		if err := store.executePartitionGroups(ctx, groups, parallelism, "bulk set"); err != nil {
+			return bulkError(err, "bulk set failed")
+		}
+	}
 
-			store.logger.Errorf("Failed to execute bulk set batch after %d attempts: %v", attempt, err)
-			return fmt.Errorf("bulk set batch failed: %w", err)
+	lastWrite := make(map[string]int, len(stmts))
+	for i, stmt := range stmts {
+		lastWrite[stmt.storageKey] = i
+	}
+	for i, stmt := range stmts {
+		setReq := req[plain[i]]
+		value := values[plain[i]]
+		store.afterSet(setReq.Key, stmt.storageKey, value, stmt.args[2].(string), stmt.args[4].(int))
+		store.meterWrite(setReq.Key, len(value))
+		store.observeSchema(setReq.Key, value)
+		// Only the last write of a duplicated key is persisted
+		if lastWrite[stmt.storageKey] == i {
+			store.verifyWrite(setReq.Key, stmt.storageKey, value, stmt.args[2].(string))
+		}
+		store.auditSet(setReq.Key, stmt.args[2].(string), value, nil)
+	}
+
+	if len(conditional) > 0 {
+		applied, err := store.conditionalBulkSet(ctx, conditional, parallelism)
+		for _, item := range applied {
+			store.afterSet(item.key, item.storageKey, item.value, item.etag, item.ttl)
+			store.meterWrite(item.key, len(item.value))
+			store.observeSchema(item.key, item.value)
+			store.verifyWrite(item.key, item.storageKey, item.value, item.etag)
+			store.auditSet(item.key, item.etag, item.value, nil)
+		}
+		if err != nil {
+			return bulkError(err, "bulk set failed")
 		}
 	}
 
@@ -794,7 +1915,49 @@ func (store *ScyllaStateStore) BulkSet(ctx context.Context, req []state.SetReque
 	return nil
 }
 
+// concurrentBulk applies a small bulk request as concurrent single-key
+// operations (benchmark best practice) and returns the first failure.
+func concurrentBulk[T state.StateRequest](req []T, operation string, apply func(request T) error) error {
+	type result struct {
+		key string
+		err error
+	}
+
+	results := make(chan result, len(req))
+	for _, request := range req {
+		go func() {
+			results <- result{key: request.GetKey(), err: apply(request)}
+		}()
+	}
+
+	for range req {
+		if result := <-results; result.err != nil {
+			return bulkError(result.err, fmt.Sprintf("failed to %s key %s", operation, result.key))
+		}
+	}
+	return nil
+}
+
+// bulkError adds context to the failure of a bulk operation. ETag errors are
+// returned unwrapped, as the gRPC server only finds their status on the error
+// itself.
+func bulkError(err error, description string) error {
+	var etagErr *stateext.ETagError
+	if errors.As(err, &etagErr) {
+		return etagErr
+	}
+	return fmt.Errorf("%s: %w", description, err)
+}
+
 func (store *ScyllaStateStore) BulkDelete(ctx context.Context, req []state.DeleteRequest, opts state.BulkStoreOpts) error {
+	ctx, err := store.admit(ctx, sloOpBulkDelete, store.requestPriority(priorityBulk, requestsMetadata(req)...))
+	if err != nil {
+		return err
+	}
+
+	defer store.observeLatency(sloOpBulkDelete, time.Now())
+	defer store.invalidateQueryCache()
+
 	if len(req) == 0 {
 		return nil
 	}
@@ -812,83 +1975,341 @@ func (store *ScyllaStateStore) BulkDelete(ctx context.Context, req []state.Delet
 
 	store.logger.Debugf("Bulk deleting %d keys", len(req))
 
-	// For small batches, use concurrent individual operations for better performance
-	if len(req) <= 5 {
-		type deleteResult struct {
-			key string
-			err error
-		}
+	// For small batches, use concurrent individual operations for better performance;
+	// bucketed keys are always written one by one
+	if len(req) <= 5 || store.timeBuckets != nil {
+		return concurrentBulk(req, "delete", func(request state.DeleteRequest) error {
+			return store.Delete(ctx, &request)
+		})
+	}
+
+	req, err = store.forceBulkDelete(req)
+	if err != nil {
+		return err
+	}
+
+	// Validate ETags and required keys against one snapshot before deleting anything
+	if err := store.checkBulkDelete(ctx, req); err != nil {
+		return err
+	}
 
-		resultChan := make(chan deleteResult, len(req))
+	// For larger batches, group by partition and execute one batch per partition in parallel
+	query := store.queries.delete
+	stmts := make([]partitionStatement, 0, len(req))
+	daprKeys := make([]string, 0, len(req))
 
-		// Use concurrent execution (benchmark best practice)
-		for _, delReq := range req {
-			go func(request state.DeleteRequest) {
-				err := store.Delete(ctx, &request)
-				resultChan <- deleteResult{key: request.Key, err: err}
-			}(delReq)
+	for _, delReq := range req {
+		if store.isDryRun(delReq.Metadata) {
+			store.logger.Infof("DRY RUN: would delete key %s", delReq.Key)
+			continue
 		}
 
-		// Collect results and check for errors
-		for i := 0; i < len(req); i++ {
-			result := <-resultChan
-			if result.err != nil {
-				return fmt.Errorf("failed to delete key %s: %w", result.key, result.err)
+		key := store.storageKey(delReq.Key)
+		stmts = append(stmts, partitionStatement{
+			query:      query,
+			args:       []interface{}{key},
+			storageKey: key,
+		})
+		daprKeys = append(daprKeys, delReq.Key)
+	}
+
+	groups := store.groupByTokenRange(stmts)
+	store.logger.Debugf("Bulk delete of %d keys grouped into %d batches", len(req), len(groups))
+
+	if err := store.executePartitionGroups(ctx, groups, store.bulkParallelism(opts.Parallelism), "bulk delete"); err != nil {
+		return bulkError(err, "bulk delete failed")
+	}
+
+	for i, stmt := range stmts {
+		store.afterDelete(stmt.storageKey)
+		store.meterDelete(daprKeys[i])
+		store.auditDelete(daprKeys[i])
+	}
+
+	store.logger.Debugf("BulkDelete completed for %d keys", len(req))
+	return nil
+}
+
+// Multi applies a set of upsert/delete operations as a single LOGGED batch.
+//
+// All ETags carried by the operations are validated against one snapshot of the
+// current ETags, read before any mutation is applied (see checkTransactionETags).
+// A mismatch on any operation aborts the whole transaction before anything is
+// written, and the LOGGED batch applies all mutations or none (unless
+// transactionChunkBytes splits it, see applyChunks).
+//
+// Validation and apply are not atomic: they are separate round trips and
+// ScyllaDB has no cross-partition locking, so a concurrent write landing between
+// them is not detected and is overwritten. The isolation level is read-committed
+// only.
+func (store *ScyllaStateStore) Multi(ctx context.Context, request *state.TransactionalStateRequest) error {
+	var requestMetadata map[string]string
+	if request != nil {
+		requestMetadata = request.Metadata
+	}
+	ctx, err := store.admit(ctx, sloOpMulti, store.requestPriority(priorityNormal, requestMetadata))
+	if err != nil {
+		return err
+	}
+
+	defer store.observeLatency(sloOpMulti, time.Now())
+	defer store.invalidateQueryCache()
+
+	if request == nil || len(request.Operations) == 0 {
+		return nil
+	}
+
+	if store.timeBuckets != nil {
+		return errTimeBucketsMulti
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed {
+		return errors.New("store is closed")
+	}
+
+	if store.session == nil {
+		return errors.New("session not initialized")
+	}
+
+	store.logger.Debugf("Executing transaction with %d operations", len(request.Operations))
+
+	// Collect the ETag expectations of every operation before touching the database
+	var checks []etagCheck
+	for _, op := range request.Operations {
+		switch req := op.(type) {
+		case state.SetRequest:
+			if req.Key == "" {
+				return errors.New("key cannot be empty")
+			}
+			if err := rejectMergePatch("transaction", req.Key, req.Metadata); err != nil {
+				return err
+			}
+			force, err := store.forceWrite("transaction set", req.Key, req.ETag, req.Metadata, requestMetadata)
+			if err != nil {
+				return err
+			}
+			if req.ETag != nil && !force {
+				checks = append(checks, etagCheck{key: req.Key, storageKey: store.storageKey(req.Key), etag: *req.ETag})
+			}
+		case state.DeleteRequest:
+			if req.Key == "" {
+				return errors.New("key cannot be empty")
 			}
+			force, err := store.forceWrite("transaction delete", req.Key, req.ETag, req.Metadata, requestMetadata)
+			if err != nil {
+				return err
+			}
+			if req.ETag != nil && !force {
+				checks = append(checks, etagCheck{key: req.Key, storageKey: store.storageKey(req.Key), etag: *req.ETag})
+			}
+		default:
+			return fmt.Errorf("unsupported transaction operation type: %T", op)
+		}
+	}
+
+	// Validate all ETags against a single snapshot and abort on the first mismatch
+	if len(checks) > 0 {
+		keys := make([]string, len(checks))
+		for i, check := range checks {
+			keys[i] = check.storageKey
+		}
+
+		snapshot, err := store.readETagSnapshot(ctx, keys)
+		if err != nil {
+			return fmt.Errorf("failed to read etag snapshot: %w", err)
+		}
+		if err := checkTransactionETags(checks, snapshot); err != nil {
+			return err
+		}
+	}
+
+	if store.isDryRun(request.Metadata) {
+		for _, op := range request.Operations {
+			store.logger.Infof("DRY RUN: transaction would %s key %s", op.Operation(), op.GetKey())
 		}
 		return nil
 	}
 
-	// For larger batches, use optimized batch operations
-	const maxBatchSize = 50 // Optimal batch size for ScyllaDB
+	// Prepare every mutation before writing any
+	mutations := make([]txMutation, len(request.Operations))
+	var writes []quotaWrite
+	for i, op := range request.Operations {
+		switch req := op.(type) {
+		case state.SetRequest:
+			value, err := store.coerceValue(req.Key, req.Value, req.ContentType)
+			if err != nil {
+				return err
+			}
+			// Operations may set their own TTL; otherwise the transaction metadata applies
+			ttlMetadata := req.Metadata
+			if _, ok := ttlMetadata[metadata.TTLMetadataKey]; !ok {
+				ttlMetadata = request.Metadata
+			}
+			ttl, err := rowTTL(ttlMetadata)
+			if err != nil {
+				return fmt.Errorf("invalid ttl for key %s: %w", req.Key, err)
+			}
+			mutations[i] = txMutation{daprKey: req.Key, key: store.storageKey(req.Key), set: true, value: value, etag: store.newETag(), ttl: ttl}
+			writes = append(writes, quotaWrite{key: req.Key, size: len(value)})
+		case state.DeleteRequest:
+			mutations[i] = txMutation{daprKey: req.Key, key: store.storageKey(req.Key)}
+		}
+	}
 
-	for start := 0; start < len(req); start += maxBatchSize {
-		end := start + maxBatchSize
-		if end > len(req) {
-			end = len(req)
+	if err := store.reserveQuota(ctx, writes...); err != nil {
+		return err
+	}
+
+	// Transactions over the batch size limit are applied in chunks
+	if chunking := store.txChunking; chunking != nil {
+		if chunks := chunking.split(mutations); len(chunks) > 1 {
+			applied, err := store.applyChunks(ctx, chunks)
+			store.afterTransaction(applied)
+			return err
 		}
+	}
 
-		batchReq := req[start:end]
+	if err := store.executeTransactionBatch(ctx, mutations); err != nil {
+		store.logger.Errorf("Failed to execute transaction batch: %v", err)
+		return fmt.Errorf("transaction failed: %w", err)
+	}
+	store.afterTransaction(mutations)
 
-		// Use UNLOGGED batch for better performance (benchmark best practice)
-		batch := store.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+	store.logger.Debugf("Transaction completed with %d operations", len(request.Operations))
+	return nil
+}
 
-		query := fmt.Sprintf("DELETE FROM %s WHERE key = ?", store.config.Table)
+// etagCheck is the ETag a transaction operation expects its key to have.
+type etagCheck struct {
+	key        string
+	storageKey string
+	etag       string
+}
 
-		for _, delReq := range batchReq {
-			batch.Query(query, delReq.Key)
+// checkTransactionETags validates the ETags of a transaction against one
+// snapshot of the current ETags, returning an ETag mismatch for the first
+// operation whose key has another ETag or does not exist.
+func checkTransactionETags(checks []etagCheck, snapshot map[string]string) error {
+	for _, check := range checks {
+		currentEtag, exists := snapshot[check.storageKey]
+		if !exists {
+			return stateext.NewETagError(state.ETagMismatch,
+				fmt.Errorf("transaction aborted: etag %s supplied for missing key %s", check.etag, check.key))
+		}
+		if currentEtag != check.etag {
+			return stateext.NewETagMismatch(
+				fmt.Errorf("transaction aborted: etag mismatch for key %s: expected %s, got %s",
+					check.key, check.etag, currentEtag), currentEtag)
 		}
+	}
+	return nil
+}
 
-		// Execute batch with retry logic
-		var err error
-		maxRetries := 3
-		for attempt := 1; attempt <= maxRetries; attempt++ {
-			err = store.session.ExecuteBatch(batch)
-			if err == nil {
-				break
+// executeTransactionBatch writes mutations in one LOGGED batch, so they are
+// applied atomically, with the key group entries of the keys they set.
+func (store *ScyllaStateStore) executeTransactionBatch(ctx context.Context, mutations []txMutation) error {
+	batch := store.session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+
+	setQuery, deleteQuery := store.queries.set, store.queries.delete
+	var upserted []string
+	for _, mutation := range mutations {
+		if !mutation.set {
+			if err := store.addHookedBatchEntry(ctx, batch, "transaction", deleteQuery, mutation.key); err != nil {
+				return err
 			}
+			continue
+		}
+		if store.keyFilter != nil {
+			store.keyFilter.add(mutation.key)
+		}
+		if err := store.addHookedBatchEntry(ctx, batch, "transaction", setQuery, mutation.key, stringToBytes(mutation.value), mutation.etag, store.now(), mutation.ttl); err != nil {
+			return err
+		}
+		upserted = append(upserted, mutation.daprKey)
+	}
+	if err := store.addKeyGroupEntries(ctx, batch, upserted); err != nil {
+		return err
+	}
 
-			// Retry on transient errors with exponential backoff
-			if errors.Is(err, gocql.ErrUnavailable) || errors.Is(err, gocql.ErrTimeoutNoResponse) {
-				if attempt < maxRetries {
-					backoff := time.Duration(attempt*attempt) * 100 * time.Millisecond
-					store.logger.Warnf("Transient error on bulk delete batch (attempt %d/%d), retrying after %v: %v",
-						attempt, maxRetries, backoff, err)
-					time.Sleep(backoff)
-					continue
-				}
-			}
+	// Execute batch with retry logic
+	return store.withRetry(ctx, "transaction batch", func() error {
+		return store.session.ExecuteBatch(batch)
+	})
+}
 
-			store.logger.Errorf("Failed to execute bulk delete batch after %d attempts: %v", attempt, err)
-			return fmt.Errorf("bulk delete batch failed: %w", err)
+// afterTransaction forwards the applied mutations of a transaction to the
+// features observing writes.
+func (store *ScyllaStateStore) afterTransaction(mutations []txMutation) {
+	for _, mutation := range mutations {
+		if mutation.set {
+			store.afterSet(mutation.daprKey, mutation.key, mutation.value, mutation.etag, mutation.ttl)
+			store.meterWrite(mutation.daprKey, len(mutation.value))
+			store.observeSchema(mutation.daprKey, mutation.value)
+			store.verifyWrite(mutation.daprKey, mutation.key, mutation.value, mutation.etag)
+			store.auditSet(mutation.daprKey, mutation.etag, mutation.value, nil)
+		} else {
+			store.afterDelete(mutation.key)
+			store.meterDelete(mutation.daprKey)
+			store.auditDelete(mutation.daprKey)
 		}
 	}
+}
 
-	store.logger.Debugf("BulkDelete completed for %d keys", len(req))
-	return nil
+// readETagSnapshot returns the current ETag of every existing key in keys.
+// Keys that do not exist are absent from the returned map.
+func (store *ScyllaStateStore) readETagSnapshot(ctx context.Context, keys []string) (map[string]string, error) {
+	snapshot := make(map[string]string, len(keys))
+
+	const maxBatchSize = 100 // ScyllaDB recommendation for IN queries
+	for start := 0; start < len(keys); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		batchKeys := keys[start:end]
+		query := store.inQuery("key, etag", len(batchKeys))
+
+		keyInterfaces := make([]interface{}, len(batchKeys))
+		for i, key := range batchKeys {
+			keyInterfaces[i] = key
+		}
+
+		stmt, err := store.hookedQuery(ctx, "transaction", query, keyInterfaces...)
+		if err != nil {
+			return nil, err
+		}
+		iter := stmt.Iter()
+
+		var key, etag string
+		for iter.Scan(&key, &etag) {
+			snapshot[key] = etag
+		}
+
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshot, nil
 }
 
 func (store *ScyllaStateStore) Query(ctx context.Context, req *state.QueryRequest) (*state.QueryResponse, error) {
+	defer store.observeLatency(sloOpQuery, time.Now())
+
+	// Administrative refresh swaps the session, so it runs before taking the read lock
+	if req.Metadata[refreshSchemaMetadataKey] == "true" {
+		return store.refreshSchemaQuery(req.Metadata)
+	}
+
+	// Watches long-poll, taking the read lock only while reading the change feed
+	if req.Metadata[watchMetadataKey] != "" || req.Metadata[watchPrefixMetadataKey] != "" {
+		return store.watchQuery(ctx, req.Metadata)
+	}
+
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
@@ -902,14 +2323,103 @@ func (store *ScyllaStateStore) Query(ctx context.Context, req *state.QueryReques
 
 	store.logger.Debugf("Executing query: %+v", req.Query)
 
-	// For now, implement basic key-based queries (following GoCQL examples pattern)
-	// TODO: Implement more sophisticated query parsing when needed
-	queryStr := fmt.Sprintf("SELECT key, value, etag FROM %s LIMIT 100", store.config.Table)
+	// Administrative delete of every key with a prefix
+	if prefix, ok := req.Metadata[deletePrefixMetadataKey]; ok {
+		return store.deleteByPrefixQuery(ctx, prefix, req.Metadata)
+	}
+
+	// Keyspace and table provisioning for tenant onboarding
+	if _, ok := req.Metadata[provisionMetadataKey]; ok {
+		return store.provisionQuery(ctx, req.Metadata)
+	}
+
+	// Usage report for chargeback
+	if req.Metadata[usageReportMetadataKey] == "true" {
+		return store.usageReportQuery()
+	}
+
+	// Most frequently accessed keys
+	if req.Metadata[hotKeysMetadataKey] == "true" {
+		return store.hotKeysQuery()
+	}
+
+	// Field names and types observed per key prefix
+	if req.Metadata[observedSchemaMetadataKey] == "true" {
+		return store.observedSchemaQuery()
+	}
+
+	// Table statistics for capacity planning
+	if req.Metadata[statsMetadataKey] == "true" {
+		return store.statsQuery(ctx)
+	}
+
+	// Raw CQL for administrators, when enabled
+	if statement, ok := req.Metadata[cqlMetadataKey]; ok {
+		return store.passthroughQuery(ctx, statement, req.Metadata)
+	}
+
+	// Queries are the first load to go when the store misses its latency SLO
+	ctx, err := store.admit(ctx, sloOpQuery, store.requestPriority(priorityBulk, req.Metadata))
+	if err != nil {
+		store.logger.Warnf("Shedding query while store is degraded")
+		return nil, err
+	}
+
+	// Existence and ETags only, read with a projection of the key and etag columns
+	if stateext.KeysOnly(req.Metadata) {
+		ctx = withKeysOnly(ctx)
+	}
+
+	// All keys of a group, such as one workflow instance's state
+	if group, ok := req.Metadata[keyGroupMetadataKey]; ok {
+		return store.keyGroupQuery(ctx, group, req)
+	}
+
+	// Full-text queries are answered by the search index
+	if text := req.Metadata[fullTextQueryMetadataKey]; text != "" {
+		if store.searchIndex == nil {
+			return nil, errors.New("full-text query requires searchIndexUrl to be configured")
+		}
+		return store.maskQueryResponse(store.fullTextQuery(ctx, req, text))
+	}
+
+	// Filters and sorting are not translated, so only the opt-in row listing remains
+	if store.config.QueryScan != "true" {
+		return nil, errQueryNotSupported
+	}
+
+	// Hashed partition keys cannot be mapped back to Dapr keys
+	if store.keys != nil && !store.keys.reversible() {
+		return nil, errors.New("query is not supported with keyStrategy=hash")
+	}
+
+	if store.queryCache != nil {
+		return store.maskQueryResponse(store.cachedQuery(ctx, req, func() (*state.QueryResponse, error) {
+			return store.scanQuery(ctx)
+		}))
+	}
+	return store.maskQueryResponse(store.scanQuery(ctx))
+}
+
+// scanQuery lists the first rows of the table.
+func (store *ScyllaStateStore) scanQuery(ctx context.Context) (*state.QueryResponse, error) {
+	// Filters are not translated; arbitrary CQL goes through the passthrough (cql metadata)
+	scan := newRowScan(ctx)
+	queryStr := fmt.Sprintf("SELECT %s FROM %s LIMIT 100", scan.columns(), store.config.Table)
+	var times map[string]map[string]string
+	if store.rowTimesEnabled() {
+		queryStr = fmt.Sprintf("SELECT %s, %s FROM %s LIMIT 100", scan.columns(), rowTimesColumns, store.config.Table)
+		times = make(map[string]map[string]string)
+	}
 
 	store.logger.Debugf("Executing CQL query: %s", queryStr)
 
 	// Execute the query with proper context and error handling (GoCQL best practice)
-	iter := store.session.Query(queryStr).WithContext(ctx).Iter()
+	stmt, err := store.hookedQuery(ctx, "query", queryStr)
+	if err != nil {
+		return nil, err
+	}
+	iter := stmt.Iter()
 	defer func() {
 		if err := iter.Close(); err != nil {
 			store.logger.Errorf("Error closing query iterator: %v", err)
@@ -921,17 +2431,35 @@ func (store *ScyllaStateStore) Query(ctx context.Context, req *state.QueryReques
 	// Use scanner pattern for better memory management (GoCQL best practice)
 	scanner := iter.Scanner()
 	for scanner.Next() {
-		var key, value, etag string
-		if err := scanner.Scan(&key, &value, &etag); err != nil {
+		var row rowTimes
+		dest := scan.dest()
+		if times != nil {
+			dest = append(dest, &row.ttl, &row.writeTime)
+		}
+		if err := scanner.Scan(dest...); err != nil {
 			store.logger.Errorf("Error scanning row: %v", err)
 			continue
 		}
+		key, etag, value := scan.key, scan.etag, scan.value()
 
+		// Skip rows stored under another application's key namespace
+		if store.keys != nil {
+			daprKey, ok := store.keys.fromStorage(key)
+			if !ok {
+				continue
+			}
+			key = daprKey
+		}
+
+		store.meterRead(key, len(value))
 		results = append(results, state.QueryItem{
 			Key:  key,
-			Data: []byte(value),
+			Data: value,
 			ETag: &etag,
 		})
+		if times != nil {
+			times[key] = row.metadata(store.now())
+		}
 	}
 
 	// Check for scanner errors (GoCQL best practice)
@@ -941,24 +2469,95 @@ func (store *ScyllaStateStore) Query(ctx context.Context, req *state.QueryReques
 	}
 
 	store.logger.Debugf("Query returned %d results", len(results))
-	return &state.QueryResponse{
+	response := &state.QueryResponse{
 		Results: results,
 		Token:   "", // No pagination support for now
-	}, nil
+	}
+	if times != nil {
+		response.Metadata = rowTimesResponseMetadata(times)
+	}
+	return response, nil
 }
 
 func (store *ScyllaStateStore) Close() error {
 	store.mu.Lock()
-	defer store.mu.Unlock()
-
 	if store.closed {
+		store.mu.Unlock()
 		return nil
 	}
-
 	store.closed = true
+	coalescer := store.coalescer
+	hostWatch := store.hostWatch
+	filter := store.keyFilter
+	searchIndex := store.searchIndex
+	usage := store.usage
+	workload := store.workload
+	blobMigration := store.blobMigration
+	timeBuckets := store.timeBuckets
+	changeFeed := store.changeFeed
+	tlsReloader := store.tlsReloader
+	quotas := store.quotas
+	statsGauges := store.statsGauges
+	clusterMirror := store.clusterMirror
+	store.mu.Unlock()
+
+	// Stop background workers outside the lock; they take the read lock themselves.
+	// Buffered Sets are written first, while the write hooks still run
+	if coalescer != nil {
+		coalescer.stop()
+	}
+	if hostWatch != nil {
+		hostWatch.stop()
+	}
+	if filter != nil {
+		filter.stop()
+	}
+	if searchIndex != nil {
+		// Repairs are queued for the index, so the verifier stops first
+		if searchIndex.verifier != nil {
+			searchIndex.verifier.stop()
+		}
+		searchIndex.stop()
+	}
+	if usage != nil {
+		usage.stop()
+	}
+	if workload != nil {
+		workload.stop()
+	}
+	if blobMigration != nil {
+		blobMigration.stop()
+	}
+	if timeBuckets != nil {
+		timeBuckets.stop()
+	}
+	if changeFeed != nil {
+		changeFeed.stop()
+	}
+	if tlsReloader != nil {
+		tlsReloader.stop()
+	}
+	if quotas != nil {
+		quotas.stop()
+	}
+	if statsGauges != nil {
+		statsGauges.stop()
+	}
+	if clusterMirror != nil {
+		if clusterMirror.reconciler != nil {
+			clusterMirror.reconciler.stop()
+		}
+		if clusterMirror.shadow != nil {
+			clusterMirror.shadow.stop()
+		}
+		clusterMirror.stop()
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
 
 	if store.session != nil {
-		store.session.Close()
+		store.closeSession(store.session)
 		store.session = nil
 	}
 
@@ -0,0 +1,136 @@
+package scylladb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"nebulagraph/stores/stateext"
+)
+
+func TestBulkSetError(t *testing.T) {
+	mismatch := stateext.NewETagError(state.ETagMismatch, errors.New("etag mismatch for key k"))
+
+	// The paths of BulkSet and BulkDelete, each failing with err
+	paths := map[string]func(err error) error{
+		"large bulk set": func(err error) error {
+			return bulkError(err, "bulk set failed")
+		},
+		"large bulk delete": func(err error) error {
+			return bulkError(err, "bulk delete failed")
+		},
+		"small bulk set": func(err error) error {
+			req := []state.SetRequest{{Key: "a"}, {Key: "k"}, {Key: "b"}}
+			return concurrentBulk(req, "set", func(request state.SetRequest) error {
+				if request.Key == "k" {
+					return err
+				}
+				return nil
+			})
+		},
+		"small bulk delete": func(err error) error {
+			req := []state.DeleteRequest{{Key: "a"}, {Key: "k"}, {Key: "b"}}
+			return concurrentBulk(req, "delete", func(request state.DeleteRequest) error {
+				if request.Key == "k" {
+					return err
+				}
+				return nil
+			})
+		},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"etag mismatch", mismatch, codes.FailedPrecondition},
+		{"wrapped etag mismatch", fmt.Errorf("partition failed: %w", mismatch), codes.FailedPrecondition},
+		{"invalid etag", stateext.NewETagError(state.ETagInvalid, errors.New("bad etag")), codes.InvalidArgument},
+		{"other error", errors.New("timeout"), codes.Unknown},
+	}
+	for path, fail := range paths {
+		for _, tt := range tests {
+			t.Run(path+"/"+tt.name, func(t *testing.T) {
+				err := fail(tt.err)
+				if got := status.Code(err); got != tt.want {
+					t.Errorf("status code = %s, want %s (%v)", got, tt.want, err)
+				}
+				if tt.want == codes.Unknown && !errors.Is(err, tt.err) {
+					t.Errorf("error %v does not wrap %v", err, tt.err)
+				}
+			})
+		}
+	}
+
+	if err := concurrentBulk([]state.SetRequest{{Key: "a"}}, "set", func(state.SetRequest) error { return nil }); err != nil {
+		t.Errorf("concurrentBulk() without failures = %v, want nil", err)
+	}
+}
+
+func TestCheckTransactionETags(t *testing.T) {
+	// Snapshot of the stored ETags, read once before the transaction is applied
+	snapshot := map[string]string{"app||k1": "1", "app||k2": "2"}
+	check := func(key, etag string) etagCheck {
+		return etagCheck{key: key, storageKey: "app||" + key, etag: etag}
+	}
+
+	tests := []struct {
+		name     string
+		checks   []etagCheck
+		conflict bool
+		// ETag the conflict carries, "" when the key is missing
+		currentEtag string
+	}{
+		{name: "matching etags", checks: []etagCheck{check("k1", "1"), check("k2", "2")}},
+		{name: "same key twice", checks: []etagCheck{check("k1", "1"), check("k1", "1")}},
+		// Another writer committed k2 before the snapshot was read
+		{name: "concurrent write", checks: []etagCheck{check("k1", "1"), check("k2", "1")}, conflict: true, currentEtag: "2"},
+		{name: "operations disagree on one key", checks: []etagCheck{check("k1", "1"), check("k1", "0")}, conflict: true, currentEtag: "1"},
+		{name: "etag for missing key", checks: []etagCheck{check("k1", "1"), check("k3", "3")}, conflict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransactionETags(tt.checks, snapshot)
+			if !tt.conflict {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var etagErr *stateext.ETagError
+			if !errors.As(err, &etagErr) || etagErr.Kind() != state.ETagMismatch {
+				t.Fatalf("got %v, want an ETag mismatch", err)
+			}
+			if currentEtag, ok := etagErr.CurrentETag(); currentEtag != tt.currentEtag || ok != (tt.currentEtag != "") {
+				t.Errorf("got current etag %q (%v), want %q", currentEtag, ok, tt.currentEtag)
+			}
+
+			st, _ := status.FromError(err)
+			if st.Code() != codes.FailedPrecondition {
+				t.Errorf("got code %v, want %v", st.Code(), codes.FailedPrecondition)
+			}
+			var info *errdetails.ErrorInfo
+			for _, detail := range st.Details() {
+				if detail, ok := detail.(*errdetails.ErrorInfo); ok {
+					info = detail
+				}
+			}
+			switch {
+			case tt.currentEtag == "" && info != nil:
+				t.Errorf("got ErrorInfo %v for a missing key", info)
+			case tt.currentEtag != "" && (info == nil || info.Reason != stateext.ETagMismatchReason ||
+				info.Metadata[stateext.CurrentETagMetadataKey] != tt.currentEtag):
+				t.Errorf("got ErrorInfo %v, want reason %s and %s %s",
+					info, stateext.ETagMismatchReason, stateext.CurrentETagMetadataKey, tt.currentEtag)
+			}
+		})
+	}
+}
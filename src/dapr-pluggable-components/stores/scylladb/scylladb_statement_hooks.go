@@ -0,0 +1,94 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// Statement is a CQL statement the store is about to execute for a state
+// operation. Hooks may change Query and Values in place.
+type Statement struct {
+	// Operation is the store operation issuing the statement: "get", "set",
+	// "delete", "bulk get", "bulk set", "bulk delete", "delete by prefix",
+	// "transaction" or "query".
+	Operation string
+	Query     string
+	Values    []any
+}
+
+// StatementHook observes or rewrites the statements issued by state
+// operations, for example to add tenant filters or compliance tags without
+// forking the store. Returning an error fails the operation without executing
+// the statement. Schema management and background jobs are not hooked.
+type StatementHook interface {
+	BeforeStatement(ctx context.Context, stmt *Statement) error
+}
+
+// Hooks in registration order; only written during package initialization
+var statementHooks []StatementHook
+
+// RegisterStatementHook adds a hook run before every state operation
+// statement. It is meant to be called from an init function of a package
+// compiled into the binary, and must not be called once stores are running.
+func RegisterStatementHook(hook StatementHook) {
+	statementHooks = append(statementHooks, hook)
+}
+
+// runStatementHooks passes a statement through every registered hook.
+func runStatementHooks(ctx context.Context, operation, query string, values []any) (string, []any, error) {
+	stmt := &Statement{Operation: operation, Query: query, Values: values}
+	for _, hook := range statementHooks {
+		if err := hook.BeforeStatement(ctx, stmt); err != nil {
+			return "", nil, fmt.Errorf("statement hook rejected %s: %w", operation, err)
+		}
+	}
+	return stmt.Query, stmt.Values, nil
+}
+
+// hookedQuery builds a query for an ad-hoc statement after running the hooks.
+func (store *ScyllaStateStore) hookedQuery(ctx context.Context, operation, query string, values ...any) (*gocql.Query, error) {
+	if len(statementHooks) > 0 {
+		var err error
+		if query, values, err = runStatementHooks(ctx, operation, query, values); err != nil {
+			return nil, err
+		}
+	}
+	return store.session.Query(query, values...).WithContext(ctx), nil
+}
+
+// hookedStatement binds values to one of the prepared statements. When hooks
+// are registered the statement they return is issued instead, with the
+// prepared statement's consistency.
+func (store *ScyllaStateStore) hookedStatement(ctx context.Context, operation string, prepared *gocql.Query, values ...any) (*gocql.Query, error) {
+	prepared, values, err := store.withDeadline(ctx, operation, prepared, values)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statementHooks) == 0 {
+		// WithContext copies the shared statement, so concurrent calls bind their own values
+		return prepared.WithContext(ctx).Bind(values...), nil
+	}
+
+	query, values, err := runStatementHooks(ctx, operation, prepared.Statement(), values)
+	if err != nil {
+		return nil, err
+	}
+	// Hooks may rewrite the statement but not its idempotency
+	return store.session.Query(query, values...).Consistency(prepared.GetConsistency()).
+		Idempotent(prepared.IsIdempotent()).SetSpeculativeExecutionPolicy(store.speculative).WithContext(ctx), nil
+}
+
+// addHookedBatchEntry adds a statement to batch after running the hooks.
+func (store *ScyllaStateStore) addHookedBatchEntry(ctx context.Context, batch *gocql.Batch, operation, query string, values ...any) error {
+	if len(statementHooks) > 0 {
+		var err error
+		if query, values, err = runStatementHooks(ctx, operation, query, values); err != nil {
+			return err
+		}
+	}
+	batch.Query(query, values...)
+	return nil
+}
@@ -0,0 +1,109 @@
+package scylladb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+// Request metadata key that makes a Query return table statistics
+const statsMetadataKey = "stats"
+
+// Upper bound for the statistics queries, which scan the table
+const statsTimeout = 2 * time.Minute
+
+// Stats returns capacity figures for the state table: approximate key count
+// and size from system.size_estimates, and the oldest and newest
+// last_modified. The estimates are refreshed by ScyllaDB periodically and
+// cover only the node that answers, so they are scaled by the number of nodes
+// and the replication factor; the last_modified range needs a full table scan.
+func (store *ScyllaStateStore) Stats(ctx context.Context) (map[string]any, error) {
+	store.mu.RLock()
+	session := store.session
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || session == nil {
+		return nil, errors.New("store is closed")
+	}
+	return store.tableStats(ctx, session)
+}
+
+func (store *ScyllaStateStore) tableStats(ctx context.Context, session *gocql.Session) (map[string]any, error) {
+	ctx, cancel := context.WithTimeout(ctx, statsTimeout)
+	defer cancel()
+
+	keys, bytes, err := store.sizeEstimates(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldest, newest time.Time
+	rangeQuery := fmt.Sprintf("SELECT min(last_modified), max(last_modified) FROM %s", store.config.Table)
+	if err := session.Query(rangeQuery).WithContext(ctx).Scan(&oldest, &newest); err != nil {
+		return nil, fmt.Errorf("failed to read last_modified range: %w", err)
+	}
+
+	stats := map[string]any{
+		"keyspace":         store.config.Keyspace,
+		"table":            store.config.Table,
+		"approximateKeys":  keys,
+		"approximateBytes": bytes,
+	}
+
+	if !oldest.IsZero() {
+		stats["oldestLastModified"] = oldest.UTC()
+		stats["newestLastModified"] = newest.UTC()
+	}
+	return stats, nil
+}
+
+// sizeEstimates returns the approximate key count and size of the state
+// table across the cluster. Every key is its own partition.
+func (store *ScyllaStateStore) sizeEstimates(ctx context.Context, session *gocql.Session) (keys, bytes int64, err error) {
+	// size_estimates only covers the token ranges owned by the answering node
+	var partitions, meanSize int64
+	estimates := session.Query(
+		"SELECT partitions_count, mean_partition_size FROM system.size_estimates WHERE keyspace_name = ? AND table_name = ?",
+		store.config.Keyspace, store.config.Table).WithContext(ctx).Iter()
+	for estimates.Scan(&partitions, &meanSize) {
+		keys += partitions
+		bytes += partitions * meanSize
+	}
+	if err := estimates.Close(); err != nil {
+		return 0, 0, fmt.Errorf("failed to read size estimates: %w", err)
+	}
+
+	// Every key is stored on replicationFactor of the nodes
+	_, nodes := store.AvailableHosts()
+	replicationFactor, err := strconv.ParseInt(store.config.ReplicationFactor, 10, 64)
+	if nodes > 0 && err == nil && replicationFactor > 0 {
+		keys = keys * int64(nodes) / replicationFactor
+		bytes = bytes * int64(nodes) / replicationFactor
+	}
+	return keys, bytes, nil
+}
+
+// statsQuery answers a Query carrying the stats metadata with one item holding
+// the statistics as JSON.
+func (store *ScyllaStateStore) statsQuery(ctx context.Context) (*state.QueryResponse, error) {
+	stats, err := store.tableStats(ctx, store.session)
+	if err != nil {
+		store.logger.Errorf("Stats query failed: %v", err)
+		return nil, err
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	return &state.QueryResponse{
+		Results: []state.QueryItem{{Key: store.config.Table, Data: data}},
+	}, nil
+}
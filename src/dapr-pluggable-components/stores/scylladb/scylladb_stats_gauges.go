@@ -0,0 +1,194 @@
+package scylladb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sizeSample is one reading of the table's size estimates.
+type sizeSample struct {
+	keys      int64
+	bytes     int64
+	sampledAt time.Time
+}
+
+// statsGauges samples the state table's size estimates periodically so they
+// can be scraped as Prometheus gauges. Unlike Stats, it reads only
+// system.size_estimates and never scans the table, so it is cheap enough to
+// run on every replica.
+type statsGauges struct {
+	interval time.Duration
+	latest   atomic.Pointer[sizeSample]
+	failures atomic.Int64
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// initStatsGauges parses the sampling interval and starts the sampling loop.
+func (store *ScyllaStateStore) initStatsGauges() {
+	interval, err := time.ParseDuration(store.config.StatsInterval)
+	if err != nil || interval <= 0 {
+		store.logger.Warnf("Invalid statsInterval: %s, using default", store.config.StatsInterval)
+		interval = 5 * time.Minute
+	}
+
+	gauges := &statsGauges{
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+	store.statsGauges = gauges
+
+	store.logger.Infof("Sampling size estimates of %s.%s every %v", store.config.Keyspace, store.config.Table, interval)
+
+	gauges.wg.Add(1)
+	go func() {
+		defer gauges.wg.Done()
+
+		ticker := time.NewTicker(gauges.interval)
+		defer ticker.Stop()
+
+		for {
+			store.sampleSizeEstimates(gauges)
+			select {
+			case <-gauges.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (store *ScyllaStateStore) sampleSizeEstimates(gauges *statsGauges) {
+	store.mu.RLock()
+	session := store.session
+	closed := store.closed
+	store.mu.RUnlock()
+
+	if closed || session == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	keys, bytes, err := store.sizeEstimates(ctx, session)
+	if err != nil {
+		gauges.failures.Add(1)
+		store.logger.Warnf("Failed to sample size estimates: %v", err)
+		return
+	}
+	gauges.latest.Store(&sizeSample{keys: keys, bytes: bytes, sampledAt: time.Now()})
+}
+
+func (g *statsGauges) stop() {
+	close(g.stopCh)
+	g.wg.Wait()
+}
+
+// StatsGauges returns the latest size estimates and the counters of the write
+// mirror, the key locks, the change feed, overload shedding, the stale cache,
+// conflict merges, hot keys, write coalescing and search index verification
+// as gauge values, and the labels identifying the table. Values are nil when
+// none of them is enabled.
+func (store *ScyllaStateStore) StatsGauges() (map[string]string, map[string]float64) {
+	store.mu.RLock()
+	gauges := store.statsGauges
+	mirror := store.clusterMirror
+	locks := store.keyLocks
+	feed := store.changeFeed
+	detector := store.overload
+	stale := store.staleCache
+	merger := store.merger
+	hot := store.hotKeys
+	coalescer := store.coalescer
+	auditor := store.auditor
+	var verifier *searchIndexVerifier
+	if store.searchIndex != nil {
+		verifier = store.searchIndex.verifier
+	}
+	labels := map[string]string{"keyspace": store.config.Keyspace, "table": store.config.Table}
+	store.mu.RUnlock()
+
+	if gauges == nil && mirror == nil && locks == nil && feed == nil && detector == nil && stale == nil && merger == nil && hot == nil && coalescer == nil && auditor == nil && verifier == nil {
+		return nil, nil
+	}
+	values := make(map[string]float64)
+	if mirror != nil {
+		for name, value := range mirror.gauges() {
+			values[name] = value
+		}
+	}
+	if locks != nil {
+		for name, value := range locks.gauges() {
+			values[name] = value
+		}
+	}
+	if feed != nil {
+		for name, value := range feed.gauges() {
+			values[name] = value
+		}
+	}
+	if detector != nil {
+		for name, value := range detector.gauges() {
+			values[name] = value
+		}
+	}
+	if stale != nil {
+		for name, value := range stale.gauges() {
+			values[name] = value
+		}
+	}
+	if merger != nil {
+		for name, value := range merger.gauges() {
+			values[name] = value
+		}
+	}
+	if hot != nil {
+		for name, value := range hot.gauges() {
+			values[name] = value
+		}
+	}
+	if coalescer != nil {
+		for name, value := range coalescer.gauges() {
+			values[name] = value
+		}
+	}
+	if auditor != nil {
+		for name, value := range auditor.gauges() {
+			values[name] = value
+		}
+	}
+	if verifier != nil {
+		for name, value := range verifier.gauges() {
+			values[name] = value
+		}
+	}
+	if gauges == nil {
+		return labels, values
+	}
+
+	values["stats_sample_failures_total"] = float64(gauges.failures.Load())
+	if sample := gauges.latest.Load(); sample != nil {
+		// One row per key, so rows and partitions are the same figure
+		values["estimated_rows"] = float64(sample.keys)
+		values["estimated_partitions"] = float64(sample.keys)
+		values["estimated_bytes"] = float64(sample.bytes)
+		values["stats_sampled_timestamp_seconds"] = float64(sample.sampledAt.Unix())
+	}
+	return labels, values
+}
+
+func (g *statsGauges) diagnostics() map[string]any {
+	diagnostics := map[string]any{
+		"interval": g.interval.String(),
+		"failures": g.failures.Load(),
+	}
+	if sample := g.latest.Load(); sample != nil {
+		diagnostics["keys"] = sample.keys
+		diagnostics["bytes"] = sample.bytes
+		diagnostics["sampledAt"] = sample.sampledAt.UTC()
+	}
+	return diagnostics
+}
@@ -0,0 +1,427 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+
+	"nebulagraph/stores/stateext"
+)
+
+// Ephemeral workloads such as sessions and telemetry can write into time
+// buckets. With timeBuckets=daily or weekly, Set writes to one table per
+// bucket, <table>_d20261016 or <table>_w2026_42 (ISO week, UTC), and a bucket
+// expires as a whole when its table is dropped, which costs one schema change
+// instead of a tombstone per key. Get returns the key from the newest bucket
+// holding it and Delete removes it from every bucket that is kept. Only the
+// newest timeBucketRetention buckets, the current one included, are read and
+// kept; a janitor creates the next bucket ahead of time and drops older ones.
+
+// Bucketing modes of timeBuckets
+const (
+	timeBucketsDaily  = "daily"
+	timeBucketsWeekly = "weekly"
+)
+
+// How often the janitor creates the next bucket and drops expired ones
+const timeBucketJanitorInterval = 10 * time.Minute
+
+var errTimeBucketsMulti = errors.New("transactions are not supported with timeBuckets: keys of one transaction may live in different bucket tables")
+
+// Options that read or write the base table directly
+var timeBucketConflicts = []struct {
+	name    string
+	enabled func(cfg *ScyllaConfig) bool
+}{
+	{"bloomFilter", func(cfg *ScyllaConfig) bool { return cfg.BloomFilter == "true" }},
+	{"blobMigration", func(cfg *ScyllaConfig) bool { return cfg.BlobMigration == "true" }},
+	{"verifyWrites", func(cfg *ScyllaConfig) bool { return cfg.VerifyWrites == "true" }},
+	{"queryScan", func(cfg *ScyllaConfig) bool { return cfg.QueryScan == "true" }},
+	{"mirrorHosts", func(cfg *ScyllaConfig) bool { return cfg.MirrorHosts != "" }},
+	{"keyGroups", func(cfg *ScyllaConfig) bool { return cfg.KeyGroups == "true" }},
+	// Bucket tables are created and dropped as they rotate
+	{"createSchema=false", func(cfg *ScyllaConfig) bool { return cfg.CreateSchema != "true" }},
+}
+
+// timeBuckets holds the bucketing settings and the bucket tables known to exist.
+type timeBuckets struct {
+	mode      string
+	retention int
+	pattern   *regexp.Regexp // names of bucket tables of this mode
+
+	mu     sync.Mutex
+	tables map[string]*bucketQueries // bucket tables created by this replica
+
+	created atomic.Int64
+	dropped atomic.Int64
+	job     *backgroundJob
+}
+
+// bucketQueries holds the statement texts of one bucket table.
+type bucketQueries struct {
+	get    string
+	set    string
+	delete string
+}
+
+// initTimeBuckets parses the bucketing settings. Options that bypass the
+// bucket tables are rejected.
+func (store *ScyllaStateStore) initTimeBuckets() error {
+	mode := strings.ToLower(store.config.TimeBuckets)
+	var suffix string
+	switch mode {
+	case timeBucketsDaily:
+		suffix = `d\d{8}`
+	case timeBucketsWeekly:
+		suffix = `w\d{4}_\d{2}`
+	default:
+		return fmt.Errorf("invalid timeBuckets: %s (expected %s or %s)", store.config.TimeBuckets, timeBucketsDaily, timeBucketsWeekly)
+	}
+	for _, conflict := range timeBucketConflicts {
+		if conflict.enabled(&store.config) {
+			return fmt.Errorf("timeBuckets cannot be combined with %s", conflict.name)
+		}
+	}
+
+	retention, err := strconv.Atoi(store.config.TimeBucketRetention)
+	if err != nil || retention <= 0 {
+		store.logger.Warnf("Invalid timeBucketRetention: %s, using default", store.config.TimeBucketRetention)
+		retention = 7
+	}
+
+	store.timeBuckets = &timeBuckets{
+		mode:      mode,
+		retention: retention,
+		pattern:   regexp.MustCompile(`^` + regexp.QuoteMeta(strings.ToLower(store.config.Table)) + `_` + suffix + `$`),
+		tables:    make(map[string]*bucketQueries),
+	}
+	store.logger.Infof("Writing keys into %s buckets (keeping %d)", mode, retention)
+	return nil
+}
+
+// startTimeBuckets creates the kept buckets and starts the janitor. With
+// leader election enabled only the replica holding the timeBuckets lease
+// creates and drops buckets ahead of time; every replica still creates the
+// buckets it needs on first use.
+func (store *ScyllaStateStore) startTimeBuckets() error {
+	buckets := store.timeBuckets
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := store.bucketTables(ctx, store.now()); err != nil {
+		return err
+	}
+
+	buckets.job = store.startBackgroundJob("timeBuckets", func(ctx context.Context) {
+		ticker := time.NewTicker(timeBucketJanitorInterval)
+		defer ticker.Stop()
+		for {
+			if err := store.maintainTimeBuckets(ctx, store.now()); err != nil && ctx.Err() == nil {
+				store.logger.Warnf("Time bucket maintenance failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+	return nil
+}
+
+// bucketName returns the table of the bucket holding t.
+func (b *timeBuckets) bucketName(table string, t time.Time) string {
+	t = t.UTC()
+	if b.mode == timeBucketsWeekly {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%s_w%04d_%02d", table, year, week)
+	}
+	return fmt.Sprintf("%s_d%s", table, t.Format("20060102"))
+}
+
+// period returns the length of one bucket.
+func (b *timeBuckets) period() time.Duration {
+	if b.mode == timeBucketsWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// liveBuckets returns the names of the kept buckets at now, newest first.
+func (b *timeBuckets) liveBuckets(table string, now time.Time) []string {
+	names := make([]string, b.retention)
+	for i := range names {
+		names[i] = b.bucketName(table, now.Add(-time.Duration(i)*b.period()))
+	}
+	return names
+}
+
+// bucketTables returns the statements of the kept buckets at now, newest
+// first, creating the tables this replica has not seen yet.
+func (store *ScyllaStateStore) bucketTables(ctx context.Context, now time.Time) ([]*bucketQueries, error) {
+	names := store.timeBuckets.liveBuckets(store.config.Table, now)
+	tables := make([]*bucketQueries, len(names))
+	for i, name := range names {
+		queries, err := store.ensureBucketTable(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = queries
+	}
+	return tables, nil
+}
+
+// ensureBucketTable creates the bucket table name unless this replica already
+// did, and returns its statements.
+func (store *ScyllaStateStore) ensureBucketTable(ctx context.Context, name string) (*bucketQueries, error) {
+	buckets := store.timeBuckets
+	buckets.mu.Lock()
+	defer buckets.mu.Unlock()
+
+	if queries, ok := buckets.tables[name]; ok {
+		return queries, nil
+	}
+
+	store.logger.Infof("Creating time bucket table %s", name)
+	if err := store.session.Query(stateTableDDL(name)).WithContext(ctx).Exec(); err != nil {
+		return nil, fmt.Errorf("failed to create time bucket table %s: %w", name, err)
+	}
+	buckets.created.Add(1)
+
+	queries := &bucketQueries{
+		get:    fmt.Sprintf("SELECT value, value_blob, etag FROM %s WHERE key = ?", name),
+		set:    fmt.Sprintf("INSERT INTO %s (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?) USING TTL ?", name),
+		delete: fmt.Sprintf("DELETE FROM %s WHERE key = ?", name),
+	}
+	buckets.tables[name] = queries
+	return queries, nil
+}
+
+// maintainTimeBuckets creates the kept buckets and the next one, so writes
+// after the rollover do not wait for a schema change, and drops the buckets
+// older than the kept ones.
+func (store *ScyllaStateStore) maintainTimeBuckets(ctx context.Context, now time.Time) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed || store.session == nil {
+		return errors.New("store is closed")
+	}
+
+	buckets := store.timeBuckets
+	if _, err := store.bucketTables(ctx, now); err != nil {
+		return err
+	}
+	if _, err := store.ensureBucketTable(ctx, buckets.bucketName(store.config.Table, now.Add(buckets.period()))); err != nil {
+		return err
+	}
+
+	expired, err := store.expiredBuckets(ctx, store.session, now)
+	if err != nil {
+		return err
+	}
+	for _, name := range expired {
+		store.logger.Infof("Dropping expired time bucket table %s", name)
+		if err := store.session.Query(fmt.Sprintf("DROP TABLE IF EXISTS %s", name)).WithContext(ctx).Exec(); err != nil {
+			return fmt.Errorf("failed to drop time bucket table %s: %w", name, err)
+		}
+		buckets.dropped.Add(1)
+
+		buckets.mu.Lock()
+		delete(buckets.tables, name)
+		buckets.mu.Unlock()
+	}
+	return nil
+}
+
+// expiredBuckets returns the bucket tables of the keyspace older than the kept
+// ones at now.
+func (store *ScyllaStateStore) expiredBuckets(ctx context.Context, session *gocql.Session, now time.Time) ([]string, error) {
+	buckets := store.timeBuckets
+	live := buckets.liveBuckets(store.config.Table, now)
+	oldest := strings.ToLower(live[len(live)-1])
+
+	iter := session.Query("SELECT table_name FROM system_schema.tables WHERE keyspace_name = ?",
+		store.config.Keyspace).WithContext(ctx).Iter()
+	var expired []string
+	var name string
+	for iter.Scan(&name) {
+		// Bucket names of one mode sort by time
+		if buckets.pattern.MatchString(name) && name < oldest {
+			expired = append(expired, name)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to list bucket tables: %w", err)
+	}
+	return expired, nil
+}
+
+// bucketRow reads key from the newest bucket holding it. It returns
+// gocql.ErrNotFound when no kept bucket does.
+func (store *ScyllaStateStore) bucketRow(ctx context.Context, daprKey, key string) (storedRow, error) {
+	var row storedRow
+	tables, err := store.bucketTables(ctx, store.now())
+	if err != nil {
+		return row, err
+	}
+
+	for _, table := range tables {
+		stmt, err := store.hookedQuery(ctx, "get", table.get, key)
+		if err != nil {
+			return row, err
+		}
+		err = store.withRetry(ctx, fmt.Sprintf("get key %s", daprKey), func() error {
+			return stmt.Idempotent(true).Scan(&row.text, &row.blob, &row.etag)
+		})
+		if err != gocql.ErrNotFound {
+			return row, err
+		}
+	}
+	return row, gocql.ErrNotFound
+}
+
+// bucketGet is Get for bucketed stores. Conditional reads are not supported,
+// so the value is always returned.
+func (store *ScyllaStateStore) bucketGet(ctx context.Context, req *state.GetRequest, key string) (*state.GetResponse, error) {
+	row, err := store.bucketRow(ctx, req.Key, key)
+	if err == gocql.ErrNotFound {
+		return &state.GetResponse{}, nil
+	}
+	if err != nil {
+		store.logger.Errorf("Failed to get key %s: %v", req.Key, err)
+		return nil, fmt.Errorf("failed to get key %s: %w", req.Key, err)
+	}
+
+	value := storedBytes(row.text, row.blob)
+	store.meterRead(req.Key, len(value))
+
+	response := &state.GetResponse{
+		Data: value,
+		ETag: &row.etag,
+	}
+	if store.masker != nil {
+		response.Data = store.masker.mask(response.Data)
+	}
+	return response, nil
+}
+
+// bucketSet is Set for bucketed stores: the value is written to the current
+// bucket. Older buckets keep their version of the key until they expire, but
+// reads prefer the newest bucket.
+func (store *ScyllaStateStore) bucketSet(ctx context.Context, req *state.SetRequest, key, value string, ttl int) error {
+	if err := rejectMergePatch("set", req.Key, req.Metadata); err != nil {
+		return err
+	}
+
+	if req.ETag != nil {
+		current, err := store.bucketRow(ctx, req.Key, key)
+		if err != nil && err != gocql.ErrNotFound {
+			return fmt.Errorf("failed to check current etag: %w", err)
+		}
+		if err == nil && current.etag != *req.ETag {
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", req.Key, *req.ETag, current.etag), current.etag)
+		}
+	}
+
+	table, err := store.ensureBucketTable(ctx, store.timeBuckets.bucketName(store.config.Table, store.now()))
+	if err != nil {
+		return err
+	}
+
+	etag := store.newETag()
+	stmt, err := store.hookedQuery(ctx, "set", table.set, key, stringToBytes(value), etag, store.now(), ttl)
+	if err != nil {
+		return err
+	}
+	stmt = stmt.Idempotent(true)
+	if req.ETag == nil {
+		stmt = store.withWriteTimestamp(stmt)
+	}
+
+	if err := store.withRetry(ctx, fmt.Sprintf("set key %s", req.Key), stmt.Exec); err != nil {
+		store.logger.Errorf("Failed to set key %s: %v", req.Key, err)
+		return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+	}
+
+	store.afterSet(req.Key, key, value, etag, ttl)
+	store.meterWrite(req.Key, len(value))
+	store.observeSchema(req.Key, value)
+	store.auditSet(req.Key, etag, value, nil)
+	return nil
+}
+
+// bucketDelete is Delete for bucketed stores: the key is removed from every
+// kept bucket, so an older version cannot reappear.
+func (store *ScyllaStateStore) bucketDelete(ctx context.Context, req *state.DeleteRequest, key string) error {
+	if req.ETag != nil || !store.ignoreNotFound(req.Metadata) {
+		current, err := store.bucketRow(ctx, req.Key, key)
+		if err == gocql.ErrNotFound {
+			if store.ignoreNotFound(req.Metadata) {
+				return nil
+			}
+			return keyNotFoundError(req.Key)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check current etag: %w", err)
+		}
+		if req.ETag != nil && current.etag != *req.ETag {
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", req.Key, *req.ETag, current.etag), current.etag)
+		}
+	}
+
+	if store.isDryRun(req.Metadata) {
+		store.logger.Infof("DRY RUN: would delete key %s", req.Key)
+		return nil
+	}
+
+	tables, err := store.bucketTables(ctx, store.now())
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		stmt, err := store.hookedQuery(ctx, "delete", table.delete, key)
+		if err != nil {
+			return err
+		}
+		if err := store.withRetry(ctx, fmt.Sprintf("delete key %s", req.Key), stmt.Idempotent(true).Exec); err != nil {
+			store.logger.Errorf("Failed to delete key %s: %v", req.Key, err)
+			return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+		}
+	}
+
+	store.afterDelete(key)
+	store.meterDelete(req.Key)
+	store.auditDelete(req.Key)
+	return nil
+}
+
+func (b *timeBuckets) stop() {
+	if b.job != nil {
+		b.job.stop()
+	}
+}
+
+func (b *timeBuckets) diagnostics(table string, now time.Time) map[string]any {
+	b.mu.Lock()
+	known := len(b.tables)
+	b.mu.Unlock()
+	return map[string]any{
+		"mode":          b.mode,
+		"retention":     b.retention,
+		"currentBucket": b.bucketName(table, now),
+		"knownTables":   known,
+		"created":       b.created.Load(),
+		"dropped":       b.dropped.Load(),
+	}
+}
@@ -0,0 +1,194 @@
+package scylladb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// tlsReloader watches the CA, certificate and key files and rebuilds the
+// session when they change, so certificates renewed by cert-manager (or any
+// tool rewriting the mounted files) are picked up without a restart.
+type tlsReloader struct {
+	caFile, certFile, keyFile string
+	serverName                string
+	hostVerification          bool
+	interval                  time.Duration
+
+	// Digest of the files the current session was built from
+	digest [sha256.Size]byte
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// initTLS configures TLS on cluster when enabled and keeps the settings for
+// reloading. It fails when the files cannot be loaded, so a misconfigured
+// component does not silently fall back to plaintext.
+func (store *ScyllaStateStore) initTLS(cluster *gocql.ClusterConfig) error {
+	if store.config.TLS != "true" && store.config.TLSCAFile == "" && store.config.TLSCertFile == "" {
+		return nil
+	}
+	if (store.config.TLSCertFile == "") != (store.config.TLSKeyFile == "") {
+		return errors.New("tlsCertFile and tlsKeyFile must be set together")
+	}
+
+	interval, err := time.ParseDuration(store.config.TLSReloadInterval)
+	if err != nil || interval < 0 {
+		store.logger.Warnf("Invalid tlsReloadInterval: %s, using default", store.config.TLSReloadInterval)
+		interval = time.Minute
+	}
+
+	reloader := &tlsReloader{
+		caFile:           store.config.TLSCAFile,
+		certFile:         store.config.TLSCertFile,
+		keyFile:          store.config.TLSKeyFile,
+		serverName:       store.config.TLSServerName,
+		hostVerification: store.config.TLSHostVerification != "false",
+		interval:         interval,
+		stopCh:           make(chan struct{}),
+	}
+
+	sslOpts, digest, err := reloader.load()
+	if err != nil {
+		return err
+	}
+	cluster.SslOpts = sslOpts
+	reloader.digest = digest
+	store.tlsReloader = reloader
+
+	if !reloader.hostVerification {
+		store.logger.Warnf("TLS host verification is disabled; server certificates are not checked")
+	}
+	store.logger.Infof("TLS enabled (caFile=%q, certFile=%q, reloadInterval=%v)", reloader.caFile, reloader.certFile, interval)
+	return nil
+}
+
+// load reads the configured files and returns the driver's TLS options along
+// with a digest of the file contents.
+func (r *tlsReloader) load() (*gocql.SslOptions, [sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+	contents, err := r.readFiles()
+	if err != nil {
+		return nil, digest, err
+	}
+	caPEM, certPEM, keyPEM := contents[0], contents[1], contents[2]
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: r.serverName,
+		// Host verification is applied by the driver through EnableHostVerification
+		InsecureSkipVerify: !r.hostVerification,
+	}
+	if caPEM != nil {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, digest, fmt.Errorf("no CA certificates found in %s", r.caFile)
+		}
+	}
+	if certPEM != nil {
+		certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, digest, fmt.Errorf("failed to load client certificate %s: %w", r.certFile, err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return &gocql.SslOptions{Config: config, EnableHostVerification: r.hostVerification}, digestOf(contents), nil
+}
+
+// readFiles returns the CA, certificate and key file contents, nil for the
+// files that are not configured.
+func (r *tlsReloader) readFiles() ([3][]byte, error) {
+	var contents [3][]byte
+	for i, path := range []string{r.caFile, r.certFile, r.keyFile} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return contents, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		contents[i] = data
+	}
+	return contents, nil
+}
+
+func digestOf(contents [3][]byte) [sha256.Size]byte {
+	return sha256.Sum256(bytes.Join(contents[:], []byte{0}))
+}
+
+// startTLSReload polls the TLS files every reload interval. When their
+// contents change and the new files load, the TLS options are replaced and
+// the session is rebuilt; in-flight operations finish on the old connections.
+// Files that fail to load, e.g. while a rotation is half written, are retried
+// on the next tick and the current connections stay in use.
+func (store *ScyllaStateStore) startTLSReload() {
+	reloader := store.tlsReloader
+	if reloader.interval == 0 {
+		return
+	}
+
+	reloader.wg.Add(1)
+	go func() {
+		defer reloader.wg.Done()
+
+		ticker := time.NewTicker(reloader.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-reloader.stopCh:
+				return
+			case <-ticker.C:
+				store.reloadTLS()
+			}
+		}
+	}()
+}
+
+func (store *ScyllaStateStore) reloadTLS() {
+	reloader := store.tlsReloader
+	contents, err := reloader.readFiles()
+	if err != nil {
+		store.logger.Warnf("TLS reload skipped: %v", err)
+		return
+	}
+	if digestOf(contents) == reloader.digest {
+		return
+	}
+
+	sslOpts, digest, err := reloader.load()
+	if err != nil {
+		store.logger.Warnf("TLS files changed but cannot be loaded, keeping current connections: %v", err)
+		return
+	}
+
+	store.logger.Info("TLS files changed, rebuilding session with the new certificates")
+	store.mu.Lock()
+	previous := store.cluster.SslOpts
+	store.cluster.SslOpts = sslOpts
+	store.mu.Unlock()
+
+	if err := store.refreshSession(); err != nil {
+		store.logger.Errorf("Session rebuild after TLS change failed, keeping current connections: %v", err)
+		store.mu.Lock()
+		store.cluster.SslOpts = previous
+		store.mu.Unlock()
+		return
+	}
+	reloader.digest = digest
+}
+
+func (r *tlsReloader) stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
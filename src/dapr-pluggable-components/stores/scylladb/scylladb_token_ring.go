@@ -0,0 +1,140 @@
+package scylladb
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// murmur3Token returns the token of a partition key under the Murmur3
+// partitioner, the default of ScyllaDB and Cassandra: the first half of
+// MurmurHash3_x64_128 with seed 0, including Cassandra's sign extension of
+// the tail bytes.
+func murmur3Token(key []byte) int64 {
+	const (
+		c1 = 0x87c37b91114253d5
+		c2 = 0x4cf5ad432745937f
+	)
+	var h1, h2 uint64
+
+	n := len(key) / 16
+	for i := 0; i < n; i++ {
+		k1 := binary.LittleEndian.Uint64(key[i*16:])
+		k2 := binary.LittleEndian.Uint64(key[i*16+8:])
+
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	// Tail bytes are sign-extended, as in Cassandra's implementation
+	tail := key[n*16:]
+	var k1, k2 uint64
+	for i := len(tail) - 1; i >= 8; i-- {
+		k2 ^= uint64(int64(int8(tail[i]))) << ((i - 8) * 8)
+	}
+	if len(tail) > 8 {
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+	}
+	for i := min(len(tail), 8) - 1; i >= 0; i-- {
+		k1 ^= uint64(int64(int8(tail[i]))) << (i * 8)
+	}
+	if len(tail) > 0 {
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+	}
+
+	h1 ^= uint64(len(key))
+	h2 ^= uint64(len(key))
+	h1 += h2
+	h2 += h1
+	h1 = fmix64(h1)
+	h2 = fmix64(h2)
+	h1 += h2
+	return int64(h1)
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// tokenRing maps tokens to the host owning them: a key belongs to the host
+// with the smallest token not below the key's, wrapping around to the first.
+type tokenRing struct {
+	tokens []int64
+	hosts  []string // owner of tokens[i]
+}
+
+// tokenRing returns the ring of the hosts known to the driver, or nil when
+// the driver reported no tokens, e.g. for a partitioner other than Murmur3.
+func (t *hostTracker) tokenRing() *tokenRing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	set := t.active
+	if set.ring != nil || len(set.tokens) == 0 {
+		return set.ring
+	}
+
+	type entry struct {
+		token int64
+		host  string
+	}
+	var entries []entry
+	for host, tokens := range set.tokens {
+		for _, token := range tokens {
+			if value, err := strconv.ParseInt(token, 10, 64); err == nil {
+				entries = append(entries, entry{value, host})
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].token < entries[j].token })
+
+	ring := &tokenRing{tokens: make([]int64, len(entries)), hosts: make([]string, len(entries))}
+	for i, e := range entries {
+		ring.tokens[i], ring.hosts[i] = e.token, e.host
+	}
+	set.ring = ring
+	return ring
+}
+
+// owner returns the host owning token.
+func (r *tokenRing) owner(token int64) string {
+	return r.hosts[r.rangeOf(token)]
+}
+
+// rangeOf returns the index of the token range holding token. Tokens of one
+// range have the same replicas.
+func (r *tokenRing) rangeOf(token int64) int {
+	i := sort.Search(len(r.tokens), func(i int) bool { return r.tokens[i] >= token })
+	if i == len(r.tokens) {
+		i = 0
+	}
+	return i
+}
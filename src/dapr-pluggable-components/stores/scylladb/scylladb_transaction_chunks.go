@@ -0,0 +1,351 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Modes of transactionCompensation
+const (
+	compensationRollback = "rollback"
+	compensationReport   = "report"
+)
+
+// Bytes counted per mutation besides its key, value and ETag: column names,
+// timestamps and the batch framing
+const mutationOverhead = 64
+
+// Upper bound for rolling back the applied chunks of a failed transaction,
+// which runs after the request context may have ended
+const compensationTimeout = 30 * time.Second
+
+// txMutation is one prepared operation of a transaction.
+type txMutation struct {
+	daprKey string
+	key     string // storage key
+	set     bool
+	value   string
+	etag    string // ETag written by a set
+	ttl     int
+}
+
+// size estimates the bytes the mutation adds to a batch.
+func (m txMutation) size() int {
+	return len(m.key) + len(m.value) + len(m.etag) + mutationOverhead
+}
+
+// transactionChunking applies transactions larger than the batch size limit
+// of the database as several logged batches. Each chunk is atomic, the
+// transaction as a whole is not: before a chunk is applied, the current rows
+// of the keys it writes are recorded, and when a later chunk fails the
+// applied chunks are rolled back to them (rollback) or the failure lists the
+// applied keys (report); rows are only recorded for rollback. Rollback is best effort: a key written by someone
+// else since the transaction touched it is left alone and reported, and the
+// record lives in memory, so a replica that stops mid-transaction cannot roll
+// back.
+type transactionChunking struct {
+	maxBytes int
+	rollback bool
+
+	chunked    atomic.Int64
+	failed     atomic.Int64
+	rolledBack atomic.Int64
+	leftBehind atomic.Int64
+	uncertain  atomic.Int64
+}
+
+// initTransactionChunking parses the chunking settings; chunking stays
+// disabled without transactionChunkBytes.
+func (store *ScyllaStateStore) initTransactionChunking() {
+	maxBytes, err := strconv.Atoi(store.config.TransactionChunkBytes)
+	if err != nil || maxBytes < 0 {
+		store.logger.Warnf("Invalid transactionChunkBytes: %s, using default", store.config.TransactionChunkBytes)
+		maxBytes = 0
+	}
+	if maxBytes == 0 {
+		return
+	}
+
+	mode := strings.ToLower(store.config.TransactionCompensation)
+	if mode != compensationRollback && mode != compensationReport {
+		store.logger.Warnf("Invalid transactionCompensation: %s, using default", store.config.TransactionCompensation)
+		mode = compensationRollback
+	}
+	store.txChunking = &transactionChunking{maxBytes: maxBytes, rollback: mode == compensationRollback}
+	store.logger.Infof("Transactions over %d bytes are applied in chunks (compensation: %s)", maxBytes, mode)
+}
+
+// split returns the mutations in chunks of at most maxBytes each, keeping
+// their order. A mutation larger than maxBytes is a chunk of its own.
+func (c *transactionChunking) split(mutations []txMutation) [][]txMutation {
+	var chunks [][]txMutation
+	start, size := 0, 0
+	for i, mutation := range mutations {
+		if i > start && size+mutation.size() > c.maxBytes {
+			chunks = append(chunks, mutations[start:i])
+			start, size = i, 0
+		}
+		size += mutation.size()
+	}
+	return append(chunks, mutations[start:])
+}
+
+func (c *transactionChunking) diagnostics() map[string]any {
+	mode := compensationReport
+	if c.rollback {
+		mode = compensationRollback
+	}
+	return map[string]any{
+		"maxBytes":          c.maxBytes,
+		"compensation":      mode,
+		"chunked":           c.chunked.Load(),
+		"failed":            c.failed.Load(),
+		"rolledBackKeys":    c.rolledBack.Load(),
+		"notRolledBackKeys": c.leftBehind.Load(),
+		"uncertainKeys":     c.uncertain.Load(),
+	}
+}
+
+// ChunkedTransactionError reports a transaction applied in chunks that failed
+// part way, with the keys of its operations by outcome, in operation order.
+type ChunkedTransactionError struct {
+	Chunks int // chunks of the transaction
+	Failed int // the failed chunk, from 1
+	// Operations applied and still in effect
+	Applied []string
+	// Operations applied, then restored to the rows before the transaction
+	RolledBack []string
+	// Operations not applied: the failed chunk and those after it
+	Unapplied []string
+	// Operations of a chunk that timed out, which the batch log may still
+	// apply, and operations whose rollback failed without a known outcome
+	Uncertain []string
+	Err       error
+}
+
+func (e *ChunkedTransactionError) Error() string {
+	list := func(keys []string) string {
+		if len(keys) == 0 {
+			return "none"
+		}
+		return strings.Join(keys, ",")
+	}
+	return fmt.Sprintf("transaction failed in chunk %d of %d: %v; applied: %s; rolled back: %s; unapplied: %s; uncertain: %s",
+		e.Failed, e.Chunks, e.Err, list(e.Applied), list(e.RolledBack), list(e.Unapplied), list(e.Uncertain))
+}
+
+func (e *ChunkedTransactionError) Unwrap() error {
+	return e.Err
+}
+
+// rowImage is the row of a key before a transaction wrote it.
+type rowImage struct {
+	exists       bool
+	text         string
+	blob         []byte
+	etag         string
+	lastModified time.Time
+	ttl          int
+}
+
+// applyChunks applies the chunks of a transaction in order and returns the
+// mutations that remain applied, with a ChunkedTransactionError when a chunk
+// failed.
+func (store *ScyllaStateStore) applyChunks(ctx context.Context, chunks [][]txMutation) ([]txMutation, error) {
+	chunking := store.txChunking
+	chunking.chunked.Add(1)
+	store.logger.Infof("Applying transaction in %d chunks", len(chunks))
+
+	images := make(map[string]rowImage)
+	var applied []txMutation
+	for i, chunk := range chunks {
+		var err error
+		// The rows before the transaction are only needed to roll back
+		if chunking.rollback {
+			err = store.recordImages(ctx, chunk, images)
+		}
+		if err == nil {
+			err = store.executeTransactionBatch(ctx, chunk)
+		}
+		if err == nil {
+			applied = append(applied, chunk...)
+			continue
+		}
+
+		chunking.failed.Add(1)
+		store.logger.Errorf("Transaction chunk %d of %d failed: %v", i+1, len(chunks), err)
+		failure := &ChunkedTransactionError{Chunks: len(chunks), Failed: i + 1, Err: err}
+		var timeout *gocql.RequestErrWriteTimeout
+		unapplied := chunks[i+1:]
+		if errors.As(err, &timeout) || errors.Is(err, context.DeadlineExceeded) {
+			failure.Uncertain = daprKeys(chunk)
+		} else {
+			unapplied = chunks[i:]
+		}
+		var rest []txMutation
+		for _, later := range unapplied {
+			rest = append(rest, later...)
+		}
+		failure.Unapplied = daprKeys(rest)
+
+		if chunking.rollback {
+			var rolledBack, uncertain []txMutation
+			applied, rolledBack, uncertain = store.compensate(ctx, applied, images)
+			failure.RolledBack = daprKeys(rolledBack)
+			failure.Uncertain = append(failure.Uncertain, daprKeys(uncertain)...)
+		}
+		failure.Applied = daprKeys(applied)
+		return applied, failure
+	}
+	return applied, nil
+}
+
+// daprKeys returns the keys of mutations once each, in order.
+func daprKeys(mutations []txMutation) []string {
+	var keys []string
+	seen := make(map[string]bool, len(mutations))
+	for _, mutation := range mutations {
+		if !seen[mutation.daprKey] {
+			seen[mutation.daprKey] = true
+			keys = append(keys, mutation.daprKey)
+		}
+	}
+	return keys
+}
+
+// recordImages reads the rows of the keys of chunk not recorded yet, so the
+// image of a key is its row before the transaction touched it.
+func (store *ScyllaStateStore) recordImages(ctx context.Context, chunk []txMutation, images map[string]rowImage) error {
+	var keys []string
+	for _, mutation := range chunk {
+		if _, ok := images[mutation.key]; !ok {
+			images[mutation.key] = rowImage{}
+			keys = append(keys, mutation.key)
+		}
+	}
+
+	const maxBatchSize = 100 // ScyllaDB recommendation for IN queries
+	for start := 0; start < len(keys); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(keys))
+		batchKeys := keys[start:end]
+		values := make([]any, len(batchKeys))
+		for i, key := range batchKeys {
+			values[i] = key
+		}
+
+		stmt, err := store.hookedQuery(ctx, "transaction", store.inQuery("key, value, value_blob, etag, last_modified, TTL(etag)", len(batchKeys)), values...)
+		if err != nil {
+			return err
+		}
+		iter := stmt.Iter()
+		var (
+			key   string
+			image rowImage
+		)
+		for iter.Scan(&key, &image.text, &image.blob, &image.etag, &image.lastModified, &image.ttl) {
+			image.exists = true
+			images[key] = image
+			image = rowImage{}
+		}
+		if err := iter.Close(); err != nil {
+			return fmt.Errorf("failed to record rows before the transaction: %w", err)
+		}
+	}
+	return nil
+}
+
+// compensate restores the keys of the applied mutations to their images,
+// newest first. Each restore is conditional on the key still holding what the
+// transaction left, so writes made since are kept. It returns the mutations
+// still applied, those rolled back and those whose rollback failed, which may
+// or may not have been applied.
+func (store *ScyllaStateStore) compensate(ctx context.Context, applied []txMutation, images map[string]rowImage) (remaining, rolledBack, uncertain []txMutation) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), compensationTimeout)
+	defer cancel()
+
+	// What the transaction left in each key: its last mutation
+	last := make(map[string]txMutation)
+	var order []string
+	for _, mutation := range applied {
+		if _, ok := last[mutation.key]; !ok {
+			order = append(order, mutation.key)
+		}
+		last[mutation.key] = mutation
+	}
+
+	kept := make(map[string]bool)
+	failed := make(map[string]bool)
+	for i := len(order) - 1; i >= 0; i-- {
+		mutation := last[order[i]]
+		restored, err := store.restoreImage(ctx, mutation, images[mutation.key])
+		switch {
+		case err != nil:
+			store.logger.Errorf("Failed to roll back key %s of a failed transaction, its value is uncertain: %v", mutation.daprKey, err)
+			failed[mutation.key] = true
+			store.txChunking.uncertain.Add(1)
+		case !restored:
+			store.logger.Warnf("Key %s changed after the failed transaction wrote it and was not rolled back", mutation.daprKey)
+			kept[mutation.key] = true
+			store.txChunking.leftBehind.Add(1)
+		default:
+			store.txChunking.rolledBack.Add(1)
+		}
+	}
+
+	for _, mutation := range applied {
+		switch {
+		case failed[mutation.key]:
+			uncertain = append(uncertain, mutation)
+		case kept[mutation.key]:
+			remaining = append(remaining, mutation)
+		default:
+			rolledBack = append(rolledBack, mutation)
+		}
+	}
+	return remaining, rolledBack, uncertain
+}
+
+// restoreImage puts back the row of a key before the transaction, if the key
+// still holds what the transaction's last mutation of it left. It reports
+// whether the key was restored. Like the conditional writes of BulkSet it is
+// not retried, as a CAS whose outcome is unknown cannot be repeated safely.
+func (store *ScyllaStateStore) restoreImage(ctx context.Context, mutation txMutation, image rowImage) (bool, error) {
+	table := store.config.Table
+	// Values stored as blobs have no text
+	var text any = image.text
+	if image.blob != nil {
+		text = nil
+	}
+
+	var (
+		query string
+		args  []any
+	)
+	switch {
+	case !image.exists && !mutation.set:
+		// Deleted a missing key: nothing changed
+		return true, nil
+	case !image.exists:
+		query = fmt.Sprintf("DELETE FROM %s WHERE key = ? IF etag = ?", table)
+		args = []any{mutation.key, mutation.etag}
+	case mutation.set:
+		query = fmt.Sprintf("UPDATE %s USING TTL ? SET value = ?, value_blob = ?, etag = ?, last_modified = ? WHERE key = ? IF etag = ?", table)
+		args = []any{image.ttl, text, image.blob, image.etag, image.lastModified, mutation.key, mutation.etag}
+	default:
+		query = fmt.Sprintf("INSERT INTO %s (key, value, value_blob, etag, last_modified) VALUES (?, ?, ?, ?, ?) IF NOT EXISTS USING TTL ?", table)
+		args = []any{mutation.key, text, image.blob, image.etag, image.lastModified, image.ttl}
+	}
+
+	stmt, err := store.hookedQuery(ctx, "transaction rollback", query, args...)
+	if err != nil {
+		return false, err
+	}
+	return stmt.MapScanCAS(make(map[string]any))
+}
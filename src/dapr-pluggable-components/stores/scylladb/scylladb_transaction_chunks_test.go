@@ -0,0 +1,101 @@
+package scylladb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransactionChunkingSplit(t *testing.T) {
+	// Each mutation of key "kN" and value of n bytes is 2+n+mutationOverhead bytes
+	mutation := func(key string, valueBytes int) txMutation {
+		return txMutation{key: key, set: true, value: strings.Repeat("v", valueBytes)}
+	}
+	small := 2 + 10 + mutationOverhead
+
+	tests := []struct {
+		name      string
+		maxBytes  int
+		mutations []txMutation
+		want      [][]string // keys of each chunk
+	}{
+		{
+			name:      "fits in one chunk",
+			maxBytes:  3 * small,
+			mutations: []txMutation{mutation("k1", 10), mutation("k2", 10), mutation("k3", 10)},
+			want:      [][]string{{"k1", "k2", "k3"}},
+		},
+		{
+			name:      "exact fit",
+			maxBytes:  2 * small,
+			mutations: []txMutation{mutation("k1", 10), mutation("k2", 10), mutation("k3", 10), mutation("k4", 10)},
+			want:      [][]string{{"k1", "k2"}, {"k3", "k4"}},
+		},
+		{
+			name:      "one byte short",
+			maxBytes:  2*small - 1,
+			mutations: []txMutation{mutation("k1", 10), mutation("k2", 10), mutation("k3", 10)},
+			want:      [][]string{{"k1"}, {"k2"}, {"k3"}},
+		},
+		{
+			name:      "oversized mutation alone",
+			maxBytes:  2 * small,
+			mutations: []txMutation{mutation("k1", 10), mutation("k2", 1000), mutation("k3", 10)},
+			want:      [][]string{{"k1"}, {"k2"}, {"k3"}},
+		},
+		{
+			name:      "oversized first mutation",
+			maxBytes:  2 * small,
+			mutations: []txMutation{mutation("k1", 1000), mutation("k2", 10), mutation("k3", 10)},
+			want:      [][]string{{"k1"}, {"k2", "k3"}},
+		},
+		{
+			name:      "deletes count their key",
+			maxBytes:  2*(2+mutationOverhead) + small - 1,
+			mutations: []txMutation{{key: "k1"}, {key: "k2"}, mutation("k3", 10), {key: "k4"}},
+			want:      [][]string{{"k1", "k2"}, {"k3", "k4"}},
+		},
+		{
+			name:      "single mutation",
+			maxBytes:  small,
+			mutations: []txMutation{mutation("k1", 10)},
+			want:      [][]string{{"k1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunking := &transactionChunking{maxBytes: tt.maxBytes}
+			chunks := chunking.split(tt.mutations)
+
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("split() returned %d chunks %v, want %d", len(chunks), chunkKeys(chunks), len(tt.want))
+			}
+			for i, chunk := range chunks {
+				keys := chunkKeys(chunks)[i]
+				if strings.Join(keys, ",") != strings.Join(tt.want[i], ",") {
+					t.Errorf("chunk %d = %v, want %v", i, keys, tt.want[i])
+				}
+				if size := chunkSize(chunk); len(chunk) > 1 && size > tt.maxBytes {
+					t.Errorf("chunk %d is %d bytes, over the %d byte limit", i, size, tt.maxBytes)
+				}
+			}
+		})
+	}
+}
+
+func chunkKeys(chunks [][]txMutation) [][]string {
+	keys := make([][]string, len(chunks))
+	for i, chunk := range chunks {
+		for _, mutation := range chunk {
+			keys[i] = append(keys[i], mutation.key)
+		}
+	}
+	return keys
+}
+
+func chunkSize(chunk []txMutation) int {
+	size := 0
+	for _, mutation := range chunk {
+		size += mutation.size()
+	}
+	return size
+}
@@ -0,0 +1,28 @@
+package scylladb
+
+import (
+	"fmt"
+
+	stateutils "github.com/dapr/components-contrib/state/utils"
+)
+
+// Largest TTL ScyllaDB accepts (20 years)
+const maxTTLSeconds = 630720000
+
+// rowTTL returns the TTL in seconds requested through the ttlInSeconds
+// metadata, or 0 when the row must not expire. Dapr uses -1 for "never
+// expire", which CQL expresses as TTL 0. A write with TTL 0 also clears the
+// TTL of a previous write to the same key.
+func rowTTL(requestMetadata map[string]string) (int, error) {
+	ttl, err := stateutils.ParseTTL(requestMetadata)
+	if err != nil {
+		return 0, err
+	}
+	if ttl == nil || *ttl <= 0 {
+		return 0, nil
+	}
+	if *ttl > maxTTLSeconds {
+		return 0, fmt.Errorf("ttlInSeconds %d exceeds the maximum of %d", *ttl, maxTTLSeconds)
+	}
+	return *ttl, nil
+}
@@ -0,0 +1,227 @@
+package scylladb
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// Request metadata key that makes a Query return the usage report
+const usageReportMetadataKey = "usageReport"
+
+const (
+	// Dapr prefixes state keys with the app id followed by this separator
+	daprKeySeparator = "||"
+	// Upper bound on distinct (app id, prefix) buckets; the rest is folded together
+	usageMaxBuckets  = 10000
+	usageOtherPrefix = "(other)"
+)
+
+// usageBucket identifies who a metered operation is charged to.
+type usageBucket struct {
+	AppID  string `json:"appId"`
+	Prefix string `json:"prefix"`
+}
+
+// usageCounters are the cumulative totals of one bucket.
+type usageCounters struct {
+	Reads        int64 `json:"reads"`
+	Writes       int64 `json:"writes"`
+	Deletes      int64 `json:"deletes"`
+	BytesRead    int64 `json:"bytesRead"`
+	BytesWritten int64 `json:"bytesWritten"`
+}
+
+// usageMeter accounts operations and value bytes per Dapr app id and key
+// prefix, for chargeback of a shared state cluster. Totals are cumulative since
+// the component started and are reported periodically in the log and on demand
+// through Query.
+type usageMeter struct {
+	mu             sync.Mutex
+	buckets        map[usageBucket]*usageCounters
+	delimiter      string
+	defaultAppID   string
+	reportInterval time.Duration
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+}
+
+// initUsageMeter parses the metering settings and starts the report loop.
+func (store *ScyllaStateStore) initUsageMeter() {
+	reportInterval, err := time.ParseDuration(store.config.UsageReportInterval)
+	if err != nil || reportInterval <= 0 {
+		store.logger.Warnf("Invalid usageReportInterval: %s, using default", store.config.UsageReportInterval)
+		reportInterval = 5 * time.Minute
+	}
+
+	meter := &usageMeter{
+		buckets:        make(map[usageBucket]*usageCounters),
+		delimiter:      store.config.UsagePrefixDelimiter,
+		defaultAppID:   store.config.AppID,
+		reportInterval: reportInterval,
+		stopCh:         make(chan struct{}),
+	}
+	store.usage = meter
+
+	store.logger.Infof("Usage metering enabled (prefixDelimiter=%q, reportInterval=%v)", meter.delimiter, reportInterval)
+
+	meter.wg.Add(1)
+	go func() {
+		defer meter.wg.Done()
+
+		ticker := time.NewTicker(meter.reportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-meter.stopCh:
+				return
+			case <-ticker.C:
+				for _, entry := range meter.report() {
+					store.logger.Infof("Usage app=%s prefix=%s reads=%d writes=%d deletes=%d bytesRead=%d bytesWritten=%d",
+						entry.AppID, entry.Prefix, entry.Reads, entry.Writes, entry.Deletes, entry.BytesRead, entry.BytesWritten)
+				}
+			}
+		}
+	}()
+}
+
+// bucketForKey splits a Dapr key into its app id and the key prefix ending at
+// delimiter. Keys without an app id are charged to defaultAppID.
+func bucketForKey(key, defaultAppID, delimiter string) usageBucket {
+	bucket := usageBucket{AppID: defaultAppID}
+	if appID, rest, ok := strings.Cut(key, daprKeySeparator); ok {
+		bucket.AppID = appID
+		key = rest
+	}
+	if delimiter != "" {
+		if prefix, _, ok := strings.Cut(key, delimiter); ok {
+			bucket.Prefix = prefix
+		}
+	}
+	return bucket
+}
+
+func (m *usageMeter) record(key string, update func(*usageCounters)) {
+	bucket := bucketForKey(key, m.defaultAppID, m.delimiter)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counters, ok := m.buckets[bucket]
+	if !ok {
+		if len(m.buckets) >= usageMaxBuckets {
+			bucket.Prefix = usageOtherPrefix
+			counters = m.buckets[bucket]
+		}
+		if counters == nil {
+			counters = &usageCounters{}
+			m.buckets[bucket] = counters
+		}
+	}
+	update(counters)
+}
+
+// usageReportEntry is one row of the usage report.
+type usageReportEntry struct {
+	usageBucket
+	usageCounters
+}
+
+// report returns a snapshot of all buckets ordered by app id and prefix.
+func (m *usageMeter) report() []usageReportEntry {
+	m.mu.Lock()
+	entries := make([]usageReportEntry, 0, len(m.buckets))
+	for bucket, counters := range m.buckets {
+		entries = append(entries, usageReportEntry{usageBucket: bucket, usageCounters: *counters})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AppID != entries[j].AppID {
+			return entries[i].AppID < entries[j].AppID
+		}
+		return entries[i].Prefix < entries[j].Prefix
+	})
+	return entries
+}
+
+func (m *usageMeter) stop() {
+	close(m.stopCh)
+	m.wg.Wait()
+}
+
+// meterRead accounts a read of a value of the given size.
+func (store *ScyllaStateStore) meterRead(key string, size int) {
+	if store.workload != nil {
+		store.workload.recordRead(key, size)
+	}
+	if store.hotKeys != nil {
+		store.hotKeys.record(key, false)
+	}
+	if store.usage != nil {
+		store.usage.record(key, func(c *usageCounters) {
+			c.Reads++
+			c.BytesRead += int64(size)
+		})
+	}
+}
+
+// meterWrite accounts a write of a value of the given size.
+func (store *ScyllaStateStore) meterWrite(key string, size int) {
+	if store.workload != nil {
+		store.workload.recordWrite(key, size)
+	}
+	if store.hotKeys != nil {
+		store.hotKeys.record(key, true)
+	}
+	if store.usage != nil {
+		store.usage.record(key, func(c *usageCounters) {
+			c.Writes++
+			c.BytesWritten += int64(size)
+		})
+	}
+}
+
+// meterDelete accounts a delete.
+func (store *ScyllaStateStore) meterDelete(key string) {
+	if store.workload != nil {
+		store.workload.recordDelete(key)
+	}
+	if store.hotKeys != nil {
+		store.hotKeys.record(key, true)
+	}
+	if store.usage != nil {
+		store.usage.record(key, func(c *usageCounters) {
+			c.Deletes++
+		})
+	}
+}
+
+// usageReportQuery answers a Query carrying the usageReport metadata with one
+// item per (app id, prefix) bucket.
+func (store *ScyllaStateStore) usageReportQuery() (*state.QueryResponse, error) {
+	if store.usage == nil {
+		return nil, errors.New("usage report requires usageMetering to be enabled")
+	}
+
+	entries := store.usage.report()
+	results := make([]state.QueryItem, 0, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, state.QueryItem{
+			Key:  entry.AppID + "/" + entry.Prefix,
+			Data: data,
+		})
+	}
+
+	return &state.QueryResponse{Results: results}, nil
+}
@@ -0,0 +1,43 @@
+package scylladb
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Content type for which the SDK decodes request values into JSON types
+const jsonContentType = "application/json"
+
+// coerceValue converts a state value to the string stored in the database.
+//
+// Strings and byte slices are stored as-is, without copying, and anything
+// else is marshaled to JSON. With strictValues enabled only strings, byte
+// slices and values the SDK decoded from an application/json payload are
+// accepted, so an unexpected Go type is rejected instead of being stored in a
+// form the caller did not intend.
+func (store *ScyllaStateStore) coerceValue(key string, value any, contentType *string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		// The SDK decodes application/json values and drops those that fail to
+		// parse, which is always the case for ciphertext
+		if store.sidecarEncryption && contentType != nil && *contentType == jsonContentType {
+			return "", fmt.Errorf("value for key %s could not be decoded: encrypted values must not be sent with contentType %s", key, jsonContentType)
+		}
+		return "", nil
+	case []byte:
+		return bytesToString(v), nil
+	case string:
+		return v, nil
+	}
+
+	strict := store.strictValues && store.featureEnabled(featureStrictValues, key)
+	if strict && (contentType == nil || *contentType != jsonContentType) {
+		return "", fmt.Errorf("unsupported value type %T for key %s: only strings, bytes and JSON payloads are accepted when strictValues is enabled", value, key)
+	}
+
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert value to string for key %s: %w", key, err)
+	}
+	return bytesToString(jsonBytes), nil
+}
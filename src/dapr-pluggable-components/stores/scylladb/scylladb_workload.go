@@ -0,0 +1,167 @@
+package scylladb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"sync"
+	"time"
+)
+
+// WorkloadSizeBuckets is the number of power-of-two size buckets; the last one
+// also holds larger sizes.
+const WorkloadSizeBuckets = 32
+
+// WorkloadSample is one interval of recorded workload, written as a JSON line
+// to the workload sample file. Size histograms are power-of-two buckets:
+// bucket 0 counts empty keys or values and bucket i sizes in [2^(i-1), 2^i).
+type WorkloadSample struct {
+	Time       time.Time        `json:"time"`
+	Interval   float64          `json:"intervalSeconds"`
+	Operations map[string]int64 `json:"operations"`
+	KeySize    []int64          `json:"keySize"`
+	ReadSize   []int64          `json:"readSize"`
+	WriteSize  []int64          `json:"writeSize"`
+}
+
+// workloadSampler accumulates the operation mix and key and value size
+// histograms, and appends a WorkloadSample to its file every interval. The
+// resulting file feeds the report subcommand for capacity planning.
+type workloadSampler struct {
+	mu         sync.Mutex
+	operations map[string]int64
+	keySize    [WorkloadSizeBuckets]int64
+	readSize   [WorkloadSizeBuckets]int64
+	writeSize  [WorkloadSizeBuckets]int64
+	started    time.Time
+
+	file     string
+	interval time.Duration
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// initWorkloadSampler parses the sampling settings and starts the flush loop.
+func (store *ScyllaStateStore) initWorkloadSampler() {
+	interval, err := time.ParseDuration(store.config.WorkloadSampleInterval)
+	if err != nil || interval <= 0 {
+		store.logger.Warnf("Invalid workloadSampleInterval: %s, using default", store.config.WorkloadSampleInterval)
+		interval = 5 * time.Minute
+	}
+
+	sampler := &workloadSampler{
+		operations: make(map[string]int64),
+		started:    time.Now(),
+		file:       store.config.WorkloadSampleFile,
+		interval:   interval,
+		stopCh:     make(chan struct{}),
+	}
+	store.workload = sampler
+
+	store.logger.Infof("Workload sampling enabled (file=%s, interval=%v)", sampler.file, interval)
+
+	sampler.wg.Add(1)
+	go func() {
+		defer sampler.wg.Done()
+
+		ticker := time.NewTicker(sampler.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-sampler.stopCh:
+				// Keep the partial interval so short runs still leave data behind
+				if err := sampler.flush(); err != nil {
+					store.logger.Warnf("Failed to write workload sample: %v", err)
+				}
+				return
+			case <-ticker.C:
+				if err := sampler.flush(); err != nil {
+					store.logger.Warnf("Failed to write workload sample: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// sizeBucket returns the histogram bucket of size.
+func sizeBucket(size int) int {
+	bucket := bits.Len(uint(size))
+	if bucket >= WorkloadSizeBuckets {
+		bucket = WorkloadSizeBuckets - 1
+	}
+	return bucket
+}
+
+func (s *workloadSampler) countOperation(op string) {
+	s.mu.Lock()
+	s.operations[op]++
+	s.mu.Unlock()
+}
+
+func (s *workloadSampler) recordRead(key string, size int) {
+	s.mu.Lock()
+	s.keySize[sizeBucket(len(key))]++
+	s.readSize[sizeBucket(size)]++
+	s.mu.Unlock()
+}
+
+func (s *workloadSampler) recordWrite(key string, size int) {
+	s.mu.Lock()
+	s.keySize[sizeBucket(len(key))]++
+	s.writeSize[sizeBucket(size)]++
+	s.mu.Unlock()
+}
+
+func (s *workloadSampler) recordDelete(key string) {
+	s.mu.Lock()
+	s.keySize[sizeBucket(len(key))]++
+	s.mu.Unlock()
+}
+
+// flush appends the current interval to the sample file and starts a new one.
+// Intervals without operations are not written.
+func (s *workloadSampler) flush() error {
+	now := time.Now()
+
+	s.mu.Lock()
+	sample := WorkloadSample{
+		Time:       now.UTC(),
+		Interval:   now.Sub(s.started).Seconds(),
+		Operations: s.operations,
+		KeySize:    append([]int64(nil), s.keySize[:]...),
+		ReadSize:   append([]int64(nil), s.readSize[:]...),
+		WriteSize:  append([]int64(nil), s.writeSize[:]...),
+	}
+	s.operations = make(map[string]int64)
+	s.keySize = [WorkloadSizeBuckets]int64{}
+	s.readSize = [WorkloadSizeBuckets]int64{}
+	s.writeSize = [WorkloadSizeBuckets]int64{}
+	s.started = now
+	s.mu.Unlock()
+
+	if len(sample.Operations) == 0 {
+		return nil
+	}
+
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.file, err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", s.file, err)
+	}
+	return file.Close()
+}
+
+func (s *workloadSampler) stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
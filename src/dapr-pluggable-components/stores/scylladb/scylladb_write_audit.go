@@ -0,0 +1,272 @@
+package scylladb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gocql/gocql"
+)
+
+// auditChange is one changed field of a Set, addressed by a JSON Pointer
+// (RFC 6901); the empty path is the whole value.
+type auditChange struct {
+	Op    string `json:"op"` // add, remove or replace
+	Path  string `json:"path"`
+	Old   any    `json:"old,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// auditRecord is one line of the write audit.
+type auditRecord struct {
+	Op   string        `json:"op"` // set or delete
+	Key  string        `json:"key"`
+	ETag string        `json:"etag,omitempty"`
+	Diff []auditChange `json:"diff,omitempty"`
+	// Changes found when the diff was cut to writeAuditDiffMaxBytes
+	Changes   int  `json:"changes,omitempty"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// priorValue is the value a Set replaced, as read for its audit diff.
+type priorValue struct {
+	value  []byte
+	exists bool
+}
+
+// writeAuditor logs every Set and Delete with the AUDIT: prefix used for
+// forced writes, so investigators can tell from the component's log which
+// keys changed and when. In diff mode a Set also records what changed: the
+// previous value is read before the write and compared field by field with
+// the new one. Values are masked with maskPaths before they are compared,
+// whatever maskAppIds says, and the diff is cut to maxBytes.
+//
+// Diffs are recorded for Set, including merge patches and merged conflicts;
+// bulk writes, transactions, coalesced writes and time-bucketed stores are
+// logged without one.
+type writeAuditor struct {
+	diff     bool
+	maxBytes int
+	// Masks recorded values (nil without maskPaths)
+	masker *valueMasker
+
+	records   atomic.Int64
+	diffs     atomic.Int64
+	truncated atomic.Int64
+}
+
+// initWriteAudit parses the audit settings.
+func (store *ScyllaStateStore) initWriteAudit() {
+	auditor := &writeAuditor{diff: store.config.WriteAuditDiff == "true"}
+
+	maxBytes, err := strconv.Atoi(store.config.WriteAuditDiffMaxBytes)
+	if err != nil || maxBytes <= 0 {
+		store.logger.Warnf("Invalid writeAuditDiffMaxBytes: %s, using default", store.config.WriteAuditDiffMaxBytes)
+		maxBytes = 4096
+	}
+	auditor.maxBytes = maxBytes
+
+	if auditor.diff && store.sidecarEncryption {
+		store.logger.Warn("writeAuditDiff ignored: values encrypted by the Dapr sidecar cannot be compared")
+		auditor.diff = false
+	}
+	if auditor.diff && store.config.MaskPaths != "" {
+		// Parsed by initMasking already
+		rules, _ := parseMaskRules(store.config.MaskPaths)
+		auditor.masker = &valueMasker{rules: rules, hashKey: []byte(store.config.MaskHashKey)}
+	}
+
+	store.auditor = auditor
+	store.logger.Infof("Write audit enabled (diff=%t, diffMaxBytes=%d, masked=%t)", auditor.diff, maxBytes, auditor.masker != nil)
+}
+
+// auditPrevious reads the stored value of key for the diff of a Set. It
+// returns nil when diffs are off or the read fails, in which case the Set is
+// logged without a diff.
+func (store *ScyllaStateStore) auditPrevious(ctx context.Context, daprKey, storageKey string) *priorValue {
+	if store.auditor == nil || !store.auditor.diff {
+		return nil
+	}
+	var text string
+	var blob []byte
+	stmt, err := store.hookedQuery(ctx, "set", fmt.Sprintf("SELECT value, value_blob FROM %s WHERE key = ?", store.config.Table), storageKey)
+	if err == nil {
+		err = stmt.Scan(&text, &blob)
+	}
+	if err == gocql.ErrNotFound {
+		return &priorValue{}
+	}
+	if err != nil {
+		store.logger.Warnf("Failed to read key %s for the audit diff: %v", daprKey, err)
+		return nil
+	}
+	return &priorValue{value: storedBytes(text, blob), exists: true}
+}
+
+// auditSet logs a successful Set of daprKey. previous is the value the Set
+// replaced, or nil when it was not read; the diff needs it.
+func (store *ScyllaStateStore) auditSet(daprKey, etag, value string, previous *priorValue) {
+	if store.auditor == nil {
+		return
+	}
+	record := auditRecord{Op: "set", Key: daprKey, ETag: etag}
+	if store.auditor.diff && previous != nil {
+		record.Diff, record.Changes, record.Truncated = store.auditor.diffValues(*previous, []byte(value))
+	}
+	store.auditor.log(store, record)
+}
+
+// auditDelete logs a successful Delete of daprKey.
+func (store *ScyllaStateStore) auditDelete(daprKey string) {
+	if store.auditor == nil {
+		return
+	}
+	store.auditor.log(store, auditRecord{Op: "delete", Key: daprKey})
+}
+
+func (a *writeAuditor) log(store *ScyllaStateStore, record auditRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		store.logger.Errorf("Failed to encode audit record of key %s: %v", record.Key, err)
+		return
+	}
+	a.records.Add(1)
+	store.logger.Infof("AUDIT: %s", line)
+}
+
+// diffValues returns the changes from previous to value, cut to maxBytes,
+// with the number of changes found and whether any were cut. When the key did
+// not exist the whole new value is an addition. Values that are not
+// JSON documents are compared as a whole, and left out of the record when
+// masking is configured, as they cannot be masked.
+func (a *writeAuditor) diffValues(prior priorValue, value []byte) ([]auditChange, int, bool) {
+	a.diffs.Add(1)
+	previous := prior.value
+	if a.masker != nil {
+		if prior.exists {
+			previous = a.masker.mask(previous)
+		}
+		value = a.masker.mask(value)
+	}
+
+	newDoc, newErr := decodeJSONDocument(value)
+	var changes []auditChange
+	if !prior.exists {
+		change := auditChange{Op: "add", Value: newDoc}
+		if newErr != nil {
+			change.Value = a.opaque(value)
+		}
+		changes = []auditChange{change}
+	} else {
+		oldDoc, oldErr := decodeJSONDocument(previous)
+		if oldErr == nil && newErr == nil {
+			changes = diffJSON("", oldDoc, newDoc, nil)
+		} else if string(previous) != string(value) {
+			changes = []auditChange{{Op: "replace", Old: a.opaque(previous), Value: a.opaque(value)}}
+		}
+	}
+
+	kept, truncated := capDiff(changes, a.maxBytes)
+	if truncated {
+		a.truncated.Add(1)
+		return kept, len(changes), true
+	}
+	return kept, 0, false
+}
+
+// opaque returns a value that is not a JSON document for the record, or nil
+// when it would escape masking.
+func (a *writeAuditor) opaque(value []byte) any {
+	if a.masker != nil {
+		return nil
+	}
+	return string(value)
+}
+
+// diffJSON appends the changes from old to new at path. Objects are compared
+// member by member; arrays and scalars are replaced as a whole.
+func diffJSON(path string, old, new any, changes []auditChange) []auditChange {
+	oldObject, oldIsObject := old.(map[string]any)
+	newObject, newIsObject := new.(map[string]any)
+	if !oldIsObject || !newIsObject {
+		if reflect.DeepEqual(old, new) {
+			return changes
+		}
+		return append(changes, auditChange{Op: "replace", Path: path, Old: old, Value: new})
+	}
+
+	names := make([]string, 0, len(oldObject)+len(newObject))
+	for name := range oldObject {
+		names = append(names, name)
+	}
+	for name := range newObject {
+		if _, ok := oldObject[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, name := range names {
+		member := path + "/" + escaper.Replace(name)
+		oldValue, inOld := oldObject[name]
+		newValue, inNew := newObject[name]
+		switch {
+		case !inNew:
+			changes = append(changes, auditChange{Op: "remove", Path: member, Old: oldValue})
+		case !inOld:
+			changes = append(changes, auditChange{Op: "add", Path: member, Value: newValue})
+		default:
+			changes = diffJSON(member, oldValue, newValue, changes)
+		}
+	}
+	return changes
+}
+
+// capDiff keeps the changes whose encoding fits in maxBytes. A change too
+// large to fit keeps its path without the values, so the record still names
+// every field it can.
+func capDiff(changes []auditChange, maxBytes int) ([]auditChange, bool) {
+	size := len("[]")
+	truncated := false
+	kept := make([]auditChange, 0, len(changes))
+	for _, change := range changes {
+		encoded, _ := json.Marshal(change)
+		if size+len(encoded)+1 > maxBytes {
+			change.Old, change.Value = nil, nil
+			encoded, _ = json.Marshal(change)
+			truncated = true
+		}
+		if size+len(encoded)+1 > maxBytes {
+			return kept, true
+		}
+		kept = append(kept, change)
+		size += len(encoded) + 1
+	}
+	return kept, truncated
+}
+
+// gauges returns the audit counters for the metrics endpoint.
+func (a *writeAuditor) gauges() map[string]float64 {
+	return map[string]float64{
+		"write_audit_records_total":   float64(a.records.Load()),
+		"write_audit_diffs_total":     float64(a.diffs.Load()),
+		"write_audit_truncated_total": float64(a.truncated.Load()),
+	}
+}
+
+func (a *writeAuditor) diagnostics() map[string]any {
+	return map[string]any{
+		"diff":         a.diff,
+		"diffMaxBytes": a.maxBytes,
+		"masked":       a.masker != nil,
+		"records":      a.records.Load(),
+		"diffs":        a.diffs.Load(),
+		"truncated":    a.truncated.Load(),
+	}
+}
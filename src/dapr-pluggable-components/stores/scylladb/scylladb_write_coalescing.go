@@ -0,0 +1,384 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Rule of writeCoalescing applying to keys no other rule matches
+	writeCoalescingDefaultRule = "*"
+	// Longest window a rule may set; buffered values are lost if the process dies
+	writeCoalescingMaxWindow = 10 * time.Second
+	// Time a flush of one key may take, retries included
+	writeCoalescingFlushTimeout = 10 * time.Second
+)
+
+// coalesceRule is one entry of writeCoalescing.
+type coalesceRule struct {
+	prefix string
+	window time.Duration
+}
+
+// pendingWrite is the latest buffered value of a key.
+type pendingWrite struct {
+	daprKey   string
+	value     string
+	etag      string
+	ttl       int
+	modified  time.Time
+	timestamp int64 // write timestamp of the latest buffered Set, in microseconds
+	seq       int64 // incremented by every buffered Set
+	timer     *time.Timer
+
+	// Set while the value is being written; buffered Sets may still replace it
+	flushing     bool
+	flushingETag string
+	// Highest seq superseded by another write of the key during the flush
+	discardedSeq int64
+}
+
+// writeCoalescer buffers plain Sets of hot keys and writes only the latest
+// value once per window, for keys updated far more often than their value is
+// needed durably. The key must match a writeCoalescing rule and be counted at
+// least minCount times by the hot key tracker.
+//
+// A coalesced Set succeeds before its value is written: if the process dies
+// within the window, the buffered value is lost. Gets on this replica return
+// the buffered value. Any other write of the key supersedes the buffered value;
+// Sets with an ETag and Deletes write it first, so the ETag can be checked.
+type writeCoalescer struct {
+	store    *ScyllaStateStore
+	rules    []coalesceRule
+	minCount int64
+
+	mu      sync.Mutex
+	pending map[string]*pendingWrite
+	stopped bool
+	// Flushes started by timers
+	wg sync.WaitGroup
+
+	coalesced atomic.Int64
+	flushed   atomic.Int64
+	failed    atomic.Int64
+}
+
+// parseCoalesceRules parses rules of the form "<prefix>=<window>", separated
+// by commas, where prefix is a key prefix or *.
+func parseCoalesceRules(spec string) ([]coalesceRule, error) {
+	var rules []coalesceRule
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		separator := strings.LastIndex(entry, "=")
+		if separator <= 0 {
+			return nil, fmt.Errorf("invalid writeCoalescing rule %q, expected <prefix>=<window>", entry)
+		}
+		prefix := strings.TrimSpace(entry[:separator])
+		window, err := time.ParseDuration(strings.TrimSpace(entry[separator+1:]))
+		if err != nil || window <= 0 || window > writeCoalescingMaxWindow {
+			return nil, fmt.Errorf("invalid window in writeCoalescing rule %q, expected a duration up to %v", entry, writeCoalescingMaxWindow)
+		}
+		if prefix == "" || seen[prefix] {
+			return nil, fmt.Errorf("invalid writeCoalescing rule %q: empty or repeated prefix", entry)
+		}
+		seen[prefix] = true
+		rules = append(rules, coalesceRule{prefix: prefix, window: window})
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("no writeCoalescing rules")
+	}
+
+	// Longest prefix first, the default rule last
+	length := func(rule coalesceRule) int {
+		if rule.prefix == writeCoalescingDefaultRule {
+			return -1
+		}
+		return len(rule.prefix)
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return length(rules[i]) > length(rules[j]) })
+	return rules, nil
+}
+
+// initWriteCoalescing parses the coalescing rules. Hot keys are detected by
+// the hot key tracker, which is enabled with its defaults when hotKeys is not
+// set.
+func (store *ScyllaStateStore) initWriteCoalescing() {
+	if store.timeBuckets != nil {
+		store.logger.Warnf("writeCoalescing does not apply to time-bucketed tables, ignoring it")
+		return
+	}
+	rules, err := parseCoalesceRules(store.config.WriteCoalescing)
+	if err != nil {
+		store.logger.Warnf("Invalid writeCoalescing: %v, disabling it", err)
+		return
+	}
+	minCount, err := strconv.ParseInt(store.config.WriteCoalescingMinCount, 10, 64)
+	if err != nil || minCount <= 0 {
+		store.logger.Warnf("Invalid writeCoalescingMinCount: %s, using default", store.config.WriteCoalescingMinCount)
+		minCount = 100
+	}
+
+	if store.hotKeys == nil {
+		store.initHotKeys()
+	}
+	store.coalescer = &writeCoalescer{
+		store:    store,
+		rules:    rules,
+		minCount: minCount,
+		pending:  make(map[string]*pendingWrite),
+	}
+	store.logger.Infof("Coalescing Sets of hot keys for %d key prefixes (minCount=%d)", len(rules), minCount)
+}
+
+// windowFor returns the window of the first rule matching key, or 0.
+func (c *writeCoalescer) windowFor(key string) time.Duration {
+	for _, rule := range c.rules {
+		if rule.prefix == writeCoalescingDefaultRule || strings.HasPrefix(key, rule.prefix) {
+			return rule.window
+		}
+	}
+	return 0
+}
+
+// offer buffers a Set of a hot key matching a rule and returns true, or
+// returns false when the Set has to be written now.
+func (c *writeCoalescer) offer(daprKey, storageKey, value string, ttl int) bool {
+	window := c.windowFor(daprKey)
+	if window == 0 || c.store.hotKeys.count(daprKey) < c.minCount {
+		return false
+	}
+	timestamp := time.Now().UnixMicro()
+	if c.store.writeClock != nil {
+		timestamp = c.store.writeClock.next()
+	}
+	etag := c.store.newETag()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return false
+	}
+	entry, ok := c.pending[storageKey]
+	if !ok {
+		entry = &pendingWrite{daprKey: daprKey, timer: c.schedule(storageKey, window)}
+		c.pending[storageKey] = entry
+	}
+	entry.value, entry.etag, entry.ttl = value, etag, ttl
+	entry.modified, entry.timestamp = c.store.now(), timestamp
+	entry.seq++
+	c.coalesced.Add(1)
+	return true
+}
+
+// schedule flushes a key after window.
+func (c *writeCoalescer) schedule(storageKey string, window time.Duration) *time.Timer {
+	return time.AfterFunc(window, func() {
+		c.mu.Lock()
+		if c.stopped {
+			// stop writes the key
+			c.mu.Unlock()
+			return
+		}
+		c.wg.Add(1)
+		c.mu.Unlock()
+		defer c.wg.Done()
+
+		c.store.mu.RLock()
+		defer c.store.mu.RUnlock()
+		c.flushKey(storageKey)
+	})
+}
+
+// get returns the buffered value and ETag of a key.
+func (c *writeCoalescer) get(storageKey string) (string, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.pending[storageKey]
+	if !ok {
+		return "", "", false
+	}
+	return entry.value, entry.etag, true
+}
+
+// settle writes the buffered value of a key now, or waits for the write under
+// way, before a write that checks its ETag. The caller holds the store's read
+// lock.
+func (c *writeCoalescer) settle(storageKey string) {
+	deadline := time.Now().Add(writeCoalescingFlushTimeout)
+	for {
+		c.mu.Lock()
+		entry, ok := c.pending[storageKey]
+		if !ok {
+			c.mu.Unlock()
+			return
+		}
+		flushing := entry.flushing
+		if !flushing {
+			entry.timer.Stop()
+		}
+		c.mu.Unlock()
+
+		if !flushing {
+			c.flushKey(storageKey)
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// discard drops the buffered value of a key another write has replaced. The
+// flush of the key itself passes its own ETag, which leaves the entry alone.
+func (c *writeCoalescer) discard(storageKey, etag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.pending[storageKey]
+	if !ok {
+		return
+	}
+	if entry.flushing {
+		if etag != entry.flushingETag {
+			entry.discardedSeq = entry.seq
+		}
+		return
+	}
+	entry.timer.Stop()
+	delete(c.pending, storageKey)
+}
+
+// flushKey writes the latest buffered value of a key. Sets buffered while it
+// is written are flushed after another window, or right away once the
+// coalescer stops. The caller holds the store's read lock.
+func (c *writeCoalescer) flushKey(storageKey string) {
+	for {
+		c.mu.Lock()
+		entry, ok := c.pending[storageKey]
+		if !ok || entry.flushing {
+			c.mu.Unlock()
+			return
+		}
+		entry.flushing, entry.flushingETag = true, entry.etag
+		write := *entry
+		c.mu.Unlock()
+
+		if err := c.write(storageKey, write); err != nil {
+			c.failed.Add(1)
+			c.store.logger.Errorf("Failed to write coalesced value of key %s, the buffered value is lost: %v", write.daprKey, err)
+		} else {
+			c.flushed.Add(1)
+		}
+
+		c.mu.Lock()
+		entry.flushing, entry.flushingETag = false, ""
+		if entry.seq == write.seq || entry.seq == entry.discardedSeq {
+			delete(c.pending, storageKey)
+			c.mu.Unlock()
+			return
+		}
+		entry.discardedSeq = 0
+		if !c.stopped {
+			entry.timer = c.schedule(storageKey, c.windowFor(entry.daprKey))
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+	}
+}
+
+// write stores one buffered value with the timestamp of its Set, so a later
+// write of the key wins however the two race. The caller holds the store's
+// read lock.
+func (c *writeCoalescer) write(storageKey string, write pendingWrite) error {
+	store := c.store
+	if store.session == nil {
+		return errors.New("session not initialized")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeCoalescingFlushTimeout)
+	defer cancel()
+
+	stmt, err := store.hookedStatement(ctx, "set", store.setStmt, storageKey, stringToBytes(write.value), write.etag, write.modified, write.ttl)
+	if err != nil {
+		return err
+	}
+	stmt = stmt.WithTimestamp(write.timestamp)
+	if err := store.withRetry(ctx, fmt.Sprintf("set coalesced key %s", write.daprKey), stmt.Exec); err != nil {
+		return err
+	}
+
+	store.afterSet(write.daprKey, storageKey, write.value, write.etag, write.ttl)
+	store.observeSchema(write.daprKey, write.value)
+	store.verifyWrite(write.daprKey, storageKey, write.value, write.etag)
+	store.auditSet(write.daprKey, write.etag, write.value, nil)
+	return nil
+}
+
+// stop writes every buffered value; Sets are no longer coalesced afterwards.
+// It runs in Close before the session and the write hooks are stopped.
+func (c *writeCoalescer) stop() {
+	c.mu.Lock()
+	c.stopped = true
+	for _, entry := range c.pending {
+		entry.timer.Stop()
+	}
+	c.mu.Unlock()
+
+	// Flushes already running write the Sets buffered meanwhile themselves
+	c.wg.Wait()
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.pending))
+	for key := range c.pending {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	c.store.mu.RLock()
+	defer c.store.mu.RUnlock()
+	for _, key := range keys {
+		c.flushKey(key)
+	}
+}
+
+// gauges returns the coalescing counters for the metrics endpoint.
+func (c *writeCoalescer) gauges() map[string]float64 {
+	c.mu.Lock()
+	pending := len(c.pending)
+	c.mu.Unlock()
+	return map[string]float64{
+		"write_coalesced_total":           float64(c.coalesced.Load()),
+		"write_coalescing_flushes_total":  float64(c.flushed.Load()),
+		"write_coalescing_failures_total": float64(c.failed.Load()),
+		"write_coalescing_pending":        float64(pending),
+	}
+}
+
+func (c *writeCoalescer) diagnostics() map[string]any {
+	rules := make(map[string]string, len(c.rules))
+	for _, rule := range c.rules {
+		rules[rule.prefix] = rule.window.String()
+	}
+	c.mu.Lock()
+	pending := len(c.pending)
+	c.mu.Unlock()
+	return map[string]any{
+		"rules":     rules,
+		"minCount":  c.minCount,
+		"pending":   pending,
+		"coalesced": c.coalesced.Load(),
+		"flushed":   c.flushed.Load(),
+		"failed":    c.failed.Load(),
+	}
+}
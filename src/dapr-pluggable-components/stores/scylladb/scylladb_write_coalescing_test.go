@@ -0,0 +1,119 @@
+package scylladb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCoalesceRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []coalesceRule
+		wantErr bool
+	}{
+		{
+			name: "single rule",
+			spec: "presence:=1s",
+			want: []coalesceRule{{prefix: "presence:", window: time.Second}},
+		},
+		{
+			name: "longest prefix first",
+			spec: "a=1s,abc=2s,ab=3s",
+			want: []coalesceRule{
+				{prefix: "abc", window: 2 * time.Second},
+				{prefix: "ab", window: 3 * time.Second},
+				{prefix: "a", window: time.Second},
+			},
+		},
+		{
+			name: "default rule last",
+			spec: "*=5s,x=1s",
+			want: []coalesceRule{
+				{prefix: "x", window: time.Second},
+				{prefix: "*", window: 5 * time.Second},
+			},
+		},
+		{
+			name: "equal lengths keep their order",
+			spec: "bb=1s,aa=2s,cc=3s",
+			want: []coalesceRule{
+				{prefix: "bb", window: time.Second},
+				{prefix: "aa", window: 2 * time.Second},
+				{prefix: "cc", window: 3 * time.Second},
+			},
+		},
+		{
+			name: "spaces and empty entries",
+			spec: " ab = 500ms ,, a=1s, ",
+			want: []coalesceRule{
+				{prefix: "ab", window: 500 * time.Millisecond},
+				{prefix: "a", window: time.Second},
+			},
+		},
+		{
+			name: "prefix containing =",
+			spec: "k=v=2s",
+			want: []coalesceRule{{prefix: "k=v", window: 2 * time.Second}},
+		},
+		{
+			name: "longest window",
+			spec: "a=10s",
+			want: []coalesceRule{{prefix: "a", window: writeCoalescingMaxWindow}},
+		},
+		{name: "empty", spec: "", wantErr: true},
+		{name: "only separators", spec: " , ", wantErr: true},
+		{name: "no window", spec: "a", wantErr: true},
+		{name: "no prefix", spec: "=1s", wantErr: true},
+		{name: "blank prefix", spec: " =1s", wantErr: true},
+		{name: "invalid window", spec: "a=soon", wantErr: true},
+		{name: "zero window", spec: "a=0s", wantErr: true},
+		{name: "negative window", spec: "a=-1s", wantErr: true},
+		{name: "window too long", spec: "a=11s", wantErr: true},
+		{name: "repeated prefix", spec: "a=1s,a=2s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCoalesceRules(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCoalesceRules(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCoalesceRules(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowFor(t *testing.T) {
+	rules, err := parseCoalesceRules("*=5s,presence:=1s,presence:user=2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coalescer := &writeCoalescer{rules: rules}
+
+	tests := []struct {
+		key  string
+		want time.Duration
+	}{
+		{"presence:user-1", 2 * time.Second},
+		{"presence:device-1", time.Second},
+		{"cart-1", 5 * time.Second},
+		{"", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := coalescer.windowFor(tt.key); got != tt.want {
+			t.Errorf("windowFor(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	rules, err = parseCoalesceRules("presence:=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coalescer = &writeCoalescer{rules: rules}
+	if got := coalescer.windowFor("cart-1"); got != 0 {
+		t.Errorf("windowFor(%q) without a default rule = %v, want 0", "cart-1", got)
+	}
+}
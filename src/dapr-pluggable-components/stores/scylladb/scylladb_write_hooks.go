@@ -0,0 +1,43 @@
+package scylladb
+
+// afterSet forwards a successful upsert to the search index, the mirror
+// cluster, the change feed and the stale cache when enabled, and drops a value
+// write coalescing buffered for the key before.
+func (store *ScyllaStateStore) afterSet(daprKey, storageKey, value, etag string, ttl int) {
+	if store.coalescer != nil {
+		store.coalescer.discard(storageKey, etag)
+	}
+	if store.searchIndex != nil {
+		store.searchIndex.indexSet(daprKey, storageKey, value)
+	}
+	if store.clusterMirror != nil {
+		store.clusterMirror.mirrorSet(storageKey, value, etag, ttl)
+	}
+	if store.changeFeed != nil {
+		store.changeFeed.record(storageKey, "set", etag)
+	}
+	if store.staleCache != nil {
+		store.staleCache.remember(storageKey, []byte(value), etag, false)
+	}
+}
+
+// afterDelete forwards a successful delete to the search index, the mirror
+// cluster, the change feed and the stale cache when enabled, and drops a value
+// write coalescing buffered for the key.
+func (store *ScyllaStateStore) afterDelete(storageKey string) {
+	if store.coalescer != nil {
+		store.coalescer.discard(storageKey, "")
+	}
+	if store.searchIndex != nil {
+		store.searchIndex.indexDelete(storageKey)
+	}
+	if store.clusterMirror != nil {
+		store.clusterMirror.mirrorDelete(storageKey)
+	}
+	if store.changeFeed != nil {
+		store.changeFeed.record(storageKey, "delete", "")
+	}
+	if store.staleCache != nil {
+		store.staleCache.forget(storageKey)
+	}
+}
@@ -0,0 +1,134 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Strategies for the write timestamps of Sets without ETag
+const (
+	// One client timestamp per Set, taken when the request arrives and reused
+	// by every retry and speculative execution
+	writeTimestampsRequest = "request"
+	// The driver's timestamp, taken again for every attempt
+	writeTimestampsDriver = "driver"
+	// The coordinator's clock; the driver sends no timestamp
+	writeTimestampsServer = "server"
+)
+
+// Query reading the coordinator's clock in milliseconds
+const serverClockQuery = "SELECT toUnixTimestamp(now()) FROM system.local"
+
+// writeClock issues the timestamps of Sets without ETag. The driver stamps
+// each attempt separately, so a Set retried after a timeout, or executed
+// speculatively, can carry a later timestamp than a newer Set of the same key
+// and bring the older value back. Taking the timestamp once per request makes
+// every attempt of a Set identical, and last-write-wins then follows the order
+// in which requests arrived.
+//
+// Timestamps never go backwards within the process, even when the wall clock
+// is stepped back. Across replicas they are only as ordered as the clocks, so
+// Init compares the local clock with the coordinator's and refuses to start
+// when they differ by more than the configured skew.
+type writeClock struct {
+	maxSkew time.Duration
+	last    atomic.Int64 // last issued timestamp, in microseconds
+	// Offset of the coordinator's clock from ours, measured at Init
+	skew atomic.Int64
+	// Timestamps raised past the wall clock to stay monotonic
+	adjusted atomic.Int64
+}
+
+// initWriteTimestamps applies the writeTimestamps strategy to cluster.
+func (store *ScyllaStateStore) initWriteTimestamps(cluster *gocql.ClusterConfig) {
+	switch store.config.WriteTimestamps {
+	case writeTimestampsRequest:
+	case writeTimestampsDriver:
+		return
+	case writeTimestampsServer:
+		cluster.DefaultTimestamp = false
+		store.logger.Info("Using coordinator timestamps for writes")
+		return
+	default:
+		store.logger.Warnf("Invalid writeTimestamps: %s, using default", store.config.WriteTimestamps)
+		store.config.WriteTimestamps = writeTimestampsRequest
+	}
+
+	maxSkew, err := time.ParseDuration(store.config.WriteTimestampMaxSkew)
+	if err != nil || maxSkew < 0 {
+		store.logger.Warnf("Invalid writeTimestampMaxSkew: %s, using default", store.config.WriteTimestampMaxSkew)
+		maxSkew = time.Second
+	}
+	store.writeClock = &writeClock{maxSkew: maxSkew}
+}
+
+// checkClockSkew compares the local clock with the coordinator's. A skew above
+// the limit fails Init: values written from this replica could overwrite newer
+// values written from others, or be overwritten by older ones. Failing to read
+// the server clock only logs a warning.
+func (store *ScyllaStateStore) checkClockSkew(ctx context.Context) error {
+	clock := store.writeClock
+	if clock == nil || clock.maxSkew == 0 {
+		return nil
+	}
+
+	var serverMillis int64
+	sent := time.Now()
+	if err := store.session.Query(serverClockQuery).WithContext(ctx).Scan(&serverMillis); err != nil {
+		store.logger.Warnf("Failed to read the server clock, clock skew not checked: %v", err)
+		return nil
+	}
+	received := time.Now()
+
+	// The server read its clock somewhere within the round trip; assume the middle
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := time.UnixMilli(serverMillis).Sub(local)
+	clock.skew.Store(int64(skew))
+
+	// The server clock has millisecond precision
+	if skew.Abs() > clock.maxSkew+time.Millisecond+received.Sub(sent)/2 {
+		return fmt.Errorf("clock skew of %v with the ScyllaDB coordinator exceeds writeTimestampMaxSkew (%v); "+
+			"synchronize the clocks or set writeTimestamps to server", skew.Round(time.Millisecond), clock.maxSkew)
+	}
+	store.logger.Infof("Using request timestamps for writes (clock skew %v)", skew.Round(time.Millisecond))
+	return nil
+}
+
+// next returns a timestamp in microseconds greater than any issued before.
+func (c *writeClock) next() int64 {
+	for {
+		now := time.Now().UnixMicro()
+		last := c.last.Load()
+		ts := now
+		if ts <= last {
+			ts = last + 1
+		}
+		if c.last.CompareAndSwap(last, ts) {
+			if ts != now {
+				c.adjusted.Add(1)
+			}
+			return ts
+		}
+	}
+}
+
+// withWriteTimestamp fixes the timestamp of a Set without ETag, so that
+// retries and speculative executions of stmt all write the same cell version.
+func (store *ScyllaStateStore) withWriteTimestamp(stmt *gocql.Query) *gocql.Query {
+	if store.writeClock == nil {
+		return stmt
+	}
+	return stmt.WithTimestamp(store.writeClock.next())
+}
+
+func (c *writeClock) diagnostics() map[string]any {
+	return map[string]any{
+		"maxSkew":  c.maxSkew.String(),
+		"skew":     time.Duration(c.skew.Load()).String(),
+		"adjusted": c.adjusted.Load(),
+	}
+}
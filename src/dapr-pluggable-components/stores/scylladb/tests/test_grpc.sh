@@ -701,12 +701,81 @@ test_grpc_query_performance() {
     fi
 }
 
+# Test 16.5: gRPC BULK DELETE of missing keys
+test_grpc_bulk_delete_not_found() {
+    print_test_header "16.5. Testing gRPC BULK DELETE of Missing Keys"
+
+    set_request='{
+        "storeName": "'$COMPONENT_NAME'",
+        "states": [{
+            "key": "scylla-grpc-nf-existing",
+            "value": "'$(echo -n '"present"' | base64 -w 0)'"
+        }]
+    }'
+    grpcurl -plaintext -H "dapr-app-id: scylladb-test" -d "$set_request" \
+        localhost:$DAPR_GRPC_PORT dapr.proto.runtime.v1.Dapr/SaveState > /dev/null 2>&1
+
+    # Missing keys are ignored by default
+    default_request='{
+        "storeName": "'$COMPONENT_NAME'",
+        "states": [
+            {"key": "scylla-grpc-nf-existing"},
+            {"key": "scylla-grpc-nf-missing"}
+        ]
+    }'
+    default_response=$(grpcurl -plaintext -H "dapr-app-id: scylladb-test" -d "$default_request" \
+        localhost:$DAPR_GRPC_PORT dapr.proto.runtime.v1.Dapr/DeleteBulkState 2>&1)
+    if [ $? -eq 0 ]; then
+        print_pass "BULK DELETE with a missing key succeeds by default"
+    else
+        print_fail "BULK DELETE with a missing key failed by default"
+        print_info "Response: $default_response"
+    fi
+
+    # ignoreNotFound=false turns a missing key into an error
+    strict_request='{
+        "storeName": "'$COMPONENT_NAME'",
+        "states": [
+            {"key": "scylla-grpc-nf-missing", "metadata": {"ignoreNotFound": "false"}}
+        ]
+    }'
+    strict_response=$(grpcurl -plaintext -H "dapr-app-id: scylladb-test" -d "$strict_request" \
+        localhost:$DAPR_GRPC_PORT dapr.proto.runtime.v1.Dapr/DeleteBulkState 2>&1)
+    if [ $? -ne 0 ]; then
+        print_pass "BULK DELETE with ignoreNotFound=false rejects a missing key"
+    else
+        print_fail "BULK DELETE with ignoreNotFound=false accepted a missing key"
+    fi
+
+    # A stale ETag of an existing key fails and leaves the key in place
+    grpcurl -plaintext -H "dapr-app-id: scylladb-test" -d "$set_request" \
+        localhost:$DAPR_GRPC_PORT dapr.proto.runtime.v1.Dapr/SaveState > /dev/null 2>&1
+    stale_request='{
+        "storeName": "'$COMPONENT_NAME'",
+        "states": [
+            {"key": "scylla-grpc-nf-existing", "etag": {"value": "stale-etag"}}
+        ]
+    }'
+    stale_response=$(grpcurl -plaintext -H "dapr-app-id: scylladb-test" -d "$stale_request" \
+        localhost:$DAPR_GRPC_PORT dapr.proto.runtime.v1.Dapr/DeleteBulkState 2>&1)
+    stale_exit_code=$?
+    get_response=$(grpcurl -plaintext -H "dapr-app-id: scylladb-test" \
+        -d '{"storeName": "'$COMPONENT_NAME'", "key": "scylla-grpc-nf-existing"}' \
+        localhost:$DAPR_GRPC_PORT dapr.proto.runtime.v1.Dapr/GetState 2>&1)
+    if [ $stale_exit_code -ne 0 ] && echo "$get_response" | grep -q '"data"'; then
+        print_pass "BULK DELETE with a stale ETag fails and keeps the key"
+    else
+        print_fail "BULK DELETE with a stale ETag did not fail cleanly"
+        print_info "Response: $stale_response"
+    fi
+}
+
 # Test 17: Final gRPC cleanup
 test_grpc_final_cleanup() {
     print_test_header "17. Final gRPC Cleanup"
     
     cleanup_count=0
-    for key in "scylla-grpc-bulk-test-2" "scylla-grpc-query-user-001" "scylla-grpc-query-product-001" "scylla-grpc-json-test" "scylla-grpc-test-key-json"; do
+    for key in "scylla-grpc-bulk-test-2" "scylla-grpc-query-user-001" "scylla-grpc-query-product-001" "scylla-grpc-json-test" "scylla-grpc-test-key-json" "scylla-grpc-nf-existing"; do
         delete_request='{
             "storeName": "'$COMPONENT_NAME'",
             "key": "'$key'"
@@ -752,6 +821,7 @@ test_grpc_json_set
 test_grpc_query_setup
 test_grpc_query
 test_grpc_query_performance
+test_grpc_bulk_delete_not_found
 test_grpc_final_cleanup
 
 # Print final summary
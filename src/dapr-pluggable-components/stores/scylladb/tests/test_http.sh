@@ -509,9 +509,128 @@ else
     fi
 fi
 
-print_test_header "16. Final ScyllaDB Cleanup"
+print_test_header "16. Testing Transaction ETag Snapshot"
+curl -s -X POST "$DAPR_URL" -H "Content-Type: application/json" \
+    -d '[{"key": "scylla-tx-key-1", "value": "tx original 1"}, {"key": "scylla-tx-key-2", "value": "tx original 2"}]' > /dev/null
+
+# A stale ETag on the second operation must abort the whole transaction
+tx_response=$(curl -s -w "|%{http_code}" -X POST "$DAPR_URL/transaction" \
+    -H "Content-Type: application/json" \
+    -d '{
+        "operations": [
+            {"operation": "upsert", "request": {"key": "scylla-tx-key-1", "value": "tx updated 1"}},
+            {"operation": "delete", "request": {"key": "scylla-tx-key-2", "etag": "stale-etag"}}
+        ]
+    }')
+http_code=$(echo "$tx_response" | grep -o '[0-9]*$')
+
+if [ "$http_code" != "200" ] && [ "$http_code" != "204" ]; then
+    print_pass "Transaction with stale ETag rejected (HTTP $http_code)"
+else
+    print_fail "Transaction with stale ETag was accepted (HTTP $http_code)"
+fi
+
+verify_response=$(curl -s "$DAPR_URL/scylla-tx-key-1")
+if [ "$verify_response" = '"tx original 1"' ]; then
+    print_pass "Aborted transaction applied no operations"
+else
+    print_fail "Aborted transaction partially applied - got: $verify_response"
+fi
+
+# The same transaction without the stale ETag must apply every operation
+tx_response=$(curl -s -w "%{http_code}" -X POST "$DAPR_URL/transaction" \
+    -H "Content-Type: application/json" \
+    -d '{
+        "operations": [
+            {"operation": "upsert", "request": {"key": "scylla-tx-key-1", "value": "tx updated 1"}},
+            {"operation": "delete", "request": {"key": "scylla-tx-key-2"}}
+        ]
+    }')
+http_code="${tx_response: -3}"
+verify_response=$(curl -s "$DAPR_URL/scylla-tx-key-1")
+
+if { [ "$http_code" = "200" ] || [ "$http_code" = "204" ]; } && [ "$verify_response" = '"tx updated 1"' ]; then
+    print_pass "Transaction without ETag conflicts applied all operations"
+else
+    print_fail "Transaction without ETag conflicts failed (HTTP $http_code, value: $verify_response)"
+fi
+
+print_test_header "17. Testing TTL in Transactions"
+# One upsert expires, the other has no TTL; both are applied in the same transaction
+tx_response=$(curl -s -w "%{http_code}" -X POST "$DAPR_URL/transaction" \
+    -H "Content-Type: application/json" \
+    -d '{
+        "operations": [
+            {"operation": "upsert", "request": {"key": "scylla-tx-ttl-key", "value": "expires", "metadata": {"ttlInSeconds": "2"}}},
+            {"operation": "upsert", "request": {"key": "scylla-tx-no-ttl-key", "value": "persists"}}
+        ]
+    }')
+http_code="${tx_response: -3}"
+
+if [ "$http_code" = "200" ] || [ "$http_code" = "204" ]; then
+    print_pass "Transaction with mixed TTL operations applied (HTTP $http_code)"
+else
+    print_fail "Transaction with mixed TTL operations failed (HTTP $http_code)"
+fi
+
+sleep 4
+
+ttl_response=$(curl -s "$DAPR_URL/scylla-tx-ttl-key")
+no_ttl_response=$(curl -s "$DAPR_URL/scylla-tx-no-ttl-key")
+
+if [ -z "$ttl_response" ]; then
+    print_pass "Upsert with ttlInSeconds expired"
+else
+    print_fail "Upsert with ttlInSeconds did not expire - got: $ttl_response"
+fi
+
+if [ "$no_ttl_response" = '"persists"' ]; then
+    print_pass "Upsert without TTL in the same transaction persisted"
+else
+    print_fail "Upsert without TTL was lost - got: $no_ttl_response"
+fi
+
+print_test_header "18. Testing DELETE of Missing Keys"
+# Deleting a missing key succeeds by default, with or without an ETag
+missing_response=$(curl -s -w "%{http_code}" -X DELETE "$DAPR_URL/scylla-missing-key")
+http_code="${missing_response: -3}"
+if [ "$http_code" = "200" ] || [ "$http_code" = "204" ]; then
+    print_pass "DELETE of a missing key succeeded (HTTP $http_code)"
+else
+    print_fail "DELETE of a missing key failed (HTTP $http_code)"
+fi
+
+missing_response=$(curl -s -w "%{http_code}" -X DELETE "$DAPR_URL/scylla-missing-key" -H "If-Match: 12345")
+http_code="${missing_response: -3}"
+if [ "$http_code" = "200" ] || [ "$http_code" = "204" ]; then
+    print_pass "DELETE of a missing key with an ETag succeeded (HTTP $http_code)"
+else
+    print_fail "DELETE of a missing key with an ETag failed (HTTP $http_code)"
+fi
+
+# ignoreNotFound=false makes a missing key an error
+missing_response=$(curl -s -w "%{http_code}" -X DELETE "$DAPR_URL/scylla-missing-key?metadata.ignoreNotFound=false")
+http_code="${missing_response: -3}"
+if [ "$http_code" != "200" ] && [ "$http_code" != "204" ]; then
+    print_pass "DELETE of a missing key with ignoreNotFound=false rejected (HTTP $http_code)"
+else
+    print_fail "DELETE of a missing key with ignoreNotFound=false succeeded (HTTP $http_code)"
+fi
+
+# An existing key is still deleted with ignoreNotFound=false
+curl -s -X POST "$DAPR_URL" -H "Content-Type: application/json" \
+    -d '[{"key": "scylla-not-found-key", "value": "present"}]' > /dev/null
+existing_response=$(curl -s -w "%{http_code}" -X DELETE "$DAPR_URL/scylla-not-found-key?metadata.ignoreNotFound=false")
+http_code="${existing_response: -3}"
+if [ "$http_code" = "200" ] || [ "$http_code" = "204" ]; then
+    print_pass "DELETE of an existing key with ignoreNotFound=false succeeded (HTTP $http_code)"
+else
+    print_fail "DELETE of an existing key with ignoreNotFound=false failed (HTTP $http_code)"
+fi
+
+print_test_header "19. Final ScyllaDB Cleanup"
 cleanup_count=0
-cleanup_keys=("scylla-bulk-test-1" "scylla-bulk-test-3" "scylla-bulk-test-5" "scylla-query-user-001" "scylla-query-user-002" "scylla-query-product-001")
+cleanup_keys=("scylla-bulk-test-1" "scylla-bulk-test-3" "scylla-bulk-test-5" "scylla-query-user-001" "scylla-query-user-002" "scylla-query-product-001" "scylla-tx-key-1" "scylla-tx-key-2" "scylla-tx-no-ttl-key")
 
 # Add performance test keys to cleanup
 for i in {1..10}; do
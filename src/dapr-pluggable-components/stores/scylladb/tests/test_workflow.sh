@@ -0,0 +1,206 @@
+#!/bin/bash
+
+# Load environment configuration if available
+if [ -f "../../../../.env" ]; then
+    source ../../../../.env
+fi
+
+echo "Testing ScyllaDB Dapr State Store Component - Workflow State Pattern"
+echo "===================================================================="
+echo "Replays the key layout and write pattern of the Dapr workflow engine"
+
+# Base configuration
+DAPR_HTTP_PORT=${SCYLLADB_HTTP_PORT}
+COMPONENT_NAME="scylladb-state"
+DAPR_URL="http://localhost:$DAPR_HTTP_PORT/v1.0/state/$COMPONENT_NAME"
+QUERY_URL="http://localhost:$DAPR_HTTP_PORT/v1.0-alpha1/state/$COMPONENT_NAME/query"
+HISTORY_EVENTS=${WORKFLOW_HISTORY_EVENTS:-50}
+INSTANCE="wf-test-$$"
+
+echo "Configuration:"
+echo "  • HTTP Port: $DAPR_HTTP_PORT"
+echo "  • Component: $COMPONENT_NAME (needs keyGroups: \"true\")"
+echo "  • Workflow instance: $INSTANCE"
+echo "  • History events: $HISTORY_EVENTS"
+echo ""
+
+# Test counters
+TOTAL_TESTS=0
+PASSED_TESTS=0
+FAILED_TESTS=0
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+print_test_header() {
+    echo -e "\n${BLUE}$1${NC}"
+    echo "----------------------------------------"
+}
+
+print_pass() {
+    echo -e "${GREEN}✅ PASS${NC}: $1"
+    ((PASSED_TESTS++))
+    ((TOTAL_TESTS++))
+}
+
+print_fail() {
+    echo -e "${RED}❌ FAIL${NC}: $1"
+    ((FAILED_TESTS++))
+    ((TOTAL_TESTS++))
+}
+
+print_info() {
+    echo -e "${BLUE}ℹ️  INFO${NC}: $1"
+}
+
+print_summary() {
+    echo ""
+    echo "=============================================================="
+    echo -e "${BLUE}SCYLLADB WORKFLOW STATE TEST SUMMARY${NC}"
+    echo "=============================================================="
+    echo -e "Total Tests: ${TOTAL_TESTS}"
+    echo -e "${GREEN}Passed: ${PASSED_TESTS}${NC}"
+    echo -e "${RED}Failed: ${FAILED_TESTS}${NC}"
+    [ $FAILED_TESTS -eq 0 ]
+}
+
+# Keys saved over HTTP are prefixed with the app id, which starts their group
+APP_ID=$(curl -s "http://localhost:$DAPR_HTTP_PORT/v1.0/metadata" | sed -n 's/^{"id":"\([^"]*\)".*/\1/p')
+if [ -z "$APP_ID" ]; then
+    print_fail "Could not read the app id from the Dapr metadata endpoint on port $DAPR_HTTP_PORT"
+    print_summary
+    exit 1
+fi
+GROUP="$APP_ID||$INSTANCE"
+print_info "Key group: $GROUP"
+
+# query_group prints the body of a keyGroup query
+query_group() {
+    curl -s -X POST "$QUERY_URL?metadata.keyGroup=$(printf '%s' "$GROUP" | sed 's/|/%7C/g')$1" \
+        -H "Content-Type: application/json" -d '{"filter": {}}'
+}
+
+print_test_header "1. Starting the Workflow"
+# The engine writes the inbox, the first history event and the metadata in one transaction
+start_response=$(curl -s -w "%{http_code}" -X POST "$DAPR_URL/transaction" \
+    -H "Content-Type: application/json" \
+    -d '{
+        "operations": [
+            {"operation": "upsert", "request": {"key": "'"$INSTANCE"'||inbox-000000", "value": {"kind": "ExecutionStarted"}}},
+            {"operation": "upsert", "request": {"key": "'"$INSTANCE"'||history-000000", "value": {"kind": "OrchestratorStarted"}}},
+            {"operation": "upsert", "request": {"key": "'"$INSTANCE"'||metadata", "value": {"inboxLength": 1, "historyLength": 1, "generation": 1}}}
+        ]
+    }')
+http_code="${start_response: -3}"
+if [ "$http_code" = "200" ] || [ "$http_code" = "204" ]; then
+    print_pass "Start transaction applied (HTTP $http_code)"
+else
+    print_fail "Start transaction failed (HTTP $http_code): ${start_response%???}"
+fi
+
+print_test_header "2. Appending History Events"
+# Every step appends one small history key and rewrites the metadata under its ETag
+append_failures=0
+start_time=$(date +%s%N)
+for i in $(seq 1 "$HISTORY_EVENTS"); do
+    etag=$(curl -s -D - -o /dev/null "$DAPR_URL/$INSTANCE||metadata" | tr -d '\r' | sed -n 's/^[Ee][Tt]ag: *//p')
+    event=$(printf '%06d' "$i")
+    append_response=$(curl -s -w "%{http_code}" -o /dev/null -X POST "$DAPR_URL/transaction" \
+        -H "Content-Type: application/json" \
+        -d '{
+            "operations": [
+                {"operation": "upsert", "request": {"key": "'"$INSTANCE"'||history-'"$event"'", "value": {"kind": "TaskCompleted", "taskId": '"$i"'}}},
+                {"operation": "upsert", "request": {"key": "'"$INSTANCE"'||metadata", "etag": "'"$etag"'", "value": {"inboxLength": 1, "historyLength": '"$((i + 1))"', "generation": 1}}}
+            ]
+        }')
+    if [ "$append_response" != "200" ] && [ "$append_response" != "204" ]; then
+        ((append_failures++))
+    fi
+done
+elapsed_ms=$(( ($(date +%s%N) - start_time) / 1000000 ))
+if [ $append_failures -eq 0 ]; then
+    print_pass "$HISTORY_EVENTS history appends applied in ${elapsed_ms}ms ($((elapsed_ms / HISTORY_EVENTS))ms each)"
+else
+    print_fail "$append_failures of $HISTORY_EVENTS history appends failed"
+fi
+
+# A stale metadata ETag must reject the append, as the engine relies on it to fence old workers
+stale_response=$(curl -s -w "%{http_code}" -o /dev/null -X POST "$DAPR_URL/transaction" \
+    -H "Content-Type: application/json" \
+    -d '{
+        "operations": [
+            {"operation": "upsert", "request": {"key": "'"$INSTANCE"'||history-999999", "value": {"kind": "Stale"}}},
+            {"operation": "upsert", "request": {"key": "'"$INSTANCE"'||metadata", "etag": "stale-etag", "value": {}}}
+        ]
+    }')
+if [ "$stale_response" != "200" ] && [ "$stale_response" != "204" ]; then
+    print_pass "Append with a stale metadata ETag rejected (HTTP $stale_response)"
+else
+    print_fail "Append with a stale metadata ETag was applied"
+fi
+
+print_test_header "3. Loading the Workflow State in One Call"
+expected=$((HISTORY_EVENTS + 3))
+group_response=$(query_group "")
+if echo "$group_response" | grep -q "keyGroups to be enabled"; then
+    print_fail "keyGroup queries are disabled; set keyGroups: \"true\" on the component"
+else
+    count=$(echo "$group_response" | grep -o '"key":"[^"]*"' | wc -l)
+    if [ "$count" -eq "$expected" ]; then
+        print_pass "keyGroup query returned all $expected keys of the instance"
+    else
+        print_fail "keyGroup query returned $count keys, expected $expected"
+    fi
+
+    first=$(echo "$group_response" | grep -o '"key":"[^"]*"' | sed -n 2p)
+    if echo "$first" | grep -q "history-000000"; then
+        print_pass "Keys are returned in key order"
+    else
+        print_fail "Unexpected key order, second key: $first"
+    fi
+
+    if echo "$group_response" | grep -q "history-999999"; then
+        print_fail "The rejected append is visible in the group"
+    else
+        print_pass "The rejected append is not part of the group"
+    fi
+
+    # Pages of 20 keys, continued with the token of the previous page
+    paged=0
+    token=""
+    for page in $(seq 1 20); do
+        page_response=$(curl -s -X POST "$QUERY_URL?metadata.keyGroup=$(printf '%s' "$GROUP" | sed 's/|/%7C/g')" \
+            -H "Content-Type: application/json" \
+            -d '{"filter": {}, "page": {"limit": 20, "token": "'"$token"'"}}')
+        paged=$((paged + $(echo "$page_response" | grep -o '"key":"[^"]*"' | wc -l)))
+        token=$(echo "$page_response" | sed -n 's/.*"token":"\([^"]*\)".*/\1/p')
+        [ -z "$token" ] && break
+    done
+    if [ "$paged" -eq "$expected" ]; then
+        print_pass "Paged keyGroup queries returned all $expected keys"
+    else
+        print_fail "Paged keyGroup queries returned $paged keys, expected $expected"
+    fi
+fi
+
+print_test_header "4. Purging the Workflow"
+purge_failures=0
+for key in "$INSTANCE||inbox-000000" "$INSTANCE||metadata" $(for i in $(seq 0 "$HISTORY_EVENTS"); do printf '%s||history-%06d ' "$INSTANCE" "$i"; done); do
+    purge_response=$(curl -s -w "%{http_code}" -o /dev/null -X DELETE "$DAPR_URL/$key")
+    if [ "$purge_response" != "200" ] && [ "$purge_response" != "204" ]; then
+        ((purge_failures++))
+    fi
+done
+group_response=$(query_group "")
+count=$(echo "$group_response" | grep -o '"key":"[^"]*"' | wc -l)
+if [ $purge_failures -eq 0 ] && [ "$count" -eq 0 ]; then
+    print_pass "Purged instance has no keys left in its group"
+else
+    print_fail "Purge left $count keys in the group ($purge_failures deletes failed)"
+fi
+
+print_summary
+exit $?
@@ -0,0 +1,200 @@
+package simulated
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Operations faults can be limited to with faultOperations. Bulk requests are
+// served key by key, so they get the faults of their single-key operation.
+var faultOperationNames = []string{"get", "set", "delete", "transact", "deleteWithPrefix"}
+
+// z-score of the 99th percentile of the standard normal distribution
+const p99ZScore = 2.326
+
+// latency is a distribution of simulated request latencies.
+type latency struct {
+	spec string
+	// Draws a latency; nil without latency
+	sample func(rng *rand.Rand) time.Duration
+}
+
+// parseLatency parses a latency distribution: none, fixed:<d>,
+// uniform:<min>-<max>, normal:<mean>,<stddev> or lognormal:<median>,<p99>.
+func parseLatency(spec string) (latency, error) {
+	name, args, _ := strings.Cut(spec, ":")
+	switch name {
+	case "", "none":
+		return latency{spec: "none"}, nil
+	case "fixed":
+		d, err := parseDurations(args, "", 1)
+		if err != nil {
+			return latency{}, err
+		}
+		return latency{spec: spec, sample: func(*rand.Rand) time.Duration { return d[0] }}, nil
+	case "uniform":
+		d, err := parseDurations(args, "-", 2)
+		if err != nil {
+			return latency{}, err
+		}
+		if d[1] < d[0] {
+			return latency{}, fmt.Errorf("uniform latency %s has its maximum below its minimum", args)
+		}
+		return latency{spec: spec, sample: func(rng *rand.Rand) time.Duration {
+			return d[0] + time.Duration(rng.Int64N(int64(d[1]-d[0])+1))
+		}}, nil
+	case "normal":
+		d, err := parseDurations(args, ",", 2)
+		if err != nil {
+			return latency{}, err
+		}
+		mean, stddev := float64(d[0]), float64(d[1])
+		return latency{spec: spec, sample: func(rng *rand.Rand) time.Duration {
+			return time.Duration(max(0, mean+stddev*rng.NormFloat64()))
+		}}, nil
+	case "lognormal":
+		// Most requests near the median and a long tail, like a database
+		// under compactions and garbage collection pauses
+		d, err := parseDurations(args, ",", 2)
+		if err != nil {
+			return latency{}, err
+		}
+		if d[0] == 0 || d[1] < d[0] {
+			return latency{}, fmt.Errorf("lognormal latency %s needs a positive median and a p99 above it", args)
+		}
+		median := float64(d[0])
+		sigma := math.Log(float64(d[1])/median) / p99ZScore
+		return latency{spec: spec, sample: func(rng *rand.Rand) time.Duration {
+			return time.Duration(median * math.Exp(sigma*rng.NormFloat64()))
+		}}, nil
+	}
+	return latency{}, fmt.Errorf("unknown latency distribution %q: use none, fixed, uniform, normal or lognormal", name)
+}
+
+// parseDurations parses n non-negative durations separated by sep.
+func parseDurations(value, sep string, n int) ([]time.Duration, error) {
+	parts := []string{value}
+	if sep != "" {
+		parts = strings.Split(value, sep)
+	}
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d durations in %q", n, value)
+	}
+	durations := make([]time.Duration, n)
+	for i, part := range parts {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid duration %q", part)
+		}
+		durations[i] = d
+	}
+	return durations, nil
+}
+
+// parseRate parses a fraction of requests between 0 and 1.
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid rate %q: use a fraction between 0 and 1", value)
+	}
+	return rate, nil
+}
+
+// faults injects latency and failures into the requests of the store.
+type faults struct {
+	latency      latency
+	errorRate    float64
+	timeoutRate  float64
+	conflictRate float64
+	// Operations the faults apply to; nil for every operation
+	operations map[string]bool
+
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	// Faults injected so far, for diagnostics
+	errors    atomic.Int64
+	timeouts  atomic.Int64
+	conflicts atomic.Int64
+}
+
+// applies reports whether operation gets faults.
+func (f *faults) applies(operation string) bool {
+	return f.operations == nil || f.operations[operation]
+}
+
+// roll returns true with probability rate.
+func (f *faults) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Float64() < rate
+}
+
+// delay returns the latency of the next request.
+func (f *faults) delay() time.Duration {
+	if f.latency.sample == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.latency.sample(f.rng)
+}
+
+// before waits out the latency of a request and decides its fault: a
+// transient error, returned as the error, or a timeout once the operation has
+// been decided on.
+func (f *faults) before(ctx context.Context, operation string) (timeout bool, err error) {
+	if !f.applies(operation) {
+		return false, nil
+	}
+	if err := sleep(ctx, f.delay()); err != nil {
+		return false, err
+	}
+	if f.roll(f.errorRate) {
+		f.errors.Add(1)
+		return false, status.Errorf(codes.Unavailable, "simulated %s failure: not enough replicas available for the consistency level", operation)
+	}
+	if f.roll(f.timeoutRate) {
+		f.timeouts.Add(1)
+		return true, nil
+	}
+	return false, nil
+}
+
+// conflict reports whether a conditional write loses to a simulated
+// concurrent writer.
+func (f *faults) conflict(operation string) bool {
+	if !f.applies(operation) || !f.roll(f.conflictRate) {
+		return false
+	}
+	f.conflicts.Add(1)
+	return true
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,635 @@
+// Package simulated is an in-memory state store that behaves like the
+// database-backed stores of this module, with configurable latency, ETag
+// conflicts and transient errors, so applications can test their failure
+// handling locally without running a database.
+package simulated
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	stateutils "github.com/dapr/components-contrib/state/utils"
+	"github.com/dapr/kit/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"nebulagraph/stores/clock"
+	"nebulagraph/stores/fixtures"
+	"nebulagraph/stores/stateext"
+)
+
+// Request metadata key choosing whether deleting a missing key succeeds
+const ignoreNotFoundMetadataKey = "ignoreNotFound"
+
+// SimulatedStateStore keeps state in memory with the contract of the ScyllaDB
+// store: time-based ETags, first-write concurrency, TTLs, deletes of missing
+// keys that succeed unless ignoreNotFound=false, atomic transactions, prefix
+// deletes and keysOnly bulk reads.
+//
+// On top of that it simulates what a real database does to requests:
+// 1. Latency drawn from a configurable distribution
+// 2. Transient Unavailable errors at errorRate
+// 3. Timeouts at timeoutRate; a timed out write applies or not, at random
+// 4. Conditional writes losing to a concurrent writer at conflictRate
+//
+// State lives in the process and is lost when it exits.
+type SimulatedStateStore struct {
+	state.BulkStore
+
+	config         SimulatedConfig
+	requestTimeout time.Duration
+	faults         *faults
+	clock          clock.Clock
+	etags          clock.ETags
+	logger         logger.Logger
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+	closed  bool
+}
+
+// entry is the stored state of a key.
+type entry struct {
+	value     []byte
+	etag      string
+	expiresAt time.Time // zero without TTL
+}
+
+// Compile time check to ensure SimulatedStateStore implements the optional capabilities
+var (
+	_ state.TransactionalStore  = (*SimulatedStateStore)(nil)
+	_ stateext.DeleteWithPrefix = (*SimulatedStateStore)(nil)
+)
+
+// SimulatedConfig holds the configuration for the simulated state store.
+type SimulatedConfig struct {
+	Latency         string `json:"latency" mapstructure:"latency"`                 // Latency distribution: none, fixed:<d>, uniform:<min>-<max>, normal:<mean>,<stddev> or lognormal:<median>,<p99> (default: none)
+	ErrorRate       string `json:"errorRate" mapstructure:"errorRate"`             // Fraction of requests failing with a transient Unavailable error (default: 0)
+	TimeoutRate     string `json:"timeoutRate" mapstructure:"timeoutRate"`         // Fraction of requests timing out after requestTimeout (default: 0)
+	ConflictRate    string `json:"conflictRate" mapstructure:"conflictRate"`       // Fraction of writes with an ETag losing to a concurrent writer (default: 0)
+	FaultOperations string `json:"faultOperations" mapstructure:"faultOperations"` // Comma-separated operations faults apply to: get, set, delete, transact, deleteWithPrefix (default: all)
+	Seed            string `json:"seed" mapstructure:"seed"`                       // Seed of the fault sequence, for reproducible runs (default: random)
+	RequestTimeout  string `json:"requestTimeout" mapstructure:"requestTimeout"`   // Time a simulated timeout takes (default: 10s)
+	Fixtures        string `json:"fixtures" mapstructure:"fixtures"`               // Comma-separated fixture files or directories written on Init
+	TestClock       string `json:"testClock" mapstructure:"testClock"`             // Hidden: RFC 3339 time the store's clock starts at, for reproducible staging runs
+	TestETags       string `json:"testETags" mapstructure:"testETags"`             // Hidden: ETag generator, time or sequence[:n] (default: time)
+}
+
+// NewSimulatedStateStore creates a new instance of SimulatedStateStore.
+func NewSimulatedStateStore(inputLogger logger.Logger) state.Store {
+	// Create default logger if none provided
+	if inputLogger == nil {
+		inputLogger = logger.NewLogger("simulated-state")
+	}
+	store := &SimulatedStateStore{
+		logger: inputLogger,
+	}
+	store.BulkStore = state.NewDefaultBulkStore(store)
+	return store
+}
+
+// UseClock replaces the clock and the ETag generator of the store, for tests
+// of TTLs and ETag conflicts that should not depend on the wall clock. It
+// must be called before Init; the testClock and testETags metadata take
+// precedence.
+func (store *SimulatedStateStore) UseClock(c clock.Clock, etags clock.ETags) {
+	store.clock = c
+	store.etags = etags
+}
+
+func (store *SimulatedStateStore) Init(ctx context.Context, metadata state.Metadata) error {
+	store.logger.Info("Initializing SimulatedStateStore...")
+
+	// Parse configuration from metadata
+	configBytes, _ := json.Marshal(metadata.Properties)
+	if err := json.Unmarshal(configBytes, &store.config); err != nil {
+		store.logger.Errorf("Failed to parse config: %v", err)
+		return fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	// Set defaults
+	if store.config.Latency == "" {
+		store.config.Latency = "none"
+	}
+	if store.config.ErrorRate == "" {
+		store.config.ErrorRate = "0"
+	}
+	if store.config.TimeoutRate == "" {
+		store.config.TimeoutRate = "0"
+	}
+	if store.config.ConflictRate == "" {
+		store.config.ConflictRate = "0"
+	}
+	if store.config.RequestTimeout == "" {
+		store.config.RequestTimeout = "10s"
+	}
+
+	if timeout, err := time.ParseDuration(store.config.RequestTimeout); err == nil && timeout > 0 {
+		store.requestTimeout = timeout
+	} else {
+		store.logger.Warnf("Invalid requestTimeout: %s, using default", store.config.RequestTimeout)
+		store.requestTimeout = 10 * time.Second
+	}
+
+	configured := store.parseFaults()
+
+	if store.config.TestClock != "" || store.config.TestETags != "" {
+		c, etags, err := clock.FromMetadata(store.config.TestClock, store.config.TestETags)
+		if err != nil {
+			store.logger.Warnf("Invalid test clock: %v, using the wall clock", err)
+		} else {
+			store.logger.Warnf("Using test clock (testClock=%q, testETags=%q); not for production", store.config.TestClock, store.config.TestETags)
+			store.clock, store.etags = c, etags
+		}
+	}
+	if store.clock == nil {
+		store.clock = clock.System
+	}
+	if store.etags == nil {
+		store.etags = clock.TimeETags(store.clock)
+	}
+
+	store.mu.Lock()
+	store.entries = make(map[string]*entry)
+	store.closed = false
+	store.mu.Unlock()
+
+	store.logger.Infof("Simulated store config: latency=%s, errorRate=%g, timeoutRate=%g, conflictRate=%g",
+		configured.latency.spec, configured.errorRate, configured.timeoutRate, configured.conflictRate)
+
+	// Fixtures are written without faults. Nothing here outlives the process,
+	// so they need no devMode.
+	store.faults = &faults{}
+	if store.config.Fixtures != "" {
+		if err := fixtures.Seed(ctx, store, store.config.Fixtures, true, store.logger); err != nil {
+			return err
+		}
+	}
+	store.faults = configured
+
+	store.logger.Info("SimulatedStateStore initialized successfully")
+	return nil
+}
+
+// parseFaults builds the faults from the configuration, without the invalid
+// settings.
+func (store *SimulatedStateStore) parseFaults() *faults {
+	f := &faults{}
+
+	distribution, err := parseLatency(store.config.Latency)
+	if err != nil {
+		store.logger.Warnf("Invalid latency: %s (%v), using none", store.config.Latency, err)
+		distribution = latency{spec: "none"}
+	}
+	f.latency = distribution
+
+	for _, setting := range []struct {
+		name  string
+		value string
+		rate  *float64
+	}{
+		{"errorRate", store.config.ErrorRate, &f.errorRate},
+		{"timeoutRate", store.config.TimeoutRate, &f.timeoutRate},
+		{"conflictRate", store.config.ConflictRate, &f.conflictRate},
+	} {
+		rate, err := parseRate(setting.value)
+		if err != nil {
+			store.logger.Warnf("Invalid %s: %s, using default", setting.name, setting.value)
+			continue
+		}
+		*setting.rate = rate
+	}
+
+	if store.config.FaultOperations != "" {
+		f.operations = make(map[string]bool)
+		for _, operation := range strings.Split(store.config.FaultOperations, ",") {
+			operation = strings.TrimSpace(operation)
+			if !slices.Contains(faultOperationNames, operation) {
+				store.logger.Warnf("Invalid faultOperations entry: %s, ignoring it", operation)
+				continue
+			}
+			f.operations[operation] = true
+		}
+	}
+
+	seed := uint64(time.Now().UnixNano())
+	if store.config.Seed != "" {
+		if parsed, err := strconv.ParseUint(store.config.Seed, 10, 64); err == nil {
+			seed = parsed
+		} else {
+			store.logger.Warnf("Invalid seed: %s, using a random one", store.config.Seed)
+		}
+	}
+	f.rng = rand.New(rand.NewPCG(seed, seed))
+	return f
+}
+
+func (store *SimulatedStateStore) GetComponentMetadata() map[string]string {
+	return map[string]string{
+		"type":    "state",
+		"version": "v1",
+		"author":  "nebulagraph",
+	}
+}
+
+func (store *SimulatedStateStore) Features() []state.Feature {
+	return []state.Feature{
+		state.FeatureETag,
+		state.FeatureTransactional,
+		stateext.FeatureDeleteWithPrefix,
+	}
+}
+
+func (store *SimulatedStateStore) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	timeout, err := store.faults.before(ctx, "get")
+	if err != nil {
+		return nil, err
+	}
+	if timeout {
+		return nil, store.timeOut(ctx, "get")
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if store.closed {
+		return nil, errStoreClosed()
+	}
+
+	current := store.live(req.Key)
+	if current == nil {
+		return &state.GetResponse{}, nil
+	}
+	etag := current.etag
+	return &state.GetResponse{
+		Data: slices.Clone(current.value),
+		ETag: &etag,
+	}, nil
+}
+
+// BulkGet reads keys one by one, or with keysOnly request metadata returns
+// their ETags without values.
+func (store *SimulatedStateStore) BulkGet(ctx context.Context, req []state.GetRequest, opts state.BulkGetOpts) ([]state.BulkGetResponse, error) {
+	responses, err := store.BulkStore.BulkGet(ctx, req, opts)
+	if err != nil || !stateext.KeysOnly(requestsMetadata(req)...) {
+		return responses, err
+	}
+	for i := range responses {
+		responses[i].Data = nil
+	}
+	return responses, nil
+}
+
+// requestsMetadata collects the metadata of every request of a bulk operation.
+func requestsMetadata(requests []state.GetRequest) []map[string]string {
+	metadata := make([]map[string]string, len(requests))
+	for i := range requests {
+		metadata[i] = requests[i].Metadata
+	}
+	return metadata
+}
+
+func (store *SimulatedStateStore) Set(ctx context.Context, req *state.SetRequest) error {
+	timeout, err := store.faults.before(ctx, "set")
+	if err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	if store.closed {
+		store.mu.Unlock()
+		return errStoreClosed()
+	}
+	if timeout && !store.faults.roll(0.5) {
+		store.mu.Unlock()
+		return store.timeOut(ctx, "set")
+	}
+	err = store.apply(*req, "set")
+	store.mu.Unlock()
+
+	if timeout {
+		return store.timeOut(ctx, "set")
+	}
+	return err
+}
+
+func (store *SimulatedStateStore) Delete(ctx context.Context, req *state.DeleteRequest) error {
+	timeout, err := store.faults.before(ctx, "delete")
+	if err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	if store.closed {
+		store.mu.Unlock()
+		return errStoreClosed()
+	}
+	if timeout && !store.faults.roll(0.5) {
+		store.mu.Unlock()
+		return store.timeOut(ctx, "delete")
+	}
+	err = store.apply(*req, "delete")
+	store.mu.Unlock()
+
+	if timeout {
+		return store.timeOut(ctx, "delete")
+	}
+	return err
+}
+
+// Multi checks every ETag and first-write condition first, then applies all
+// operations or none, as a logged batch of lightweight transactions would.
+func (store *SimulatedStateStore) Multi(ctx context.Context, request *state.TransactionalStateRequest) error {
+	if request == nil || len(request.Operations) == 0 {
+		return nil
+	}
+
+	timeout, err := store.faults.before(ctx, "transact")
+	if err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	if store.closed {
+		store.mu.Unlock()
+		return errStoreClosed()
+	}
+	if timeout && !store.faults.roll(0.5) {
+		store.mu.Unlock()
+		return store.timeOut(ctx, "transact")
+	}
+	err = store.applyAll(request.Operations)
+	store.mu.Unlock()
+
+	if timeout {
+		return store.timeOut(ctx, "transact")
+	}
+	return err
+}
+
+// applyAll checks the conditions of every operation and, when all hold,
+// applies them. The caller holds the write lock.
+func (store *SimulatedStateStore) applyAll(operations []state.TransactionalStateOperation) error {
+	for _, op := range operations {
+		switch req := op.(type) {
+		case state.SetRequest:
+			if err := store.check(req.Key, req.ETag, req.Options.Concurrency == state.FirstWrite, "transact"); err != nil {
+				return err
+			}
+			if _, err := valueBytes(req.Value); err != nil {
+				return fmt.Errorf("failed to convert value for key %s: %w", req.Key, err)
+			}
+		case state.DeleteRequest:
+			if err := store.check(req.Key, req.ETag, false, "transact"); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported operation type %T", op)
+		}
+	}
+
+	for _, op := range operations {
+		switch req := op.(type) {
+		case state.SetRequest:
+			req.ETag = nil
+			req.Options.Concurrency = state.LastWrite
+			if err := store.apply(req, "transact"); err != nil {
+				return err
+			}
+		case state.DeleteRequest:
+			req.ETag = nil
+			if err := store.apply(req, "transact"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply writes a set or delete request. The caller holds the write lock.
+func (store *SimulatedStateStore) apply(op state.TransactionalStateOperation, operation string) error {
+	switch req := op.(type) {
+	case state.SetRequest:
+		if req.Key == "" {
+			return errors.New("key cannot be empty")
+		}
+		if err := store.check(req.Key, req.ETag, req.Options.Concurrency == state.FirstWrite, operation); err != nil {
+			return err
+		}
+		value, err := valueBytes(req.Value)
+		if err != nil {
+			return fmt.Errorf("failed to convert value for key %s: %w", req.Key, err)
+		}
+
+		// A write without TTL also clears an earlier one
+		ttl, err := stateutils.ParseTTL(req.Metadata)
+		if err != nil {
+			return fmt.Errorf("invalid ttl for key %s: %w", req.Key, err)
+		}
+		written := &entry{value: slices.Clone(value), etag: store.etags.NewETag()}
+		if ttl != nil && *ttl > 0 {
+			written.expiresAt = store.clock.Now().Add(time.Duration(*ttl) * time.Second)
+		}
+		store.entries[req.Key] = written
+		return nil
+
+	case state.DeleteRequest:
+		ignoreNotFound := true
+		if value, ok := req.Metadata[ignoreNotFoundMetadataKey]; ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				store.logger.Warnf("Invalid %s request metadata: %s, using default", ignoreNotFoundMetadataKey, value)
+			} else {
+				ignoreNotFound = parsed
+			}
+		}
+		if store.live(req.Key) == nil {
+			// Drops an expired entry
+			delete(store.entries, req.Key)
+			if !ignoreNotFound {
+				return fmt.Errorf("key %s not found", req.Key)
+			}
+			return nil
+		}
+		if err := store.check(req.Key, req.ETag, false, operation); err != nil {
+			return err
+		}
+		delete(store.entries, req.Key)
+		return nil
+	}
+	return fmt.Errorf("unsupported operation type %T", op)
+}
+
+// check verifies the ETag or first-write condition of a write to key. Like
+// the ScyllaDB store, a missing key has nothing to conflict with. A write with
+// an ETag loses to a simulated concurrent writer at conflictRate, which gives
+// the key a new ETag. The caller holds the write lock.
+func (store *SimulatedStateStore) check(key string, etag *string, firstWrite bool, operation string) error {
+	current := store.live(key)
+	switch {
+	case current == nil:
+		return nil
+	case etag != nil && *etag != "":
+		if current.etag != *etag {
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", key, *etag, current.etag), current.etag)
+		}
+		if store.faults.conflict(operation) {
+			current.etag = store.etags.NewETag()
+			return stateext.NewETagMismatch(
+				fmt.Errorf("etag mismatch for key %s: expected %s, got %s", key, *etag, current.etag), current.etag)
+		}
+	case firstWrite:
+		return stateext.NewETagError(state.ETagMismatch, fmt.Errorf("key %s already exists", key))
+	}
+	return nil
+}
+
+// DeleteWithPrefix deletes every key starting with req.Prefix.
+func (store *SimulatedStateStore) DeleteWithPrefix(ctx context.Context, req stateext.DeleteWithPrefixRequest) (stateext.DeleteWithPrefixResponse, error) {
+	if err := req.Validate(); err != nil {
+		return stateext.DeleteWithPrefixResponse{}, err
+	}
+
+	timeout, err := store.faults.before(ctx, "deleteWithPrefix")
+	if err != nil {
+		return stateext.DeleteWithPrefixResponse{}, err
+	}
+	if timeout {
+		return stateext.DeleteWithPrefixResponse{}, store.timeOut(ctx, "deleteWithPrefix")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.closed {
+		return stateext.DeleteWithPrefixResponse{}, errStoreClosed()
+	}
+
+	var deleted int64
+	for key := range store.entries {
+		if !strings.HasPrefix(key, req.Prefix) {
+			continue
+		}
+		if store.live(key) != nil {
+			deleted++
+		}
+		delete(store.entries, key)
+	}
+
+	store.logger.Infof("Delete with prefix %q deleted %d keys", req.Prefix, deleted)
+	return stateext.DeleteWithPrefixResponse{Count: deleted}, nil
+}
+
+// live returns the entry of key unless it is missing or expired. The caller
+// holds the lock.
+func (store *SimulatedStateStore) live(key string) *entry {
+	current, ok := store.entries[key]
+	if !ok {
+		return nil
+	}
+	if !current.expiresAt.IsZero() && !store.clock.Now().Before(current.expiresAt) {
+		return nil
+	}
+	return current
+}
+
+// timeOut waits out the request timeout, or the caller's deadline when it
+// comes first, and fails the request as timed out.
+func (store *SimulatedStateStore) timeOut(ctx context.Context, operation string) error {
+	sleep(ctx, store.requestTimeout)
+	return status.Errorf(codes.DeadlineExceeded, "simulated %s timeout after %s: the request may or may not have been applied", operation, store.requestTimeout)
+}
+
+// Diagnostics reports the store's configuration and the faults injected so
+// far for the diagnostics endpoint.
+func (store *SimulatedStateStore) Diagnostics() map[string]any {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	diagnostics := map[string]any{
+		"closed": store.closed,
+		"keys":   len(store.entries),
+	}
+	if f := store.faults; f != nil {
+		operations := "all"
+		if f.operations != nil {
+			operations = strings.Join(slices.Sorted(maps.Keys(f.operations)), ",")
+		}
+		diagnostics["faults"] = map[string]any{
+			"latency":      f.latency.spec,
+			"errorRate":    f.errorRate,
+			"timeoutRate":  f.timeoutRate,
+			"conflictRate": f.conflictRate,
+			"operations":   operations,
+			"injected": map[string]any{
+				"errors":    f.errors.Load(),
+				"timeouts":  f.timeouts.Load(),
+				"conflicts": f.conflicts.Load(),
+			},
+		}
+	}
+	return diagnostics
+}
+
+// Capabilities returns the capability matrix logged once Init succeeded and
+// served for support bundles.
+func (store *SimulatedStateStore) Capabilities() map[string]any {
+	return map[string]any{
+		"backend": map[string]any{
+			"dialect": "simulated",
+			"version": "in-memory",
+		},
+		"features": map[string]any{
+			"ttl":          true,
+			"lwt":          true,
+			"transactions": true,
+			"queryAPI":     false,
+			"cache":        []string{},
+			"encryption": map[string]any{
+				"tls": false,
+			},
+		},
+		"pool": map[string]any{},
+		"schema": map[string]any{
+			"status": "schemaless",
+		},
+	}
+}
+
+func (store *SimulatedStateStore) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.closed = true
+	store.entries = nil
+	store.logger.Info("SimulatedStateStore closed successfully")
+	return nil
+}
+
+func errStoreClosed() error {
+	return errors.New("store is closed")
+}
+
+// valueBytes converts a state value to the bytes stored under the key.
+func valueBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return []byte{}, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return json.Marshal(value)
+}
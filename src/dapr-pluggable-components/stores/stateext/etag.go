@@ -0,0 +1,91 @@
+package stateext
+
+import (
+	"github.com/dapr/components-contrib/state"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Field named in the BadRequest violation of ETag errors
+const etagField = "etag"
+
+// ErrorInfo of ETag mismatches that know the stored ETag: the reason, and the
+// metadata key holding the ETag
+const (
+	ETagMismatchReason     = "ETAG_MISMATCH"
+	CurrentETagMetadataKey = "currentEtag"
+)
+
+// ETagError is a state.ETagError that converts to the gRPC status the sidecar
+// maps back to an ETag error: FailedPrecondition for a mismatch and
+// InvalidArgument for an invalid ETag, each with a BadRequest violation on the
+// etag field. Returned as a plain state.ETagError, the error reaches the
+// sidecar as Unknown and the caller gets a 500 instead of a 409.
+//
+// A mismatch created with NewETagMismatch also carries the ETag stored for
+// the key, in an ErrorInfo detail with reason ETAG_MISMATCH and the ETag under
+// currentEtag, so callers can retry without parsing the message or another
+// Get.
+//
+// Like any error carrying a status, it has to be returned unwrapped, as the
+// gRPC server does not unwrap errors.
+type ETagError struct {
+	*state.ETagError
+	cause       error
+	currentETag *string
+}
+
+// NewETagError returns an ETag error of kind wrapping err.
+func NewETagError(kind state.ETagErrorKind, err error) *ETagError {
+	return &ETagError{ETagError: state.NewETagError(kind, err), cause: err}
+}
+
+// NewETagMismatch returns an ETag mismatch wrapping err for a key whose stored
+// ETag is currentETag.
+func NewETagMismatch(err error, currentETag string) *ETagError {
+	mismatch := NewETagError(state.ETagMismatch, err)
+	mismatch.currentETag = &currentETag
+	return mismatch
+}
+
+// CurrentETag returns the ETag stored for the key, if the error knows it.
+func (e *ETagError) CurrentETag() (string, bool) {
+	if e.currentETag == nil {
+		return "", false
+	}
+	return *e.currentETag, true
+}
+
+// Unwrap returns the state.ETagError, so errors.As finds it.
+func (e *ETagError) Unwrap() error {
+	return e.ETagError
+}
+
+// GRPCStatus is used by the gRPC server to build the response status.
+func (e *ETagError) GRPCStatus() *status.Status {
+	code := codes.FailedPrecondition
+	if e.Kind() == state.ETagInvalid {
+		code = codes.InvalidArgument
+	}
+	st := status.New(code, e.Error())
+
+	description := e.Error()
+	if e.cause != nil {
+		description = e.cause.Error()
+	}
+	violation := &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: etagField, Description: description}},
+	}
+	detailed, err := st.WithDetails(violation)
+	if e.currentETag != nil {
+		detailed, err = st.WithDetails(violation, &errdetails.ErrorInfo{
+			Reason:   ETagMismatchReason,
+			Metadata: map[string]string{CurrentETagMetadataKey: *e.currentETag},
+		})
+	}
+	if err != nil {
+		return st
+	}
+	return detailed
+}
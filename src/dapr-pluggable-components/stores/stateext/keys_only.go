@@ -0,0 +1,20 @@
+package stateext
+
+import "strconv"
+
+// KeysOnlyMetadataKey is the request metadata asking a BulkGet or Query for
+// the existence and ETags of keys without their values. Items of keys that
+// exist carry an ETag and no data; keys that do not exist have neither.
+const KeysOnlyMetadataKey = "keysOnly"
+
+// KeysOnly reports whether keysOnly is set to true in the metadata of every
+// request. The sidecar copies the metadata of a bulk request to each key, so
+// a bulk request asks for keys only when all of its requests do.
+func KeysOnly(metadata ...map[string]string) bool {
+	for _, md := range metadata {
+		if keysOnly, err := strconv.ParseBool(md[KeysOnlyMetadataKey]); err != nil || !keysOnly {
+			return false
+		}
+	}
+	return len(metadata) > 0
+}
@@ -0,0 +1,120 @@
+package stateext
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PrefixMigration asks to rename every key starting with From so it starts
+// with To instead. Dapr prefixes keys according to the component's keyPrefix
+// (appid by default: "myapp||order-1"); after changing it, for example to
+// none ("order-1"), the stored keys have to be renamed to stay reachable.
+//
+// When To starts with From, keys already starting with To are left alone, so
+// From "" and To "myapp||" prefixes every key that is not prefixed yet.
+type PrefixMigration struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Keys renamed per batch; each batch is journaled before it is applied
+	BatchSize int `json:"batchSize"`
+	// Only report what would be renamed
+	DryRun bool `json:"dryRun"`
+	// Receives one PrefixMove per line before the moves are applied, so an
+	// interrupted or unwanted migration can be rolled back; may be nil
+	Journal io.Writer `json:"-"`
+}
+
+// Validate checks that the migration renames something.
+func (m PrefixMigration) Validate() error {
+	if m.From == m.To {
+		return errors.New("from and to prefixes are the same")
+	}
+	if m.BatchSize < 0 {
+		return errors.New("batch size cannot be negative")
+	}
+	return nil
+}
+
+// Matches reports whether key is renamed by the migration.
+func (m PrefixMigration) Matches(key string) bool {
+	if !strings.HasPrefix(key, m.From) {
+		return false
+	}
+	// Already renamed, possibly by this migration
+	return !(strings.HasPrefix(m.To, m.From) && strings.HasPrefix(key, m.To))
+}
+
+// Rename returns the new name of a matching key.
+func (m PrefixMigration) Rename(key string) string {
+	return m.To + strings.TrimPrefix(key, m.From)
+}
+
+// PrefixMove is one journal entry: key From was, or was about to be, renamed
+// to To. Keys are Dapr keys, as the sidecar passes them to the store.
+type PrefixMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// PrefixMigrationReport counts the keys a migration or rollback handled.
+type PrefixMigrationReport struct {
+	Scanned int64 `json:"scanned"`
+	Matched int64 `json:"matched"`
+	Moved   int64 `json:"moved"`
+	// Keys that disappeared before they could be renamed
+	Missing int64 `json:"missing"`
+	// Keys left in place because the new name already exists
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Keys left in place because they were written while being renamed
+	Changed []string `json:"changed,omitempty"`
+}
+
+// PrefixMigrator is implemented by stores that can rename keys in place.
+// RollbackPrefixMigration renames the keys of a journal back, To to From;
+// only BatchSize and DryRun of m are used.
+type PrefixMigrator interface {
+	MigratePrefix(ctx context.Context, m PrefixMigration) (PrefixMigrationReport, error)
+	RollbackPrefixMigration(ctx context.Context, journal []PrefixMove, m PrefixMigration) (PrefixMigrationReport, error)
+}
+
+// WriteJournal appends moves to a migration journal.
+func WriteJournal(w io.Writer, moves []PrefixMove) error {
+	if w == nil {
+		return nil
+	}
+	encoder := json.NewEncoder(w)
+	for _, move := range moves {
+		if err := encoder.Encode(move); err != nil {
+			return fmt.Errorf("failed to write migration journal: %w", err)
+		}
+	}
+	if syncer, ok := w.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			return fmt.Errorf("failed to sync migration journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadJournal reads the moves of a migration journal.
+func ReadJournal(r io.Reader) ([]PrefixMove, error) {
+	var moves []PrefixMove
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var move PrefixMove
+		if err := json.Unmarshal(scanner.Bytes(), &move); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		moves = append(moves, move)
+	}
+	return moves, scanner.Err()
+}
@@ -0,0 +1,40 @@
+// Package stateext defines state store capabilities that the components-contrib
+// version this module builds against does not have yet. Names and shapes
+// follow the upstream proposals, so stores implementing them can switch to the
+// upstream definitions by changing imports once Dapr formalizes them.
+package stateext
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// FeatureDeleteWithPrefix is advertised by stores that can delete every key
+// starting with a prefix in one request.
+const FeatureDeleteWithPrefix state.Feature = "DELETE_WITH_PREFIX"
+
+// DeleteWithPrefixRequest asks to delete every key starting with Prefix.
+type DeleteWithPrefixRequest struct {
+	Prefix   string            `json:"prefix"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Validate checks that the request cannot match the whole store.
+func (r DeleteWithPrefixRequest) Validate() error {
+	if r.Prefix == "" {
+		return errors.New("prefix cannot be empty")
+	}
+	return nil
+}
+
+// DeleteWithPrefixResponse reports the number of keys deleted.
+type DeleteWithPrefixResponse struct {
+	Count int64 `json:"count"`
+}
+
+// DeleteWithPrefix is implemented by stores advertising FeatureDeleteWithPrefix.
+type DeleteWithPrefix interface {
+	DeleteWithPrefix(ctx context.Context, req DeleteWithPrefixRequest) (DeleteWithPrefixResponse, error)
+}
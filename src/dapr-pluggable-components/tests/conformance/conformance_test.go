@@ -0,0 +1,276 @@
+// Package conformance runs the state store conformance suite of
+// dapr/components-contrib, at the version this module is built with, against
+// the stores of this module and writes a capability matrix report.
+//
+// Each store is opened in process, as the component binary opens it, and the
+// suite runs once per capability: the basic operations (crud) every store
+// must pass, then each optional capability the store declares through
+// Features (etag, first-write, transaction, query) and ttl, which every store
+// here supports. Capabilities a store does not declare are reported as
+// unsupported rather than run.
+//
+// Without backend flags only the simulated store runs. With -scylla-hosts,
+// -cassandra-hosts, -alternator-endpoint or -etcd-endpoints it also runs
+// against those backends; "make conformance" starts them in containers.
+//
+//	go test ./tests/conformance -v
+//	go test ./tests/conformance -v -args -stores scylladb -scylla-hosts localhost -report conformance.json
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/metadata"
+	"github.com/dapr/components-contrib/state"
+	conformance "github.com/dapr/components-contrib/tests/conformance/state"
+	"github.com/dapr/kit/logger"
+
+	"nebulagraph/stores/client"
+)
+
+const contribModule = "github.com/dapr/components-contrib"
+
+var (
+	stores             = flag.String("stores", "scylladb,cassandra,alternator,etcd,simulated", "Store types to run the suite against")
+	scyllaHosts        = flag.String("scylla-hosts", "", "ScyllaDB hosts; the scylladb store is skipped when empty")
+	scyllaPort         = flag.String("scylla-port", "9042", "ScyllaDB port")
+	cassandraHosts     = flag.String("cassandra-hosts", "", "Cassandra hosts; the cassandra store is skipped when empty")
+	cassandraPort      = flag.String("cassandra-port", "9042", "Cassandra port")
+	alternatorEndpoint = flag.String("alternator-endpoint", "", "Alternator URL; the alternator store is skipped when empty")
+	etcdEndpoints      = flag.String("etcd-endpoints", "", "etcd endpoints; the etcd store is skipped when empty")
+	wait               = flag.Duration("wait", 0, "How long to retry Init while a backend starts")
+	reportPath         = flag.String("report", "", "File the JSON capability matrix is written to")
+)
+
+// Capabilities in report order; crud runs the operations every store must support
+var capabilities = []string{"crud", "etag", "first-write", "transaction", "ttl", "query"}
+
+// Outcomes of one capability in the report
+const (
+	outcomePass        = "pass"
+	outcomeFail        = "fail"
+	outcomeUnsupported = "unsupported"
+	outcomeSkipped     = "skipped"
+)
+
+// Report is the capability matrix written to -report.
+type Report struct {
+	GeneratedAt    time.Time     `json:"generatedAt"`
+	ContribVersion string        `json:"contribVersion"`
+	Stores         []StoreReport `json:"stores"`
+}
+
+// StoreReport holds the outcome of each capability of one store.
+type StoreReport struct {
+	Store        string            `json:"store"`
+	Features     []string          `json:"features,omitempty"`
+	Capabilities map[string]string `json:"capabilities"`
+	Reason       string            `json:"reason,omitempty"`
+}
+
+var (
+	reportMu sync.Mutex
+	report   = Report{ContribVersion: contribVersion()}
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if *reportPath != "" {
+		report.GeneratedAt = time.Now().UTC()
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*reportPath, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			os.Stderr.WriteString("failed to write conformance report: " + err.Error() + "\n")
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func TestConformance(t *testing.T) {
+	for _, storeType := range strings.Split(*stores, ",") {
+		storeType = strings.TrimSpace(storeType)
+		if storeType == "" {
+			continue
+		}
+		t.Run(storeType, func(t *testing.T) {
+			result := StoreReport{Store: storeType, Capabilities: make(map[string]string)}
+			defer func() { addStoreReport(result) }()
+
+			props, reason := storeProperties(storeType)
+			if reason != "" {
+				result.Reason = reason
+				for _, capability := range capabilities {
+					result.Capabilities[capability] = outcomeSkipped
+				}
+				t.Skip(reason)
+			}
+
+			features, err := probeFeatures(storeType, props)
+			if err != nil {
+				result.Reason = err.Error()
+				for _, capability := range capabilities {
+					result.Capabilities[capability] = outcomeFail
+				}
+				t.Fatalf("failed to initialize %s store: %v", storeType, err)
+			}
+			for _, feature := range features {
+				result.Features = append(result.Features, string(feature))
+			}
+
+			for _, capability := range capabilities {
+				operations, declared, supported := capabilityOperations(capability, features)
+				if !supported {
+					result.Capabilities[capability] = outcomeUnsupported
+					continue
+				}
+				passed := t.Run(capability, func(t *testing.T) {
+					store, err := client.NewStore(storeType, logger.NewLogger("conformance-"+storeType))
+					if err != nil {
+						t.Fatal(err)
+					}
+					t.Cleanup(func() { closeStore(store) })
+
+					config, err := conformance.NewTestConfig(storeType, operations, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					conformance.ConformanceTests(t, props, newStoreView(store, declared), config)
+				})
+				if passed {
+					result.Capabilities[capability] = outcomePass
+				} else {
+					result.Capabilities[capability] = outcomeFail
+				}
+			}
+		})
+	}
+}
+
+// storeProperties returns the component metadata of a store type, or why the
+// store is skipped.
+func storeProperties(storeType string) (map[string]string, string) {
+	switch storeType {
+	case "scylladb", "cassandra":
+		hosts, port, hostsFlag := *scyllaHosts, *scyllaPort, "-scylla-hosts"
+		if storeType == "cassandra" {
+			hosts, port, hostsFlag = *cassandraHosts, *cassandraPort, "-cassandra-hosts"
+		}
+		if hosts == "" {
+			return nil, "no backend (set " + hostsFlag + ")"
+		}
+		return map[string]string{
+			"hosts":             hosts,
+			"port":              port,
+			"keyspace":          "dapr_conformance",
+			"replicationFactor": "1",
+		}, ""
+	case "alternator":
+		if *alternatorEndpoint == "" {
+			return nil, "no backend (set -alternator-endpoint)"
+		}
+		return map[string]string{"endpoint": *alternatorEndpoint, "table": "dapr_conformance"}, ""
+	case "etcd":
+		if *etcdEndpoints == "" {
+			return nil, "no backend (set -etcd-endpoints)"
+		}
+		return map[string]string{"endpoints": *etcdEndpoints, "keyPrefixPath": "dapr-conformance"}, ""
+	case "simulated":
+		return map[string]string{}, ""
+	default:
+		return nil, "unknown store type"
+	}
+}
+
+// probeFeatures initializes a store of the given type, retrying for -wait,
+// and returns the features it declares. Some depend on the metadata, so they
+// are only known after Init.
+func probeFeatures(storeType string, props map[string]string) ([]state.Feature, error) {
+	deadline := time.Now().Add(*wait)
+	for {
+		store, err := client.NewStore(storeType, logger.NewLogger("conformance-probe"))
+		if err != nil {
+			return nil, err
+		}
+		err = store.Init(context.Background(), state.Metadata{Base: metadata.Base{Properties: props}})
+		if err == nil {
+			features := store.Features()
+			closeStore(store)
+			return features, nil
+		}
+		closeStore(store)
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// capabilityOperations returns the conformance operations that exercise a
+// capability, the features a store declares to the suite while they run and
+// whether the store supports the capability.
+func capabilityOperations(capability string, features []state.Feature) ([]string, []state.Feature, bool) {
+	var feature state.Feature
+	switch capability {
+	case "crud":
+		return nil, nil, true
+	case "ttl":
+		// The pinned contrib version has no TTL feature; every store here honors ttlInSeconds
+		return []string{capability}, nil, true
+	case "etag":
+		feature = state.FeatureETag
+	case "first-write":
+		// The suite checks first-write only alongside ETags
+		return []string{"etag", capability}, []state.Feature{state.FeatureETag}, state.FeatureETag.IsPresent(features)
+	case "transaction":
+		feature = state.FeatureTransactional
+	case "query":
+		feature = state.FeatureQueryAPI
+	default:
+		return nil, nil, false
+	}
+	return []string{capability}, []state.Feature{feature}, feature.IsPresent(features)
+}
+
+func closeStore(store state.Store) {
+	if closer, ok := store.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func addStoreReport(result StoreReport) {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	report.Stores = append(report.Stores, result)
+	slices.SortFunc(report.Stores, func(a, b StoreReport) int { return strings.Compare(a.Store, b.Store) })
+}
+
+// contribVersion returns the components-contrib version the suite comes from.
+func contribVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == contribModule {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/utils"
+)
+
+// storeView is a store as one run of the suite sees it. It declares only the
+// features under test, since the suite checks that the others are absent, and
+// passes values to the store as bytes: the suite sets Go values in process,
+// while the component always receives them from daprd JSON-encoded.
+type storeView struct {
+	state.Store
+	features []state.Feature
+}
+
+// newStoreView wraps store, keeping Multi and Query when the features under
+// test need them.
+func newStoreView(store state.Store, features []state.Feature) state.Store {
+	view := &storeView{Store: store, features: features}
+	if transactional, ok := store.(state.TransactionalStore); ok && state.FeatureTransactional.IsPresent(features) {
+		return &transactionalView{storeView: view, transactional: transactional}
+	}
+	if querier, ok := store.(state.Querier); ok && state.FeatureQueryAPI.IsPresent(features) {
+		return &querierView{storeView: view, querier: querier}
+	}
+	return view
+}
+
+func (v *storeView) Features() []state.Feature {
+	return v.features
+}
+
+func (v *storeView) Set(ctx context.Context, req *state.SetRequest) error {
+	encoded, err := encodeSet(*req)
+	if err != nil {
+		return err
+	}
+	return v.Store.Set(ctx, &encoded)
+}
+
+func (v *storeView) BulkSet(ctx context.Context, reqs []state.SetRequest, opts state.BulkStoreOpts) error {
+	encoded := make([]state.SetRequest, len(reqs))
+	for i, req := range reqs {
+		var err error
+		if encoded[i], err = encodeSet(req); err != nil {
+			return err
+		}
+	}
+	return v.Store.BulkSet(ctx, encoded, opts)
+}
+
+type transactionalView struct {
+	*storeView
+	transactional state.TransactionalStore
+}
+
+func (v *transactionalView) Multi(ctx context.Context, req *state.TransactionalStateRequest) error {
+	encoded := *req
+	encoded.Operations = make([]state.TransactionalStateOperation, len(req.Operations))
+	for i, op := range req.Operations {
+		if set, ok := op.(state.SetRequest); ok {
+			var err error
+			if op, err = encodeSet(set); err != nil {
+				return err
+			}
+		}
+		encoded.Operations[i] = op
+	}
+	return v.transactional.Multi(ctx, &encoded)
+}
+
+type querierView struct {
+	*storeView
+	querier state.Querier
+}
+
+func (v *querierView) Query(ctx context.Context, req *state.QueryRequest) (*state.QueryResponse, error) {
+	return v.querier.Query(ctx, req)
+}
+
+// encodeSet returns req with its value encoded as daprd sends it.
+func encodeSet(req state.SetRequest) (state.SetRequest, error) {
+	value, err := utils.Marshal(req.Value, json.Marshal)
+	if err != nil {
+		return req, err
+	}
+	req.Value = value
+	return req, nil
+}
@@ -0,0 +1,797 @@
+// Command sidecar is a mock Dapr sidecar for end-to-end tests of the component's
+// Unix sockets. It starts the component binary, waits for the sockets of the
+// requested stores and drives them through the pluggable components gRPC API
+// the way daprd does, checking:
+//
+//   - registration: one socket per store, named after its component;
+//   - metadata propagation: Init properties reach the store, per component
+//     instance (x-component-instance header);
+//   - error mapping: the status codes daprd converts back into Dapr errors;
+//   - readiness: the health endpoint reports failed Init phases.
+//
+// Without backend flags only checks that need no database run, along with the
+// writes to the simulated store. With -scylla-hosts, -cassandra-hosts,
+// -alternator-endpoint or -etcd-endpoints it also writes to those backends.
+//
+//	go run ./tests/sidecar -stores scylladb,alternator
+//	go run ./tests/sidecar -stores scylladb -scylla-hosts localhost -scylla-port 9042
+//	go run ./tests/sidecar -stores cassandra -cassandra-hosts localhost -cassandra-port 9043
+//	go run ./tests/sidecar -stores etcd -etcd-endpoints localhost:2379
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+	"github.com/gocql/gocql"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Header daprd uses to address one component instance on a shared socket
+const instanceHeader = "x-component-instance"
+
+// Unreachable endpoint for Init checks that must fail without a backend
+const closedPort = "1"
+
+// Colors matching the shell test suites
+const (
+	green = "\033[0;32m"
+	red   = "\033[0;31m"
+	blue  = "\033[0;34m"
+	reset = "\033[0m"
+)
+
+type harness struct {
+	socketFolder string
+	healthPort   string
+	timeout      time.Duration
+	passed       int
+	failed       int
+}
+
+func main() {
+	binary := flag.String("binary", "", "Component binary; built from the current directory when empty")
+	stores := flag.String("stores", "scylladb,alternator", "Store types passed to the component as STORE_TYPES")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout for startup and each check")
+	scyllaHosts := flag.String("scylla-hosts", "", "ScyllaDB hosts for backend checks; skipped when empty")
+	scyllaPort := flag.String("scylla-port", "9042", "ScyllaDB port for backend checks")
+	cassandraHosts := flag.String("cassandra-hosts", "", "Cassandra hosts for backend checks; skipped when empty")
+	cassandraPort := flag.String("cassandra-port", "9042", "Cassandra port for backend checks")
+	alternatorEndpoint := flag.String("alternator-endpoint", "", "Alternator URL for backend checks; skipped when empty")
+	etcdEndpoints := flag.String("etcd-endpoints", "", "etcd endpoints for backend checks; skipped when empty")
+	flag.Parse()
+
+	backends := backends{
+		scyllaHosts:        *scyllaHosts,
+		scyllaPort:         *scyllaPort,
+		cassandraHosts:     *cassandraHosts,
+		cassandraPort:      *cassandraPort,
+		alternatorEndpoint: *alternatorEndpoint,
+		etcdEndpoints:      *etcdEndpoints,
+	}
+	os.Exit(run(*binary, *stores, *timeout, backends))
+}
+
+// backends are the databases the backend checks run against
+type backends struct {
+	scyllaHosts        string
+	scyllaPort         string
+	cassandraHosts     string
+	cassandraPort      string
+	alternatorEndpoint string
+	etcdEndpoints      string
+}
+
+func run(binary, stores string, timeout time.Duration, backends backends) int {
+	workDir, err := os.MkdirTemp("", "dapr-sidecar-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(workDir)
+
+	if binary == "" {
+		binary = filepath.Join(workDir, "component")
+		build := exec.Command("go", "build", "-o", binary, ".")
+		build.Stdout, build.Stderr = os.Stdout, os.Stderr
+		if err := build.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: building the component: %v\n", err)
+			return 1
+		}
+	}
+
+	h := &harness{socketFolder: filepath.Join(workDir, "sockets"), timeout: timeout}
+	if err := os.Mkdir(h.socketFolder, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if h.healthPort, err = freePort(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	logFile := filepath.Join(workDir, "component.log")
+	component, err := h.start(binary, stores, logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: starting the component: %v\n", err)
+		return 1
+	}
+	defer func() {
+		component.Process.Kill()
+		component.Wait()
+	}()
+
+	var storeTypes []string
+	for _, storeType := range strings.Split(stores, ",") {
+		storeTypes = append(storeTypes, strings.TrimSpace(storeType))
+	}
+
+	h.header("1. Registration")
+	if !h.checkRegistration(storeTypes) {
+		h.dumpLog(logFile)
+		return h.summary()
+	}
+
+	for _, storeType := range storeTypes {
+		conn, err := grpc.Dial("unix://"+h.socket(storeType), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			h.fail(fmt.Sprintf("%s: dial socket: %v", storeType, err))
+			continue
+		}
+
+		switch storeType {
+		case "scylladb":
+			h.header("2. ScyllaDB store (scylladb-state)")
+			h.checkCQL(conn, storeType, backends.scyllaHosts, backends.scyllaPort)
+		case "alternator":
+			h.header("3. Alternator store (alternator-state)")
+			h.checkAlternator(conn, backends.alternatorEndpoint)
+		case "cassandra":
+			h.header("4. Cassandra store (cassandra-state)")
+			h.checkCQL(conn, storeType, backends.cassandraHosts, backends.cassandraPort)
+		case "etcd":
+			h.header("5. etcd store (etcd-state)")
+			h.checkEtcd(conn, backends.etcdEndpoints)
+		case "simulated":
+			h.header("6. Simulated store (simulated-state)")
+			h.checkSimulated(conn)
+		default:
+			h.header(fmt.Sprintf("%s store", storeType))
+			h.checkPing(conn, storeType)
+		}
+		conn.Close()
+	}
+
+	h.header("7. Health endpoint")
+	h.checkReadiness(storeTypes)
+
+	if h.failed > 0 {
+		h.dumpLog(logFile)
+	}
+	return h.summary()
+}
+
+// start runs the component with its sockets in the harness' socket folder.
+func (h *harness) start(binary, stores, logFile string) (*exec.Cmd, error) {
+	log, err := os.Create(logFile)
+	if err != nil {
+		return nil, err
+	}
+
+	component := exec.Command(binary)
+	component.Env = append(os.Environ(),
+		"STORE_TYPES="+stores,
+		"DAPR_COMPONENT_SOCKETS_FOLDER="+h.socketFolder,
+		"HEALTH_PORT="+h.healthPort,
+	)
+	component.Stdout, component.Stderr = log, log
+	if err := component.Start(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	go func() {
+		component.Wait()
+		log.Close()
+	}()
+	return component, nil
+}
+
+// componentName returns the name a store type registers under.
+func componentName(storeType string) string {
+	return storeType + "-state"
+}
+
+func (h *harness) socket(storeType string) string {
+	return filepath.Join(h.socketFolder, componentName(storeType)+".sock")
+}
+
+// checkRegistration waits for one socket per store and checks that no other
+// socket appears.
+func (h *harness) checkRegistration(storeTypes []string) bool {
+	expected := make([]string, len(storeTypes))
+	for i, storeType := range storeTypes {
+		expected[i] = componentName(storeType) + ".sock"
+	}
+	slices.Sort(expected)
+
+	deadline := time.Now().Add(h.timeout)
+	var found []string
+	for time.Now().Before(deadline) {
+		entries, _ := os.ReadDir(h.socketFolder)
+		found = found[:0]
+		for _, entry := range entries {
+			found = append(found, entry.Name())
+		}
+		slices.Sort(found)
+		if len(found) >= len(expected) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if !slices.Equal(found, expected) {
+		h.fail(fmt.Sprintf("sockets %v, expected %v", found, expected))
+		return false
+	}
+	h.pass(fmt.Sprintf("sockets registered: %s", strings.Join(found, ", ")))
+	return true
+}
+
+// instance returns a context addressing the component instance id, as daprd
+// does for each Dapr component backed by the same socket.
+func (h *harness) instance(id string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	return metadata.AppendToOutgoingContext(ctx, instanceHeader, id), cancel
+}
+
+func (h *harness) checkPing(conn *grpc.ClientConn, storeType string) {
+	ctx, cancel := h.instance("ping")
+	defer cancel()
+
+	if _, err := proto.NewStateStoreClient(conn).Ping(ctx, &proto.PingRequest{}); err != nil {
+		h.fail(fmt.Sprintf("%s: Ping: %v", storeType, err))
+		return
+	}
+	h.pass(storeType + ": Ping")
+}
+
+func (h *harness) checkFeatures(client proto.StateStoreClient, storeType string, want ...string) {
+	ctx, cancel := h.instance("features")
+	defer cancel()
+
+	resp, err := client.Features(ctx, &proto.FeaturesRequest{})
+	if err != nil {
+		h.fail(fmt.Sprintf("%s: Features: %v", storeType, err))
+		return
+	}
+	for _, feature := range want {
+		if !slices.Contains(resp.Features, feature) {
+			h.fail(fmt.Sprintf("%s: Features %v lack %s", storeType, resp.Features, feature))
+			return
+		}
+	}
+	h.pass(fmt.Sprintf("%s: Features %v", storeType, resp.Features))
+}
+
+// checkInitError initializes a fresh instance with properties and expects
+// the error to mention every string in want, proving the properties reached
+// the store.
+func (h *harness) checkInitError(client proto.StateStoreClient, name, instance string, properties map[string]string, want ...string) {
+	ctx, cancel := h.instance(instance)
+	defer cancel()
+
+	_, err := client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{Properties: properties}})
+	if err == nil {
+		h.fail(name + ": Init succeeded, expected an error")
+		return
+	}
+	for _, s := range want {
+		if !strings.Contains(err.Error(), s) {
+			h.fail(fmt.Sprintf("%s: Init error %q does not mention %q", name, err, s))
+			return
+		}
+	}
+	h.pass(name)
+}
+
+// checkAllowlist checks that allowedOperations is enforced per component
+// instance before the store is reached, so it needs no backend.
+func (h *harness) checkAllowlist(conn *grpc.ClientConn, client proto.StateStoreClient, storeType string) {
+	h.checkInitError(client, storeType+": allowedOperations rejects unknown operations", "allowlist-typo",
+		map[string]string{"allowedOperations": "get,upsert"}, "unknown operation", "upsert")
+
+	ctx, cancel := h.instance("read-only")
+	defer cancel()
+
+	// Init fails without a backend, but the allowlist is already in place
+	client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{Properties: map[string]string{
+		"allowedOperations": "get,bulkGet,query",
+		"hosts":             "127.0.0.1",
+		"port":              closedPort,
+		"connectionTimeout": "1s",
+	}}})
+
+	_, err := client.Set(ctx, &proto.SetRequest{Key: "harness-key", Value: []byte("blocked")})
+	if got := status.Code(err); got != codes.PermissionDenied {
+		h.fail(fmt.Sprintf("%s: Set on a read-only instance: status %s (%v), expected PermissionDenied", storeType, got, err))
+		return
+	}
+	_, err = client.Get(ctx, &proto.GetRequest{Key: "harness-key"})
+	if got := status.Code(err); got == codes.PermissionDenied {
+		h.fail(storeType + ": Get on a read-only instance was denied")
+		return
+	}
+	// Administrative queries need their own operation, not query
+	_, err = proto.NewQueriableStateStoreClient(conn).Query(ctx, &proto.QueryRequest{
+		Query:    &proto.Query{Pagination: &proto.Pagination{Limit: 10}},
+		Metadata: map[string]string{"deletePrefix": "harness-"},
+	})
+	if got := status.Code(err); got != codes.PermissionDenied {
+		h.fail(fmt.Sprintf("%s: deletePrefix Query on a read-only instance: status %s (%v), expected PermissionDenied", storeType, got, err))
+		return
+	}
+	h.pass(storeType + ": allowedOperations blocks writes with PermissionDenied")
+}
+
+// checkCode calls fn and expects a status with code.
+func (h *harness) checkCode(name string, code codes.Code, fn func(context.Context) error) {
+	ctx, cancel := h.instance("codes")
+	defer cancel()
+
+	err := fn(ctx)
+	if got := status.Code(err); got != code {
+		h.fail(fmt.Sprintf("%s: status %s (%v), expected %s", name, got, err, code))
+		return
+	}
+	h.pass(fmt.Sprintf("%s: %s", name, code))
+}
+
+// checkCQL checks a store served by the CQL implementation: scylladb, or
+// cassandra, which is the same store with the Cassandra dialect.
+func (h *harness) checkCQL(conn *grpc.ClientConn, storeType, hosts, port string) {
+	client := proto.NewStateStoreClient(conn)
+
+	h.checkPing(conn, storeType)
+	h.checkFeatures(client, storeType, "ETAG", "TRANSACTIONAL")
+
+	h.checkInitError(client, storeType+": Init properties reach the store", "unreachable", map[string]string{
+		"hosts":             "127.0.0.1",
+		"port":              closedPort,
+		"connectionTimeout": "1s",
+	}, "failed to create session", "127.0.0.1:"+closedPort)
+
+	h.checkCode(storeType+": Get before Init", codes.Unknown, func(ctx context.Context) error {
+		_, err := client.Get(ctx, &proto.GetRequest{Key: "harness-key"})
+		return err
+	})
+
+	h.checkAllowlist(conn, client, storeType)
+
+	if hosts == "" {
+		hostsFlag := "-scylla-hosts"
+		if storeType == "cassandra" {
+			hostsFlag = "-cassandra-hosts"
+		}
+		h.info(fmt.Sprintf("%s: backend checks skipped (set %s)", storeType, hostsFlag))
+		return
+	}
+	h.checkCQLBackend(conn, storeType, hosts, port)
+}
+
+// checkCQLBackend writes to a real ScyllaDB or Cassandra through an
+// initialized instance and checks the codes of typed errors.
+func (h *harness) checkCQLBackend(conn *grpc.ClientConn, storeType, hosts, port string) {
+	client := proto.NewStateStoreClient(conn)
+	ctx, cancel := h.instance("backend")
+	defer cancel()
+
+	_, err := client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{Properties: map[string]string{
+		"hosts":             hosts,
+		"port":              port,
+		"keyspace":          "dapr_sidecar_harness",
+		"replicationFactor": "1",
+	}}})
+	if err != nil {
+		h.fail(fmt.Sprintf("%s: Init against %s:%s: %v", storeType, hosts, port, err))
+		return
+	}
+	h.pass(storeType + ": Init against " + hosts)
+
+	h.checkBackendWrites(ctx, client, storeType)
+	h.checkRetriedSet(ctx, client, storeType, hosts, port)
+
+	h.checkCode(storeType+": Query without queryScan", codes.Unimplemented, func(context.Context) error {
+		_, err := proto.NewQueriableStateStoreClient(conn).Query(ctx, &proto.QueryRequest{
+			Query: &proto.Query{Pagination: &proto.Pagination{Limit: 10}},
+		})
+		return err
+	})
+}
+
+func (h *harness) checkAlternator(conn *grpc.ClientConn, endpoint string) {
+	client := proto.NewStateStoreClient(conn)
+
+	h.checkPing(conn, "alternator")
+	h.checkFeatures(client, "alternator", "ETAG")
+
+	h.checkInitError(client, "alternator: Init validates properties", "missing-endpoint",
+		map[string]string{"table": "harness"}, "endpoint is required")
+	h.checkInitError(client, "alternator: Init properties reach the store", "unreachable", map[string]string{
+		"endpoint":       "http://127.0.0.1:" + closedPort,
+		"table":          "harness_propagation",
+		"createTable":    "false",
+		"requestTimeout": "1s",
+	}, "harness_propagation")
+
+	h.checkCode("alternator: Transact", codes.Unimplemented, func(ctx context.Context) error {
+		_, err := proto.NewTransactionalStateStoreClient(conn).Transact(ctx, &proto.TransactionalStateRequest{})
+		return err
+	})
+	h.checkCode("alternator: Query", codes.Unimplemented, func(ctx context.Context) error {
+		_, err := proto.NewQueriableStateStoreClient(conn).Query(ctx, &proto.QueryRequest{
+			Query: &proto.Query{Pagination: &proto.Pagination{Limit: 10}},
+		})
+		return err
+	})
+
+	if endpoint == "" {
+		h.info("alternator: backend checks skipped (set -alternator-endpoint)")
+		return
+	}
+
+	ctx, cancel := h.instance("backend")
+	defer cancel()
+	_, err := client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{Properties: map[string]string{
+		"endpoint": endpoint,
+		"table":    "dapr_sidecar_harness",
+	}}})
+	if err != nil {
+		h.fail(fmt.Sprintf("alternator: Init against %s: %v", endpoint, err))
+		return
+	}
+	h.pass("alternator: Init against " + endpoint)
+
+	h.checkBackendWrites(ctx, client, "alternator")
+}
+
+func (h *harness) checkEtcd(conn *grpc.ClientConn, endpoints string) {
+	client := proto.NewStateStoreClient(conn)
+
+	h.checkPing(conn, "etcd")
+	h.checkFeatures(client, "etcd", "ETAG", "TRANSACTIONAL")
+
+	h.checkInitError(client, "etcd: Init validates properties", "missing-endpoints",
+		map[string]string{"keyPrefixPath": "harness"}, "endpoints is required")
+	h.checkInitError(client, "etcd: Init properties reach the store", "unreachable", map[string]string{
+		"endpoints":   "127.0.0.1:" + closedPort,
+		"dialTimeout": "1s",
+	}, "failed to connect to etcd", "127.0.0.1:"+closedPort)
+
+	if endpoints == "" {
+		h.info("etcd: backend checks skipped (set -etcd-endpoints)")
+		return
+	}
+
+	ctx, cancel := h.instance("backend")
+	defer cancel()
+	_, err := client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{Properties: map[string]string{
+		"endpoints":     endpoints,
+		"keyPrefixPath": "dapr-sidecar-harness",
+	}}})
+	if err != nil {
+		h.fail(fmt.Sprintf("etcd: Init against %s: %v", endpoints, err))
+		return
+	}
+	h.pass("etcd: Init against " + endpoints)
+
+	h.checkBackendWrites(ctx, client, "etcd")
+}
+
+// checkSimulated runs the writes on the simulated store, which needs no
+// backend, and checks that its injected faults reach the sidecar with the
+// status codes of the real stores.
+func (h *harness) checkSimulated(conn *grpc.ClientConn) {
+	client := proto.NewStateStoreClient(conn)
+
+	h.checkPing(conn, "simulated")
+	h.checkFeatures(client, "simulated", "ETAG", "TRANSACTIONAL")
+
+	ctx, cancel := h.instance("backend")
+	defer cancel()
+	if _, err := client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{}}); err != nil {
+		h.fail(fmt.Sprintf("simulated: Init: %v", err))
+		return
+	}
+	h.pass("simulated: Init")
+	h.checkBackendWrites(ctx, client, "simulated")
+
+	errorCtx, cancel := h.instance("errors")
+	defer cancel()
+	_, err := client.Init(errorCtx, &proto.InitRequest{Metadata: &proto.MetadataRequest{Properties: map[string]string{
+		"errorRate":       "1",
+		"faultOperations": "get",
+	}}})
+	if err != nil {
+		h.fail(fmt.Sprintf("simulated: Init with errorRate: %v", err))
+		return
+	}
+	_, err = client.Get(errorCtx, &proto.GetRequest{Key: "sidecar-harness-errors"})
+	if st := status.Convert(err); st.Code() != codes.Unavailable {
+		h.fail(fmt.Sprintf("simulated: injected error: status %s (%v), expected Unavailable", st.Code(), err))
+	} else {
+		h.pass("simulated: injected errors map to Unavailable")
+	}
+
+	conflictCtx, cancel := h.instance("conflicts")
+	defer cancel()
+	_, err = client.Init(conflictCtx, &proto.InitRequest{Metadata: &proto.MetadataRequest{Properties: map[string]string{
+		"conflictRate": "1",
+	}}})
+	if err != nil {
+		h.fail(fmt.Sprintf("simulated: Init with conflictRate: %v", err))
+		return
+	}
+	key := "sidecar-harness-conflicts"
+	if _, err := client.Set(conflictCtx, &proto.SetRequest{Key: key, Value: []byte(`{}`)}); err != nil {
+		h.fail(fmt.Sprintf("simulated: Set: %v", err))
+		return
+	}
+	resp, err := client.Get(conflictCtx, &proto.GetRequest{Key: key})
+	if err != nil {
+		h.fail(fmt.Sprintf("simulated: Get: %v", err))
+		return
+	}
+	_, err = client.Set(conflictCtx, &proto.SetRequest{Key: key, Value: []byte(`{}`), Etag: resp.Etag})
+	if st := status.Convert(err); st.Code() != codes.FailedPrecondition || !hasETagViolation(st) {
+		h.fail(fmt.Sprintf("simulated: injected conflict: status %s (%v), expected FailedPrecondition with an etag violation", st.Code(), err))
+	} else {
+		h.pass("simulated: injected conflicts map to FailedPrecondition")
+	}
+}
+
+// checkBackendWrites runs a Set/Get/Delete round trip on an initialized
+// instance and checks that a stale ETag maps to the status daprd converts
+// into an ETag mismatch.
+func (h *harness) checkBackendWrites(ctx context.Context, client proto.StateStoreClient, storeType string) {
+	key := fmt.Sprintf("sidecar-harness-%d", time.Now().UnixNano())
+	value := []byte(`{"harness":true}`)
+
+	if _, err := client.Set(ctx, &proto.SetRequest{Key: key, Value: value}); err != nil {
+		h.fail(fmt.Sprintf("%s: Set: %v", storeType, err))
+		return
+	}
+	resp, err := client.Get(ctx, &proto.GetRequest{Key: key})
+	switch {
+	case err != nil:
+		h.fail(fmt.Sprintf("%s: Get: %v", storeType, err))
+		return
+	case string(resp.Data) != string(value):
+		h.fail(fmt.Sprintf("%s: Get returned %q, expected %q", storeType, resp.Data, value))
+		return
+	case resp.Etag == nil || resp.Etag.Value == "":
+		h.fail(storeType + ": Get returned no ETag")
+		return
+	}
+	h.pass(storeType + ": Set/Get round trip with ETag")
+
+	// A well-formed ETag for every store: etcd ETags are revisions, and no
+	// key is ever written at revision 1
+	_, err = client.Set(ctx, &proto.SetRequest{Key: key, Value: value, Etag: &proto.Etag{Value: "1"}})
+	if st := status.Convert(err); st.Code() != codes.FailedPrecondition || !hasETagViolation(st) {
+		h.fail(fmt.Sprintf("%s: stale ETag: status %s (%v), expected FailedPrecondition with an etag violation", storeType, st.Code(), err))
+	} else {
+		h.pass(storeType + ": stale ETag maps to FailedPrecondition")
+	}
+
+	if _, err := client.Delete(ctx, &proto.DeleteRequest{Key: key, Etag: resp.Etag}); err != nil {
+		h.fail(fmt.Sprintf("%s: Delete: %v", storeType, err))
+		return
+	}
+	h.pass(storeType + ": Delete with current ETag")
+}
+
+// checkRetriedSet simulates a Set retried after a newer Set of the same key:
+// it replays the first write directly in the table with the timestamp the
+// store gave it, and expects the newer value to survive.
+func (h *harness) checkRetriedSet(ctx context.Context, client proto.StateStoreClient, storeType, hosts, port string) {
+	cluster := gocql.NewCluster(strings.Split(hosts, ",")...)
+	if p, err := strconv.Atoi(port); err == nil {
+		cluster.Port = p
+	}
+	cluster.Keyspace = "dapr_sidecar_harness"
+	cluster.Timeout = h.timeout
+	session, err := cluster.CreateSession()
+	if err != nil {
+		h.fail(fmt.Sprintf("%s: connect for retried Set check: %v", storeType, err))
+		return
+	}
+	defer session.Close()
+
+	key := fmt.Sprintf("sidecar-harness-retry-%d", time.Now().UnixNano())
+	older, newer := []byte(`{"version":1}`), []byte(`{"version":2}`)
+
+	before := time.Now().UnixMicro()
+	if _, err := client.Set(ctx, &proto.SetRequest{Key: key, Value: older}); err != nil {
+		h.fail(fmt.Sprintf("%s: Set: %v", storeType, err))
+		return
+	}
+	after := time.Now().UnixMicro()
+
+	var written int64
+	if err := session.Query("SELECT WRITETIME(value_blob) FROM state WHERE key = ?", key).Scan(&written); err != nil {
+		h.fail(fmt.Sprintf("%s: read write time: %v", storeType, err))
+		return
+	}
+	if written < before || written > after {
+		h.fail(fmt.Sprintf("%s: Set timestamp %d outside the request (%d-%d)", storeType, written, before, after))
+	} else {
+		h.pass(storeType + ": Set timestamp taken when the request arrived")
+	}
+
+	if _, err := client.Set(ctx, &proto.SetRequest{Key: key, Value: newer}); err != nil {
+		h.fail(fmt.Sprintf("%s: Set: %v", storeType, err))
+		return
+	}
+	// The late attempt of the first Set reaches a replica after the second
+	err = session.Query("INSERT INTO state (key, value_blob, etag, last_modified) VALUES (?, ?, ?, ?)",
+		key, older, "retried", time.Now()).WithTimestamp(written).Exec()
+	if err != nil {
+		h.fail(fmt.Sprintf("%s: replay first Set: %v", storeType, err))
+		return
+	}
+
+	resp, err := client.Get(ctx, &proto.GetRequest{Key: key})
+	switch {
+	case err != nil:
+		h.fail(fmt.Sprintf("%s: Get: %v", storeType, err))
+	case string(resp.Data) != string(newer):
+		h.fail(fmt.Sprintf("%s: retried Set resurrected %q over %q", storeType, resp.Data, newer))
+	default:
+		h.pass(storeType + ": retried Set cannot overwrite a newer value")
+	}
+
+	if _, err := client.Delete(ctx, &proto.DeleteRequest{Key: key}); err != nil {
+		h.fail(fmt.Sprintf("%s: Delete: %v", storeType, err))
+	}
+}
+
+// readinessInstance is the readiness report of one store instance.
+type readinessInstance struct {
+	Ready bool           `json:"ready"`
+	Init  map[string]any `json:"init"`
+}
+
+// checkReadiness checks the health endpoint. Every CQL store has an instance
+// whose Init failed to connect, so readiness must be refused and report the
+// phase Init failed in.
+func (h *harness) checkReadiness(storeTypes []string) {
+	client := &http.Client{Timeout: h.timeout}
+	base := "http://" + net.JoinHostPort("127.0.0.1", h.healthPort)
+
+	resp, err := client.Get(base + "/healthz")
+	if err != nil {
+		h.fail(fmt.Sprintf("liveness: %v", err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		h.fail(fmt.Sprintf("liveness: HTTP %d, expected 200", resp.StatusCode))
+	} else {
+		h.pass("liveness answers 200")
+	}
+
+	resp, err = client.Get(base + "/readyz")
+	if err != nil {
+		h.fail(fmt.Sprintf("readiness: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	var report struct {
+		Ready  bool                           `json:"ready"`
+		Stores map[string][]readinessInstance `json:"stores"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		h.fail(fmt.Sprintf("readiness: decode: %v", err))
+		return
+	}
+
+	for _, storeType := range storeTypes {
+		if storeType != "scylladb" && storeType != "cassandra" {
+			continue
+		}
+		name := componentName(storeType)
+		found := slices.ContainsFunc(report.Stores[name], func(instance readinessInstance) bool {
+			return instance.Init["state"] == "failed" && instance.Init["phase"] == "connecting"
+		})
+		switch {
+		case resp.StatusCode != http.StatusServiceUnavailable || report.Ready:
+			h.fail(fmt.Sprintf("%s: readiness: HTTP %d (ready %v), expected 503 after a failed Init", storeType, resp.StatusCode, report.Ready))
+		case !found:
+			h.fail(fmt.Sprintf("%s: readiness does not report the Init that failed while connecting: %+v", storeType, report.Stores[name]))
+		default:
+			h.pass(storeType + ": readiness reports the failed Init phase")
+		}
+	}
+}
+
+// freePort returns a TCP port that is free on the loopback interface.
+func freePort() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	return port, err
+}
+
+// hasETagViolation reports whether st carries the BadRequest detail daprd
+// requires to map a status to an ETag error.
+func hasETagViolation(st *status.Status) bool {
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+		for _, violation := range badRequest.FieldViolations {
+			if violation.Field == "etag" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (h *harness) header(title string) {
+	fmt.Printf("\n%s%s%s\n----------------------------------------\n", blue, title, reset)
+}
+
+func (h *harness) pass(message string) {
+	fmt.Printf("%s✅ PASS%s: %s\n", green, reset, message)
+	h.passed++
+}
+
+func (h *harness) fail(message string) {
+	fmt.Printf("%s❌ FAIL%s: %s\n", red, reset, message)
+	h.failed++
+}
+
+func (h *harness) info(message string) {
+	fmt.Printf("%sℹ️  INFO%s: %s\n", blue, reset, message)
+}
+
+func (h *harness) dumpLog(logFile string) {
+	data, err := os.ReadFile(logFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		h.info(fmt.Sprintf("cannot read component log: %v", err))
+		return
+	}
+	fmt.Printf("\nComponent log:\n%s\n", data)
+}
+
+func (h *harness) summary() int {
+	fmt.Printf("\nTotal: %d, passed: %d, failed: %d\n", h.passed+h.failed, h.passed, h.failed)
+	if h.failed > 0 {
+		return 1
+	}
+	return 0
+}
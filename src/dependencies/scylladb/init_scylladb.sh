@@ -90,6 +90,7 @@ USE $SCYLLA_KEYSPACE;
 CREATE TABLE IF NOT EXISTS state (
     key TEXT PRIMARY KEY,
     value TEXT,
+    value_blob BLOB,
     etag TEXT,
     last_modified TIMESTAMP
 ) WITH comment = 'Dapr state store table';"